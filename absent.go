@@ -0,0 +1,204 @@
+package gopixi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// What readers do when they need a tile that has not been written, as in sparse layers or layers that
+// were only partially appended.
+type AbsentTilePolicy int
+
+const (
+	AbsentTileError AbsentTilePolicy = iota // Fail with ErrTileNotFound. This is the default without channel fill values.
+	AbsentTileZero                          // Read every sample of the tile as zero.
+	AbsentTileFill                          // Read every sample of the tile as the given fill sample.
+)
+
+type readOptions struct {
+	absent        AbsentTilePolicy
+	absentSet     bool // whether the policy was given, rather than defaulting for the layer
+	fill          Sample
+	skipChecksums bool
+	channels      []string
+	concurrency   int
+	stride        []int
+}
+
+type ReadOption interface {
+	applyRead(*readOptions)
+}
+
+type absentTileOption struct {
+	policy AbsentTilePolicy
+	fill   Sample
+}
+
+func (o absentTileOption) applyRead(opts *readOptions) {
+	opts.absent = o.policy
+	opts.fill = o.fill
+	opts.absentSet = true
+}
+
+// Makes reads fail with ErrTileNotFound when they need a tile that has not been written. This is the
+// default for layers without channel fill values.
+func WithAbsentTileError() ReadOption {
+	return absentTileOption{policy: AbsentTileError}
+}
+
+// Makes reads return zero for every sample of tiles that have not been written.
+func WithAbsentTileZeros() ReadOption {
+	return absentTileOption{policy: AbsentTileZero}
+}
+
+// Makes reads return the given sample, which must have a value for every channel of the layer read, for
+// every sample of tiles that have not been written.
+func WithAbsentTileFill(fill Sample) ReadOption {
+	return absentTileOption{policy: AbsentTileFill, fill: fill}
+}
+
+type verifyChecksumsOption bool
+
+func (o verifyChecksumsOption) applyRead(opts *readOptions) {
+	opts.skipChecksums = !bool(o)
+}
+
+// Sets whether reads compare each tile with the checksum stored after it, failing with ErrChecksum on a
+// mismatch. Checksums are verified by default; skipping them saves a pass over every tile read, for
+// trusted storage where decoding speed matters more than detecting silent corruption.
+func WithVerifyChecksums(verify bool) ReadOption {
+	return verifyChecksumsOption(verify)
+}
+
+func newReadOptions(opts []ReadOption) readOptions {
+	options := readOptions{}
+	for _, o := range opts {
+		o.applyRead(&options)
+	}
+	return options
+}
+
+// The options for reading the layer: unless an absent tile policy was given, tiles of layers whose channels
+// have fill values (see Channel.FillValue) that were never written are read as the FillSample of the layer.
+func (o readOptions) forLayer(l Layer) readOptions {
+	if !o.absentSet && l.Channels.HasFillValues() {
+		o.absent = AbsentTileFill
+		o.fill = l.Channels.FillSample()
+	}
+	return o
+}
+
+// Builds the decoded data of a tile of the layer in which every sample is the given fill sample, or zero
+// if the fill is nil.
+func (l Layer) FillTile(h Header, tile int, fill Sample) ([]byte, error) {
+	data := make([]byte, l.slotsTileSize(tile))
+	if fill == nil {
+		return data, nil
+	}
+	if len(fill) != len(l.Channels) {
+		return nil, ErrFormat(fmt.Sprintf("fill sample has %d values, layer has %d channels", len(fill), len(l.Channels)))
+	}
+	samples := l.Dimensions.TileSamples() + l.Dimensions.HaloSamples()
+	if l.Separated {
+		channelIndex := tile / l.Dimensions.Tiles()
+		channel := l.Channels[channelIndex]
+		for inTile := range samples {
+			if channel.Type == ChannelBool {
+				PackBool(fill[channelIndex].(bool), data, inTile)
+			} else {
+				channel.PutValue(fill[channelIndex], h.ByteOrder, data[inTile*channel.Size():])
+			}
+		}
+		return data, nil
+	}
+	offset := 0
+	for range samples {
+		for i, channel := range l.Channels {
+			channel.PutValue(fill[i], h.ByteOrder, data[offset:])
+			offset += channel.Size()
+		}
+	}
+	return data, nil
+}
+
+// Wraps a tile accessor so that tiles which have not been written are read according to an
+// AbsentTilePolicy instead of failing. Synthesized tiles are built once for each channel and shared.
+type AbsentFillLayer struct {
+	base    TileAccessLayer
+	options readOptions
+
+	lock   sync.Mutex
+	filled map[int][]byte // by channel index for separated layers, or 0 for contiguous layers
+}
+
+var _ TileAccessLayer = (*AbsentFillLayer)(nil)
+
+// Wraps the base accessor, applying the absent tile policy of the given options, or reading the channel fill
+// values of the layer if it has them and no policy is given. Returns an error if a fill sample does not match
+// the channels of the layer.
+func NewAbsentFillLayer(base TileAccessLayer, opts ...ReadOption) (*AbsentFillLayer, error) {
+	options := newReadOptions(opts).forLayer(base.Layer())
+	if options.absent == AbsentTileFill && len(options.fill) != len(base.Layer().Channels) {
+		return nil, ErrFormat(fmt.Sprintf("fill sample has %d values, layer has %d channels", len(options.fill), len(base.Layer().Channels)))
+	}
+	return &AbsentFillLayer{base: base, options: options, filled: map[int][]byte{}}, nil
+}
+
+// Reads the stored tiles of the layer through a cache of the given size, reading tiles that have not been
+// written as the fill values of its channels, or as zero for layers without fill values.
+func newFilledReadLayer(backing io.ReadSeeker, h Header, l Layer, cacheSize int) *AbsentFillLayer {
+	opts := []ReadOption{WithAbsentTileZeros()}
+	if l.Channels.HasFillValues() {
+		opts = nil
+	}
+	return &AbsentFillLayer{
+		base:    NewFifoCacheReadLayer(backing, h, l, cacheSize),
+		options: newReadOptions(opts).forLayer(l),
+		filled:  map[int][]byte{},
+	}
+}
+
+func (a *AbsentFillLayer) Layer() Layer {
+	return a.base.Layer()
+}
+
+func (a *AbsentFillLayer) Header() Header {
+	return a.base.Header()
+}
+
+func (a *AbsentFillLayer) Tile(tile int) ([]byte, error) {
+	data, err := a.base.Tile(tile)
+	if err == nil || a.options.absent == AbsentTileError {
+		return data, err
+	}
+	var notFound ErrTileNotFound
+	if !errors.As(err, &notFound) {
+		return nil, err
+	}
+	return a.fillTile(tile)
+}
+
+func (a *AbsentFillLayer) fillTile(tile int) ([]byte, error) {
+	layer := a.Layer()
+	key := 0
+	if layer.Separated {
+		key = tile / layer.Dimensions.Tiles()
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if data, ok := a.filled[key]; ok {
+		return data, nil
+	}
+	var fill Sample
+	if a.options.absent == AbsentTileFill {
+		fill = a.options.fill
+	}
+	data, err := layer.FillTile(a.Header(), tile, fill)
+	if err != nil {
+		return nil, err
+	}
+	a.filled[key] = data
+	return data, nil
+}
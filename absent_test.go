@@ -0,0 +1,100 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestReadRegionAbsentTiles(t *testing.T) {
+	for _, planar := range []bool{false, true} {
+		buf := buffer.NewBuffer(10)
+		opts := []LayerOption{}
+		if planar {
+			opts = append(opts, WithPlanar())
+		}
+		channels := ChannelSet{{Name: "v", Type: ChannelInt16}, {Name: "valid", Type: ChannelBool}}
+		layers := []Layer{NewLayer("sparse", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, channels, opts...)}
+		written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+			return Sample{int16(coord[0]), true}
+		})
+
+		// punch a hole where the second tile position would be
+		layer := written.Layers[0]
+		layer.TileBytes = append([]int64{}, layer.TileBytes...)
+		for channel := range layer.DiskTiles() / layer.Dimensions.Tiles() {
+			layer.TileBytes[1+channel*layer.Dimensions.Tiles()] = 0
+		}
+		region := FullRegion(layer.Dimensions)
+
+		var notFound ErrTileNotFound
+		if _, err := layer.ReadRegion(buf, written.Header, region); !errors.As(err, &notFound) {
+			t.Errorf("planar %v: expected ErrTileNotFound by default, got %v", planar, err)
+		}
+		if _, err := layer.ReadRegion(buf, written.Header, region, WithAbsentTileZeros(), WithAbsentTileError()); !errors.As(err, &notFound) {
+			t.Errorf("planar %v: expected the last option to take precedence, got %v", planar, err)
+		}
+
+		zeros, err := layer.ReadRegion(buf, written.Header, region, WithAbsentTileZeros())
+		if err != nil {
+			t.Fatal(err)
+		}
+		filled, err := layer.ReadRegion(buf, written.Header, region, WithAbsentTileFill(Sample{int16(-999), false}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for x := range 8 {
+			if x < 4 {
+				if zeros[x][0] != int16(x) || filled[x][0] != int16(x) || filled[x][1] != true {
+					t.Errorf("planar %v: expected written sample at %d, got %v and %v", planar, x, zeros[x], filled[x])
+				}
+				continue
+			}
+			if zeros[x][0] != int16(0) || zeros[x][1] != false {
+				t.Errorf("planar %v: expected zero sample at %d, got %v", planar, x, zeros[x])
+			}
+			if filled[x][0] != int16(-999) || filled[x][1] != false {
+				t.Errorf("planar %v: expected fill sample at %d, got %v", planar, x, filled[x])
+			}
+		}
+
+		if _, err := layer.ReadRegion(buf, written.Header, region, WithAbsentTileFill(Sample{int16(1)})); err == nil {
+			t.Errorf("planar %v: expected error for fill sample with missing channels", planar)
+		}
+	}
+}
+
+func TestReadLayerAbsentTiles(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("sparse", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint16}}, WithPlanar())}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint16(coord[0])}
+	})
+	written.Layers[0].TileBytes[0] = 0
+	written.RegisterReadTransform("sparse", ScaleOffset{Channel: "v", Scale: 2})
+
+	access, err := written.ReadLayer(buf, 0, 1, WithAbsentTileFill(Sample{uint16(50)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, expected := range map[int]float64{1: 100, 6: 12} {
+		sample, err := SampleAt(access, SampleCoordinate{x})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sample[0] != expected {
+			t.Errorf("expected %v at %d, got %v", expected, x, sample[0])
+		}
+	}
+
+	strict, err := written.ReadLayer(buf, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notFound ErrTileNotFound
+	if _, err := SampleAt(strict, SampleCoordinate{1}); !errors.As(err, &notFound) {
+		t.Errorf("expected ErrTileNotFound without an absent tile policy, got %v", err)
+	}
+}
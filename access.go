@@ -127,9 +127,23 @@ func ChannelAt(accessor TileAccessLayer, coord SampleCoordinate, channelIndex in
 
 func SetSampleAt(modifier TileModifierLayer, coord SampleCoordinate, values Sample) error {
 	layer := modifier.Layer()
-	// Update Min/Max for all channels
+	if err := layer.CheckSample(coord, values); err != nil {
+		return err
+	}
+	if layer.Channels.HasStrings() {
+		return ErrUnsupported("setting samples of string channels, which are written in tile order")
+	}
+	if len(layer.NonFinite) > 0 {
+		var err error
+		if values, err = layer.applyNonFinite(coord, values); err != nil {
+			return err
+		}
+	}
+	// Update Min/Max for all channels, whose moments and histograms can no longer be known without reading
+	// every sample
 	for channelIndex, value := range values {
 		layer.Channels[channelIndex] = layer.Channels[channelIndex].WithMinMax(value)
+		layer.Channels[channelIndex].clearDistribution()
 	}
 
 	tileSelector := coord.ToTileSelector(layer.Dimensions)
@@ -169,8 +183,22 @@ func SetSampleAt(modifier TileModifierLayer, coord SampleCoordinate, values Samp
 
 func SetChannelAt(modifier TileModifierLayer, coord SampleCoordinate, channelIndex int, value any) error {
 	layer := modifier.Layer()
-	// Update Min/Max for the channel
+	if err := layer.Channels[channelIndex].CheckValue(value); err != nil {
+		return withCoordinate(err, coord)
+	}
+	if layer.Channels[channelIndex].Type == ChannelString {
+		return ErrUnsupported("setting samples of string channels, which are written in tile order")
+	}
+	if len(layer.NonFinite) > 0 {
+		var err error
+		if value, _, err = layer.applyNonFiniteValue(coord, channelIndex, value); err != nil {
+			return err
+		}
+	}
+	// Update Min/Max for the channel, whose moments and histogram can no longer be known without reading
+	// every sample
 	layer.Channels[channelIndex] = layer.Channels[channelIndex].WithMinMax(value)
+	layer.Channels[channelIndex].clearDistribution()
 
 	tileSelector := coord.ToTileSelector(layer.Dimensions)
 	channel := layer.Channels[channelIndex]
@@ -225,6 +253,13 @@ type IterativeLayerWriter interface {
 	SetSample(values Sample)
 }
 
+// An IterativeLayerWriter that can wait for everything set so far to reach its backing stream, as required
+// by Pixi.Checkpoint.
+type FlushableLayerWriter interface {
+	IterativeLayerWriter
+	Flush() error
+}
+
 type IterativeLayerReadWriter interface {
 	IterativeLayerReader
 	IterativeLayerWriter
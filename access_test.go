@@ -2,6 +2,8 @@ package gopixi
 
 import (
 	"encoding/binary"
+	"errors"
+	"slices"
 	"testing"
 
 	"github.com/gracefulearth/gopixi/internal/buffer"
@@ -622,3 +624,21 @@ func TestSampleInto_ReuseSlice(t *testing.T) {
 		}
 	}
 }
+
+func TestSetSampleAtValueTypeError(t *testing.T) {
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "a", Type: ChannelUint8}, {Name: "b", Type: ChannelBool}})
+	memLayer := NewMemoryLayer(buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize4), layer)
+	var mismatch ErrValueType
+	if err := SetSampleAt(memLayer, SampleCoordinate{1}, Sample{uint8(1), 1}); !errors.As(err, &mismatch) || mismatch.Channel != "b" {
+		t.Errorf("expected type mismatch for channel b, got %v", err)
+	}
+	if err := SetChannelAt(memLayer, SampleCoordinate{2}, 0, "1"); !errors.As(err, &mismatch) || mismatch.Got != "string" || !slices.Equal(mismatch.Coordinate, SampleCoordinate{2}) {
+		t.Errorf("expected type mismatch at coordinate 2, got %v", err)
+	}
+	if err := SetSampleAt(memLayer, SampleCoordinate{4}, Sample{uint8(1), true}); !errors.As(err, &ErrSampleCoordinateOutOfBounds{}) {
+		t.Errorf("expected out of bounds error, got %v", err)
+	}
+	if memLayer.Layer().Channels[0].Min != nil {
+		t.Errorf("expected rejected samples not to update channel statistics, got min %v", memLayer.Layer().Channels[0].Min)
+	}
+}
@@ -0,0 +1,278 @@
+package gopixi
+
+import (
+	"cmp"
+	"container/heap"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Tracks which tiles and regions of a served dataset are read the most, within bounded memory. Attached to
+// a Pixi as its AccessStats, it records the reads of a TileServer and StatsServer; other servers record
+// theirs with RecordTile and RecordRegion. Tile counts may be overestimated by at most their Error. An
+// AccessStats is safe for concurrent use, and its methods do nothing for a nil one.
+type AccessStats struct {
+	lock     sync.Mutex
+	counters map[tileAccessKey]*tileAccessCounter
+	queue    tileAccessQueue // least read first
+	capacity int
+	regions  []RegionAccess // the most recent regions, as a ring buffer
+	next     int            // the position in regions of the next region recorded
+	reads    int64
+}
+
+// The reads of a tile counted by an AccessStats.
+type TileAccess struct {
+	Layer int   `json:"layer"` // The index of the layer in the file.
+	Tile  int   `json:"tile"`  // The disk tile index.
+	Count int64 `json:"count"` // The number of reads of the tile, overestimated by at most Error.
+	Error int64 `json:"error"` // The most reads counted for the tile that may have been of other tiles it replaced.
+}
+
+// A region of a layer read at the given time, as recorded by an AccessStats.
+type RegionAccess struct {
+	Layer  int       `json:"layer"`
+	Region Region    `json:"region"`
+	Time   time.Time `json:"time"`
+}
+
+// The report of an AccessStats served over HTTP, as JSON.
+type AccessReport struct {
+	Reads   int64          `json:"reads"`   // The number of tile reads recorded.
+	Tiles   []TileAccess   `json:"tiles"`   // The most read tiles, most read first.
+	Regions []RegionAccess `json:"regions"` // The most recent regions, oldest first.
+}
+
+type tileAccessKey struct {
+	layer int
+	tile  int
+}
+
+type tileAccessCounter struct {
+	key   tileAccessKey
+	count int64
+	error int64
+	index int // in the queue
+}
+
+var _ http.Handler = (*AccessStats)(nil)
+
+// Creates access statistics counting the reads of up to tiles distinct tiles exactly (the most read of
+// which are tracked approximately once more tiles than that are read), and keeping the last regions read.
+func NewAccessStats(tiles int, regions int) *AccessStats {
+	return &AccessStats{
+		counters: map[tileAccessKey]*tileAccessCounter{},
+		capacity: max(tiles, 1),
+		regions:  make([]RegionAccess, 0, max(regions, 0)),
+	}
+}
+
+// Records a read of the tile with the given disk tile index of the layer with the given index. Once every
+// counter is in use, a tile without one takes over the counter of the least read tile, inheriting its
+// count as its Error.
+func (s *AccessStats) RecordTile(layerIndex int, tile int) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reads++
+	key := tileAccessKey{layer: layerIndex, tile: tile}
+	if counter, ok := s.counters[key]; ok {
+		counter.count++
+		heap.Fix(&s.queue, counter.index)
+		return
+	}
+	if len(s.queue) < s.capacity {
+		counter := &tileAccessCounter{key: key, count: 1}
+		heap.Push(&s.queue, counter)
+		s.counters[key] = counter
+		return
+	}
+	least := s.queue[0]
+	delete(s.counters, least.key)
+	least.key, least.error = key, least.count
+	least.count++
+	heap.Fix(&s.queue, 0)
+	s.counters[key] = least
+}
+
+// Records a read of the region of the layer with the given index. Only the region itself is recorded; the
+// tiles it spans are recorded with RecordTile.
+func (s *AccessStats) RecordRegion(layerIndex int, region Region) {
+	if s == nil || cap(s.regions) == 0 {
+		return
+	}
+	access := RegionAccess{
+		Layer:  layerIndex,
+		Region: Region{Start: slices.Clone(region.Start), End: slices.Clone(region.End)},
+		Time:   time.Now(),
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.regions) < cap(s.regions) {
+		s.regions = append(s.regions, access)
+	} else {
+		s.regions[s.next] = access
+	}
+	s.next = (s.next + 1) % cap(s.regions)
+}
+
+// Records a read of the region of the layer, and of the disk tiles holding the given channels of the
+// samples it spans.
+func (s *AccessStats) recordRegionTiles(layerIndex int, layer Layer, channels []int, region Region) {
+	if s == nil {
+		return
+	}
+	s.RecordRegion(layerIndex, region)
+	tiles := region.stridedTiles(layer.Dimensions, nil)
+	if slices.ContainsFunc(tiles, func(t []int) bool { return len(t) == 0 }) {
+		return
+	}
+	position := make([]int, len(tiles))
+	tile := TileCoordinate{Tile: make([]int, len(tiles)), InTile: make([]int, len(tiles))}
+	for {
+		for d := range tiles {
+			tile.Tile[d] = tiles[d][position[d]]
+		}
+		index := tile.ToTileSelector(layer.Dimensions).Tile
+		if layer.Separated {
+			for _, c := range channels {
+				s.RecordTile(layerIndex, index+c*layer.Dimensions.Tiles())
+			}
+		} else {
+			s.RecordTile(layerIndex, index)
+		}
+
+		d := 0
+		for ; d < len(position); d++ {
+			position[d]++
+			if position[d] < len(tiles[d]) {
+				break
+			}
+			position[d] = 0
+		}
+		if d == len(position) {
+			return
+		}
+	}
+}
+
+// The number of tile reads recorded.
+func (s *AccessStats) Reads() int64 {
+	if s == nil {
+		return 0
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.reads
+}
+
+// The n most read tiles (or all counted tiles, if n is not positive), most read first.
+func (s *AccessStats) TopTiles(n int) []TileAccess {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	top := make([]TileAccess, 0, len(s.queue))
+	for _, counter := range s.queue {
+		top = append(top, TileAccess{Layer: counter.key.layer, Tile: counter.key.tile, Count: counter.count, Error: counter.error})
+	}
+	s.lock.Unlock()
+	slices.SortFunc(top, func(a, b TileAccess) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Layer, b.Layer), cmp.Compare(a.Tile, b.Tile))
+	})
+	if n > 0 && n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// The most recent regions read, oldest first.
+func (s *AccessStats) RecentRegions() []RegionAccess {
+	if s == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.regions) < cap(s.regions) {
+		return slices.Clone(s.regions)
+	}
+	return append(slices.Clone(s.regions[s.next:]), s.regions[:s.next]...)
+}
+
+// Forgets every read recorded, such as at the start of each reporting period.
+func (s *AccessStats) Reset() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	clear(s.counters)
+	s.queue = s.queue[:0]
+	s.regions = s.regions[:0]
+	s.next, s.reads = 0, 0
+}
+
+// Serves the statistics as an AccessReport in JSON, limited to the number of tiles given by the query
+// parameter "top" (every counted tile if absent).
+func (s *AccessStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	top := 0
+	if r.URL.Query().Has("top") {
+		var err error
+		if top, err = strconv.Atoi(r.URL.Query().Get("top")); err != nil || top < 1 {
+			http.Error(w, "top must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	body, err := json.Marshal(AccessReport{Reads: s.Reads(), Tiles: s.TopTiles(top), Regions: s.RecentRegions()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == http.MethodGet {
+		w.Write(body)
+	}
+}
+
+type tileAccessQueue []*tileAccessCounter
+
+var _ heap.Interface = (*tileAccessQueue)(nil)
+
+func (q tileAccessQueue) Len() int {
+	return len(q)
+}
+
+func (q tileAccessQueue) Less(i, j int) bool {
+	return q[i].count < q[j].count
+}
+
+func (q tileAccessQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *tileAccessQueue) Push(x any) {
+	counter := x.(*tileAccessCounter)
+	counter.index = len(*q)
+	*q = append(*q, counter)
+}
+
+func (q *tileAccessQueue) Pop() any {
+	old := *q
+	counter := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return counter
+}
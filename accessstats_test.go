@@ -0,0 +1,120 @@
+package gopixi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestAccessStatsTopTiles(t *testing.T) {
+	stats := NewAccessStats(3, 0)
+	for tile, reads := range map[int]int{0: 5, 1: 3, 2: 1} {
+		for range reads {
+			stats.RecordTile(0, tile)
+		}
+	}
+	stats.RecordTile(1, 0)
+	expected := []TileAccess{{Layer: 0, Tile: 0, Count: 5}, {Layer: 0, Tile: 1, Count: 3}, {Layer: 1, Tile: 0, Count: 2, Error: 1}}
+	if top := stats.TopTiles(0); !reflect.DeepEqual(top, expected) {
+		t.Errorf("expected the least read tile to be replaced, got %+v", top)
+	}
+	if top := stats.TopTiles(1); len(top) != 1 || top[0].Tile != 0 || top[0].Count != 5 {
+		t.Errorf("expected the most read tile, got %+v", top)
+	}
+	if reads := stats.Reads(); reads != 10 {
+		t.Errorf("expected 10 reads, got %d", reads)
+	}
+
+	// a heavily read tile is found even among many read once
+	for tile := range 100 {
+		stats.RecordTile(2, tile)
+		stats.RecordTile(2, 1000)
+	}
+	if top := stats.TopTiles(1); top[0] != (TileAccess{Layer: 2, Tile: 1000, Count: top[0].Count, Error: top[0].Error}) || top[0].Count-top[0].Error > 100 || top[0].Count < 100 {
+		t.Errorf("expected the heavily read tile to be counted within its error, got %+v", top[0])
+	}
+
+	stats.Reset()
+	if stats.Reads() != 0 || len(stats.TopTiles(0)) != 0 {
+		t.Error("expected resetting to forget every read")
+	}
+
+	// a nil AccessStats records nothing
+	var none *AccessStats
+	none.RecordTile(0, 0)
+	none.RecordRegion(0, Region{})
+	if none.Reads() != 0 || none.TopTiles(0) != nil || none.RecentRegions() != nil {
+		t.Error("expected nil access statistics to be empty")
+	}
+}
+
+func TestAccessStatsRecentRegions(t *testing.T) {
+	stats := NewAccessStats(1, 2)
+	for i := range 3 {
+		stats.RecordRegion(i, Region{Start: SampleCoordinate{i}, End: SampleCoordinate{i + 1}})
+	}
+	regions := stats.RecentRegions()
+	if len(regions) != 2 || regions[0].Layer != 1 || regions[1].Layer != 2 || !slices.Equal(regions[1].Region.End, SampleCoordinate{3}) {
+		t.Errorf("expected the two most recent regions, oldest first, got %+v", regions)
+	}
+	if regions[0].Time.After(regions[1].Time) || regions[0].Time.IsZero() {
+		t.Errorf("expected regions to be timed in order, got %v and %v", regions[0].Time, regions[1].Time)
+	}
+}
+
+func TestAccessStatsServers(t *testing.T) {
+	for name, opts := range map[string][]LayerOption{"contiguous": nil, "separated": {WithPlanar()}} {
+		buf, pixi := newEditTestPixi(t, opts...)
+		pixi.AccessStats = NewAccessStats(16, 4)
+		source := buffer.NewBufferFrom(buf.Bytes())
+
+		tiles := httptest.NewServer(NewTileServer(source, pixi, 4))
+		if _, err := FetchTile(tiles.Client(), tiles.URL, 1, pixi.Layers[1], 0); err != nil {
+			t.Fatal(err)
+		}
+		tiles.Close()
+
+		// the region spans tiles 1 and 4 of channel a, the second column of tiles
+		summaries := httptest.NewServer(NewStatsServer(source, pixi, 4))
+		if _, status := fetchSummary(t, summaries.URL+"?layer=0&channel=a&start=3,0&end=5,3"); status != http.StatusOK {
+			t.Fatalf("%s: expected 200 OK, got %d", name, status)
+		}
+		summaries.Close()
+
+		expected := []TileAccess{{Layer: 0, Tile: 1, Count: 1}, {Layer: 0, Tile: 4, Count: 1}, {Layer: 1, Tile: 0, Count: 1}}
+		if top := pixi.AccessStats.TopTiles(0); !reflect.DeepEqual(top, expected) {
+			t.Errorf("%s: expected the served and summarized tiles %+v, got %+v", name, expected, top)
+		}
+		if regions := pixi.AccessStats.RecentRegions(); len(regions) != 1 || !slices.Equal(regions[0].Region.Start, SampleCoordinate{3, 0}) {
+			t.Errorf("%s: expected the summarized region, got %+v", name, regions)
+		}
+
+		report := httptest.NewServer(pixi.AccessStats)
+		response, err := http.Get(report.URL + "?top=2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded AccessReport
+		if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if decoded.Reads != 3 || !reflect.DeepEqual(decoded.Tiles, expected[:2]) || len(decoded.Regions) != 1 {
+			t.Errorf("%s: unexpected report %+v", name, decoded)
+		}
+		response, err = http.Get(report.URL + "?top=none")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected an invalid top to be rejected, got %d", name, response.StatusCode)
+		}
+		report.Close()
+	}
+}
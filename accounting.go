@@ -0,0 +1,57 @@
+package gopixi
+
+import "fmt"
+
+// The storage used by a layer, broken down for storage-cost accounting.
+type LayerBytes struct {
+	Name    string
+	Header  int64 // The layer header, excluding the tile index: name, dimensions, and channels.
+	Index   int64 // The tile index in the layer header: the size and offset of every tile.
+	Stored  int64 // The stored (compressed) tile data of written tiles, including tile checksums.
+	Logical int64 // The uncompressed size of the written tiles.
+	Tiles   int   // The number of disk tiles in the layer.
+	Written int   // The number of disk tiles that have been written.
+}
+
+// The total number of bytes the layer occupies in the file.
+func (b LayerBytes) Total() int64 {
+	return b.Header + b.Index + b.Stored
+}
+
+// The ratio of the logical size of the written tiles to their stored size, or zero if none are written.
+func (b LayerBytes) CompressionRatio() float64 {
+	if b.Stored == 0 {
+		return 0
+	}
+	return float64(b.Logical) / float64(b.Stored)
+}
+
+func (b LayerBytes) String() string {
+	return fmt.Sprintf("%s: %d bytes (header %d, index %d, stored %d, logical %d, %d/%d tiles)",
+		b.Name, b.Total(), b.Header, b.Index, b.Stored, b.Logical, b.Written, b.Tiles)
+}
+
+// Measures the storage used by the layer in a file with the given header, from its metadata alone.
+func (l Layer) Bytes(h Header) LayerBytes {
+	b := LayerBytes{Name: l.Name, Tiles: l.DiskTiles()}
+	b.Index = 2 * int64(b.Tiles) * int64(h.OffsetSize)
+	b.Header = int64(l.HeaderSize(h)) - b.Index
+	for tile, size := range l.TileBytes {
+		if size == 0 {
+			continue
+		}
+		b.Written++
+		b.Stored += size + int64(h.Checksum.Size())
+		b.Logical += int64(l.DiskTileSize(tile))
+	}
+	return b
+}
+
+// Measures the storage used by every layer in the file, in order, from its metadata alone.
+func (p *Pixi) LayerBytes() []LayerBytes {
+	bytes := make([]LayerBytes, len(p.Layers))
+	for i, l := range p.Layers {
+		bytes[i] = l.Bytes(p.Header)
+	}
+	return bytes
+}
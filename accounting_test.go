@@ -0,0 +1,52 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestLayerBytes(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.LittleEndian, OffsetSize8)
+	layers := []Layer{
+		NewLayer("raw", DimensionSet{{Name: "x", Size: 10, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint16}}),
+		NewLayer("packed", DimensionSet{{Name: "x", Size: 64, TileSize: 32}}, ChannelSet{{Name: "a", Type: ChannelUint8}, {Name: "b", Type: ChannelUint8}}, WithPlanar(), WithCompression(CompressionFlate)),
+	}
+	written := writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		if layer == 0 {
+			return Sample{uint16(coord[0])}
+		}
+		return Sample{uint8(0), uint8(1)}
+	})
+
+	accounts := written.LayerBytes()
+	if len(accounts) != 2 {
+		t.Fatalf("expected 2 layers, got %d", len(accounts))
+	}
+	raw := accounts[0]
+	if raw.Name != "raw" || raw.Tiles != 3 || raw.Written != 3 || raw.Index != 3*2*8 || raw.Logical != 3*8 || raw.Stored != 3*(8+4) {
+		t.Errorf("unexpected raw layer accounting %v", raw)
+	}
+	if raw.Header+raw.Index != int64(written.Layers[0].HeaderSize(header)) {
+		t.Errorf("expected header and index to add up to the layer header size")
+	}
+	packed := accounts[1]
+	if packed.Tiles != 4 || packed.Logical != 64*2 || packed.CompressionRatio() <= 1 {
+		t.Errorf("expected packed layer to be compressed, got %v (ratio %v)", packed, packed.CompressionRatio())
+	}
+
+	total := int64(header.DiskSize())
+	for _, account := range accounts {
+		total += account.Total()
+	}
+	if total != int64(len(buf.Bytes())) {
+		t.Errorf("expected layer totals to account for the whole file, got %d of %d bytes", total, len(buf.Bytes()))
+	}
+
+	empty := NewLayer("empty", DimensionSet{{Name: "x", Size: 4, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}}).Bytes(header)
+	if empty.Written != 0 || empty.Stored != 0 || empty.CompressionRatio() != 0 {
+		t.Errorf("unexpected accounting for unwritten layer %v", empty)
+	}
+}
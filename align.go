@@ -0,0 +1,210 @@
+package gopixi
+
+import (
+	"fmt"
+	"math"
+)
+
+// The tolerance AlignAxes allows unless another is given with WithAxisTolerance, as a fraction of the step.
+const DefaultAxisTolerance float64 = 1e-6
+
+type alignOptions struct {
+	tolerance float64
+}
+
+type AlignOption interface {
+	applyAlign(*alignOptions)
+}
+
+type axisToleranceOption struct {
+	tolerance float64
+}
+
+func (o axisToleranceOption) applyAlign(opts *alignOptions) {
+	opts.tolerance = o.tolerance
+}
+
+// Sets how far apart axis steps and origins may be while still being considered equal by AlignAxes, as a
+// fraction of the step of the axis.
+func WithAxisTolerance(tolerance float64) AlignOption {
+	return axisToleranceOption{tolerance: math.Abs(tolerance)}
+}
+
+// How the sample grid of one layer lies on the grid of another, as computed by AlignAxes.
+type AxisAlignment struct {
+	// For each dimension, the index in the second layer of the first sample of the first layer. Offsets are
+	// negative where the first layer extends before the start of the second.
+	Offsets []int
+	Within  bool // Every sample of the first layer lies within the second.
+	Covers  bool // Every sample of the second layer lies within the first.
+	sizeA   []int
+	sizeB   []int
+}
+
+// Verifies that two layers share a compatible sample grid, and computes how the grids align. The layers must
+// have the same dimensions in the same order, with axes of the same units and calendars whose origins are a
+// whole number of steps apart, and one grid must lie within the other. Returns an ErrAxisMismatch describing
+// the first dimension that does not align.
+func AlignAxes(a Layer, b Layer, opts ...AlignOption) (AxisAlignment, error) {
+	options := alignOptions{tolerance: DefaultAxisTolerance}
+	for _, o := range opts {
+		o.applyAlign(&options)
+	}
+	if len(a.Dimensions) != len(b.Dimensions) {
+		return AxisAlignment{}, ErrAxisMismatch{Kind: AxisMismatchDimensions,
+			Detail: fmt.Sprintf("layer '%s' has %d dimensions, layer '%s' has %d", a.Name, len(a.Dimensions), b.Name, len(b.Dimensions))}
+	}
+
+	alignment := AxisAlignment{Within: true, Covers: true}
+	for d, dimA := range a.Dimensions {
+		dimB := b.Dimensions[d]
+		if dimA.Name != dimB.Name {
+			return AxisAlignment{}, ErrAxisMismatch{Dimension: dimA.Name, Kind: AxisMismatchDimensions,
+				Detail: fmt.Sprintf("dimension %d of layer '%s' is '%s'", d, b.Name, dimB.Name)}
+		}
+		offset, err := alignAxis(dimA, dimB, options.tolerance)
+		if err != nil {
+			return AxisAlignment{}, err
+		}
+		alignment.Offsets = append(alignment.Offsets, offset)
+		alignment.sizeA = append(alignment.sizeA, dimA.Size)
+		alignment.sizeB = append(alignment.sizeB, dimB.Size)
+		alignment.Within = alignment.Within && offset >= 0 && offset+dimA.Size <= dimB.Size
+		alignment.Covers = alignment.Covers && offset <= 0 && offset+dimA.Size >= dimB.Size
+	}
+	if !alignment.Within && !alignment.Covers {
+		return AxisAlignment{}, ErrAxisMismatch{Kind: AxisMismatchExtent,
+			Detail: fmt.Sprintf("neither layer '%s' nor layer '%s' lies within the other", a.Name, b.Name)}
+	}
+	return alignment, nil
+}
+
+// The index in the second dimension of the first index of the first, along their axes.
+func alignAxis(a Dimension, b Dimension, tolerance float64) (int, error) {
+	if a.Axis == nil && b.Axis == nil {
+		return 0, nil
+	}
+	if a.Axis == nil || b.Axis == nil {
+		return 0, ErrAxisMismatch{Dimension: a.Name, Kind: AxisMismatchAxis, Detail: "only one layer has an axis"}
+	}
+	if a.Axis.Unit != b.Axis.Unit {
+		return 0, ErrAxisMismatch{Dimension: a.Name, Kind: AxisMismatchUnit, Detail: fmt.Sprintf("'%s' and '%s'", a.Axis.Unit, b.Axis.Unit)}
+	}
+	if a.Axis.Calendar != b.Axis.Calendar {
+		return 0, ErrAxisMismatch{Dimension: a.Name, Kind: AxisMismatchUnit, Detail: fmt.Sprintf("calendars %v and %v", a.Axis.Calendar, b.Axis.Calendar)}
+	}
+	if a.Axis.Coordinates != nil || b.Axis.Coordinates != nil {
+		return alignCoordinates(a, b, tolerance)
+	}
+	minA, okMinA := a.Axis.Type.ToFloat64(a.Axis.Minimum)
+	stepA, okStepA := a.Axis.Type.ToFloat64(a.Axis.Step)
+	minB, okMinB := b.Axis.Type.ToFloat64(b.Axis.Minimum)
+	stepB, okStepB := b.Axis.Type.ToFloat64(b.Axis.Step)
+	if !okMinA || !okStepA || !okMinB || !okStepB || a.Axis.Type.Base() == ChannelBool || b.Axis.Type.Base() == ChannelBool {
+		return 0, ErrAxisMismatch{Dimension: a.Name, Kind: AxisMismatchAxis, Detail: "axis values are not numeric"}
+	}
+	if stepA == 0 || math.Abs(stepA-stepB) > tolerance*math.Abs(stepA) {
+		return 0, ErrAxisMismatch{Dimension: a.Name, Kind: AxisMismatchStep, Detail: fmt.Sprintf("%v and %v", stepA, stepB)}
+	}
+	position := (minA - minB) / stepB
+	offset := math.Round(position)
+	if math.Abs(position-offset) > tolerance {
+		return 0, ErrAxisMismatch{Dimension: a.Name, Kind: AxisMismatchOrigin,
+			Detail: fmt.Sprintf("%v is %v steps from %v", minA, position, minB)}
+	}
+	return int(offset), nil
+}
+
+// The index in the second dimension of the first index of the first, for axes of which either has explicit
+// coordinates. The first value of one must lie within the tolerance of a value of the other, as a fraction of
+// the spacing of their neighbors, and so must every value where the dimensions overlap.
+func alignCoordinates(a Dimension, b Dimension, tolerance float64) (int, error) {
+	valuesA, okA := axisValues(a)
+	valuesB, okB := axisValues(b)
+	if !okA || !okB {
+		return 0, ErrAxisMismatch{Dimension: a.Name, Kind: AxisMismatchAxis, Detail: "axis values are not numeric"}
+	}
+	matches := func(x float64, values []float64, i int) bool {
+		spacing := math.Inf(1)
+		if i > 0 {
+			spacing = math.Abs(values[i] - values[i-1])
+		}
+		if i+1 < len(values) {
+			spacing = min(spacing, math.Abs(values[i+1]-values[i]))
+		}
+		if math.IsInf(spacing, 1) {
+			spacing = 0
+		}
+		return math.Abs(x-values[i]) <= tolerance*spacing
+	}
+	offset, found := 0, false
+	for i := range valuesB {
+		if matches(valuesA[0], valuesB, i) {
+			offset, found = i, true
+			break
+		}
+	}
+	for i := 1; !found && i < len(valuesA); i++ {
+		if matches(valuesB[0], valuesA, i) {
+			offset, found = -i, true
+		}
+	}
+	if !found {
+		return 0, ErrAxisMismatch{Dimension: a.Name, Kind: AxisMismatchOrigin,
+			Detail: fmt.Sprintf("%v is not a coordinate of the other axis", valuesA[0])}
+	}
+	for i := max(0, -offset); i < len(valuesA) && i+offset < len(valuesB); i++ {
+		if !matches(valuesA[i], valuesB, i+offset) {
+			return 0, ErrAxisMismatch{Dimension: a.Name, Kind: AxisMismatchStep,
+				Detail: fmt.Sprintf("coordinate %d is %v and %v", i, valuesA[i], valuesB[i+offset])}
+		}
+	}
+	return offset, nil
+}
+
+// The value of the axis of the dimension at every index as float64 values, if they are numeric.
+func axisValues(d Dimension) ([]float64, bool) {
+	if d.Axis.Type.Base() == ChannelBool {
+		return nil, false
+	}
+	values := make([]float64, d.Size)
+	for i := range values {
+		var ok bool
+		if values[i], ok = d.Axis.Type.ToFloat64(d.Axis.StepValue(i)); !ok {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// The coordinate in the second layer of the sample at the given coordinate in the first layer.
+func (al AxisAlignment) ToSecond(coord SampleCoordinate) SampleCoordinate {
+	converted := make(SampleCoordinate, len(coord))
+	for d, c := range coord {
+		converted[d] = c + al.Offsets[d]
+	}
+	return converted
+}
+
+// The coordinate in the first layer of the sample at the given coordinate in the second layer.
+func (al AxisAlignment) ToFirst(coord SampleCoordinate) SampleCoordinate {
+	converted := make(SampleCoordinate, len(coord))
+	for d, c := range coord {
+		converted[d] = c - al.Offsets[d]
+	}
+	return converted
+}
+
+// The regions of the first and second layers covering the samples the layers have in common.
+func (al AxisAlignment) Overlap() (first Region, second Region) {
+	dims := len(al.Offsets)
+	first = Region{Start: make(SampleCoordinate, dims), End: make(SampleCoordinate, dims)}
+	second = Region{Start: make(SampleCoordinate, dims), End: make(SampleCoordinate, dims)}
+	for d, offset := range al.Offsets {
+		second.Start[d] = max(offset, 0)
+		second.End[d] = min(offset+al.sizeA[d], al.sizeB[d])
+		first.Start[d] = second.Start[d] - offset
+		first.End[d] = second.End[d] - offset
+	}
+	return first, second
+}
@@ -0,0 +1,99 @@
+package gopixi
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func alignTestLayer(name string, lonSize int, lonMin float64, latSize int, latMin float64) Layer {
+	return NewLayer(name, DimensionSet{
+		{Name: "lon", Size: lonSize, TileSize: 4, Axis: &Axis{Type: ChannelFloat64, Minimum: lonMin, Step: 0.1, Unit: "degrees_east"}},
+		{Name: "lat", Size: latSize, TileSize: 4, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(latMin), Step: float32(-0.5), Unit: "degrees_north"}},
+	}, ChannelSet{{Name: "v", Type: ChannelFloat32}})
+}
+
+func TestAlignAxes(t *testing.T) {
+	full := alignTestLayer("full", 20, 10, 10, 45)
+	sub := alignTestLayer("sub", 5, 10.3, 4, 44)
+
+	alignment, err := AlignAxes(sub, full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(alignment.Offsets, []int{3, 2}) || !alignment.Within || alignment.Covers {
+		t.Errorf("unexpected alignment %+v", alignment)
+	}
+	if coord := alignment.ToSecond(SampleCoordinate{1, 1}); !slices.Equal(coord, SampleCoordinate{4, 3}) {
+		t.Errorf("expected {4, 3} in the second layer, got %v", coord)
+	}
+	if coord := alignment.ToFirst(SampleCoordinate{4, 3}); !slices.Equal(coord, SampleCoordinate{1, 1}) {
+		t.Errorf("expected {1, 1} in the first layer, got %v", coord)
+	}
+	first, second := alignment.Overlap()
+	if first.Samples() != 20 || !slices.Equal(second.Start, SampleCoordinate{3, 2}) || !slices.Equal(second.End, SampleCoordinate{8, 6}) {
+		t.Errorf("unexpected overlap %v and %v", first, second)
+	}
+
+	reversed, err := AlignAxes(full, sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(reversed.Offsets, []int{-3, -2}) || reversed.Within || !reversed.Covers {
+		t.Errorf("unexpected reversed alignment %+v", reversed)
+	}
+
+	same, err := AlignAxes(full, alignTestLayer("copy", 20, 10+1e-9, 10, 45))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !same.Within || !same.Covers {
+		t.Errorf("expected identical grids to cover each other, got %+v", same)
+	}
+
+	plain := NewLayer("plain", DimensionSet{{Name: "x", Size: 4}, {Name: "y", Size: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	if alignment, err := AlignAxes(plain, plain); err != nil || !slices.Equal(alignment.Offsets, []int{0, 0}) {
+		t.Errorf("expected dimensions without axes to align at zero, got %+v, %v", alignment, err)
+	}
+}
+
+func TestAlignAxesMismatches(t *testing.T) {
+	full := alignTestLayer("full", 20, 10, 10, 45)
+	step := alignTestLayer("step", 5, 10, 4, 45)
+	step.Dimensions[0].Axis = &Axis{Type: ChannelFloat64, Minimum: 10.0, Step: 0.2, Unit: "degrees_east"}
+	unit := alignTestLayer("unit", 5, 10, 4, 45)
+	unit.Dimensions[1].Axis = &Axis{Type: ChannelFloat32, Minimum: float32(45), Step: float32(-0.5), Unit: "m"}
+	missing := alignTestLayer("missing", 5, 10, 4, 45)
+	missing.Dimensions[1].Axis = nil
+	renamed := alignTestLayer("renamed", 5, 10, 4, 45)
+	renamed.Dimensions[0].Name = "x"
+
+	cases := []struct {
+		layer     Layer
+		kind      AxisMismatch
+		dimension string
+	}{
+		{step, AxisMismatchStep, "lon"},
+		{unit, AxisMismatchUnit, "lat"},
+		{missing, AxisMismatchAxis, "lat"},
+		{renamed, AxisMismatchDimensions, "x"},
+		{alignTestLayer("origin", 5, 10.05, 4, 45), AxisMismatchOrigin, "lon"},
+		{alignTestLayer("extent", 5, 11.8, 4, 45), AxisMismatchExtent, ""},
+		{NewLayer("flat", DimensionSet{{Name: "lon", Size: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}}), AxisMismatchDimensions, ""},
+	}
+	for _, tc := range cases {
+		_, err := AlignAxes(tc.layer, full)
+		var mismatch ErrAxisMismatch
+		if !errors.As(err, &mismatch) {
+			t.Errorf("%s: expected an axis mismatch, got %v", tc.layer.Name, err)
+			continue
+		}
+		if mismatch.Kind != tc.kind || mismatch.Dimension != tc.dimension {
+			t.Errorf("%s: expected %s mismatch of '%s', got %v", tc.layer.Name, tc.kind, tc.dimension, mismatch)
+		}
+	}
+
+	if _, err := AlignAxes(alignTestLayer("close", 5, 10.1+0.004, 4, 45), full, WithAxisTolerance(0.05)); err != nil {
+		t.Errorf("expected origins within tolerance to align, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package gopixi
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The delay between the frames of an animation unless another is given in AnimationOptions.
+const DefaultFrameDelay = 100 * time.Millisecond
+
+// Describes how ExportGIF and ExportFrames render the frames of an animation.
+type AnimationOptions struct {
+	RenderOptions
+	// The plane animated through, whose indices in the first two dimensions and the animated dimension are
+	// ignored. A nil coordinate selects index zero of every other dimension.
+	At    SampleCoordinate
+	Delay time.Duration // The time each frame is shown, DefaultFrameDelay if zero.
+	// The number of times a GIF animation repeats after it is first shown, forever if zero, or never if
+	// negative, as for gif.GIF.
+	LoopCount int
+	// The labels drawn around each frame written by ExportFrames, as by RenderFigure, or none if nil. The
+	// frames of GIF animations are never labeled.
+	Labels *FigureLabels
+}
+
+// Writes an animated GIF showing each index of the named dimension of the layer in turn, with every frame
+// rendered as by RenderPlane and shaded with the same range of values.
+func ExportGIF(w io.Writer, layer TileAccessLayer, dimension string, options AnimationOptions) error {
+	planes, channel, low, high, err := animationFrames(layer, dimension, options)
+	if err != nil {
+		return err
+	}
+	delay := options.Delay
+	if delay <= 0 {
+		delay = DefaultFrameDelay
+	}
+	animation := &gif.GIF{LoopCount: options.LoopCount}
+	for _, plane := range planes {
+		img, err := renderPlane(layer, channel, plane, low, high, options.RenderOptions)
+		if err != nil {
+			return err
+		}
+		animation.Image = append(animation.Image, img)
+		animation.Delay = append(animation.Delay, int(max(delay/(10*time.Millisecond), 1)))
+		animation.Disposal = append(animation.Disposal, gif.DisposalBackground)
+	}
+	return gif.EncodeAll(w, animation)
+}
+
+// Writes every frame of the animation described for ExportGIF to the directory as a PNG file named
+// frame_00000.png, frame_00001.png, and so on, creating the directory if needed, and returns the paths of
+// the frames written. The delay of the options is not used.
+func ExportFrames(dir string, layer TileAccessLayer, dimension string, options AnimationOptions) ([]string, error) {
+	planes, channel, low, high, err := animationFrames(layer, dimension, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(planes))
+	for i, plane := range planes {
+		var img image.Image
+		if options.Labels != nil {
+			img, err = renderFigure(layer, channel, plane, low, high, options.RenderOptions, *options.Labels)
+		} else {
+			img, err = renderPlane(layer, channel, plane, low, high, options.RenderOptions)
+		}
+		if err != nil {
+			return paths, err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("frame_%05d.png", i))
+		if err := writeFramePng(path, img); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func writeFramePng(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// The plane of each frame of an animation along the named dimension, along with the rendered channel and
+// the range of values shading every frame.
+func animationFrames(layer TileAccessLayer, dimension string, options AnimationOptions) ([]SampleCoordinate, int, float64, float64, error) {
+	l := layer.Layer()
+	channel, err := renderChannel(l, options.RenderOptions)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	dimIndex := l.Dimensions.Index(dimension)
+	if dimIndex < 0 {
+		return nil, 0, 0, 0, ErrFormat(fmt.Sprintf("layer '%s' has no dimension '%s'", l.Name, dimension))
+	}
+	if dimIndex < 2 {
+		return nil, 0, 0, 0, ErrFormat(fmt.Sprintf("dimension '%s' spans the rendered plane and cannot be animated", dimension))
+	}
+	at := options.At
+	if at != nil && len(at) == len(l.Dimensions) {
+		at = append(SampleCoordinate{}, at...)
+		at[dimIndex] = 0
+	}
+	origin, err := renderCoordinate(l, at)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+
+	planes := make([]SampleCoordinate, l.Dimensions[dimIndex].Size)
+	for i := range planes {
+		planes[i] = append(SampleCoordinate{}, origin...)
+		planes[i][dimIndex] = i
+	}
+	low, high, err := renderRange(layer, channel, planes, options.RenderOptions)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	return planes, channel, low, high, nil
+}
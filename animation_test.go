@@ -0,0 +1,76 @@
+package gopixi
+
+import (
+	"bytes"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExportGIF(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 3, TileSize: 3}, {Name: "y", Size: 2, TileSize: 2}, {Name: "band", Size: 2, TileSize: 1}, {Name: "time", Size: 4, TileSize: 2}}
+	values := renderTestLayer(t, dims, ChannelSet{{Name: "v", Type: ChannelInt16}}, func(coord SampleCoordinate) float64 {
+		return float64(coord[0] + 10*coord[3] + 1000*coord[2])
+	})
+
+	// without statistics, the range spans the values of every frame
+	unscaled, err := NewTransformedReadLayer(values, 4, ScaleOffset{Channel: "v", Scale: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = ExportGIF(&buf, unscaled, "time", AnimationOptions{At: SampleCoordinate{0, 0, 1, 3}, Delay: 250 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	animation, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != 4 || animation.Delay[0] != 25 || animation.LoopCount != 0 {
+		t.Fatalf("unexpected animation of %d frames with delays %v", len(animation.Image), animation.Delay)
+	}
+	// every frame is shaded with the range of the whole animation, from 1000 in the first to 1032 in the last
+	first, last := animation.Image[0], animation.Image[3]
+	if first.ColorIndexAt(0, 0) != 1 || last.ColorIndexAt(2, 1) != 255 || first.ColorIndexAt(2, 0) == 255 || last.ColorIndexAt(0, 0) == 1 {
+		t.Errorf("frames are not shaded with a shared range: first %v, last %v", first.Pix, last.Pix)
+	}
+
+	if err := ExportGIF(&buf, values, "x", AnimationOptions{}); err == nil {
+		t.Error("expected error for animating a dimension of the plane")
+	}
+	if err := ExportGIF(&buf, values, "depth", AnimationOptions{}); err == nil {
+		t.Error("expected error for a missing dimension")
+	}
+}
+
+func TestExportFrames(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 3, TileSize: 3}, {Name: "y", Size: 2, TileSize: 2}, {Name: "time", Size: 3, TileSize: 1}}
+	values := renderTestLayer(t, dims, ChannelSet{{Name: "v", Type: ChannelUint8}}, func(coord SampleCoordinate) float64 {
+		return float64(coord[0] + coord[2])
+	})
+
+	dir := filepath.Join(t.TempDir(), "frames")
+	paths, err := ExportFrames(dir, values, "time", AnimationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 || filepath.Base(paths[2]) != "frame_00002.png" {
+		t.Fatalf("unexpected frame paths %v", paths)
+	}
+	file, err := os.Open(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 3 || img.Bounds().Dy() != 2 {
+		t.Errorf("unexpected frame bounds %v", img.Bounds())
+	}
+}
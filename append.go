@@ -0,0 +1,60 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Reads the metadata of the existing Pixi file in the stream so that new tags and layers can be appended to
+// it without moving existing layers or tiles. The options set how the appended layers are written, as for
+// Create. Files described only by a footer need a new one written with AppendFooter once appending is done.
+func ReadPixiForAppend(rw io.ReadWriteSeeker, opts ...CreateOption) (*Pixi, error) {
+	options := createOptions{}
+	for _, o := range opts {
+		o.applyCreate(&options)
+	}
+
+	if _, err := rw.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var start Header
+	if err := start.ReadHeader(rw); err != nil {
+		return nil, ErrFormat(fmt.Sprintf("reading pixi header: %s", err))
+	}
+	if _, err := rw.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	pixi, err := ReadPixi(rw)
+	if err != nil {
+		return nil, err
+	}
+	if start.FirstLayerOffset != pixi.Header.FirstLayerOffset || start.FirstTagsOffset != pixi.Header.FirstTagsOffset {
+		// read from the footer: its tags and layer headers become the chains linked from the start header
+		if err := start.OverwriteOffsets(rw, pixi.Header.FirstLayerOffset, pixi.Header.FirstTagsOffset); err != nil {
+			return nil, err
+		}
+	}
+
+	pixi.HeaderPadding = options.headerPadding
+	pixi.PreviewSize = options.previewSize
+	pixi.TileHistory = options.tileHistory
+	pixi.Deterministic = options.deterministic
+	return pixi, nil
+}
+
+// Opens the Pixi file at the given path for reading and writing, returning the file handle along with the
+// metadata read from it, to which new tags and layers can then be appended as described for
+// ReadPixiForAppend.
+func OpenAppend(path string, opts ...CreateOption) (*os.File, *Pixi, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	pixi, err := ReadPixiForAppend(file, opts...)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, pixi, nil
+}
@@ -0,0 +1,147 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendTestLayer(name string) Layer {
+	return NewLayer(name, DimensionSet{{Name: "x", Size: 6, TileSize: 4}, {Name: "y", Size: 5, TileSize: 4}},
+		ChannelSet{{Name: "v", Type: ChannelInt32}}, WithCompression(CompressionFlate))
+}
+
+func appendTestValue(layer int, coord SampleCoordinate) int32 {
+	return int32(layer*1000 + coord[0]*10 + coord[1])
+}
+
+func appendTestGenerator(layer int) func(writer IterativeLayerWriter) error {
+	return func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{appendTestValue(layer, writer.Coordinate())})
+		}
+		return nil
+	}
+}
+
+func checkAppendedFile(t *testing.T, path string, layers int) {
+	t.Helper()
+	file, pixi, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if len(pixi.Layers) != layers || pixi.AllTags()["source"] != "test" {
+		t.Fatalf("read %d layers and tags %v, expected %d layers", len(pixi.Layers), pixi.AllTags(), layers)
+	}
+	for i := range layers {
+		values, err := pixi.ReadLayer(file, i, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for coord := range pixi.Layers[i].Dimensions.SampleCoordinates() {
+			if sample, err := SampleAt(values, coord); err != nil || sample[0] != appendTestValue(i, coord) {
+				t.Fatalf("layer %d sample %v: got %v (%v)", i, coord, sample, err)
+			}
+		}
+	}
+}
+
+func TestOpenAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "append.pixi")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pixi, err := Create(file, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.AppendTags(file, map[string]string{"source": "test"}); err != nil {
+		t.Fatal(err)
+	}
+	layer := appendTestLayer("first")
+	if err := pixi.AppendIterativeLayer(file, layer, NewTileOrderWriteIterator(file, pixi.Header, layer), appendTestGenerator(0)); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		file, pixi, err := OpenAppend(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		layer := appendTestLayer("derived")
+		if err := pixi.AppendIterativeLayer(file, layer, NewTileOrderWriteIterator(file, pixi.Header, layer), appendTestGenerator(i)); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		checkAppendedFile(t, path, i+1)
+	}
+
+	// the tiles of the first layer are untouched, with only the header linking to the next layer rewritten
+	appended, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tileStart, tileEnd := pixi.Layers[0].TileOffsets[0], pixi.Layers[0].TileOffsets[len(pixi.Layers[0].TileOffsets)-1]
+	if !bytes.Equal(appended[tileStart:tileEnd], original[tileStart:tileEnd]) {
+		t.Error("appending rewrote tiles of the existing layer")
+	}
+}
+
+func TestReadPixiForAppendFooter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "streamed.pixi")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := NewStreamWriter(file, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.AddTags(map[string]string{"source": "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.AppendLayer(appendTestLayer("first"), appendTestGenerator(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	file, pixi, err := OpenAppend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := appendTestLayer("derived")
+	if err := pixi.AppendIterativeLayer(file, layer, NewTileOrderWriteIterator(file, pixi.Header, layer), appendTestGenerator(1)); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	checkAppendedFile(t, path, 2)
+
+	// a new footer makes the file readable from the end again
+	file, pixi, err = OpenAppend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.AppendFooter(file); err != nil {
+		t.Fatal(err)
+	}
+	footer, err := ReadFooter(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if len(footer.Layers) != 2 || footer.Layers[1].Name != "derived" {
+		t.Errorf("expected the new footer to describe both layers, got %d", len(footer.Layers))
+	}
+}
@@ -0,0 +1,335 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+)
+
+// The kinds of value an attribute may hold, as stored in its four-byte kind field. Arrays of values of a
+// kind set attributeArray alongside it.
+const (
+	attributeString uint32 = 1
+	attributeInt    uint32 = 2
+	attributeFloat  uint32 = 3
+	attributeBool   uint32 = 4
+	attributeArray  uint32 = 1 << 8
+)
+
+// Converts an attribute value to the form in which it is stored and read back: a string, bool, int64, or
+// float64, or a slice of one of these. Other integer and floating point types (and slices of them) are
+// widened, and a []any holding values of a single kind becomes a slice of that kind; an empty []any is a
+// []string. Returns the kind of the value, or an error for a value of any other type.
+func attributeValue(name string, value any) (any, uint32, error) {
+	switch v := value.(type) {
+	case string:
+		return v, attributeString, nil
+	case bool:
+		return v, attributeBool, nil
+	case int:
+		return int64(v), attributeInt, nil
+	case int8:
+		return int64(v), attributeInt, nil
+	case int16:
+		return int64(v), attributeInt, nil
+	case int32:
+		return int64(v), attributeInt, nil
+	case int64:
+		return v, attributeInt, nil
+	case uint8:
+		return int64(v), attributeInt, nil
+	case uint16:
+		return int64(v), attributeInt, nil
+	case uint32:
+		return int64(v), attributeInt, nil
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return nil, 0, ErrFormat(fmt.Sprintf("attribute '%s' value %d overflows a 64-bit integer", name, v))
+		}
+		return int64(v), attributeInt, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, 0, ErrFormat(fmt.Sprintf("attribute '%s' value %d overflows a 64-bit integer", name, v))
+		}
+		return int64(v), attributeInt, nil
+	case float32:
+		return float64(v), attributeFloat, nil
+	case float64:
+		return v, attributeFloat, nil
+	case []string:
+		return v, attributeString | attributeArray, nil
+	case []bool:
+		return v, attributeBool | attributeArray, nil
+	case []int64:
+		return v, attributeInt | attributeArray, nil
+	case []float64:
+		return v, attributeFloat | attributeArray, nil
+	case []int:
+		return attributeArrayOf(name, v)
+	case []int32:
+		return attributeArrayOf(name, v)
+	case []float32:
+		return attributeArrayOf(name, v)
+	case []any:
+		return attributeArrayOf(name, v)
+	}
+	return nil, 0, ErrFormat(fmt.Sprintf("attribute '%s' has unsupported type %T", name, value))
+}
+
+// Converts the elements of a slice to a slice of the single attribute kind they all convert to.
+func attributeArrayOf[T any](name string, values []T) (any, uint32, error) {
+	kind := attributeString
+	converted := make([]any, len(values))
+	for i, element := range values {
+		value, elementKind, err := attributeValue(name, element)
+		if err != nil {
+			return nil, 0, err
+		}
+		if elementKind&attributeArray != 0 || (i > 0 && elementKind != kind) {
+			return nil, 0, ErrFormat(fmt.Sprintf("attribute '%s' must be an array of values of a single kind", name))
+		}
+		kind, converted[i] = elementKind, value
+	}
+	switch kind {
+	case attributeInt:
+		return attributeSlice[int64](converted), kind | attributeArray, nil
+	case attributeFloat:
+		return attributeSlice[float64](converted), kind | attributeArray, nil
+	case attributeBool:
+		return attributeSlice[bool](converted), kind | attributeArray, nil
+	}
+	return attributeSlice[string](converted), kind | attributeArray, nil
+}
+
+func attributeSlice[T any](values []any) []T {
+	typed := make([]T, len(values))
+	for i, value := range values {
+		typed[i] = value.(T)
+	}
+	return typed
+}
+
+// The size in bytes of the attributes as they are written to disk, or zero if any is invalid.
+func attributesSize(h Header, attributes map[string]any) int {
+	size := 4
+	for name, value := range attributes {
+		value, kind, err := attributeValue(name, value)
+		if err != nil {
+			return 0
+		}
+		size += h.FriendlySize(name) + 4
+		if kind&attributeArray != 0 {
+			size += 4
+		}
+		switch v := value.(type) {
+		case string:
+			size += h.FriendlySize(v)
+		case []string:
+			for _, s := range v {
+				size += h.FriendlySize(s)
+			}
+		case bool:
+			size++
+		case []bool:
+			size += len(v)
+		case []int64:
+			size += 8 * len(v)
+		case []float64:
+			size += 8 * len(v)
+		default:
+			size += 8
+		}
+	}
+	return size
+}
+
+// Writes the attributes to the current position in the writer stream, in order of their names: a four-byte
+// count of attributes, then for each its name as a friendly string, its four-byte kind, a four-byte count of
+// values for arrays, and its values. Strings are friendly strings, integers and floats take eight bytes, and
+// booleans one.
+func writeAttributes(w io.Writer, h Header, attributes map[string]any) error {
+	if h.Version < VersionExtensions {
+		return ErrFormat(fmt.Sprintf("attributes require version %d or later", VersionExtensions))
+	}
+	if err := h.Write(w, uint32(len(attributes))); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(attributes)) {
+		value, kind, err := attributeValue(name, attributes[name])
+		if err != nil {
+			return err
+		}
+		if err := h.WriteFriendly(w, name); err != nil {
+			return err
+		}
+		if err := h.Write(w, kind); err != nil {
+			return err
+		}
+		switch v := value.(type) {
+		case string:
+			err = h.WriteFriendly(w, v)
+		case []string:
+			err = h.Write(w, uint32(len(v)))
+			for _, s := range v {
+				if err != nil {
+					break
+				}
+				err = h.WriteFriendly(w, s)
+			}
+		case []bool:
+			if err = h.Write(w, uint32(len(v))); err == nil {
+				err = h.Write(w, v)
+			}
+		case []int64:
+			if err = h.Write(w, uint32(len(v))); err == nil {
+				err = h.Write(w, v)
+			}
+		case []float64:
+			if err = h.Write(w, uint32(len(v))); err == nil {
+				err = h.Write(w, v)
+			}
+		default:
+			err = h.Write(w, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads attributes written by writeAttributes from the current position in the reader stream.
+func readAttributes(r io.Reader, h Header) (map[string]any, error) {
+	var count uint32
+	if err := h.Read(r, &count); err != nil {
+		return nil, ErrFormat(fmt.Sprintf("reading attribute count: %s", err))
+	}
+	attributes := make(map[string]any, min(count, 1024))
+	for range count {
+		name, err := h.ReadFriendly(r)
+		if err != nil {
+			return nil, ErrFormat(fmt.Sprintf("reading attribute name: %s", err))
+		}
+		var kind uint32
+		if err := h.Read(r, &kind); err != nil {
+			return nil, ErrFormat(fmt.Sprintf("reading attribute '%s': %s", name, err))
+		}
+		length := uint32(1)
+		if kind&attributeArray != 0 {
+			if err := h.Read(r, &length); err != nil {
+				return nil, ErrFormat(fmt.Sprintf("reading attribute '%s': %s", name, err))
+			}
+		}
+		value, err := readAttributeValues(r, h, kind&^attributeArray, int(length))
+		if err != nil {
+			return nil, ErrFormat(fmt.Sprintf("reading attribute '%s': %s", name, err))
+		}
+		if kind&attributeArray == 0 {
+			value, _ = readAttributeElement(value, 0)
+		}
+		attributes[name] = value
+	}
+	return attributes, nil
+}
+
+// Reads the given number of values of an attribute kind as a slice of that kind.
+func readAttributeValues(r io.Reader, h Header, kind uint32, length int) (any, error) {
+	switch kind {
+	case attributeString:
+		values := []string{}
+		for range length {
+			s, err := h.ReadFriendly(r)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	case attributeInt:
+		return readAttributeArray[int64](r, h, length)
+	case attributeFloat:
+		return readAttributeArray[float64](r, h, length)
+	case attributeBool:
+		return readAttributeArray[bool](r, h, length)
+	}
+	return nil, ErrFormat(fmt.Sprintf("unknown attribute kind %d", kind))
+}
+
+// Reads fixed size values a bounded number at a time, so that a corrupt length cannot exhaust memory
+// before the stream runs out.
+func readAttributeArray[T any](r io.Reader, h Header, length int) ([]T, error) {
+	values := []T{}
+	for len(values) < length {
+		chunk := make([]T, min(length-len(values), 4096))
+		if err := h.Read(r, chunk); err != nil {
+			return nil, err
+		}
+		values = append(values, chunk...)
+	}
+	return values, nil
+}
+
+func readAttributeElement(values any, i int) (any, bool) {
+	switch v := values.(type) {
+	case []string:
+		return v[i], true
+	case []int64:
+		return v[i], true
+	case []float64:
+		return v[i], true
+	case []bool:
+		return v[i], true
+	}
+	return nil, false
+}
+
+// The names and string values of the attributes, for the string table of a layer header.
+func attributeStrings(attributes map[string]any) []string {
+	values := []string{}
+	for name, value := range attributes {
+		values = append(values, name)
+		value, _, _ = attributeValue(name, value)
+		switch v := value.(type) {
+		case string:
+			values = append(values, v)
+		case []string:
+			values = append(values, v...)
+		}
+	}
+	return values
+}
+
+// Checks that every value of the attributes has a type that can be stored.
+func checkAttributes(attributes map[string]any) error {
+	for _, name := range slices.Sorted(maps.Keys(attributes)) {
+		if _, _, err := attributeValue(name, attributes[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The attributes of the file, merged from every tag section in order as for AllTags, so that attributes of
+// later sections replace those of the same name in earlier ones.
+func (p *Pixi) AllAttributes() map[string]any {
+	attributes := map[string]any{}
+	for _, t := range p.Tags {
+		maps.Copy(attributes, t.Attributes)
+	}
+	return attributes
+}
+
+// Appends a new tag section holding only the given attributes to the end of the file, as AppendTags does
+// for tags. Attribute values must be strings, numbers, or booleans, or slices of one of these, as described
+// for Layer.Attributes. Requires VersionExtensions or later.
+func (p *Pixi) AppendAttributes(w io.WriteSeeker, attributes map[string]any) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append attributes"}
+	}
+	if err := checkAttributes(attributes); err != nil {
+		return err
+	}
+	return p.appendTagSection(w, TagSection{Tags: map[string]string{}, Attributes: maps.Clone(attributes)})
+}
@@ -0,0 +1,181 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestLayerAttributesWriteRead(t *testing.T) {
+	attributes := map[string]any{
+		"sensor":      "VIIRS",
+		"orbit":       42,
+		"gain":        float32(1.5),
+		"calibrated":  false,
+		"bands":       []string{"I1", "I2"},
+		"window":      []int{3, 5},
+		"wavelengths": []any{0.64, 0.865},
+		"mask":        []bool{true, false},
+		"none":        []any{},
+	}
+	expected := map[string]any{
+		"sensor":      "VIIRS",
+		"orbit":       int64(42),
+		"gain":        1.5,
+		"calibrated":  false,
+		"bands":       []string{"I1", "I2"},
+		"window":      []int64{3, 5},
+		"wavelengths": []float64{0.64, 0.865},
+		"mask":        []bool{true, false},
+		"none":        []string{},
+	}
+	for _, dictionary := range []bool{false, true} {
+		for _, header := range allHeaderVariants(Version) {
+			layer := Layer{
+				Name:             "attributed",
+				HeaderDictionary: dictionary,
+				Attributes:       attributes,
+				Dimensions:       DimensionSet{{Name: "x", Size: 4, TileSize: 2}},
+				Channels:         ChannelSet{{Name: "VIIRS", Type: ChannelUint16}},
+				TileBytes:        []int64{10, 20},
+				TileOffsets:      []int64{30, 40},
+			}
+			buf := buffer.NewBuffer(10)
+			if err := layer.WriteHeader(buf, header); err != nil {
+				t.Fatal(err)
+			}
+			if len(buf.Bytes()) != layer.HeaderSize(header) {
+				t.Errorf("expected header size %d, wrote %d bytes", layer.HeaderSize(header), len(buf.Bytes()))
+			}
+			read := Layer{}
+			if err := read.ReadLayer(buffer.NewBufferFrom(buf.Bytes()), header); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(read.Attributes, expected) {
+				t.Errorf("expected attributes %v, got %v", expected, read.Attributes)
+			}
+			if read.TileOffsets[1] != 40 {
+				t.Errorf("expected the tile index to follow the attributes")
+			}
+		}
+	}
+}
+
+func TestAttributesUnsupported(t *testing.T) {
+	header := NewHeader(binary.LittleEndian, OffsetSize4)
+	for name, value := range map[string]any{
+		"struct":   struct{}{},
+		"mixed":    []any{1, "one"},
+		"nested":   []any{[]int{1}},
+		"overflow": uint64(1 << 63),
+		"map":      map[string]any{"a": 1},
+	} {
+		err := writeAttributes(&bytes.Buffer{}, header, map[string]any{name: value})
+		var format ErrFormat
+		if !errors.As(err, &format) {
+			t.Errorf("%s: expected a format error, got %v", name, err)
+		}
+	}
+
+	old := header
+	old.Version = VersionExtensions - 1
+	layer := NewLayer("layer", DimensionSet{{Name: "x", Size: 4, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	layer.Attributes = map[string]any{"a": 1}
+	if err := layer.WriteHeader(&bytes.Buffer{}, old); err == nil {
+		t.Errorf("expected layer attributes to require version %d", VersionExtensions)
+	}
+	if err := (TagSection{Attributes: map[string]any{"a": 1}}).Write(&bytes.Buffer{}, old); err == nil {
+		t.Errorf("expected dataset attributes to require version %d", VersionExtensions)
+	}
+}
+
+func TestAppendAttributes(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	pixi, err := Create(buf, NewHeader(binary.BigEndian, OffsetSize8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.AppendTags(buf, map[string]string{"title": "scene"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.AppendAttributes(buf, map[string]any{"processing": "v1", "threshold": 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.AppendAttributes(buf, map[string]any{"processing": "v2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.AppendAttributes(buf, map[string]any{"bad": []any{true, 1}}); err == nil {
+		t.Fatal("expected an error appending a mixed array")
+	}
+	layer := NewLayer("layer", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	writer := NewTileOrderWriteIterator(buf, pixi.Header, layer)
+	err = pixi.AppendIterativeLayer(buf, layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{uint8(writer.Coordinate()[0])})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.SetLayerAttributes(buf, 0, map[string]any{"source": "granule 7", "window": []int{3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.SetLayerAttributes(buf, 0, map[string]any{"window": nil}); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (map[string]any{"processing": "v2", "threshold": 0.5}); !reflect.DeepEqual(read.AllAttributes(), expected) {
+		t.Errorf("expected attributes %v, got %v", expected, read.AllAttributes())
+	}
+	if tags := read.AllTags(); len(tags) != 1 || tags["title"] != "scene" {
+		t.Errorf("expected tags to be unchanged, got %v", tags)
+	}
+	if expected := (map[string]any{"source": "granule 7"}); !reflect.DeepEqual(read.Layers[0].Attributes, expected) {
+		t.Errorf("expected layer attributes %v, got %v", expected, read.Layers[0].Attributes)
+	}
+}
+
+func TestStreamWriterAttributes(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewStreamWriter(&out, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.AddAttributes(map[string]any{"sensor": "OLI", "bands": []int64{2, 3, 4}}); err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("layer", DimensionSet{{Name: "x", Size: 3, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelInt16}})
+	layer.Attributes = map[string]any{"scale": 2.75e-5}
+	err = writer.AppendLayer(layer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{int16(writer.Coordinate()[0])})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadPixi(buffer.NewBufferFrom(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (map[string]any{"sensor": "OLI", "bands": []int64{2, 3, 4}}); !reflect.DeepEqual(read.AllAttributes(), expected) {
+		t.Errorf("expected attributes %v, got %v", expected, read.AllAttributes())
+	}
+	if read.Layers[0].Attributes["scale"] != 2.75e-5 {
+		t.Errorf("expected layer attributes to be kept, got %v", read.Layers[0].Attributes)
+	}
+}
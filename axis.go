@@ -1,7 +1,11 @@
 package gopixi
 
 import (
+	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
+	"slices"
 
 	"github.com/chenxingqiang/go-floatx"
 	"github.com/kshard/float8"
@@ -16,8 +20,27 @@ type Axis struct {
 	Minimum any         // The starting value of the axis at dimension index 0. Must match Type if present.
 	Step    any         // The increment value as the index increments. Must match Type if present.
 	Unit    string      // Optional unit description for the axis values (e.g., "seconds", "meters", "nm").
+	// Optional explicit value of the axis at every index of the dimension, for axes that are not regularly
+	// spaced (such as satellite swaths or pressure levels). Each must match Type, and there must be as many
+	// as the size of the dimension. When present, Minimum and Step are not used.
+	Coordinates []any
+	// The identifier of the shared axis this axis refers to, stored once in a tagging section of the file (see
+	// Pixi.AppendAxes) or in a Manifest, or empty if the axis is stored in full with its dimension. Layers on
+	// the same grid refer to one shared axis so that their axes cannot drift apart. When set, only the type
+	// and the identifier are written with the dimension, and the rest of the axis is filled in from the
+	// shared axis when the file is read. Use Pixi.SharedAxis to obtain an axis referring to a shared axis.
+	Ref string
+	// The calendar in which the values of a time axis, whose unit is a time unit with a reference epoch such
+	// as "hours since 2000-01-01", count dates. See TimeValue.
+	Calendar Calendar
 }
 
+const (
+	axisTypeCoordinatesFlag ChannelType = 0x10000000 // Flags explicit coordinates in place of the minimum and step.
+	axisTypeReferenceFlag   ChannelType = 0x20000000 // Flags a reference to a shared axis in place of its description.
+	axisTypeCalendarFlag    ChannelType = 0x40000000 // Flags a calendar other than the standard one after the unit.
+)
+
 // Returns the size in bytes that this axis contributes to the dimension header on disk.
 // Always accounts for at least 4 bytes used by the axis type field (written by Dimension.Write).
 func (a *Axis) HeaderSize(h Header) int {
@@ -26,35 +49,50 @@ func (a *Axis) HeaderSize(h Header) int {
 	if a == nil || a.Type.Base() == ChannelUnknown {
 		return size
 	}
-	size += 2 + len([]byte(a.Unit))  // unit string
-	size += 2 * a.Type.Base().Size() // minimum and step
+	if a.Ref != "" {
+		return size + h.FriendlySize(a.Ref)
+	}
+	size += h.FriendlySize(a.Unit) // unit string
+	if a.Calendar != CalendarStandard {
+		size += 4 // calendar
+	}
+	if a.Coordinates != nil {
+		size += len(a.Coordinates) * a.Type.Base().Size()
+	} else {
+		size += 2 * a.Type.Base().Size() // minimum and step
+	}
 	return size
 }
 
 // Writes the binary description of the axis to the given stream. If the axis is nil,
 // a description is written that allows readers to make the same determination when
-// deserializing. Non-nil Axis values must have both minimum and step supplied for
-// Write to operate successfully.
+// deserializing. Non-nil Axis values must have either explicit coordinates or both
+// minimum and step supplied for Write to operate successfully.
 func (a *Axis) Write(w io.Writer, h Header) error {
 	if a != nil && a.Type.Base() == ChannelUnknown {
 		return ErrFormat("axis type ChannelUnknown is invalid when axis metadata is present")
 	}
+	if a != nil && a.Type.Base() == ChannelString {
+		return ErrUnsupported("axes of string values")
+	}
 
 	if a == nil {
 		return h.Write(w, ChannelUnknown)
 	}
 
+	if a.Ref != "" {
+		return a.writeReference(w, h)
+	}
+	if a.Coordinates != nil {
+		return a.writeCoordinates(w, h)
+	}
+
 	// Validate minimum and step must not be nil
 	if a.Minimum == nil || a.Step == nil {
 		return ErrFormat("axis with type must have both minimum and step values")
 	}
 
-	err := h.Write(w, a.Type.Base())
-	if err != nil {
-		return err
-	}
-
-	err = h.WriteFriendly(w, a.Unit)
+	err := a.writeUnit(w, h, a.Type.Base())
 	if err != nil {
 		return err
 	}
@@ -75,16 +113,90 @@ func (a *Axis) Write(w io.Writer, h Header) error {
 	return nil
 }
 
-// Reads a description of the axis from the given binary stream, according to the
-// type of axis value supplied from a previous read.
-func (a *Axis) Read(r io.Reader, h Header, baseType ChannelType) error {
-	a.Type = baseType
+// Writes the axis type field with the reference flag, and the identifier of the shared axis.
+func (a *Axis) writeReference(w io.Writer, h Header) error {
+	if h.Version < VersionExtensions {
+		return ErrFormat(fmt.Sprintf("references to shared axes require version %d or later", VersionExtensions))
+	}
+	err := h.Write(w, a.Type.Base()|axisTypeReferenceFlag)
+	if err != nil {
+		return err
+	}
+	return h.WriteFriendly(w, a.Ref)
+}
+
+// Writes the axis type field with the coordinates flag, the unit, and then every coordinate of the axis.
+func (a *Axis) writeCoordinates(w io.Writer, h Header) error {
+	if h.Version < VersionExtensions {
+		return ErrFormat(fmt.Sprintf("axis coordinates require version %d or later", VersionExtensions))
+	}
+	base := a.Type.Base()
+	for i, value := range a.Coordinates {
+		if err := base.CheckValue(value); err != nil {
+			return ErrFormat(fmt.Sprintf("axis coordinate %d: %v", i, err))
+		}
+	}
+
+	err := a.writeUnit(w, h, base|axisTypeCoordinatesFlag)
+	if err != nil {
+		return err
+	}
+
+	size := base.Size()
+	byteBuf := make([]byte, len(a.Coordinates)*size)
+	for i, value := range a.Coordinates {
+		base.PutValue(value, h.ByteOrder, byteBuf[i*size:])
+	}
+	_, err = w.Write(byteBuf)
+	return err
+}
+
+// Writes the axis type field with the given flags, followed by the unit and, if it is not the standard
+// calendar, the calendar of the axis.
+func (a *Axis) writeUnit(w io.Writer, h Header, encodedType ChannelType) error {
+	if a.Calendar != CalendarStandard {
+		if h.Version < VersionExtensions {
+			return ErrFormat(fmt.Sprintf("axis calendars require version %d or later", VersionExtensions))
+		}
+		if _, ok := calendarMonthDays[a.Calendar]; !ok {
+			return ErrFormat(fmt.Sprintf("unknown axis calendar %v", a.Calendar))
+		}
+		encodedType |= axisTypeCalendarFlag
+	}
+	err := h.Write(w, encodedType)
+	if err != nil {
+		return err
+	}
+	err = h.WriteFriendly(w, a.Unit)
+	if err != nil || a.Calendar == CalendarStandard {
+		return err
+	}
+	return h.Write(w, a.Calendar)
+}
 
+// Reads the unit of the axis, and its calendar if the axis type field read previously flags one.
+func (a *Axis) readUnit(r io.Reader, h Header, encodedType ChannelType) error {
+	a.Type = encodedType.Base()
 	unit, err := h.ReadFriendly(r)
 	if err != nil {
 		return err
 	}
 	a.Unit = unit
+	a.Calendar = CalendarStandard
+	if encodedType&axisTypeCalendarFlag == 0 || h.Version < VersionExtensions {
+		return nil
+	}
+	return h.Read(r, &a.Calendar)
+}
+
+// Reads a description of the axis from the given binary stream, according to the
+// type field of the axis supplied from a previous read.
+func (a *Axis) Read(r io.Reader, h Header, baseType ChannelType) error {
+	err := a.readUnit(r, h, baseType)
+	if err != nil {
+		return err
+	}
+	baseType = baseType.Base()
 
 	readBytes := make([]byte, baseType.Size())
 	_, err = r.Read(readBytes)
@@ -102,10 +214,40 @@ func (a *Axis) Read(r io.Reader, h Header, baseType ChannelType) error {
 	return nil
 }
 
+// Reads a description of an axis with explicit coordinates from the given binary stream, according to the
+// type field of the axis supplied from a previous read and the number of coordinates (the size of the
+// dimension).
+func (a *Axis) readCoordinates(r io.Reader, h Header, baseType ChannelType, count int) error {
+	err := a.readUnit(r, h, baseType)
+	if err != nil {
+		return err
+	}
+	baseType = baseType.Base()
+
+	size := baseType.Size()
+	readBytes := make([]byte, count*size)
+	_, err = io.ReadFull(r, readBytes)
+	if err != nil {
+		return err
+	}
+	a.Coordinates = make([]any, count)
+	for i := range a.Coordinates {
+		a.Coordinates[i] = baseType.Value(readBytes[i*size:], h.ByteOrder)
+	}
+	return nil
+}
+
 // Returns the axis value at the given dimension index i.
-// The value is calculated as: i * step + minimum
+// The value is the explicit coordinate at i if the axis has them, and otherwise is calculated as:
+// i * step + minimum
 // Returns nil if the axis is nil or does not have complete information.
 func (a *Axis) StepValue(i int) any {
+	if a != nil && a.Coordinates != nil {
+		if i < 0 || i >= len(a.Coordinates) {
+			return nil
+		}
+		return a.Coordinates[i]
+	}
 	if a == nil || a.Type.Base() == ChannelUnknown || a.Minimum == nil || a.Step == nil {
 		return nil
 	}
@@ -179,3 +321,76 @@ func (a *Axis) StepValue(i int) any {
 		return nil
 	}
 }
+
+// Returns a copy of the axis for a dimension of the given size that starts at the given index of this
+// axis, so that the samples it keeps have the same axis values. Returns nil for a nil axis. The copy only
+// refers to the same shared axis if it has the same values.
+func (a *Axis) offset(start int, size int) *Axis {
+	if a != nil && start == 0 && (a.Coordinates == nil || size == len(a.Coordinates)) {
+		return a
+	}
+	if a != nil && a.Coordinates != nil {
+		shifted := *a
+		shifted.Ref = ""
+		shifted.Coordinates = slices.Clone(a.Coordinates[start : start+size])
+		return &shifted
+	}
+	if a == nil || a.Type.Base() == ChannelUnknown || a.Minimum == nil || a.Step == nil {
+		return a
+	}
+	shifted := *a
+	shifted.Ref = ""
+	shifted.Minimum = a.StepValue(start)
+	return &shifted
+}
+
+// Returns a copy of the axis for a dimension that samples every stride-th index of this axis,
+// keeping the same minimum and multiplying the step by the stride, or keeping every stride-th explicit
+// coordinate. Returns nil for a nil axis. The copy only refers to the same shared axis for a stride of one.
+func (a *Axis) strided(stride int) *Axis {
+	if stride == 1 {
+		return a
+	}
+	if a != nil && a.Coordinates != nil {
+		scaled := *a
+		scaled.Ref = ""
+		scaled.Coordinates = make([]any, 0, (len(a.Coordinates)+stride-1)/stride)
+		for i := 0; i < len(a.Coordinates); i += stride {
+			scaled.Coordinates = append(scaled.Coordinates, a.Coordinates[i])
+		}
+		return &scaled
+	}
+	if a == nil || a.Type.Base() == ChannelUnknown || a.Minimum == nil || a.Step == nil {
+		return a
+	}
+	zeroed := *a
+	zeroed.Minimum = a.Type.Base().Value(make([]byte, a.Type.Base().Size()), binary.LittleEndian)
+	scaled := *a
+	scaled.Ref = ""
+	scaled.Step = zeroed.StepValue(stride)
+	return &scaled
+}
+
+// Returns the axis of the given type on which the values fall if they are evenly spaced, or nil if they are
+// not or there are fewer than two of them. Values of floating point types may stray from the axis by a
+// millionth of its step, while those of integer types must fall on it exactly, with a whole step.
+func regularAxis(t ChannelType, values []float64) *Axis {
+	count := len(values)
+	if count < 2 {
+		return nil
+	}
+	step := (values[count-1] - values[0]) / float64(count-1)
+	tolerance := 0.0
+	if isFloatChannel(t) {
+		tolerance = 1e-6 * math.Abs(step)
+	}
+	for i, value := range values {
+		if math.Abs(value-(values[0]+float64(i)*step)) > tolerance {
+			return nil
+		}
+	}
+	if step == 0 || (tolerance == 0 && step != math.Trunc(step)) {
+		return nil
+	}
+	return &Axis{Type: t, Minimum: t.FromFloat64(values[0]), Step: t.FromFloat64(step)}
+}
@@ -3,6 +3,7 @@ package gopixi
 import (
 	"bytes"
 	"encoding/binary"
+	"slices"
 	"testing"
 )
 
@@ -122,3 +123,76 @@ func TestAxisHeaderSize(t *testing.T) {
 		})
 	}
 }
+
+func TestAxisCoordinatesWriteRead(t *testing.T) {
+	levels := []any{float32(1000), float32(850), float32(500), float32(250), float32(10)}
+	for _, header := range allHeaderVariants(Version) {
+		dim := Dimension{Name: "pressure", Size: 5, TileSize: 2, Axis: &Axis{Type: ChannelFloat32, Unit: "hPa", Coordinates: levels}}
+		buf := new(bytes.Buffer)
+		if err := dim.Write(buf, header); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != dim.HeaderSize(header) {
+			t.Errorf("expected header size %d, wrote %d bytes", dim.HeaderSize(header), buf.Len())
+		}
+		read := Dimension{}
+		if err := read.Read(bytes.NewReader(buf.Bytes()), header); err != nil {
+			t.Fatal(err)
+		}
+		if !axesEqual(read.Axis, dim.Axis) {
+			t.Errorf("expected axis %+v, got %+v", *dim.Axis, *read.Axis)
+		}
+		if read.Axis.StepValue(2) != float32(500) || read.Axis.StepValue(5) != nil {
+			t.Errorf("expected coordinate values by index, got %v and %v", read.Axis.StepValue(2), read.Axis.StepValue(5))
+		}
+	}
+
+	header := NewHeader(binary.BigEndian, OffsetSize8)
+	short := Dimension{Name: "pressure", Size: 6, TileSize: 2, Axis: &Axis{Type: ChannelFloat32, Coordinates: levels}}
+	if err := short.Write(new(bytes.Buffer), header); err == nil {
+		t.Error("expected an error writing fewer coordinates than indices")
+	}
+	mistyped := Dimension{Name: "pressure", Size: 2, TileSize: 2, Axis: &Axis{Type: ChannelFloat32, Coordinates: []any{1.0, 2.0}}}
+	if err := mistyped.Write(new(bytes.Buffer), header); err == nil {
+		t.Error("expected an error writing coordinates of the wrong type")
+	}
+	header.Version = VersionExtensions - 1
+	old := Dimension{Name: "pressure", Size: 5, TileSize: 2, Axis: &Axis{Type: ChannelFloat32, Coordinates: levels}}
+	if err := old.Write(new(bytes.Buffer), header); err == nil {
+		t.Errorf("expected axis coordinates to require version %d", VersionExtensions)
+	}
+}
+
+func TestAxisCoordinatesDerived(t *testing.T) {
+	axis := &Axis{Type: ChannelInt32, Unit: "ms", Coordinates: []any{int32(0), int32(7), int32(15), int32(22), int32(31)}}
+	if shifted := axis.offset(1, 3); !slices.Equal(shifted.Coordinates, []any{int32(7), int32(15), int32(22)}) {
+		t.Errorf("expected offset coordinates, got %v", shifted.Coordinates)
+	}
+	if strided := axis.strided(2); !slices.Equal(strided.Coordinates, []any{int32(0), int32(15), int32(31)}) {
+		t.Errorf("expected strided coordinates, got %v", strided.Coordinates)
+	}
+
+	dim := Dimension{Name: "scan", Size: 5, TileSize: 5, Axis: axis}
+	if position, ok := dim.Locate(18); !ok || position.Index != 2 || !position.InRange {
+		t.Errorf("expected 18 to lie after index 2, got %+v (%v)", position, ok)
+	}
+	if start, end, ok := dim.Select(5, 22); !ok || start != 1 || end != 4 {
+		t.Errorf("expected indices [1, 4), got [%d, %d) (%v)", start, end, ok)
+	}
+
+	layer := func(name string, dim Dimension) Layer {
+		return NewLayer(name, DimensionSet{dim}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	}
+	sub := Dimension{Name: "scan", Size: 3, TileSize: 3, Axis: axis.offset(2, 3)}
+	alignment, err := AlignAxes(layer("sub", sub), layer("full", dim))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(alignment.Offsets, []int{2}) || !alignment.Within {
+		t.Errorf("unexpected alignment %+v", alignment)
+	}
+	skewed := Dimension{Name: "scan", Size: 3, TileSize: 3, Axis: &Axis{Type: ChannelInt32, Unit: "ms", Coordinates: []any{int32(15), int32(22), int32(33)}}}
+	if _, err := AlignAxes(layer("skewed", skewed), layer("full", dim)); err == nil {
+		t.Error("expected coordinates that diverge to be rejected")
+	}
+}
@@ -0,0 +1,203 @@
+package gopixi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// The location of a coordinate value along an axis, as a fractional index between two neighboring
+// indices, for nearest-neighbor selection or linear interpolation between samples.
+type AxisPosition struct {
+	Index    int     // The index at or before the value, in the direction of increasing index.
+	Fraction float64 // How far the value lies from Index towards Index+1, in [0, 1).
+	Exact    bool    // True if the value is exactly the coordinate at Index.
+	// False if the value lies outside the coordinates of the axis, in which case the position is clamped
+	// to the first or last index.
+	InRange bool
+}
+
+// The fractional index of the position.
+func (p AxisPosition) Position() float64 {
+	return float64(p.Index) + p.Fraction
+}
+
+// The index whose coordinate is nearest to the located value.
+func (p AxisPosition) Nearest() int {
+	if p.Fraction > 0.5 {
+		return p.Index + 1
+	}
+	return p.Index
+}
+
+// Looks up indices along an axis with explicit, irregularly spaced coordinates (such as pressure levels or
+// observation times) by binary search. The coordinates must be strictly ascending or descending.
+type CoordinateIndex struct {
+	coords     []float64
+	descending bool
+}
+
+// Builds an index over the given coordinates, detecting whether they are ascending or descending. Returns
+// an error if there are no coordinates, or if they are not strictly monotonic or contain NaN.
+func NewCoordinateIndex(coords []float64) (*CoordinateIndex, error) {
+	if len(coords) == 0 {
+		return nil, ErrFormat("coordinate index requires at least one coordinate")
+	}
+	index := &CoordinateIndex{coords: coords, descending: len(coords) > 1 && coords[1] < coords[0]}
+	for i, c := range coords {
+		if math.IsNaN(c) {
+			return nil, ErrFormat(fmt.Sprintf("coordinate %d is NaN", i))
+		}
+		if i > 0 && (c == coords[i-1] || (c < coords[i-1]) != index.descending) {
+			return nil, ErrFormat(fmt.Sprintf("coordinates are not strictly monotonic at index %d", i))
+		}
+	}
+	return index, nil
+}
+
+// The number of coordinates.
+func (c *CoordinateIndex) Len() int {
+	return len(c.coords)
+}
+
+// True if the coordinates decrease with increasing index.
+func (c *CoordinateIndex) Descending() bool {
+	return c.descending
+}
+
+// The coordinate at the given index.
+func (c *CoordinateIndex) At(index int) float64 {
+	return c.coords[index]
+}
+
+// Finds the position of the value along the axis.
+func (c *CoordinateIndex) Locate(value float64) AxisPosition {
+	n := len(c.coords)
+	// the number of coordinates at or before the value in the direction of the axis
+	before := sort.Search(n, func(i int) bool {
+		if c.descending {
+			return c.coords[i] < value
+		}
+		return c.coords[i] > value
+	})
+	switch {
+	case math.IsNaN(value):
+		return AxisPosition{}
+	case before == 0:
+		return AxisPosition{Index: 0}
+	case before == n:
+		last := c.coords[n-1]
+		return AxisPosition{Index: n - 1, Exact: value == last, InRange: value == last}
+	}
+	index := before - 1
+	low, high := c.coords[index], c.coords[before]
+	return AxisPosition{
+		Index:    index,
+		Fraction: (value - low) / (high - low),
+		Exact:    value == low,
+		InRange:  true,
+	}
+}
+
+// The index of the coordinate exactly equal to the value, if there is one.
+func (c *CoordinateIndex) Lookup(value float64) (int, bool) {
+	position := c.Locate(value)
+	return position.Index, position.Exact
+}
+
+// The half-open range of indices [start, end) whose coordinates lie between the two values (inclusive, in
+// either order), as used to select samples by axis value. The range is empty if no coordinates lie
+// between them.
+func (c *CoordinateIndex) Select(from float64, to float64) (start int, end int) {
+	low, high := min(from, to), max(from, to)
+	n := len(c.coords)
+	if c.descending {
+		start = sort.Search(n, func(i int) bool { return c.coords[i] <= high })
+		end = sort.Search(n, func(i int) bool { return c.coords[i] < low })
+	} else {
+		start = sort.Search(n, func(i int) bool { return c.coords[i] >= low })
+		end = sort.Search(n, func(i int) bool { return c.coords[i] > high })
+	}
+	return start, max(end, start)
+}
+
+// Finds the position of the value along the regular axis of the dimension, in constant time, or along its
+// explicit coordinates by binary search. Returns false if the dimension has no axis, or an axis whose values
+// cannot be converted to float64 (such as a boolean axis), whose step is zero, or whose coordinates are not
+// strictly monotonic.
+func (d Dimension) Locate(value float64) (AxisPosition, bool) {
+	if index, ok := d.coordinateIndex(); ok {
+		return index.Locate(value), true
+	}
+	minimum, step, ok := d.regularAxis()
+	if !ok {
+		return AxisPosition{}, false
+	}
+	position := (value - minimum) / step
+	switch {
+	case math.IsNaN(position):
+		return AxisPosition{}, true
+	case position < 0:
+		return AxisPosition{Index: 0}, true
+	case position > float64(d.Size-1):
+		return AxisPosition{Index: d.Size - 1}, true
+	}
+	index := min(int(math.Floor(position)), d.Size-1)
+	fraction := position - float64(index)
+	return AxisPosition{Index: index, Fraction: fraction, Exact: fraction == 0, InRange: true}, true
+}
+
+// The half-open range of indices [start, end) whose values along the axis of the dimension lie between the
+// two values (inclusive, in either order), like CoordinateIndex.Select. Returns false under the same
+// conditions as Locate.
+func (d Dimension) Select(from float64, to float64) (start int, end int, ok bool) {
+	if index, ok := d.coordinateIndex(); ok {
+		start, end = index.Select(from, to)
+		return start, end, true
+	}
+	minimum, step, ok := d.regularAxis()
+	if !ok {
+		return 0, 0, false
+	}
+	low, high := (from-minimum)/step, (to-minimum)/step
+	if low > high {
+		low, high = high, low
+	}
+	start = int(max(0, min(float64(d.Size), math.Ceil(low))))
+	end = int(max(0, min(float64(d.Size), math.Floor(high)+1)))
+	return start, max(end, start), true
+}
+
+// The minimum and step of the regular axis of the dimension as float64 values, if it has a numeric axis
+// with a nonzero step.
+func (d Dimension) regularAxis() (minimum float64, step float64, ok bool) {
+	a := d.Axis
+	if a == nil || a.Coordinates != nil || a.Minimum == nil || a.Step == nil || a.Type.Base() == ChannelBool {
+		return 0, 0, false
+	}
+	if minimum, ok = a.Type.ToFloat64(a.Minimum); !ok {
+		return 0, 0, false
+	}
+	if step, ok = a.Type.ToFloat64(a.Step); !ok || step == 0 {
+		return 0, 0, false
+	}
+	return minimum, step, true
+}
+
+// An index over the explicit coordinates of the axis of the dimension, if it has numeric coordinates that
+// are strictly monotonic.
+func (d Dimension) coordinateIndex() (*CoordinateIndex, bool) {
+	a := d.Axis
+	if a == nil || a.Coordinates == nil || a.Type.Base() == ChannelBool {
+		return nil, false
+	}
+	coords := make([]float64, len(a.Coordinates))
+	for i, value := range a.Coordinates {
+		var ok bool
+		if coords[i], ok = a.Type.ToFloat64(value); !ok {
+			return nil, false
+		}
+	}
+	index, err := NewCoordinateIndex(coords)
+	return index, err == nil
+}
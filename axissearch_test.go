@@ -0,0 +1,145 @@
+package gopixi
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCoordinateIndexLocate(t *testing.T) {
+	ascending, err := NewCoordinateIndex([]float64{0, 1, 3, 7, 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	descending, err := NewCoordinateIndex([]float64{1000, 850, 500, 250, 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ascending.Descending() || !descending.Descending() {
+		t.Fatal("expected axis directions to be detected")
+	}
+	tests := []struct {
+		index    *CoordinateIndex
+		value    float64
+		expected AxisPosition
+	}{
+		{ascending, 3, AxisPosition{Index: 2, Exact: true, InRange: true}},
+		{ascending, 5, AxisPosition{Index: 2, Fraction: 0.5, InRange: true}},
+		{ascending, 0, AxisPosition{Index: 0, Exact: true, InRange: true}},
+		{ascending, 15, AxisPosition{Index: 4, Exact: true, InRange: true}},
+		{ascending, -1, AxisPosition{Index: 0}},
+		{ascending, 20, AxisPosition{Index: 4}},
+		{descending, 850, AxisPosition{Index: 1, Exact: true, InRange: true}},
+		{descending, 400, AxisPosition{Index: 2, Fraction: 0.4, InRange: true}},
+		{descending, 2000, AxisPosition{Index: 0}},
+		{descending, 50, AxisPosition{Index: 4}},
+		{ascending, math.NaN(), AxisPosition{}},
+	}
+	for _, test := range tests {
+		if got := test.index.Locate(test.value); got != test.expected {
+			t.Errorf("locate %v: expected %+v, got %+v", test.value, test.expected, got)
+		}
+	}
+	if p := ascending.Locate(6); p.Nearest() != 3 || p.Position() != 2.75 {
+		t.Errorf("unexpected nearest index %d or position %v", p.Nearest(), p.Position())
+	}
+	if i, ok := descending.Lookup(250); !ok || i != 3 {
+		t.Errorf("expected exact lookup at 3, got %d %v", i, ok)
+	}
+	if _, ok := descending.Lookup(251); ok {
+		t.Error("expected lookup of a value between coordinates to fail")
+	}
+}
+
+func TestCoordinateIndexSelect(t *testing.T) {
+	ascending, _ := NewCoordinateIndex([]float64{0, 1, 3, 7, 15})
+	descending, _ := NewCoordinateIndex([]float64{1000, 850, 500, 250, 100})
+	tests := []struct {
+		index      *CoordinateIndex
+		from, to   float64
+		start, end int
+	}{
+		{ascending, 1, 7, 1, 4},
+		{ascending, 7, 1, 1, 4},
+		{ascending, 2, 6, 2, 3},
+		{ascending, 4, 6, 3, 3},
+		{ascending, -10, 100, 0, 5},
+		{ascending, 20, 30, 5, 5},
+		{descending, 900, 300, 1, 3},
+		{descending, 100, 1000, 0, 5},
+		{descending, 90, 10, 5, 5},
+	}
+	for _, test := range tests {
+		start, end := test.index.Select(test.from, test.to)
+		if start != test.start || end != test.end {
+			t.Errorf("select [%v, %v]: expected [%d, %d), got [%d, %d)", test.from, test.to, test.start, test.end, start, end)
+		}
+	}
+}
+
+func TestCoordinateIndexLarge(t *testing.T) {
+	coords := make([]float64, 1_000_000)
+	for i := range coords {
+		coords[i] = float64(i) * 0.5
+	}
+	index, err := NewCoordinateIndex(coords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := index.Locate(123456.25); p.Index != 246912 || p.Fraction != 0.5 {
+		t.Errorf("unexpected position %+v", p)
+	}
+}
+
+func TestNewCoordinateIndexErrors(t *testing.T) {
+	for _, coords := range [][]float64{nil, {1, 2, 2, 3}, {1, 3, 2}, {3, 2, 4}, {1, math.NaN()}} {
+		if _, err := NewCoordinateIndex(coords); err == nil {
+			t.Errorf("expected coordinates %v to be rejected", coords)
+		}
+	}
+	if _, err := NewCoordinateIndex([]float64{5}); err != nil {
+		t.Errorf("expected a single coordinate to be accepted: %v", err)
+	}
+}
+
+func TestDimensionLocate(t *testing.T) {
+	dim := Dimension{Name: "lat", Size: 5, TileSize: 5, Axis: &Axis{Type: ChannelFloat64, Minimum: 90.0, Step: -0.5}}
+	if p, ok := dim.Locate(89.25); !ok || p != (AxisPosition{Index: 1, Fraction: 0.5, InRange: true}) {
+		t.Errorf("unexpected position %+v", p)
+	}
+	if p, ok := dim.Locate(88); !ok || p != (AxisPosition{Index: 4, Exact: true, InRange: true}) {
+		t.Errorf("unexpected position %+v", p)
+	}
+	if p, _ := dim.Locate(80); p.InRange || p.Index != 4 {
+		t.Errorf("expected clamped position, got %+v", p)
+	}
+	ints := Dimension{Name: "t", Size: 10, TileSize: 10, Axis: &Axis{Type: ChannelInt32, Minimum: int32(100), Step: int32(10)}}
+	if p, ok := ints.Locate(135); !ok || p.Index != 3 || p.Fraction != 0.5 {
+		t.Errorf("unexpected position %+v", p)
+	}
+	if _, ok := (Dimension{Name: "x", Size: 4, TileSize: 4}).Locate(1); ok {
+		t.Error("expected a dimension without an axis to fail")
+	}
+}
+
+func TestDimensionSelect(t *testing.T) {
+	dim := Dimension{Name: "lat", Size: 5, TileSize: 5, Axis: &Axis{Type: ChannelFloat64, Minimum: 90.0, Step: -0.5}}
+	tests := []struct {
+		from, to   float64
+		start, end int
+	}{
+		{89.5, 88.5, 1, 4},
+		{88.5, 89.5, 1, 4},
+		{89.75, 89.25, 1, 2},
+		{100, 95, 0, 0},
+		{80, 100, 0, 5},
+		{89.6, 89.7, 1, 1},
+	}
+	for _, test := range tests {
+		if start, end, ok := dim.Select(test.from, test.to); !ok || start != test.start || end != test.end {
+			t.Errorf("select %v to %v: expected [%d, %d), got [%d, %d)", test.from, test.to, test.start, test.end, start, end)
+		}
+	}
+	if _, _, ok := (Dimension{Name: "x", Size: 4, TileSize: 4}).Select(0, 1); ok {
+		t.Error("expected a dimension without an axis to fail")
+	}
+}
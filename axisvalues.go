@@ -0,0 +1,72 @@
+package gopixi
+
+import (
+	"fmt"
+	"iter"
+	"math"
+	"time"
+)
+
+// Iterate over the value of the axis of the dimension at every index in order, as float64. Dimensions
+// without a usable axis yield the index itself.
+func (d Dimension) AxisValues() iter.Seq[float64] {
+	a := d.Axis
+	if a != nil && a.Coordinates != nil {
+		return func(yield func(float64) bool) {
+			for i := range d.Size {
+				value, _ := a.Type.ToFloat64(a.StepValue(i))
+				if !yield(value) {
+					return
+				}
+			}
+		}
+	}
+	minimum, step := 0.0, 1.0
+	if a != nil && a.Minimum != nil && a.Step != nil {
+		var minOk, stepOk bool
+		minimum, minOk = a.Type.ToFloat64(a.Minimum)
+		step, stepOk = a.Type.ToFloat64(a.Step)
+		if !minOk || !stepOk {
+			minimum, step = 0, 1
+		}
+	}
+	return func(yield func(float64) bool) {
+		for i := range d.Size {
+			if !yield(minimum + float64(i)*step) {
+				return
+			}
+		}
+	}
+}
+
+// Iterate over the instant named by the value of the time axis of the dimension at every index in order,
+// as by Axis.TimeValue. The returned function reports the error that stopped the iteration, if any, once
+// ranging is done, or at once if the dimension has no time axis.
+func (d Dimension) AxisTimes() (iter.Seq[time.Time], func() error) {
+	unit, err := d.Axis.timeUnit()
+	if err == nil && d.Axis.Type.Base() == ChannelBool {
+		err = ErrFormat(fmt.Sprintf("boolean axis of dimension '%s' is not a time axis", d.Name))
+	}
+	if err != nil {
+		return func(yield func(time.Time) bool) {}, func() error { return err }
+	}
+	var iterErr error
+	return func(yield func(time.Time) bool) {
+		i := 0
+		for value := range d.AxisValues() {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				iterErr = ErrFormat(fmt.Sprintf("axis value %v at index %d is not a time", value, i))
+				return
+			}
+			t, err := d.Axis.timeAt(unit, value)
+			if err != nil {
+				iterErr = err
+				return
+			}
+			if !yield(t) {
+				return
+			}
+			i++
+		}
+	}, func() error { return iterErr }
+}
@@ -0,0 +1,77 @@
+package gopixi
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAxisValues(t *testing.T) {
+	for name, test := range map[string]struct {
+		dim      Dimension
+		expected []float64
+	}{
+		"regular": {Dimension{Size: 4, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(-1.5), Step: float32(0.5)}}, []float64{-1.5, -1, -0.5, 0}},
+		"integer": {Dimension{Size: 3, Axis: &Axis{Type: ChannelInt16, Minimum: int16(100), Step: int16(-40)}}, []float64{100, 60, 20}},
+		"coordinates": {Dimension{Size: 3, Axis: &Axis{Type: ChannelFloat64, Coordinates: []any{1000.0, 850.0, 500.0}}},
+			[]float64{1000, 850, 500}},
+		"no axis":         {Dimension{Size: 3}, []float64{0, 1, 2}},
+		"incomplete axis": {Dimension{Size: 2, Axis: &Axis{Type: ChannelFloat64, Unit: "m"}}, []float64{0, 1}},
+	} {
+		if values := slices.Collect(test.dim.AxisValues()); !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("%s: expected %v, got %v", name, test.expected, values)
+		}
+	}
+
+	// values are generated lazily, so that breaking out early never visits the rest of a huge dimension
+	huge := Dimension{Size: 1 << 40, Axis: &Axis{Type: ChannelFloat64, Minimum: 0.0, Step: 0.25}}
+	count := 0
+	for value := range huge.AxisValues() {
+		if count++; value >= 1 {
+			break
+		}
+	}
+	if count != 5 {
+		t.Errorf("expected to stop after 5 values, got %d", count)
+	}
+}
+
+func TestAxisTimes(t *testing.T) {
+	dim := Dimension{Name: "time", Size: 3, Axis: &Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(36), Unit: "hours since 2000-01-01"}}
+	times, timesErr := dim.AxisTimes()
+	expected := []time.Time{
+		time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2000, 1, 2, 12, 0, 0, 0, time.UTC),
+		time.Date(2000, 1, 4, 0, 0, 0, 0, time.UTC),
+	}
+	if values := slices.Collect(times); !slices.EqualFunc(values, expected, time.Time.Equal) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+	if err := timesErr(); err != nil {
+		t.Error(err)
+	}
+	for i, value := range expected {
+		if at, err := dim.Axis.TimeValue(i); err != nil || !at.Equal(value) {
+			t.Errorf("expected AxisTimes to agree with TimeValue at %d, got %v (%v)", i, at, err)
+		}
+	}
+
+	// the iteration stops at dates without an equivalent
+	days := Dimension{Name: "time", Size: 3, Axis: &Axis{Type: ChannelFloat64, Coordinates: []any{0.0, 57.0, 59.0},
+		Unit: "days since 2001-01-01", Calendar: Calendar360Day}}
+	times, timesErr = days.AxisTimes()
+	if values := slices.Collect(times); len(values) != 2 || timesErr() == nil {
+		t.Errorf("expected two times and then an error for February 30, got %v (%v)", values, timesErr())
+	}
+
+	for name, dim := range map[string]Dimension{
+		"no axis":      {Name: "x", Size: 2},
+		"no time unit": {Name: "x", Size: 2, Axis: &Axis{Type: ChannelFloat64, Minimum: 0.0, Step: 1.0, Unit: "m"}},
+	} {
+		times, timesErr := dim.AxisTimes()
+		if values := slices.Collect(times); len(values) != 0 || timesErr() == nil {
+			t.Errorf("%s: expected an error and no times, got %v", name, values)
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// An arithmetic expression over the named channels of a layer, such as "(nir - red) / (nir + red)", as
+// parsed by ParseExpression. Expressions are evaluated in float64 arithmetic, one sample at a time.
+type Expression struct {
+	source   string
+	root     exprNode
+	channels []string // referenced channel names, in order of first appearance
+}
+
+// Controls how an expression treats missing values.
+type ExpressionOptions struct {
+	// Values of input channels that mark a sample as missing (e.g., -9999), by channel name. A sample
+	// with a missing input evaluates to OutputFill.
+	InputFill map[string]float64
+	// The value of samples with a missing input, or whose result is not finite (such as 0/0). Defaults
+	// to NaN if nil, which for integer result types converts to zero.
+	OutputFill *float64
+}
+
+// Parses an expression made of channel names, numbers, the operators + - * / and ^ (with the usual
+// precedence, and ^ binding tightest), parentheses, and the functions abs, sqrt, exp, log, min, and max.
+// Channel names start with a letter or underscore and may contain letters, digits, underscores, and
+// periods; any other name can be written in square brackets, as in "[band 4] * 2".
+func ParseExpression(expression string) (*Expression, error) {
+	p := &exprParser{input: []rune(expression), expr: &Expression{source: expression}}
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected '%c'", p.input[p.pos])
+	}
+	p.expr.root = root
+	return p.expr, nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// The names of the channels referenced by the expression, in order of first appearance.
+func (e *Expression) Channels() []string {
+	return slices.Clone(e.channels)
+}
+
+// The channel type of the expression's result over the given channels: float32 if every referenced
+// channel is a float32 or narrower float, or an integer of at most 16 bits (all of which float32
+// represents exactly), and float64 otherwise.
+func (e *Expression) ResultType(channels ChannelSet) (ChannelType, error) {
+	for _, name := range e.channels {
+		index := channels.Index(name)
+		if index < 0 {
+			return ChannelUnknown, ErrChannelNotFound{ChannelName: name}
+		}
+		switch channels[index].Type.Base() {
+		case ChannelInt8, ChannelUint8, ChannelInt16, ChannelUint16, ChannelBool,
+			ChannelFloat8, ChannelFloat16, ChannelBFloat16, ChannelFloat32:
+		default:
+			return ChannelFloat64, nil
+		}
+	}
+	return ChannelFloat32, nil
+}
+
+// Derives the unit of the expression's result from the units of the given channels, using
+// MultiplyUnits, DivideUnits, PowUnit, and AddUnits. Numbers are dimensionless, but adopt the unit of
+// whatever they are added to. Returns the empty string if the unit is unspecified, and an error if the
+// expression adds or compares values with incompatible units.
+func (e *Expression) Unit(channels ChannelSet) (string, error) {
+	units := make([]string, len(e.channels))
+	for i, name := range e.channels {
+		index := channels.Index(name)
+		if index < 0 {
+			return "", ErrChannelNotFound{ChannelName: name}
+		}
+		units[i] = channels[index].Unit
+	}
+	unit, _, err := e.root.unit(units)
+	return unit, err
+}
+
+// Returns a function evaluating the expression for samples of the given channels. Samples with a
+// missing input value, or whose result is not finite, evaluate to the output fill value.
+func (e *Expression) Bind(channels ChannelSet, opts ExpressionOptions) (func(sample Sample) float64, error) {
+	indices := make([]int, len(e.channels))
+	fills := make([]*float64, len(e.channels))
+	for i, name := range e.channels {
+		indices[i] = channels.Index(name)
+		if indices[i] < 0 {
+			return nil, ErrChannelNotFound{ChannelName: name}
+		}
+		if fill, ok := opts.InputFill[name]; ok {
+			fills[i] = &fill
+		}
+	}
+	outputFill := math.NaN()
+	if opts.OutputFill != nil {
+		outputFill = *opts.OutputFill
+	}
+	values := make([]float64, len(e.channels))
+	return func(sample Sample) float64 {
+		for i, index := range indices {
+			values[i], _ = channels[index].Type.ToFloat64(sample[index])
+			if fills[i] != nil && values[i] == *fills[i] {
+				return outputFill
+			}
+		}
+		result := e.root.eval(values)
+		if math.IsNaN(result) || math.IsInf(result, 0) {
+			return outputFill
+		}
+		return result
+	}, nil
+}
+
+// A ReadTransform that appends a virtual channel whose values are computed from each sample by an
+// expression.
+type VirtualChannel struct {
+	Name       string
+	Expression *Expression
+	Options    ExpressionOptions
+}
+
+func (v VirtualChannel) Bind(channels ChannelSet) (ChannelSet, func(SampleCoordinate, Sample) Sample, error) {
+	channel, err := v.Expression.channel(v.Name, channels)
+	if err != nil {
+		return nil, nil, err
+	}
+	eval, err := v.Expression.Bind(channels, v.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(slices.Clone(channels), channel), func(coord SampleCoordinate, sample Sample) Sample {
+		return append(sample, channel.Type.FromFloat64(eval(sample)))
+	}, nil
+}
+
+// Describes the channel holding the expression's results over the given channels.
+func (e *Expression) channel(name string, channels ChannelSet) (Channel, error) {
+	resultType, err := e.ResultType(channels)
+	if err != nil {
+		return Channel{}, err
+	}
+	unit, err := e.Unit(channels)
+	if err != nil {
+		return Channel{}, err
+	}
+	return Channel{Name: name, Type: resultType, Unit: unit}, nil
+}
+
+// Evaluates the expression over every sample of the source layer and appends the results to dst as a new
+// layer with the given name, the dimensions and compression of the source, and a single channel of the same
+// name. The source is read through src, which must be a separate stream from w.
+func (e *Expression) AppendLayer(src io.ReadSeeker, srcHeader Header, srcLayer Layer, dst *Pixi, w io.WriteSeeker, name string, opts ExpressionOptions) error {
+	source, err := NewTransformedReadLayer(newFilledReadLayer(src, srcHeader, srcLayer, 1), 1,
+		VirtualChannel{Name: name, Expression: e, Options: opts})
+	if err != nil {
+		return err
+	}
+	channel := source.Layer().Channels[len(srcLayer.Channels)]
+	layer := NewLayer(name, srcLayer.Dimensions, ChannelSet{channel}, WithCompression(srcLayer.Compression))
+	writer := NewTileOrderWriteIterator(w, dst.Header, layer)
+	return dst.AppendIterativeLayer(w, layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			coord := writer.Coordinate()
+			if !layer.Dimensions.ContainsCoordinate(coord) {
+				continue
+			}
+			value, err := ChannelAt(source, coord, len(srcLayer.Channels))
+			if err != nil {
+				return err
+			}
+			writer.SetChannel(0, value)
+		}
+		return nil
+	})
+}
+
+type exprNode interface {
+	eval(values []float64) float64
+	// Returns the unit of the node's value, and whether the node is a plain number, whose unit adapts to
+	// the values it is combined with.
+	unit(units []string) (string, bool, error)
+}
+
+type exprNumber float64
+
+func (n exprNumber) eval(values []float64) float64 {
+	return float64(n)
+}
+
+func (n exprNumber) unit(units []string) (string, bool, error) {
+	return UnitDimensionless, true, nil
+}
+
+type exprChannel int
+
+func (c exprChannel) eval(values []float64) float64 {
+	return values[c]
+}
+
+func (c exprChannel) unit(units []string) (string, bool, error) {
+	return units[c], false, nil
+}
+
+type exprNegate struct {
+	operand exprNode
+}
+
+func (n exprNegate) eval(values []float64) float64 {
+	return -n.operand.eval(values)
+}
+
+func (n exprNegate) unit(units []string) (string, bool, error) {
+	return n.operand.unit(units)
+}
+
+type exprBinary struct {
+	op          rune
+	left, right exprNode
+}
+
+func (b exprBinary) eval(values []float64) float64 {
+	l, r := b.left.eval(values), b.right.eval(values)
+	switch b.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	case '/':
+		return l / r
+	default:
+		return math.Pow(l, r)
+	}
+}
+
+func (b exprBinary) unit(units []string) (string, bool, error) {
+	left, leftNumber, err := b.left.unit(units)
+	if err != nil {
+		return "", false, err
+	}
+	right, rightNumber, err := b.right.unit(units)
+	if err != nil {
+		return "", false, err
+	}
+	both := leftNumber && rightNumber
+	switch b.op {
+	case '+', '-':
+		if leftNumber {
+			return right, both, nil
+		}
+		if rightNumber {
+			return left, false, nil
+		}
+		unit, err := AddUnits(left, right)
+		return unit, false, err
+	case '*':
+		unit, err := MultiplyUnits(left, right)
+		return unit, both, err
+	case '/':
+		unit, err := DivideUnits(left, right)
+		return unit, both, err
+	default:
+		// powers are only defined for units raised to integer constants
+		number, isConstant := b.right.(exprNumber)
+		if left == UnitDimensionless || left == "" {
+			return left, both, nil
+		}
+		if !isConstant || float64(number) != math.Trunc(float64(number)) {
+			return "", false, nil
+		}
+		unit, err := PowUnit(left, int(number))
+		return unit, false, err
+	}
+}
+
+type exprCall struct {
+	function string
+	args     []exprNode
+}
+
+var exprFunctions = map[string]int{"abs": 1, "sqrt": 1, "exp": 1, "log": 1, "min": 2, "max": 2}
+
+func (c exprCall) eval(values []float64) float64 {
+	a := c.args[0].eval(values)
+	switch c.function {
+	case "abs":
+		return math.Abs(a)
+	case "sqrt":
+		return math.Sqrt(a)
+	case "exp":
+		return math.Exp(a)
+	case "log":
+		return math.Log(a)
+	case "min":
+		return math.Min(a, c.args[1].eval(values))
+	default:
+		return math.Max(a, c.args[1].eval(values))
+	}
+}
+
+func (c exprCall) unit(units []string) (string, bool, error) {
+	arg, number, err := c.args[0].unit(units)
+	if err != nil {
+		return "", false, err
+	}
+	switch c.function {
+	case "abs":
+		return arg, number, nil
+	case "sqrt":
+		if arg == "" || number {
+			return arg, number, nil
+		}
+		terms, err := ParseUnitTerms(arg)
+		if err != nil {
+			return "", false, err
+		}
+		for i, term := range terms {
+			if term.Power%2 != 0 {
+				return "", false, nil
+			}
+			terms[i].Power /= 2
+		}
+		return terms.String(), false, nil
+	case "exp", "log":
+		return UnitDimensionless, number, nil
+	default:
+		return exprBinary{op: '+', left: c.args[0], right: c.args[1]}.unit(units)
+	}
+}
+
+type exprParser struct {
+	input []rune
+	pos   int
+	expr  *Expression
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return ErrFormat(fmt.Sprintf("expression '%s' at offset %d: %s", p.expr.source, p.pos, fmt.Sprintf(format, args...)))
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// Consumes the next non-space character if it is one of the given operators.
+func (p *exprParser) accept(ops string) (rune, bool) {
+	p.skipSpace()
+	if p.pos < len(p.input) && strings.ContainsRune(ops, p.input[p.pos]) {
+		p.pos++
+		return p.input[p.pos-1], true
+	}
+	return 0, false
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	node, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+-")
+		if !ok {
+			return node, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		node = exprBinary{op: op, left: node, right: right}
+	}
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*/")
+		if !ok {
+			return node, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		node = exprBinary{op: op, left: node, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if _, ok := p.accept("-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return exprNegate{operand: operand}, nil
+	}
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("^"); ok {
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return exprBinary{op: '^', left: base, right: exponent}, nil
+	}
+	return base, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	r := p.input[p.pos]
+	switch {
+	case r == '(':
+		p.pos++
+		node, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, p.errorf("missing ')'")
+		}
+		return node, nil
+	case r == '[':
+		end := slices.Index(p.input[p.pos:], ']')
+		if end < 0 {
+			return nil, p.errorf("missing ']'")
+		}
+		name := string(p.input[p.pos+1 : p.pos+end])
+		p.pos += end + 1
+		return p.channel(name), nil
+	case unicode.IsDigit(r) || r == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.' ||
+			p.input[p.pos] == 'e' || p.input[p.pos] == 'E' ||
+			((p.input[p.pos] == '-' || p.input[p.pos] == '+') && (p.input[p.pos-1] == 'e' || p.input[p.pos-1] == 'E'))) {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(string(p.input[start:p.pos]), 64)
+		if err != nil {
+			return nil, p.errorf("invalid number '%s'", string(p.input[start:p.pos]))
+		}
+		return exprNumber(value), nil
+	case unicode.IsLetter(r) || r == '_':
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '_' || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		name := string(p.input[start:p.pos])
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(name)
+		}
+		return p.channel(name), nil
+	default:
+		return nil, p.errorf("unexpected '%c'", r)
+	}
+}
+
+func (p *exprParser) parseCall(function string) (exprNode, error) {
+	arity, ok := exprFunctions[function]
+	if !ok {
+		return nil, p.errorf("unknown function '%s'", function)
+	}
+	call := exprCall{function: function}
+	for {
+		arg, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if _, ok := p.accept(","); !ok {
+			break
+		}
+	}
+	if _, ok := p.accept(")"); !ok {
+		return nil, p.errorf("missing ')' after arguments to '%s'", function)
+	}
+	if len(call.args) != arity {
+		return nil, p.errorf("function '%s' takes %d arguments, got %d", function, arity, len(call.args))
+	}
+	return call, nil
+}
+
+func (p *exprParser) channel(name string) exprNode {
+	index := slices.Index(p.expr.channels, name)
+	if index < 0 {
+		index = len(p.expr.channels)
+		p.expr.channels = append(p.expr.channels, name)
+	}
+	return exprChannel(index)
+}
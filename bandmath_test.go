@@ -0,0 +1,269 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestParseExpression(t *testing.T) {
+	channels := ChannelSet{
+		{Name: "nir", Type: ChannelUint16},
+		{Name: "red", Type: ChannelUint16},
+		{Name: "band 4", Type: ChannelInt8},
+	}
+	sample := Sample{uint16(30), uint16(10), int8(-2)}
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"(nir - red) / (nir + red)", 0.5},
+		{"nir - red - 5", 15},
+		{"nir - red * 2", 10},
+		{"-red ^ 2", -100},
+		{"2 ^ 3 ^ 2", 512},
+		{"[band 4] * 1.5e1", -30},
+		{"abs([band 4]) + sqrt(red - 1)", 5},
+		{"max(nir, red) / min(nir, red)", 3},
+		{"log(exp(2))", 2},
+	}
+	for _, test := range tests {
+		expr, err := ParseExpression(test.expression)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		eval, err := expr.Bind(channels, ExpressionOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := eval(sample); math.Abs(result-test.expected) > 1e-12 {
+			t.Errorf("%s: expected %v, got %v", test.expression, test.expected, result)
+		}
+	}
+
+	expr, _ := ParseExpression("(nir - red) / (nir + nir)")
+	if !slices.Equal(expr.Channels(), []string{"nir", "red"}) {
+		t.Errorf("unexpected referenced channels %v", expr.Channels())
+	}
+
+	for _, bad := range []string{"", "nir +", "(nir", "nir red", "foo(nir)", "min(nir)", "[nir", "nir $ red", "1.2.3"} {
+		if _, err := ParseExpression(bad); err == nil {
+			t.Errorf("expected parsing '%s' to fail", bad)
+		}
+	}
+	if _, err := expr.Bind(ChannelSet{{Name: "nir", Type: ChannelUint16}}, ExpressionOptions{}); err == nil {
+		t.Error("expected binding to channels missing a referenced channel to fail")
+	}
+}
+
+func TestExpressionFillValues(t *testing.T) {
+	channels := ChannelSet{{Name: "a", Type: ChannelInt16}, {Name: "b", Type: ChannelInt16}}
+	expr, err := ParseExpression("a / b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eval, err := expr.Bind(channels, ExpressionOptions{InputFill: map[string]float64{"a": -9999}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := eval(Sample{int16(-9999), int16(1)}); !math.IsNaN(result) {
+		t.Errorf("expected missing input to give NaN, got %v", result)
+	}
+	if result := eval(Sample{int16(0), int16(0)}); !math.IsNaN(result) {
+		t.Errorf("expected 0/0 to give NaN, got %v", result)
+	}
+
+	fill := -1.0
+	eval, _ = expr.Bind(channels, ExpressionOptions{InputFill: map[string]float64{"a": -9999}, OutputFill: &fill})
+	if result := eval(Sample{int16(3), int16(0)}); result != fill {
+		t.Errorf("expected division by zero to give the output fill, got %v", result)
+	}
+	if result := eval(Sample{int16(3), int16(2)}); result != 1.5 {
+		t.Errorf("expected 1.5, got %v", result)
+	}
+}
+
+func TestExpressionTypesAndUnits(t *testing.T) {
+	channels := ChannelSet{
+		{Name: "dist", Type: ChannelUint8, Unit: "m"},
+		{Name: "time", Type: ChannelFloat32, Unit: "s"},
+		{Name: "area", Type: ChannelInt16, Unit: "m2"},
+		{Name: "mass", Type: ChannelFloat64, Unit: "kg"},
+		{Name: "count", Type: ChannelInt32},
+	}
+	tests := []struct {
+		expression string
+		resultType ChannelType
+		unit       string
+	}{
+		{"dist / time", ChannelFloat32, "m s-1"},
+		{"dist * 2 + 1", ChannelFloat32, "m"},
+		{"1 / time", ChannelFloat32, "s-1"},
+		{"sqrt(area) - dist", ChannelFloat32, "m"},
+		{"dist ^ 3", ChannelFloat32, "m3"},
+		{"mass * dist / time ^ 2", ChannelFloat64, "kg m s-2"},
+		{"count * 2", ChannelFloat64, ""},
+		{"exp(dist / dist)", ChannelFloat32, UnitDimensionless},
+	}
+	for _, test := range tests {
+		expr, err := ParseExpression(test.expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resultType, err := expr.ResultType(channels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit, err := expr.Unit(channels)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if resultType != test.resultType || unit != test.unit {
+			t.Errorf("%s: expected %v '%s', got %v '%s'", test.expression, test.resultType, test.unit, resultType, unit)
+		}
+	}
+
+	expr, _ := ParseExpression("dist + time")
+	if _, err := expr.Unit(channels); err == nil {
+		t.Error("expected adding incompatible units to fail")
+	}
+}
+
+func TestVirtualChannel(t *testing.T) {
+	for _, planar := range []bool{false, true} {
+		buf := buffer.NewBuffer(10)
+		opts := []LayerOption{}
+		if planar {
+			opts = append(opts, WithPlanar())
+		}
+		channels := ChannelSet{{Name: "nir", Type: ChannelUint16}, {Name: "red", Type: ChannelUint16}}
+		layers := []Layer{NewLayer("bands", DimensionSet{{Name: "x", Size: 10, TileSize: 4}}, channels, opts...)}
+		written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+			return Sample{uint16(3 * coord[0]), uint16(coord[0])}
+		})
+
+		ndvi, err := ParseExpression("(nir - red) / (nir + red)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		written.RegisterReadTransform("bands", VirtualChannel{Name: "ndvi", Expression: ndvi})
+		access, err := written.ReadLayer(buffer.NewBufferFrom(buf.Bytes()), 0, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if layer := access.Layer(); len(layer.Channels) != 3 || layer.Channels[2].Name != "ndvi" || layer.Channels[2].Type != ChannelFloat32 {
+			t.Fatalf("unexpected transformed channels %v", layer.Channels)
+		}
+		for _, x := range []int{9, 0, 5, 1} {
+			sample, err := SampleAt(access, SampleCoordinate{x})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sample[0] != uint16(3*x) || sample[1] != uint16(x) {
+				t.Errorf("planar %v: unexpected stored values %v", planar, sample)
+			}
+			value := sample[2].(float32)
+			if x == 0 {
+				if !math.IsNaN(float64(value)) {
+					t.Errorf("planar %v: expected NaN at x=0, got %v", planar, value)
+				}
+			} else if value != 0.5 {
+				t.Errorf("planar %v: expected 0.5 at x=%d, got %v", planar, x, value)
+			}
+		}
+	}
+}
+
+func TestExpressionAppendLayer(t *testing.T) {
+	for _, planar := range []bool{false, true} {
+		buf := buffer.NewBuffer(10)
+		opts := []LayerOption{WithCompression(CompressionFlate)}
+		if planar {
+			opts = append(opts, WithPlanar())
+		}
+		channels := ChannelSet{{Name: "a", Type: ChannelInt32, Unit: "m"}, {Name: "b", Type: ChannelInt32, Unit: "m"}}
+		layers := []Layer{NewLayer("input", DimensionSet{{Name: "x", Size: 7, TileSize: 4}, {Name: "y", Size: 3, TileSize: 2}}, channels, opts...)}
+		written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+			return Sample{int32(coord[0] * 10), int32(coord[1])}
+		})
+
+		expr, err := ParseExpression("a + b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fill := -1.0
+		err = expr.AppendLayer(buffer.NewBufferFrom(buf.Bytes()), written.Header, written.Layers[0], written, buf, "sum",
+			ExpressionOptions{InputFill: map[string]float64{"b": 2}, OutputFill: &fill})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf.Seek(0, 0)
+		read, err := ReadPixi(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read.Layers) != 2 {
+			t.Fatalf("expected 2 layers, got %d", len(read.Layers))
+		}
+		derived := read.Layers[1]
+		if derived.Name != "sum" || derived.Compression != CompressionFlate || derived.Channels[0].Type != ChannelFloat64 || derived.Channels[0].Unit != "m" {
+			t.Errorf("unexpected derived layer %v", derived)
+		}
+		samples, err := derived.ReadRegion(buffer.NewBufferFrom(buf.Bytes()), read.Header, FullRegion(derived.Dimensions))
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := 0
+		for coord := range FullRegion(derived.Dimensions).Coordinates() {
+			expected := float64(coord[0]*10 + coord[1])
+			if coord[1] == 2 {
+				expected = fill
+			}
+			if samples[i][0] != expected {
+				t.Errorf("planar %v: sample %v: expected %v, got %v", planar, coord, expected, samples[i][0])
+			}
+			i++
+		}
+	}
+
+	// unwritten tiles of sparse layers are read as their fill values
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("sparse", DimensionSet{{Name: "x", Size: 8, TileSize: 4}},
+		ChannelSet{{Name: "a", Type: ChannelInt32, FillValue: int32(-1)}}, WithSparseTiles())}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		if coord[0] < 4 {
+			return Sample{int32(coord[0])}
+		}
+		return Sample{int32(-1)}
+	})
+	if written.Layers[0].TileBytes[1] != 0 {
+		t.Fatalf("expected the fill tile to be absent, got tile bytes %v", written.Layers[0].TileBytes)
+	}
+	expr, err := ParseExpression("a * 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill := -5.0
+	err = expr.AppendLayer(buffer.NewBufferFrom(buf.Bytes()), written.Header, written.Layers[0], written, buf, "double",
+		ExpressionOptions{InputFill: map[string]float64{"a": -1}, OutputFill: &fill})
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples, err := written.Layers[1].ReadRegion(buffer.NewBufferFrom(buf.Bytes()), written.Header, FullRegion(written.Layers[1].Dimensions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, sample := range samples {
+		expected := fill
+		if i < 4 {
+			expected = float64(i * 2)
+		}
+		if sample[0] != expected {
+			t.Errorf("sparse: sample %d: expected %v, got %v", i, expected, sample[0])
+		}
+	}
+}
@@ -18,6 +18,8 @@ type FifoCacheReadLayer struct {
 	layer     Layer
 	cache     map[int]FifoCacheLayerTile
 	maxSize   int
+	// Whether tiles are read without verifying their checksums, as set by WithVerifyChecksums.
+	skipChecksums bool
 }
 
 // Compile-time check to ensure LayerReadFifoCache implements TileAccessLayer
@@ -51,8 +53,9 @@ func (c *FifoCacheReadLayer) Tile(tile int) ([]byte, error) {
 
 	data := make([]byte, c.layer.DiskTileSize(tile))
 	c.cacheLock.Lock()
-	err := c.layer.ReadTile(c.backing, c.header, tile, data)
+	err := c.layer.readTile(c.backing, c.header, tile, data, !c.skipChecksums)
 	if err != nil {
+		c.cacheLock.Unlock()
 		return nil, err
 	}
 
@@ -0,0 +1,183 @@
+package gopixi
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// The calendar of the dates of a time axis, as in the CF conventions, which determines how the values of an
+// axis with a time unit and reference epoch ("hours since 2000-01-01") are counted into dates.
+type Calendar uint32
+
+const (
+	// The Gregorian calendar, which is proleptic: dates before its introduction in 1582 are counted with
+	// Gregorian leap years too, rather than those of the Julian calendar.
+	CalendarStandard Calendar = 0
+	// A calendar whose years are all 365 days long, without leap days.
+	CalendarNoLeap Calendar = 1
+	// A calendar whose years are all 366 days long, with a leap day in every year.
+	CalendarAllLeap Calendar = 2
+	// A calendar whose years are twelve months of thirty days each.
+	Calendar360Day Calendar = 3
+)
+
+func (c Calendar) String() string {
+	switch c {
+	case CalendarStandard:
+		return "standard"
+	case CalendarNoLeap:
+		return "noleap"
+	case CalendarAllLeap:
+		return "all_leap"
+	case Calendar360Day:
+		return "360_day"
+	default:
+		return fmt.Sprintf("Calendar(%d)", uint32(c))
+	}
+}
+
+// Parses the name of a calendar, as returned by its String method, or any of its other names in the CF
+// conventions ("gregorian", "proleptic_gregorian", "365_day", "366_day").
+func ParseCalendar(name string) (Calendar, error) {
+	switch name {
+	case "gregorian", "proleptic_gregorian":
+		return CalendarStandard, nil
+	case "365_day":
+		return CalendarNoLeap, nil
+	case "366_day":
+		return CalendarAllLeap, nil
+	}
+	for c := CalendarStandard; c <= Calendar360Day; c++ {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return 0, ErrFormat(fmt.Sprintf("unknown calendar '%s'", name))
+}
+
+// The number of days before each month of a year, with a final entry for the length of the year, for the
+// calendars whose years all have the same length.
+var calendarMonthDays = map[Calendar][13]int64{
+	CalendarNoLeap:  {0, 31, 59, 90, 120, 151, 181, 212, 243, 273, 304, 334, 365},
+	CalendarAllLeap: {0, 31, 60, 91, 121, 152, 182, 213, 244, 274, 305, 335, 366},
+	Calendar360Day:  {0, 30, 60, 90, 120, 150, 180, 210, 240, 270, 300, 330, 360},
+}
+
+// The number of days from the start of year zero of the calendar to the given date, which must be a date of
+// the calendar. Not used for the standard calendar.
+func (c Calendar) dayNumber(year int, month time.Month, day int) (int64, error) {
+	days := calendarMonthDays[c]
+	if month < time.January || month > time.December || day < 1 || int64(day) > days[month]-days[month-1] {
+		return 0, ErrFormat(fmt.Sprintf("%04d-%02d-%02d is not a date of the %s calendar", year, month, day, c))
+	}
+	return int64(year)*days[12] + days[month-1] + int64(day-1), nil
+}
+
+// The date of the calendar the given number of days from the start of its year zero. Not used for the
+// standard calendar.
+func (c Calendar) date(dayNumber int64) (year int, month time.Month, day int) {
+	days := calendarMonthDays[c]
+	y := dayNumber / days[12]
+	if dayNumber%days[12] < 0 {
+		y--
+	}
+	remainder := dayNumber - y*days[12]
+	m := time.January
+	for remainder >= days[m] {
+		m++
+	}
+	return int(y), m, int(remainder-days[m-1]) + 1
+}
+
+// The time unit and reference epoch of the axis, or an error if its unit is not a time unit with a reference
+// epoch or its calendar is unknown.
+func (a *Axis) timeUnit() (Unit, error) {
+	if a == nil {
+		return Unit{}, ErrFormat("dimension has no axis")
+	}
+	if _, ok := calendarMonthDays[a.Calendar]; !ok && a.Calendar != CalendarStandard {
+		return Unit{}, ErrFormat(fmt.Sprintf("unknown axis calendar %v", a.Calendar))
+	}
+	unit, err := ParseUnit(a.Unit)
+	if err != nil {
+		return Unit{}, err
+	}
+	if unit.Since == nil {
+		return Unit{}, ErrFormat(fmt.Sprintf("axis unit '%s' is not a time unit with a reference epoch", a.Unit))
+	}
+	return unit, nil
+}
+
+// Returns the instant the axis value at dimension index i names, for time axes whose unit is a time unit with
+// a reference epoch, such as "hours since 2000-01-01", counted in the calendar of the axis. Returns an error
+// if the axis is not a time axis, or the date it names has no equivalent in the calendar of time.Time.
+func (a *Axis) TimeValue(i int) (time.Time, error) {
+	unit, err := a.timeUnit()
+	if err != nil {
+		return time.Time{}, err
+	}
+	value, ok := a.Type.ToFloat64(a.StepValue(i))
+	if !ok || a.Type.Base() == ChannelBool || math.IsNaN(value) || math.IsInf(value, 0) {
+		return time.Time{}, ErrFormat(fmt.Sprintf("axis value %v at index %d is not a time", a.StepValue(i), i))
+	}
+	return a.timeAt(unit, value)
+}
+
+// The instant the axis value names in the time unit of the axis, as for TimeValue.
+func (a *Axis) timeAt(unit Unit, value float64) (time.Time, error) {
+	seconds := value * unit.Scale
+	whole := math.Floor(seconds)
+	nanos := int64(math.Round((seconds - whole) * 1e9))
+	epoch := *unit.Since
+
+	if a.Calendar == CalendarStandard {
+		return time.Unix(epoch.Unix()+int64(whole), int64(epoch.Nanosecond())+nanos).In(epoch.Location()), nil
+	}
+	epochDay, err := a.Calendar.dayNumber(epoch.Year(), epoch.Month(), epoch.Day())
+	if err != nil {
+		return time.Time{}, err
+	}
+	total := epochDay*86400 + int64(epoch.Hour()*3600+epoch.Minute()*60+epoch.Second()) + int64(whole)
+	nanos += int64(epoch.Nanosecond())
+	total, nanos = total+nanos/1e9, nanos%1e9
+	day := total / 86400
+	if total%86400 < 0 {
+		day--
+	}
+	second := total - day*86400
+	year, month, dayOfMonth := a.Calendar.date(day)
+	t := time.Date(year, month, dayOfMonth, int(second/3600), int(second/60%60), int(second%60), int(nanos), epoch.Location())
+	if t.Month() != month || t.Day() != dayOfMonth {
+		return time.Time{}, ErrUnsupported(fmt.Sprintf("%04d-%02d-%02d of the %s calendar as a time.Time", year, month, dayOfMonth, a.Calendar))
+	}
+	return t, nil
+}
+
+// Returns the axis value naming the given instant, for time axes as in TimeValue, for use in finding the
+// instant along the dimension of the axis with Dimension.Locate and Dimension.Select. Returns an error if
+// the axis is not a time axis, or if the date of the instant does not exist in the calendar of the axis,
+// such as February 29 in the calendar without leap days.
+func (a *Axis) ValueAtTime(t time.Time) (float64, error) {
+	unit, err := a.timeUnit()
+	if err != nil {
+		return 0, err
+	}
+	epoch := *unit.Since
+	if a.Calendar == CalendarStandard {
+		seconds := float64(t.Unix()-epoch.Unix()) + float64(t.Nanosecond()-epoch.Nanosecond())/1e9
+		return seconds / unit.Scale, nil
+	}
+	t = t.In(epoch.Location())
+	day, err := a.Calendar.dayNumber(t.Year(), t.Month(), t.Day())
+	if err != nil {
+		return 0, err
+	}
+	epochDay, err := a.Calendar.dayNumber(epoch.Year(), epoch.Month(), epoch.Day())
+	if err != nil {
+		return 0, err
+	}
+	clock := func(t time.Time) int64 { return int64(t.Hour()*3600 + t.Minute()*60 + t.Second()) }
+	seconds := float64((day-epochDay)*86400+clock(t)-clock(epoch)) + float64(t.Nanosecond()-epoch.Nanosecond())/1e9
+	return seconds / unit.Scale, nil
+}
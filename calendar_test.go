@@ -0,0 +1,192 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestParseCalendar(t *testing.T) {
+	for c := CalendarStandard; c <= Calendar360Day; c++ {
+		if parsed, err := ParseCalendar(c.String()); err != nil || parsed != c {
+			t.Errorf("expected to parse %v, got %v (%v)", c, parsed, err)
+		}
+	}
+	for name, expected := range map[string]Calendar{"gregorian": CalendarStandard, "proleptic_gregorian": CalendarStandard, "365_day": CalendarNoLeap, "366_day": CalendarAllLeap} {
+		if parsed, err := ParseCalendar(name); err != nil || parsed != expected {
+			t.Errorf("expected '%s' to parse as %v, got %v (%v)", name, expected, parsed, err)
+		}
+	}
+	if _, err := ParseCalendar("julian"); err == nil {
+		t.Errorf("expected an unsupported calendar to be rejected")
+	}
+}
+
+func TestAxisTimeValue(t *testing.T) {
+	date := func(year int, month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	for name, test := range map[string]struct {
+		axis     *Axis
+		index    int
+		expected time.Time
+	}{
+		"standard hours":     {&Axis{Type: ChannelInt64, Minimum: int64(0), Step: int64(6), Unit: "hours since 2000-01-01"}, 5, date(2000, 1, 2, 6, 0)},
+		"standard leap day":  {&Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(59), Unit: "days since 2000-01-01"}, 1, date(2000, 2, 29, 0, 0)},
+		"fractional hours":   {&Axis{Type: ChannelFloat64, Minimum: 0.0, Step: 1.5, Unit: "hours since 2000-01-01T00:00:00Z"}, 1, date(2000, 1, 1, 1, 30)},
+		"noleap":             {&Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(59), Unit: "days since 2000-01-01", Calendar: CalendarNoLeap}, 1, date(2000, 3, 1, 0, 0)},
+		"noleap before":      {&Axis{Type: ChannelInt32, Minimum: int32(-1), Step: int32(365), Unit: "days since 2000-01-01", Calendar: CalendarNoLeap}, 0, date(1999, 12, 31, 0, 0)},
+		"noleap years":       {&Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(365), Unit: "days since 1850-01-01 12:00", Calendar: CalendarNoLeap}, 170, date(2020, 1, 1, 12, 0)},
+		"360 day":            {&Axis{Type: ChannelFloat32, Minimum: float32(0), Step: float32(30.25), Unit: "days since 2000-01-01", Calendar: Calendar360Day}, 2, date(2000, 3, 1, 12, 0)},
+		"all leap":           {&Axis{Type: ChannelInt16, Minimum: int16(0), Step: int16(366), Unit: "days since 1999-03-01", Calendar: CalendarAllLeap}, 1, date(2000, 3, 1, 0, 0)},
+		"coordinates minute": {&Axis{Type: ChannelInt32, Unit: "minutes since 2000-01-01", Coordinates: []any{int32(0), int32(90)}, Calendar: CalendarNoLeap}, 1, date(2000, 1, 1, 1, 30)},
+	} {
+		got, err := test.axis.TimeValue(test.index)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !got.Equal(test.expected) {
+			t.Errorf("%s: expected %v, got %v", name, test.expected, got)
+		}
+		value, err := test.axis.ValueAtTime(got)
+		expected, _ := test.axis.Type.ToFloat64(test.axis.StepValue(test.index))
+		if err != nil || value != expected {
+			t.Errorf("%s: expected the time to name axis value %v, got %v (%v)", name, expected, value, err)
+		}
+	}
+
+	// dates of other calendars that the standard calendar lacks cannot be returned
+	thirty := &Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(59), Unit: "days since 2000-01-01", Calendar: Calendar360Day}
+	if _, err := thirty.TimeValue(1); !errors.As(err, new(ErrUnsupported)) {
+		t.Errorf("expected February 30 to be unsupported, got %v", err)
+	}
+	allLeap := &Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(59), Unit: "days since 2001-01-01", Calendar: CalendarAllLeap}
+	if _, err := allLeap.TimeValue(1); !errors.As(err, new(ErrUnsupported)) {
+		t.Errorf("expected February 29 of 2001 to be unsupported, got %v", err)
+	}
+	noLeap := &Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(1), Unit: "days since 2000-01-01", Calendar: CalendarNoLeap}
+	if _, err := noLeap.ValueAtTime(date(2004, 2, 29, 0, 0)); err == nil {
+		t.Errorf("expected a leap day to have no value in the calendar without leap days")
+	}
+	if _, err := thirty.ValueAtTime(date(2000, 1, 31, 0, 0)); err == nil {
+		t.Errorf("expected the thirty-first to have no value in the 360-day calendar")
+	}
+	for name, axis := range map[string]*Axis{
+		"no epoch":    {Type: ChannelInt32, Minimum: int32(0), Step: int32(1), Unit: "days"},
+		"not time":    {Type: ChannelInt32, Minimum: int32(0), Step: int32(1), Unit: "m since 2000-01-01"},
+		"boolean":     {Type: ChannelBool, Minimum: true, Step: false, Unit: "days since 2000-01-01"},
+		"no calendar": {Type: ChannelInt32, Minimum: int32(0), Step: int32(1), Unit: "days since 2000-01-01", Calendar: Calendar(9)},
+		"nil":         nil,
+	} {
+		if _, err := axis.TimeValue(0); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAxisCalendarWriteRead(t *testing.T) {
+	header := NewHeader(binary.BigEndian, OffsetSize8)
+	axis := &Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(30), Unit: "days since 2000-01-01", Calendar: Calendar360Day}
+	dim := Dimension{Name: "time", Size: 12, TileSize: 6, Axis: axis}
+	var encoded bytes.Buffer
+	if err := dim.Write(&encoded, header); err != nil {
+		t.Fatal(err)
+	}
+	if encoded.Len() != dim.HeaderSize(header) {
+		t.Errorf("expected %d bytes, got %d", dim.HeaderSize(header), encoded.Len())
+	}
+	read := Dimension{}
+	if err := read.Read(bytes.NewReader(encoded.Bytes()), header); err != nil {
+		t.Fatal(err)
+	}
+	if !axesEqual(read.Axis, axis) {
+		t.Errorf("expected %+v, got %+v", *axis, *read.Axis)
+	}
+	if s := read.String(); s != "time(12 / 6) [0; 30; days since 2000-01-01; 360_day]" {
+		t.Errorf("unexpected description %s", s)
+	}
+
+	old := header
+	old.Version = VersionExtensions - 1
+	if err := dim.Write(&bytes.Buffer{}, old); err == nil {
+		t.Errorf("expected axis calendars to require version %d", VersionExtensions)
+	}
+
+	// shared axes keep their calendars, and those referring to them take them on
+	buf := buffer.NewBuffer(10)
+	pixi, err := Create(buf, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.AppendAxes(buf, map[string]*Axis{"time": axis}); err != nil {
+		t.Fatal(err)
+	}
+	reread, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared := reread.SharedAxis("time"); shared == nil || shared.Calendar != Calendar360Day {
+		t.Errorf("expected the shared axis to keep its calendar, got %v", shared)
+	}
+}
+
+func TestAxisCalendarExport(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("tas", DimensionSet{
+		{Name: "time", Size: 4, TileSize: 4, Axis: &Axis{Type: ChannelFloat64, Minimum: 15.5, Step: 30.0, Unit: "days since 1850-01-01", Calendar: CalendarNoLeap}},
+	}, ChannelSet{{Name: "tas", Type: ChannelFloat32, Unit: "K"}})
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{float32(280 + writer.Coordinate()[0])})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nc bytes.Buffer
+	if err := file.WriteNetCDF(file.Stream(), &nc); err != nil {
+		t.Fatal(err)
+	}
+	fromNetCDF, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fromNetCDF.AppendNetCDF(fromNetCDF.Stream(), bytes.NewReader(nc.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if axis := fromNetCDF.Layers[0].Dimensions[0].Axis; axis == nil || axis.Calendar != CalendarNoLeap {
+		t.Errorf("expected the NetCDF calendar attribute to round trip, got %v", axis)
+	}
+	if _, ok := fromNetCDF.AllTags()["time:calendar"]; ok {
+		t.Errorf("expected the calendar attribute not to be kept as a tag")
+	}
+
+	store, create := memoryZarrStore()
+	if err := file.WriteZarr(file.Stream(), create); err != nil {
+		t.Fatal(err)
+	}
+	if calendar := zarrTestMetadata(t, store, "time/zarr.json")["attributes"].(map[string]any)["calendar"]; calendar != "noleap" {
+		t.Errorf("expected the calendar of the time array, got %v", calendar)
+	}
+	fromZarr, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fromZarr.AppendZarr(fromZarr.Stream(), store); err != nil {
+		t.Fatal(err)
+	}
+	if axis := fromZarr.Layers[0].Dimensions[0].Axis; axis == nil || axis.Calendar != CalendarNoLeap {
+		t.Errorf("expected the Zarr calendar attribute to round trip, got %v", axis)
+	}
+}
@@ -0,0 +1,316 @@
+package gopixi
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// The number of datasets a Catalog keeps open unless another limit is given with WithMaxOpenDatasets.
+const DefaultMaxOpenDatasets int = 64
+
+// The number of bytes of decoded tiles a Catalog caches unless another budget is given with
+// WithTileCacheBudget.
+const DefaultTileCacheBudget int64 = 256 << 20
+
+type catalogOptions struct {
+	maxOpen  int
+	budget   int64
+	throttle *Throttle
+}
+
+type CatalogOption interface {
+	applyCatalog(*catalogOptions)
+}
+
+type maxOpenDatasetsOption struct {
+	max int
+}
+
+func (o maxOpenDatasetsOption) applyCatalog(opts *catalogOptions) {
+	opts.maxOpen = o.max
+}
+
+// Sets the number of datasets a Catalog keeps open at once. The least recently used dataset is closed
+// when another must be opened.
+func WithMaxOpenDatasets(datasets int) CatalogOption {
+	return maxOpenDatasetsOption{max: max(datasets, 1)}
+}
+
+type tileCacheBudgetOption struct {
+	bytes int64
+}
+
+func (o tileCacheBudgetOption) applyCatalog(opts *catalogOptions) {
+	opts.budget = o.bytes
+}
+
+// Sets the number of bytes of decoded tiles a Catalog caches, shared by all of its datasets. The least
+// recently used tiles are evicted to stay within the budget. A budget of zero disables the cache.
+func WithTileCacheBudget(bytes int64) CatalogOption {
+	return tileCacheBudgetOption{bytes: max(bytes, 0)}
+}
+
+type catalogThrottleOption struct {
+	throttle *Throttle
+}
+
+func (o catalogThrottleOption) applyCatalog(opts *catalogOptions) {
+	opts.throttle = o.throttle
+}
+
+// Limits the reads of every dataset opened by the catalog with the given throttle, as by wrapping each in a
+// ThrottledStream. Reads of tiles held in the tile cache of the catalog are not limited.
+func WithCatalogThrottle(t *Throttle) CatalogOption {
+	return catalogThrottleOption{throttle: t}
+}
+
+// Manages many Pixi datasets addressed by ID, opening them lazily and closing the least recently used beyond
+// the limit on open datasets. Decoded tiles of every dataset share one TileCache. A Catalog is safe for
+// concurrent use.
+type Catalog struct {
+	open     func(id string) (io.ReadSeekCloser, error)
+	maxOpen  int
+	budget   int64
+	throttle *Throttle
+
+	lock    sync.Mutex
+	handles map[string]*list.Element // of *catalogHandle, most recently used first
+	lru     *list.List
+
+	cacheLock sync.Mutex
+	tiles     map[catalogTile]*list.Element // of catalogCachedTile, most recently used first
+	tileLru   *list.List
+	cached    int64
+}
+
+type catalogHandle struct {
+	id   string
+	pixi *Pixi
+
+	lock    sync.Mutex // serializes reads of the stream
+	stream  io.ReadSeekCloser
+	refs    int
+	evicted bool
+}
+
+type catalogTile struct {
+	id    string
+	layer int
+	tile  int
+}
+
+type catalogCachedTile struct {
+	key  catalogTile
+	data []byte
+}
+
+// Creates a catalog that opens the dataset with a given ID using the open function.
+func NewCatalog(open func(id string) (io.ReadSeekCloser, error), opts ...CatalogOption) *Catalog {
+	options := catalogOptions{maxOpen: DefaultMaxOpenDatasets, budget: DefaultTileCacheBudget}
+	for _, o := range opts {
+		o.applyCatalog(&options)
+	}
+	return &Catalog{
+		open:     open,
+		maxOpen:  options.maxOpen,
+		budget:   options.budget,
+		throttle: options.throttle,
+		handles:  map[string]*list.Element{},
+		lru:      list.New(),
+		tiles:    map[catalogTile]*list.Element{},
+		tileLru:  list.New(),
+	}
+}
+
+// Returns a handle to the open dataset, opening it if needed, with a reference held until release.
+func (c *Catalog) acquire(id string) (*catalogHandle, error) {
+	c.lock.Lock()
+	if element, ok := c.handles[id]; ok {
+		c.lru.MoveToFront(element)
+		handle := element.Value.(*catalogHandle)
+		handle.refs++
+		c.lock.Unlock()
+		return handle, nil
+	}
+	c.lock.Unlock()
+
+	// opening may be slow, so it happens without holding the catalog lock
+	stream, err := c.open(id)
+	if err != nil {
+		return nil, err
+	}
+	if c.throttle != nil {
+		stream = NewThrottledStream(stream, c.throttle)
+	}
+	pixi, err := ReadPixi(stream)
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.handles[id]; ok {
+		// another caller opened the dataset in the meantime
+		stream.Close()
+		c.lru.MoveToFront(element)
+		handle := element.Value.(*catalogHandle)
+		handle.refs++
+		return handle, nil
+	}
+	handle := &catalogHandle{id: id, pixi: pixi, stream: stream, refs: 1}
+	c.handles[id] = c.lru.PushFront(handle)
+	for c.lru.Len() > c.maxOpen {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		evicted := oldest.Value.(*catalogHandle)
+		delete(c.handles, evicted.id)
+		evicted.evicted = true
+		if evicted.refs == 0 {
+			evicted.stream.Close()
+		}
+	}
+	return handle, nil
+}
+
+func (c *Catalog) release(handle *catalogHandle) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	handle.refs--
+	if handle.refs == 0 && handle.evicted {
+		handle.stream.Close()
+	}
+}
+
+// The metadata of the dataset with the given ID, opening it if needed.
+func (c *Catalog) Pixi(id string) (*Pixi, error) {
+	handle, err := c.acquire(id)
+	if err != nil {
+		return nil, err
+	}
+	defer c.release(handle)
+	return handle.pixi, nil
+}
+
+// Reads the decoded data of a tile of a layer of the dataset with the given ID, through the shared tile cache.
+// The returned data is shared with other readers and must not be modified.
+func (c *Catalog) Tile(id string, layerIndex int, tile int) ([]byte, error) {
+	key := catalogTile{id: id, layer: layerIndex, tile: tile}
+	c.cacheLock.Lock()
+	if element, ok := c.tiles[key]; ok {
+		c.tileLru.MoveToFront(element)
+		data := element.Value.(catalogCachedTile).data
+		c.cacheLock.Unlock()
+		return data, nil
+	}
+	c.cacheLock.Unlock()
+
+	handle, err := c.acquire(id)
+	if err != nil {
+		return nil, err
+	}
+	defer c.release(handle)
+	if layerIndex < 0 || layerIndex >= len(handle.pixi.Layers) {
+		return nil, ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	layer := handle.pixi.Layers[layerIndex]
+	if tile < 0 || tile >= layer.DiskTiles() {
+		return nil, ErrTileNotFound{TileIndex: tile}
+	}
+	data := make([]byte, layer.DiskTileSize(tile))
+	handle.lock.Lock()
+	err = layer.ReadTile(handle.stream, handle.pixi.Header, tile, data)
+	handle.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+	if _, ok := c.tiles[key]; !ok && int64(len(data)) <= c.budget {
+		c.tiles[key] = c.tileLru.PushFront(catalogCachedTile{key: key, data: data})
+		c.cached += int64(len(data))
+		for c.cached > c.budget {
+			oldest := c.tileLru.Back()
+			c.tileLru.Remove(oldest)
+			evicted := oldest.Value.(catalogCachedTile)
+			delete(c.tiles, evicted.key)
+			c.cached -= int64(len(evicted.data))
+		}
+	}
+	return data, nil
+}
+
+// Provides access to a layer of the dataset with the given ID, for use with sample accessors such as
+// SampleAt, reading tiles through the shared cache.
+func (c *Catalog) Layer(id string, layerIndex int) (TileAccessLayer, error) {
+	pixi, err := c.Pixi(id)
+	if err != nil {
+		return nil, err
+	}
+	if layerIndex < 0 || layerIndex >= len(pixi.Layers) {
+		return nil, ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	return catalogLayer{catalog: c, id: id, index: layerIndex, layer: pixi.Layers[layerIndex], header: pixi.Header}, nil
+}
+
+// The number of datasets currently open.
+func (c *Catalog) OpenDatasets() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// The number of bytes of decoded tiles currently cached.
+func (c *Catalog) CachedBytes() int64 {
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+	return c.cached
+}
+
+// Closes every open dataset (those still in use are closed once released) and empties the tile cache. The
+// catalog remains usable, reopening datasets as needed.
+func (c *Catalog) Close() error {
+	c.lock.Lock()
+	var errs []error
+	for _, element := range c.handles {
+		handle := element.Value.(*catalogHandle)
+		handle.evicted = true
+		if handle.refs == 0 {
+			errs = append(errs, handle.stream.Close())
+		}
+	}
+	c.handles = map[string]*list.Element{}
+	c.lru.Init()
+	c.lock.Unlock()
+
+	c.cacheLock.Lock()
+	c.tiles = map[catalogTile]*list.Element{}
+	c.tileLru.Init()
+	c.cached = 0
+	c.cacheLock.Unlock()
+	return errors.Join(errs...)
+}
+
+type catalogLayer struct {
+	catalog *Catalog
+	id      string
+	index   int
+	layer   Layer
+	header  Header
+}
+
+func (l catalogLayer) Layer() Layer {
+	return l.layer
+}
+
+func (l catalogLayer) Header() Header {
+	return l.header
+}
+
+func (l catalogLayer) Tile(tile int) ([]byte, error) {
+	return l.catalog.Tile(l.id, l.index, tile)
+}
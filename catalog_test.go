@@ -0,0 +1,149 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+type countingCloser struct {
+	*buffer.Buffer
+	closed *int
+}
+
+func (c countingCloser) Close() error {
+	*c.closed++
+	return nil
+}
+
+func TestCatalog(t *testing.T) {
+	header := NewHeader(binary.LittleEndian, OffsetSize4)
+	files := map[string][]byte{}
+	for i := range 3 {
+		buf := buffer.NewBuffer(10)
+		layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 16, TileSize: 8}}, ChannelSet{{Name: "v", Type: ChannelUint16}})}
+		writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+			return Sample{uint16(i*100 + coord[0])}
+		})
+		files[fmt.Sprintf("file%d", i)] = buf.Bytes()
+	}
+
+	lock := sync.Mutex{}
+	opened, closed := 0, 0
+	catalog := NewCatalog(func(id string) (io.ReadSeekCloser, error) {
+		data, ok := files[id]
+		if !ok {
+			return nil, fmt.Errorf("no dataset %s", id)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		opened++
+		return countingCloser{Buffer: buffer.NewBufferFrom(data), closed: &closed}, nil
+	}, WithMaxOpenDatasets(2), WithTileCacheBudget(32))
+
+	if catalog.OpenDatasets() != 0 {
+		t.Fatal("expected datasets to be opened lazily")
+	}
+	for i := range 3 {
+		layer, err := catalog.Layer(fmt.Sprintf("file%d", i), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sample, err := SampleAt(layer, SampleCoordinate{9})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sample[0] != uint16(i*100+9) {
+			t.Errorf("file%d: expected %d, got %v", i, i*100+9, sample[0])
+		}
+	}
+	if catalog.OpenDatasets() != 2 || opened != 3 || closed != 1 {
+		t.Errorf("expected 2 open datasets after 3 opens and 1 close, got %d open, %d opens, %d closes", catalog.OpenDatasets(), opened, closed)
+	}
+	// each tile is 16 bytes, so the budget holds the two most recent
+	if catalog.CachedBytes() != 32 {
+		t.Errorf("expected 32 cached bytes, got %d", catalog.CachedBytes())
+	}
+
+	// file2 is open and its tile cached, so reading it again opens nothing
+	if _, err := catalog.Tile("file2", 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if opened != 3 {
+		t.Errorf("expected a cached tile not to open the dataset, got %d opens", opened)
+	}
+	// file0 was closed, so reading a tile that is not cached reopens it
+	if _, err := catalog.Tile("file0", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if opened != 4 || closed != 2 {
+		t.Errorf("expected file0 to be reopened and another closed, got %d opens, %d closes", opened, closed)
+	}
+
+	if _, err := catalog.Tile("file0", 0, 7); !errors.As(err, new(ErrTileNotFound)) {
+		t.Errorf("expected ErrTileNotFound, got %v", err)
+	}
+	if _, err := catalog.Pixi("missing"); err == nil {
+		t.Error("expected an error opening a missing dataset")
+	}
+
+	if err := catalog.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if catalog.OpenDatasets() != 0 || catalog.CachedBytes() != 0 || closed != 4 {
+		t.Errorf("expected close to release everything, got %d open, %d cached bytes, %d closes", catalog.OpenDatasets(), catalog.CachedBytes(), closed)
+	}
+}
+
+func TestCatalogConcurrent(t *testing.T) {
+	header := NewHeader(binary.LittleEndian, OffsetSize4)
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 64, TileSize: 8}}, ChannelSet{{Name: "v", Type: ChannelUint32}}, WithCompression(CompressionFlate))}
+	writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint32(coord[0])}
+	})
+	data := buf.Bytes()
+
+	catalog := NewCatalog(func(id string) (io.ReadSeekCloser, error) {
+		closed := 0
+		return countingCloser{Buffer: buffer.NewBufferFrom(data), closed: &closed}, nil
+	}, WithMaxOpenDatasets(1), WithTileCacheBudget(64))
+	defer catalog.Close()
+
+	group := sync.WaitGroup{}
+	errs := make(chan error, 16)
+	for g := range 16 {
+		group.Go(func() {
+			id := fmt.Sprintf("file%d", g%4)
+			layer, err := catalog.Layer(id, 0)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for x := range 64 {
+				sample, err := SampleAt(layer, SampleCoordinate{x})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if sample[0] != uint32(x) {
+					errs <- fmt.Errorf("%s: expected %d at %d, got %v", id, x, x, sample[0])
+					return
+				}
+			}
+		})
+	}
+	group.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if catalog.CachedBytes() > 64 {
+		t.Errorf("expected the cache to stay within its budget, got %d bytes", catalog.CachedBytes())
+	}
+}
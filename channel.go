@@ -3,8 +3,10 @@ package gopixi
 import (
 	"cmp"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"math"
+	"strings"
 
 	"github.com/chenxingqiang/go-floatx"
 	"github.com/kshard/float8"
@@ -20,6 +22,29 @@ type Channel struct {
 	Type ChannelType // The type of data stored in each element of this channel.
 	Min  any         // Optional minimum value for the range of data in this channel. Must match Type if present.
 	Max  any         // Optional maximum value for the range of data in this channel. Must match Type if present.
+	Unit string      // Optional unit of the values in this channel (e.g., "W m-2", "K"). See MultiplyUnits and DivideUnits.
+	// Optional value read for every sample of this channel in tiles that were never written, so that sparse
+	// layers store nothing for empty regions. Must match Type if present. Requires VersionExtensions. String
+	// channels have no fill value, and read as the empty string in unwritten tiles.
+	FillValue any
+	// Optional count, mean, and standard deviation of the valid values of this channel, computed as its layer
+	// is written or by Pixi.RecomputeStats. Requires VersionExtensions, and is left out of the channel
+	// descriptions of files of earlier versions. See Stats.
+	Moments ChannelMoments
+	// Optional histogram of the valid values of this channel, stored by Pixi.StoreHistogram. Requires
+	// VersionExtensions, and is left out of the channel descriptions of files of earlier versions.
+	// See Layer.Histogram.
+	Histogram *SummaryHistogram
+}
+
+// Whether the moments of the channel are stored in its description in files with the given header.
+func (c Channel) storesMoments(h Header) bool {
+	return c.Moments.ValidCount > 0 && h.Version >= VersionExtensions
+}
+
+// Whether the histogram of the channel is stored in its description in files with the given header.
+func (c Channel) storesHistogram(h Header) bool {
+	return c.Histogram != nil && len(c.Histogram.Counts) > 0 && h.Version >= VersionExtensions
 }
 
 // Returns the size of a channel in bytes.
@@ -42,28 +67,111 @@ func (c Channel) PutValue(val any, order binary.ByteOrder, raw []byte) {
 	c.Type.PutValue(val, order, raw)
 }
 
+// Checks that the value has the Go type of the channel's values, as returned by Value, returning an
+// ErrValueType naming the channel if it does not.
+func (c Channel) CheckValue(val any) error {
+	if err := c.Type.CheckValue(val); err != nil {
+		mismatch := err.(ErrValueType)
+		mismatch.Channel = c.Name
+		return mismatch
+	}
+	return nil
+}
+
 // Get the size in bytes of this dimension description as it is laid out and written to disk.
 func (c Channel) HeaderSize(h Header) int {
-	size := 2 + len([]byte(c.Name)) + 4 // base size: name + channel type
+	size := h.FriendlySize(c.Name) + 4 // base size: name + channel type
 
 	// Add size for optional Min value
 	if c.Min != nil {
-		size += c.Type.Base().Size()
+		size += c.valueHeaderSize(h, c.Min)
 	}
 
 	// Add size for optional Max value
 	if c.Max != nil {
-		size += c.Type.Base().Size()
+		size += c.valueHeaderSize(h, c.Max)
+	}
+
+	// Add size for optional unit string
+	if c.Unit != "" {
+		size += h.FriendlySize(c.Unit)
+	}
+
+	// Add size for optional fill value
+	if c.FillValue != nil {
+		size += c.valueHeaderSize(h, c.FillValue)
+	}
+
+	// Add size for optional moments
+	if c.storesMoments(h) {
+		size += 3 * 8
+	}
+
+	// Add size for optional histogram
+	if c.storesHistogram(h) {
+		size += 4 + 4*8 + 8*len(c.Histogram.Counts)
 	}
 
 	return size
 }
 
+// The size in bytes of a value of the channel in its description: a friendly string for string channels,
+// whose values have no fixed size, and otherwise the size of the value.
+func (c Channel) valueHeaderSize(h Header, value any) int {
+	if c.Type.Base() == ChannelString {
+		return h.FriendlySize(value.(string))
+	}
+	return c.Type.Base().Size()
+}
+
+// Writes a value of the channel into its description, as a friendly string for string channels.
+func (c Channel) writeHeaderValue(w io.Writer, h Header, value any) error {
+	if c.Type.Base() == ChannelString {
+		return h.WriteFriendly(w, value.(string))
+	}
+	raw := make([]byte, c.Type.Base().Size())
+	c.Type.Base().PutValue(value, h.ByteOrder, raw)
+	_, err := w.Write(raw)
+	return err
+}
+
+// Reads a value of the channel from its description, as written by writeHeaderValue.
+func (c Channel) readHeaderValue(r io.Reader, h Header) (any, error) {
+	if c.Type.Base() == ChannelString {
+		return h.ReadFriendly(r)
+	}
+	raw := make([]byte, c.Type.Size())
+	if _, err := r.Read(raw); err != nil {
+		return nil, err
+	}
+	return c.Type.Value(raw, h.ByteOrder), nil
+}
+
 // Writes the binary description of the channel to the given stream, according to the specification
 // in the Pixi header h.
 func (c Channel) Write(w io.Writer, h Header) error {
-	// Set flags based on presence of Min/Max values
-	encodedType := c.Type.WithMin(c.Min != nil).WithMax(c.Max != nil)
+	if c.Type.Base() == ChannelString {
+		if h.Version < VersionExtensions {
+			return ErrFormat(fmt.Sprintf("string channels require version %d or later", VersionExtensions))
+		}
+		if c.FillValue != nil {
+			return ErrUnsupported("string channels cannot have fill values")
+		}
+	}
+	if c.Unit != "" && h.Version < VersionExtensions {
+		return ErrFormat(fmt.Sprintf("channel units require version %d or later", VersionExtensions))
+	}
+	if c.FillValue != nil {
+		if h.Version < VersionExtensions {
+			return ErrFormat(fmt.Sprintf("channel fill values require version %d or later", VersionExtensions))
+		}
+		if err := c.CheckValue(c.FillValue); err != nil {
+			return err
+		}
+	}
+
+	// Set flags based on presence of Min/Max values, unit, and fill value
+	encodedType := c.Type.WithMin(c.Min != nil).WithMax(c.Max != nil).WithUnit(c.Unit != "").WithFill(c.FillValue != nil).WithMoments(c.storesMoments(h)).WithHistogram(c.storesHistogram(h))
 
 	// write the name, then the channel type with flags
 	err := h.WriteFriendly(w, c.Name)
@@ -78,9 +186,7 @@ func (c Channel) Write(w io.Writer, h Header) error {
 
 	// Write optional Min value
 	if c.Min != nil {
-		minBytes := make([]byte, c.Type.Base().Size())
-		c.Type.Base().PutValue(c.Min, h.ByteOrder, minBytes)
-		_, err = w.Write(minBytes)
+		err = c.writeHeaderValue(w, h, c.Min)
 		if err != nil {
 			return err
 		}
@@ -88,14 +194,41 @@ func (c Channel) Write(w io.Writer, h Header) error {
 
 	// Write optional Max value
 	if c.Max != nil {
-		maxBytes := make([]byte, c.Type.Base().Size())
-		c.Type.Base().PutValue(c.Max, h.ByteOrder, maxBytes)
-		_, err = w.Write(maxBytes)
+		err = c.writeHeaderValue(w, h, c.Max)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write optional unit string
+	if c.Unit != "" {
+		err = h.WriteFriendly(w, c.Unit)
 		if err != nil {
 			return err
 		}
 	}
 
+	// Write optional fill value
+	if c.FillValue != nil {
+		err = c.writeHeaderValue(w, h, c.FillValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write optional moments
+	if c.storesMoments(h) {
+		err = h.Write(w, c.Moments)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write optional histogram
+	if c.storesHistogram(h) {
+		return c.Histogram.write(w, h)
+	}
+
 	return nil
 }
 
@@ -119,31 +252,83 @@ func (c *Channel) Read(r io.Reader, h Header) error {
 
 	// Read optional Min value
 	if encodedType.HasMin() {
-		minBytes := make([]byte, c.Type.Size())
-		_, err = r.Read(minBytes)
+		c.Min, err = c.readHeaderValue(r, h)
 		if err != nil {
 			return err
 		}
-		c.Min = c.Type.Value(minBytes, h.ByteOrder)
 	} else {
 		c.Min = nil
 	}
 
 	// Read optional Max value
 	if encodedType.HasMax() {
-		maxBytes := make([]byte, c.Type.Size())
-		_, err = r.Read(maxBytes)
+		c.Max, err = c.readHeaderValue(r, h)
 		if err != nil {
 			return err
 		}
-		c.Max = c.Type.Value(maxBytes, h.ByteOrder)
 	} else {
 		c.Max = nil
 	}
 
+	// Read optional unit string
+	if encodedType.HasUnit() {
+		c.Unit, err = h.ReadFriendly(r)
+		if err != nil {
+			return err
+		}
+	} else {
+		c.Unit = ""
+	}
+
+	// Read optional fill value
+	if encodedType.HasFill() {
+		c.FillValue, err = c.readHeaderValue(r, h)
+		if err != nil {
+			return err
+		}
+	} else {
+		c.FillValue = nil
+	}
+
+	// Read optional moments
+	c.Moments = ChannelMoments{}
+	if encodedType.HasMoments() {
+		err = h.Read(r, &c.Moments)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Read optional histogram
+	c.Histogram = nil
+	if encodedType.HasHistogram() {
+		c.Histogram = &SummaryHistogram{}
+		return c.Histogram.read(r, h)
+	}
+
 	return nil
 }
 
+// Clears the moments and histogram of the channel, which describe the distribution of its values and go stale
+// once they change.
+func (c *Channel) clearDistribution() {
+	c.Moments = ChannelMoments{}
+	c.Histogram = nil
+}
+
+// Updates the channel's Min and Max values and its moments based on a new value, as it is written to a new
+// layer. Values that are NaN or the fill value of the channel are left out of the moments.
+func (channel Channel) withStatistics(value any) Channel {
+	channel = channel.WithMinMax(value)
+	if channel.Type.Base() == ChannelString || (channel.FillValue != nil && value == channel.FillValue) {
+		return channel
+	}
+	if f, ok := channel.Type.ToFloat64(value); ok && !math.IsNaN(f) {
+		channel.Moments.add(f)
+	}
+	return channel
+}
+
 // Updates the channel's Min and Max values based on a new value. Returns true if the channel was modified.
 func (channel Channel) WithMinMax(value any) Channel {
 	// Update Min if needed
@@ -163,9 +348,13 @@ func (channel Channel) WithMinMax(value any) Channel {
 type ChannelType uint32
 
 const (
-	channelTypeBaseMask ChannelType = 0x3FFFFFFF // Mask for the base channel type (lower 30 bits)
-	channelTypeMinFlag  ChannelType = 0x40000000 // Flag for Min value presence (bit 30)
-	channelTypeMaxFlag  ChannelType = 0x80000000 // Flag for Max value presence (bit 31)
+	channelTypeBaseMask      ChannelType = 0x03FFFFFF // Mask for the base channel type (lower 26 bits)
+	channelTypeHistogramFlag ChannelType = 0x04000000 // Flag for histogram presence (bit 26)
+	channelTypeMomentsFlag   ChannelType = 0x08000000 // Flag for moments presence (bit 27)
+	channelTypeFillFlag      ChannelType = 0x10000000 // Flag for fill value presence (bit 28)
+	channelTypeUnitFlag      ChannelType = 0x20000000 // Flag for unit string presence (bit 29)
+	channelTypeMinFlag       ChannelType = 0x40000000 // Flag for Min value presence (bit 30)
+	channelTypeMaxFlag       ChannelType = 0x80000000 // Flag for Max value presence (bit 31)
 )
 
 const (
@@ -187,6 +376,10 @@ const (
 	ChannelUint128  ChannelType = 15 // A 128-bit unsigned integer using github.com/shogo82148/int128.
 	ChannelFloat128 ChannelType = 16 // A 128-bit floating point number using github.com/shogo82148/float128.
 	ChannelBFloat16 ChannelType = 17 // A 16-bit brain floating point number.
+	// A string of any length, such as a station identifier or a category name, whose bytes are stored in the
+	// string data block of each tile (see Layer.StringBytes). Each sample holds an 8-byte slot of the offset
+	// of its string from the start of the slot and the length of the string, both unsigned 32-bit integers.
+	ChannelString ChannelType = 18
 )
 
 // Returns the base channel type without the optional flags.
@@ -204,6 +397,26 @@ func (c ChannelType) HasMax() bool {
 	return c&channelTypeMaxFlag != 0
 }
 
+// Returns whether the unit string flag is set.
+func (c ChannelType) HasUnit() bool {
+	return c&channelTypeUnitFlag != 0
+}
+
+// Returns whether the fill value flag is set.
+func (c ChannelType) HasFill() bool {
+	return c&channelTypeFillFlag != 0
+}
+
+// Returns whether the moments flag is set.
+func (c ChannelType) HasMoments() bool {
+	return c&channelTypeMomentsFlag != 0
+}
+
+// Returns whether the histogram flag is set.
+func (c ChannelType) HasHistogram() bool {
+	return c&channelTypeHistogramFlag != 0
+}
+
 // Returns a new ChannelType with the Min flag set or cleared.
 func (c ChannelType) WithMin(hasMin bool) ChannelType {
 	if hasMin {
@@ -220,6 +433,38 @@ func (c ChannelType) WithMax(hasMax bool) ChannelType {
 	return c & ^channelTypeMaxFlag
 }
 
+// Returns a new ChannelType with the unit flag set or cleared.
+func (c ChannelType) WithUnit(hasUnit bool) ChannelType {
+	if hasUnit {
+		return c | channelTypeUnitFlag
+	}
+	return c & ^channelTypeUnitFlag
+}
+
+// Returns a new ChannelType with the fill value flag set or cleared.
+func (c ChannelType) WithFill(hasFill bool) ChannelType {
+	if hasFill {
+		return c | channelTypeFillFlag
+	}
+	return c & ^channelTypeFillFlag
+}
+
+// Returns a new ChannelType with the moments flag set or cleared.
+func (c ChannelType) WithMoments(hasMoments bool) ChannelType {
+	if hasMoments {
+		return c | channelTypeMomentsFlag
+	}
+	return c & ^channelTypeMomentsFlag
+}
+
+// Returns a new ChannelType with the histogram flag set or cleared.
+func (c ChannelType) WithHistogram(hasHistogram bool) ChannelType {
+	if hasHistogram {
+		return c | channelTypeHistogramFlag
+	}
+	return c & ^channelTypeHistogramFlag
+}
+
 // This function returns the size of each element in a channel in bytes.
 func (c ChannelType) Size() int {
 	switch c.Base() {
@@ -259,6 +504,8 @@ func (c ChannelType) Size() int {
 		return 16
 	case ChannelBFloat16:
 		return 2
+	case ChannelString:
+		return stringSlotSize
 	default:
 		panic("pixi: unsupported channel type")
 	}
@@ -302,6 +549,8 @@ func (c ChannelType) String() string {
 		return "float128"
 	case ChannelBFloat16:
 		return "bfloat16"
+	case ChannelString:
+		return "string"
 	default:
 		panic("pixi: unsupported channel type")
 	}
@@ -381,14 +630,75 @@ func (c ChannelType) Value(raw []byte, o binary.ByteOrder) any {
 		// Read BFloat16 from bytes
 		bits := o.Uint16(raw)
 		return floatx.BF16Frombits(bits)
+	case ChannelString:
+		// the raw bytes extend from the slot to the end of the tile, through the string data block
+		offset, length := o.Uint32(raw), o.Uint32(raw[4:])
+		if length == 0 {
+			return ""
+		}
+		return string(raw[offset : offset+length])
 	default:
 		panic("pixi: tried to read unsupported channel type")
 	}
 }
 
-// Writes the given value, assumed to correspond to the ChannelType, into it's raw representation
-// in bytes according to the byte order specified.
+// Checks that the value has the Go type of the values of the ChannelType, as returned by Value, returning
+// an ErrValueType if it does not.
+func (c ChannelType) CheckValue(val any) error {
+	ok := false
+	switch c.Base() {
+	case ChannelInt8:
+		_, ok = val.(int8)
+	case ChannelUint8:
+		_, ok = val.(uint8)
+	case ChannelInt16:
+		_, ok = val.(int16)
+	case ChannelUint16:
+		_, ok = val.(uint16)
+	case ChannelInt32:
+		_, ok = val.(int32)
+	case ChannelUint32:
+		_, ok = val.(uint32)
+	case ChannelInt64:
+		_, ok = val.(int64)
+	case ChannelUint64:
+		_, ok = val.(uint64)
+	case ChannelFloat8:
+		_, ok = val.(float8.Float8)
+	case ChannelFloat16:
+		_, ok = val.(float16.Float16)
+	case ChannelFloat32:
+		_, ok = val.(float32)
+	case ChannelFloat64:
+		_, ok = val.(float64)
+	case ChannelBool:
+		_, ok = val.(bool)
+	case ChannelInt128:
+		_, ok = val.(int128.Int128)
+	case ChannelUint128:
+		_, ok = val.(int128.Uint128)
+	case ChannelFloat128:
+		_, ok = val.(float128.Float128)
+	case ChannelBFloat16:
+		_, ok = val.(floatx.BFloat16)
+	case ChannelString:
+		_, ok = val.(string)
+	}
+	if !ok {
+		return ErrValueType{Expected: c.Base(), Got: fmt.Sprintf("%T", val)}
+	}
+	return nil
+}
+
+// Writes the given value, which must have the Go type of the ChannelType, into its raw representation in
+// bytes according to the byte order specified. Panics with an ErrValueType if the value has the wrong type.
+// Only the empty string can be written to the slot of a string channel this way.
 func (c ChannelType) PutValue(val any, o binary.ByteOrder, bytes []byte) {
+	if c.Base() != ChannelUnknown {
+		if err := c.CheckValue(val); err != nil {
+			panic(err)
+		}
+	}
 	switch c.Base() {
 	case ChannelUnknown:
 		panic("pixi: tried to write channel with unknown size")
@@ -457,6 +767,11 @@ func (c ChannelType) PutValue(val any, o binary.ByteOrder, bytes []byte) {
 		// Write BFloat16 to bytes
 		bf16 := val.(floatx.BFloat16)
 		o.PutUint16(bytes, uint16(bf16))
+	case ChannelString:
+		if val.(string) != "" {
+			panic(ErrUnsupported("strings must be written with the string data block of their tile"))
+		}
+		clear(bytes[:stringSlotSize])
 	default:
 		panic("pixi: tried to write unsupported channel type")
 	}
@@ -569,6 +884,8 @@ func (ctype ChannelType) CompareValues(a, b any) int {
 		va, vb := a.(floatx.BFloat16), b.(floatx.BFloat16)
 		vaf, vbf := va.Float32(), vb.Float32()
 		return cmp.Compare(vaf, vbf)
+	case ChannelString:
+		return strings.Compare(a.(string), b.(string))
 	default:
 		return 0
 	}
@@ -3,6 +3,7 @@ package gopixi
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -222,6 +223,11 @@ func TestChannelWithMinMaxWriteRead(t *testing.T) {
 		{Name: "uint128_with_max", Type: ChannelUint128, Min: nil, Max: int128.Uint128{H: 0, L: 999999}},
 		{Name: "float128_with_min", Type: ChannelFloat128, Min: float128.FromFloat64(-123.456), Max: nil},
 		{Name: "bfloat16_with_both", Type: ChannelBFloat16, Min: floatx.BF16Fromfloat32(-1.0), Max: floatx.BF16Fromfloat32(1.0)},
+		{Name: "float32_with_unit", Type: ChannelFloat32, Unit: "W m-2"},
+		{Name: "uint16_with_all", Type: ChannelUint16, Min: uint16(3), Max: uint16(7), Unit: "K"},
+		{Name: "float32_with_fill", Type: ChannelFloat32, FillValue: float32(-9999)},
+		{Name: "int16_with_all", Type: ChannelInt16, Min: int16(-3), Max: int16(7), Unit: "m", FillValue: int16(-32768)},
+		{Name: "bool_with_fill", Type: ChannelBool, FillValue: true},
 	}
 
 	for _, c := range cases {
@@ -231,6 +237,9 @@ func TestChannelWithMinMaxWriteRead(t *testing.T) {
 			if err != nil {
 				t.Fatal("write channel", err)
 			}
+			if len(buf.Bytes()) != c.HeaderSize(h) {
+				t.Errorf("expected channel header size %d, wrote %d bytes", c.HeaderSize(h), len(buf.Bytes()))
+			}
 
 			readBuf := buffer.NewBufferFrom(buf.Bytes())
 			readChannel := Channel{}
@@ -246,6 +255,32 @@ func TestChannelWithMinMaxWriteRead(t *testing.T) {
 	}
 }
 
+func TestChannelFillValueRequiresVersion(t *testing.T) {
+	c := Channel{Name: "sst", Type: ChannelFloat32, FillValue: float32(-9999)}
+	for _, h := range allHeaderVariants(VersionExtensions - 1) {
+		if err := c.Write(buffer.NewBuffer(100), h); err == nil {
+			t.Errorf("expected error writing a fill value with header %+v", h)
+		}
+	}
+	c.FillValue = -9999.0
+	if err := c.Write(buffer.NewBuffer(100), NewHeader(binary.LittleEndian, OffsetSize4)); err == nil {
+		t.Error("expected error writing a fill value of the wrong type")
+	}
+}
+
+func TestChannelUnitRequiresVersion(t *testing.T) {
+	c := Channel{Name: "sst", Type: ChannelFloat32, Unit: "K"}
+	v1 := NewHeader(binary.LittleEndian, OffsetSize4)
+	v1.Version = 1
+	var formatErr ErrFormat
+	if err := c.Write(buffer.NewBuffer(100), v1); !errors.As(err, &formatErr) {
+		t.Errorf("expected writing a unit with a version 1 header to fail, got %v", err)
+	}
+	if err := c.Write(buffer.NewBuffer(100), NewHeader(binary.LittleEndian, OffsetSize4)); err != nil {
+		t.Errorf("expected writing a unit with a version %d header to succeed, got %v", Version, err)
+	}
+}
+
 func TestChannelTypeFlags(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -391,3 +426,52 @@ func TestTileOrderWriteIteratorMinMaxTracking(t *testing.T) {
 		}
 	}
 }
+
+func TestChannelCheckValue(t *testing.T) {
+	valid := map[ChannelType]any{
+		ChannelInt8: int8(1), ChannelUint8: uint8(1), ChannelInt16: int16(1), ChannelUint16: uint16(1),
+		ChannelInt32: int32(1), ChannelUint32: uint32(1), ChannelInt64: int64(1), ChannelUint64: uint64(1),
+		ChannelFloat8: float8.Float8(0), ChannelFloat16: float16.Fromfloat32(1), ChannelFloat32: float32(1),
+		ChannelFloat64: float64(1), ChannelBool: true, ChannelInt128: int128.Int128{}, ChannelUint128: int128.Uint128{},
+		ChannelFloat128: float128.Float128{}, ChannelBFloat16: floatx.BFloat16(0),
+	}
+	for channelType, value := range valid {
+		if err := channelType.CheckValue(value); err != nil {
+			t.Errorf("%v: unexpected error for %T: %v", channelType, value, err)
+		}
+		if err := channelType.WithMin(true).CheckValue(value); err != nil {
+			t.Errorf("%v with flags: unexpected error for %T: %v", channelType, value, err)
+		}
+	}
+
+	channel := Channel{Name: "temperature", Type: ChannelInt32}
+	var mismatch ErrValueType
+	if err := channel.CheckValue(3.5); !errors.As(err, &mismatch) || mismatch.Channel != "temperature" ||
+		mismatch.Expected != ChannelInt32 || mismatch.Got != "float64" {
+		t.Errorf("expected type mismatch for channel, got %v", err)
+	}
+	if err := ChannelUnknown.CheckValue(int32(1)); err == nil {
+		t.Error("expected error checking a value of an unknown channel type")
+	}
+
+	defer func() {
+		if err, ok := recover().(ErrValueType); !ok || err.Got != "int" {
+			t.Errorf("expected PutValue to panic with a type mismatch, got %v", err)
+		}
+	}()
+	ChannelUint16.PutValue(7, binary.LittleEndian, make([]byte, 2))
+}
+
+func TestChannelSetCheckSample(t *testing.T) {
+	channels := ChannelSet{{Name: "a", Type: ChannelUint8}, {Name: "b", Type: ChannelFloat32}}
+	if err := channels.CheckSample(Sample{uint8(1), float32(2)}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	var mismatch ErrValueType
+	if err := channels.CheckSample(Sample{uint8(1), 2.0}); !errors.As(err, &mismatch) || mismatch.Channel != "b" {
+		t.Errorf("expected type mismatch for channel b, got %v", err)
+	}
+	if err := channels.CheckSample(Sample{uint8(1)}); err == nil {
+		t.Error("expected error for a sample missing a channel")
+	}
+}
@@ -0,0 +1,137 @@
+package gopixi
+
+import (
+	"slices"
+	"sync"
+)
+
+type channelsOption struct {
+	names []string
+}
+
+func (o channelsOption) applyRead(opts *readOptions) {
+	opts.channels = o.names
+}
+
+// Makes reads return only the named channels of the layer, in the given order. For separated layers the
+// tiles of the other channels are never fetched or decoded; for interleaved layers every tile is still read
+// whole, but the samples returned hold only the selected channels. Any fill sample given for absent tiles
+// must still have a value for every channel of the layer.
+func WithChannels(names ...string) ReadOption {
+	return channelsOption{names: names}
+}
+
+// Provides access to a subset of the channels of a layer, in a chosen order. The layer it reports has only
+// the selected channels, and the tiles of channels of a separated layer that are not selected are never read.
+type ChannelSelectLayer struct {
+	base    TileAccessLayer
+	layer   Layer
+	indices []int // the index in the base layer of each selected channel
+	maxSize int
+
+	cacheLock sync.Mutex
+	cache     map[int][]byte
+	order     []int // cached tiles, oldest first
+}
+
+var _ TileAccessLayer = (*ChannelSelectLayer)(nil)
+
+// Wraps the tiles of the base layer so that it has only the named channels, in the given order. Up to
+// maxSize repacked tiles of an interleaved layer are kept in memory.
+func NewChannelSelectLayer(base TileAccessLayer, maxSize int, channels ...string) (*ChannelSelectLayer, error) {
+	layer := base.Layer()
+	if len(channels) == 0 {
+		return nil, ErrFormat("channel selection must name at least one channel")
+	}
+	indices, err := channelIndices(layer.Channels, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := layer
+	selected.Channels = make(ChannelSet, len(indices))
+	for i, index := range indices {
+		selected.Channels[i] = layer.Channels[index]
+	}
+	selected.StringBytes = selected.emptyStringBytes()
+	if layer.Separated && len(layer.TileBytes) == layer.DiskTiles() {
+		tiles := layer.Dimensions.Tiles()
+		selected.TileBytes = make([]int64, tiles*len(indices))
+		selected.TileOffsets = make([]int64, tiles*len(indices))
+		for i, index := range indices {
+			copy(selected.TileBytes[i*tiles:], layer.TileBytes[index*tiles:(index+1)*tiles])
+			copy(selected.TileOffsets[i*tiles:], layer.TileOffsets[index*tiles:(index+1)*tiles])
+			if selected.StringBytes != nil {
+				copy(selected.StringBytes[i*tiles:], layer.StringBytes[index*tiles:(index+1)*tiles])
+			}
+		}
+	}
+	return &ChannelSelectLayer{
+		base:    base,
+		layer:   selected,
+		indices: indices,
+		maxSize: max(maxSize, 1),
+		cache:   map[int][]byte{},
+	}, nil
+}
+
+func (c *ChannelSelectLayer) Layer() Layer {
+	return c.layer
+}
+
+func (c *ChannelSelectLayer) Header() Header {
+	return c.base.Header()
+}
+
+func (c *ChannelSelectLayer) Tile(tile int) ([]byte, error) {
+	dims := c.layer.Dimensions
+	if c.layer.Separated {
+		channel := tile / dims.Tiles()
+		if channel < 0 || channel >= len(c.indices) {
+			return nil, ErrTileNotFound{TileIndex: tile}
+		}
+		return c.base.Tile(tile%dims.Tiles() + c.indices[channel]*dims.Tiles())
+	}
+
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+	if data, ok := c.cache[tile]; ok {
+		return data, nil
+	}
+	data, err := c.base.Tile(tile)
+	if err != nil {
+		return nil, err
+	}
+
+	// copy the bytes of the selected channels out of every sample of the tile, including its halo, and the
+	// strings of selected string channels into a new string data block
+	order := c.base.Header().ByteOrder
+	channels := c.base.Layer().Channels
+	offsets := make([]int, len(c.indices))
+	for i, index := range c.indices {
+		offsets[i] = channels.Offset(index)
+	}
+	stride, selectedStride := channels.Size(), c.layer.Channels.Size()
+	samples := dims.TileSamples() + dims.HaloSamples()
+	repacked := make([]byte, samples*selectedStride)
+	slot := 0
+	for sample := range samples {
+		for i, index := range c.indices {
+			start := sample*stride + offsets[i]
+			if channels[index].Type.Base() == ChannelString {
+				repacked = putString(repacked, slot, channels[index].Value(data[start:], order).(string), order)
+			} else {
+				copy(repacked[slot:], data[start:start+channels[index].Size()])
+			}
+			slot += channels[index].Size()
+		}
+	}
+
+	if len(c.order) >= c.maxSize {
+		delete(c.cache, c.order[0])
+		c.order = slices.Delete(c.order, 0, 1)
+	}
+	c.order = append(c.order, tile)
+	c.cache[tile] = repacked
+	return repacked, nil
+}
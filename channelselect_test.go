@@ -0,0 +1,94 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func newChannelSelectTestFile(t *testing.T, opts ...LayerOption) *MemoryFile {
+	t.Helper()
+	file, err := NewMemoryFile(NewHeader(binary.BigEndian, OffsetSize8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 6, TileSize: 4}, {Name: "y", Size: 3, TileSize: 2}},
+		ChannelSet{{Name: "a", Type: ChannelUint16}, {Name: "b", Type: ChannelBool}, {Name: "c", Type: ChannelFloat64}}, opts...)
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			coord := writer.Coordinate()
+			writer.SetSample(Sample{uint16(coord[0] + coord[1]*10), coord[0]%2 == 0, float64(coord[1]) / 2})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestReadLayerWithChannels(t *testing.T) {
+	for _, planar := range []bool{false, true} {
+		opts := []LayerOption{}
+		if planar {
+			opts = append(opts, WithPlanar())
+		}
+		file := newChannelSelectTestFile(t, opts...)
+		layer, err := file.Layer(0, WithChannels("c", "a"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if channels := layer.Layer().Channels; len(channels) != 2 || channels[0].Name != "c" || channels[1].Name != "a" {
+			t.Fatalf("planar %v: unexpected selected channels %v", planar, channels)
+		}
+		for coord := range layer.Layer().Dimensions.SampleCoordinates() {
+			sample, err := SampleAt(layer, coord)
+			if err != nil || sample[0] != float64(coord[1])/2 || sample[1] != uint16(coord[0]+coord[1]*10) {
+				t.Errorf("planar %v: sample %v: got %v (%v)", planar, coord, sample, err)
+			}
+		}
+		if _, err := file.Layer(0, WithChannels("missing")); !errors.As(err, &ErrChannelNotFound{}) {
+			t.Errorf("planar %v: expected channel not found error, got %v", planar, err)
+		}
+	}
+}
+
+func TestReadRegionWithChannelsSkipsTiles(t *testing.T) {
+	file := newChannelSelectTestFile(t, WithPlanar())
+	layer := file.Layers[0]
+	region := Region{Start: SampleCoordinate{1, 1}, End: SampleCoordinate{6, 3}}
+
+	full, err := layer.ExplainRead(file.Header, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := layer.ExplainRead(file.Header, region, WithChannels("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Tiles) != len(full.Tiles)/3 || plan.DecodedSamples != full.DecodedSamples {
+		t.Errorf("expected only the tiles of one channel, got %v of %v", plan.Tiles, full.Tiles)
+	}
+
+	// reading the other channels would fail, since their tiles are corrupted
+	for _, tile := range full.Tiles {
+		if tile < layer.Dimensions.Tiles() {
+			file.Bytes()[layer.TileOffsets[tile]] ^= 0xff
+		}
+	}
+	if _, err := layer.ReadRegion(file.Stream(), file.Header, region); !errors.As(err, &ErrChecksum{}) {
+		t.Errorf("expected checksum error reading every channel, got %v", err)
+	}
+	samples, err := layer.ReadRegion(file.Stream(), file.Header, region, WithChannels("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := 0
+	for coord := range region.Coordinates() {
+		if len(samples[i]) != 1 || samples[i][0] != (coord[0]%2 == 0) {
+			t.Errorf("sample %v: got %v", coord, samples[i])
+		}
+		i++
+	}
+}
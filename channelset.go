@@ -1,10 +1,16 @@
 package gopixi
 
+import (
+	"fmt"
+	"slices"
+)
+
 // An ordered set of named channels present in each sample of a layer in a Pixi file.
 type ChannelSet []Channel
 
 // The size in bytes of each sample in the data set. Each channel has a fixed size, and a sample
-// is made up of one element of each channel, so the sample size is the sum of all channel sizes.
+// is made up of one element of each channel, so the sample size is the sum of all channel sizes. The
+// strings of string channels are stored apart from their samples, which only hold their slots.
 func (set ChannelSet) Size() int {
 	sampleSize := 0
 	for _, c := range set {
@@ -13,6 +19,20 @@ func (set ChannelSet) Size() int {
 	return sampleSize
 }
 
+// Checks that the sample has a value of the right Go type for every channel of the set, as a schema check
+// before writing it, returning an ErrValueType for the first value that does not.
+func (set ChannelSet) CheckSample(sample Sample) error {
+	if len(sample) != len(set) {
+		return ErrFormat(fmt.Sprintf("sample has %d values, expected %d channels", len(sample), len(set)))
+	}
+	for i, channel := range set {
+		if err := channel.CheckValue(sample[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // The index of the (first) channel with the given name in the set, or -1 if not found.
 func (set ChannelSet) Index(channelName string) int {
 	for i, channel := range set {
@@ -44,3 +64,39 @@ func (set ChannelSet) NamedOffset(channelName string) (int, bool) {
 	}
 	return 0, false
 }
+
+// Whether any channel of the set has a FillValue.
+func (set ChannelSet) HasFillValues() bool {
+	for _, channel := range set {
+		if channel.FillValue != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// The sample read in tiles that were never written: the FillValue of each channel, or zero (the empty string
+// for string channels) for channels without one.
+func (set ChannelSet) FillSample() Sample {
+	fill := make(Sample, len(set))
+	for i, channel := range set {
+		if channel.FillValue != nil {
+			fill[i] = channel.FillValue
+		} else if channel.Type.Base() == ChannelString {
+			fill[i] = ""
+		} else {
+			fill[i] = channel.Type.FromFloat64(0)
+		}
+	}
+	return fill
+}
+
+// The channels with their moments and histograms cleared, such as before they are computed anew or once
+// they are stale.
+func (set ChannelSet) withoutDistributions() ChannelSet {
+	set = slices.Clone(set)
+	for i := range set {
+		set[i].clearDistribution()
+	}
+	return set
+}
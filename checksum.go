@@ -0,0 +1,168 @@
+package gopixi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/bits"
+)
+
+// The algorithm used to compute the checksum stored after every tile of a file, recorded in the file header
+// from VersionExtensions onward. Cheap checksums guard against accidental corruption on hot paths, while
+// cryptographic digests let security-sensitive archives detect deliberate tampering with their tiles.
+type ChecksumAlgorithm uint32
+
+const (
+	// CRC-32 with the IEEE polynomial, in four bytes: the default, and the only algorithm of files written
+	// before VersionExtensions.
+	ChecksumCRC32 ChecksumAlgorithm = 0
+	// CRC-32 with the Castagnoli polynomial, in four bytes, which is computed in hardware on most processors.
+	ChecksumCRC32C ChecksumAlgorithm = 1
+	// The 64-bit xxHash of the tile with a seed of zero, in eight bytes.
+	ChecksumXXHash64 ChecksumAlgorithm = 2
+	// The SHA-256 digest of the tile, in thirty-two bytes.
+	ChecksumSHA256 ChecksumAlgorithm = 3
+)
+
+func (c ChecksumAlgorithm) String() string {
+	switch c {
+	case ChecksumCRC32:
+		return "crc32"
+	case ChecksumCRC32C:
+		return "crc32c"
+	case ChecksumXXHash64:
+		return "xxhash64"
+	case ChecksumSHA256:
+		return "sha256"
+	default:
+		return fmt.Sprintf("ChecksumAlgorithm(%d)", uint32(c))
+	}
+}
+
+// Parses the name of a checksum algorithm, as returned by its String method.
+func ParseChecksumAlgorithm(name string) (ChecksumAlgorithm, error) {
+	for c := ChecksumCRC32; c <= ChecksumSHA256; c++ {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return 0, ErrFormat(fmt.Sprintf("unknown checksum algorithm '%s'", name))
+}
+
+// The number of bytes of the checksum stored after each tile, or zero for an unknown algorithm.
+func (c ChecksumAlgorithm) Size() int {
+	switch c {
+	case ChecksumCRC32, ChecksumCRC32C:
+		return 4
+	case ChecksumXXHash64:
+		return 8
+	case ChecksumSHA256:
+		return sha256.Size
+	}
+	return 0
+}
+
+// Whether the algorithm is a cryptographic digest, for which tiles cannot feasibly be altered without
+// changing their checksums.
+func (c ChecksumAlgorithm) Cryptographic() bool {
+	return c == ChecksumSHA256
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Appends the checksum of the data, computed with the algorithm of the header, to dst. Checksums of four
+// and eight bytes are written as integers in the byte order of the header, and digests as they are.
+func (s Header) appendChecksum(dst []byte, data []byte) []byte {
+	var checksum [8]byte
+	switch s.Checksum {
+	case ChecksumCRC32C:
+		s.ByteOrder.PutUint32(checksum[:], crc32.Checksum(data, castagnoli))
+	case ChecksumXXHash64:
+		s.ByteOrder.PutUint64(checksum[:], xxhash64(data))
+	case ChecksumSHA256:
+		digest := sha256.Sum256(data)
+		return append(dst, digest[:]...)
+	default:
+		s.ByteOrder.PutUint32(checksum[:], crc32.ChecksumIEEE(data))
+	}
+	return append(dst, checksum[:s.Checksum.Size()]...)
+}
+
+// Writes the checksum of the data to the current position in the writer stream.
+func (s Header) writeChecksum(w io.Writer, data []byte) error {
+	var checksum [sha256.Size]byte
+	_, err := w.Write(s.appendChecksum(checksum[:0], data))
+	return err
+}
+
+// Reports whether the stored checksum is the checksum of the data.
+func (s Header) checksumMatches(stored []byte, data []byte) bool {
+	var checksum [sha256.Size]byte
+	return bytes.Equal(stored, s.appendChecksum(checksum[:0], data))
+}
+
+// The primes of xxHash, as variables so that the arithmetic of the initial state wraps around.
+var (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// The 64-bit xxHash of the data with a seed of zero.
+func xxhash64(data []byte) uint64 {
+	n := uint64(len(data))
+	var h uint64
+	if len(data) >= 32 {
+		v1, v2, v3, v4 := xxPrime1+xxPrime2, xxPrime2, uint64(0), -xxPrime1
+		for ; len(data) >= 32; data = data[32:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(data))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(data[8:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(data[16:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(data[24:]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMergeRound(h, v1)
+		h = xxMergeRound(h, v2)
+		h = xxMergeRound(h, v3)
+		h = xxMergeRound(h, v4)
+	} else {
+		h = xxPrime5
+	}
+	h += n
+
+	for ; len(data) >= 8; data = data[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(data))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(data) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(data)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		data = data[4:]
+	}
+	for _, b := range data {
+		h ^= uint64(b) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxRound(acc uint64, input uint64) uint64 {
+	acc += input * xxPrime2
+	return bits.RotateLeft64(acc, 31) * xxPrime1
+}
+
+func xxMergeRound(acc uint64, value uint64) uint64 {
+	acc ^= xxRound(0, value)
+	return acc*xxPrime1 + xxPrime4
+}
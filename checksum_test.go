@@ -0,0 +1,152 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestXXHash64(t *testing.T) {
+	for input, expected := range map[string]uint64{
+		"":    0xef46db3751d8e999,
+		"a":   0xd24ec4f1a98c6e5b,
+		"abc": 0x44bc2cf5ad770999,
+		"Nobody inspects the spammish repetition": 0xfbcea83c8a378bf1,
+	} {
+		if got := xxhash64([]byte(input)); got != expected {
+			t.Errorf("xxhash64(%q): expected %x, got %x", input, expected, got)
+		}
+	}
+}
+
+func TestChecksumAlgorithms(t *testing.T) {
+	for checksum := ChecksumCRC32; checksum <= ChecksumSHA256; checksum++ {
+		parsed, err := ParseChecksumAlgorithm(checksum.String())
+		if err != nil || parsed != checksum {
+			t.Errorf("expected to parse %v, got %v (%v)", checksum, parsed, err)
+		}
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			buf := buffer.NewBuffer(10)
+			header := NewHeader(order, OffsetSize4)
+			header.Checksum = checksum
+			layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 10, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelInt32}}, WithCompression(CompressionFlate))}
+			written := writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+				return Sample{int32(coord[0] * 3)}
+			})
+			file := buffer.NewBufferFrom(buf.Bytes())
+			read, err := ReadPixi(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if read.Header.Checksum != checksum {
+				t.Fatalf("expected the header to record %v, got %v", checksum, read.Header.Checksum)
+			}
+			layer := read.Layers[0]
+			if size := layer.TileRange(read.Header, 1).Length - layer.TileBytes[1]; size != int64(checksum.Size()) {
+				t.Errorf("%v: expected %d checksum bytes, got %d", checksum, checksum.Size(), size)
+			}
+			if got := written.LiveBytes(); got != int64(len(buf.Bytes())) {
+				t.Errorf("%v: expected %d live bytes, got %d", checksum, len(buf.Bytes()), got)
+			}
+			samples, err := layer.ReadRegion(file, read.Header, FullRegion(layer.Dimensions))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if samples[7][0] != int32(21) {
+				t.Errorf("%v: expected sample 7 to be 21, got %v", checksum, samples[7][0])
+			}
+
+			// a single flipped bit in the stored checksum is detected
+			corrupted := bytes.Clone(buf.Bytes())
+			corrupted[layer.TileOffsets[1]+layer.TileBytes[1]+int64(checksum.Size())-1] ^= 1
+			data := make([]byte, layer.DiskTileSize(1))
+			if err := layer.ReadTile(buffer.NewBufferFrom(corrupted), read.Header, 1, data); !errors.As(err, &ErrChecksum{}) {
+				t.Errorf("%v: expected a checksum error, got %v", checksum, err)
+			}
+		}
+	}
+	if !ChecksumSHA256.Cryptographic() || ChecksumXXHash64.Cryptographic() {
+		t.Errorf("expected only sha256 to be cryptographic")
+	}
+}
+
+func TestChecksumHeaderVersions(t *testing.T) {
+	header := NewHeader(binary.LittleEndian, OffsetSize8)
+	header.Checksum = ChecksumSHA256
+	header.Version = VersionExtensions - 1
+	if err := header.WriteHeader(&bytes.Buffer{}); err == nil {
+		t.Errorf("expected sha256 checksums to require version %d", VersionExtensions)
+	}
+
+	// files of earlier versions have no checksum field, and always use crc32
+	header.Checksum = ChecksumCRC32
+	var encoded bytes.Buffer
+	if err := header.WriteHeader(&encoded); err != nil {
+		t.Fatal(err)
+	}
+	if encoded.Len() != header.DiskSize() || encoded.Len() != 4+2+1+1+2*8 {
+		t.Errorf("expected a %d byte header, got %d", header.DiskSize(), encoded.Len())
+	}
+
+	header.Version = Version
+	header.Checksum = ChecksumAlgorithm(99)
+	if err := header.WriteHeader(&bytes.Buffer{}); err == nil {
+		t.Errorf("expected an unknown checksum algorithm to be rejected")
+	}
+	header.Checksum = ChecksumXXHash64
+	encoded.Reset()
+	if err := header.WriteHeader(&encoded); err != nil {
+		t.Fatal(err)
+	}
+	read := Header{}
+	if err := read.ReadHeader(bytes.NewReader(encoded.Bytes())); err != nil || read.Checksum != ChecksumXXHash64 {
+		t.Fatalf("expected to read back xxhash64, got %v (%v)", read.Checksum, err)
+	}
+	corrupted := bytes.Clone(encoded.Bytes())
+	binary.LittleEndian.PutUint32(corrupted[header.DiskSize()-4:], 99)
+	if err := read.ReadHeader(bytes.NewReader(corrupted)); err == nil {
+		t.Errorf("expected an unknown checksum algorithm to be rejected when read")
+	}
+}
+
+func TestChecksumCloneAndServe(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.BigEndian, OffsetSize8)
+	header.Checksum = ChecksumSHA256
+	layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 40, TileSize: 16}}, ChannelSet{{Name: "v", Type: ChannelUint16}}, WithCompression(CompressionZstd))}
+	written := writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint16(coord[0] / 3)}
+	})
+
+	cloned := buffer.NewBuffer(10)
+	clone, err := Clone(buffer.NewBufferFrom(buf.Bytes()), cloned, CloneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.Header.Checksum != ChecksumSHA256 {
+		t.Errorf("expected the clone to keep sha256 checksums, got %v", clone.Header.Checksum)
+	}
+	chunks := NewChunkMap(clone.Layers[0])
+	if err := chunks.Verify(buffer.NewBufferFrom(cloned.Bytes()), clone.Header, clone.Layers[0]); err != nil || chunks.Failures() != 0 {
+		t.Errorf("expected every tile of the clone to verify, got %d failures (%v)", chunks.Failures(), err)
+	}
+
+	server := NewTileServer(buffer.NewBufferFrom(buf.Bytes()), written, 0)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	expected := make([]byte, written.Layers[0].DiskTileSize(2))
+	if err := written.Layers[0].ReadTile(buf, header, 2, expected); err != nil {
+		t.Fatal(err)
+	}
+	data, err := FetchTile(httpServer.Client(), httpServer.URL, 0, written.Layers[0], 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("expected the served tile to match the stored tile")
+	}
+}
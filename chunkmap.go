@@ -0,0 +1,178 @@
+package gopixi
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// The state of one disk tile of a layer in a ChunkMap.
+type ChunkMapTile struct {
+	Tile       int   `json:"tile"`              // The index of the disk tile.
+	Coordinate []int `json:"coordinate"`        // The position of the tile along each dimension, in tiles.
+	Channel    int   `json:"channel,omitempty"` // The channel of the tile, for separated layers.
+	Present    bool  `json:"present"`           // Whether the tile has been written.
+	Bytes      int64 `json:"bytes"`             // The stored (compressed) size of the tile, excluding its checksum.
+	Logical    int64 `json:"logical"`           // The uncompressed size of the tile.
+	// The error reading the tile, such as a checksum mismatch, if the chunk map was verified.
+	Error string `json:"error,omitempty"`
+}
+
+// The ratio of the uncompressed size of the tile to its stored size, or zero if it is absent.
+func (t ChunkMapTile) CompressionRatio() float64 {
+	if t.Bytes == 0 {
+		return 0
+	}
+	return float64(t.Logical) / float64(t.Bytes)
+}
+
+// A map of the tile index of a layer, describing the size, compression, and presence of every tile, for
+// diagnosing layout and sparsity problems in large files. The map can be exported as JSON or rendered as a
+// heatmap image.
+type ChunkMap struct {
+	Layer       string         `json:"layer"`
+	Compression string         `json:"compression"`
+	Tiles       []int          `json:"tiles"`    // The number of tiles along each dimension.
+	Channels    int            `json:"channels"` // The number of channels with tiles of their own: 1 unless separated.
+	Verified    bool           `json:"verified"` // Whether every present tile was read and checked by Verify.
+	Chunks      []ChunkMapTile `json:"chunks"`   // Every disk tile, in order.
+}
+
+// Builds the chunk map of the layer from its metadata alone.
+func NewChunkMap(layer Layer) ChunkMap {
+	dims := layer.Dimensions
+	m := ChunkMap{Layer: layer.Name, Compression: layer.Compression.String(), Channels: 1}
+	for _, dim := range dims {
+		m.Tiles = append(m.Tiles, dim.Tiles())
+	}
+	if layer.Separated {
+		m.Channels = len(layer.Channels)
+	}
+	for tile := range layer.DiskTiles() {
+		coord := TileSelector{Tile: tile % dims.Tiles()}.ToTileCoordinate(dims)
+		chunk := ChunkMapTile{
+			Tile:       tile,
+			Coordinate: coord.Tile,
+			Channel:    tile / dims.Tiles(),
+			Logical:    int64(layer.DiskTileSize(tile)),
+		}
+		if tile < len(layer.TileBytes) && layer.TileBytes[tile] > 0 {
+			chunk.Present, chunk.Bytes = true, layer.TileBytes[tile]
+		}
+		m.Chunks = append(m.Chunks, chunk)
+	}
+	return m
+}
+
+// Reads every present tile of the layer, recording the error of each tile that cannot be read, such as a
+// tile that fails to decode or whose checksum does not match. Returns an error only if the chunk map does
+// not describe the layer.
+func (m *ChunkMap) Verify(r io.ReadSeeker, h Header, layer Layer) error {
+	if len(m.Chunks) != layer.DiskTiles() {
+		return ErrFormat(fmt.Sprintf("chunk map of layer '%s' does not match layer '%s'", m.Layer, layer.Name))
+	}
+	for i, chunk := range m.Chunks {
+		if !chunk.Present {
+			continue
+		}
+		data := make([]byte, chunk.Logical)
+		if err := layer.ReadTile(r, h, chunk.Tile, data); err != nil {
+			m.Chunks[i].Error = err.Error()
+		} else {
+			m.Chunks[i].Error = ""
+		}
+	}
+	m.Verified = true
+	return nil
+}
+
+// The number of tiles that have errors recorded by Verify.
+func (m ChunkMap) Failures() int {
+	failures := 0
+	for _, chunk := range m.Chunks {
+		if chunk.Error != "" {
+			failures++
+		}
+	}
+	return failures
+}
+
+// Encodes the chunk map as JSON.
+func (m ChunkMap) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
+
+// The measure of each tile shaded by ChunkMap.Image.
+type ChunkMetric int
+
+const (
+	ChunkMetricBytes ChunkMetric = iota // The stored size of each tile.
+	ChunkMetricRatio                    // The compression ratio of each tile.
+)
+
+// The colors of the heatmap ramp of ChunkMap.Image, from low to high.
+var chunkRamp = [3]color.NRGBA{{13, 8, 135, 255}, {33, 145, 140, 255}, {253, 231, 37, 255}}
+
+// Renders the chunk map as a heatmap with a block of scale by scale pixels for every tile, shading present
+// tiles by the metric from dark blue (smallest) to yellow (largest). Absent tiles are transparent, and
+// tiles that failed verification are red.
+func (m ChunkMap) Image(metric ChunkMetric, scale int) image.Image {
+	scale = max(scale, 1)
+	width, rows := 1, 1
+	if len(m.Tiles) > 0 {
+		width = m.Tiles[0]
+		if len(m.Chunks) > 0 {
+			rows = len(m.Chunks) / width
+		}
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width*scale, rows*scale))
+
+	value := func(chunk ChunkMapTile) float64 {
+		if metric == ChunkMetricRatio {
+			return chunk.CompressionRatio()
+		}
+		return float64(chunk.Bytes)
+	}
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, chunk := range m.Chunks {
+		if chunk.Present {
+			low, high = math.Min(low, value(chunk)), math.Max(high, value(chunk))
+		}
+	}
+
+	for i, chunk := range m.Chunks {
+		c := color.NRGBA{}
+		switch {
+		case chunk.Error != "":
+			c = color.NRGBA{R: 255, A: 255}
+		case chunk.Present:
+			t := 0.5
+			if high > low {
+				t = (value(chunk) - low) / (high - low)
+			}
+			c = rampColor(t)
+		}
+		x, y := i%width, i/width
+		for dy := range scale {
+			for dx := range scale {
+				img.SetNRGBA(x*scale+dx, y*scale+dy, c)
+			}
+		}
+	}
+	return img
+}
+
+// Interpolates the color of the heatmap ramp at t in [0, 1].
+func rampColor(t float64) color.NRGBA {
+	t = math.Max(0, math.Min(1, t)) * float64(len(chunkRamp)-1)
+	i := min(int(t), len(chunkRamp)-2)
+	f := t - float64(i)
+	lerp := func(a, b uint8) uint8 { return uint8(math.Round(float64(a) + f*(float64(b)-float64(a)))) }
+	a, b := chunkRamp[i], chunkRamp[i+1]
+	return color.NRGBA{R: lerp(a.R, b.R), G: lerp(a.G, b.G), B: lerp(a.B, b.B), A: 255}
+}
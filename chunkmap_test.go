@@ -0,0 +1,70 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image/color"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestChunkMap(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 6, TileSize: 2}, {Name: "y", Size: 4, TileSize: 2}}
+	layer := NewLayer("sparse", dims, ChannelSet{{Name: "a", Type: ChannelUint8}, {Name: "b", Type: ChannelUint16}},
+		WithPlanar(), WithCompression(CompressionFlate))
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{layer}, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0]), uint16(coord[0] * coord[1] * 1000)}
+	})
+	layer = p.Layers[0]
+	// make the map sparse, and corrupt the checksum of another tile
+	layer.TileBytes[4], layer.TileOffsets[4] = 0, 0
+	data := buf.Bytes()
+	data[layer.TileOffsets[7]+layer.TileBytes[7]] ^= 0xff
+
+	m := NewChunkMap(layer)
+	if len(m.Chunks) != 12 || m.Channels != 2 || m.Tiles[0] != 3 || m.Tiles[1] != 2 || m.Verified {
+		t.Fatalf("unexpected chunk map %+v", m)
+	}
+	if chunk := m.Chunks[10]; chunk.Channel != 1 || chunk.Coordinate[0] != 1 || chunk.Coordinate[1] != 1 || chunk.Logical != 8 {
+		t.Errorf("unexpected chunk %+v", chunk)
+	}
+	if m.Chunks[4].Present || !m.Chunks[5].Present || m.Chunks[5].CompressionRatio() <= 0 {
+		t.Errorf("unexpected presence of chunks %+v and %+v", m.Chunks[4], m.Chunks[5])
+	}
+
+	if err := m.Verify(buffer.NewBufferFrom(data), p.Header, layer); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Verified || m.Failures() != 1 || m.Chunks[7].Error == "" {
+		t.Errorf("expected a single checksum failure at tile 7, got %+v", m.Chunks)
+	}
+
+	var out bytes.Buffer
+	if err := m.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded ChunkMap
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Layer != "sparse" || decoded.Compression != CompressionFlate.String() || len(decoded.Chunks) != 12 || decoded.Chunks[7].Error != m.Chunks[7].Error {
+		t.Errorf("unexpected decoded chunk map %+v", decoded)
+	}
+
+	img := m.Image(ChunkMetricBytes, 3)
+	if bounds := img.Bounds(); bounds.Dx() != 9 || bounds.Dy() != 12 {
+		t.Fatalf("unexpected image bounds %v", bounds)
+	}
+	if _, _, _, a := img.At(1*3+1, 1*3+1).RGBA(); a != 0 {
+		t.Error("expected absent tiles to be transparent")
+	}
+	if c := color.NRGBAModel.Convert(img.At(1*3, 2*3+2)).(color.NRGBA); c != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("expected failed tiles to be red, got %v", c)
+	}
+	if c := color.NRGBAModel.Convert(m.Image(ChunkMetricRatio, 1).At(0, 0)).(color.NRGBA); c.A != 255 || c.R == 255 {
+		t.Errorf("expected present tiles to be shaded, got %v", c)
+	}
+}
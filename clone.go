@@ -0,0 +1,242 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The tag under which Clone records the parent dataset a region was extracted from, as given by
+// CloneOptions.Parent.
+const ExtractParentTag string = "pixi.extract.parent"
+
+// The tag under which Clone records the start of the extracted region in the parent dataset, as the
+// comma-separated sample index along each dimension.
+const ExtractOffsetTag string = "pixi.extract.offset"
+
+// Controls which parts of a Pixi file are copied by Clone, and how.
+type CloneOptions struct {
+	Layers      []string    // Names of the layers to copy. If empty, every layer is copied.
+	Region      *Region     // If set, only samples within this region of each copied layer are kept.
+	Recompress  bool        // If true, tiles are re-encoded using Compression instead of the source compression.
+	Compression Compression // The compression to use for copied layers when Recompress is set.
+	Parent      string      // Identifies the source dataset (such as its path or URL) in the provenance of a region.
+	Upgrade     bool        // If true, the copy is written at the current Version rather than that of the source.
+}
+
+// Copies the Pixi file in src into the empty stream dst, returning the metadata of the new file. Layers
+// copied whole without recompression keep their stored tiles verbatim, while others are decoded and written
+// again. A region keeps the axis values of its samples and is recorded in the ExtractParentTag and
+// ExtractOffsetTag tags. Generations of files with tile history are not copied. The copy keeps the version of
+// the source unless the options ask for an upgrade.
+func Clone(src io.ReadSeeker, dst io.WriteSeeker, opts CloneOptions) (*Pixi, error) {
+	srcPixi, err := ReadPixi(src)
+	if err != nil {
+		return nil, err
+	}
+
+	dstPixi := &Pixi{Header: NewHeader(srcPixi.Header.ByteOrder, srcPixi.Header.OffsetSize)}
+	dstPixi.Header.Checksum = srcPixi.Header.Checksum // stored tiles are copied along with their checksums
+	if !opts.Upgrade {
+		dstPixi.Header.Version = srcPixi.Header.Version
+	}
+	_, err = dst.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	err = dstPixi.Header.WriteHeader(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	// retained tile versions are not copied, so the generations referencing them are dropped
+	tags := srcPixi.AllTags()
+	maps.DeleteFunc(tags, func(tag string, value string) bool { return strings.HasPrefix(tag, GenerationTagPrefix) })
+	if opts.Region != nil {
+		delete(tags, ExtractParentTag)
+		if opts.Parent != "" {
+			tags[ExtractParentTag] = opts.Parent
+		}
+		tags[ExtractOffsetTag] = formatExtractOffset(opts.Region.Start)
+	}
+	if len(tags) > 0 {
+		err = dstPixi.AppendTags(dst, tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if attributes := srcPixi.AllAttributes(); len(attributes) > 0 {
+		err = dstPixi.AppendAttributes(dst, attributes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if axes := srcPixi.SharedAxes(); len(axes) > 0 {
+		err = dstPixi.AppendAxes(dst, axes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, srcLayer := range srcPixi.Layers {
+		if len(opts.Layers) > 0 && !slices.Contains(opts.Layers, srcLayer.Name) {
+			continue
+		}
+		if opts.Region == nil && (!opts.Recompress || opts.Compression == srcLayer.Compression) {
+			err = dstPixi.cloneLayerRaw(src, dst, srcPixi.Header, srcLayer)
+		} else {
+			err = dstPixi.cloneLayerDecoded(src, dst, srcPixi.Header, srcLayer, opts)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dstPixi, nil
+}
+
+// Copies the stored tiles of the layer byte-for-byte, including the checksum following each tile.
+func (p *Pixi) cloneLayerRaw(src io.ReadSeeker, dst io.WriteSeeker, srcHeader Header, srcLayer Layer) error {
+	dstLayer := srcLayer
+	dstLayer.Channels = slices.Clone(srcLayer.Channels)
+	dstLayer.TileBytes = make([]int64, srcLayer.DiskTiles())
+	dstLayer.TileOffsets = make([]int64, srcLayer.DiskTiles())
+	dstLayer.StringBytes = slices.Clone(srcLayer.StringBytes)
+	dstLayer.NextLayerStart = 0
+
+	for tileIndex := range srcLayer.DiskTiles() {
+		if srcLayer.TileBytes[tileIndex] == 0 {
+			continue
+		}
+		dstOffset, err := copyRawTile(src, dst, srcHeader, srcLayer.TileOffsets[tileIndex], srcLayer.TileBytes[tileIndex])
+		if err != nil {
+			return err
+		}
+		dstLayer.TileOffsets[tileIndex] = dstOffset
+		dstLayer.TileBytes[tileIndex] = srcLayer.TileBytes[tileIndex]
+	}
+
+	return p.appendLayerHeader(dst, dstLayer)
+}
+
+// Copies the stored tile (and its checksum) at the given offset in src, a file with the given header, to the
+// end of dst, returning its offset in dst.
+func copyRawTile(src io.ReadSeeker, dst io.WriteSeeker, h Header, offset int64, bytes int64) (int64, error) {
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	dstOffset, err := dst.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.CopyN(dst, src, bytes+int64(h.Checksum.Size())); err != nil {
+		return 0, err
+	}
+	return dstOffset, nil
+}
+
+// Decodes the samples of the layer (within the selected region, if any) and encodes them into a new layer.
+func (p *Pixi) cloneLayerDecoded(src io.ReadSeeker, dst io.WriteSeeker, srcHeader Header, srcLayer Layer, opts CloneOptions) error {
+	region := FullRegion(srcLayer.Dimensions)
+	if opts.Region != nil {
+		region = *opts.Region
+		if err := region.Validate(srcLayer.Dimensions); err != nil {
+			return err
+		}
+	}
+
+	regionSize := region.Size()
+	dstDims := make(DimensionSet, len(srcLayer.Dimensions))
+	for i, dim := range srcLayer.Dimensions {
+		dstDims[i] = Dimension{
+			Name:     dim.Name,
+			Size:     regionSize[i],
+			TileSize: min(dim.TileSize, regionSize[i]),
+			Axis:     dim.Axis.offset(region.Start[i], regionSize[i]),
+		}
+	}
+
+	// min and max are recomputed from the copied samples
+	dstChannels := slices.Clone(srcLayer.Channels)
+	for i := range dstChannels {
+		dstChannels[i].Min = nil
+		dstChannels[i].Max = nil
+	}
+
+	compression := srcLayer.Compression
+	if opts.Recompress {
+		compression = opts.Compression
+	}
+	layerOpts := []LayerOption{WithCompression(compression)}
+	if srcLayer.Separated {
+		layerOpts = append(layerOpts, WithPlanar())
+	}
+	if srcLayer.SparseTiles || slices.Contains(srcLayer.TileBytes, 0) { // keep absent tiles absent
+		layerOpts = append(layerOpts, WithSparseTiles())
+	}
+	dstLayer := NewLayer(srcLayer.Name, dstDims, dstChannels, layerOpts...)
+	dstLayer.Attributes = maps.Clone(srcLayer.Attributes)
+	dstLayer.Georeference = srcLayer.Georeference.offset(region.Start)
+
+	srcData := newFilledReadLayer(src, srcHeader, srcLayer, 16)
+	srcCoord := make(SampleCoordinate, len(dstDims))
+	writer := NewTileOrderWriteIterator(dst, p.Header, dstLayer)
+	return p.AppendIterativeLayer(dst, dstLayer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			coord := writer.Coordinate()
+			if !dstDims.ContainsCoordinate(coord) {
+				continue
+			}
+			for i := range coord {
+				srcCoord[i] = coord[i] + region.Start[i]
+			}
+			sample, err := SampleAt(srcData, srcCoord)
+			if err != nil {
+				return err
+			}
+			writer.SetSample(sample)
+		}
+		return nil
+	})
+}
+
+// The provenance of a file extracted from a region of another by Clone.
+type Provenance struct {
+	Parent string           // The parent dataset, or empty if it was not given.
+	Offset SampleCoordinate // The sample index of the start of the region along each dimension of the parent.
+}
+
+// The provenance recorded in the file by Clone, and whether the file was extracted from a region at all.
+func (p *Pixi) Provenance() (Provenance, bool) {
+	tags := p.AllTags()
+	value, ok := tags[ExtractOffsetTag]
+	if !ok {
+		return Provenance{}, false
+	}
+	offset := SampleCoordinate{}
+	for field := range strings.SplitSeq(value, ",") {
+		index, err := strconv.Atoi(field)
+		if err != nil {
+			return Provenance{}, false
+		}
+		offset = append(offset, index)
+	}
+	return Provenance{Parent: tags[ExtractParentTag], Offset: offset}, true
+}
+
+func formatExtractOffset(start SampleCoordinate) string {
+	fields := make([]string, len(start))
+	for i, index := range start {
+		fields[i] = fmt.Sprint(index)
+	}
+	return strings.Join(fields, ",")
+}
+
+// Copies the Pixi file in src into the empty stream dst, leaving behind any dead space from tiles and
+// headers that are no longer referenced. Tiles are copied without being decoded.
+func Compact(src io.ReadSeeker, dst io.WriteSeeker) (*Pixi, error) {
+	return Clone(src, dst, CloneOptions{})
+}
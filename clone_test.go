@@ -0,0 +1,261 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func newCloneTestSource(t *testing.T) []byte {
+	t.Helper()
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{
+		NewLayer("first",
+			DimensionSet{{Name: "x", Size: 10, TileSize: 4}, {Name: "y", Size: 6, TileSize: 3}},
+			ChannelSet{{Name: "a", Type: ChannelUint16}, {Name: "b", Type: ChannelFloat32}},
+			WithCompression(CompressionFlate)),
+		NewLayer("second",
+			DimensionSet{{Name: "x", Size: 5, TileSize: 5}},
+			ChannelSet{{Name: "c", Type: ChannelInt32}},
+			WithPlanar()),
+	}
+	writeTestPixi(t, buf, NewHeader(binary.BigEndian, OffsetSize8), map[string]string{"origin": "test"}, layers, func(layer int, coord SampleCoordinate) Sample {
+		if layer == 0 {
+			return Sample{uint16(coord[0] + coord[1]*10), float32(coord[1])}
+		}
+		return Sample{int32(-coord[0])}
+	})
+	return buf.Bytes()
+}
+
+func TestCloneRawCopy(t *testing.T) {
+	srcBytes := newCloneTestSource(t)
+	src := buffer.NewBufferFrom(srcBytes)
+	srcPixi, err := ReadPixi(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := buffer.NewBuffer(10)
+	dstPixi, err := Clone(buffer.NewBufferFrom(srcBytes), dst, CloneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readPixi, err := ReadPixi(buffer.NewBufferFrom(dst.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readPixi.Layers) != 2 || len(dstPixi.Layers) != 2 {
+		t.Fatalf("expected 2 layers in clone, got %d", len(readPixi.Layers))
+	}
+	if readPixi.AllTags()["origin"] != "test" {
+		t.Errorf("expected tags to be cloned, got %v", readPixi.AllTags())
+	}
+
+	for layerIndex, srcLayer := range srcPixi.Layers {
+		dstLayer := readPixi.Layers[layerIndex]
+		for tileIndex := range srcLayer.DiskTiles() {
+			srcTile := srcBytes[srcLayer.TileOffsets[tileIndex] : srcLayer.TileOffsets[tileIndex]+srcLayer.TileBytes[tileIndex]]
+			dstTile := dst.Bytes()[dstLayer.TileOffsets[tileIndex] : dstLayer.TileOffsets[tileIndex]+dstLayer.TileBytes[tileIndex]]
+			if !bytes.Equal(srcTile, dstTile) {
+				t.Errorf("expected raw tile %d of layer %d to be copied verbatim", tileIndex, layerIndex)
+			}
+		}
+
+		dstData := NewFifoCacheReadLayer(buffer.NewBufferFrom(dst.Bytes()), readPixi.Header, dstLayer, 4)
+		srcData := NewFifoCacheReadLayer(buffer.NewBufferFrom(srcBytes), srcPixi.Header, srcLayer, 4)
+		for coord := range srcLayer.Dimensions.SampleCoordinates() {
+			want, err := SampleAt(srcData, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := SampleAt(dstData, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range want {
+				if want[i] != got[i] {
+					t.Errorf("layer %d sample %v: expected %v, got %v", layerIndex, coord, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestCloneLayerFilterAndRegion(t *testing.T) {
+	srcBytes := newCloneTestSource(t)
+
+	dst := buffer.NewBuffer(10)
+	region := Region{Start: SampleCoordinate{3, 2}, End: SampleCoordinate{9, 5}}
+	_, err := Clone(buffer.NewBufferFrom(srcBytes), dst, CloneOptions{
+		Layers:      []string{"first"},
+		Region:      &region,
+		Recompress:  true,
+		Compression: CompressionRle8,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readPixi, err := ReadPixi(buffer.NewBufferFrom(dst.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readPixi.Layers) != 1 || readPixi.Layers[0].Name != "first" {
+		t.Fatalf("expected only the first layer to be cloned, got %v", readPixi.Layers)
+	}
+	layer := readPixi.Layers[0]
+	if layer.Compression != CompressionRle8 {
+		t.Errorf("expected recompressed layer, got %s", layer.Compression)
+	}
+	if layer.Dimensions[0].Size != 6 || layer.Dimensions[1].Size != 3 {
+		t.Errorf("expected region sized dimensions, got %v", layer.Dimensions)
+	}
+
+	data := NewFifoCacheReadLayer(buffer.NewBufferFrom(dst.Bytes()), readPixi.Header, layer, 4)
+	for coord := range layer.Dimensions.SampleCoordinates() {
+		sample, err := SampleAt(data, coord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := uint16(coord[0] + 3 + (coord[1]+2)*10)
+		if sample[0] != want {
+			t.Errorf("sample %v: expected %v, got %v", coord, want, sample[0])
+		}
+	}
+
+	badRegion := Region{Start: SampleCoordinate{0, 0}, End: SampleCoordinate{11, 6}}
+	_, err = Clone(buffer.NewBufferFrom(srcBytes), buffer.NewBuffer(10), CloneOptions{Layers: []string{"first"}, Region: &badRegion})
+	if err == nil {
+		t.Error("expected error cloning region outside layer bounds")
+	}
+}
+
+func TestCloneKeepsVersion(t *testing.T) {
+	header := NewHeader(binary.LittleEndian, OffsetSize4)
+	header.Version = 1
+	src := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("old", DimensionSet{{Name: "x", Size: 6, TileSize: 3}}, ChannelSet{{Name: "a", Type: ChannelUint8}})}
+	writeTestPixi(t, src, header, map[string]string{"origin": "test"}, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+
+	for _, opts := range []CloneOptions{{}, {Recompress: true, Compression: CompressionFlate}, {Upgrade: true}} {
+		dst := buffer.NewBuffer(10)
+		if _, err := Clone(buffer.NewBufferFrom(src.Bytes()), dst, opts); err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadPixi(buffer.NewBufferFrom(dst.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 1
+		if opts.Upgrade {
+			want = Version
+		}
+		if read.Header.Version != want {
+			t.Errorf("%+v: expected version %d, got %d", opts, want, read.Header.Version)
+		}
+	}
+}
+
+func TestCloneSparseRegion(t *testing.T) {
+	src := buffer.NewBuffer(10)
+	layer := NewLayer("sparse", DimensionSet{{Name: "x", Size: 12, TileSize: 4}},
+		ChannelSet{{Name: "v", Type: ChannelFloat32, FillValue: float32(-1)}}, WithSparseTiles())
+	writeTestPixi(t, src, NewHeader(binary.LittleEndian, OffsetSize8), nil, []Layer{layer}, func(layer int, coord SampleCoordinate) Sample {
+		if coord[0] >= 4 && coord[0] < 8 {
+			return Sample{float32(-1)}
+		}
+		return Sample{float32(coord[0])}
+	})
+
+	dst := buffer.NewBuffer(10)
+	region := Region{Start: SampleCoordinate{4}, End: SampleCoordinate{12}}
+	cloned, err := Clone(buffer.NewBufferFrom(src.Bytes()), dst, CloneOptions{Region: &region})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cloned.Layers[0].SparseTiles || cloned.Layers[0].TileBytes[0] != 0 {
+		t.Errorf("expected the fill tile to stay absent, got tile bytes %v", cloned.Layers[0].TileBytes)
+	}
+
+	samples, err := cloned.Layers[0].ReadRegion(buffer.NewBufferFrom(dst.Bytes()), cloned.Header, FullRegion(cloned.Layers[0].Dimensions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, sample := range samples {
+		want := float32(-1)
+		if i >= 4 {
+			want = float32(i + 4)
+		}
+		if sample[0] != want {
+			t.Errorf("sample %d: expected %v, got %v", i, want, sample[0])
+		}
+	}
+}
+
+func TestCloneRegionProvenance(t *testing.T) {
+	src, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dims := DimensionSet{
+		{Name: "lon", Size: 8, TileSize: 4, Axis: &Axis{Type: ChannelFloat64, Minimum: -10.0, Step: 0.5, Unit: "degrees"}},
+		{Name: "time", Size: 4, TileSize: 4, Axis: &Axis{Type: ChannelInt64, Minimum: int64(1000), Step: int64(60)}},
+	}
+	layer := NewLayer("values", dims, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	writer := NewTileOrderWriteIterator(src.Stream(), src.Header, layer)
+	err = src.AppendIterativeLayer(src.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{uint8(writer.Coordinate()[0])})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := src.Provenance(); ok {
+		t.Error("expected no provenance in the source")
+	}
+
+	dst := buffer.NewBuffer(10)
+	region := Region{Start: SampleCoordinate{3, 1}, End: SampleCoordinate{7, 3}}
+	cloned, err := Clone(buffer.NewBufferFrom(src.Bytes()), dst, CloneOptions{Region: &region, Parent: "s3://bucket/source.pixi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, dim := range cloned.Layers[0].Dimensions {
+		for index := range dim.Size {
+			if got, want := dim.Axis.StepValue(index), dims[i].Axis.StepValue(index+region.Start[i]); got != want {
+				t.Errorf("dimension %s index %d: expected axis value %v, got %v", dim.Name, index, want, got)
+			}
+		}
+	}
+	if cloned.Layers[0].Dimensions[0].Axis.Unit != "degrees" {
+		t.Errorf("expected axis unit to be kept, got %q", cloned.Layers[0].Dimensions[0].Axis.Unit)
+	}
+
+	read, err := ReadPixi(buffer.NewBufferFrom(dst.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	provenance, ok := read.Provenance()
+	if !ok || provenance.Parent != "s3://bucket/source.pixi" || !slices.Equal(provenance.Offset, region.Start) {
+		t.Errorf("unexpected provenance %v (%v)", provenance, ok)
+	}
+
+	// extracting again replaces the provenance with that of the new parent
+	again := Region{Start: SampleCoordinate{1, 0}, End: SampleCoordinate{2, 1}}
+	nested, err := Clone(buffer.NewBufferFrom(dst.Bytes()), buffer.NewBuffer(10), CloneOptions{Region: &again})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provenance, ok := nested.Provenance(); !ok || provenance.Parent != "" || !slices.Equal(provenance.Offset, again.Start) {
+		t.Errorf("unexpected provenance after second extraction %v (%v)", provenance, ok)
+	}
+}
@@ -11,7 +11,9 @@ import (
 func main() {
 	srcFileName := flag.String("src", "", "path to the pixi file to open")
 	dstFileName := flag.String("dst", "", "name of the output pixi file")
-	method := flag.String("method", "flate", "compression method to use (flate, lzw_lsb, lzw_msb, none)")
+	method := flag.String("method", "flate", "compression method to use (flate, lzw_lsb, lzw_msb, rle8, zstd, lz4, none)")
+	level := flag.Int("level", 0, "compression level for methods that support levels (zstd: 1-22, lz4: 1-9), or 0 for the default")
+	checksumName := flag.String("checksum", "", "checksum algorithm of the output tiles (crc32, crc32c, xxhash64, sha256), or empty to keep that of the source")
 	flag.Parse()
 
 	// determine compression method
@@ -25,13 +27,27 @@ func main() {
 		compression = gopixi.CompressionLzwMsb
 	case "rle8":
 		compression = gopixi.CompressionRle8
+	case "zstd":
+		compression = gopixi.CompressionZstd
+	case "lz4":
+		compression = gopixi.CompressionLz4
 	case "none":
 		compression = gopixi.CompressionNone
 	default:
-		fmt.Println("Invalid compression method. Must be one of: flate, lzw_lsb, lzw_msb, none")
+		fmt.Println("Invalid compression method. Must be one of: flate, lzw_lsb, lzw_msb, rle8, zstd, lz4, none")
 		return
 	}
 
+	var checksum gopixi.ChecksumAlgorithm
+	if *checksumName != "" {
+		var err error
+		checksum, err = gopixi.ParseChecksumAlgorithm(*checksumName)
+		if err != nil {
+			fmt.Println("Invalid checksum algorithm. Must be one of: crc32, crc32c, xxhash64, sha256")
+			return
+		}
+	}
+
 	// open source and destination files
 	srcStream, err := gopixi.OpenFileOrHttp(*srcFileName)
 	if err != nil {
@@ -56,6 +72,15 @@ func main() {
 
 	// create destination Pixi file with compressed layers
 	dstPixi := gopixi.NewHeader(srcPixi.Header.ByteOrder, srcPixi.Header.OffsetSize)
+	dstPixi.Checksum = srcPixi.Header.Checksum
+	if *checksumName != "" {
+		dstPixi.Checksum = checksum
+	}
+	err = dstPixi.WriteHeader(dstFile)
+	if err != nil {
+		fmt.Println("Failed to write Pixi header to destination Pixi file.")
+		return
+	}
 	summary := &gopixi.Pixi{
 		Header: dstPixi,
 	}
@@ -65,13 +90,29 @@ func main() {
 		fmt.Println("Failed to write tags to destination Pixi file.")
 		return
 	}
+	if attributes := srcPixi.AllAttributes(); len(attributes) > 0 {
+		err = summary.AppendAttributes(dstFile, attributes)
+		if err != nil {
+			fmt.Println("Failed to write attributes to destination Pixi file.")
+			return
+		}
+	}
+	if axes := srcPixi.SharedAxes(); len(axes) > 0 {
+		err = summary.AppendAxes(dstFile, axes)
+		if err != nil {
+			fmt.Println("Failed to write shared axes to destination Pixi file.")
+			return
+		}
+	}
 
 	for _, srcLayer := range srcPixi.Layers {
-		opts := []gopixi.LayerOption{gopixi.WithCompression(compression)}
+		opts := []gopixi.LayerOption{gopixi.WithCompression(compression), gopixi.WithCompressionLevel(*level)}
 		if srcLayer.Separated {
 			opts = append(opts, gopixi.WithPlanar())
 		}
 		dstLayer := gopixi.NewLayer(srcLayer.Name, srcLayer.Dimensions, srcLayer.Channels, opts...)
+		dstLayer.Attributes = srcLayer.Attributes
+		dstLayer.Georeference = srcLayer.Georeference
 		srcData := gopixi.NewFifoCacheReadLayer(srcStream, srcPixi.Header, srcLayer, 4)
 
 		iterator := gopixi.NewTileOrderWriteIterator(dstFile, dstPixi, dstLayer)
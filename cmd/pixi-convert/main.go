@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/binary"
 	"flag"
 	"fmt"
@@ -23,17 +24,17 @@ import (
 
 func main() {
 	toPixiFlags := flag.NewFlagSet("toPixi", flag.ExitOnError)
-	toSrcFile := toPixiFlags.String("src", "", "file to convert to Pixi")
+	toSrcFile := toPixiFlags.String("src", "", "image, NetCDF (.nc) file, or Zarr v3 store (.zarr directory) to convert to Pixi")
 	toDstFile := toPixiFlags.String("dst", "", "name of the resulting Pixi file")
 	toTileSize := toPixiFlags.Int("tileSize", 0, "the size of tiles to generate in the Pixi file, if zero (default) will be the same size as the image")
-	toComp := toPixiFlags.Int("compression", 0, "compression to be used for data in Pixi (none, flate, lzw-lsb, lzw-msb, rle8) represented as 0, 1, 2, 3, 4 respectively")
+	toComp := toPixiFlags.Int("compression", 0, "compression to be used for data in Pixi (none, flate, lzw-lsb, lzw-msb, rle8, zstd, lz4) represented as 0, 1, 2, 3, 4, 5, 6 respectively")
 	toOrder := toPixiFlags.String("endian", "native", "the endianness byte order (big, little, native) to use in the Pixi file")
 	toOffsetSize := toPixiFlags.Int("offsetSize", 4, "the size in bytes of offsets in the Pixi file (4 or 8)")
 
 	fromPixiFlags := flag.NewFlagSet("fromPixi", flag.ExitOnError)
 	fromSrcFile := fromPixiFlags.String("src", "", "Pixi file to convert")
-	fromDstFile := fromPixiFlags.String("dst", "", "name of the file resulting from Pixi conversion")
-	fromModel := fromPixiFlags.String("model", "image", "the target model to convert the Pixi file to (image)")
+	fromDstFile := fromPixiFlags.String("dst", "", "name of the file resulting from Pixi conversion, all layers being written for NetCDF (.nc) files and Zarr v3 (.zarr) stores")
+	fromModel := fromPixiFlags.String("model", "image", "the target model to convert the Pixi file to (image, or geotiff and cog for GeoTIFF files of the layer's values)")
 	fromLayer := fromPixiFlags.Int("layer", 0, "the index of the layer to convert from the Pixi file (default 0)")
 
 	switch os.Args[1] {
@@ -67,28 +68,6 @@ func main() {
 }
 
 func otherToPixi(srcFile string, dstFile string, tileSize int, comp int, endianness string, offsetSize int) error {
-	var srcStream io.Reader
-	if strings.HasPrefix(srcFile, "http://") || strings.HasPrefix(srcFile, "https://") {
-		resp, err := http.Get(srcFile)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close() // Close the response body when done
-
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return fmt.Errorf("failed to fetch file, status code: %d", resp.StatusCode)
-		}
-		srcStream = resp.Body
-	} else {
-		rdFile, err := os.Open(srcFile)
-		if err != nil {
-			return err
-		}
-		defer rdFile.Close()
-
-		srcStream = rdFile
-	}
-
 	compression := gopixi.CompressionNone
 	switch comp {
 	case 0:
@@ -101,6 +80,10 @@ func otherToPixi(srcFile string, dstFile string, tileSize int, comp int, endiann
 		compression = gopixi.CompressionLzwMsb
 	case 4:
 		compression = gopixi.CompressionRle8
+	case 5:
+		compression = gopixi.CompressionZstd
+	case 6:
+		compression = gopixi.CompressionLz4
 	}
 
 	if offsetSize != 4 && offsetSize != 8 {
@@ -119,6 +102,33 @@ func otherToPixi(srcFile string, dstFile string, tileSize int, comp int, endiann
 		return fmt.Errorf("invalid endianness: %s; must be 'big' or 'little'", endianness)
 	}
 
+	// Zarr stores are directories of keys, rather than a single stream
+	if strings.ToLower(path.Ext(srcFile)) == ".zarr" {
+		return zarrToPixi(srcFile, dstFile, order, offsetSize, compression)
+	}
+
+	var srcStream io.Reader
+	if strings.HasPrefix(srcFile, "http://") || strings.HasPrefix(srcFile, "https://") {
+		resp, err := http.Get(srcFile)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // Close the response body when done
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("failed to fetch file, status code: %d", resp.StatusCode)
+		}
+		srcStream = resp.Body
+	} else {
+		rdFile, err := os.Open(srcFile)
+		if err != nil {
+			return err
+		}
+		defer rdFile.Close()
+
+		srcStream = rdFile
+	}
+
 	options := gopixi.FromImageOptions{
 		Compression: compression,
 		OffsetSize:  gopixi.OffsetSize(offsetSize),
@@ -128,6 +138,10 @@ func otherToPixi(srcFile string, dstFile string, tileSize int, comp int, endiann
 		Tags:        map[string]string{},
 	}
 
+	if strings.ToLower(path.Ext(srcFile)) == ".nc" {
+		return netCDFToPixi(srcStream, dstFile, order, offsetSize, compression)
+	}
+
 	var img image.Image
 	var err error
 	switch strings.ToLower(path.Ext(srcFile)) {
@@ -179,6 +193,48 @@ func otherToPixi(srcFile string, dstFile string, tileSize int, comp int, endiann
 	return summary.AppendImage(pixiFile, img, options)
 }
 
+// NetCDF variables are read at arbitrary offsets, so the source is read into memory unless it is already a file.
+func netCDFToPixi(srcStream io.Reader, dstFile string, order binary.ByteOrder, offsetSize int, compression gopixi.Compression) error {
+	source, ok := srcStream.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(srcStream)
+		if err != nil {
+			return err
+		}
+		source = bytes.NewReader(data)
+	}
+
+	pixiFile, err := os.Create(dstFile)
+	if err != nil {
+		return err
+	}
+	defer pixiFile.Close()
+
+	summary := &gopixi.Pixi{
+		Header: gopixi.NewHeader(order, gopixi.OffsetSize(offsetSize)),
+	}
+	if err := summary.Header.WriteHeader(pixiFile); err != nil {
+		return err
+	}
+	return summary.AppendNetCDF(pixiFile, source, gopixi.WithCompression(compression))
+}
+
+func zarrToPixi(srcDir string, dstFile string, order binary.ByteOrder, offsetSize int, compression gopixi.Compression) error {
+	pixiFile, err := os.Create(dstFile)
+	if err != nil {
+		return err
+	}
+	defer pixiFile.Close()
+
+	summary := &gopixi.Pixi{
+		Header: gopixi.NewHeader(order, gopixi.OffsetSize(offsetSize)),
+	}
+	if err := summary.Header.WriteHeader(pixiFile); err != nil {
+		return err
+	}
+	return summary.AppendZarr(pixiFile, os.DirFS(srcDir), gopixi.WithCompression(compression))
+}
+
 func pixiToOther(srcFile string, dstFile string, srcModel string, srcLayer int) error {
 	pixiStream, err := gopixi.OpenFileOrHttp(srcFile)
 	if err != nil {
@@ -187,6 +243,16 @@ func pixiToOther(srcFile string, dstFile string, srcModel string, srcLayer int)
 	}
 	defer pixiStream.Close()
 
+	// Zarr stores hold every layer of the Pixi file, as a directory of keys rather than a single file
+	if strings.ToLower(path.Ext(dstFile)) == ".zarr" {
+		pixiSum, err := gopixi.ReadPixi(pixiStream)
+		if err != nil {
+			fmt.Printf("failed to read source Pixi file: %v\n", err)
+			return err
+		}
+		return pixiSum.WriteZarr(pixiStream, gopixi.ZarrDirectory(dstFile))
+	}
+
 	imgFile, err := os.Create(dstFile)
 	if err != nil {
 		fmt.Printf("failed to create destination file: %v\n", err)
@@ -200,10 +266,23 @@ func pixiToOther(srcFile string, dstFile string, srcModel string, srcLayer int)
 		return err
 	}
 
+	// NetCDF files hold every layer of the Pixi file, rather than a single one as an image
+	if strings.ToLower(path.Ext(dstFile)) == ".nc" {
+		return pixiSum.WriteNetCDF(pixiStream, imgFile)
+	}
+
 	if srcLayer < 0 || srcLayer >= len(pixiSum.Layers) {
 		return fmt.Errorf("invalid layer index: %d", srcLayer)
 	}
 
+	// GeoTIFF files hold the values of the layer rather than an image of them, for GIS tools
+	switch srcModel {
+	case "geotiff":
+		return pixiSum.WriteGeoTIFF(pixiStream, imgFile, srcLayer, gopixi.GeoTIFFOptions{})
+	case "cog":
+		return pixiSum.WriteGeoTIFF(pixiStream, imgFile, srcLayer, gopixi.GeoTIFFOptions{COG: true})
+	}
+
 	img, err := gopixi.LayerAsImage(pixiStream, pixiSum, pixiSum.Layers[srcLayer], srcModel)
 	if err != nil {
 		return err
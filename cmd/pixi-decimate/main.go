@@ -131,11 +131,21 @@ func main() {
 			newSize := max(int(math.Ceil(float64(dim.Size)*factor)), 1)
 			// Keep tile size proportional but ensure it's at least 1 and not larger than the new size
 			newTileSize := min(max(int(math.Ceil(float64(dim.TileSize)*factor)), 1), newSize)
+			axis := dim.Axis
+			if axis != nil && axis.Coordinates != nil {
+				// explicit coordinates no longer match the decimated size
+				axis = nil
+			} else if axis != nil && axis.Ref != "" {
+				// shared axes are not copied, so the axis is written in full
+				inline := *axis
+				inline.Ref = ""
+				axis = &inline
+			}
 			newDims[i] = gopixi.Dimension{
 				Name:     dim.Name,
 				Size:     newSize,
 				TileSize: newTileSize,
-				Axis:     dim.Axis,
+				Axis:     axis,
 			}
 		}
 
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/gracefulearth/gopixi"
+)
+
+func main() {
+	dir := flag.String("dir", "fixtures", "directory to write the conformance fixtures to")
+	flag.Parse()
+
+	err := gopixi.WriteConformanceSuite(*dir)
+	if err != nil {
+		fmt.Println("Failed to write conformance fixtures:", err)
+		return
+	}
+	fmt.Printf("Wrote %d conformance fixtures to %s\n", len(gopixi.ConformanceFixtures()), *dir)
+}
@@ -3,12 +3,21 @@ package main
 import (
 	"flag"
 	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
 
 	"github.com/gracefulearth/gopixi"
 )
 
 func main() {
 	pixiPath := flag.String("path", "", "path to the pixi file to open, e.g. /path/to/file.pixi or http://example.com/file.pixi")
+	chunkMapPath := flag.String("chunkmap", "", "if given, writes the chunk map of the layer to this path, as JSON if it ends in .json and as a PNG heatmap otherwise")
+	chunkLayer := flag.Int("layer", 0, "index of the layer whose chunk map is written")
+	chunkVerify := flag.Bool("verify", false, "read every tile of the layer when writing its chunk map, marking tiles that fail their checksums")
+	chunkRatio := flag.Bool("ratio", false, "shade the chunk map heatmap by compression ratio rather than stored size")
+	lint := flag.Bool("lint", false, "report anti-patterns found in the file, exiting with a non-zero status if there are any")
 	flag.Parse()
 
 	if *pixiPath == "" {
@@ -29,21 +38,45 @@ func main() {
 	fmt.Printf("\tVersion: %d\n", summary.Header.Version)
 	fmt.Printf("\tOffset size: %d\n", summary.Header.OffsetSize)
 	fmt.Printf("\tByte order: %s\n", summary.Header.ByteOrder)
+	fmt.Printf("\tChecksum: %s\n", summary.Header.Checksum)
 	fmt.Printf("Tag Sections: %d\n", len(summary.Tags))
 	for sectionInd, section := range summary.Tags {
 		fmt.Printf("\tSection %d\n", sectionInd)
 		for k, v := range section.Tags {
 			fmt.Printf("\t\t%s: %s\n", k, v)
 		}
+		for k, v := range section.Attributes {
+			fmt.Printf("\t\t%s = %v\n", k, v)
+		}
+		for id, axis := range section.Axes {
+			if axis.Coordinates != nil {
+				fmt.Printf("\t\tAxis %s: %s [%d coordinates; %s]\n", id, axis.Type, len(axis.Coordinates), axis.Unit)
+			} else {
+				fmt.Printf("\t\tAxis %s: %s [%v; %v; %s]\n", id, axis.Type, axis.Minimum, axis.Step, axis.Unit)
+			}
+		}
 	}
 	fmt.Printf("Layers: %d\n", len(summary.Layers))
 	for layerInd, layer := range summary.Layers {
 		fmt.Printf("\tLayer %d: %s\n", layerInd, layer.Name)
 		fmt.Printf("\t\tSeparated: %v\n", layer.Separated)
 		fmt.Printf("\t\tCompression: %s\n", layer.Compression)
+		if len(layer.Attributes) > 0 {
+			fmt.Printf("\t\tAttributes: %d\n", len(layer.Attributes))
+			for k, v := range layer.Attributes {
+				fmt.Printf("\t\t\t%s = %v\n", k, v)
+			}
+		}
+		if crs, transform, ok := layer.CRS(); ok {
+			fmt.Printf("\t\tCRS: %s\n", crs)
+			fmt.Printf("\t\tGeotransform: %v\n", transform)
+		}
 		fmt.Printf("\t\tDimensions: %d\n", len(layer.Dimensions))
 		for dimInd, dim := range layer.Dimensions {
 			fmt.Printf("\t\t\tDim %d (%s): %d / %d (%d tiles)\n", dimInd, dim.Name, dim.Size, dim.TileSize, dim.Tiles())
+			if dim.Axis != nil && dim.Axis.Ref != "" {
+				fmt.Printf("\t\t\t\tShared axis: %s\n", dim.Axis.Ref)
+			}
 		}
 		fmt.Printf("\t\tChannels: %d\n", len(layer.Channels))
 		for channelInd, channel := range layer.Channels {
@@ -58,11 +91,68 @@ func main() {
 			} else {
 				fmt.Printf("\t\t\tChannel %d (%s) : %s\n", channelInd, channel.Name, channel.Type)
 			}
+			if stats, ok := channel.Stats(); ok {
+				fmt.Printf("\t\t\t\tMean: %v, standard deviation: %v, valid values: %d\n", stats.Mean, stats.StdDev, stats.ValidCount)
+			}
+			if histogram := channel.Histogram; histogram != nil {
+				lo, _ := histogram.Percentile(2)
+				hi, _ := histogram.Percentile(98)
+				fmt.Printf("\t\t\t\tHistogram: %d bins from %v to %v (2nd percentile %v, 98th percentile %v)\n", len(histogram.Counts), histogram.Min, histogram.Max, lo, hi)
+			}
 		}
+		bytes := layer.Bytes(summary.Header)
+		fmt.Printf("\t\tBytes: %d (header %d, index %d, stored %d, logical %d, %d/%d tiles written)\n",
+			bytes.Total(), bytes.Header, bytes.Index, bytes.Stored, bytes.Logical, bytes.Written, bytes.Tiles)
 	}
 
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
+
+	if *chunkMapPath != "" {
+		if err := writeChunkMap(pixiStream, summary, *chunkLayer, *chunkMapPath, *chunkVerify, *chunkRatio); err != nil {
+			fmt.Println("Failed to write chunk map:", err)
+			return
+		}
+	}
+
+	if *lint {
+		findings := gopixi.Lint(summary)
+		fmt.Printf("Lint findings: %d\n", len(findings))
+		for _, finding := range findings {
+			fmt.Printf("\t%s\n", finding)
+		}
+		if len(findings) > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+func writeChunkMap(r io.ReadSeeker, summary *gopixi.Pixi, layerIndex int, path string, verify bool, ratio bool) error {
+	if layerIndex < 0 || layerIndex >= len(summary.Layers) {
+		return fmt.Errorf("layer index %d out of range, file has %d layers", layerIndex, len(summary.Layers))
+	}
+	layer := summary.Layers[layerIndex]
+	chunks := gopixi.NewChunkMap(layer)
+	if verify {
+		if err := chunks.Verify(r, summary.Header, layer); err != nil {
+			return err
+		}
+		fmt.Printf("Verified %d tiles of layer %s: %d failed\n", len(chunks.Chunks), layer.Name, chunks.Failures())
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if filepath.Ext(path) == ".json" {
+		return chunks.WriteJSON(out)
+	}
+	metric := gopixi.ChunkMetricBytes
+	if ratio {
+		metric = gopixi.ChunkMetricRatio
+	}
+	return png.Encode(out, chunks.Image(metric, 8))
 }
@@ -15,7 +15,7 @@ import (
 func main() {
 	dstFileName := flag.String("dst", "", "name of the output pixi file")
 	separatedArg := flag.Bool("sep", false, "whether to separate channels of layers in the output file")
-	compressionArg := flag.String("comp", "none", "compression type for output file (none, flate, lzw-lsb, lzw-msb)")
+	compressionArg := flag.String("comp", "none", "compression type for output file (none, flate, lzw-lsb, lzw-msb, rle8, zstd, lz4)")
 	flag.Parse()
 
 	if len(flag.Args()) == 0 {
@@ -40,6 +40,10 @@ func main() {
 		compression = gopixi.CompressionLzwMsb
 	case "rle8":
 		compression = gopixi.CompressionRle8
+	case "zstd":
+		compression = gopixi.CompressionZstd
+	case "lz4":
+		compression = gopixi.CompressionLz4
 	default:
 		fmt.Printf("Unsupported compression type: %s\n", *compressionArg)
 		return
@@ -86,6 +86,20 @@ func main() {
 		fmt.Println("Failed to write tags to destination Pixi file.")
 		return
 	}
+	if attributes := srcPixi.AllAttributes(); len(attributes) > 0 {
+		err = dstSummary.AppendAttributes(dstFile, attributes)
+		if err != nil {
+			fmt.Println("Failed to write attributes to destination Pixi file.")
+			return
+		}
+	}
+	if axes := srcPixi.SharedAxes(); len(axes) > 0 {
+		err = dstSummary.AppendAxes(dstFile, axes)
+		if err != nil {
+			fmt.Println("Failed to write shared axes to destination Pixi file.")
+			return
+		}
+	}
 
 	dstDims := make(gopixi.DimensionSet, len(srcLayer.Dimensions))
 	for i, dim := range srcLayer.Dimensions {
@@ -101,6 +115,8 @@ func main() {
 		opts = append(opts, gopixi.WithPlanar())
 	}
 	dstLayer := gopixi.NewLayer(srcLayer.Name, dstDims, srcLayer.Channels, opts...)
+	dstLayer.Attributes = srcLayer.Attributes
+	dstLayer.Georeference = srcLayer.Georeference
 
 	srcData := gopixi.NewFifoCacheReadLayer(srcStream, srcPixi.Header, srcLayer, 4)
 
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"io/fs"
 	"log/slog"
 	"net/http"
+	"os"
 	"path"
 	"path/filepath"
 	"strconv"
+	"sync"
+
+	"github.com/gracefulearth/gopixi"
 )
 
 // This is an example application showing that it is possible to serve Pixi files and easily read them using the
-// Pixi library. It serves files from a specified folder and allows you to access them via HTTP.
+// Pixi library. It serves files from a specified folder and allows you to access them via HTTP, either whole
+// (under /pixi/), tile by tile with compression negotiation (under /tiles/, see gopixi.TileServer), or as JSON
+// summaries of regions of their layers (under /stats/, see gopixi.StatsServer).
 
 func main() {
 	port := flag.Int("port", 8080, "port to serve Pixi files on")
@@ -24,6 +32,8 @@ func main() {
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/pixi/", handlePixi(*folder))
+	mux.HandleFunc("/tiles/", handleTiles(*folder))
+	mux.HandleFunc("/stats/", handleStats(*folder))
 
 	slog.Info("Serving pixi files", "folder", *folder, "port", *port)
 	err := http.ListenAndServe(":"+strconv.Itoa(*port), mux)
@@ -54,3 +64,71 @@ func handlePixi(folder string) func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, filepath.Join(".", folder, filename))
 	}
 }
+
+// Opens the named file of the folder for serving, returning the stream it is read from and its metadata.
+func openServed(folder string, filename string) (*os.File, *gopixi.Pixi, error) {
+	file, err := os.Open(filepath.Join(".", folder, filename))
+	if err != nil {
+		return nil, nil, err
+	}
+	summary, err := gopixi.ReadPixi(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, summary, nil
+}
+
+func handleTiles(folder string) func(w http.ResponseWriter, r *http.Request) {
+	var lock sync.Mutex
+	servers := map[string]*gopixi.TileServer{}
+	return func(w http.ResponseWriter, r *http.Request) {
+		filename := path.Base(r.URL.Path)
+		lock.Lock()
+		server, ok := servers[filename]
+		if !ok {
+			file, summary, err := openServed(folder, filename)
+			if errors.Is(err, fs.ErrNotExist) {
+				lock.Unlock()
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				lock.Unlock()
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			server = gopixi.NewTileServer(file, summary, 256)
+			servers[filename] = server
+		}
+		lock.Unlock()
+		server.ServeHTTP(w, r)
+	}
+}
+
+func handleStats(folder string) func(w http.ResponseWriter, r *http.Request) {
+	var lock sync.Mutex
+	servers := map[string]*gopixi.StatsServer{}
+	return func(w http.ResponseWriter, r *http.Request) {
+		filename := path.Base(r.URL.Path)
+		lock.Lock()
+		server, ok := servers[filename]
+		if !ok {
+			file, summary, err := openServed(folder, filename)
+			if errors.Is(err, fs.ErrNotExist) {
+				lock.Unlock()
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				lock.Unlock()
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			server = gopixi.NewStatsServer(file, summary, 256)
+			servers[filename] = server
+		}
+		lock.Unlock()
+		server.ServeHTTP(w, r)
+	}
+}
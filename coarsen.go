@@ -0,0 +1,218 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// How the samples of a window along a dimension are combined into one sample by AppendCoarsen and
+// AppendGroupByBins.
+type Aggregation int
+
+const (
+	AggregateMean  Aggregation = iota // The mean of the values in the window.
+	AggregateSum                      // The sum of the values in the window.
+	AggregateMin                      // The least value in the window.
+	AggregateMax                      // The greatest value in the window.
+	AggregateCount                    // The number of values in the window that are not missing.
+)
+
+func (a Aggregation) String() string {
+	switch a {
+	case AggregateMean:
+		return "mean"
+	case AggregateSum:
+		return "sum"
+	case AggregateMin:
+		return "min"
+	case AggregateMax:
+		return "max"
+	case AggregateCount:
+		return "count"
+	default:
+		return fmt.Sprintf("aggregation(%d)", int(a))
+	}
+}
+
+// The aggregate of the statistics of a window, or NaN if every value of the window was missing (except
+// for counts, which are zero).
+func (a Aggregation) result(stats ZoneStatistics) float64 {
+	if a == AggregateCount {
+		return float64(stats.Count)
+	}
+	if stats.Count == 0 {
+		return math.NaN()
+	}
+	switch a {
+	case AggregateMean:
+		return stats.Mean()
+	case AggregateSum:
+		return stats.Sum
+	case AggregateMin:
+		return stats.Min
+	default:
+		return stats.Max
+	}
+}
+
+// Appends a layer to the end of the file reducing the named dimension of the source layer by an integer
+// factor, aggregating each run of factor consecutive samples along it into one, the last run being shorter
+// if the size of the dimension is not a multiple of the factor. Each result is labelled with the axis value
+// of the first sample of its run. Missing and NaN samples are left out, and missing results are written as
+// described for AppendOperation.
+func (p *Pixi) AppendCoarsen(w io.WriteSeeker, name string, source Operand, dimension string, factor int, aggregation Aggregation, outputFill *float64) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append layer"}
+	}
+	if source.Layer == nil {
+		return ErrFormat("the source of a coarsen operation must be a layer")
+	}
+	if factor < 1 {
+		return ErrFormat(fmt.Sprintf("coarsen factor %d must be positive", factor))
+	}
+	dims := source.Layer.Layer().Dimensions
+	dimIndex := dims.Index(dimension)
+	if dimIndex < 0 {
+		return ErrFormat(fmt.Sprintf("layer '%s' has no dimension '%s'", source.Layer.Layer().Name, dimension))
+	}
+
+	size := (dims[dimIndex].Size + factor - 1) / factor
+	windows := make([][2]int, size)
+	for i := range windows {
+		windows[i] = [2]int{i * factor, min((i+1)*factor, dims[dimIndex].Size)}
+	}
+	return p.appendReduction(w, name, source, dimIndex, windows, dims[dimIndex].Axis.strided(factor), aggregation, outputFill)
+}
+
+// Appends a layer to the end of the file aggregating the samples of the source layer along the named
+// dimension, which must have a regular numeric axis, into the bins between the strictly ascending edges:
+// bin i holds the samples with axis values from edges[i] up to but excluding edges[i+1], and the last bin
+// those equal to its upper edge. The result is otherwise as described for AppendCoarsen.
+func (p *Pixi) AppendGroupByBins(w io.WriteSeeker, name string, source Operand, dimension string, edges []float64, aggregation Aggregation, outputFill *float64) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append layer"}
+	}
+	if source.Layer == nil {
+		return ErrFormat("the source of a group by bins operation must be a layer")
+	}
+	if len(edges) < 2 {
+		return ErrFormat(fmt.Sprintf("%d bin edges given, expected at least two", len(edges)))
+	}
+	for i := 1; i < len(edges); i++ {
+		if !(edges[i] > edges[i-1]) {
+			return ErrFormat(fmt.Sprintf("bin edges are not strictly ascending at index %d", i))
+		}
+	}
+	layer := source.Layer.Layer()
+	dimIndex := layer.Dimensions.Index(dimension)
+	if dimIndex < 0 {
+		return ErrFormat(fmt.Sprintf("layer '%s' has no dimension '%s'", layer.Name, dimension))
+	}
+	dim := layer.Dimensions[dimIndex]
+	minimum, step, ok := dim.regularAxis()
+	if !ok {
+		return ErrFormat(fmt.Sprintf("dimension '%s' of layer '%s' has no regular numeric axis to bin by", dimension, layer.Name))
+	}
+
+	windows := make([][2]int, len(edges)-1)
+	for i := range windows {
+		low, high := snapIndex((edges[i]-minimum)/step), snapIndex((edges[i+1]-minimum)/step)
+		last := i == len(windows)-1
+		var start, end float64
+		if step > 0 {
+			start, end = math.Ceil(low), math.Ceil(high)
+			if last {
+				end = math.Floor(high) + 1
+			}
+		} else {
+			start, end = math.Floor(high)+1, math.Floor(low)+1
+			if last {
+				start = math.Ceil(high)
+			}
+		}
+		start = max(0, min(float64(dim.Size), start))
+		end = max(start, min(float64(dim.Size), end))
+		windows[i] = [2]int{int(start), int(end)}
+	}
+	return p.appendReduction(w, name, source, dimIndex, windows, binAxis(dim.Axis, edges), aggregation, outputFill)
+}
+
+// Rounds a fractional index to the nearest integer if it lies within a billionth of it.
+func snapIndex(position float64) float64 {
+	if rounded := math.Round(position); math.Abs(position-rounded) < 1e-9 {
+		return rounded
+	}
+	return position
+}
+
+// The axis labelling bins with the given edges by their lower edge, or nil if the edges are not evenly
+// spaced. The axis keeps the type and unit of the source axis if the edges are exactly representable in it.
+func binAxis(source *Axis, edges []float64) *Axis {
+	spacing := edges[1] - edges[0]
+	for i := 2; i < len(edges); i++ {
+		if math.Abs(edges[i]-edges[i-1]-spacing) > 1e-9*spacing {
+			return nil
+		}
+	}
+	axisType := source.Type.Base()
+	if fromMin, _ := axisType.ToFloat64(axisType.FromFloat64(edges[0])); fromMin != edges[0] {
+		axisType = ChannelFloat64
+	} else if fromStep, _ := axisType.ToFloat64(axisType.FromFloat64(spacing)); fromStep != spacing {
+		axisType = ChannelFloat64
+	}
+	return &Axis{Type: axisType, Minimum: axisType.FromFloat64(edges[0]), Step: axisType.FromFloat64(spacing), Unit: source.Unit}
+}
+
+// Appends a layer reducing the dimension of the source with the given index, so that each of its indices
+// aggregates the half-open window [start, end) of source indices, with the given axis.
+func (p *Pixi) appendReduction(w io.WriteSeeker, name string, source Operand, dimIndex int, windows [][2]int, axis *Axis, aggregation Aggregation, outputFill *float64) error {
+	if aggregation < AggregateMean || aggregation > AggregateCount {
+		return ErrUnsupported(fmt.Sprintf("aggregation %v", aggregation))
+	}
+	layer := source.Layer.Layer()
+	channels := make(ChannelSet, len(layer.Channels))
+	for i, channel := range layer.Channels {
+		resultType := channel.Type.Base()
+		switch aggregation {
+		case AggregateCount:
+			resultType = ChannelUint32
+		case AggregateMean, AggregateSum:
+			resultType = PromoteTypes(channel.Type, ChannelFloat32)
+		}
+		channels[i] = Channel{Name: channel.Name, Type: resultType, Unit: channel.Unit}
+	}
+
+	reduced := layer
+	reduced.Dimensions = slices.Clone(layer.Dimensions)
+	reduced.Dimensions[dimIndex].Size = len(windows)
+	reduced.Dimensions[dimIndex].TileSize = min(reduced.Dimensions[dimIndex].TileSize, len(windows))
+	reduced.Dimensions[dimIndex].Axis = axis
+
+	sourceCoord := make(SampleCoordinate, len(layer.Dimensions))
+	stats := make([]ZoneStatistics, len(channels))
+	return p.appendTilewise(w, derivedLayer(name, reduced, channels), resultFills(channels, outputFill, source),
+		func(coord SampleCoordinate, result []float64) error {
+			clear(stats)
+			copy(sourceCoord, coord)
+			window := windows[coord[dimIndex]]
+			for i := window[0]; i < window[1]; i++ {
+				sourceCoord[dimIndex] = i
+				sample, err := SampleAt(source.Layer, sourceCoord)
+				if err != nil {
+					return err
+				}
+				for c, channel := range layer.Channels {
+					value, ok := channel.Type.ToFloat64(sample[c])
+					if ok && (source.Fill == nil || value != *source.Fill) {
+						stats[c].add(value)
+					}
+				}
+			}
+			for c := range result {
+				result[c] = aggregation.result(stats[c])
+			}
+			return nil
+		})
+}
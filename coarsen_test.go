@@ -0,0 +1,182 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func coarsenTestFile(t *testing.T, dims DimensionSet, value func(coord SampleCoordinate) float64) *MemoryFile {
+	t.Helper()
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("source", dims, ChannelSet{{Name: "v", Type: ChannelInt16, Unit: "K"}}, WithCompression(CompressionFlate))
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{int16(value(writer.Coordinate()))})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestAppendCoarsen(t *testing.T) {
+	hourly := &Axis{Type: ChannelInt64, Minimum: int64(3600), Step: int64(3600), Unit: "s"}
+	dims := DimensionSet{{Name: "x", Size: 3, TileSize: 2}, {Name: "time", Size: 10, TileSize: 4, Axis: hourly}}
+	value := func(coord SampleCoordinate) float64 { return float64(coord[0]*100 + coord[1]) }
+	file := coarsenTestFile(t, dims, value)
+	source, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fill := 5.0 // the sample at time index 5 is missing
+	cases := []struct {
+		aggregation Aggregation
+		resultType  ChannelType
+		expected    func(values []float64) float64
+	}{
+		{AggregateMean, ChannelFloat32, func(values []float64) float64 {
+			sum := 0.0
+			for _, v := range values {
+				sum += v
+			}
+			return sum / float64(len(values))
+		}},
+		{AggregateMin, ChannelInt16, func(values []float64) float64 { return values[0] }},
+		{AggregateMax, ChannelInt16, func(values []float64) float64 { return values[len(values)-1] }},
+		{AggregateCount, ChannelUint32, func(values []float64) float64 { return float64(len(values)) }},
+	}
+	for _, c := range cases {
+		t.Run(c.aggregation.String(), func(t *testing.T) {
+			if err := file.AppendCoarsen(file.Stream(), c.aggregation.String(), LayerOperand(source, &fill), "time", 4, c.aggregation, nil); err != nil {
+				t.Fatal(err)
+			}
+			index := len(file.Layers) - 1
+			layer := file.Layers[index]
+			time := layer.Dimensions[1]
+			if time.Size != 3 || time.TileSize != 3 || layer.Channels[0].Type != c.resultType || layer.Channels[0].Unit != "K" {
+				t.Fatalf("unexpected result layer %v with channels %v", layer.Dimensions, layer.Channels)
+			}
+			if time.Axis.Minimum != int64(3600) || time.Axis.Step != int64(4*3600) || time.Axis.Unit != "s" {
+				t.Errorf("unexpected coarsened axis %+v", *time.Axis)
+			}
+			result, err := file.Layer(index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for coord := range layer.Dimensions.SampleCoordinates() {
+				var values []float64
+				for i := coord[1] * 4; i < min(coord[1]*4+4, 10); i++ {
+					if v := value(SampleCoordinate{coord[0], i}); v != fill {
+						values = append(values, v)
+					}
+				}
+				sample, err := SampleAt(result, coord)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, _ := c.resultType.ToFloat64(sample[0])
+				if expected := c.expected(values); math.Abs(got-expected) > 1e-4 {
+					t.Errorf("sample %v: got %v, expected %v", coord, got, expected)
+				}
+			}
+		})
+	}
+
+	if err := file.AppendCoarsen(file.Stream(), "bad", LayerOperand(source, nil), "time", 0, AggregateMean, nil); err == nil {
+		t.Error("expected error for a zero factor")
+	}
+	if err := file.AppendCoarsen(file.Stream(), "bad", LayerOperand(source, nil), "depth", 2, AggregateMean, nil); err == nil {
+		t.Error("expected error for a missing dimension")
+	}
+}
+
+func TestAppendGroupByBins(t *testing.T) {
+	latitude := &Axis{Type: ChannelFloat64, Minimum: 0.0, Step: 0.1, Unit: "degrees_north"}
+	dims := DimensionSet{{Name: "lat", Size: 25, TileSize: 8, Axis: latitude}, {Name: "x", Size: 2, TileSize: 2}}
+	file := coarsenTestFile(t, dims, func(coord SampleCoordinate) float64 { return float64(coord[0] + 100*coord[1]) })
+	source, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the samples at 1.0 and 2.0 must fall into the bins they start, despite rounding of 10*0.1 and 20*0.1
+	if err := file.AppendGroupByBins(file.Stream(), "bins", LayerOperand(source, nil), "lat", []float64{0, 1, 2}, AggregateCount, nil); err != nil {
+		t.Fatal(err)
+	}
+	layer := file.Layers[1]
+	lat := layer.Dimensions[0]
+	if lat.Size != 2 || lat.Axis == nil || lat.Axis.Type != ChannelFloat64 || lat.Axis.Minimum != 0.0 || lat.Axis.Step != 1.0 || lat.Axis.Unit != "degrees_north" {
+		t.Fatalf("unexpected binned dimension %v", lat)
+	}
+	result, err := file.Layer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for coord, expected := range map[[2]int]uint32{{0, 0}: 10, {1, 0}: 11, {0, 1}: 10, {1, 1}: 11} {
+		if sample, err := SampleAt(result, SampleCoordinate{coord[0], coord[1]}); err != nil || sample[0] != expected {
+			t.Errorf("bin %v: got %v (%v), expected %d", coord, sample, err, expected)
+		}
+	}
+
+	// uneven bins have no axis, and bins without samples are missing
+	if err := file.AppendGroupByBins(file.Stream(), "uneven", LayerOperand(source, nil), "lat", []float64{0.05, 0.35, 5, 6}, AggregateMean, nil); err != nil {
+		t.Fatal(err)
+	}
+	if axis := file.Layers[2].Dimensions[0].Axis; axis != nil {
+		t.Errorf("expected no axis for uneven bins, got %+v", *axis)
+	}
+	uneven, err := file.Layer(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []float64{2, (4 + 24) / 2.0, math.NaN()} {
+		sample, err := SampleAt(uneven, SampleCoordinate{i, 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := float64(sample[0].(float32)); got != expected+100 && !(math.IsNaN(got) && math.IsNaN(expected)) {
+			t.Errorf("bin %d: got %v, expected %v", i, got, expected+100)
+		}
+	}
+
+	if err := file.AppendGroupByBins(file.Stream(), "bad", LayerOperand(source, nil), "lat", []float64{1, 0}, AggregateMean, nil); err == nil {
+		t.Error("expected error for descending edges")
+	}
+	if err := file.AppendGroupByBins(file.Stream(), "bad", LayerOperand(source, nil), "x", []float64{0, 1}, AggregateMean, nil); err == nil {
+		t.Error("expected error for a dimension without an axis")
+	}
+}
+
+func TestAppendGroupByBinsDescendingAxis(t *testing.T) {
+	latitude := &Axis{Type: ChannelInt32, Minimum: int32(90), Step: int32(-10), Unit: "degrees_north"}
+	dims := DimensionSet{{Name: "lat", Size: 19, TileSize: 5, Axis: latitude}}
+	file := coarsenTestFile(t, dims, func(coord SampleCoordinate) float64 { return float64(coord[0]) })
+	source, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.AppendGroupByBins(file.Stream(), "bins", LayerOperand(source, nil), "lat", []float64{-90, 0, 90}, AggregateMax, nil); err != nil {
+		t.Fatal(err)
+	}
+	if axis := file.Layers[1].Dimensions[0].Axis; axis.Type != ChannelInt32 || axis.Minimum != int32(-90) || axis.Step != int32(90) {
+		t.Errorf("unexpected binned axis %+v", *axis)
+	}
+	result, err := file.Layer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// [-90, 0) holds indices 10 to 18, and [0, 90] holds indices 0 to 9
+	for i, expected := range []int16{18, 9} {
+		if sample, err := SampleAt(result, SampleCoordinate{i}); err != nil || sample[0] != expected {
+			t.Errorf("bin %d: got %v (%v), expected %d", i, sample, err, expected)
+		}
+	}
+}
@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"compress/flate"
 	"compress/lzw"
+	"fmt"
 	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
 )
 
 // Represents the compression method used to shrink the data persisted to a layer in a Pixi file.
@@ -16,6 +21,11 @@ const (
 	CompressionLzwLsb Compression = 2 // Least-significant-bit Lempel-Ziv-Welch compression from Go standard lib
 	CompressionLzwMsb Compression = 3 // Most-significant-bit Lempel-Ziv-Welch compression from Go standard lib
 	CompressionRle8   Compression = 4 // Run-length encoding capable of compressing up to 255 repeats of a sample
+	CompressionZstd   Compression = 5 // Zstandard compression, at the level given by Layer.CompressionLevel
+	CompressionLz4    Compression = 6 // LZ4 block compression, whose decompression is nearly free
+	// Byte planes of the tile's values from most to least significant, each compressed with FLATE, so that a
+	// truncated tile still decodes to a lower precision approximation (see Layer.DecodeTilePrefix).
+	CompressionProgressive Compression = 7
 )
 
 func (c Compression) String() string {
@@ -30,67 +40,135 @@ func (c Compression) String() string {
 		return "lzw_msb"
 	case CompressionRle8:
 		return "rle"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionLz4:
+		return "lz4"
+	case CompressionProgressive:
+		return "progressive"
 	default:
 		return "unknown"
 	}
 }
 
+// Holds the compressor state and scratch buffer used to encode tiles, so that they can be reused
+// from one tile to the next (and, through TileOrderWriteIterator.Reset, from one file to the next)
+// instead of being reallocated for every tile. The zero value is ready to use.
+type tileEncoder struct {
+	buf    bytes.Buffer
+	flate  *flate.Writer
+	lzwLsb *lzw.Writer
+	lzwMsb *lzw.Writer
+	zstd   map[zstd.EncoderLevel]*zstd.Encoder
+	lz4    lz4.Compressor
+	lz4HC  lz4.CompressorHC
+
+	// the write hooks run for each tile encoded, and the index of the layer the tiles belong to
+	hooks      *WriteHooks
+	layerIndex int
+}
+
+// The zstd decoder shared by every reader, which is safe for concurrent use with DecodeAll.
+var zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+})
+
 // Compresses the given chunk of data according to the selected compression scheme, and writes
 // the compressed data to the writer. Returns the number of compressed bytes written, or an error
 // if the write failed.
-func (c Compression) writeChunk(w io.Writer, layer Layer, tileIndex int, chunk []byte) (int, error) {
+func (c Compression) writeChunk(w io.Writer, h Header, layer Layer, tileIndex int, chunk []byte) (int, error) {
+	return c.writeChunkWith(&tileEncoder{}, w, h, layer, tileIndex, chunk)
+}
+
+// Compresses the chunk as in writeChunk, reusing the compressors and buffer held by the encoder.
+func (c Compression) writeChunkWith(enc *tileEncoder, w io.Writer, h Header, layer Layer, tileIndex int, chunk []byte) (int, error) {
+	// we have to write to a buffer so we can get the actual amount the compression writes
+	enc.buf.Reset()
 	switch c {
 	case CompressionNone:
 		return w.Write(chunk)
 	case CompressionFlate:
-		// we have to write to a buffer so we can get the actual amount the compression writes
-		buf := new(bytes.Buffer)
-		flateWriter, err := flate.NewWriter(buf, flate.BestCompression)
-		if err != nil {
-			return 0, err
+		if enc.flate == nil {
+			flateWriter, err := flate.NewWriter(&enc.buf, flate.BestCompression)
+			if err != nil {
+				return 0, err
+			}
+			enc.flate = flateWriter
+		} else {
+			enc.flate.Reset(&enc.buf)
 		}
 		// skip this amount; it just returns len(chunk)!
-		_, err = flateWriter.Write(chunk)
+		_, err := enc.flate.Write(chunk)
 		if err != nil {
-			flateWriter.Close()
+			enc.flate.Close()
 			return 0, err
 		}
-		flateWriter.Close()
-		writeAmt, err := io.Copy(w, buf)
-		return int(writeAmt), err
-	case CompressionLzwLsb:
-		// we have to write to a buffer so we can get the actual amount the compression writes
-		buf := new(bytes.Buffer)
-		lzwWriter := lzw.NewWriter(buf, lzw.LSB, 8)
-
-		// skip this amount; it just returns len(chunk)!
-		_, err := lzwWriter.Write(chunk)
-		if err != nil {
-			lzwWriter.Close()
-			return 0, err
+		enc.flate.Close()
+		writeAmt, err := w.Write(enc.buf.Bytes())
+		return writeAmt, err
+	case CompressionLzwLsb, CompressionLzwMsb:
+		lzwWriter, order := &enc.lzwLsb, lzw.LSB
+		if c == CompressionLzwMsb {
+			lzwWriter, order = &enc.lzwMsb, lzw.MSB
+		}
+		if *lzwWriter == nil {
+			*lzwWriter = lzw.NewWriter(&enc.buf, order, 8).(*lzw.Writer)
+		} else {
+			(*lzwWriter).Reset(&enc.buf, order, 8)
 		}
-		lzwWriter.Close()
-		writeAmt, err := io.Copy(w, buf)
-		return int(writeAmt), err
-	case CompressionLzwMsb:
-		// we have to write to a buffer so we can get the actual amount the compression writes
-		buf := new(bytes.Buffer)
-		lzwWriter := lzw.NewWriter(buf, lzw.MSB, 8)
 
 		// skip this amount; it just returns len(chunk)!
-		_, err := lzwWriter.Write(chunk)
+		_, err := (*lzwWriter).Write(chunk)
 		if err != nil {
-			lzwWriter.Close()
+			(*lzwWriter).Close()
 			return 0, err
 		}
-		lzwWriter.Close()
-		writeAmt, err := io.Copy(w, buf)
-		return int(writeAmt), err
+		(*lzwWriter).Close()
+		writeAmt, err := w.Write(enc.buf.Bytes())
+		return writeAmt, err
+	case CompressionZstd:
+		level := zstd.SpeedDefault
+		if layer.CompressionLevel > 0 {
+			level = zstd.EncoderLevelFromZstd(layer.CompressionLevel)
+		}
+		encoder := enc.zstd[level]
+		if encoder == nil {
+			var err error
+			encoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+			if err != nil {
+				return 0, err
+			}
+			if enc.zstd == nil {
+				enc.zstd = map[zstd.EncoderLevel]*zstd.Encoder{}
+			}
+			enc.zstd[level] = encoder
+		}
+		enc.buf.Write(encoder.EncodeAll(chunk, enc.buf.AvailableBuffer()))
+		return w.Write(enc.buf.Bytes())
+	case CompressionLz4:
+		// tiles that do not shrink are stored as they are, which readers detect from their stored size
+		if len(chunk) == 0 {
+			return 0, nil
+		}
+		enc.buf.Grow(len(chunk))
+		compressed := enc.buf.AvailableBuffer()[:len(chunk)-1]
+		var n int
+		var err error
+		if layer.CompressionLevel > 0 {
+			enc.lz4HC.Level = lz4.CompressionLevel(1 << (8 + min(layer.CompressionLevel, 9)))
+			n, err = enc.lz4HC.CompressBlock(chunk, compressed)
+		} else {
+			n, err = enc.lz4.CompressBlock(chunk, compressed)
+		}
+		if n == 0 || err != nil {
+			return w.Write(chunk)
+		}
+		return w.Write(compressed[:n])
 	case CompressionRle8:
 		if len(layer.Channels) == 0 {
 			return 0, ErrFormat("RLE compression requires layer channels to be defined")
 		}
-		buf := new(bytes.Buffer)
+		buf := &enc.buf
 		// two modes: separated vs condensed
 		if layer.Separated {
 			channel := layer.Channels[tileIndex/layer.Dimensions.Tiles()]
@@ -169,6 +247,8 @@ func (c Compression) writeChunk(w io.Writer, layer Layer, tileIndex int, chunk [
 		// write buffer to writer
 		amtWrt, err := io.Copy(w, buf)
 		return int(amtWrt), err
+	case CompressionProgressive:
+		return writeProgressive(enc, w, h, layer, tileIndex, chunk)
 	default:
 		return 0, ErrUnsupported("unknown compression")
 	}
@@ -201,6 +281,50 @@ func (c Compression) readChunk(r io.Reader, layer Layer, tileIndex int, chunk []
 		amtRd, err := io.Copy(bufRd, lzwRdr)
 		copy(chunk, bufRd.Bytes())
 		return int(amtRd), err
+	case CompressionZstd:
+		// zstd frames and lz4 blocks do not end the stream, so only the stored bytes of the tile are read if
+		// they are known
+		if tileIndex < len(layer.TileBytes) && layer.TileBytes[tileIndex] > 0 {
+			r = io.LimitReader(r, layer.TileBytes[tileIndex])
+		}
+		compressed, err := io.ReadAll(r)
+		if err != nil {
+			return 0, err
+		}
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return 0, err
+		}
+		decoded, err := decoder.DecodeAll(compressed, chunk[:0])
+		return copy(chunk, decoded), err
+	case CompressionLz4:
+		if tileIndex < len(layer.TileBytes) && layer.TileBytes[tileIndex] > 0 {
+			r = io.LimitReader(r, layer.TileBytes[tileIndex])
+		}
+		compressed, err := io.ReadAll(r)
+		if err != nil {
+			return 0, err
+		}
+		if len(compressed) == len(chunk) {
+			return copy(chunk, compressed), nil
+		}
+		return lz4.UncompressBlock(compressed, chunk)
+	case CompressionProgressive:
+		if tileIndex < len(layer.TileBytes) && layer.TileBytes[tileIndex] > 0 {
+			r = io.LimitReader(r, layer.TileBytes[tileIndex])
+		}
+		stored, err := io.ReadAll(r)
+		if err != nil {
+			return 0, err
+		}
+		complete, err := decodeProgressive(stored, layer, tileIndex, chunk)
+		if err != nil {
+			return 0, err
+		}
+		if !complete {
+			return 0, ErrFormat(fmt.Sprintf("progressive tile %d is truncated", tileIndex))
+		}
+		return len(chunk), nil
 	case CompressionRle8:
 		if len(layer.Channels) == 0 {
 			return 0, ErrFormat("RLE compression requires layer channels to be defined")
@@ -15,7 +15,7 @@ func TestFlateCompressionWriteRead(t *testing.T) {
 		}
 
 		buf := bytes.NewBuffer([]byte{})
-		amtWrt, err := CompressionFlate.writeChunk(buf, Layer{}, 0, chunk)
+		amtWrt, err := CompressionFlate.writeChunk(buf, Header{}, Layer{}, 0, chunk)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -40,6 +40,82 @@ func TestFlateCompressionWriteRead(t *testing.T) {
 	}
 }
 
+func TestZstdCompressionWriteRead(t *testing.T) {
+	enc := &tileEncoder{}
+	for i := range 25 {
+		chunk := make([]byte, rand.IntN(4999)+1)
+		for i := range len(chunk) {
+			chunk[i] = byte(rand.IntN(16))
+		}
+
+		// cycle through levels, reusing the encoder of each
+		layer := Layer{CompressionLevel: []int{0, 1, 3, 9, 19}[i%5]}
+		buf := bytes.NewBuffer([]byte{})
+		amtWrt, err := CompressionZstd.writeChunkWith(enc, buf, Header{}, layer, 0, chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amtWrt < 1 || amtWrt != buf.Len() {
+			t.Errorf("expected write amount to be the %d bytes written, got %d", buf.Len(), amtWrt)
+		}
+
+		// trailing bytes (such as the tile checksum) must not be read as part of the tile
+		layer.TileBytes = []int64{int64(amtWrt)}
+		buf.Write([]byte{1, 2, 3, 4})
+		rdChunk := make([]byte, len(chunk))
+		amtRcv, err := CompressionZstd.readChunk(bytes.NewReader(buf.Bytes()), layer, 0, rdChunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amtRcv != len(chunk) {
+			t.Errorf("expected to read %d bytes but read %d", len(chunk), amtRcv)
+		}
+		if !slices.Equal(chunk, rdChunk) {
+			t.Errorf("expected chunks to be equal at level %d", layer.CompressionLevel)
+		}
+	}
+}
+
+func TestLz4CompressionWriteRead(t *testing.T) {
+	enc := &tileEncoder{}
+	for i := range 30 {
+		chunk := make([]byte, rand.IntN(4999)+1)
+		for i := range len(chunk) {
+			chunk[i] = byte(rand.IntN(4))
+		}
+		if i%3 == 2 {
+			// random data does not shrink, and is stored as it is
+			for i := range len(chunk) {
+				chunk[i] = byte(rand.IntN(256))
+			}
+		}
+
+		layer := Layer{CompressionLevel: []int{0, 4, 9}[i%3]}
+		buf := bytes.NewBuffer([]byte{})
+		amtWrt, err := CompressionLz4.writeChunkWith(enc, buf, Header{}, layer, 0, chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amtWrt < 1 || amtWrt != buf.Len() || amtWrt > len(chunk) {
+			t.Errorf("expected between 1 and %d bytes to be written, got %d (%d in buffer)", len(chunk), amtWrt, buf.Len())
+		}
+
+		layer.TileBytes = []int64{int64(amtWrt)}
+		buf.Write([]byte{1, 2, 3, 4})
+		rdChunk := make([]byte, len(chunk))
+		amtRcv, err := CompressionLz4.readChunk(bytes.NewReader(buf.Bytes()), layer, 0, rdChunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amtRcv != len(chunk) {
+			t.Errorf("expected to read %d bytes but read %d", len(chunk), amtRcv)
+		}
+		if !slices.Equal(chunk, rdChunk) {
+			t.Errorf("expected chunks to be equal at level %d", layer.CompressionLevel)
+		}
+	}
+}
+
 func TestLzwLsbCompressionWriteRead(t *testing.T) {
 	for range 25 {
 		chunk := make([]byte, rand.IntN(499)+256)
@@ -48,7 +124,7 @@ func TestLzwLsbCompressionWriteRead(t *testing.T) {
 		}
 
 		buf := bytes.NewBuffer([]byte{})
-		amtWrt, err := CompressionLzwLsb.writeChunk(buf, Layer{}, 0, chunk)
+		amtWrt, err := CompressionLzwLsb.writeChunk(buf, Header{}, Layer{}, 0, chunk)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -81,7 +157,7 @@ func TestLzwMsbCompressionWriteRead(t *testing.T) {
 		}
 
 		buf := bytes.NewBuffer([]byte{})
-		amtWrt, err := CompressionLzwMsb.writeChunk(buf, Layer{}, 0, chunk)
+		amtWrt, err := CompressionLzwMsb.writeChunk(buf, Header{}, Layer{}, 0, chunk)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -143,7 +219,7 @@ func TestRle8CompressionWriteReadCondensedLayer(t *testing.T) {
 
 		buf := bytes.NewBuffer([]byte{})
 		layer := Layer{Channels: channels, Separated: false}
-		amtWrt, err := CompressionRle8.writeChunk(buf, layer, 0, chunk)
+		amtWrt, err := CompressionRle8.writeChunk(buf, Header{}, layer, 0, chunk)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -0,0 +1,139 @@
+package gopixi
+
+import (
+	"math"
+
+	"github.com/chenxingqiang/go-floatx"
+	"github.com/kshard/float8"
+	"github.com/shogo82148/float128"
+	"github.com/shogo82148/int128"
+	"github.com/x448/float16"
+)
+
+// Converts a value of this channel type to a float64, returning false if the value is not of a type that
+// can be stored in a channel. Booleans convert to 0 or 1. Values of 64-bit and wider types may lose
+// precision. The channel type is needed to tell float8 values apart from uint8 values, which share a Go type.
+func (c ChannelType) ToFloat64(value any) (float64, bool) {
+	if v, ok := value.(float8.Float8); ok && c.Base() == ChannelFloat8 {
+		return float64(float8.ToFloat32(v)), true
+	}
+	switch v := value.(type) {
+	case int8:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float16.Float16:
+		return float64(v.Float32()), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case int128.Int128:
+		if v.H < 0 {
+			// negate the two's complement value so that small negative numbers keep their precision
+			low := ^v.L + 1
+			high := ^uint64(v.H)
+			if low == 0 {
+				high++
+			}
+			return -(float64(high)*math.Exp2(64) + float64(low)), true
+		}
+		return float64(v.H)*math.Exp2(64) + float64(v.L), true
+	case int128.Uint128:
+		return float64(v.H)*math.Exp2(64) + float64(v.L), true
+	case float128.Float128:
+		return v.Float64(), true
+	case floatx.BFloat16:
+		return float64(v.Float32()), true
+	default:
+		return 0, false
+	}
+}
+
+// Converts a float64 to a value of this channel type. Integer types round to the nearest integer and
+// saturate at the limits of the type, with NaN converting to zero. Booleans are true for any non-zero value.
+func (c ChannelType) FromFloat64(f float64) any {
+	switch c.Base() {
+	case ChannelInt8:
+		return int8(clampInteger(f, math.MinInt8, math.MaxInt8))
+	case ChannelUint8:
+		return uint8(clampInteger(f, 0, math.MaxUint8))
+	case ChannelInt16:
+		return int16(clampInteger(f, math.MinInt16, math.MaxInt16))
+	case ChannelUint16:
+		return uint16(clampInteger(f, 0, math.MaxUint16))
+	case ChannelInt32:
+		return int32(clampInteger(f, math.MinInt32, math.MaxInt32))
+	case ChannelUint32:
+		return uint32(clampInteger(f, 0, math.MaxUint32))
+	case ChannelInt64:
+		switch f = math.Round(f); {
+		case math.IsNaN(f):
+			return int64(0)
+		case f >= math.Exp2(63):
+			return int64(math.MaxInt64)
+		case f <= math.MinInt64:
+			return int64(math.MinInt64)
+		}
+		return int64(f)
+	case ChannelUint64:
+		switch f = math.Round(f); {
+		case math.IsNaN(f) || f <= 0:
+			return uint64(0)
+		case f >= math.Exp2(64):
+			return uint64(math.MaxUint64)
+		}
+		return uint64(f)
+	case ChannelFloat8:
+		return float8.ToFloat8(float32(f))
+	case ChannelFloat16:
+		return float16.Fromfloat32(float32(f))
+	case ChannelFloat32:
+		return float32(f)
+	case ChannelFloat64:
+		return f
+	case ChannelBool:
+		return f != 0 && !math.IsNaN(f)
+	case ChannelInt128:
+		if math.IsNaN(f) {
+			return int128.Int128{}
+		}
+		return int128.Float64ToInt128(math.Round(f))
+	case ChannelUint128:
+		if math.IsNaN(f) || f <= 0 {
+			return int128.Uint128{}
+		}
+		return int128.Float64ToUint128(math.Round(f))
+	case ChannelFloat128:
+		return float128.FromFloat64(f)
+	case ChannelBFloat16:
+		return floatx.BF16Fromfloat32(float32(f))
+	default:
+		panic("pixi: tried to convert to unsupported channel type")
+	}
+}
+
+// Rounds the value to the nearest integer within [low, high], mapping NaN to zero.
+func clampInteger(f float64, low float64, high float64) float64 {
+	if math.IsNaN(f) {
+		return 0
+	}
+	return math.Min(math.Max(math.Round(f), low), high)
+}
@@ -0,0 +1,49 @@
+package gopixi
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kshard/float8"
+)
+
+func TestChannelTypeFloat64Conversion(t *testing.T) {
+	cases := []struct {
+		channelType ChannelType
+		in          float64
+		value       any
+		out         float64
+	}{
+		{ChannelInt8, -3.4, int8(-3), -3},
+		{ChannelInt8, 1000, int8(math.MaxInt8), math.MaxInt8},
+		{ChannelUint8, -5, uint8(0), 0},
+		{ChannelUint8, 2.5, uint8(3), 3},
+		{ChannelUint16, math.NaN(), uint16(0), 0},
+		{ChannelInt32, -1e12, int32(math.MinInt32), math.MinInt32},
+		{ChannelInt64, 1e30, int64(math.MaxInt64), math.Exp2(63)},
+		{ChannelUint64, 42, uint64(42), 42},
+		{ChannelFloat32, 0.5, float32(0.5), 0.5},
+		{ChannelFloat64, 1.25, 1.25, 1.25},
+		{ChannelBool, 2, true, 1},
+		{ChannelBool, 0, false, 0},
+		{ChannelFloat8, 1, float8.ToFloat8(1), 1},
+		{ChannelFloat16, 0.75, nil, 0.75},
+		{ChannelBFloat16, 2, nil, 2},
+		{ChannelInt128, -7, nil, -7},
+		{ChannelUint128, 1 << 40, nil, 1 << 40},
+		{ChannelFloat128, 3.5, nil, 3.5},
+	}
+	for _, c := range cases {
+		value := c.channelType.FromFloat64(c.in)
+		if c.value != nil && value != c.value {
+			t.Errorf("%v from %v: expected %v (%T), got %v (%T)", c.channelType, c.in, c.value, c.value, value, value)
+		}
+		out, ok := c.channelType.ToFloat64(value)
+		if !ok || out != c.out {
+			t.Errorf("%v to float64: expected %v, got %v (ok %v)", c.channelType, c.out, out, ok)
+		}
+	}
+	if _, ok := ChannelUint8.ToFloat64("not a number"); ok {
+		t.Error("expected conversion of a string to fail")
+	}
+}
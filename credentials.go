@@ -0,0 +1,151 @@
+package gopixi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Supplies authentication for the requests made by remote backends (such as HttpReadSeeker). Services
+// can provide their own implementation to inject whatever authentication scheme their storage requires,
+// rather than relying on the package to pick a particular SDK's credential chain.
+type Credentials interface {
+	// Adds authentication information to the outgoing request, typically by setting headers. Returns
+	// an error if credentials could not be obtained, in which case the request is not sent.
+	Authorize(req *http.Request) error
+}
+
+// Returned by a Credentials provider when it has no credentials to offer, allowing a CredentialsChain
+// to fall through to the next provider.
+var ErrNoCredentials = errors.New("pixi: no credentials available")
+
+// Adapts an ordinary function into a Credentials provider, for fully custom authentication schemes.
+type CredentialsFunc func(req *http.Request) error
+
+func (f CredentialsFunc) Authorize(req *http.Request) error {
+	return f(req)
+}
+
+// Fixed credentials that set the same headers on every request. Use NewBearerCredentials or
+// NewBasicCredentials for the common cases.
+type StaticCredentials struct {
+	Header http.Header
+}
+
+// Creates static credentials that send the given token as an 'Authorization: Bearer' header.
+func NewBearerCredentials(token string) StaticCredentials {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	return StaticCredentials{Header: header}
+}
+
+// Creates static credentials that use HTTP basic authentication with the given user and password.
+func NewBasicCredentials(user string, password string) StaticCredentials {
+	req := &http.Request{Header: http.Header{}}
+	req.SetBasicAuth(user, password)
+	return StaticCredentials{Header: req.Header}
+}
+
+func (s StaticCredentials) Authorize(req *http.Request) error {
+	for key, values := range s.Header {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	return nil
+}
+
+// Credentials read from environment variables at request time. If TokenVar is set, its value is sent as a
+// bearer token; otherwise, if UserVar is set, its value and the value of PasswordVar are sent using basic
+// authentication.
+type EnvCredentials struct {
+	TokenVar    string
+	UserVar     string
+	PasswordVar string
+}
+
+// The default environment credentials, reading a bearer token from PIXI_BEARER_TOKEN or basic
+// authentication from PIXI_USERNAME and PIXI_PASSWORD.
+var DefaultEnvCredentials = EnvCredentials{
+	TokenVar:    "PIXI_BEARER_TOKEN",
+	UserVar:     "PIXI_USERNAME",
+	PasswordVar: "PIXI_PASSWORD",
+}
+
+func (e EnvCredentials) Authorize(req *http.Request) error {
+	if e.TokenVar != "" {
+		if token, ok := os.LookupEnv(e.TokenVar); ok && token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		}
+	}
+	if e.UserVar != "" {
+		if user, ok := os.LookupEnv(e.UserVar); ok && user != "" {
+			req.SetBasicAuth(user, os.Getenv(e.PasswordVar))
+			return nil
+		}
+	}
+	return ErrNoCredentials
+}
+
+// A short-lived access token along with the time at which it stops being valid. A zero Expiry means
+// the token never expires.
+type Token struct {
+	AccessToken string
+	Expiry      time.Time
+}
+
+// Credentials backed by a refreshable token source, such as an IAM role or an OIDC identity provider. The
+// Fetch function is called to obtain a new token whenever the cached token is missing or within
+// RefreshBefore of expiring.
+type TokenCredentials struct {
+	Fetch         func(ctx context.Context) (Token, error)
+	RefreshBefore time.Duration
+
+	lock  sync.Mutex
+	token Token
+}
+
+// Creates token credentials that refresh tokens one minute before they expire.
+func NewTokenCredentials(fetch func(ctx context.Context) (Token, error)) *TokenCredentials {
+	return &TokenCredentials{
+		Fetch:         fetch,
+		RefreshBefore: time.Minute,
+	}
+}
+
+func (t *TokenCredentials) Authorize(req *http.Request) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.token.AccessToken == "" || (!t.token.Expiry.IsZero() && time.Now().Add(t.RefreshBefore).After(t.token.Expiry)) {
+		token, err := t.Fetch(req.Context())
+		if err != nil {
+			return err
+		}
+		t.token = token
+	}
+
+	req.Header.Set("Authorization", "Bearer "+t.token.AccessToken)
+	return nil
+}
+
+// An ordered list of providers, where the first provider to authorize the request without returning
+// ErrNoCredentials is used. Any other error stops the chain and is returned. If no provider has
+// credentials, the request is sent without authentication.
+type CredentialsChain []Credentials
+
+func (c CredentialsChain) Authorize(req *http.Request) error {
+	for _, provider := range c {
+		err := provider.Authorize(req)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package gopixi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newAuthTestServer(t *testing.T, content []byte, authorized func(r *http.Request) bool) *url.URL {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.ServeContent(w, r, "test.pixi", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	serverUrl, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serverUrl
+}
+
+func TestHttpBearerCredentials(t *testing.T) {
+	content := []byte("pixi credential test content")
+	serverUrl := newAuthTestServer(t, content, func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer secret"
+	})
+
+	_, err := OpenHttp(serverUrl, nil)
+	if err == nil {
+		t.Fatal("expected unauthenticated open to fail")
+	}
+
+	reader, err := OpenHttp(serverUrl, nil, WithCredentials(NewBearerCredentials("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, content) {
+		t.Errorf("expected %q, got %q", content, read)
+	}
+}
+
+func TestHttpBasicCredentials(t *testing.T) {
+	content := []byte("pixi basic auth content")
+	serverUrl := newAuthTestServer(t, content, func(r *http.Request) bool {
+		user, pass, ok := r.BasicAuth()
+		return ok && user == "user" && pass == "pass"
+	})
+
+	reader, err := OpenBufferedHttp(serverUrl, nil, WithCredentials(NewBasicCredentials("user", "pass")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, content) {
+		t.Errorf("expected %q, got %q", content, read)
+	}
+}
+
+func TestHttpCredentialsSignRange(t *testing.T) {
+	content := []byte("pixi signed range content")
+	serverUrl := newAuthTestServer(t, content, func(r *http.Request) bool {
+		return r.Header.Get("X-Signed-Range") == r.Header.Get("Range")
+	})
+
+	// signs the range requested, as credentials signing the request headers do
+	signer := CredentialsFunc(func(req *http.Request) error {
+		req.Header.Set("X-Signed-Range", req.Header.Get("Range"))
+		return nil
+	})
+	reader, err := OpenHttp(serverUrl, nil, WithCredentials(signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Seek(5, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	read, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, content[5:]) {
+		t.Errorf("expected %q, got %q", content[5:], read)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	creds := EnvCredentials{TokenVar: "PIXI_TEST_TOKEN", UserVar: "PIXI_TEST_USER", PasswordVar: "PIXI_TEST_PASS"}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if err := creds.Authorize(req); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials with empty environment, got %v", err)
+	}
+
+	t.Setenv("PIXI_TEST_USER", "envuser")
+	t.Setenv("PIXI_TEST_PASS", "envpass")
+	req = httptest.NewRequest("GET", "/", nil)
+	if err := creds.Authorize(req); err != nil {
+		t.Fatal(err)
+	}
+	if user, pass, ok := req.BasicAuth(); !ok || user != "envuser" || pass != "envpass" {
+		t.Errorf("expected basic auth from environment, got %s:%s", user, pass)
+	}
+
+	t.Setenv("PIXI_TEST_TOKEN", "envtoken")
+	req = httptest.NewRequest("GET", "/", nil)
+	if err := creds.Authorize(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Authorization") != "Bearer envtoken" {
+		t.Errorf("expected bearer token to take precedence, got %s", req.Header.Get("Authorization"))
+	}
+}
+
+func TestTokenCredentialsRefresh(t *testing.T) {
+	fetches := 0
+	creds := NewTokenCredentials(func(ctx context.Context) (Token, error) {
+		fetches++
+		// expires within the default refresh window, so every request triggers a refresh
+		return Token{AccessToken: "token", Expiry: time.Now().Add(30 * time.Second)}, nil
+	})
+
+	for range 3 {
+		req := httptest.NewRequest("GET", "/", nil)
+		if err := creds.Authorize(req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected authorization header %s", req.Header.Get("Authorization"))
+		}
+	}
+	if fetches != 3 {
+		t.Errorf("expected token near expiry to be refetched each time, fetched %d times", fetches)
+	}
+
+	fetches = 0
+	creds = NewTokenCredentials(func(ctx context.Context) (Token, error) {
+		fetches++
+		return Token{AccessToken: "token", Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	for range 3 {
+		req := httptest.NewRequest("GET", "/", nil)
+		if err := creds.Authorize(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected cached token to be reused, fetched %d times", fetches)
+	}
+}
+
+func TestCredentialsChain(t *testing.T) {
+	chain := CredentialsChain{
+		EnvCredentials{TokenVar: "PIXI_TEST_CHAIN_TOKEN"},
+		NewBearerCredentials("fallback"),
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	if err := chain.Authorize(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Authorization") != "Bearer fallback" {
+		t.Errorf("expected chain to fall through to static credentials, got %s", req.Header.Get("Authorization"))
+	}
+
+	failure := errors.New("provider failure")
+	chain = CredentialsChain{
+		CredentialsFunc(func(req *http.Request) error { return failure }),
+		NewBearerCredentials("unused"),
+	}
+	req = httptest.NewRequest("GET", "/", nil)
+	if err := chain.Authorize(req); !errors.Is(err, failure) {
+		t.Errorf("expected provider failure to stop the chain, got %v", err)
+	}
+}
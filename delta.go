@@ -0,0 +1,372 @@
+package gopixi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// The reserved tag of a delta file written by ExportDelta recording the fingerprint of the tiles of the
+	// file it was exported against, which ApplyDelta checks before applying it.
+	DeltaBaseTag string = "pixi.delta.base"
+	// The reserved tag of a delta file listing the indices of the layers of the base (separated by commas)
+	// whose tiles the delta replaces entirely, because their layout changed or tiles were removed.
+	DeltaReplacedTag string = "pixi.delta.replaced"
+)
+
+// What was written to a delta file by ExportDelta.
+type DeltaReport struct {
+	Tiles  int   // The number of changed tiles stored in the delta.
+	Bytes  int64 // The number of bytes of stored tiles in the delta, including their checksums.
+	Layers int   // The number of layers of the target that are not in the base.
+}
+
+func (r DeltaReport) String() string {
+	return fmt.Sprintf("stored %d changed tiles in %d bytes, with %d new layers", r.Tiles, r.Bytes, r.Layers)
+}
+
+// Writes a delta file to the empty stream dst holding only what the Pixi file in target changed since the
+// Pixi file in base, to be applied with ApplyDelta. Tiles are unchanged when their stored sizes and checksums
+// match. The target must have the byte order, offset size, and checksum algorithm of the base, and every
+// layer of the base at the same index.
+func ExportDelta(base io.ReadSeeker, target io.ReadSeeker, dst io.WriteSeeker) (*Pixi, DeltaReport, error) {
+	basePixi, err := ReadPixi(base)
+	if err != nil {
+		return nil, DeltaReport{}, err
+	}
+	targetPixi, err := ReadPixi(target)
+	if err != nil {
+		return nil, DeltaReport{}, err
+	}
+	return exportDelta(basePixi, base, targetPixi, target, false, dst)
+}
+
+// Writes a delta file to the empty stream dst holding what the current generation of the Pixi file with
+// tile history in src changed since the given generation, as ExportDelta does for two files.
+func ExportGenerationDelta(src io.ReadSeeker, generation int, dst io.WriteSeeker) (*Pixi, DeltaReport, error) {
+	current, err := ReadPixi(src)
+	if err != nil {
+		return nil, DeltaReport{}, err
+	}
+	view, err := current.OpenAt(generation)
+	if err != nil {
+		return nil, DeltaReport{}, err
+	}
+	return exportDelta(view, src, current, src, true, dst)
+}
+
+func exportDelta(base *Pixi, baseStream io.ReadSeeker, target *Pixi, targetStream io.ReadSeeker, sameStream bool, dst io.WriteSeeker) (*Pixi, DeltaReport, error) {
+	if err := checkDeltaHeaders(base.Header, target.Header); err != nil {
+		return nil, DeltaReport{}, err
+	}
+	if len(target.Layers) < len(base.Layers) {
+		return nil, DeltaReport{}, ErrUnsupported(fmt.Sprintf("delta to a file of %d layers from a base of %d layers", len(target.Layers), len(base.Layers)))
+	}
+	fingerprint, err := base.tileFingerprint(baseStream)
+	if err != nil {
+		return nil, DeltaReport{}, err
+	}
+
+	header := NewHeader(target.Header.ByteOrder, target.Header.OffsetSize)
+	header.Version = target.Header.Version
+	header.Checksum = target.Header.Checksum // stored tiles are copied along with their checksums
+	delta, err := Create(dst, header)
+	if err != nil {
+		return nil, DeltaReport{}, err
+	}
+
+	replaced := []string{}
+	for i, layer := range base.Layers {
+		if deltaReplaces(layer, target.Layers[i]) {
+			replaced = append(replaced, strconv.Itoa(i))
+		}
+	}
+	section := changedTags(base, target)
+	section.Tags[DeltaBaseTag] = fingerprint
+	if len(replaced) > 0 {
+		section.Tags[DeltaReplacedTag] = strings.Join(replaced, ",")
+	}
+	if err := delta.appendTagSection(dst, section); err != nil {
+		return nil, DeltaReport{}, err
+	}
+
+	report := DeltaReport{Layers: len(target.Layers) - len(base.Layers)}
+	for i, layer := range target.Layers {
+		deltaLayer := layer
+		deltaLayer.Channels = slices.Clone(layer.Channels)
+		deltaLayer.TileBytes = make([]int64, layer.DiskTiles())
+		deltaLayer.TileOffsets = make([]int64, layer.DiskTiles())
+		deltaLayer.StringBytes = slices.Clone(layer.StringBytes)
+		deltaLayer.NextLayerStart = 0
+		incremental := i < len(base.Layers) && !slices.Contains(replaced, strconv.Itoa(i))
+		for tile := range layer.DiskTiles() {
+			if layer.TileBytes[tile] == 0 {
+				continue
+			}
+			if incremental {
+				changed, err := tileChanged(baseStream, base.Layers[i], targetStream, layer, header, tile, sameStream)
+				if err != nil {
+					return nil, DeltaReport{}, err
+				}
+				if !changed {
+					continue
+				}
+			}
+			offset, err := copyRawTile(targetStream, dst, header, layer.TileOffsets[tile], layer.TileBytes[tile])
+			if err != nil {
+				return nil, DeltaReport{}, err
+			}
+			deltaLayer.TileOffsets[tile] = offset
+			deltaLayer.TileBytes[tile] = layer.TileBytes[tile]
+			report.Tiles++
+			report.Bytes += layer.TileBytes[tile] + int64(header.Checksum.Size())
+		}
+		if err := delta.appendLayerHeader(dst, deltaLayer); err != nil {
+			return nil, DeltaReport{}, err
+		}
+	}
+	return delta, report, nil
+}
+
+// Applies the delta file written by ExportDelta against this file to the file in rw, appending the changed
+// tiles and metadata before updating the headers that reference them, so that an interrupted apply leaves
+// the file as it was. Returns an error without changing the file if its tiles are not those the delta was
+// exported against.
+func (p *Pixi) ApplyDelta(rw io.ReadWriteSeeker, delta io.ReadSeeker) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "apply delta"}
+	}
+	deltaPixi, err := ReadPixi(delta)
+	if err != nil {
+		return err
+	}
+	tags := deltaPixi.AllTags()
+	base, ok := tags[DeltaBaseTag]
+	if !ok {
+		return ErrFormat("not a delta file: it has no base fingerprint")
+	}
+	if err := checkDeltaHeaders(p.Header, deltaPixi.Header); err != nil {
+		return err
+	}
+	if len(deltaPixi.Layers) < len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("delta has %d layers, but the file has %d", len(deltaPixi.Layers), len(p.Layers)))
+	}
+	fingerprint, err := p.tileFingerprint(rw)
+	if err != nil {
+		return err
+	}
+	if fingerprint != base {
+		return ErrFormat("delta was exported against a file with different tiles")
+	}
+	replaced := map[int]bool{}
+	if list := tags[DeltaReplacedTag]; list != "" {
+		for _, field := range strings.Split(list, ",") {
+			index, err := strconv.Atoi(field)
+			if err != nil {
+				return ErrFormat(fmt.Sprintf("invalid replaced layer '%s' in delta", field))
+			}
+			replaced[index] = true
+		}
+	}
+	for i, layer := range p.Layers {
+		if !replaced[i] && !sameTileLayout(layer, deltaPixi.Layers[i]) {
+			return ErrFormat(fmt.Sprintf("layer %d of the delta does not have the tile layout of layer '%s'", i, layer.Name))
+		}
+	}
+
+	// copy the changed tiles first, so that no header references them until they are in place
+	layers := make([]Layer, len(deltaPixi.Layers))
+	for i, deltaLayer := range deltaPixi.Layers {
+		layer := deltaLayer
+		layer.TileBytes = make([]int64, deltaLayer.DiskTiles())
+		layer.TileOffsets = make([]int64, deltaLayer.DiskTiles())
+		layer.StringBytes = slices.Clone(deltaLayer.StringBytes)
+		for tile := range deltaLayer.DiskTiles() {
+			switch {
+			case deltaLayer.TileBytes[tile] != 0:
+				offset, err := copyRawTile(delta, rw, p.Header, deltaLayer.TileOffsets[tile], deltaLayer.TileBytes[tile])
+				if err != nil {
+					return err
+				}
+				layer.TileOffsets[tile] = offset
+				layer.TileBytes[tile] = deltaLayer.TileBytes[tile]
+			case i < len(p.Layers) && !replaced[i]:
+				layer.TileOffsets[tile] = p.Layers[i].TileOffsets[tile]
+				layer.TileBytes[tile] = p.Layers[i].TileBytes[tile]
+				if layer.StringBytes != nil {
+					layer.StringBytes[tile] = p.Layers[i].StringBytes[tile]
+				}
+			}
+		}
+		layers[i] = layer
+	}
+
+	section := TagSection{Tags: tags, Attributes: deltaPixi.AllAttributes(), Axes: deltaPixi.SharedAxes()}
+	delete(section.Tags, DeltaBaseTag)
+	delete(section.Tags, DeltaReplacedTag)
+	if len(section.Tags) > 0 || len(section.Attributes) > 0 || len(section.Axes) > 0 {
+		if err := p.appendTagSection(rw, section); err != nil {
+			return err
+		}
+	}
+	existing := len(p.Layers)
+	for i, layer := range layers {
+		if i >= existing {
+			if err := p.appendLayerHeader(rw, layer); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.UpdateLayerHeader(rw, i, layer); err != nil {
+			return err
+		}
+		if err := p.layerFinalized(i); err != nil {
+			return err
+		}
+	}
+	p.resolveAxes()
+	return nil
+}
+
+// Checks that tiles stored in files with the given headers can be copied between them verbatim.
+func checkDeltaHeaders(base Header, target Header) error {
+	if base.ByteOrder != target.ByteOrder || base.OffsetSize != target.OffsetSize || base.Checksum != target.Checksum {
+		return ErrUnsupported(fmt.Sprintf("delta between files with %v, %d-byte offsets, and %v checksums and %v, %d-byte offsets, and %v checksums",
+			base.ByteOrder, base.OffsetSize, base.Checksum, target.ByteOrder, target.OffsetSize, target.Checksum))
+	}
+	return nil
+}
+
+// Whether the layers store their tiles with the same layout, so that a stored tile of one can stand in for
+// the tile of the same index in the other.
+func sameTileLayout(a Layer, b Layer) bool {
+	if a.Separated != b.Separated || a.Compression != b.Compression || len(a.Dimensions) != len(b.Dimensions) || len(a.Channels) != len(b.Channels) {
+		return false
+	}
+	for d, dim := range a.Dimensions {
+		other := b.Dimensions[d]
+		if dim.Size != other.Size || dim.TileSize != other.TileSize || dim.Halo != other.Halo {
+			return false
+		}
+	}
+	for c, channel := range a.Channels {
+		if channel.Type.Base() != b.Channels[c].Type.Base() {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether a delta from the base layer to the target layer must store every tile of the target layer, because
+// their tile layouts differ or the target lacks a tile stored in the base.
+func deltaReplaces(base Layer, target Layer) bool {
+	if !sameTileLayout(base, target) {
+		return true
+	}
+	for tile, size := range base.TileBytes {
+		if size != 0 && target.TileBytes[tile] == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the stored tile of the target layer differs from the stored tile of the same index in the base
+// layer, by size or checksum. Tiles at the same location of the same stream are unchanged without reading.
+func tileChanged(baseStream io.ReadSeeker, base Layer, targetStream io.ReadSeeker, target Layer, h Header, tile int, sameStream bool) (bool, error) {
+	if base.TileBytes[tile] != target.TileBytes[tile] {
+		return true, nil
+	}
+	if sameStream && base.TileOffsets[tile] == target.TileOffsets[tile] {
+		return false, nil
+	}
+	baseChecksum, err := storedChecksum(baseStream, h, base.TileOffsets[tile], base.TileBytes[tile])
+	if err != nil {
+		return false, err
+	}
+	targetChecksum, err := storedChecksum(targetStream, h, target.TileOffsets[tile], target.TileBytes[tile])
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(baseChecksum, targetChecksum), nil
+}
+
+// Reads the checksum stored after the tile at the given offset and of the given size.
+func storedChecksum(r io.ReadSeeker, h Header, offset int64, size int64) ([]byte, error) {
+	if _, err := r.Seek(offset+size, io.SeekStart); err != nil {
+		return nil, err
+	}
+	checksum := make([]byte, h.Checksum.Size())
+	if _, err := io.ReadFull(r, checksum); err != nil {
+		return nil, err
+	}
+	return checksum, nil
+}
+
+// A fingerprint of the tiles of the file: a SHA-256 digest of the name of each layer and the stored size and
+// checksum of each of its tiles, so that files holding the same tiles have the same fingerprint wherever in
+// the files the tiles are stored.
+func (p *Pixi) tileFingerprint(r io.ReadSeeker) (string, error) {
+	hash := sha256.New()
+	var size [8]byte
+	for _, layer := range p.Layers {
+		binary.BigEndian.PutUint64(size[:], uint64(len(layer.Name)))
+		hash.Write(size[:])
+		hash.Write([]byte(layer.Name))
+		for tile, bytes := range layer.TileBytes {
+			binary.BigEndian.PutUint64(size[:], uint64(bytes))
+			hash.Write(size[:])
+			if bytes == 0 {
+				continue
+			}
+			checksum, err := storedChecksum(r, p.Header, layer.TileOffsets[tile], bytes)
+			if err != nil {
+				return "", err
+			}
+			hash.Write(checksum)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// The tags, attributes, and shared axes of the target that are new or differ from those of the base, in a
+// single section. Generation records are left out.
+func changedTags(base *Pixi, target *Pixi) TagSection {
+	section := TagSection{Tags: map[string]string{}}
+	baseTags := base.AllTags()
+	for key, value := range target.AllTags() {
+		if strings.HasPrefix(key, GenerationTagPrefix) || key == DeltaBaseTag || key == DeltaReplacedTag {
+			continue
+		}
+		if old, ok := baseTags[key]; !ok || old != value {
+			section.Tags[key] = value
+		}
+	}
+	baseAttributes := base.AllAttributes()
+	for name, value := range target.AllAttributes() {
+		if old, ok := baseAttributes[name]; !ok || !reflect.DeepEqual(old, value) {
+			if section.Attributes == nil {
+				section.Attributes = map[string]any{}
+			}
+			section.Attributes[name] = value
+		}
+	}
+	baseAxes := base.SharedAxes()
+	for id, axis := range target.SharedAxes() {
+		if old, ok := baseAxes[id]; !ok || !axesEqual(old, axis) {
+			if section.Axes == nil {
+				section.Axes = map[string]*Axis{}
+			}
+			section.Axes[id] = axis
+		}
+	}
+	return section
+}
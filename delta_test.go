@@ -0,0 +1,169 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestGenerationDeltaApply(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.LittleEndian, OffsetSize8)
+	header.Checksum = ChecksumSHA256
+	layers := []Layer{
+		NewLayer("a", DimensionSet{{Name: "x", Size: 16, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}}, WithCompression(CompressionFlate)),
+		NewLayer("b", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}}),
+	}
+	written := writeTestPixi(t, buf, header, map[string]string{"source": "model"}, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0] + layer*100)}
+	})
+	mirrorBytes := bytes.Clone(buf.Bytes())
+
+	written.TileHistory = true
+	if err := written.RewriteTiles(buf, 0, map[int][]byte{2: {50, 51, 52, 53}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := written.AppendTags(buf, map[string]string{"source": "reanalysis", "run": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	newLayer := NewLayer("c", DimensionSet{{Name: "x", Size: 4, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	writer := NewTileOrderWriteIterator(buf, written.Header, newLayer)
+	err := written.AppendIterativeLayer(buf, newLayer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{uint8(200 + writer.Coordinate()[0])})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file as it was at generation zero holds the new layer already, so only the rewritten tile is stored
+	delta := buffer.NewBuffer(10)
+	_, report, err := ExportGenerationDelta(buffer.NewBufferFrom(buf.Bytes()), 0, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Tiles != 1 || report.Layers != 0 {
+		t.Errorf("expected a single changed tile, got %v", report)
+	}
+	if len(delta.Bytes()) >= len(buf.Bytes()) {
+		t.Errorf("expected the delta (%d bytes) to be smaller than the file (%d bytes)", len(delta.Bytes()), len(buf.Bytes()))
+	}
+
+	// a mirror without the new layer receives it in full from a delta between the files
+	delta = buffer.NewBuffer(10)
+	_, report, err = ExportDelta(buffer.NewBufferFrom(mirrorBytes), buffer.NewBufferFrom(buf.Bytes()), delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Tiles != 2 || report.Layers != 1 {
+		t.Errorf("expected the changed tile and the new layer, got %v", report)
+	}
+
+	mirror := buffer.NewBufferFrom(mirrorBytes)
+	mirrorPixi, err := ReadPixi(mirror)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mirrorPixi.ApplyDelta(mirror, buffer.NewBufferFrom(delta.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := ReadPixi(buffer.NewBufferFrom(mirror.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied.Layers) != 3 {
+		t.Fatalf("expected three layers after applying the delta, got %d", len(applied.Layers))
+	}
+	tags := applied.AllTags()
+	if tags["source"] != "reanalysis" || tags["run"] != "2" {
+		t.Errorf("expected the changed tags, got %v", tags)
+	}
+	if _, ok := tags[DeltaBaseTag]; ok {
+		t.Errorf("expected the delta tags to be left out of the applied file")
+	}
+	current, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, layer := range current.Layers {
+		expected, err := layer.ReadRegion(buffer.NewBufferFrom(buf.Bytes()), current.Header, FullRegion(layer.Dimensions))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := applied.Layers[i].ReadRegion(buffer.NewBufferFrom(mirror.Bytes()), applied.Header, FullRegion(layer.Dimensions))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for s := range expected {
+			if got[s][0] != expected[s][0] {
+				t.Errorf("layer '%s' sample %d: expected %v, got %v", layer.Name, s, expected[s][0], got[s][0])
+			}
+		}
+	}
+
+	// the mirror no longer holds the tiles the delta was exported against
+	if err := applied.ApplyDelta(mirror, buffer.NewBufferFrom(delta.Bytes())); err == nil {
+		t.Errorf("expected a delta applied twice to be rejected")
+	}
+}
+
+func TestDeltaReplacedLayer(t *testing.T) {
+	header := NewHeader(binary.BigEndian, OffsetSize4)
+	base := buffer.NewBuffer(10)
+	baseLayers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelInt16}})}
+	basePixi := writeTestPixi(t, base, header, nil, baseLayers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{int16(coord[0])}
+	})
+	if err := basePixi.AppendAttributes(base, map[string]any{"scale": 0.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the target stores the layer in tiles of a different size, so the delta must hold all of them
+	target := buffer.NewBuffer(10)
+	targetLayers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 8, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelInt16}})}
+	targetPixi := writeTestPixi(t, target, header, nil, targetLayers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{int16(-coord[0])}
+	})
+	if err := targetPixi.AppendAttributes(target, map[string]any{"scale": 0.25}); err != nil {
+		t.Fatal(err)
+	}
+	delta := buffer.NewBuffer(10)
+	deltaPixi, report, err := ExportDelta(buffer.NewBufferFrom(base.Bytes()), buffer.NewBufferFrom(target.Bytes()), delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Tiles != 4 || deltaPixi.AllTags()[DeltaReplacedTag] != "0" {
+		t.Errorf("expected every tile of the replaced layer, got %v", report)
+	}
+
+	if err := basePixi.ApplyDelta(base, buffer.NewBufferFrom(delta.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if scale := basePixi.AllAttributes()["scale"]; scale != 0.25 {
+		t.Errorf("expected the changed attribute, got %v", scale)
+	}
+	samples, err := basePixi.Layers[0].ReadRegion(base, basePixi.Header, FullRegion(basePixi.Layers[0].Dimensions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if samples[5][0] != int16(-5) || basePixi.Layers[0].Dimensions[0].TileSize != 2 {
+		t.Errorf("expected the replaced layer, got sample %v", samples[5][0])
+	}
+
+	// files that cannot share stored tiles are rejected
+	other := NewHeader(binary.LittleEndian, OffsetSize4)
+	otherBuf := buffer.NewBuffer(10)
+	writeTestPixi(t, otherBuf, other, nil, targetLayers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{int16(0)}
+	})
+	if _, _, err := ExportDelta(buffer.NewBufferFrom(base.Bytes()), buffer.NewBufferFrom(otherBuf.Bytes()), buffer.NewBuffer(10)); err == nil {
+		t.Errorf("expected a delta between files of different byte orders to be rejected")
+	}
+	if err := basePixi.ApplyDelta(base, buffer.NewBufferFrom(target.Bytes())); err == nil {
+		t.Errorf("expected a file that is not a delta to be rejected")
+	}
+}
@@ -0,0 +1,145 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// A file format that can be recognized by Detect from the first few bytes of a file.
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatPixi
+	FormatGzip
+	FormatZstd
+	FormatBzip2
+	FormatXz
+	FormatZip
+	FormatTiff
+	FormatBigTiff
+	FormatPng
+	FormatJpeg
+	FormatGif
+	FormatNetCDF
+	FormatHdf5
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatPixi:
+		return "pixi"
+	case FormatGzip:
+		return "gzip"
+	case FormatZstd:
+		return "zstd"
+	case FormatBzip2:
+		return "bzip2"
+	case FormatXz:
+		return "xz"
+	case FormatZip:
+		return "zip"
+	case FormatTiff:
+		return "tiff"
+	case FormatBigTiff:
+		return "bigtiff"
+	case FormatPng:
+		return "png"
+	case FormatJpeg:
+		return "jpeg"
+	case FormatGif:
+		return "gif"
+	case FormatNetCDF:
+		return "netcdf"
+	case FormatHdf5:
+		return "hdf5"
+	default:
+		return "unknown"
+	}
+}
+
+// The number of bytes Detect reads from the start of a file to identify it.
+const detectLength = 16
+
+var formatMagics = []struct {
+	format Format
+	magic  []byte
+}{
+	{FormatGzip, []byte{0x1f, 0x8b}},
+	{FormatZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{FormatBzip2, []byte("BZh")},
+	{FormatXz, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{FormatZip, []byte{'P', 'K', 0x03, 0x04}},
+	{FormatTiff, []byte{'I', 'I', 42, 0}},
+	{FormatTiff, []byte{'M', 'M', 0, 42}},
+	{FormatBigTiff, []byte{'I', 'I', 43, 0}},
+	{FormatBigTiff, []byte{'M', 'M', 0, 43}},
+	{FormatPng, []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}},
+	{FormatJpeg, []byte{0xff, 0xd8, 0xff}},
+	{FormatGif, []byte("GIF87a")},
+	{FormatGif, []byte("GIF89a")},
+	{FormatNetCDF, []byte{'C', 'D', 'F', 0x01}},
+	{FormatNetCDF, []byte{'C', 'D', 'F', 0x02}},
+	{FormatNetCDF, []byte{'C', 'D', 'F', 0x05}},
+	{FormatHdf5, []byte{0x89, 'H', 'D', 'F', '\r', '\n', 0x1a, '\n'}},
+}
+
+// Describes the format of a file as identified by Detect. For Pixi files, the version, byte order,
+// and offset size are filled in from the header when they are valid; Supported reports whether this
+// package is able to read the file, which is false for files written by a newer version of the format
+// or with an invalid header.
+type FormatInfo struct {
+	Format     Format
+	Version    int
+	ByteOrder  binary.ByteOrder
+	OffsetSize OffsetSize
+	Supported  bool
+}
+
+// Identifies the format of a file from its first few bytes. Pixi files are recognized regardless of their
+// version. An error is only returned if reading the start of the file fails; unrecognized files are
+// reported as FormatUnknown.
+func Detect(r io.ReaderAt) (FormatInfo, error) {
+	buf := make([]byte, detectLength)
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return FormatInfo{}, err
+	}
+	buf = buf[:n]
+
+	if bytes.HasPrefix(buf, []byte(FileType)) {
+		return detectPixi(buf), nil
+	}
+	for _, m := range formatMagics {
+		if bytes.HasPrefix(buf, m.magic) {
+			return FormatInfo{Format: m.format}, nil
+		}
+	}
+	return FormatInfo{Format: FormatUnknown}, nil
+}
+
+func detectPixi(buf []byte) FormatInfo {
+	info := FormatInfo{Format: FormatPixi}
+	if len(buf) < 8 {
+		return info
+	}
+	version, err := strconv.ParseInt(string(buf[4:6]), 10, 32)
+	if err != nil {
+		return info
+	}
+	info.Version = int(version)
+	if buf[6] == 4 || buf[6] == 8 {
+		info.OffsetSize = OffsetSize(buf[6])
+	}
+	switch buf[7] {
+	case 0x00:
+		info.ByteOrder = binary.LittleEndian
+	case 0xff:
+		info.ByteOrder = binary.BigEndian
+	}
+	info.Supported = info.Version <= Version && info.OffsetSize != 0 && info.ByteOrder != nil
+	return info
+}
@@ -0,0 +1,78 @@
+package gopixi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestDetectPixi(t *testing.T) {
+	for _, header := range allHeaderVariants(Version) {
+		buf := buffer.NewBuffer(10)
+		err := header.WriteHeader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := Detect(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Format != FormatPixi || !info.Supported {
+			t.Fatalf("expected supported pixi file, got %+v", info)
+		}
+		if info.Version != header.Version || info.OffsetSize != header.OffsetSize || info.ByteOrder != header.ByteOrder {
+			t.Errorf("expected header details %+v, got %+v", header, info)
+		}
+	}
+}
+
+func TestDetectFuturePixiVersion(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.LittleEndian, OffsetSize8)
+	header.Version = Version + 1
+	err := header.WriteHeader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := Detect(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != FormatPixi || info.Supported || info.Version != Version+1 {
+		t.Errorf("expected unsupported future pixi version, got %+v", info)
+	}
+}
+
+func TestDetectOtherFormats(t *testing.T) {
+	gz := new(bytes.Buffer)
+	gzw := gzip.NewWriter(gz)
+	gzw.Write([]byte("content"))
+	gzw.Close()
+
+	cases := []struct {
+		data   []byte
+		format Format
+	}{
+		{gz.Bytes(), FormatGzip},
+		{[]byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, FormatZstd},
+		{[]byte("II*\x00\x08\x00\x00\x00"), FormatTiff},
+		{[]byte("MM\x00+\x00\x08"), FormatBigTiff},
+		{[]byte("\x89PNG\r\n\x1a\n\x00"), FormatPng},
+		{[]byte("CDF\x01\x00"), FormatNetCDF},
+		{[]byte("\x89HDF\r\n\x1a\n"), FormatHdf5},
+		{[]byte("plain text"), FormatUnknown},
+		{[]byte{}, FormatUnknown},
+	}
+	for _, c := range cases {
+		info, err := Detect(bytes.NewReader(c.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Format != c.format {
+			t.Errorf("expected %s for %q, got %s", c.format, c.data, info.Format)
+		}
+	}
+}
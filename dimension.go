@@ -12,12 +12,18 @@ type Dimension struct {
 	Name     string // Friendly name to refer to the dimension in the layer.
 	Size     int    // The total number of elements in the dimension.
 	TileSize int    // The size of the tiles in the dimension. Does not need to be a factor of Size.
-	Axis     *Axis  // Optional axis metadata describing the units and range of this dimension.
+	// The number of samples of each neighboring tile duplicated on either side of every tile in this
+	// dimension, so that stencils near tile edges can be computed from a single tile. See WithHalo.
+	Halo int
+	Axis *Axis // Optional axis metadata describing the units and range of this dimension.
 }
 
 // Get the size in bytes of this dimension description as it is laid out and written to disk.
 func (d Dimension) HeaderSize(h Header) int {
-	size := 2 + len([]byte(d.Name)) + 2*int(h.OffsetSize) // base size: name + size + tileSize
+	size := h.FriendlySize(d.Name) + 2*int(h.OffsetSize) // base size: name + size + tileSize
+	if h.Version >= VersionExtensions {
+		size += int(h.OffsetSize) // halo
+	}
 
 	// Add size for axis fields (includes 4 bytes for type)
 	size += d.Axis.HeaderSize(h)
@@ -45,6 +51,12 @@ func (d Dimension) Write(w io.Writer, h Header) error {
 	if d.Size < d.TileSize {
 		return ErrFormat("dimension tile size cannot be larger than dimension total size")
 	}
+	if d.Halo < 0 || d.Halo > d.TileSize {
+		return ErrFormat("dimension halo must be between 0 and the tile size")
+	}
+	if d.Halo > 0 && h.Version < VersionExtensions {
+		return ErrFormat(fmt.Sprintf("dimension halos require version %d or later", VersionExtensions))
+	}
 
 	// write the name, then size and tile size
 	err := h.WriteFriendly(w, d.Name)
@@ -59,6 +71,16 @@ func (d Dimension) Write(w io.Writer, h Header) error {
 	if err != nil {
 		return err
 	}
+	if h.Version >= VersionExtensions {
+		err = h.WriteOffset(w, int64(d.Halo))
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.Axis != nil && d.Axis.Coordinates != nil && len(d.Axis.Coordinates) != d.Size {
+		return ErrFormat(fmt.Sprintf("dimension '%s' has %d axis coordinates, expected one for each of its %d indices", d.Name, len(d.Axis.Coordinates), d.Size))
+	}
 
 	// Write axis fields using Axis method
 	return d.Axis.Write(w, h)
@@ -86,6 +108,18 @@ func (d *Dimension) Read(r io.Reader, h Header) error {
 	d.Size = int(size)
 	d.TileSize = int(tileSize)
 
+	d.Halo = 0
+	if h.Version >= VersionExtensions {
+		halo, err := h.ReadOffset(r)
+		if err != nil {
+			return err
+		}
+		d.Halo = int(halo)
+		if d.Halo < 0 || d.Halo > d.TileSize {
+			return ErrFormat("dimension halo must be between 0 and the tile size")
+		}
+	}
+
 	// Read the axis type
 	var encodedType ChannelType
 	err = h.Read(r, &encodedType)
@@ -94,9 +128,21 @@ func (d *Dimension) Read(r io.Reader, h Header) error {
 	}
 
 	// Check if axis information is present
-	if encodedType.Base() != ChannelUnknown {
+	if encodedType&axisTypeReferenceFlag != 0 && h.Version >= VersionExtensions {
+		ref, err := h.ReadFriendly(r)
+		if err != nil {
+			return err
+		}
+		d.Axis = &Axis{Type: encodedType.Base(), Ref: ref}
+	} else if encodedType&axisTypeCoordinatesFlag != 0 && h.Version >= VersionExtensions {
+		d.Axis = &Axis{}
+		err = d.Axis.readCoordinates(r, h, encodedType, d.Size)
+		if err != nil {
+			return err
+		}
+	} else if encodedType.Base() != ChannelUnknown {
 		d.Axis = &Axis{}
-		err = d.Axis.Read(r, h, encodedType.Base())
+		err = d.Axis.Read(r, h, encodedType)
 		if err != nil {
 			return err
 		}
@@ -111,5 +157,15 @@ func (d Dimension) String() string {
 	if d.Axis == nil {
 		return fmt.Sprintf("%s(%d / %d)", d.Name, d.Size, d.TileSize)
 	}
-	return fmt.Sprintf("%s(%d / %d) [%v; %v; %s]", d.Name, d.Size, d.TileSize, d.Axis.Minimum, d.Axis.Step, d.Axis.Unit)
+	if d.Axis.Ref != "" && d.Axis.Minimum == nil && d.Axis.Coordinates == nil {
+		return fmt.Sprintf("%s(%d / %d) [axis '%s']", d.Name, d.Size, d.TileSize, d.Axis.Ref)
+	}
+	unit := d.Axis.Unit
+	if d.Axis.Calendar != CalendarStandard {
+		unit += "; " + d.Axis.Calendar.String()
+	}
+	if d.Axis.Coordinates != nil {
+		return fmt.Sprintf("%s(%d / %d) [%d coordinates; %s]", d.Name, d.Size, d.TileSize, len(d.Axis.Coordinates), unit)
+	}
+	return fmt.Sprintf("%s(%d / %d) [%v; %v; %s]", d.Name, d.Size, d.TileSize, d.Axis.Minimum, d.Axis.Step, unit)
 }
@@ -20,7 +20,7 @@ func TestDimensionHeaderSize(t *testing.T) {
 			Size:     rand.Int(),
 			TileSize: rand.Int(),
 		}
-		expectedSize := 2 + nameLen + 2*int(header.OffsetSize) + 4 // +4 for type field
+		expectedSize := 2 + nameLen + 3*int(header.OffsetSize) + 4 // size, tile size, and halo, +4 for type field
 		if dim.HeaderSize(header) != expectedSize {
 			t.Errorf("unexpected dimension header size without axis info: got %d, want %d", dim.HeaderSize(header), expectedSize)
 		}
@@ -38,7 +38,7 @@ func TestDimensionHeaderSize(t *testing.T) {
 				Unit:    unitStr,
 			},
 		}
-		expectedSizeWithAxis := 2 + nameLen + 2*int(header.OffsetSize) + 4 + 2 + len(unitStr) + 4 + 4 // +4 for type, +2+len for unit, +4 for min, +4 for step
+		expectedSizeWithAxis := 2 + nameLen + 3*int(header.OffsetSize) + 4 + 2 + len(unitStr) + 4 + 4 // +4 for type, +2+len for unit, +4 for min, +4 for step
 		if dimWithAxis.HeaderSize(header) != expectedSizeWithAxis {
 			t.Errorf("unexpected dimension header size with axis info: got %d, want %d", dimWithAxis.HeaderSize(header), expectedSizeWithAxis)
 		}
@@ -112,6 +112,16 @@ func (set DimensionSet) TileCoordinates() iter.Seq[TileCoordinate] {
 	}
 }
 
+// The index of the (first) dimension with the given name in the set, or -1 if not found.
+func (set DimensionSet) Index(dimensionName string) int {
+	for i, dim := range set {
+		if dim.Name == dimensionName {
+			return i
+		}
+	}
+	return -1
+}
+
 // Returns true if the given sample coordinate is within the bounds of the dimension set.
 func (set DimensionSet) ContainsCoordinate(coord SampleCoordinate) bool {
 	if len(coord) != len(set) {
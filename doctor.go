@@ -0,0 +1,250 @@
+package gopixi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// The prefix of the tags recording the order of the samples along a named dimension of every layer, as
+// AxisOrderAscending or AxisOrderDescending axis values. For example, the tag "pixi.order.lat" set to
+// "descending" records that rows run from north to south, so a latitude axis with a positive step
+// contradicts the data.
+const AxisOrderTagPrefix string = "pixi.order."
+
+const (
+	AxisOrderAscending  string = "ascending"  // Axis values increase with the sample index.
+	AxisOrderDescending string = "descending" // Axis values decrease with the sample index.
+)
+
+// A machine-readable identifier of the kind of inconsistency a Diagnosis reports.
+type DiagnosisCode string
+
+const (
+	DiagnosisTruncatedTile    DiagnosisCode = "truncated-tile"    // A tile extends past the end of the file, as after an interrupted write.
+	DiagnosisUnwrittenExtent  DiagnosisCode = "unwritten-extent"  // The last dimension extends past the last tiles written to it.
+	DiagnosisAxisOrder        DiagnosisCode = "axis-order"        // The sign of an axis step contradicts the recorded order of the samples.
+	DiagnosisStaleStatistics  DiagnosisCode = "stale-statistics"  // The Min/Max statistics of a channel do not match its tiles.
+	DiagnosisChecksumMismatch DiagnosisCode = "checksum-mismatch" // A tile does not match its checksum.
+)
+
+// An inconsistency found by Diagnose or Doctor, along with the repair that fixes it. Inconsistencies that
+// cannot be repaired from the metadata and tiles of the file alone, such as tiles failing their checksums,
+// have no repair.
+type Diagnosis struct {
+	Code    DiagnosisCode `json:"code"`
+	Layer   string        `json:"layer"`
+	Problem string        `json:"problem"`
+	// The change that fixes the problem, or that fixed it if Repaired is true.
+	Repair   string `json:"repair,omitempty"`
+	Repaired bool   `json:"repaired"`
+}
+
+func (d Diagnosis) String() string {
+	s := fmt.Sprintf("%s: layer '%s': %s", d.Code, d.Layer, d.Problem)
+	switch {
+	case d.Repaired:
+		s += fmt.Sprintf(" (repaired: %s)", d.Repair)
+	case d.Repair != "":
+		s += fmt.Sprintf(" (repair: %s)", d.Repair)
+	}
+	return s
+}
+
+// Checks every layer of the file for inconsistencies between its metadata and its tiles, reading all of it,
+// and returns a diagnosis of each one found without changing anything.
+func (p *Pixi) Diagnose(r io.ReadSeeker) ([]Diagnosis, error) {
+	return p.doctor(r, nil)
+}
+
+// Checks every layer of the file for inconsistencies as Diagnose does, and repairs each one that can be
+// repaired by rewriting the layer header, leaving tile data unchanged. Returns every diagnosis, with Repaired
+// set for those that were repaired.
+func (p *Pixi) Doctor(rw io.ReadWriteSeeker) ([]Diagnosis, error) {
+	if p.ReadOnly {
+		return nil, ErrReadOnly{Operation: "doctor"}
+	}
+	return p.doctor(rw, rw)
+}
+
+func (p *Pixi) doctor(r io.ReadSeeker, w io.WriteSeeker) ([]Diagnosis, error) {
+	fileEnd, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	tags := p.AllTags()
+	diagnoses := []Diagnosis{}
+	for layerIndex, original := range p.Layers {
+		layer := original
+		layer.TileBytes = slices.Clone(original.TileBytes)
+		layer.TileOffsets = slices.Clone(original.TileOffsets)
+		layer.StringBytes = slices.Clone(original.StringBytes)
+		layer.Dimensions = slices.Clone(original.Dimensions)
+		layer.Channels = slices.Clone(original.Channels)
+		found := []Diagnosis{}
+		report := func(code DiagnosisCode, repair string, format string, args ...any) {
+			found = append(found, Diagnosis{Code: code, Layer: layer.Name, Problem: fmt.Sprintf(format, args...), Repair: repair})
+		}
+
+		for tile, bytes := range layer.TileBytes {
+			if bytes != 0 && layer.TileOffsets[tile]+bytes+4 > fileEnd {
+				report(DiagnosisTruncatedTile, "mark the tile unwritten",
+					"tile %d ends at byte %d, past the end of the file at %d", tile, layer.TileOffsets[tile]+bytes+4, fileEnd)
+				layer.TileBytes[tile], layer.TileOffsets[tile] = 0, 0
+			}
+		}
+
+		if !layer.Channels.HasFillValues() {
+			if shrunk, ok := layer.writtenExtent(); ok {
+				last := len(layer.Dimensions) - 1
+				dim := layer.Dimensions[last]
+				report(DiagnosisUnwrittenExtent, fmt.Sprintf("shrink dimension '%s' to size %d", dim.Name, shrunk.Dimensions[last].Size),
+					"dimension '%s' has size %d but no tiles are written beyond sample %d", dim.Name, dim.Size, shrunk.Dimensions[last].Size)
+				layer = shrunk
+			}
+		}
+
+		for d, dim := range layer.Dimensions {
+			order, ok := tags[AxisOrderTagPrefix+dim.Name]
+			if !ok {
+				continue
+			}
+			_, step, regular := dim.regularAxis()
+			if !regular || (order == AxisOrderAscending) == (step > 0) {
+				continue
+			}
+			reversed, ok := dim.Axis.reversed(dim.Size)
+			repair := ""
+			if ok {
+				repair = fmt.Sprintf("start the axis at %v with step %v", reversed.Minimum, reversed.Step)
+				layer.Dimensions[d].Axis = reversed
+			}
+			report(DiagnosisAxisOrder, repair, "axis of dimension '%s' has step %v but the file records %s order", dim.Name, dim.Axis.Step, order)
+		}
+
+		stats, mismatches, err := layer.tileStatistics(r, p.Header)
+		if err != nil {
+			return nil, err
+		}
+		for _, tile := range mismatches {
+			report(DiagnosisChecksumMismatch, "", "tile %d does not match its checksum", tile)
+		}
+		if len(mismatches) == 0 {
+			for c, channel := range layer.Channels {
+				want := stats[c]
+				if (channel.Min == nil && channel.Max == nil) || want.Min == nil {
+					continue
+				}
+				if channel.Min != nil && channel.Type.CompareValues(channel.Min, want.Min) == 0 &&
+					channel.Max != nil && channel.Type.CompareValues(channel.Max, want.Max) == 0 {
+					continue
+				}
+				report(DiagnosisStaleStatistics, fmt.Sprintf("set the statistics to %v to %v", want.Min, want.Max),
+					"channel '%s' has statistics %v to %v but its tiles hold %v to %v", channel.Name, channel.Min, channel.Max, want.Min, want.Max)
+				layer.Channels[c].Min, layer.Channels[c].Max = want.Min, want.Max
+			}
+		}
+
+		repairable := slices.ContainsFunc(found, func(d Diagnosis) bool { return d.Repair != "" })
+		if w != nil && repairable {
+			if err := p.UpdateLayerHeader(w, layerIndex, layer); err != nil {
+				return nil, err
+			}
+			for i := range found {
+				found[i].Repaired = found[i].Repair != ""
+			}
+		}
+		diagnoses = append(diagnoses, found...)
+	}
+	return diagnoses, nil
+}
+
+// Returns a copy of the layer with its last dimension shrunk to end with the last tiles written along it,
+// and true, if tiles after them along the last dimension are all unwritten. Layers with no written tiles
+// are left as they are.
+func (l Layer) writtenExtent() (Layer, bool) {
+	dims := l.Dimensions
+	last := len(dims) - 1
+	tiles := dims.Tiles()
+	slab := tiles / dims[last].Tiles() // the number of tiles sharing a tile coordinate of the last dimension
+	written := -1
+	for tile, bytes := range l.TileBytes {
+		if bytes != 0 {
+			written = max(written, tile%tiles/slab)
+		}
+	}
+	if written < 0 || written+1 == dims[last].Tiles() {
+		return l, false
+	}
+
+	shrunk := l
+	shrunk.Dimensions = slices.Clone(dims)
+	shrunk.Dimensions[last].Size = (written + 1) * dims[last].TileSize
+	kept := shrunk.Dimensions.Tiles()
+	shrunk.TileBytes, shrunk.TileOffsets = []int64{}, []int64{}
+	shrunk.StringBytes = shrunk.emptyStringBytes()[:0]
+	for start := 0; start < len(l.TileBytes); start += tiles {
+		shrunk.TileBytes = append(shrunk.TileBytes, l.TileBytes[start:start+kept]...)
+		shrunk.TileOffsets = append(shrunk.TileOffsets, l.TileOffsets[start:start+kept]...)
+		if l.StringBytes != nil {
+			shrunk.StringBytes = append(shrunk.StringBytes, l.StringBytes[start:start+kept]...)
+		}
+	}
+	return shrunk, true
+}
+
+// Returns the axis running over the same values in the opposite order along a dimension of the given size,
+// and true, unless its type cannot hold the negated step.
+func (a *Axis) reversed(size int) (*Axis, bool) {
+	switch a.Type.Base() {
+	case ChannelUint8, ChannelUint16, ChannelUint32, ChannelUint64, ChannelBool:
+		return nil, false
+	}
+	step, ok := a.Type.ToFloat64(a.Step)
+	if !ok {
+		return nil, false
+	}
+	reversed := *a
+	reversed.Minimum = a.StepValue(size - 1)
+	reversed.Step = a.Type.FromFloat64(-step)
+	return &reversed, true
+}
+
+// Computes the Min/Max statistics and moments of every channel from the written tiles of the layer (and, for
+// Min/Max, the fill values of its unwritten tiles), verifying each tile against its checksum. Returns the
+// channels holding the statistics, without histograms, and the indices of the tiles that failed their
+// checksums, which are left out.
+func (l Layer) tileStatistics(r io.ReadSeeker, h Header) (ChannelSet, []int, error) {
+	stats := l
+	stats.Channels = slices.Clone(l.Channels)
+	for c := range stats.Channels {
+		stats.Channels[c].Min, stats.Channels[c].Max = nil, nil
+		stats.Channels[c].clearDistribution()
+	}
+	mismatches := []int{}
+	for tile, bytes := range l.TileBytes {
+		if bytes == 0 {
+			if l.Channels.HasFillValues() {
+				for c, value := range l.Channels.FillSample() {
+					if !l.Separated || tile/l.Dimensions.Tiles() == c {
+						stats.Channels[c] = stats.Channels[c].WithMinMax(value)
+					}
+				}
+			}
+			continue
+		}
+		data := make([]byte, l.DiskTileSize(tile))
+		err := l.ReadTile(r, h, tile, data)
+		var integrity ErrDataIntegrity
+		if errors.As(err, &integrity) {
+			mismatches = append(mismatches, tile)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		stats.updateTileStatistics(h, tile, data)
+	}
+	return stats.Channels, mismatches, nil
+}
@@ -0,0 +1,178 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func writeDoctorFile(t *testing.T, opts ...LayerOption) *MemoryFile {
+	t.Helper()
+	layer := NewLayer("grid", DimensionSet{
+		{Name: "lon", Size: 4, TileSize: 2, Axis: &Axis{Type: ChannelFloat64, Minimum: -10.0, Step: 0.5}},
+		{Name: "lat", Size: 6, TileSize: 2, Axis: &Axis{Type: ChannelFloat64, Minimum: 40.0, Step: 0.5}},
+	}, ChannelSet{{Name: "height", Type: ChannelInt16}, {Name: "valid", Type: ChannelBool}}, opts...)
+	buf := buffer.NewBuffer(10)
+	writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{layer}, func(_ int, c SampleCoordinate) Sample {
+		return Sample{int16(c[0] + 10*c[1]), c[0] != c[1]}
+	})
+	file, err := FromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func diagnosisCodes(diagnoses []Diagnosis) []DiagnosisCode {
+	codes := []DiagnosisCode{}
+	for _, d := range diagnoses {
+		codes = append(codes, d.Code)
+	}
+	return codes
+}
+
+// Diagnoses the file, then doctors it and checks that it reads back without any remaining problems.
+func doctorAndReread(t *testing.T, file *MemoryFile, expected []DiagnosisCode) ([]Diagnosis, *MemoryFile) {
+	t.Helper()
+	diagnosed, err := file.Diagnose(file.Stream())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(diagnosisCodes(diagnosed), expected) {
+		t.Fatalf("expected diagnoses %v, got %v", expected, diagnosed)
+	}
+	if slices.ContainsFunc(diagnosed, func(d Diagnosis) bool { return d.Repaired }) {
+		t.Errorf("diagnosing reported repairs: %v", diagnosed)
+	}
+	repaired, err := file.Doctor(file.Stream())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range repaired {
+		if !d.Repaired {
+			t.Errorf("expected the problem to be repaired: %v", d)
+		}
+	}
+
+	reread, err := FromBytes(file.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining, err := reread.Diagnose(reread.Stream()); err != nil || len(remaining) != 0 {
+		t.Errorf("expected no problems after repair, got %v (%v)", remaining, err)
+	}
+	return repaired, reread
+}
+
+func TestDoctorStaleStatistics(t *testing.T) {
+	for _, opts := range [][]LayerOption{nil, {WithPlanar()}} {
+		file := writeDoctorFile(t, opts...)
+		stale := file.Layers[0]
+		stale.Channels = slices.Clone(stale.Channels)
+		stale.Channels[0].Max = int16(1000)
+		if err := file.UpdateLayerHeader(file.Stream(), 0, stale); err != nil {
+			t.Fatal(err)
+		}
+		_, reread := doctorAndReread(t, file, []DiagnosisCode{DiagnosisStaleStatistics})
+		if max := reread.Layers[0].Channels[0].Max; max != int16(53) {
+			t.Errorf("expected the maximum to be repaired to 53, got %v", max)
+		}
+	}
+}
+
+func TestDoctorUnwrittenExtent(t *testing.T) {
+	for _, opts := range [][]LayerOption{nil, {WithPlanar()}} {
+		file := writeDoctorFile(t, opts...)
+		layer := file.Layers[0]
+		// declare another slab of rows along the last dimension that was never written
+		extended := layer
+		extended.Dimensions = slices.Clone(layer.Dimensions)
+		extended.Dimensions[1].Size = 9
+		extended.TileBytes, extended.TileOffsets = []int64{}, []int64{}
+		for start := 0; start < len(layer.TileBytes); start += layer.Dimensions.Tiles() {
+			end := start + layer.Dimensions.Tiles()
+			extended.TileBytes = append(append(extended.TileBytes, layer.TileBytes[start:end]...), 0, 0, 0, 0)
+			extended.TileOffsets = append(append(extended.TileOffsets, layer.TileOffsets[start:end]...), 0, 0, 0, 0)
+		}
+		if err := file.UpdateLayerHeader(file.Stream(), 0, extended); err != nil {
+			t.Fatal(err)
+		}
+
+		_, reread := doctorAndReread(t, file, []DiagnosisCode{DiagnosisUnwrittenExtent})
+		if size := reread.Layers[0].Dimensions[1].Size; size != 6 {
+			t.Errorf("expected the last dimension to shrink back to 6, got %d", size)
+		}
+		values, err := reread.Layer(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sample, err := SampleAt(values, SampleCoordinate{3, 5}); err != nil || sample[0] != int16(53) || sample[1] != true {
+			t.Errorf("expected the last sample to read as [53 true], got %v (%v)", sample, err)
+		}
+	}
+}
+
+func TestDoctorTruncatedTile(t *testing.T) {
+	file := writeDoctorFile(t)
+	// an index recording more of the last tile than was ever written, as when a write is interrupted
+	truncated := file.Layers[0]
+	truncated.TileBytes = slices.Clone(truncated.TileBytes)
+	truncated.TileBytes[len(truncated.TileBytes)-1] = 1 << 20
+	if err := file.UpdateLayerHeader(file.Stream(), 0, truncated); err != nil {
+		t.Fatal(err)
+	}
+	repaired, reread := doctorAndReread(t, file, []DiagnosisCode{DiagnosisTruncatedTile, DiagnosisStaleStatistics})
+	if repaired[0].Layer != "grid" {
+		t.Errorf("expected the diagnosis to name the layer, got %v", repaired[0])
+	}
+	if stored := storedTiles(reread.Layers[0]); stored != reread.Layers[0].DiskTiles()-1 {
+		t.Errorf("expected the truncated tile to be marked unwritten, %d of %d tiles are stored", stored, reread.Layers[0].DiskTiles())
+	}
+}
+
+func TestDoctorAxisOrder(t *testing.T) {
+	file := writeDoctorFile(t)
+	if err := file.AppendTags(file.Stream(), map[string]string{AxisOrderTagPrefix + "lat": AxisOrderDescending, AxisOrderTagPrefix + "lon": AxisOrderAscending}); err != nil {
+		t.Fatal(err)
+	}
+	_, reread := doctorAndReread(t, file, []DiagnosisCode{DiagnosisAxisOrder})
+	axis := reread.Layers[0].Dimensions[1].Axis
+	if axis.Minimum != 42.5 || axis.Step != -0.5 {
+		t.Errorf("expected the latitude axis to run from 42.5 by -0.5, got %v by %v", axis.Minimum, axis.Step)
+	}
+	if lon := reread.Layers[0].Dimensions[0].Axis; lon.Minimum != -10.0 || lon.Step != 0.5 {
+		t.Errorf("expected the longitude axis to be left alone, got %v by %v", lon.Minimum, lon.Step)
+	}
+}
+
+func TestDoctorChecksumMismatch(t *testing.T) {
+	file := writeDoctorFile(t)
+	stale := file.Layers[0]
+	stale.Channels = slices.Clone(stale.Channels)
+	stale.Channels[0].Max = int16(1000)
+	if err := file.UpdateLayerHeader(file.Stream(), 0, stale); err != nil {
+		t.Fatal(err)
+	}
+	file.Bytes()[file.Layers[0].TileOffsets[1]] ^= 0xff
+
+	diagnoses, err := file.Doctor(file.Stream())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// statistics are not checked against tiles that cannot be trusted
+	if codes := diagnosisCodes(diagnoses); !slices.Equal(codes, []DiagnosisCode{DiagnosisChecksumMismatch}) || diagnoses[0].Repaired {
+		t.Errorf("expected an unrepaired checksum mismatch, got %v", diagnoses)
+	}
+	if file.Layers[0].Channels[0].Max != int16(1000) {
+		t.Error("expected the layer to be left alone")
+	}
+
+	file.ReadOnly = true
+	var readOnly ErrReadOnly
+	if _, err := file.Doctor(file.Stream()); !errors.As(err, &readOnly) {
+		t.Errorf("expected ErrReadOnly doctoring a read-only file, got %v", err)
+	}
+}
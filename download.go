@@ -0,0 +1,202 @@
+package gopixi
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// One step of a DownloadPlan: the tiles to fetch from one resolution level of a layer.
+type DownloadStep struct {
+	Layer int // The index of the layer of the level in the file.
+	// The size of a sample of the level in samples of the requested layer, in each dimension, such as 4 for a
+	// level downsampled by a factor of four. The requested layer has a scale of one.
+	Scale []float64
+	// The tiles to fetch from the level, for the region of the level covering the requested view. The Tiles
+	// and Ranges of a partial step are the subset of the tiles covering the view nearest its center.
+	Plan     ReadPlan
+	Complete bool // Whether the step covers the whole view, rather than just the part nearest its center.
+}
+
+// The steps of fetching a view of a layer from a remote file within a budget of bytes, from the coarsest
+// resolution level to the finest, as planned by PlanDownload. Fetching and displaying each step in turn gives
+// a progressively more detailed view.
+type DownloadPlan struct {
+	Budget int64
+	Steps  []DownloadStep
+}
+
+// The total number of bytes fetched by every step of the plan.
+func (p DownloadPlan) Bytes() int64 {
+	total := int64(0)
+	for _, step := range p.Steps {
+		total += step.Plan.Bytes()
+	}
+	return total
+}
+
+// The finest step of the plan, with the most detailed view of the region, or false if the plan has no steps.
+func (p DownloadPlan) Finest() (DownloadStep, bool) {
+	if len(p.Steps) == 0 {
+		return DownloadStep{}, false
+	}
+	return p.Steps[len(p.Steps)-1], true
+}
+
+func (p DownloadPlan) String() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "download %d of %d bytes in %d steps", p.Bytes(), p.Budget, len(p.Steps))
+	for _, step := range p.Steps {
+		coverage := "complete"
+		if !step.Complete {
+			coverage = "partial"
+		}
+		fmt.Fprintf(&b, "\n  layer %d at scale %v (%s): %v", step.Layer, step.Scale, coverage, step.Plan)
+	}
+	return b.String()
+}
+
+// The resolution levels of a layer: the layer itself, and every other layer of the file with the same
+// dimensions (by name and order) and at least the same channels that is smaller, but no larger in any
+// dimension, such as the embedded preview or layers derived with AppendCoarsen. Levels are ordered from
+// coarsest to finest.
+func (p *Pixi) resolutionLevels(layerIndex int) []int {
+	target := p.Layers[layerIndex]
+	levels := []int{}
+	for i, layer := range p.Layers {
+		if len(layer.Dimensions) != len(target.Dimensions) || (i != layerIndex && layer.Dimensions.Samples() >= target.Dimensions.Samples()) {
+			continue
+		}
+		matches := true
+		for d, dim := range layer.Dimensions {
+			if dim.Name != target.Dimensions[d].Name || dim.Size > target.Dimensions[d].Size {
+				matches = false
+			}
+		}
+		for _, channel := range target.Channels {
+			if layer.Channels.Index(channel.Name) < 0 {
+				matches = false
+			}
+		}
+		if matches {
+			levels = append(levels, i)
+		}
+	}
+	slices.SortStableFunc(levels, func(a, b int) int {
+		return p.Layers[a].Dimensions.Samples() - p.Layers[b].Dimensions.Samples()
+	})
+	return levels
+}
+
+// Plans which resolution levels of a layer, and which of their tiles, to fetch to best show a view (a region
+// of the layer) within the budget of bytes. Levels are fetched whole from the coarsest while they fit, and
+// the first that does not is fetched in part from the center of the view. The options select channels as for
+// ExplainRead. A plan with no steps means that no tile of the coarsest level fits within the budget.
+func (p *Pixi) PlanDownload(layerIndex int, view Region, budget int64, opts ...ReadOption) (DownloadPlan, error) {
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return DownloadPlan{}, ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	target := p.Layers[layerIndex]
+	if err := view.Validate(target.Dimensions); err != nil {
+		return DownloadPlan{}, err
+	}
+
+	plan := DownloadPlan{Budget: budget}
+	remaining := budget
+	for _, level := range p.resolutionLevels(layerIndex) {
+		layer := p.Layers[level]
+		scale := make([]float64, len(layer.Dimensions))
+		region := Region{Start: make(SampleCoordinate, len(layer.Dimensions)), End: make(SampleCoordinate, len(layer.Dimensions))}
+		for d, dim := range layer.Dimensions {
+			scale[d] = float64(target.Dimensions[d].Size) / float64(dim.Size)
+			region.Start[d] = min(int(float64(view.Start[d])/scale[d]), dim.Size-1)
+			region.End[d] = max(min(int(math.Ceil(float64(view.End[d])/scale[d])), dim.Size), region.Start[d]+1)
+		}
+		full, err := layer.ExplainRead(p.Header, region, opts...)
+		if err != nil {
+			return DownloadPlan{}, err
+		}
+		if full.Bytes() <= remaining {
+			plan.Steps = append(plan.Steps, DownloadStep{Layer: level, Scale: scale, Plan: full, Complete: true})
+			remaining -= full.Bytes()
+			continue
+		}
+		if partial, ok := layer.partialDownload(p.Header, full, remaining); ok {
+			plan.Steps = append(plan.Steps, DownloadStep{Layer: level, Scale: scale, Plan: partial})
+		}
+		break
+	}
+	return plan, nil
+}
+
+// Narrows the plan for reading a region of the layer to the tiles nearest the center of the region whose
+// bytes fit within the budget, taking the channel tiles of each position of a separated layer together.
+// Returns false if no tile fits.
+func (l Layer) partialDownload(h Header, full ReadPlan, budget int64) (ReadPlan, bool) {
+	dims := l.Dimensions
+	center := make([]float64, len(dims))
+	for d := range dims {
+		center[d] = float64(full.Region.Start[d]+full.Region.End[d]) / 2
+	}
+	distance := func(position int) float64 {
+		tile := TileSelector{Tile: position}.ToTileCoordinate(dims).Tile
+		total := 0.0
+		for d, dim := range dims {
+			offset := (float64(tile[d]*dim.TileSize) + float64(min(dim.TileSize, dim.Size-tile[d]*dim.TileSize))/2 - center[d]) / float64(dim.Size)
+			total += offset * offset
+		}
+		return total
+	}
+
+	byPosition := map[int][]int{}
+	for _, tile := range full.Tiles {
+		if !slices.Contains(full.Missing, tile) {
+			byPosition[tile%dims.Tiles()] = append(byPosition[tile%dims.Tiles()], tile)
+		}
+	}
+	positions := make([]int, 0, len(byPosition))
+	for position := range byPosition {
+		positions = append(positions, position)
+	}
+	slices.Sort(positions)
+	slices.SortStableFunc(positions, func(a, b int) int {
+		if da, db := distance(a), distance(b); da < db {
+			return -1
+		} else if da > db {
+			return 1
+		}
+		return 0
+	})
+
+	partial := ReadPlan{Region: full.Region}
+	spent := int64(0)
+	for _, position := range positions {
+		cost := int64(0)
+		for _, tile := range byPosition[position] {
+			cost += l.TileRange(h, tile).Length
+		}
+		if spent+cost > budget {
+			break
+		}
+		spent += cost
+		partial.Tiles = append(partial.Tiles, byPosition[position]...)
+		partial.DecodedSamples += dims.TileSamples()
+		tile, overlap := TileSelector{Tile: position}.ToTileCoordinate(dims).Tile, 1
+		for d, dim := range dims {
+			start, end := tile[d]*dim.TileSize, (tile[d]+1)*dim.TileSize
+			overlap *= min(end, full.Region.End[d]) - max(start, full.Region.Start[d])
+		}
+		partial.Samples += overlap
+	}
+	if len(partial.Tiles) == 0 {
+		return ReadPlan{}, false
+	}
+	slices.Sort(partial.Tiles)
+	for _, tile := range partial.Tiles {
+		partial.Ranges = append(partial.Ranges, l.TileRange(h, tile))
+	}
+	slices.SortFunc(partial.Ranges, func(a, b ByteRange) int { return int(a.Offset - b.Offset) })
+	partial.Ranges = coalesceRanges(partial.Ranges)
+	return partial, true
+}
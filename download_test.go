@@ -0,0 +1,101 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestPlanDownload(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dims := DimensionSet{{Name: "x", Size: 64, TileSize: 16}, {Name: "y", Size: 64, TileSize: 16}}
+	layer := NewLayer("full", dims, ChannelSet{{Name: "v", Type: ChannelUint16}}, WithCompression(CompressionFlate))
+	random := rand.New(rand.NewPCG(3, 4))
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{uint16(random.IntN(1000))})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, factor := range []int{4, 2} {
+		if err := file.AppendCoarsen(file.Stream(), "coarse", LayerOperand(source, nil), "x", factor, AggregateMean, nil); err != nil {
+			t.Fatal(err)
+		}
+		coarsened, err := file.Layer(len(file.Layers) - 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := file.AppendCoarsen(file.Stream(), "coarse", LayerOperand(coarsened, nil), "y", factor, AggregateMean, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// layers 2 (16x16) and 4 (32x32) are coarsened along both dimensions, and layers 1 (16x64) and 3 (32x64)
+	// only along x, all of them levels of the full layer ordered by their number of samples
+	view := Region{Start: SampleCoordinate{0, 0}, End: SampleCoordinate{64, 64}}
+	everything, err := file.PlanDownload(0, view, 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := []int{}
+	for _, step := range everything.Steps {
+		if !step.Complete {
+			t.Errorf("expected every step to be complete with an unlimited budget, got %v", everything)
+		}
+		layers = append(layers, step.Layer)
+	}
+	if !slices.Equal(layers, []int{2, 1, 4, 3, 0}) || everything.Steps[0].Scale[0] != 4 || everything.Steps[4].Scale[0] != 1 {
+		t.Fatalf("unexpected steps %v", everything)
+	}
+
+	// with a budget for the coarsest level and four tiles of the full layer, those nearest the center are planned
+	coarsest := everything.Steps[0].Plan.Bytes()
+	fullLayer := file.Layers[0]
+	centerTiles := []int{5, 6, 9, 10}
+	budget := coarsest
+	for _, tile := range centerTiles {
+		budget += fullLayer.TileRange(file.Header, tile).Length
+	}
+	subset := &Pixi{Header: file.Header, Layers: []Layer{file.Layers[0], file.Layers[2]}}
+	plan, err := subset.PlanDownload(0, view, budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != 2 || !plan.Steps[0].Complete || plan.Steps[1].Complete || plan.Bytes() > budget {
+		t.Fatalf("unexpected plan %v for budget %d", plan, budget)
+	}
+	if finest, _ := plan.Finest(); !slices.Equal(finest.Plan.Tiles, centerTiles) || finest.Plan.Samples != 4*16*16 {
+		t.Errorf("expected the partial step to fetch the center tiles %v, got %v", centerTiles, finest.Plan)
+	}
+
+	// a view of one corner scales onto each level
+	corner := Region{Start: SampleCoordinate{0, 0}, End: SampleCoordinate{10, 10}}
+	plan, err = subset.PlanDownload(0, corner, 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region := plan.Steps[0].Plan.Region; !slices.Equal(region.End, SampleCoordinate{3, 3}) {
+		t.Errorf("expected the corner to scale to [0, 3) on the coarsest level, got %v", region)
+	}
+	if tiles := plan.Steps[1].Plan.Tiles; !slices.Equal(tiles, []int{0}) {
+		t.Errorf("expected the corner to need only the first tile of the full layer, got %v", tiles)
+	}
+
+	if plan, err := file.PlanDownload(0, view, 1); err != nil || len(plan.Steps) != 0 {
+		t.Errorf("expected no steps for a tiny budget, got %v (%v)", plan, err)
+	}
+	if _, err := file.PlanDownload(5, view, 1); err == nil {
+		t.Error("expected error for a missing layer")
+	}
+}
@@ -0,0 +1,334 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// Appends a new channel to an existing layer by writing the affected tiles to the end of the file and
+// updating the layer header. The value of the channel at each sample coordinate is obtained from the values
+// function, or is zero if values is nil, in which case tiles that were never written are left absent.
+func (p *Pixi) AddChannel(rw io.ReadWriteSeeker, layerIndex int, channel Channel, values func(coord SampleCoordinate) any) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "add channel"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	oldLayer := p.Layers[layerIndex]
+	if oldLayer.Channels.Index(channel.Name) >= 0 {
+		return ErrFormat(fmt.Sprintf("channel '%s' already exists in layer '%s'", channel.Name, oldLayer.Name))
+	}
+	if channel.Type.Base() == ChannelUnknown {
+		return ErrFormat("cannot add a channel of unknown type")
+	}
+	if channel.Type.Base() == ChannelString {
+		return ErrUnsupported("adding string channels to existing layers")
+	}
+	if oldLayer.Channels.HasStrings() && !oldLayer.Separated {
+		return ErrUnsupported("adding channels to contiguous layers with string channels")
+	}
+
+	channel.Type = channel.Type.Base()
+	channel.clearDistribution()
+	newLayer := oldLayer
+	newLayer.Channels = append(slices.Clone(oldLayer.Channels), channel)
+	newChannelIndex := len(newLayer.Channels) - 1
+	tiles := newLayer.Dimensions.Tiles()
+
+	if oldLayer.Separated {
+		newLayer.TileBytes = append(slices.Clone(oldLayer.TileBytes), make([]int64, tiles)...)
+		newLayer.TileOffsets = append(slices.Clone(oldLayer.TileOffsets), make([]int64, tiles)...)
+		if oldLayer.StringBytes != nil {
+			newLayer.StringBytes = append(slices.Clone(oldLayer.StringBytes), make([]int64, tiles)...)
+		}
+		for tile := range tiles {
+			diskTile := tile + tiles*newChannelIndex
+			tileData := make([]byte, newLayer.DiskTileSize(diskTile))
+			if values != nil {
+				newLayer.forEachTileSample(tile, func(inTile int, coord SampleCoordinate) {
+					value := values(coord)
+					newLayer.Channels[newChannelIndex] = newLayer.Channels[newChannelIndex].withStatistics(value)
+					if channel.Type == ChannelBool {
+						PackBool(value.(bool), tileData, inTile)
+					} else {
+						channel.PutValue(value, p.Header.ByteOrder, tileData[inTile*channel.Size():])
+					}
+				})
+				newLayer.forEachHaloSample(tile, func(slot int, coord SampleCoordinate) {
+					sample := make(Sample, len(newLayer.Channels))
+					sample[newChannelIndex] = values(coord)
+					newLayer.putStoredSample(p.Header, tileData, newChannelIndex, newLayer.Dimensions.TileSamples()+slot, sample)
+				})
+			}
+			if err := newLayer.appendTile(rw, p.Header, diskTile, tileData); err != nil {
+				return err
+			}
+		}
+	} else {
+		newLayer.TileBytes = make([]int64, tiles)
+		newLayer.TileOffsets = make([]int64, tiles)
+		oldSampleSize := oldLayer.Channels.Size()
+		newSampleSize := newLayer.Channels.Size()
+		for tile := range tiles {
+			var oldData []byte
+			if oldLayer.TileBytes[tile] != 0 {
+				oldData = make([]byte, oldLayer.DiskTileSize(tile))
+				if err := oldLayer.ReadTile(rw, p.Header, tile, oldData); err != nil {
+					return err
+				}
+			} else if values == nil {
+				continue
+			} else {
+				fill, err := oldLayer.FillTile(p.Header, tile, oldLayer.Channels.FillSample())
+				if err != nil {
+					return err
+				}
+				oldData = fill
+			}
+			tileData := make([]byte, newLayer.DiskTileSize(tile))
+			for stored := range newLayer.Dimensions.TileSamples() + newLayer.Dimensions.HaloSamples() {
+				copy(tileData[stored*newSampleSize:], oldData[stored*oldSampleSize:(stored+1)*oldSampleSize])
+			}
+			if values != nil {
+				newLayer.forEachTileSample(tile, func(inTile int, coord SampleCoordinate) {
+					value := values(coord)
+					newLayer.Channels[newChannelIndex] = newLayer.Channels[newChannelIndex].withStatistics(value)
+					channel.PutValue(value, p.Header.ByteOrder, tileData[inTile*newSampleSize+oldSampleSize:])
+				})
+				newLayer.forEachHaloSample(tile, func(slot int, coord SampleCoordinate) {
+					stored := newLayer.Dimensions.TileSamples() + slot
+					channel.PutValue(values(coord), p.Header.ByteOrder, tileData[stored*newSampleSize+oldSampleSize:])
+				})
+			}
+			if err := newLayer.appendTile(rw, p.Header, tile, tileData); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.UpdateLayerHeader(rw, layerIndex, newLayer)
+}
+
+// Calls the given function for every sample of the (non-separated) tile that lies within the bounds
+// of the layer, passing the index of the sample within the tile and its coordinate in the layer.
+func (l Layer) forEachTileSample(tile int, f func(inTile int, coord SampleCoordinate)) {
+	for inTile := range l.Dimensions.TileSamples() {
+		coord := TileSelector{Tile: tile, InTile: inTile}.
+			ToTileCoordinate(l.Dimensions).
+			ToSampleCoordinate(l.Dimensions)
+		if l.Dimensions.ContainsCoordinate(coord) {
+			f(inTile, coord)
+		}
+	}
+}
+
+// Writes the tile to the end of the stream, updating the tile offset and byte count in the layer.
+func (l Layer) appendTile(w io.WriteSeeker, h Header, tileIndex int, data []byte) error {
+	_, err := w.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	return l.WriteTile(w, h, tileIndex, data)
+}
+
+// Renames a channel of an existing layer. Only the layer header is rewritten; tile data is untouched.
+func (p *Pixi) RenameChannel(w io.WriteSeeker, layerIndex int, oldName string, newName string) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "rename channel"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	layer, err := p.Layers[layerIndex].renamedChannel(oldName, newName)
+	if err != nil {
+		return err
+	}
+	return p.UpdateLayerHeader(w, layerIndex, layer)
+}
+
+// A copy of the layer with the named channel renamed.
+func (l Layer) renamedChannel(oldName string, newName string) (Layer, error) {
+	channelIndex := l.Channels.Index(oldName)
+	if channelIndex < 0 {
+		return l, ErrChannelNotFound{ChannelName: oldName}
+	}
+	if oldName != newName && l.Channels.Index(newName) >= 0 {
+		return l, ErrFormat(fmt.Sprintf("channel '%s' already exists in layer '%s'", newName, l.Name))
+	}
+	l.Channels = slices.Clone(l.Channels)
+	l.Channels[channelIndex].Name = newName
+	return l, nil
+}
+
+// Removes a channel from an existing layer. For separated layers this only rewrites the layer header,
+// leaving the tiles of the dropped channel as unreferenced space in the file. For contiguous layers the
+// tiles of the layer are rewritten without the dropped channel at the end of the file. In both cases
+// the space of the dead tiles is reclaimed when the file is compacted with Compact.
+func (p *Pixi) DropChannel(rw io.ReadWriteSeeker, layerIndex int, name string) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "drop channel"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	oldLayer := p.Layers[layerIndex]
+	channelIndex := oldLayer.Channels.Index(name)
+	if channelIndex < 0 {
+		return ErrChannelNotFound{ChannelName: name}
+	}
+	if len(oldLayer.Channels) == 1 {
+		return ErrFormat("cannot drop the only channel of a layer")
+	}
+	if oldLayer.Channels.HasStrings() && !oldLayer.Separated {
+		return ErrUnsupported("dropping channels of contiguous layers with string channels")
+	}
+
+	newLayer := oldLayer
+	newLayer.Channels = slices.Delete(slices.Clone(oldLayer.Channels), channelIndex, channelIndex+1)
+	tiles := oldLayer.Dimensions.Tiles()
+
+	if oldLayer.Separated {
+		newLayer.TileBytes = slices.Delete(slices.Clone(oldLayer.TileBytes), tiles*channelIndex, tiles*(channelIndex+1))
+		newLayer.TileOffsets = slices.Delete(slices.Clone(oldLayer.TileOffsets), tiles*channelIndex, tiles*(channelIndex+1))
+		if newLayer.Channels.HasStrings() {
+			newLayer.StringBytes = slices.Delete(slices.Clone(oldLayer.StringBytes), tiles*channelIndex, tiles*(channelIndex+1))
+		} else {
+			newLayer.StringBytes = nil
+		}
+	} else {
+		newLayer.TileBytes = make([]int64, tiles)
+		newLayer.TileOffsets = make([]int64, tiles)
+		oldSampleSize := oldLayer.Channels.Size()
+		newSampleSize := newLayer.Channels.Size()
+		channelOffset := oldLayer.Channels.Offset(channelIndex)
+		channelSize := oldLayer.Channels[channelIndex].Size()
+		for tile := range tiles {
+			if oldLayer.TileBytes[tile] == 0 {
+				continue
+			}
+			oldData := make([]byte, oldLayer.DiskTileSize(tile))
+			if err := oldLayer.ReadTile(rw, p.Header, tile, oldData); err != nil {
+				return err
+			}
+			tileData := make([]byte, newLayer.DiskTileSize(tile))
+			for stored := range oldLayer.Dimensions.TileSamples() + oldLayer.Dimensions.HaloSamples() {
+				oldSample := oldData[stored*oldSampleSize : (stored+1)*oldSampleSize]
+				newSample := tileData[stored*newSampleSize : (stored+1)*newSampleSize]
+				copy(newSample, oldSample[:channelOffset])
+				copy(newSample[channelOffset:], oldSample[channelOffset+channelSize:])
+			}
+			if err := newLayer.appendTile(rw, p.Header, tile, tileData); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.UpdateLayerHeader(rw, layerIndex, newLayer)
+}
+
+// Renames an existing layer. Only the layer header is rewritten, in place if the new name is no longer
+// than the old one and relocated to the end of the file otherwise; tile data is untouched.
+func (p *Pixi) RenameLayer(w io.WriteSeeker, layerIndex int, newName string) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "rename layer"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	layer := p.Layers[layerIndex]
+	layer.Name = newName
+	return p.UpdateLayerHeader(w, layerIndex, layer)
+}
+
+// Renames a dimension of an existing layer. Only the layer header is rewritten; tile data is untouched.
+func (p *Pixi) RenameDimension(w io.WriteSeeker, layerIndex int, oldName string, newName string) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "rename dimension"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	layer, err := p.Layers[layerIndex].renamedDimension(oldName, newName)
+	if err != nil {
+		return err
+	}
+	return p.UpdateLayerHeader(w, layerIndex, layer)
+}
+
+// A copy of the layer with the named dimension renamed.
+func (l Layer) renamedDimension(oldName string, newName string) (Layer, error) {
+	dimIndex := slices.IndexFunc(l.Dimensions, func(d Dimension) bool { return d.Name == oldName })
+	if dimIndex < 0 {
+		return l, ErrFormat(fmt.Sprintf("dimension '%s' not found in layer '%s'", oldName, l.Name))
+	}
+	if oldName != newName && slices.ContainsFunc(l.Dimensions, func(d Dimension) bool { return d.Name == newName }) {
+		return l, ErrFormat(fmt.Sprintf("dimension '%s' already exists in layer '%s'", newName, l.Name))
+	}
+	l.Dimensions = slices.Clone(l.Dimensions)
+	l.Dimensions[dimIndex].Name = newName
+	return l, nil
+}
+
+// Sets attributes of an existing layer, replacing those of the same name and removing those given a nil
+// value. Only the layer header is rewritten; tile data is untouched.
+func (p *Pixi) SetLayerAttributes(w io.WriteSeeker, layerIndex int, attributes map[string]any) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "set layer attributes"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	layer, err := p.Layers[layerIndex].withAttributes(attributes)
+	if err != nil {
+		return err
+	}
+	return p.UpdateLayerHeader(w, layerIndex, layer)
+}
+
+// A copy of the layer with the given attributes set, and those given a nil value removed.
+func (l Layer) withAttributes(attributes map[string]any) (Layer, error) {
+	l.Attributes = maps.Clone(l.Attributes)
+	if l.Attributes == nil {
+		l.Attributes = map[string]any{}
+	}
+	for name, value := range attributes {
+		if value == nil {
+			delete(l.Attributes, name)
+		} else {
+			l.Attributes[name] = value
+		}
+	}
+	return l, checkAttributes(l.Attributes)
+}
+
+// Sets the georeference of an existing layer, or removes it if nil. Only the layer header is rewritten;
+// tile data is untouched.
+func (p *Pixi) SetLayerGeoreference(w io.WriteSeeker, layerIndex int, georeference *Georeference) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "set layer georeference"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	layer, err := p.Layers[layerIndex].withGeoreference(georeference)
+	if err != nil {
+		return err
+	}
+	return p.UpdateLayerHeader(w, layerIndex, layer)
+}
+
+// A copy of the layer with the given georeference, or without one if nil.
+func (l Layer) withGeoreference(georeference *Georeference) (Layer, error) {
+	l.Georeference = nil
+	if georeference != nil {
+		if err := georeference.check(l.Dimensions); err != nil {
+			return l, err
+		}
+		copied := *georeference
+		l.Georeference = &copied
+	}
+	return l, nil
+}
@@ -0,0 +1,326 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func newEditTestPixi(t *testing.T, opts ...LayerOption) (*buffer.Buffer, *Pixi) {
+	t.Helper()
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{
+		NewLayer("data",
+			DimensionSet{{Name: "x", Size: 7, TileSize: 3}, {Name: "y", Size: 4, TileSize: 2}},
+			ChannelSet{{Name: "a", Type: ChannelUint16}, {Name: "flag", Type: ChannelBool}},
+			opts...),
+		NewLayer("other", DimensionSet{{Name: "x", Size: 3, TileSize: 3}}, ChannelSet{{Name: "b", Type: ChannelInt8}}),
+	}
+	writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize8), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		if layer == 0 {
+			return Sample{uint16(coord[0] * coord[1]), coord[0]%2 == 0}
+		}
+		return Sample{int8(coord[0])}
+	})
+	pixi, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf, pixi
+}
+
+func TestAddChannel(t *testing.T) {
+	modes := []struct {
+		name string
+		opts []LayerOption
+	}{
+		{"contiguous", nil},
+		{"separated", []LayerOption{WithPlanar()}},
+		{"compressed", []LayerOption{WithCompression(CompressionFlate)}},
+	}
+
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			buf, pixi := newEditTestPixi(t, mode.opts...)
+			err := pixi.AddChannel(buf, 0, Channel{Name: "derived", Type: ChannelFloat32}, func(coord SampleCoordinate) any {
+				return float32(coord[0]) + float32(coord[1])/10
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = pixi.AddChannel(buf, 0, Channel{Name: "a", Type: ChannelUint8}, nil)
+			if err == nil {
+				t.Error("expected error adding duplicate channel name")
+			}
+
+			read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			layer := read.Layers[0]
+			if len(layer.Channels) != 3 || layer.Channels[2].Name != "derived" {
+				t.Fatalf("expected derived channel to be appended, got %v", layer.Channels)
+			}
+			if layer.Channels[2].Max != float32(6.3) {
+				t.Errorf("expected derived channel max to be tracked, got %v", layer.Channels[2].Max)
+			}
+
+			data := NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), read.Header, layer, 4)
+			for coord := range layer.Dimensions.SampleCoordinates() {
+				sample, err := SampleAt(data, coord)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if sample[0] != uint16(coord[0]*coord[1]) || sample[1] != (coord[0]%2 == 0) {
+					t.Errorf("existing channels changed at %v: %v", coord, sample)
+				}
+				if sample[2] != float32(coord[0])+float32(coord[1])/10 {
+					t.Errorf("unexpected derived value at %v: %v", coord, sample[2])
+				}
+			}
+
+			if len(read.Layers) != 2 || read.Layers[1].Name != "other" {
+				t.Errorf("expected following layer to remain linked, got %v", read.Layers)
+			}
+		})
+	}
+
+	t.Run("sparse", func(t *testing.T) {
+		buf := buffer.NewBuffer(10)
+		layer := NewLayer("sparse", DimensionSet{{Name: "x", Size: 8, TileSize: 4}},
+			ChannelSet{{Name: "a", Type: ChannelInt16, FillValue: int16(-99)}}, WithSparseTiles())
+		writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize8), nil, []Layer{layer}, func(layer int, coord SampleCoordinate) Sample {
+			if coord[0] < 4 {
+				return Sample{int16(coord[0])}
+			}
+			return Sample{int16(-99)}
+		})
+		pixi, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pixi.Layers[0].TileBytes[1] != 0 {
+			t.Fatalf("expected the fill tile to be absent, got tile bytes %v", pixi.Layers[0].TileBytes)
+		}
+
+		if err := pixi.AddChannel(buf, 0, Channel{Name: "zeros", Type: ChannelUint8}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if pixi.Layers[0].TileBytes[1] != 0 {
+			t.Errorf("expected the absent tile to stay absent, got tile bytes %v", pixi.Layers[0].TileBytes)
+		}
+		if err := pixi.AddChannel(buf, 0, Channel{Name: "x", Type: ChannelUint8}, func(coord SampleCoordinate) any {
+			return uint8(coord[0])
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := NewAbsentFillLayer(NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), read.Header, read.Layers[0], 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for coord := range read.Layers[0].Dimensions.SampleCoordinates() {
+			sample, err := SampleAt(data, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := int16(-99)
+			if coord[0] < 4 {
+				expected = int16(coord[0])
+			}
+			if sample[0] != expected || sample[1] != uint8(0) || sample[2] != uint8(coord[0]) {
+				t.Errorf("unexpected sample at %v: %v", coord, sample)
+			}
+		}
+	})
+}
+
+func TestRenameChannel(t *testing.T) {
+	buf, pixi := newEditTestPixi(t)
+	err := pixi.RenameChannel(buf, 0, "a", "a_renamed_to_something_longer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.RenameChannel(buf, 0, "missing", "x"); err == nil {
+		t.Error("expected error renaming missing channel")
+	}
+	if err := pixi.RenameChannel(buf, 0, "flag", "a_renamed_to_something_longer"); err == nil {
+		t.Error("expected error renaming channel to an existing name")
+	}
+
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Layers[0].Channels.Index("a_renamed_to_something_longer") != 0 {
+		t.Errorf("expected channel to be renamed, got %v", read.Layers[0].Channels)
+	}
+	data := NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), read.Header, read.Layers[0], 4)
+	value, err := ChannelAt(data, SampleCoordinate{3, 2}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != uint16(6) {
+		t.Errorf("expected tile data to be unchanged, got %v", value)
+	}
+}
+
+func TestDropChannelAndCompact(t *testing.T) {
+	modes := []struct {
+		name string
+		opts []LayerOption
+	}{
+		{"contiguous", nil},
+		{"separated", []LayerOption{WithPlanar()}},
+	}
+
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			buf, pixi := newEditTestPixi(t, mode.opts...)
+			if pixi.LiveBytes() != int64(len(buf.Bytes())) {
+				t.Errorf("expected freshly written file to be all live bytes, got %d of %d", pixi.LiveBytes(), len(buf.Bytes()))
+			}
+
+			err := pixi.DropChannel(buf, 0, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := pixi.DropChannel(buf, 1, "b"); err == nil {
+				t.Error("expected error dropping the only channel of a layer")
+			}
+
+			read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(read.Layers[0].Channels) != 1 || read.Layers[0].Channels[0].Name != "flag" {
+				t.Fatalf("expected only flag channel to remain, got %v", read.Layers[0].Channels)
+			}
+			if read.LiveBytes() >= int64(len(buf.Bytes())) {
+				t.Errorf("expected dropped channel to leave dead space")
+			}
+
+			compacted := buffer.NewBuffer(10)
+			compactPixi, err := Compact(buffer.NewBufferFrom(buf.Bytes()), compacted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compactPixi.LiveBytes() != int64(len(compacted.Bytes())) {
+				t.Errorf("expected compacted file to have no dead space, got %d of %d", compactPixi.LiveBytes(), len(compacted.Bytes()))
+			}
+
+			data := NewFifoCacheReadLayer(buffer.NewBufferFrom(compacted.Bytes()), compactPixi.Header, compactPixi.Layers[0], 4)
+			for coord := range compactPixi.Layers[0].Dimensions.SampleCoordinates() {
+				sample, err := SampleAt(data, coord)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(sample) != 1 || sample[0] != (coord[0]%2 == 0) {
+					t.Errorf("unexpected sample after drop at %v: %v", coord, sample)
+				}
+			}
+		})
+	}
+}
+
+func TestRenameLayerAndDimension(t *testing.T) {
+	buf, pixi := newEditTestPixi(t)
+	sizeBefore := len(buf.Bytes())
+
+	// shorter names fit in place
+	if err := pixi.RenameLayer(buf, 1, "o"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.RenameDimension(buf, 0, "y", "z"); err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.Bytes()) != sizeBefore {
+		t.Errorf("expected same-length renames to be done in place, file grew from %d to %d", sizeBefore, len(buf.Bytes()))
+	}
+
+	// longer names relocate the header
+	if err := pixi.RenameLayer(buf, 0, "a much longer layer name than before"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.RenameDimension(buf, 1, "x", "longitude"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.RenameDimension(buf, 0, "x", "z"); err == nil {
+		t.Error("expected error renaming dimension to an existing name")
+	}
+	if err := pixi.RenameDimension(buf, 0, "missing", "w"); err == nil {
+		t.Error("expected error renaming a missing dimension")
+	}
+
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Layers[0].Name != "a much longer layer name than before" || read.Layers[1].Name != "o" {
+		t.Errorf("unexpected layer names %s, %s", read.Layers[0].Name, read.Layers[1].Name)
+	}
+	if read.Layers[0].Dimensions[1].Name != "z" || read.Layers[1].Dimensions[0].Name != "longitude" {
+		t.Errorf("unexpected dimension names %v, %v", read.Layers[0].Dimensions, read.Layers[1].Dimensions)
+	}
+
+	data := NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), read.Header, read.Layers[1], 1)
+	value, err := ChannelAt(data, SampleCoordinate{2}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != int8(2) {
+		t.Errorf("expected tile data to be unchanged, got %v", value)
+	}
+}
+
+func TestEditsEvictTileCache(t *testing.T) {
+	edits := map[string]func(buf *buffer.Buffer, pixi *Pixi) error{
+		"add channel": func(buf *buffer.Buffer, pixi *Pixi) error {
+			return pixi.AddChannel(buf, 0, Channel{Name: "c", Type: ChannelUint8}, func(coord SampleCoordinate) any { return uint8(coord[1]) })
+		},
+		"drop channel":   func(buf *buffer.Buffer, pixi *Pixi) error { return pixi.DropChannel(buf, 0, "flag") },
+		"rename channel": func(buf *buffer.Buffer, pixi *Pixi) error { return pixi.RenameChannel(buf, 0, "flag", "even") },
+		"rename layer": func(buf *buffer.Buffer, pixi *Pixi) error {
+			return pixi.RenameLayer(buf, 0, "a much longer layer name than before")
+		},
+		"rename dimension": func(buf *buffer.Buffer, pixi *Pixi) error { return pixi.RenameDimension(buf, 0, "y", "row") },
+		"set attributes": func(buf *buffer.Buffer, pixi *Pixi) error {
+			return pixi.SetLayerAttributes(buf, 0, map[string]any{"source": "test"})
+		},
+		"edit metadata": func(buf *buffer.Buffer, pixi *Pixi) error {
+			return pixi.EditMetadata(buf, func(m *MetadataEditor) error { return m.RenameLayer(0, "edited") })
+		},
+	}
+	for name, edit := range edits {
+		for mode, opts := range map[string][]LayerOption{"contiguous": nil, "separated": {WithPlanar()}} {
+			buf, pixi := newEditTestPixi(t, opts...)
+			pixi.TileCache = NewTileCache(1 << 20)
+			readAll := func() {
+				t.Helper()
+				data, err := pixi.ReadLayer(buf, 0, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for coord := range pixi.Layers[0].Dimensions.SampleCoordinates() {
+					if sample, err := SampleAt(data, coord); err != nil || sample[0] != uint16(coord[0]*coord[1]) {
+						t.Fatalf("%s, %s: sample %v: got %v (%v)", name, mode, coord, sample, err)
+					}
+				}
+			}
+			readAll()
+			if err := edit(buf, pixi); err != nil {
+				t.Fatalf("%s, %s: %v", name, mode, err)
+			}
+			if tiles := pixi.TileCache.Stats().Tiles; tiles != 0 {
+				t.Errorf("%s, %s: expected the tiles of the edited layer to be evicted, %d remain", name, mode, tiles)
+			}
+			readAll()
+		}
+	}
+}
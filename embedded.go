@@ -0,0 +1,117 @@
+package gopixi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// A read-only Pixi file held in memory, such as one shipped inside a program binary with go:embed. An
+// embedded file is safe for concurrent use, and every mutating method of its Pixi fails with ErrReadOnly.
+// The tiles returned by its layers may share the bytes of the file and must never be modified.
+type EmbeddedFile struct {
+	*Pixi
+	data []byte
+}
+
+var _ io.ReaderAt = (*EmbeddedFile)(nil)
+
+// Opens the file with the given name in the file system, such as an embed.FS, reading all of it into memory.
+func OpenFS(fsys fs.FS, name string) (*EmbeddedFile, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return OpenEmbedded(data)
+}
+
+// Opens the file held in the given bytes, such as a []byte variable set with go:embed, without copying
+// them. The bytes are shared with the returned file, so they must not be modified while it is in use.
+func OpenEmbedded(data []byte) (*EmbeddedFile, error) {
+	p, err := ReadPixi(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	p.ReadOnly = true
+	return &EmbeddedFile{Pixi: p, data: data}, nil
+}
+
+// A new, independent stream reading the file from its start.
+func (e *EmbeddedFile) Stream() io.ReadSeeker {
+	return bytes.NewReader(e.data)
+}
+
+// Reads the bytes of the file at the offset, implementing io.ReaderAt.
+func (e *EmbeddedFile) ReadAt(p []byte, off int64) (int, error) {
+	return bytes.NewReader(e.data).ReadAt(p, off)
+}
+
+// The bytes of the file, which are shared with it and must not be modified.
+func (e *EmbeddedFile) Bytes() []byte {
+	return e.data
+}
+
+// Opens the layer with the given index for reading, as in Pixi.ReadLayer. Tiles are decoded the first time
+// they are read and kept for the lifetime of the layer; the tiles of uncompressed layers are views of the
+// bytes of the file, verified against their checksums (unless the options skip them) but never copied.
+// The layer is safe for concurrent use.
+func (e *EmbeddedFile) Layer(layerIndex int, opts ...ReadOption) (TileAccessLayer, error) {
+	if layerIndex < 0 || layerIndex >= len(e.Layers) {
+		return nil, ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	layer := e.Layers[layerIndex]
+	options := newReadOptions(opts).forLayer(layer)
+	base := &embeddedReadLayer{file: e, layer: layer, verify: !options.skipChecksums, tiles: map[int][]byte{}}
+	return e.wrapReadLayer(base, layer.DiskTiles(), options, opts)
+}
+
+// Reads the tiles of a layer of an embedded file, keeping every tile once it has been read.
+type embeddedReadLayer struct {
+	file   *EmbeddedFile
+	layer  Layer
+	verify bool
+	lock   sync.Mutex
+	tiles  map[int][]byte
+}
+
+func (l *embeddedReadLayer) Layer() Layer {
+	return l.layer
+}
+
+func (l *embeddedReadLayer) Header() Header {
+	return l.file.Header
+}
+
+func (l *embeddedReadLayer) Tile(tile int) ([]byte, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if data, ok := l.tiles[tile]; ok {
+		return data, nil
+	}
+	if tile < 0 || tile >= len(l.layer.TileBytes) || l.layer.TileBytes[tile] == 0 {
+		return nil, ErrTileNotFound{TileIndex: tile}
+	}
+
+	var data []byte
+	size := int64(l.layer.DiskTileSize(tile))
+	if l.layer.Compression == CompressionNone && l.layer.TileBytes[tile] == size {
+		start := l.layer.TileOffsets[tile]
+		checksumSize := int64(l.file.Header.Checksum.Size())
+		if start < 0 || start+size+checksumSize > int64(len(l.file.data)) {
+			return nil, ErrFormat(fmt.Sprintf("tile %d of layer '%s' extends past the end of the file", tile, l.layer.Name))
+		}
+		data = l.file.data[start : start+size : start+size]
+		if l.verify && !l.file.Header.checksumMatches(l.file.data[start+size:start+size+checksumSize], data) {
+			return nil, ErrChecksum{TileIndex: tile, LayerName: l.layer.Name}
+		}
+	} else {
+		data = make([]byte, size)
+		if err := l.layer.readTile(bytes.NewReader(l.file.data), l.file.Header, tile, data, l.verify); err != nil {
+			return nil, err
+		}
+	}
+	l.tiles[tile] = data
+	return data, nil
+}
@@ -0,0 +1,161 @@
+package gopixi
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestOpenFS(t *testing.T) {
+	for _, name := range []string{"compression-none-contiguous.pixi", "compression-none-separated.pixi", "compression-zstd-separated.pixi", "sparse-contiguous.pixi", "fill-values.pixi", "halo.pixi"} {
+		embedded, err := OpenFS(goldenCorpus(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := fs.ReadFile(goldenCorpus(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		memory, err := FromBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, layer := range embedded.Layers {
+			got, err := embedded.Layer(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := memory.Layer(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for coord := range layer.Dimensions.SampleCoordinates() {
+				gotSample, gotErr := SampleAt(got, coord)
+				wantSample, wantErr := SampleAt(want, coord)
+				if (gotErr == nil) != (wantErr == nil) || !slices.Equal(gotSample, wantSample) {
+					t.Fatalf("%s: sample %v of layer %d read as %v (%v), expected %v (%v)", name, coord, i, gotSample, gotErr, wantSample, wantErr)
+				}
+			}
+		}
+	}
+}
+
+func TestEmbeddedTilesAreViews(t *testing.T) {
+	data, err := fs.ReadFile(goldenCorpus(), "compression-none-contiguous.pixi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := OpenEmbedded(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := embedded.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile, err := layer.Tile(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := layer.Tile(0); &again[0] != &tile[0] {
+		t.Error("expected the tile to be kept once read")
+	}
+	before := tile[0]
+	data[embedded.Layers[0].TileOffsets[0]] ^= 0xff
+	if tile[0] == before {
+		t.Error("expected the uncompressed tile to be a view of the embedded bytes")
+	}
+}
+
+func TestEmbeddedChecksum(t *testing.T) {
+	data, err := fs.ReadFile(goldenCorpus(), "compression-none-contiguous.pixi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := OpenEmbedded(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[embedded.Layers[0].TileOffsets[0]] ^= 0xff
+	layer, err := embedded.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var integrity ErrDataIntegrity
+	if _, err := layer.Tile(0); !errors.As(err, &integrity) {
+		t.Errorf("expected a checksum error, got %v", err)
+	}
+	unverified, err := embedded.Layer(0, WithVerifyChecksums(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unverified.Tile(0); err != nil {
+		t.Errorf("expected no error without verifying checksums, got %v", err)
+	}
+}
+
+func TestEmbeddedReadOnly(t *testing.T) {
+	embedded, err := OpenFS(goldenCorpus(), "metadata.pixi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var readOnly ErrReadOnly
+	if err := embedded.AppendTags(nil, map[string]string{"a": "b"}); !errors.As(err, &readOnly) {
+		t.Errorf("expected ErrReadOnly appending tags, got %v", err)
+	}
+	if _, err := OpenFS(goldenCorpus(), "missing.pixi"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist for a missing file, got %v", err)
+	}
+}
+
+func TestEmbeddedConcurrentReads(t *testing.T) {
+	embedded, err := OpenFS(goldenCorpus(), "compression-flate-separated.pixi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := embedded.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Sample{}
+	for coord := range embedded.Layers[0].Dimensions.SampleCoordinates() {
+		sample, err := SampleAt(layer, coord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, sample)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Go(func() {
+			fresh, err := embedded.Layer(0)
+			if err != nil {
+				errs <- err
+				return
+			}
+			i := 0
+			for coord := range embedded.Layers[0].Dimensions.SampleCoordinates() {
+				for _, accessor := range []TileAccessLayer{layer, fresh} {
+					sample, err := SampleAt(accessor, coord)
+					if err != nil || !slices.Equal(sample, want[i]) {
+						errs <- errors.Join(err, ErrFormat("concurrent read did not match"))
+						return
+					}
+				}
+				i++
+			}
+			if _, err := ReadPixi(embedded.Stream()); err != nil {
+				errs <- err
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
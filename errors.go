@@ -1,6 +1,9 @@
 package gopixi
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type ErrFormat string
 
@@ -23,6 +26,10 @@ func (e ErrDataIntegrity) Error() string {
 	return fmt.Sprintf("pixi: data integrity compromised - tile %d, layer '%s'", e.TileIndex, e.LayerName)
 }
 
+// The error returned when the data of a tile does not match the checksum stored with it, as when the file
+// was corrupted in storage or in transit.
+type ErrChecksum = ErrDataIntegrity
+
 type ErrChannelNotFound struct {
 	ChannelName string
 }
@@ -39,6 +46,37 @@ func (e ErrTileNotFound) Error() string {
 	return fmt.Sprintf("pixi: tile not found - index %d", e.TileIndex)
 }
 
+type ErrValueType struct {
+	Channel    string           // The name of the channel, if known.
+	Expected   ChannelType      // The type of the channel.
+	Got        string           // The Go type of the value given for it.
+	Coordinate SampleCoordinate // The coordinate of the sample being written, if known.
+}
+
+func (e ErrValueType) Error() string {
+	message := fmt.Sprintf("pixi: value type mismatch - expected %v, got %s", e.Expected, e.Got)
+	if e.Channel != "" {
+		message += fmt.Sprintf(" for channel '%s'", e.Channel)
+	}
+	if e.Coordinate != nil {
+		message += fmt.Sprintf(" at coordinate %v", e.Coordinate)
+	}
+	return message
+}
+
+type ErrNonFinite struct {
+	Channel    string
+	Value      float64          // The NaN or infinite value.
+	Coordinate SampleCoordinate // The coordinate of the sample being written, if known.
+}
+
+func (e ErrNonFinite) Error() string {
+	if e.Coordinate == nil {
+		return fmt.Sprintf("pixi: non-finite value - %v for channel '%s'", e.Value, e.Channel)
+	}
+	return fmt.Sprintf("pixi: non-finite value - %v for channel '%s' at coordinate %v", e.Value, e.Channel, e.Coordinate)
+}
+
 type ErrSampleCoordinateOutOfBounds struct {
 	Coordinate SampleCoordinate
 	Dimensions DimensionSet
@@ -47,3 +85,65 @@ type ErrSampleCoordinateOutOfBounds struct {
 func (e ErrSampleCoordinateOutOfBounds) Error() string {
 	return fmt.Sprintf("pixi: sample coordinate out of bounds - coordinate %v, dimensions %v", e.Coordinate, e.Dimensions)
 }
+
+type ErrReadOnly struct {
+	Operation string
+}
+
+func (e ErrReadOnly) Error() string {
+	return fmt.Sprintf("pixi: read-only - cannot %s", e.Operation)
+}
+
+type ErrMergeConflict struct {
+	Kind string
+	Name string
+}
+
+func (e ErrMergeConflict) Error() string {
+	return fmt.Sprintf("pixi: metadata merge conflict - %s '%s'", e.Kind, e.Name)
+}
+
+type ErrFriendlyString struct {
+	Value  string
+	Reason string
+}
+
+func (e ErrFriendlyString) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("pixi: invalid friendly string - %s", e.Reason)
+	}
+	return fmt.Sprintf("pixi: invalid friendly string - %s: %q", e.Reason, e.Value)
+}
+
+type ErrNonConformant struct {
+	Problems []string
+}
+
+func (e ErrNonConformant) Error() string {
+	return fmt.Sprintf("pixi: dataset does not conform to schema - %s", strings.Join(e.Problems, "; "))
+}
+
+// The way in which the grids of two layers fail to align, as reported by ErrAxisMismatch.
+type AxisMismatch string
+
+const (
+	AxisMismatchDimensions AxisMismatch = "dimensions" // The layers do not have the same dimensions.
+	AxisMismatchAxis       AxisMismatch = "axis"       // Only one of the layers has an axis for the dimension.
+	AxisMismatchUnit       AxisMismatch = "unit"       // The axes have different units.
+	AxisMismatchStep       AxisMismatch = "step"       // The axes have different steps.
+	AxisMismatchOrigin     AxisMismatch = "origin"     // The axis origins are not a whole number of steps apart.
+	AxisMismatchExtent     AxisMismatch = "extent"     // Neither grid lies within the other.
+)
+
+type ErrAxisMismatch struct {
+	Dimension string
+	Kind      AxisMismatch
+	Detail    string
+}
+
+func (e ErrAxisMismatch) Error() string {
+	if e.Dimension == "" {
+		return fmt.Sprintf("pixi: axis mismatch - %s: %s", e.Kind, e.Detail)
+	}
+	return fmt.Sprintf("pixi: axis mismatch in dimension '%s' - %s: %s", e.Dimension, e.Kind, e.Detail)
+}
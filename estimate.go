@@ -0,0 +1,262 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// The sizes in bytes of the parts of a Pixi file, either estimated before it is written or measured
+// afterwards.
+type SizeEstimate struct {
+	Header int64 // The file header and all tag sections.
+	Index  int64 // The layer headers, which include the offset and size of every tile.
+	Data   int64 // The on-disk (compressed) tile data, including tile checksums.
+	// True if every tile was actually encoded to measure Data, rather than extrapolated from a sample of
+	// tiles or from a typical compression ratio.
+	Exact bool
+}
+
+// The total size of the file.
+func (s SizeEstimate) Total() int64 {
+	return s.Header + s.Index + s.Data
+}
+
+func (s SizeEstimate) String() string {
+	return fmt.Sprintf("%d bytes (header %d, index %d, data %d)", s.Total(), s.Header, s.Index, s.Data)
+}
+
+type estimateOptions struct {
+	sample      func(layer int, coord SampleCoordinate) Sample
+	sampleTiles int
+}
+
+type EstimateOption interface {
+	applyEstimate(*estimateOptions)
+}
+
+type sampleDataOption struct {
+	sample func(layer int, coord SampleCoordinate) Sample
+	tiles  int
+}
+
+func (o sampleDataOption) applyEstimate(opts *estimateOptions) {
+	opts.sample = o.sample
+	opts.sampleTiles = o.tiles
+}
+
+// Estimates the compressed size of tile data by encoding up to the given number of tiles of each layer,
+// spread evenly through the layer, filled with the samples returned by the function. The compression
+// ratio achieved on those tiles is applied to the rest. A count of zero or less encodes every tile,
+// giving an exact size at the cost of generating all of the data.
+func WithSampleData(sample func(layer int, coord SampleCoordinate) Sample, tiles int) EstimateOption {
+	return sampleDataOption{sample: sample, tiles: tiles}
+}
+
+// Estimates the size of a file with the given header, tags, and layers (whose tiles need not have been
+// written) without writing anything. Header and index sizes are exact; tile data sizes assume a typical
+// compression ratio for each layer unless sample data is given with WithSampleData.
+func EstimateSize(h Header, tags map[string]string, layers []Layer, opts ...EstimateOption) (SizeEstimate, error) {
+	options := estimateOptions{}
+	for _, o := range opts {
+		o.applyEstimate(&options)
+	}
+
+	estimate := SizeEstimate{Header: int64(h.DiskSize()), Exact: true}
+	if len(tags) > 0 {
+		estimate.Header += int64(TagSection{Tags: tags}.DiskSize(h))
+	}
+	for layerIndex, layer := range layers {
+		estimate.Index += int64(withEstimatedStatistics(layer, h).HeaderSize(h))
+
+		tiles := len(layer.TileBytes)
+		if options.sample == nil {
+			rawBytes := 0
+			for tile := range tiles {
+				rawBytes += layer.DiskTileSize(tile)
+			}
+			estimate.Data += int64(math.Ceil(float64(rawBytes)/estimatedCompressionRatio(layer.Compression))) + int64(tiles*h.Checksum.Size())
+			if layer.Compression != CompressionNone {
+				estimate.Exact = false
+			}
+			continue
+		}
+
+		sampled := sampledTiles(layer, options.sampleTiles)
+		rawBytes, sampledRaw, sampledDisk := int64(0), int64(0), int64(0)
+		for tile := range tiles {
+			rawBytes += int64(layer.DiskTileSize(tile))
+		}
+		for _, tile := range sampled {
+			size, err := encodedTileSize(h, layer, tile, func(coord SampleCoordinate) Sample {
+				return options.sample(layerIndex, coord)
+			})
+			if err != nil {
+				return SizeEstimate{}, err
+			}
+			sampledRaw += int64(layer.DiskTileSize(tile))
+			sampledDisk += size
+		}
+		if len(sampled) == tiles {
+			estimate.Data += sampledDisk + int64(tiles*h.Checksum.Size())
+		} else {
+			estimate.Data += int64(math.Ceil(float64(rawBytes)*float64(sampledDisk)/float64(max(sampledRaw, 1)))) + int64(tiles*h.Checksum.Size())
+			estimate.Exact = false
+		}
+	}
+	return estimate, nil
+}
+
+// Measures the sizes of the parts of this file as described by its metadata.
+func (p *Pixi) Sizes() SizeEstimate {
+	sizes := SizeEstimate{Header: int64(p.Header.DiskSize()), Exact: true}
+	for _, t := range p.Tags {
+		sizes.Header += int64(t.DiskSize(p.Header))
+	}
+	for _, l := range p.Layers {
+		sizes.Index += int64(l.HeaderSize(p.Header))
+		for _, b := range l.TileBytes {
+			if b != 0 {
+				sizes.Data += b + int64(p.Header.Checksum.Size())
+			}
+		}
+	}
+	return sizes
+}
+
+// Returns a copy of the layer with Min/Max statistics and moments present on every channel (but the moments
+// of string channels, which have none), so that its header size matches that of the same layer after it has
+// been written.
+func withEstimatedStatistics(layer Layer, h Header) Layer {
+	layer.Channels = slices.Clone(layer.Channels)
+	for i, channel := range layer.Channels {
+		zero := channel.Value(make([]byte, max(channel.Size(), 1)), h.ByteOrder)
+		if channel.Min == nil {
+			layer.Channels[i].Min = zero
+		}
+		if channel.Max == nil {
+			layer.Channels[i].Max = zero
+		}
+		if channel.Type.Base() != ChannelString {
+			layer.Channels[i].Moments.ValidCount = max(channel.Moments.ValidCount, 1)
+		}
+	}
+	return layer
+}
+
+// Chooses up to count tile indices spread evenly through the layer, or every tile if count is zero or less.
+func sampledTiles(layer Layer, count int) []int {
+	tiles := len(layer.TileBytes)
+	if count <= 0 || count >= tiles {
+		count = tiles
+	}
+	chosen := make([]int, 0, count)
+	for i := range count {
+		tile := i * tiles / count
+		if len(chosen) == 0 || chosen[len(chosen)-1] != tile {
+			chosen = append(chosen, tile)
+		}
+	}
+	return chosen
+}
+
+// Encodes a single tile of the layer, filled with the given samples, and returns its compressed size
+// (excluding the checksum).
+func encodedTileSize(h Header, layer Layer, tile int, sample func(coord SampleCoordinate) Sample) (int64, error) {
+	dims := layer.Dimensions
+	selectorTile := tile % dims.Tiles()
+	scratch := &scratchTiles{layer: layer, header: h, tiles: map[int][]byte{}}
+	for inTile := range dims.TileSamples() {
+		coord := TileSelector{Tile: selectorTile, InTile: inTile}.ToTileCoordinate(dims).ToSampleCoordinate(dims)
+		if !dims.ContainsCoordinate(coord) {
+			continue
+		}
+		if err := SetSampleAt(scratch, coord, sample(coord)); err != nil {
+			return 0, err
+		}
+	}
+	data, err := scratch.Tile(tile)
+	if err != nil {
+		return 0, err
+	}
+
+	// encode into a copy of the tile index, leaving the caller's layer untouched
+	layer.TileBytes = slices.Clone(layer.TileBytes)
+	layer.TileOffsets = slices.Clone(layer.TileOffsets)
+	layer.StringBytes = slices.Clone(layer.StringBytes)
+	if err := layer.WriteTile(&DryRunWriter{}, h, tile, data); err != nil {
+		return 0, err
+	}
+	return layer.TileBytes[tile], nil
+}
+
+// Zeroed tile buffers allocated on demand, for building tiles in memory.
+type scratchTiles struct {
+	layer  Layer
+	header Header
+	tiles  map[int][]byte
+}
+
+func (s *scratchTiles) Layer() Layer {
+	return s.layer
+}
+
+func (s *scratchTiles) Header() Header {
+	return s.header
+}
+
+func (s *scratchTiles) Tile(tile int) ([]byte, error) {
+	data, ok := s.tiles[tile]
+	if !ok {
+		data = make([]byte, s.layer.DiskTileSize(tile))
+		s.tiles[tile] = data
+	}
+	return data, nil
+}
+
+func (s *scratchTiles) SetDirty(tile int) {}
+
+func (s *scratchTiles) Commit() error {
+	return nil
+}
+
+// An io.WriteSeeker that discards everything written to it while tracking the size the stream would have
+// had. Running a normal write (such as Create followed by AppendIterativeLayer) against a dry-run writer
+// computes the exact size of the file, and the resulting Pixi reports the breakdown with Sizes, without
+// needing any storage.
+type DryRunWriter struct {
+	offset int64
+	size   int64
+}
+
+var _ io.WriteSeeker = (*DryRunWriter)(nil)
+
+func (d *DryRunWriter) Write(p []byte) (int, error) {
+	d.offset += int64(len(p))
+	d.size = max(d.size, d.offset)
+	return len(p), nil
+}
+
+func (d *DryRunWriter) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		// nothing to do here
+	case io.SeekCurrent:
+		offset += d.offset
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, ErrUnsupported(fmt.Sprintf("seek with whence %d", whence))
+	}
+	if offset < 0 {
+		return 0, ErrFormat("seek to negative offset")
+	}
+	d.offset = offset
+	return offset, nil
+}
+
+// The number of bytes the stream would contain.
+func (d *DryRunWriter) Size() int64 {
+	return d.size
+}
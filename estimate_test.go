@@ -0,0 +1,74 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func estimateTestLayers() []Layer {
+	dims := DimensionSet{{Name: "x", Size: 30, TileSize: 8}, {Name: "y", Size: 20, TileSize: 8}}
+	channels := ChannelSet{{Name: "a", Type: ChannelUint16}, {Name: "b", Type: ChannelFloat32, Unit: "K"}}
+	return []Layer{
+		NewLayer("raw", dims, channels),
+		NewLayer("flate", dims, channels, WithCompression(CompressionFlate)),
+		NewLayer("separated", dims, channels, WithCompression(CompressionLzwLsb), WithPlanar()),
+	}
+}
+
+func estimateTestSample(layer int, coord SampleCoordinate) Sample {
+	return Sample{uint16(coord[0] * coord[1]), float32(coord[1]) / 3}
+}
+
+func TestEstimateSizeExact(t *testing.T) {
+	header := NewHeader(binary.LittleEndian, OffsetSize8)
+	tags := map[string]string{"source": "estimate"}
+
+	buf := buffer.NewBuffer(10)
+	written := writeTestPixi(t, buf, header, tags, estimateTestLayers(), estimateTestSample)
+	actual := written.Sizes()
+	if actual.Total() != int64(len(buf.Bytes())) {
+		t.Errorf("expected measured size %d to match file size %d", actual.Total(), len(buf.Bytes()))
+	}
+
+	estimate, err := EstimateSize(header, tags, estimateTestLayers(), WithSampleData(estimateTestSample, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !estimate.Exact || estimate != actual {
+		t.Errorf("expected exact estimate %v to match %v", estimate, actual)
+	}
+
+	dryRun := &DryRunWriter{}
+	writeTestPixi(t, dryRun, header, tags, estimateTestLayers(), estimateTestSample)
+	if dryRun.Size() != int64(len(buf.Bytes())) {
+		t.Errorf("expected dry run size %d to match file size %d", dryRun.Size(), len(buf.Bytes()))
+	}
+}
+
+func TestEstimateSizeApproximate(t *testing.T) {
+	header := NewHeader(binary.BigEndian, OffsetSize4)
+	buf := buffer.NewBuffer(10)
+	actual := writeTestPixi(t, buf, header, nil, estimateTestLayers(), estimateTestSample).Sizes()
+
+	typical, err := EstimateSize(header, nil, estimateTestLayers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampled, err := EstimateSize(header, nil, estimateTestLayers(), WithSampleData(estimateTestSample, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, estimate := range []SizeEstimate{typical, sampled} {
+		if estimate.Exact {
+			t.Error("expected estimate of compressed layers not to be exact")
+		}
+		if estimate.Header != actual.Header || estimate.Index != actual.Index {
+			t.Errorf("expected header and index sizes of %v to match %v", estimate, actual)
+		}
+		if estimate.Data < actual.Data/4 || estimate.Data > actual.Data*4 {
+			t.Errorf("estimated data size %d too far from actual %d", estimate.Data, actual.Data)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package gopixi
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gracefulearth/image/font"
+	"github.com/gracefulearth/image/font/basicfont"
+	"github.com/gracefulearth/image/math/fixed"
+)
+
+// The most tick labels along each axis of a figure drawn by RenderFigure unless FigureLabels give another.
+const DefaultFigureTicks int = 5
+
+// The size in pixels below which RenderFigure enlarges the longer side of the plane unless FigureLabels give
+// a scale, so that small planes are large enough to read.
+const DefaultFigureSize int = 256
+
+// Describes the labels RenderFigure draws around a rendered plane. Every label is drawn by default, and each
+// can be left out.
+type FigureLabels struct {
+	// The title above the plane. If empty, the title names the layer and the rendered channel with its unit,
+	// followed by the axis value (or index) of the plane in each dimension beyond the first two.
+	Title      string
+	NoTitle    bool // Leaves out the title.
+	NoColorbar bool // Leaves out the colorbar showing the values of the colors of the ramp.
+	// The most tick labels along each axis and the colorbar, DefaultFigureTicks if zero, or none if negative.
+	// Ticks are placed at round axis values, or at round indices of dimensions without an axis.
+	Ticks int
+	// The width and height in pixels of each sample of the plane. If zero, samples are enlarged by the
+	// smallest whole factor making the longer side of the plane at least DefaultFigureSize pixels.
+	Scale int
+}
+
+var (
+	figureFace       = basicfont.Face7x13
+	figureBackground = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	figureInk        = color.NRGBA{A: 255}
+)
+
+const (
+	figureLineHeight = 13 // the height of a line of text in figureFace
+	figureAscent     = 11 // the height of figureFace above its baseline
+	figureTickLength = 4
+	figurePadding    = 8
+	figureBarWidth   = 14
+)
+
+// Renders a channel of the plane of the layer as RenderPlane does, drawn on a white figure with a title, tick
+// labels along both axes giving their values, the names and units of the dimensions, and a colorbar giving
+// the values of the colors, as a quick look that needs no further annotation. Every label is derived from the
+// metadata of the layer unless the labels give it. Missing samples are left white.
+func RenderFigure(layer TileAccessLayer, at SampleCoordinate, options RenderOptions, labels FigureLabels) (*image.NRGBA, error) {
+	channel, err := renderChannel(layer.Layer(), options)
+	if err != nil {
+		return nil, err
+	}
+	plane, err := renderCoordinate(layer.Layer(), at)
+	if err != nil {
+		return nil, err
+	}
+	low, high, err := renderRange(layer, channel, []SampleCoordinate{plane}, options)
+	if err != nil {
+		return nil, err
+	}
+	return renderFigure(layer, channel, plane, low, high, options, labels)
+}
+
+// A tick along an axis of a figure: its position in pixels from the start of the axis, and its label.
+type figureTick struct {
+	position int
+	label    string
+}
+
+func renderFigure(layer TileAccessLayer, channel int, plane SampleCoordinate, low float64, high float64, options RenderOptions, labels FigureLabels) (*image.NRGBA, error) {
+	rendered, err := renderPlane(layer, channel, plane, low, high, options)
+	if err != nil {
+		return nil, err
+	}
+	l := layer.Layer()
+	dimX, dimY := l.Dimensions[0], l.Dimensions[1]
+	scale := labels.Scale
+	if scale <= 0 {
+		scale = max(1, (DefaultFigureSize+max(dimX.Size, dimY.Size)-1)/max(dimX.Size, dimY.Size))
+	}
+	ticks := labels.Ticks
+	if ticks == 0 {
+		ticks = DefaultFigureTicks
+	}
+	width, height := dimX.Size*scale, dimY.Size*scale
+
+	ticksX, ticksY, ticksBar := []figureTick{}, []figureTick{}, []figureTick{}
+	if ticks > 0 {
+		ticksX = dimensionTicks(dimX, scale, ticks)
+		ticksY = dimensionTicks(dimY, scale, ticks)
+		if high > low {
+			for _, value := range niceTicks(low, high, ticks) {
+				position := int(math.Round((high - value) / (high - low) * float64(height-1)))
+				ticksBar = append(ticksBar, figureTick{position: position, label: tickLabel(value, niceStep(low, high, ticks))})
+			}
+		} else {
+			ticksBar = append(ticksBar, figureTick{position: height / 2, label: tickLabel(low, 0)})
+		}
+	}
+	title := labels.Title
+	if title == "" {
+		title = figureTitle(l, channel, plane)
+	}
+	unit := l.Channels[channel].Unit
+
+	// lay out the figure around the plane: the y axis label above its tick labels, the x tick labels and axis
+	// label below the plane, and the colorbar with its unit and tick labels to its right
+	labelWidth := func(s string) int { return font.MeasureString(figureFace, s).Ceil() }
+	left := figurePadding + labelWidth(axisLabel(dimY))
+	tickWidthY := 0
+	for _, tick := range ticksY {
+		tickWidthY = max(tickWidthY, labelWidth(tick.label))
+	}
+	left = max(left, figurePadding+tickWidthY+2+figureTickLength)
+	top := figurePadding + figureLineHeight + 4 // the y axis label
+	if !labels.NoTitle {
+		top += figureLineHeight + 4
+	}
+	right := figurePadding
+	if !labels.NoColorbar {
+		barLabels := labelWidth(unit)
+		for _, tick := range ticksBar {
+			barLabels = max(barLabels, figureTickLength+2+labelWidth(tick.label))
+		}
+		right += 2*figurePadding + figureBarWidth + barLabels
+	}
+	bottom := figurePadding + figureLineHeight + 4 // the x axis label
+	if len(ticksX) > 0 {
+		bottom += figureTickLength + figureLineHeight + 2
+	}
+	figureWidth := max(left+width+right, 2*figurePadding+labelWidth(title))
+	img := image.NewNRGBA(image.Rect(0, 0, figureWidth, top+height+bottom))
+	draw.Draw(img, img.Bounds(), image.NewUniform(figureBackground), image.Point{}, draw.Src)
+
+	text := func(s string, x int, baseline int) {
+		d := font.Drawer{Dst: img, Src: image.NewUniform(figureInk), Face: figureFace, Dot: fixed.P(x, baseline)}
+		d.DrawString(s)
+	}
+	line := func(x0 int, y0 int, x1 int, y1 int) {
+		draw.Draw(img, image.Rect(x0, y0, x1+1, y1+1), image.NewUniform(figureInk), image.Point{}, draw.Src)
+	}
+
+	// the plane, enlarged, within a frame
+	for y := range height {
+		for x := range width {
+			index := rendered.ColorIndexAt(x/scale, y/scale)
+			if index != 0 {
+				img.Set(left+x, top+y, RenderPalette[index])
+			}
+		}
+	}
+	line(left-1, top-1, left+width, top-1)
+	line(left-1, top+height, left+width, top+height)
+	line(left-1, top-1, left-1, top+height)
+	line(left+width, top-1, left+width, top+height)
+
+	if !labels.NoTitle {
+		text(title, (figureWidth-labelWidth(title))/2, figurePadding+figureAscent)
+	}
+	text(axisLabel(dimY), figurePadding, top-4-figureLineHeight+figureAscent)
+	for _, tick := range ticksY {
+		y := top + tick.position
+		line(left-1-figureTickLength, y, left-2, y)
+		text(tick.label, left-2-figureTickLength-2-labelWidth(tick.label), y-figureLineHeight/2+figureAscent)
+	}
+	baseline := top + height + 2
+	for _, tick := range ticksX {
+		x := left + tick.position
+		line(x, top+height+1, x, top+height+figureTickLength)
+		text(tick.label, x-labelWidth(tick.label)/2, top+height+figureTickLength+2+figureAscent)
+	}
+	if len(ticksX) > 0 {
+		baseline += figureTickLength + figureLineHeight + 2
+	}
+	text(axisLabel(dimX), left+(width-labelWidth(axisLabel(dimX)))/2, baseline+4+figureAscent-2)
+
+	if !labels.NoColorbar {
+		barLeft := left + width + 2*figurePadding
+		for y := range height {
+			t := 0.5
+			if height > 1 {
+				t = 1 - float64(y)/float64(height-1)
+			}
+			c := rampColor(t)
+			for x := range figureBarWidth {
+				img.Set(barLeft+x, top+y, c)
+			}
+		}
+		line(barLeft-1, top-1, barLeft+figureBarWidth, top-1)
+		line(barLeft-1, top+height, barLeft+figureBarWidth, top+height)
+		line(barLeft-1, top-1, barLeft-1, top+height)
+		line(barLeft+figureBarWidth, top-1, barLeft+figureBarWidth, top+height)
+		text(unit, barLeft, top-4-figureLineHeight+figureAscent)
+		for _, tick := range ticksBar {
+			y := top + tick.position
+			line(barLeft+figureBarWidth+1, y, barLeft+figureBarWidth+figureTickLength, y)
+			text(tick.label, barLeft+figureBarWidth+figureTickLength+2, y-figureLineHeight/2+figureAscent)
+		}
+	}
+	return img, nil
+}
+
+// The name of the dimension, followed by the unit of its axis in parentheses if it has one.
+func axisLabel(d Dimension) string {
+	if d.Axis == nil || d.Axis.Unit == "" {
+		return d.Name
+	}
+	return fmt.Sprintf("%s (%s)", d.Name, d.Axis.Unit)
+}
+
+// The title naming the layer, the channel and its unit, and the value of the plane in every dimension beyond
+// the first two.
+func figureTitle(l Layer, channel int, plane SampleCoordinate) string {
+	title := l.Name
+	if name := l.Channels[channel].Name; name != "" && name != l.Name {
+		title += ": " + name
+	}
+	if unit := l.Channels[channel].Unit; unit != "" {
+		title += " (" + unit + ")"
+	}
+	at := []string{}
+	for d := 2; d < len(l.Dimensions); d++ {
+		dim := l.Dimensions[d]
+		value := fmt.Sprint(plane[d])
+		if t, err := dim.Axis.TimeValue(plane[d]); err == nil {
+			value = t.Format("2006-01-02 15:04:05")
+		} else if v := dim.Axis.StepValue(plane[d]); v != nil {
+			value = fmt.Sprint(v)
+			if dim.Axis.Unit != "" {
+				value += " " + dim.Axis.Unit
+			}
+		}
+		at = append(at, fmt.Sprintf("%s = %s", dim.Name, value))
+	}
+	if len(at) > 0 {
+		title += " at " + strings.Join(at, ", ")
+	}
+	return title
+}
+
+// The ticks along the dimension drawn with the given number of pixels per sample: at round values of its
+// axis that fall within it, or at round indices if it has no numeric axis. Ticks are at the centers of the
+// samples they fall on, or between them.
+func dimensionTicks(d Dimension, scale int, count int) []figureTick {
+	values, ok := []float64(nil), false
+	if d.Axis != nil {
+		values, ok = axisValues(d)
+	}
+	if _, located := d.Locate(0); !ok || !located || d.Size < 2 {
+		last := float64(d.Size - 1)
+		ticks := []figureTick{}
+		for _, index := range niceTicks(0, last, count) {
+			if index == math.Trunc(index) {
+				ticks = append(ticks, figureTick{position: int(index)*scale + scale/2, label: tickLabel(index, 1)})
+			}
+		}
+		return ticks
+	}
+	low, high := math.Min(values[0], values[len(values)-1]), math.Max(values[0], values[len(values)-1])
+	step := niceStep(low, high, count)
+	ticks := []figureTick{}
+	for _, value := range niceTicks(low, high, count) {
+		position, _ := d.Locate(value)
+		if !position.InRange {
+			continue
+		}
+		pixel := (float64(position.Index) + position.Fraction + 0.5) * float64(scale)
+		ticks = append(ticks, figureTick{position: int(math.Round(pixel)), label: tickLabel(value, step)})
+	}
+	return ticks
+}
+
+// The spacing of round values between the two values giving no more than the given number of them: one, two,
+// or five times a power of ten.
+func niceStep(low float64, high float64, count int) float64 {
+	span := high - low
+	if span <= 0 || count < 1 || math.IsInf(span, 0) || math.IsNaN(span) {
+		return 0
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(span/float64(count))))
+	for _, factor := range []float64{1, 2, 5, 10} {
+		step := factor * magnitude
+		if math.Floor(high/step)-math.Ceil(low/step)+1 <= float64(count) {
+			return step
+		}
+	}
+	return 10 * magnitude
+}
+
+// The round values between the two values (inclusive), spaced as by niceStep.
+func niceTicks(low float64, high float64, count int) []float64 {
+	step := niceStep(low, high, count)
+	if step == 0 {
+		if high == low && !math.IsNaN(low) && !math.IsInf(low, 0) {
+			return []float64{low}
+		}
+		return nil
+	}
+	ticks := []float64{}
+	for i := math.Ceil(low / step); i <= math.Floor(high/step); i++ {
+		value := i * step
+		if value == 0 {
+			value = 0 // no negative zero
+		}
+		ticks = append(ticks, value)
+	}
+	return ticks
+}
+
+// Formats a tick value with as many decimals as the spacing of the ticks needs.
+func tickLabel(value float64, step float64) string {
+	magnitude := math.Abs(value)
+	if magnitude != 0 && (magnitude >= 1e7 || magnitude < 1e-4) {
+		return strconv.FormatFloat(value, 'g', 4, 64)
+	}
+	decimals := 0
+	if step > 0 && step < 1 {
+		decimals = int(math.Ceil(-math.Log10(step) - 1e-9))
+	} else if step == 0 && value != math.Trunc(value) {
+		return strconv.FormatFloat(value, 'g', 4, 64)
+	}
+	return strconv.FormatFloat(value, 'f', decimals, 64)
+}
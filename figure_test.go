@@ -0,0 +1,180 @@
+package gopixi
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// The number of pixels of the figure within the rectangle with the color of the figure ink.
+func figureInkPixels(img *image.NRGBA, r image.Rectangle) int {
+	count := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.NRGBAAt(x, y) == figureInk {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestRenderFigure(t *testing.T) {
+	dims := DimensionSet{
+		{Name: "lon", Size: 8, TileSize: 4, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(-20), Step: float32(5), Unit: "degrees_east"}},
+		{Name: "lat", Size: 4, TileSize: 4, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(60), Step: float32(-10), Unit: "degrees_north"}},
+		{Name: "time", Size: 2, TileSize: 1, Axis: &Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(6), Unit: "hours since 2000-01-01"}},
+	}
+	values := renderTestLayer(t, dims, ChannelSet{{Name: "tas", Type: ChannelFloat32, Unit: "K"}}, func(coord SampleCoordinate) float64 {
+		return float64(coord[0] + 8*coord[1] + 100*coord[2])
+	})
+
+	plane, err := RenderPlane(values, SampleCoordinate{0, 0, 1}, RenderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	figure, err := RenderFigure(values, SampleCoordinate{0, 0, 1}, RenderOptions{}, FigureLabels{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the longer side of the plane is enlarged to 256 pixels by a scale of 32, with room for the labels
+	if figure.Bounds().Dx() <= 8*32 || figure.Bounds().Dy() <= 4*32 {
+		t.Fatalf("unexpected figure bounds %v", figure.Bounds())
+	}
+
+	// the plane is drawn enlarged with the colors of RenderPlane
+	first := RenderPalette[plane.ColorIndexAt(0, 0)]
+	origin := image.Point{-1, -1}
+	for y := 0; y < figure.Bounds().Dy() && origin.X < 0; y++ {
+		for x := 0; x < figure.Bounds().Dx(); x++ {
+			if color.NRGBAModel.Convert(figure.At(x, y)) == first {
+				origin = image.Point{x, y}
+				break
+			}
+		}
+	}
+	if origin.X < 0 {
+		t.Fatal("expected the plane to be drawn in the figure")
+	}
+	for _, coord := range []image.Point{{0, 0}, {7, 0}, {3, 2}, {7, 3}} {
+		expected := color.NRGBAModel.Convert(RenderPalette[plane.ColorIndexAt(coord.X, coord.Y)])
+		for _, offset := range []image.Point{{0, 0}, {31, 31}} {
+			at := origin.Add(coord.Mul(32)).Add(offset)
+			if got := figure.At(at.X, at.Y); got != expected {
+				t.Errorf("expected sample %v to be drawn with %v at %v, got %v", coord, expected, at, got)
+			}
+		}
+	}
+
+	// the labels are drawn above, to the left of, below, and right of the plane
+	plot := image.Rect(origin.X, origin.Y, origin.X+8*32, origin.Y+4*32)
+	bounds := figure.Bounds()
+	for name, margin := range map[string]image.Rectangle{
+		"title and y axis label": image.Rect(0, 0, bounds.Dx(), plot.Min.Y-1),
+		"y tick labels":          image.Rect(0, plot.Min.Y, plot.Min.X-1, plot.Max.Y),
+		"x tick labels":          image.Rect(plot.Min.X, plot.Max.Y+1, plot.Max.X, bounds.Dy()),
+		"colorbar":               image.Rect(plot.Max.X+1, plot.Min.Y, bounds.Dx(), plot.Max.Y),
+	} {
+		if figureInkPixels(figure, margin) == 0 {
+			t.Errorf("expected the %s to be drawn", name)
+		}
+	}
+
+	// without any labels, only the frame of the plane is drawn outside it
+	bare, err := RenderFigure(values, nil, RenderOptions{}, FigureLabels{NoTitle: true, NoColorbar: true, Ticks: -1, Scale: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bare.Bounds().Dx() >= figure.Bounds().Dx() || bare.Bounds().Dy() >= figure.Bounds().Dy() {
+		t.Errorf("expected a smaller figure without labels, got %v", bare.Bounds())
+	}
+
+	if _, err := RenderFigure(values, nil, RenderOptions{Channel: "missing"}, FigureLabels{}); err == nil {
+		t.Error("expected error for a missing channel")
+	}
+	if _, err := RenderFigure(values, SampleCoordinate{0, 0, 2}, RenderOptions{}, FigureLabels{}); err == nil {
+		t.Error("expected error for a plane outside the layer")
+	}
+}
+
+func TestFigureLabels(t *testing.T) {
+	l := Layer{
+		Name: "air",
+		Dimensions: DimensionSet{
+			{Name: "x", Size: 10},
+			{Name: "level", Size: 3, Axis: &Axis{Type: ChannelFloat32, Coordinates: []any{float32(1000), float32(850), float32(500)}, Unit: "hPa"}},
+			{Name: "time", Size: 4, Axis: &Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(6), Unit: "hours since 2000-01-01"}},
+			{Name: "member", Size: 5},
+		},
+		Channels: ChannelSet{{Name: "temperature", Unit: "K"}},
+	}
+	if title := figureTitle(l, 0, SampleCoordinate{0, 0, 3, 2}); title != "air: temperature (K) at time = 2000-01-01 18:00:00, member = 2" {
+		t.Errorf("unexpected title '%s'", title)
+	}
+	if label := axisLabel(l.Dimensions[1]); label != "level (hPa)" {
+		t.Errorf("unexpected axis label '%s'", label)
+	}
+
+	// ticks fall at round axis values, between samples if need be, or at round indices without an axis
+	ticks := dimensionTicks(l.Dimensions[1], 10, 5)
+	if !reflect.DeepEqual(ticks, []figureTick{{22, "600"}, {16, "800"}, {5, "1000"}}) {
+		t.Errorf("unexpected coordinate ticks %v", ticks)
+	}
+	if ticks := dimensionTicks(l.Dimensions[0], 4, 5); !reflect.DeepEqual(ticks, []figureTick{{2, "0"}, {10, "2"}, {18, "4"}, {26, "6"}, {34, "8"}}) {
+		t.Errorf("unexpected index ticks %v", ticks)
+	}
+
+	for _, test := range []struct {
+		low, high float64
+		count     int
+		expected  []float64
+	}{
+		{0, 111, 5, []float64{0, 50, 100}},
+		{-0.3, 0.3, 5, []float64{-0.2, -0.1, 0, 0.1, 0.2}},
+		{-0.3, 0.3, 3, []float64{-0.2, 0, 0.2}},
+		{270.5, 301, 4, []float64{280, 290, 300}},
+		{3, 3, 5, []float64{3}},
+	} {
+		if ticks := niceTicks(test.low, test.high, test.count); !reflect.DeepEqual(ticks, test.expected) {
+			t.Errorf("expected ticks %v from %v to %v, got %v", test.expected, test.low, test.high, ticks)
+		}
+	}
+	for expected, label := range map[string]string{
+		"0.25":    tickLabel(0.25, 0.05),
+		"-40":     tickLabel(-40, 20),
+		"1.5e+08": tickLabel(1.5e8, 5e7),
+	} {
+		if label != expected {
+			t.Errorf("expected label %s, got %s", expected, label)
+		}
+	}
+}
+
+func TestExportLabeledFrames(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 4, TileSize: 2}, {Name: "y", Size: 3, TileSize: 3}, {Name: "t", Size: 2, TileSize: 1}}
+	values := renderTestLayer(t, dims, ChannelSet{{Name: "v", Type: ChannelFloat32}}, func(coord SampleCoordinate) float64 {
+		return float64(coord[0] + 4*coord[1] + 100*coord[2])
+	})
+	paths, err := ExportFrames(t.TempDir(), values, "t", AnimationOptions{Labels: &FigureLabels{Scale: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(paths))
+	}
+	file, err := os.Open(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	frame, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Bounds().Dx() <= 40 || frame.Bounds().Dy() <= 30 {
+		t.Errorf("expected a labeled frame larger than the plane, got %v", frame.Bounds())
+	}
+}
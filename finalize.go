@@ -0,0 +1,248 @@
+package gopixi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"maps"
+)
+
+// The tag under which Finalize records the hex-encoded SHA-256 digest of all file content preceding the
+// footer (the header and every tile).
+const DigestTag string = "pixi.sha256"
+
+// Writes a Pixi file whose tags and layers only become visible to readers once Finalize writes its index as
+// a footer. Until then ReadPixi sees an empty file. Close releases the resources held by the writer.
+type DeferredWriter struct {
+	pixi      *Pixi
+	stream    *digestStream
+	iterator  *TileOrderWriteIterator
+	digest    string
+	finalized bool
+	closed    bool
+}
+
+// Starts a new deferred file by writing the given header, with no tags or layers referenced, to the start
+// of the stream.
+func NewDeferredWriter(w io.WriteSeeker, header Header) (*DeferredWriter, error) {
+	header.FirstLayerOffset = 0
+	header.FirstTagsOffset = 0
+	stream := &digestStream{WriteSeeker: w, hash: sha256.New()}
+	pixi, err := Create(stream, header)
+	if err != nil {
+		return nil, err
+	}
+	return &DeferredWriter{pixi: pixi, stream: stream}, nil
+}
+
+// The metadata of everything written so far, as it will appear once the file is finalized.
+func (d *DeferredWriter) Pixi() *Pixi {
+	return d.pixi
+}
+
+// Adds tags to be written with the index when the file is finalized.
+func (d *DeferredWriter) AddTags(tags map[string]string) error {
+	if err := d.checkWritable("add tags"); err != nil {
+		return err
+	}
+	if len(d.pixi.Tags) == 0 {
+		d.pixi.Tags = append(d.pixi.Tags, TagSection{Tags: map[string]string{}})
+	}
+	maps.Copy(d.pixi.Tags[0].Tags, tags)
+	return nil
+}
+
+// Adds attributes to be written with the index when the file is finalized, as for Pixi.AppendAttributes.
+func (d *DeferredWriter) AddAttributes(attributes map[string]any) error {
+	if err := d.checkWritable("add attributes"); err != nil {
+		return err
+	}
+	if err := checkAttributes(attributes); err != nil {
+		return err
+	}
+	if len(d.pixi.Tags) == 0 {
+		d.pixi.Tags = append(d.pixi.Tags, TagSection{Tags: map[string]string{}})
+	}
+	if d.pixi.Tags[0].Attributes == nil {
+		d.pixi.Tags[0].Attributes = map[string]any{}
+	}
+	maps.Copy(d.pixi.Tags[0].Attributes, attributes)
+	return nil
+}
+
+// Adds shared axes to be written with the index when the file is finalized, as for Pixi.AppendAxes. Layers appended
+// afterwards may refer to them with Axis.Ref.
+func (d *DeferredWriter) AddAxes(axes map[string]*Axis) error {
+	if err := d.checkWritable("add axes"); err != nil {
+		return err
+	}
+	for id, axis := range axes {
+		if err := checkSharedAxis(id, axis); err != nil {
+			return err
+		}
+	}
+	if len(d.pixi.Tags) == 0 {
+		d.pixi.Tags = append(d.pixi.Tags, TagSection{Tags: map[string]string{}})
+	}
+	if d.pixi.Tags[0].Axes == nil {
+		d.pixi.Tags[0].Axes = map[string]*Axis{}
+	}
+	maps.Copy(d.pixi.Tags[0].Axes, axes)
+	return nil
+}
+
+// Writes all of the tile data of a new layer using the generator, in the same manner as
+// Pixi.AppendIterativeLayer. The layer header itself is not written until the file is finalized. A single
+// write iterator is reused for every layer appended by the writer. Layers with halos are unsupported, as
+// their halos can only be filled from tiles already written with Pixi.AppendHaloLayer.
+func (d *DeferredWriter) AppendLayer(layer Layer, generator func(writer IterativeLayerWriter) error) error {
+	if err := d.checkWritable("append layer"); err != nil {
+		return err
+	}
+	if layer.Dimensions.HasHalo() {
+		return ErrUnsupported("layers with halos cannot be written iteratively")
+	}
+	if err := layer.checkStrings(); err != nil {
+		return err
+	}
+	_, err := d.stream.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if d.iterator == nil {
+		d.iterator = NewTileOrderWriteIterator(d.stream, d.pixi.Header, layer)
+	} else {
+		d.iterator.Reset(d.stream, d.pixi.Header, layer)
+	}
+	d.iterator.setWriteHooks(d.pixi.WriteHooks, len(d.pixi.Layers))
+	if err := generator(d.iterator); err != nil {
+		d.iterator.Done()
+		return err
+	}
+	d.iterator.Done()
+	if err := d.iterator.Error(); err != nil {
+		return err
+	}
+	d.pixi.Layers = append(d.pixi.Layers, d.iterator.Layer())
+	return d.pixi.layerFinalized(len(d.pixi.Layers) - 1)
+}
+
+// Makes the file visible to readers by computing the digest of everything written so far, recording it
+// under DigestTag, and writing the index of all tags and layers as a footer at the end of the file. If the
+// stream supports Sync (as *os.File does), it is synced to stable storage. Once finalized, no more tags or
+// layers can be added; finalizing again has no effect.
+func (d *DeferredWriter) Finalize() error {
+	if d.finalized {
+		return nil
+	}
+	if d.closed {
+		return ErrReadOnly{Operation: "finalize closed writer"}
+	}
+	footerStart, err := d.stream.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	d.digest = hex.EncodeToString(d.stream.hash.Sum(nil))
+	if err := d.AddTags(map[string]string{DigestTag: d.digest}); err != nil {
+		return err
+	}
+	// the footer is not part of the digest, so it is written directly to the underlying stream
+	if err := d.pixi.WriteFooter(d.stream.WriteSeeker, footerStart); err != nil {
+		return err
+	}
+	if syncer, ok := d.stream.WriteSeeker.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			return err
+		}
+	}
+	d.finalized = true
+	d.pixi.ReadOnly = true
+	return nil
+}
+
+// The hex-encoded SHA-256 digest recorded when the file was finalized, or the empty string if it has not
+// been finalized.
+func (d *DeferredWriter) Digest() string {
+	return d.digest
+}
+
+// Releases the tile buffers and compressors held by the writer, and closes the underlying stream if it
+// is an io.Closer. Closing does not finalize the file: a writer closed before Finalize leaves a file that
+// readers see as empty, unless the stream can be aborted (as S3MultipartWriter can), in which case it is
+// aborted instead so that no file is created at all. Closing again has no effect.
+func (d *DeferredWriter) Close() error {
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	d.iterator = nil
+	if aborter, ok := d.stream.WriteSeeker.(interface{ Abort() error }); ok && !d.finalized {
+		return aborter.Abort()
+	}
+	if closer, ok := d.stream.WriteSeeker.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (d *DeferredWriter) checkWritable(operation string) error {
+	if d.finalized || d.closed {
+		return ErrReadOnly{Operation: operation}
+	}
+	return nil
+}
+
+// Checks that the content preceding the footer of a finalized file matches the digest recorded under
+// DigestTag, returning an ErrFormat error if it does not or if no digest was recorded.
+func (p *Pixi) VerifyDigest(r io.ReadSeeker) error {
+	expected, ok := p.AllTags()[DigestTag]
+	if !ok {
+		return ErrFormat("file has no recorded digest")
+	}
+	trailer, err := ReadTrailer(r)
+	if err != nil {
+		return err
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	_, err = io.CopyN(hash, r, trailer.FooterStart)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != expected {
+		return ErrFormat("file content does not match recorded digest")
+	}
+	return nil
+}
+
+// Hashes everything written through it. A deferred file is only ever appended to, so every write must
+// continue exactly where the previously hashed data ended for the digest to describe the file.
+type digestStream struct {
+	io.WriteSeeker
+	hash   hash.Hash
+	offset int64
+	hashed int64
+}
+
+func (s *digestStream) Write(p []byte) (int, error) {
+	if s.offset != s.hashed {
+		return 0, ErrUnsupported("deferred writers only support sequential writes")
+	}
+	n, err := s.WriteSeeker.Write(p)
+	s.hash.Write(p[:n])
+	s.offset += int64(n)
+	s.hashed += int64(n)
+	return n, err
+}
+
+func (s *digestStream) Seek(offset int64, whence int) (int64, error) {
+	pos, err := s.WriteSeeker.Seek(offset, whence)
+	if err == nil {
+		s.offset = pos
+	}
+	return pos, err
+}
@@ -0,0 +1,121 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestDeferredWriterFinalize(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	writer, err := NewDeferredWriter(buf, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 2 {
+		layer := NewLayer("layer", DimensionSet{{Name: "x", Size: 6, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint16}})
+		err = writer.AppendLayer(layer, func(writer IterativeLayerWriter) error {
+			for writer.Next() {
+				writer.SetSample(Sample{uint16(writer.Coordinate()[0] * (i + 1))})
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.AddTags(map[string]string{"source": "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is visible before the file is finalized
+	pending, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending.Layers) != 0 || len(pending.AllTags()) != 0 {
+		t.Errorf("expected unfinalized file to be empty, got %d layers and tags %v", len(pending.Layers), pending.AllTags())
+	}
+
+	if err := writer.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+	if writer.Digest() == "" {
+		t.Error("expected digest to be computed on finalize")
+	}
+	var roErr ErrReadOnly
+	if err := writer.AddTags(map[string]string{"late": "tag"}); !errors.As(err, &roErr) {
+		t.Errorf("expected read-only error after finalize, got %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Layers) != 2 {
+		t.Fatalf("expected 2 layers, got %d", len(read.Layers))
+	}
+	tags := read.AllTags()
+	if tags["source"] != "test" || tags[DigestTag] != writer.Digest() {
+		t.Errorf("unexpected tags %v", tags)
+	}
+	for i, layer := range read.Layers {
+		access := NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), read.Header, layer, 4)
+		for x := range 6 {
+			sample, err := SampleAt(access, SampleCoordinate{x})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sample[0] != uint16(x*(i+1)) {
+				t.Errorf("layer %d sample %d: expected %d, got %v", i, x, x*(i+1), sample[0])
+			}
+		}
+	}
+
+	if err := read.VerifyDigest(buffer.NewBufferFrom(buf.Bytes())); err != nil {
+		t.Errorf("expected digest to verify, got %v", err)
+	}
+	corrupted := append([]byte{}, buf.Bytes()...)
+	corrupted[read.Layers[0].TileOffsets[0]] ^= 0xff
+	if err := read.VerifyDigest(buffer.NewBufferFrom(corrupted)); err == nil {
+		t.Error("expected digest verification of corrupted file to fail")
+	}
+}
+
+func TestDeferredWriterCloseWithoutFinalize(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	writer, err := NewDeferredWriter(buf, NewHeader(binary.BigEndian, OffsetSize8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("layer", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	err = writer.AppendLayer(layer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{uint8(1)})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var roErr ErrReadOnly
+	if err := writer.Finalize(); !errors.As(err, &roErr) {
+		t.Errorf("expected finalize after close to fail, got %v", err)
+	}
+
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Layers) != 0 {
+		t.Errorf("expected abandoned file to be empty, got %d layers", len(read.Layers))
+	}
+}
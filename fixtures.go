@@ -0,0 +1,674 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A small synthetic dataset exercising one part of the file format, whose every sample value is given by
+// FixtureValue, for checking that an implementation reads files exactly as this package writes them.
+type Fixture struct {
+	Name        string // A short identifier, usable as a file name.
+	Description string
+	Header      Header
+	Tags        map[string]string
+	Attributes  map[string]any   // Attributes of the dataset, written in a tag section of their own.
+	Axes        map[string]*Axis // Shared axes of the dataset, written in a tag section of their own.
+	Layers      []Layer          // The layers of the dataset, whose tiles are written by Write.
+	// The disk tiles of each layer (by layer index) that are left unwritten, to exercise sparse layers.
+	Absent map[int][]int
+}
+
+// The types of channel the fixtures cover: every base type the format defines.
+var fixtureChannelTypes = []ChannelType{
+	ChannelInt8, ChannelUint8, ChannelInt16, ChannelUint16, ChannelInt32, ChannelUint32, ChannelInt64, ChannelUint64,
+	ChannelFloat8, ChannelFloat16, ChannelFloat32, ChannelFloat64, ChannelBool, ChannelInt128, ChannelUint128,
+	ChannelFloat128, ChannelBFloat16,
+}
+
+// The compressions the fixtures cover: every compression the format defines.
+var fixtureCompressions = []Compression{CompressionNone, CompressionFlate, CompressionLzwLsb, CompressionLzwMsb, CompressionRle8, CompressionZstd, CompressionLz4, CompressionProgressive}
+
+// The value of a channel of the given type at the sample with the given index (its position in the order of
+// DimensionSet.SampleCoordinates) in every fixture.
+func FixtureValue(t ChannelType, channelIndex int, sampleIndex int) any {
+	pattern := (sampleIndex/2*37+channelIndex*11)%97 - 32
+	if t.Base() == ChannelString {
+		if pattern%5 == 0 {
+			return ""
+		}
+		return fmt.Sprintf("value %d", pattern)
+	}
+	return t.FromFloat64(float64(pattern))
+}
+
+// The sample of a layer of a fixture at the given coordinate.
+func (f Fixture) Sample(layerIndex int, coord SampleCoordinate) Sample {
+	layer := f.Layers[layerIndex]
+	index, stride := 0, 1
+	for d, dim := range layer.Dimensions {
+		index += coord[d] * stride
+		stride *= dim.Size
+	}
+	sample := make(Sample, len(layer.Channels))
+	for i, channel := range layer.Channels {
+		sample[i] = FixtureValue(channel.Type, i, index)
+	}
+	return sample
+}
+
+// Writes the fixture to the stream, returning the metadata of the written file.
+func (f Fixture) Write(w io.WriteSeeker) (*Pixi, error) {
+	p, err := Create(w, f.Header)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Tags) > 0 {
+		if err := p.AppendTags(w, f.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if len(f.Attributes) > 0 {
+		if err := p.AppendAttributes(w, f.Attributes); err != nil {
+			return nil, err
+		}
+	}
+	if len(f.Axes) > 0 {
+		if err := p.AppendAxes(w, f.Axes); err != nil {
+			return nil, err
+		}
+	}
+	for layerIndex, template := range f.Layers {
+		layer := template
+		layer.Channels = slices.Clone(template.Channels)
+		layer.TileBytes = make([]int64, template.DiskTiles())
+		layer.TileOffsets = make([]int64, template.DiskTiles())
+		layer.StringBytes = template.emptyStringBytes()
+		if _, err := w.Seek(0, io.SeekEnd); err != nil {
+			return nil, err
+		}
+		encoder := &tileEncoder{}
+		for tile := range layer.DiskTiles() {
+			if slices.Contains(f.Absent[layerIndex], tile) {
+				continue
+			}
+			data := f.tileData(layerIndex, layer, tile)
+			if err := layer.writeTileWith(encoder, w, p.Header, tile, data); err != nil {
+				return nil, err
+			}
+			layer.updateTileStatistics(p.Header, tile, data)
+		}
+		if err := p.appendLayerHeader(w, layer); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Builds the decoded data of a disk tile of the layer from the fixture's sample values.
+func (f Fixture) tileData(layerIndex int, layer Layer, tile int) []byte {
+	data := make([]byte, layer.slotsTileSize(tile))
+	tiles := layer.Dimensions.Tiles()
+	layer.forEachTileSample(tile%tiles, func(inTile int, coord SampleCoordinate) {
+		sample := f.Sample(layerIndex, coord)
+		if layer.Separated {
+			channelIndex := tile / tiles
+			channel := layer.Channels[channelIndex]
+			switch channel.Type.Base() {
+			case ChannelBool:
+				PackBool(sample[channelIndex].(bool), data, inTile)
+			case ChannelString:
+				data = putString(data, inTile*channel.Size(), sample[channelIndex].(string), f.Header.ByteOrder)
+			default:
+				channel.PutValue(sample[channelIndex], f.Header.ByteOrder, data[inTile*channel.Size():])
+			}
+			return
+		}
+		offset := inTile * layer.Channels.Size()
+		for i, channel := range layer.Channels {
+			if channel.Type.Base() == ChannelString {
+				data = putString(data, offset, sample[i].(string), f.Header.ByteOrder)
+			} else {
+				channel.PutValue(sample[i], f.Header.ByteOrder, data[offset:])
+			}
+			offset += channel.Size()
+		}
+	})
+	layer.forEachHaloSample(tile%tiles, func(slot int, coord SampleCoordinate) {
+		layer.putStoredSample(f.Header, data, tile/tiles, layer.Dimensions.TileSamples()+slot, f.Sample(layerIndex, coord))
+	})
+	return data
+}
+
+// Builds the full set of conformance fixtures: every channel type in both byte orders, both offset sizes,
+// and every format version; every compression with contiguous and separated storage; sparse layers; tile
+// sizes of one sample, of the whole dimension, and not dividing the dimension; tile halos; channel fill
+// values; axes, units, tags, and attributes; every checksum algorithm; and friendly strings longer than the version 1 limit.
+func ConformanceFixtures() []Fixture {
+	fixtures := []Fixture{}
+	allTypes := ChannelSet{}
+	for _, t := range fixtureChannelTypes {
+		allTypes = append(allTypes, Channel{Name: t.String(), Type: t})
+	}
+	grid := DimensionSet{{Name: "x", Size: 5, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}}
+	mixed := ChannelSet{{Name: "a", Type: ChannelUint8}, {Name: "b", Type: ChannelInt16}, {Name: "c", Type: ChannelFloat32}, {Name: "d", Type: ChannelBool}}
+	filled := ChannelSet{
+		{Name: "a", Type: ChannelUint8, FillValue: uint8(255)},
+		{Name: "b", Type: ChannelInt16, FillValue: int16(-1)},
+		{Name: "c", Type: ChannelFloat32, FillValue: float32(-9999)},
+		{Name: "d", Type: ChannelBool},
+	}
+
+	for version := 1; version <= Version; version++ {
+		for _, header := range allHeaderVariants(version) {
+			order := "le"
+			if header.ByteOrder == binary.BigEndian {
+				order = "be"
+			}
+			for _, separated := range []bool{false, true} {
+				storage, opts := "contiguous", []LayerOption{}
+				if separated {
+					storage, opts = "separated", []LayerOption{WithPlanar()}
+				}
+				fixtures = append(fixtures, Fixture{
+					Name:        fmt.Sprintf("v%d-%s%d-types-%s", version, order, header.OffsetSize, storage),
+					Description: fmt.Sprintf("every channel type, %s, version %d, %v, %d-byte offsets", storage, version, header.ByteOrder, header.OffsetSize),
+					Header:      header,
+					Layers:      []Layer{NewLayer("types", slices.Clone(grid), slices.Clone(allTypes), opts...)},
+				})
+			}
+		}
+	}
+
+	for _, checksum := range []ChecksumAlgorithm{ChecksumCRC32C, ChecksumXXHash64, ChecksumSHA256} {
+		for _, header := range []Header{NewHeader(binary.LittleEndian, OffsetSize8), NewHeader(binary.BigEndian, OffsetSize4)} {
+			order := "le"
+			if header.ByteOrder == binary.BigEndian {
+				order = "be"
+			}
+			header.Checksum = checksum
+			fixtures = append(fixtures, Fixture{
+				Name:        fmt.Sprintf("checksum-%s-%s", checksum, order),
+				Description: fmt.Sprintf("tiles with %s checksums, %v", checksum, header.ByteOrder),
+				Header:      header,
+				Layers: []Layer{
+					NewLayer("contiguous", slices.Clone(grid), slices.Clone(mixed), WithCompression(CompressionFlate)),
+					NewLayer("separated", slices.Clone(grid), slices.Clone(mixed), WithPlanar()),
+				},
+			})
+		}
+	}
+
+	header := NewHeader(binary.LittleEndian, OffsetSize8)
+	gridX := &Axis{Type: ChannelFloat64, Minimum: 0.0, Step: 1.5, Unit: "km"}
+	levels := &Axis{Type: ChannelFloat32, Unit: "hPa", Coordinates: []any{float32(1000), float32(850), float32(500), float32(250)}}
+	for _, compression := range fixtureCompressions {
+		for _, separated := range []bool{false, true} {
+			storage, opts := "contiguous", []LayerOption{WithCompression(compression)}
+			if separated {
+				storage, opts = "separated", append(opts, WithPlanar())
+			}
+			fixtures = append(fixtures, Fixture{
+				Name:        fmt.Sprintf("compression-%s-%s", compression, storage),
+				Description: fmt.Sprintf("%s compression, %s", compression, storage),
+				Header:      header,
+				Layers:      []Layer{NewLayer("compressed", DimensionSet{{Name: "x", Size: 12, TileSize: 5}, {Name: "y", Size: 6, TileSize: 4}}, slices.Clone(mixed), opts...)},
+			})
+		}
+	}
+
+	fixtures = append(fixtures,
+		Fixture{
+			Name:        "sparse-contiguous",
+			Description: "a contiguous layer with unwritten tiles",
+			Header:      header,
+			Layers:      []Layer{NewLayer("sparse", DimensionSet{{Name: "x", Size: 8, TileSize: 2}, {Name: "y", Size: 4, TileSize: 2}}, slices.Clone(mixed), WithCompression(CompressionFlate))},
+			Absent:      map[int][]int{0: {1, 2, 6}},
+		},
+		Fixture{
+			Name:        "sparse-separated",
+			Description: "a separated layer with unwritten tiles, including every tile of one channel",
+			Header:      header,
+			Layers:      []Layer{NewLayer("sparse", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, slices.Clone(mixed), WithPlanar())},
+			Absent:      map[int][]int{0: {1, 4, 5}},
+		},
+		Fixture{
+			Name:        "fill-values",
+			Description: "layers with unwritten tiles read as the fill values of their channels, contiguous and separated",
+			Header:      header,
+			Layers: []Layer{
+				NewLayer("contiguous", DimensionSet{{Name: "x", Size: 6, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}}, slices.Clone(filled)),
+				NewLayer("separated", DimensionSet{{Name: "x", Size: 6, TileSize: 3}}, slices.Clone(filled), WithPlanar()),
+			},
+			Absent: map[int][]int{0: {0, 4}, 1: {1, 2, 7}},
+		},
+		Fixture{
+			Name:        "tiles-single-sample",
+			Description: "tiles of a single sample",
+			Header:      header,
+			Layers:      []Layer{NewLayer("single", DimensionSet{{Name: "x", Size: 3, TileSize: 1}, {Name: "y", Size: 2, TileSize: 1}}, slices.Clone(mixed))},
+		},
+		Fixture{
+			Name:        "tiles-whole-dimension",
+			Description: "one tile covering the whole layer",
+			Header:      header,
+			Layers:      []Layer{NewLayer("whole", DimensionSet{{Name: "x", Size: 7, TileSize: 7}, {Name: "y", Size: 3, TileSize: 3}}, slices.Clone(mixed))},
+		},
+		Fixture{
+			Name:        "tiles-partial",
+			Description: "tile sizes that do not divide the dimensions, in one, three, and four dimensions",
+			Header:      header,
+			Layers: []Layer{
+				NewLayer("one", DimensionSet{{Name: "x", Size: 11, TileSize: 4}}, slices.Clone(mixed)),
+				NewLayer("three", DimensionSet{{Name: "x", Size: 5, TileSize: 3}, {Name: "y", Size: 4, TileSize: 3}, {Name: "z", Size: 3, TileSize: 2}}, slices.Clone(mixed), WithPlanar()),
+				NewLayer("four", DimensionSet{{Name: "x", Size: 3, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}, {Name: "z", Size: 2, TileSize: 1}, {Name: "t", Size: 3, TileSize: 2}}, slices.Clone(mixed), WithCompression(CompressionRle8)),
+			},
+		},
+		Fixture{
+			Name:        "metadata",
+			Description: "tags, dimension axes, and channel units",
+			Header:      header,
+			Tags:        map[string]string{"title": "conformance fixture", "empty": "", "unicode": "température ℃"},
+			Layers: []Layer{NewLayer("metadata",
+				DimensionSet{
+					{Name: "lon", Size: 6, TileSize: 3, Axis: &Axis{Type: ChannelFloat64, Minimum: -180.0, Step: 0.25, Unit: "degrees_east"}},
+					{Name: "time", Size: 4, TileSize: 4, Axis: &Axis{Type: ChannelInt64, Minimum: int64(1700000000), Step: int64(3600), Unit: "s"}},
+				},
+				ChannelSet{{Name: "temperature", Type: ChannelFloat32, Unit: "K"}, {Name: "count", Type: ChannelUint16, Unit: "1"}},
+			)},
+		},
+		Fixture{
+			Name:        "axis-coordinates",
+			Description: "dimension axes with explicit, irregularly spaced coordinates alongside a regular axis",
+			Header:      header,
+			Layers: []Layer{NewLayer("levels",
+				DimensionSet{
+					{Name: "x", Size: 5, TileSize: 2, Axis: &Axis{Type: ChannelFloat64, Minimum: 0.0, Step: 1.5, Unit: "km"}},
+					{Name: "pressure", Size: 4, TileSize: 3, Axis: &Axis{Type: ChannelFloat32, Unit: "hPa",
+						Coordinates: []any{float32(1000), float32(850), float32(500), float32(250)}}},
+					{Name: "scan", Size: 3, TileSize: 3, Axis: &Axis{Type: ChannelInt64, Unit: "ms",
+						Coordinates: []any{int64(0), int64(1478), int64(3003)}}},
+				},
+				slices.Clone(mixed),
+			)},
+		},
+		Fixture{
+			Name:        "axis-references",
+			Description: "dimensions of several layers referring to regular and irregular shared axes, with and without a header dictionary",
+			Header:      header,
+			Axes:        map[string]*Axis{"grid-x": gridX, "levels": levels},
+			Layers: []Layer{
+				NewLayer("temperature", DimensionSet{
+					{Name: "x", Size: 5, TileSize: 2, Axis: withFixtureRef(gridX, "grid-x")},
+					{Name: "pressure", Size: 4, TileSize: 3, Axis: withFixtureRef(levels, "levels")},
+				}, slices.Clone(mixed)),
+				NewLayer("humidity", DimensionSet{
+					{Name: "x", Size: 5, TileSize: 5, Axis: withFixtureRef(gridX, "grid-x")},
+					{Name: "pressure", Size: 4, TileSize: 2, Axis: withFixtureRef(levels, "levels")},
+				}, slices.Clone(mixed), WithHeaderDictionary()),
+			},
+		},
+		Fixture{
+			Name:        "axis-calendars",
+			Description: "time axes counting dates in calendars without leap days and of thirty-day months",
+			Header:      header,
+			Layers: []Layer{NewLayer("climate",
+				DimensionSet{
+					{Name: "time", Size: 6, TileSize: 4, Axis: &Axis{Type: ChannelInt32, Minimum: int32(0), Step: int32(73),
+						Unit: "days since 1850-01-01", Calendar: CalendarNoLeap}},
+					{Name: "month", Size: 3, TileSize: 3, Axis: &Axis{Type: ChannelFloat64, Unit: "days since 2000-01-01 00:00:00",
+						Coordinates: []any{0.0, 30.0, 359.5}, Calendar: Calendar360Day}},
+				},
+				slices.Clone(mixed),
+			)},
+		},
+		Fixture{
+			Name:        "halo",
+			Description: "tiles storing a halo of their neighboring samples, contiguous and separated",
+			Header:      header,
+			Layers: []Layer{
+				NewLayer("contiguous", DimensionSet{{Name: "x", Size: 7, TileSize: 3}, {Name: "y", Size: 5, TileSize: 2}}, slices.Clone(mixed), WithHalo(1, 2)),
+				NewLayer("separated", DimensionSet{{Name: "x", Size: 7, TileSize: 4}, {Name: "y", Size: 3, TileSize: 2}}, slices.Clone(mixed), WithHalo(1), WithPlanar()),
+			},
+		},
+		Fixture{
+			Name:        "header-dictionary",
+			Description: "layer header strings stored in a string table, with shared prefixes and repeated units",
+			Header:      header,
+			Layers: []Layer{NewLayer("dictionary",
+				DimensionSet{
+					{Name: "longitude", Size: 4, TileSize: 2, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(10), Step: float32(0.5), Unit: "degrees"}},
+					{Name: "latitude", Size: 3, TileSize: 2, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(-5), Step: float32(0.5), Unit: "degrees"}},
+				},
+				ChannelSet{
+					{Name: "surface_temperature_min", Type: ChannelFloat32, Unit: "K"},
+					{Name: "surface_temperature_max", Type: ChannelFloat32, Unit: "K"},
+					{Name: "surface_température", Type: ChannelInt16, Unit: "K"},
+					{Name: "surface", Type: ChannelUint8},
+				},
+				WithHeaderDictionary(), WithPlanar(),
+			)},
+		},
+		Fixture{
+			Name:        "attributes",
+			Description: "typed attributes of the dataset and its layers, including arrays and a layer header dictionary",
+			Header:      header,
+			Tags:        map[string]string{"title": "attributes fixture"},
+			Attributes: map[string]any{
+				"sensor":      "MODIS",
+				"orbit":       int64(-4021),
+				"scale":       0.125,
+				"calibrated":  true,
+				"bands":       []string{"red", "nir", "température"},
+				"wavelengths": []float64{645.5, 858.5},
+				"flags":       []bool{true, false, true},
+				"empty":       []int64{},
+			},
+			Layers: []Layer{
+				withFixtureAttributes(NewLayer("attributed", DimensionSet{{Name: "x", Size: 5, TileSize: 2}}, slices.Clone(mixed)),
+					map[string]any{"provenance": "resampled from level 1B", "window": []int64{3, 3}, "gain": 1.5}),
+				withFixtureAttributes(NewLayer("dictionary", DimensionSet{{Name: "x", Size: 4, TileSize: 4}}, slices.Clone(mixed), WithHeaderDictionary()),
+					map[string]any{"provenance": "resampled from level 1B", "units": []string{"K", "K", "1"}}),
+			},
+		},
+		Fixture{
+			Name:        "long-strings",
+			Description: "friendly strings longer than the version 1 limit",
+			Header:      header,
+			Tags:        map[string]string{strings.Repeat("k", 300): strings.Repeat("value ", 100)},
+			Layers:      []Layer{NewLayer(strings.Repeat("layer", 60), DimensionSet{{Name: strings.Repeat("x", 280), Size: 4, TileSize: 2}}, ChannelSet{{Name: strings.Repeat("c", 256), Type: ChannelUint8}})},
+		},
+		Fixture{
+			Name:        "georeferencing",
+			Description: "layers placed on the Earth by an EPSG code, and by a WKT2 definition in a header dictionary",
+			Header:      header,
+			Layers: []Layer{
+				NewLayer("utm", DimensionSet{{Name: "x", Size: 5, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}}, slices.Clone(mixed),
+					WithCRS(CRS{EPSG: 32633}, GeoTransform{500000, 30, 0, 4600000, 0, -30})),
+				NewLayer("rotated", DimensionSet{{Name: "x", Size: 4, TileSize: 4}, {Name: "y", Size: 4, TileSize: 2}}, slices.Clone(mixed), WithHeaderDictionary(),
+					WithCRS(CRS{WKT: fixtureWKT}, GeoTransform{10.5, 0.25, 0.125, 45.25, -0.125, -0.25})),
+			},
+		},
+		Fixture{
+			Name:        "string-channels",
+			Description: "string channels alongside fixed-size channels, with empty strings, contiguous and separated",
+			Header:      header,
+			Layers: []Layer{
+				NewLayer("contiguous", DimensionSet{{Name: "x", Size: 5, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}},
+					ChannelSet{{Name: "label", Type: ChannelString}, {Name: "a", Type: ChannelUint8}, {Name: "note", Type: ChannelString}}, WithCompression(CompressionFlate)),
+				NewLayer("separated", DimensionSet{{Name: "x", Size: 6, TileSize: 4}},
+					ChannelSet{{Name: "a", Type: ChannelInt16}, {Name: "label", Type: ChannelString}}, WithPlanar()),
+			},
+		},
+	)
+	return fixtures
+}
+
+// The definition of WGS 84 written by the georeferencing fixture.
+const fixtureWKT = `GEOGCRS["WGS 84",DATUM["World Geodetic System 1984",ELLIPSOID["WGS 84",6378137,298.257223563]],CS[ellipsoidal,2],AXIS["longitude",east],AXIS["latitude",north],ANGLEUNIT["degree",0.0174532925199433]]`
+
+// Returns a copy of the axis referring to the shared axis with the given identifier.
+func withFixtureRef(axis *Axis, id string) *Axis {
+	ref := *axis
+	ref.Ref = id
+	return &ref
+}
+
+func withFixtureAttributes(layer Layer, attributes map[string]any) Layer {
+	layer.Attributes = attributes
+	return layer
+}
+
+// The expected contents of a fixture, as written alongside it by WriteConformanceSuite for readers in other
+// languages to check against.
+type FixtureExpectation struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     int    `json:"version"`
+	ByteOrder   string `json:"byteOrder"`
+	OffsetSize  int    `json:"offsetSize"`
+	// The checksum algorithm of the tiles, recorded in the header from VersionExtensions onward.
+	Checksum string            `json:"checksum,omitempty"`
+	Tags     map[string]string `json:"tags"`
+	// The attributes of the dataset, merged from every tag section.
+	Attributes map[string]AttributeExpectation `json:"attributes,omitempty"`
+	// The shared axes of the dataset by identifier, merged from every tag section.
+	Axes   map[string]*AxisExpectation `json:"axes,omitempty"`
+	Layers []LayerExpectation          `json:"layers"`
+}
+
+type LayerExpectation struct {
+	Name        string `json:"name"`
+	Separated   bool   `json:"separated"`
+	Compression string `json:"compression"`
+	// Whether the strings of the layer header are stored in a string table (see WithHeaderDictionary).
+	HeaderDictionary bool                            `json:"headerDictionary,omitempty"`
+	Attributes       map[string]AttributeExpectation `json:"attributes,omitempty"`
+	Georeference     *GeoreferenceExpectation        `json:"georeference,omitempty"`
+	Dimensions       []DimensionExpectation          `json:"dimensions"`
+	Channels         []ChannelExpectation            `json:"channels"`
+	AbsentTiles      []int                           `json:"absentTiles"`
+	// The value of every channel of every sample, in the order of DimensionSet.SampleCoordinates, with
+	// floating point values printed exactly. Samples in absent tiles are the fill value of their channel, or
+	// null for channels without one.
+	Samples [][]any `json:"samples"`
+}
+
+// The kind of an attribute ("string", "int64", "float64", or "bool"), whether it is an array, and its value
+// (or array of values).
+type AttributeExpectation struct {
+	Kind  string `json:"kind"`
+	Array bool   `json:"array,omitempty"`
+	Value any    `json:"value"`
+}
+
+// The coordinate reference system of a georeferenced layer and its geotransform (see Georeference).
+type GeoreferenceExpectation struct {
+	EPSG      int        `json:"epsg,omitempty"`
+	WKT       string     `json:"wkt,omitempty"`
+	Transform [6]float64 `json:"transform"`
+}
+
+// The expectation of the georeference, or nil if there is none.
+func georeferenceExpectation(g *Georeference) *GeoreferenceExpectation {
+	if g == nil {
+		return nil
+	}
+	return &GeoreferenceExpectation{EPSG: g.CRS.EPSG, WKT: g.CRS.WKT, Transform: g.Transform}
+}
+
+type DimensionExpectation struct {
+	Name     string           `json:"name"`
+	Size     int              `json:"size"`
+	TileSize int              `json:"tileSize"`
+	Halo     int              `json:"halo,omitempty"`
+	Axis     *AxisExpectation `json:"axis,omitempty"`
+}
+
+type AxisExpectation struct {
+	Type        string `json:"type"`
+	Minimum     any    `json:"minimum"`
+	Step        any    `json:"step"`
+	Unit        string `json:"unit,omitempty"`
+	Coordinates []any  `json:"coordinates,omitempty"`
+	Ref         string `json:"ref,omitempty"`      // The identifier of the shared axis the dimension refers to.
+	Calendar    string `json:"calendar,omitempty"` // The calendar of a time axis, if not the standard one.
+}
+
+// Describes the shared axes as a reader should decode them by identifier, or nil if there are none.
+func axisExpectations(axes map[string]*Axis) map[string]*AxisExpectation {
+	if len(axes) == 0 {
+		return nil
+	}
+	expectations := make(map[string]*AxisExpectation, len(axes))
+	for id, axis := range axes {
+		expectations[id] = axisExpectation(axis)
+	}
+	return expectations
+}
+
+// Describes the axis as a reader should decode it, or nil for a nil axis.
+func axisExpectation(a *Axis) *AxisExpectation {
+	if a == nil {
+		return nil
+	}
+	e := &AxisExpectation{
+		Type:    a.Type.Base().String(),
+		Minimum: expectedValue(a.Type, a.Minimum),
+		Step:    expectedValue(a.Type, a.Step),
+		Unit:    a.Unit,
+		Ref:     a.Ref,
+	}
+	for _, value := range a.Coordinates {
+		e.Coordinates = append(e.Coordinates, expectedValue(a.Type, value))
+	}
+	if a.Calendar != CalendarStandard {
+		e.Calendar = a.Calendar.String()
+	}
+	return e
+}
+
+type ChannelExpectation struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Unit      string `json:"unit,omitempty"`
+	Min       any    `json:"min,omitempty"`
+	Max       any    `json:"max,omitempty"`
+	FillValue any    `json:"fillValue,omitempty"`
+	// The moments of the channel, for fixtures of VersionExtensions or later.
+	ValidCount int64   `json:"validCount,omitempty"`
+	Mean       float64 `json:"mean,omitempty"`
+	StdDev     float64 `json:"stdDev,omitempty"`
+}
+
+// Describes what a reader should decode from the written fixture, whose metadata is given.
+func (f Fixture) Expectation(written *Pixi) FixtureExpectation {
+	e := FixtureExpectation{
+		Name:        f.Name,
+		Description: f.Description,
+		Version:     f.Header.Version,
+		ByteOrder:   f.Header.ByteOrder.String(),
+		OffsetSize:  int(f.Header.OffsetSize),
+		Checksum:    f.Header.Checksum.String(),
+		Tags:        written.AllTags(),
+		Attributes:  attributeExpectations(written.AllAttributes()),
+		Axes:        axisExpectations(written.SharedAxes()),
+	}
+	for layerIndex, layer := range written.Layers {
+		le := LayerExpectation{
+			Name:             layer.Name,
+			Separated:        layer.Separated,
+			Compression:      layer.Compression.String(),
+			HeaderDictionary: layer.HeaderDictionary,
+			Attributes:       attributeExpectations(layer.Attributes),
+			Georeference:     georeferenceExpectation(layer.Georeference),
+			AbsentTiles:      slices.Clone(f.Absent[layerIndex]),
+		}
+		if le.AbsentTiles == nil {
+			le.AbsentTiles = []int{}
+		}
+		for _, dim := range layer.Dimensions {
+			de := DimensionExpectation{Name: dim.Name, Size: dim.Size, TileSize: dim.TileSize, Halo: dim.Halo, Axis: axisExpectation(dim.Axis)}
+			le.Dimensions = append(le.Dimensions, de)
+		}
+		for _, channel := range layer.Channels {
+			le.Channels = append(le.Channels, ChannelExpectation{
+				Name:      channel.Name,
+				Type:      channel.Type.Base().String(),
+				Unit:      channel.Unit,
+				Min:       expectedValue(channel.Type, channel.Min),
+				Max:       expectedValue(channel.Type, channel.Max),
+				FillValue: expectedValue(channel.Type, channel.FillValue),
+			}.withMoments(channel, written.Header))
+		}
+		tiles := layer.Dimensions.Tiles()
+		for coord := range layer.Dimensions.SampleCoordinates() {
+			tile := coord.ToTileCoordinate(layer.Dimensions).ToTileSelector(layer.Dimensions).Tile
+			values := []any{}
+			for i, v := range f.Sample(layerIndex, coord) {
+				diskTile := tile
+				if layer.Separated {
+					diskTile += tiles * i
+				}
+				if slices.Contains(f.Absent[layerIndex], diskTile) {
+					values = append(values, expectedValue(layer.Channels[i].Type, layer.Channels[i].FillValue))
+				} else {
+					values = append(values, expectedValue(layer.Channels[i].Type, v))
+				}
+			}
+			le.Samples = append(le.Samples, values)
+		}
+		e.Layers = append(e.Layers, le)
+	}
+	return e
+}
+
+// Describes the kind and value of each attribute, or returns nil if there are none.
+func attributeExpectations(attributes map[string]any) map[string]AttributeExpectation {
+	if len(attributes) == 0 {
+		return nil
+	}
+	expectations := map[string]AttributeExpectation{}
+	for name, value := range attributes {
+		value, kind, _ := attributeValue(name, value)
+		names := map[uint32]string{attributeString: "string", attributeInt: "int64", attributeFloat: "float64", attributeBool: "bool"}
+		expectations[name] = AttributeExpectation{Kind: names[kind&^attributeArray], Array: kind&attributeArray != 0, Value: value}
+	}
+	return expectations
+}
+
+// The expectation with the moments of the channel, if they are stored in files with the given header.
+func (e ChannelExpectation) withMoments(channel Channel, h Header) ChannelExpectation {
+	if channel.storesMoments(h) {
+		e.ValidCount, e.Mean, e.StdDev = channel.Moments.ValidCount, channel.Moments.Mean, channel.Moments.StdDev
+	}
+	return e
+}
+
+// Converts a channel value to a form that JSON represents exactly: booleans and strings as they are, and
+// numbers as float64 (which holds every fixture value exactly).
+func expectedValue(t ChannelType, value any) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case bool, string:
+		return v
+	}
+	f, _ := t.Base().ToFloat64(value)
+	return f
+}
+
+// Writes every conformance fixture to the directory, as "<name>.pixi" alongside a "<name>.json" file
+// holding its FixtureExpectation. The directory is created if it does not exist.
+func WriteConformanceSuite(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, fixture := range ConformanceFixtures() {
+		file, err := os.Create(filepath.Join(dir, fixture.Name+".pixi"))
+		if err != nil {
+			return err
+		}
+		written, err := fixture.Write(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("writing fixture %s: %w", fixture.Name, err)
+		}
+		expectation, err := json.MarshalIndent(fixture.Expectation(written), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, fixture.Name+".json"), expectation, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package gopixi
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestConformanceFixturesRoundTrip(t *testing.T) {
+	names := map[string]bool{}
+	for _, fixture := range ConformanceFixtures() {
+		if names[fixture.Name] {
+			t.Errorf("duplicate fixture name %s", fixture.Name)
+		}
+		names[fixture.Name] = true
+
+		buf := buffer.NewBuffer(10)
+		if _, err := fixture.Write(buf); err != nil {
+			t.Fatalf("%s: %v", fixture.Name, err)
+		}
+		r := buffer.NewBufferFrom(buf.Bytes())
+		read, err := ReadPixi(r)
+		if err != nil {
+			t.Fatalf("%s: %v", fixture.Name, err)
+		}
+		if read.Header.Version != fixture.Header.Version || read.Header.ByteOrder != fixture.Header.ByteOrder || read.Header.OffsetSize != fixture.Header.OffsetSize {
+			t.Errorf("%s: header read as %+v", fixture.Name, read.Header)
+		}
+		expectation := fixture.Expectation(read)
+		for layerIndex, layer := range read.Layers {
+			opts := []ReadOption{WithAbsentTileZeros()}
+			if layer.Channels.HasFillValues() {
+				opts = nil // absent tiles are read as fill by default
+			}
+			samples, err := layer.ReadRegion(r, read.Header, FullRegion(layer.Dimensions), opts...)
+			if err != nil {
+				t.Fatalf("%s: layer %d: %v", fixture.Name, layerIndex, err)
+			}
+			for i, sample := range samples {
+				for c, value := range sample {
+					want := expectation.Layers[layerIndex].Samples[i][c]
+					if want != nil && expectedValue(layer.Channels[c].Type, value) != want {
+						t.Fatalf("%s: layer %d: sample %d channel %d read as %v, expected %v", fixture.Name, layerIndex, i, c, value, want)
+					}
+				}
+			}
+			for _, tile := range fixture.Absent[layerIndex] {
+				if layer.TileBytes[tile] != 0 {
+					t.Errorf("%s: tile %d was expected to be absent", fixture.Name, tile)
+				}
+			}
+		}
+	}
+}
+
+func TestConformanceFixturesCoverage(t *testing.T) {
+	types, compressions := map[ChannelType]bool{}, map[Compression]bool{}
+	headers := map[Header]bool{}
+	sparse := false
+	for _, fixture := range ConformanceFixtures() {
+		headers[Header{Version: fixture.Header.Version, ByteOrder: fixture.Header.ByteOrder, OffsetSize: fixture.Header.OffsetSize}] = true
+		sparse = sparse || len(fixture.Absent) > 0
+		for _, layer := range fixture.Layers {
+			compressions[layer.Compression] = true
+			for _, channel := range layer.Channels {
+				types[channel.Type.Base()] = true
+			}
+		}
+	}
+	for _, c := range fixtureChannelTypes {
+		if !types[c] {
+			t.Errorf("no fixture covers channel type %v", c)
+		}
+	}
+	for _, c := range fixtureCompressions {
+		if !compressions[c] {
+			t.Errorf("no fixture covers compression %v", c)
+		}
+	}
+	for version := 1; version <= Version; version++ {
+		for _, h := range allHeaderVariants(version) {
+			if !headers[h] {
+				t.Errorf("no fixture covers header %+v", h)
+			}
+		}
+	}
+	if !sparse {
+		t.Error("no fixture covers sparse layers")
+	}
+}
+
+func TestWriteConformanceSuite(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteConformanceSuite(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sparse-separated.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expectation FixtureExpectation
+	if err := json.Unmarshal(data, &expectation); err != nil {
+		t.Fatal(err)
+	}
+	layer := expectation.Layers[0]
+	if !layer.Separated || len(layer.Samples) != 8 || !slices.Equal(layer.AbsentTiles, []int{1, 4, 5}) {
+		t.Errorf("unexpected expectation %+v", layer)
+	}
+	if layer.Samples[5][0] != nil || layer.Samples[0][2] != nil || layer.Samples[0][0] == nil {
+		t.Errorf("expected samples of absent tiles to be null, got %v", layer.Samples)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "v1-be4-types-contiguous.pixi")); err != nil {
+		t.Error(err)
+	}
+}
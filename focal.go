@@ -0,0 +1,235 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// A statistic computed over the window of a kernel around every sample with AppendFocal.
+type FocalStatistic int
+
+const (
+	FocalConvolution FocalStatistic = iota // The sum of the window weighted by the kernel.
+	FocalMean                              // The mean of the window weighted by the kernel.
+	FocalMin                               // The least value at a nonzero weight of the kernel.
+	FocalMax                               // The greatest value at a nonzero weight of the kernel.
+	FocalStdDev                            // The population standard deviation of the window weighted by the kernel.
+)
+
+func (s FocalStatistic) String() string {
+	switch s {
+	case FocalConvolution:
+		return "convolution"
+	case FocalMean:
+		return "mean"
+	case FocalMin:
+		return "min"
+	case FocalMax:
+		return "max"
+	case FocalStdDev:
+		return "stddev"
+	default:
+		return fmt.Sprintf("statistic(%d)", int(s))
+	}
+}
+
+// The weights of a window over the first two dimensions of a layer, centered on the sample being computed.
+// Both sizes must be odd; the weight at offset (dx, dy) from the center is Weights[(dy+Height/2)*Width +
+// dx+Width/2], with the first dimension changing fastest.
+type Kernel struct {
+	Width   int
+	Height  int
+	Weights []float64
+}
+
+// A kernel weighting every sample of a size by size window equally, for moving window statistics.
+func BoxKernel(size int) Kernel {
+	weights := make([]float64, size*size)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return Kernel{Width: size, Height: size, Weights: weights}
+}
+
+func (k Kernel) validate() error {
+	if k.Width <= 0 || k.Height <= 0 || k.Width%2 == 0 || k.Height%2 == 0 {
+		return ErrFormat(fmt.Sprintf("kernel size %dx%d must be odd", k.Width, k.Height))
+	}
+	if len(k.Weights) != k.Width*k.Height {
+		return ErrFormat(fmt.Sprintf("kernel of size %dx%d has %d weights", k.Width, k.Height, len(k.Weights)))
+	}
+	return nil
+}
+
+// Appends a layer to the end of the file holding the focal statistic of the source layer over the kernel,
+// which spans the first two dimensions of the source; further dimensions are processed plane by plane. The
+// result has the layout of the source without halos. Samples outside the layer, missing, or NaN are left
+// out of each window, except that convolutions are missing if any are. Missing results are written as
+// described for AppendOperation.
+func (p *Pixi) AppendFocal(w io.WriteSeeker, name string, source Operand, kernel Kernel, statistic FocalStatistic, outputFill *float64) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append layer"}
+	}
+	if source.Layer == nil {
+		return ErrFormat("the source of a focal operation must be a layer")
+	}
+	if err := kernel.validate(); err != nil {
+		return err
+	}
+	if statistic < FocalConvolution || statistic > FocalStdDev {
+		return ErrUnsupported(fmt.Sprintf("focal statistic %v", statistic))
+	}
+	layer := source.Layer.Layer()
+	dims := layer.Dimensions
+	if len(dims) < 2 {
+		return ErrFormat(fmt.Sprintf("layer '%s' must have at least two dimensions for a focal operation", layer.Name))
+	}
+
+	channels := make(ChannelSet, len(layer.Channels))
+	for i, channel := range layer.Channels {
+		resultType := channel.Type.Base()
+		if statistic != FocalMin && statistic != FocalMax {
+			resultType = PromoteTypes(channel.Type, ChannelFloat32)
+		}
+		channels[i] = Channel{Name: channel.Name, Type: resultType, Unit: channel.Unit}
+	}
+
+	window := newFocalWindow(source, kernel)
+	values := make([]float64, len(channels))
+	return p.appendTilewise(w, derivedLayer(name, layer, channels), resultFills(channels, outputFill, source),
+		func(coord SampleCoordinate, result []float64) error {
+			stats := window.stats[:0]
+			for range channels {
+				stats = append(stats, focalAccumulator{min: math.Inf(1), max: math.Inf(-1)})
+			}
+			for dy := -kernel.Height / 2; dy <= kernel.Height/2; dy++ {
+				for dx := -kernel.Width / 2; dx <= kernel.Width/2; dx++ {
+					weight := kernel.Weights[(dy+kernel.Height/2)*kernel.Width+dx+kernel.Width/2]
+					if weight == 0 {
+						continue
+					}
+					if err := window.values(coord, dx, dy, values); err != nil {
+						return err
+					}
+					for c, value := range values {
+						stats[c].add(weight, value)
+					}
+				}
+			}
+			window.stats = stats
+			for c := range result {
+				result[c] = stats[c].result(statistic)
+			}
+			return nil
+		})
+}
+
+// Gives the values of the samples in the window of a kernel, from the halo of the current tile if the
+// layer has wide enough halos, or else from the neighboring tiles.
+type focalWindow struct {
+	source   Operand
+	layer    Layer
+	useHalo  bool
+	tile     int
+	halo     *HaloTile
+	offset   []int
+	neighbor SampleCoordinate
+	stats    []focalAccumulator
+}
+
+func newFocalWindow(source Operand, kernel Kernel) *focalWindow {
+	layer := source.Layer.Layer()
+	dims := layer.Dimensions
+	return &focalWindow{
+		source:   source,
+		layer:    layer,
+		useHalo:  dims[0].Halo >= kernel.Width/2 && dims[1].Halo >= kernel.Height/2,
+		tile:     -1,
+		offset:   make([]int, len(dims)),
+		neighbor: make(SampleCoordinate, len(dims)),
+	}
+}
+
+// Reads the values of every channel of the sample at the offset (dx, dy) from the coordinate, as NaN if it
+// is outside the layer or missing.
+func (f *focalWindow) values(coord SampleCoordinate, dx int, dy int, values []float64) error {
+	dims := f.layer.Dimensions
+	copy(f.neighbor, coord)
+	f.neighbor[0] += dx
+	f.neighbor[1] += dy
+
+	var sample Sample
+	if f.useHalo {
+		if tile := coord.ToTileSelector(dims).Tile; tile != f.tile || f.halo == nil {
+			halo, err := ReadHaloTile(f.source.Layer, tile)
+			if err != nil {
+				return err
+			}
+			f.tile, f.halo = tile, halo
+		}
+		origin := f.halo.Origin()
+		for i := range f.offset {
+			f.offset[i] = f.neighbor[i] - origin[i]
+		}
+		sample, _ = f.halo.SampleAt(f.offset)
+	} else if dims.ContainsCoordinate(f.neighbor) {
+		var err error
+		if sample, err = SampleAt(f.source.Layer, f.neighbor); err != nil {
+			return err
+		}
+	}
+
+	for c, channel := range f.layer.Channels {
+		value, ok := math.NaN(), false
+		if sample != nil {
+			value, ok = channel.Type.ToFloat64(sample[c])
+		}
+		if !ok || (f.source.Fill != nil && value == *f.source.Fill) {
+			value = math.NaN()
+		}
+		values[c] = value
+	}
+	return nil
+}
+
+// Accumulates the weighted values of one channel over a window.
+type focalAccumulator struct {
+	weight   float64
+	sum      float64
+	squares  float64
+	min      float64
+	max      float64
+	excluded bool
+}
+
+func (a *focalAccumulator) add(weight float64, value float64) {
+	if math.IsNaN(value) {
+		a.excluded = true
+		return
+	}
+	a.weight += weight
+	a.sum += weight * value
+	a.squares += weight * value * value
+	a.min = math.Min(a.min, value)
+	a.max = math.Max(a.max, value)
+}
+
+func (a *focalAccumulator) result(statistic FocalStatistic) float64 {
+	if math.IsInf(a.min, 1) || (statistic == FocalConvolution && a.excluded) {
+		return math.NaN() // no values in the window, or a convolution missing some of them
+	}
+	switch statistic {
+	case FocalConvolution:
+		return a.sum
+	case FocalMean:
+		return a.sum / a.weight
+	case FocalMin:
+		return a.min
+	case FocalMax:
+		return a.max
+	default:
+		mean := a.sum / a.weight
+		return math.Sqrt(max(0, a.squares/a.weight-mean*mean))
+	}
+}
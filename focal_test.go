@@ -0,0 +1,121 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func focalTestValue(coord SampleCoordinate) float64 {
+	return float64((coord[0]*7+coord[1]*3)%11 + 20*coord[2])
+}
+
+// Computes the expected focal statistic directly from the values of the source.
+func expectedFocal(dims DimensionSet, coord SampleCoordinate, kernel Kernel, statistic FocalStatistic, fill float64) float64 {
+	acc := focalAccumulator{min: math.Inf(1), max: math.Inf(-1)}
+	for dy := -kernel.Height / 2; dy <= kernel.Height/2; dy++ {
+		for dx := -kernel.Width / 2; dx <= kernel.Width/2; dx++ {
+			weight := kernel.Weights[(dy+kernel.Height/2)*kernel.Width+dx+kernel.Width/2]
+			if weight == 0 {
+				continue
+			}
+			neighbor := SampleCoordinate{coord[0] + dx, coord[1] + dy, coord[2]}
+			value := math.NaN()
+			if dims.ContainsCoordinate(neighbor) {
+				value = focalTestValue(neighbor)
+			}
+			if value == fill {
+				value = math.NaN()
+			}
+			acc.add(weight, value)
+		}
+	}
+	return acc.result(statistic)
+}
+
+func TestAppendFocal(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 9, TileSize: 4}, {Name: "y", Size: 7, TileSize: 3}, {Name: "t", Size: 2, TileSize: 1}}
+	channels := ChannelSet{{Name: "v", Type: ChannelUint16}}
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{
+		NewLayer("source", dims, channels, WithCompression(CompressionFlate)),
+	}, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint16(focalTestValue(coord))}
+	})
+	if err := p.AppendHaloLayer(buf, NewLayer("halo", dims, channels, WithHalo(1, 1, 0)), NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 8)); err != nil {
+		t.Fatal(err)
+	}
+
+	fill := 0.0
+	sobel := Kernel{Width: 3, Height: 3, Weights: []float64{-1, 0, 1, -2, 0, 2, -1, 0, 1}}
+	cases := []struct {
+		kernel    Kernel
+		statistic FocalStatistic
+	}{
+		{BoxKernel(3), FocalMean},
+		{BoxKernel(3), FocalMin},
+		{BoxKernel(3), FocalMax},
+		{BoxKernel(5), FocalStdDev},
+		{sobel, FocalConvolution},
+		{Kernel{Width: 1, Height: 3, Weights: []float64{1, 2, 1}}, FocalMean},
+	}
+	for source := range 2 {
+		for _, c := range cases {
+			access := NewFifoCacheReadLayer(buf, p.Header, p.Layers[source], 8)
+			name := "focal"
+			if err := p.AppendFocal(buf, name, LayerOperand(access, &fill), c.kernel, c.statistic, nil); err != nil {
+				t.Fatal(err)
+			}
+			result := p.Layers[len(p.Layers)-1]
+			wantType := ChannelFloat32
+			if c.statistic == FocalMin || c.statistic == FocalMax {
+				wantType = ChannelUint16
+			}
+			if result.Channels[0].Type != wantType || result.Dimensions.HasHalo() {
+				t.Fatalf("%v: unexpected result layer %+v", c.statistic, result)
+			}
+			resultAccess := NewFifoCacheReadLayer(buf, p.Header, result, 8)
+			for coord := range dims.SampleCoordinates() {
+				want := expectedFocal(dims, coord, c.kernel, c.statistic, fill)
+				sample, err := SampleAt(resultAccess, coord)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, _ := result.Channels[0].Type.ToFloat64(sample[0])
+				switch {
+				case math.IsNaN(want) && wantType == ChannelUint16:
+					want = 0 // integer results are missing as the source fill
+				case math.IsNaN(want):
+					if !math.IsNaN(got) {
+						t.Fatalf("source %d %v at %v: expected missing, got %v", source, c.statistic, coord, got)
+					}
+					continue
+				}
+				if math.Abs(got-want) > 1e-4*max(1, math.Abs(want)) {
+					t.Fatalf("source %d %v at %v: expected %v, got %v", source, c.statistic, coord, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestAppendFocalErrors(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{
+		NewLayer("line", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}}),
+	}, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+	line := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 2), nil)
+	if err := p.AppendFocal(buf, "focal", line, BoxKernel(3), FocalMean, nil); err == nil {
+		t.Error("expected an error for a one dimensional layer")
+	}
+	if err := p.AppendFocal(buf, "focal", line, BoxKernel(2), FocalMean, nil); err == nil {
+		t.Error("expected an error for an even kernel")
+	}
+	if err := p.AppendFocal(buf, "focal", ConstantOperand(1), BoxKernel(3), FocalMean, nil); err == nil {
+		t.Error("expected an error for a constant source")
+	}
+}
@@ -0,0 +1,193 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	FooterMarker string = "pixf" // Every footer trailer ends with these four bytes.
+	TrailerSize  int    = 16     // The fixed size in bytes of the trailer at the very end of a file with a footer.
+)
+
+// The fixed-size record at the very end of a file containing a footer, pointing back to the start of the
+// footer and repeating the offset size and byte order of the file.
+type Trailer struct {
+	Version     int
+	OffsetSize  OffsetSize
+	ByteOrder   binary.ByteOrder
+	FooterStart int64
+}
+
+// Writes the trailer to the current position in the writer stream.
+func (t Trailer) Write(w io.Writer) error {
+	buf := make([]byte, TrailerSize)
+	t.ByteOrder.PutUint64(buf[0:8], uint64(t.FooterStart))
+	buf[8] = byte(t.OffsetSize)
+	if t.ByteOrder == binary.BigEndian {
+		buf[9] = 0xff
+	}
+	copy(buf[10:12], fmt.Sprintf("%02d", t.Version))
+	copy(buf[12:16], FooterMarker)
+	_, err := w.Write(buf)
+	return err
+}
+
+// Reads the trailer from the last TrailerSize bytes of the stream. Returns an ErrFormat error if the end
+// of the stream does not contain a valid trailer.
+func ReadTrailer(r io.ReadSeeker) (Trailer, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Trailer{}, err
+	}
+	if size < int64(TrailerSize) {
+		return Trailer{}, ErrFormat("stream too small to contain a footer trailer")
+	}
+	_, err = r.Seek(-int64(TrailerSize), io.SeekEnd)
+	if err != nil {
+		return Trailer{}, err
+	}
+	buf := make([]byte, TrailerSize)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return Trailer{}, err
+	}
+
+	if string(buf[12:16]) != FooterMarker {
+		return Trailer{}, ErrFormat("footer marker not found at end of file")
+	}
+	t := Trailer{}
+	switch buf[9] {
+	case 0x00:
+		t.ByteOrder = binary.LittleEndian
+	case 0xff:
+		t.ByteOrder = binary.BigEndian
+	default:
+		return Trailer{}, ErrFormat("unsupported or invalid byte order specified in trailer")
+	}
+	if buf[8] != 4 && buf[8] != 8 {
+		return Trailer{}, ErrFormat("reader only supports offset sizes of 4 or 8 bytes")
+	}
+	t.OffsetSize = OffsetSize(buf[8])
+	version, err := strconv.ParseInt(string(buf[10:12]), 10, 32)
+	if err != nil {
+		return Trailer{}, ErrFormat("invalid version in trailer")
+	}
+	t.Version = int(version)
+	t.FooterStart = int64(t.ByteOrder.Uint64(buf[0:8]))
+	if t.FooterStart < 0 || t.FooterStart >= size-int64(TrailerSize) {
+		return Trailer{}, ErrFormat("footer offset in trailer is outside of the file")
+	}
+	return t, nil
+}
+
+// Returns true if the stream ends with a valid footer trailer.
+func HasFooter(r io.ReadSeeker) bool {
+	_, err := ReadTrailer(r)
+	return err == nil
+}
+
+// The size in bytes of the footer (including the trailer) that WriteFooter would write for this file.
+func (p *Pixi) FooterSize() int {
+	size := p.Header.DiskSize()
+	if tags := p.condensedTags(); len(tags.Tags) > 0 || len(tags.Attributes) > 0 || len(tags.Axes) > 0 {
+		size += tags.DiskSize(p.Header)
+	}
+	for _, l := range p.Layers {
+		size += l.HeaderSize(p.Header)
+	}
+	return size + TrailerSize
+}
+
+// All tags, attributes, and shared axes of the file condensed into a single section.
+func (p *Pixi) condensedTags() TagSection {
+	return TagSection{Tags: p.AllTags(), Attributes: p.AllAttributes(), Axes: p.SharedAxes()}
+}
+
+// Writes a footer describing the whole file to the writer, which must be positioned at the absolute file
+// offset footerStart, followed by a trailer pointing back to it. The writer never needs to seek.
+func (p *Pixi) WriteFooter(w io.Writer, footerStart int64) error {
+	header := p.Header
+	offset := footerStart + int64(header.DiskSize())
+
+	tags := p.condensedTags()
+	hasTags := len(tags.Tags) > 0 || len(tags.Attributes) > 0 || len(tags.Axes) > 0
+	header.FirstTagsOffset = 0
+	if hasTags {
+		header.FirstTagsOffset = offset
+		offset += int64(tags.DiskSize(header))
+	}
+	header.FirstLayerOffset = 0
+	if len(p.Layers) > 0 {
+		header.FirstLayerOffset = offset
+	}
+
+	// buffer the footer so that partial writes to the underlying stream can't leave an invalid footer
+	buf := new(bytes.Buffer)
+	err := header.WriteHeader(buf)
+	if err != nil {
+		return err
+	}
+	if hasTags {
+		err = tags.Write(buf, header)
+		if err != nil {
+			return err
+		}
+	}
+	for i, layer := range p.Layers {
+		offset += int64(layer.HeaderSize(header))
+		layer.NextLayerStart = 0
+		if i < len(p.Layers)-1 {
+			layer.NextLayerStart = offset
+		}
+		err = layer.WriteHeader(buf, header)
+		if err != nil {
+			return err
+		}
+	}
+
+	trailer := Trailer{Version: header.Version, OffsetSize: header.OffsetSize, ByteOrder: header.ByteOrder, FooterStart: footerStart}
+	err = trailer.Write(buf)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// Writes a footer describing the whole file to the end of the stream. The header at the start of the file
+// is left untouched, so readers may start from either end of the file.
+func (p *Pixi) AppendFooter(w io.WriteSeeker) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append footer"}
+	}
+	footerStart, err := w.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	return p.WriteFooter(w, footerStart)
+}
+
+// Reads the metadata of a Pixi file starting from the trailer at the end of the file, rather than the
+// header at the start of the file.
+func ReadFooter(r io.ReadSeeker) (*Pixi, error) {
+	trailer, err := ReadTrailer(r)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.Seek(trailer.FooterStart, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	pixi, err := readPixi(r, false)
+	if err != nil {
+		return pixi, err
+	}
+	if pixi.Header.ByteOrder != trailer.ByteOrder || pixi.Header.OffsetSize != trailer.OffsetSize {
+		return pixi, ErrFormat("footer header does not match trailer")
+	}
+	return pixi, nil
+}
@@ -0,0 +1,139 @@
+package gopixi
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func writeFooterTestPixi(t *testing.T, header Header) (*buffer.Buffer, *Pixi) {
+	t.Helper()
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{
+		NewLayer("first",
+			DimensionSet{{Name: "x", Size: 6, TileSize: 3}, {Name: "y", Size: 4, TileSize: 2}},
+			ChannelSet{{Name: "a", Type: ChannelUint16}}),
+		NewLayer("second",
+			DimensionSet{{Name: "x", Size: 5, TileSize: 5}},
+			ChannelSet{{Name: "b", Type: ChannelInt8}, {Name: "c", Type: ChannelFloat64}},
+			WithPlanar(), WithCompression(CompressionFlate)),
+	}
+	pixi := writeTestPixi(t, buf, header, map[string]string{"source": "footer", "kind": "test"}, layers, func(layer int, coord SampleCoordinate) Sample {
+		if layer == 0 {
+			return Sample{uint16(coord[0] * coord[1])}
+		}
+		return Sample{int8(coord[0]), float64(coord[0]) / 2}
+	})
+	return buf, pixi
+}
+
+func TestFooterRoundTrip(t *testing.T) {
+	for _, header := range allHeaderVariants(Version) {
+		buf, pixi := writeFooterTestPixi(t, header)
+		if HasFooter(buf) {
+			t.Fatal("expected file without footer to not report one")
+		}
+
+		sizeBefore := len(buf.Bytes())
+		err := pixi.AppendFooter(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(buf.Bytes())-sizeBefore != pixi.FooterSize() {
+			t.Errorf("expected footer of %d bytes, got %d", pixi.FooterSize(), len(buf.Bytes())-sizeBefore)
+		}
+		if !HasFooter(buf) {
+			t.Fatal("expected file to report footer")
+		}
+
+		_, err = buf.Seek(0, io.SeekStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fromFront, err := ReadPixi(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromBack, err := ReadFooter(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fromFront.AllTags(), fromBack.AllTags()) {
+			t.Errorf("expected footer tags %v, got %v", fromFront.AllTags(), fromBack.AllTags())
+		}
+		if len(fromBack.Layers) != len(fromFront.Layers) {
+			t.Fatalf("expected %d layers in footer, got %d", len(fromFront.Layers), len(fromBack.Layers))
+		}
+		for i := range fromFront.Layers {
+			front, back := fromFront.Layers[i], fromBack.Layers[i]
+			front.NextLayerStart, back.NextLayerStart = 0, 0
+			if !reflect.DeepEqual(front, back) {
+				t.Errorf("expected footer layer %d to match, got %+v and %+v", i, front, back)
+			}
+		}
+	}
+}
+
+func TestFooterStreamedFile(t *testing.T) {
+	for _, header := range allHeaderVariants(Version) {
+		buf, pixi := writeFooterTestPixi(t, header)
+		err := pixi.AppendFooter(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// emulate a single-pass writer that could not go back and fill in the header offsets
+		_, err = buf.Seek(0, io.SeekStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamed := pixi.Header
+		streamed.FirstLayerOffset, streamed.FirstTagsOffset = 0, 0
+		err = streamed.WriteHeader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = buf.Seek(0, io.SeekStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadPixi(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read.AllTags()["source"] != "footer" {
+			t.Errorf("expected tags to be read from footer, got %v", read.AllTags())
+		}
+		if len(read.Layers) != 2 {
+			t.Fatalf("expected 2 layers read from footer, got %d", len(read.Layers))
+		}
+
+		layer := NewMemoryLayer(buf, read.Header, read.Layers[1])
+		sample, err := SampleAt(layer, SampleCoordinate{3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sample[0] != int8(3) || sample[1] != 1.5 {
+			t.Errorf("expected sample [3 1.5], got %v", sample)
+		}
+	}
+}
+
+func TestReadTrailerInvalid(t *testing.T) {
+	_, err := ReadTrailer(buffer.NewBufferFrom([]byte("pixf")))
+	if err == nil {
+		t.Error("expected error for stream smaller than trailer")
+	}
+
+	trailer := make([]byte, TrailerSize)
+	copy(trailer[12:], FooterMarker)
+	trailer[8] = 3
+	_, err = ReadTrailer(buffer.NewBufferFrom(trailer))
+	if err == nil {
+		t.Error("expected error for invalid offset size")
+	}
+}
@@ -0,0 +1,178 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The coordinate reference system of the model coordinates of a georeferenced layer, named by an EPSG code,
+// defined by a WKT2 string, or both (in which case they should agree). The zero value is an unknown system.
+type CRS struct {
+	EPSG int    // The EPSG code of the system, or zero if it has none.
+	WKT  string // The definition of the system in the WKT2 format of ISO 19162, or empty if it is not given.
+}
+
+// Whether the system is unknown, having neither an EPSG code nor a definition.
+func (c CRS) IsZero() bool {
+	return c.EPSG == 0 && c.WKT == ""
+}
+
+// A short description of the system: "EPSG:<code>" if it has an EPSG code, and otherwise its definition.
+func (c CRS) String() string {
+	if c.EPSG != 0 {
+		return fmt.Sprintf("EPSG:%d", c.EPSG)
+	}
+	return c.WKT
+}
+
+// Parses a system given as "EPSG:<code>" (in any case) or as a WKT2 definition.
+func ParseCRS(s string) (CRS, error) {
+	s = strings.TrimSpace(s)
+	if code, ok := strings.CutPrefix(strings.ToUpper(s), "EPSG:"); ok {
+		var epsg int
+		if _, err := fmt.Sscanf(code, "%d", &epsg); err != nil || epsg <= 0 || fmt.Sprint(epsg) != code {
+			return CRS{}, ErrFormat(fmt.Sprintf("invalid EPSG code '%s'", code))
+		}
+		return CRS{EPSG: epsg}, nil
+	}
+	if !strings.HasSuffix(s, "]") || !strings.Contains(s, "[") {
+		return CRS{}, ErrFormat(fmt.Sprintf("'%s' is neither an EPSG code nor a WKT definition", s))
+	}
+	return CRS{WKT: s}, nil
+}
+
+// An affine transform from the indices of a sample in the first two dimensions of a layer to its model
+// coordinates, with its six coefficients in the order of GDAL's geotransform: the x coordinate of index
+// (i, j) is T[0] + i*T[1] + j*T[2] and its y coordinate is T[3] + i*T[4] + j*T[5]. Unlike GDAL's, the
+// transform locates the sample itself rather than the corner of a pixel, as the axes of dimensions do.
+type GeoTransform [6]float64
+
+// The model coordinates of the sample at the given indices of the first two dimensions.
+func (t GeoTransform) Apply(i float64, j float64) (x float64, y float64) {
+	return t[0] + i*t[1] + j*t[2], t[3] + i*t[4] + j*t[5]
+}
+
+// The transform from model coordinates back to (fractional) indices of the first two dimensions, or an
+// error if the transform is degenerate and cannot be inverted.
+func (t GeoTransform) Invert() (GeoTransform, error) {
+	det := t[1]*t[5] - t[2]*t[4]
+	if det == 0 {
+		return GeoTransform{}, ErrFormat("geotransform is degenerate and cannot be inverted")
+	}
+	return GeoTransform{
+		(t[2]*t[3] - t[0]*t[5]) / det, t[5] / det, -t[2] / det,
+		(t[0]*t[4] - t[1]*t[3]) / det, -t[4] / det, t[1] / det,
+	}, nil
+}
+
+// Places a layer on the surface of the Earth (or another body): the coordinate reference system of its
+// model coordinates, and the transform from the indices of its first two dimensions to them.
+type Georeference struct {
+	CRS       CRS
+	Transform GeoTransform
+}
+
+// The georeference of the subset of a layer starting at the given indices, whose first sample lies at
+// those indices of the original.
+func (g *Georeference) offset(start []int) *Georeference {
+	if g == nil {
+		return nil
+	}
+	shifted := *g
+	shifted.Transform[0], shifted.Transform[3] = g.Transform.Apply(float64(start[0]), float64(start[1]))
+	return &shifted
+}
+
+// The georeference of a layer reduced by the factor in its first two dimensions, whose sample i lies at
+// sample i*factor of the original.
+func (g *Georeference) scaled(factor int) *Georeference {
+	if g == nil {
+		return nil
+	}
+	scaled := *g
+	for _, i := range []int{1, 2, 4, 5} {
+		scaled.Transform[i] *= float64(factor)
+	}
+	return &scaled
+}
+
+// The coordinate reference system and geotransform of the layer, if it is georeferenced.
+func (d Layer) CRS() (CRS, GeoTransform, bool) {
+	if d.Georeference == nil {
+		return CRS{}, GeoTransform{}, false
+	}
+	return d.Georeference.CRS, d.Georeference.Transform, true
+}
+
+// Georeferences the layer with the given coordinate reference system and geotransform. The layer header
+// must still be written (or rewritten with Pixi.SetLayerGeoreference) for the change to be stored.
+func (d *Layer) SetCRS(crs CRS, transform GeoTransform) {
+	d.Georeference = &Georeference{CRS: crs, Transform: transform}
+}
+
+// The model coordinates of the sample at the coordinate, if the layer is georeferenced.
+func (d Layer) ModelCoordinate(coord SampleCoordinate) (x float64, y float64, ok bool) {
+	if d.Georeference == nil || len(coord) < 2 {
+		return 0, 0, false
+	}
+	x, y = d.Georeference.Transform.Apply(float64(coord[0]), float64(coord[1]))
+	return x, y, true
+}
+
+type georeferenceOption struct {
+	georeference Georeference
+}
+
+func (o georeferenceOption) applyLayer(opts *layerOptions) {
+	opts.georeference = &o.georeference
+}
+
+// Georeferences the layer with the given coordinate reference system and geotransform, as by Layer.SetCRS.
+// Requires VersionExtensions or later.
+func WithCRS(crs CRS, transform GeoTransform) LayerOption {
+	return georeferenceOption{georeference: Georeference{CRS: crs, Transform: transform}}
+}
+
+// Checks that the georeference can be stored for a layer with the given dimensions.
+func (g Georeference) check(dims DimensionSet) error {
+	switch {
+	case len(dims) < 2:
+		return ErrFormat("georeferenced layers must have at least two dimensions")
+	case g.CRS.EPSG < 0:
+		return ErrFormat(fmt.Sprintf("invalid EPSG code %d", g.CRS.EPSG))
+	}
+	_, err := g.Transform.Invert()
+	return err
+}
+
+// The number of bytes taken by the georeference in a layer header.
+func (g Georeference) HeaderSize(h Header) int {
+	return 4 + h.FriendlySize(g.CRS.WKT) + 6*8
+}
+
+// Writes the georeference to the given stream: the EPSG code as a 4-byte integer, the WKT definition as a
+// friendly string, and the six coefficients of the transform as 8-byte floating point numbers.
+func (g Georeference) Write(w io.Writer, h Header) error {
+	if err := h.Write(w, int32(g.CRS.EPSG)); err != nil {
+		return err
+	}
+	if err := h.WriteFriendly(w, g.CRS.WKT); err != nil {
+		return err
+	}
+	return h.Write(w, g.Transform)
+}
+
+// Reads a georeference from the given stream, as written by Write.
+func (g *Georeference) Read(r io.Reader, h Header) error {
+	var epsg int32
+	if err := h.Read(r, &epsg); err != nil {
+		return err
+	}
+	wkt, err := h.ReadFriendly(r)
+	if err != nil {
+		return err
+	}
+	g.CRS = CRS{EPSG: int(epsg), WKT: wkt}
+	return h.Read(r, &g.Transform)
+}
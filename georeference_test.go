@@ -0,0 +1,120 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestGeoTransform(t *testing.T) {
+	transform := GeoTransform{500000, 30, 5, 4600000, -2, -30}
+	x, y := transform.Apply(10, 4)
+	if x != 500000+300+20 || y != 4600000-20-120 {
+		t.Errorf("unexpected model coordinates (%v, %v)", x, y)
+	}
+	inverse, err := transform.Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, j := inverse.Apply(x, y); math.Abs(i-10) > 1e-9 || math.Abs(j-4) > 1e-9 {
+		t.Errorf("expected the inverse to return indices (10, 4), got (%v, %v)", i, j)
+	}
+	if _, err := (GeoTransform{0, 1, 2, 0, 2, 4}).Invert(); err == nil {
+		t.Error("expected a degenerate transform not to be invertible")
+	}
+
+	for text, expected := range map[string]CRS{
+		"EPSG:4326":                           {EPSG: 4326},
+		" epsg:32633 ":                        {EPSG: 32633},
+		`GEOGCRS["WGS 84",CS[ellipsoidal,2]]`: {WKT: `GEOGCRS["WGS 84",CS[ellipsoidal,2]]`},
+	} {
+		if crs, err := ParseCRS(text); err != nil || crs != expected {
+			t.Errorf("expected '%s' to parse as %v, got %v (%v)", text, expected, crs, err)
+		}
+	}
+	for _, text := range []string{"EPSG:", "EPSG:-4", "EPSG:4326x", "WGS 84"} {
+		if _, err := ParseCRS(text); err == nil {
+			t.Errorf("expected '%s' not to parse", text)
+		}
+	}
+	if s := (CRS{EPSG: 4326, WKT: fixtureWKT}).String(); s != "EPSG:4326" {
+		t.Errorf("expected the EPSG code to describe the system, got %s", s)
+	}
+}
+
+func TestLayerGeoreference(t *testing.T) {
+	for _, dictionary := range []bool{false, true} {
+		opts := []LayerOption{WithCRS(CRS{EPSG: 4326, WKT: fixtureWKT}, GeoTransform{-180, 0.5, 0, 90, 0, -0.5})}
+		if dictionary {
+			opts = append(opts, WithHeaderDictionary())
+		}
+		buf, pixi := newEditTestPixi(t, opts...)
+		layer := pixi.Layers[0]
+		crs, transform, ok := layer.CRS()
+		if !ok || crs.EPSG != 4326 || crs.WKT != fixtureWKT || transform != (GeoTransform{-180, 0.5, 0, 90, 0, -0.5}) {
+			t.Fatalf("dictionary %v: unexpected georeference %v, %v", dictionary, crs, transform)
+		}
+		if x, y, ok := layer.ModelCoordinate(SampleCoordinate{4, 2}); !ok || x != -178 || y != 89 {
+			t.Errorf("dictionary %v: unexpected model coordinate (%v, %v)", dictionary, x, y)
+		}
+		if _, _, ok := pixi.Layers[1].CRS(); ok {
+			t.Errorf("dictionary %v: expected the second layer not to be georeferenced", dictionary)
+		}
+
+		// rewriting the georeference leaves the tiles alone
+		if err := pixi.SetLayerGeoreference(buf, 0, &Georeference{CRS: CRS{EPSG: 3857}, Transform: GeoTransform{0, 10, 0, 0, 0, -10}}); err != nil {
+			t.Fatal(err)
+		}
+		reread, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if crs, _, _ := reread.Layers[0].CRS(); crs != (CRS{EPSG: 3857}) {
+			t.Errorf("dictionary %v: expected the rewritten CRS, got %v", dictionary, crs)
+		}
+		if sample, err := SampleAt(NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), reread.Header, reread.Layers[0], 4), SampleCoordinate{3, 2}); err != nil || sample[0] != uint16(6) {
+			t.Errorf("dictionary %v: expected the samples to be kept, got %v (%v)", dictionary, sample, err)
+		}
+		if err := reread.SetLayerGeoreference(buf, 0, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := reread.Layers[0].CRS(); ok {
+			t.Errorf("dictionary %v: expected the georeference to be removed", dictionary)
+		}
+	}
+}
+
+func TestGeoreferenceInvalid(t *testing.T) {
+	buf, pixi := newEditTestPixi(t)
+	if err := pixi.SetLayerGeoreference(buf, 1, &Georeference{Transform: GeoTransform{0, 1, 0, 0, 0, 1}}); err == nil {
+		t.Error("expected a one-dimensional layer not to be georeferenced")
+	}
+	if err := pixi.SetLayerGeoreference(buf, 0, &Georeference{Transform: GeoTransform{0, 1, 0, 0, 0, 0}}); err == nil {
+		t.Error("expected a degenerate transform to be rejected")
+	}
+
+	layer := NewLayer("old", DimensionSet{{Name: "x", Size: 2}, {Name: "y", Size: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}},
+		WithCRS(CRS{EPSG: 4326}, GeoTransform{0, 1, 0, 0, 0, 1}))
+	old := NewHeader(binary.LittleEndian, OffsetSize4)
+	old.Version = VersionExtensions - 1
+	if err := layer.WriteHeader(buffer.NewBuffer(10), old); err == nil {
+		t.Errorf("expected georeferences to require version %d", VersionExtensions)
+	}
+}
+
+func TestCloneRegionGeoreference(t *testing.T) {
+	buf, _ := newEditTestPixi(t, WithCRS(CRS{EPSG: 32633}, GeoTransform{1000, 30, 0, 2000, 0, -30}))
+	dst := buffer.NewBuffer(10)
+	cloned, err := Clone(buffer.NewBufferFrom(buf.Bytes()), dst, CloneOptions{
+		Layers: []string{"data"},
+		Region: &Region{Start: SampleCoordinate{2, 1}, End: SampleCoordinate{6, 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, transform, ok := cloned.Layers[0].CRS(); !ok || transform != (GeoTransform{1060, 30, 0, 1970, 0, -30}) {
+		t.Errorf("expected the transform to be shifted to the region, got %v", transform)
+	}
+}
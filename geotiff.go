@@ -0,0 +1,597 @@
+package gopixi
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The size of the tiles of GeoTIFF files whose layer tiles are not a multiple of 16, as TIFF tiles must be.
+const DefaultGeoTIFFTileSize = 256
+
+// How WriteGeoTIFF writes a layer.
+type GeoTIFFOptions struct {
+	// The channels of a two-dimensional layer written as bands, in order; every channel if empty. For a
+	// three-dimensional layer, the single channel written, whose values at each index of the third dimension
+	// are a band; the only channel of the layer if empty.
+	Channels []string
+	// Whether to write a Cloud Optimized GeoTIFF, with overviews down to a size that fits in a single
+	// tile unless Overviews sets how many there are.
+	COG bool
+	// The number of overviews written after the full resolution image, each half the size of the last.
+	Overviews int
+	// The EPSG code of the coordinate reference system of the axes of the layer, written to the GeoKeys of
+	// the file if not zero, and whether it is a geographic (rather than a projected) system.
+	EPSG       int
+	Geographic bool
+}
+
+// TIFF tags written by WriteGeoTIFF.
+const (
+	tiffNewSubfileType       uint16 = 254
+	tiffImageWidth           uint16 = 256
+	tiffImageLength          uint16 = 257
+	tiffBitsPerSample        uint16 = 258
+	tiffCompression          uint16 = 259
+	tiffPhotometric          uint16 = 262
+	tiffSamplesPerPixel      uint16 = 277
+	tiffPlanarConfiguration  uint16 = 284
+	tiffTileWidth            uint16 = 322
+	tiffTileLength           uint16 = 323
+	tiffTileOffsets          uint16 = 324
+	tiffTileByteCounts       uint16 = 325
+	tiffExtraSamples         uint16 = 338
+	tiffSampleFormat         uint16 = 339
+	tiffModelPixelScale      uint16 = 33550
+	tiffModelTiepoint        uint16 = 33922
+	tiffModelTransformation  uint16 = 34264
+	tiffGeoKeyDirectory      uint16 = 34735
+	tiffGDALMetadata         uint16 = 42112
+	tiffGDALNoData           uint16 = 42113
+	tiffTypeASCII            uint16 = 2
+	tiffTypeShort            uint16 = 3
+	tiffTypeLong             uint16 = 4
+	tiffTypeDouble           uint16 = 12
+	tiffTypeLong8            uint16 = 16
+	tiffCompressionNone      uint16 = 1
+	tiffCompressionDeflate   uint16 = 8
+	tiffCompressionZstd      uint16 = 50000
+	geoKeyModelType          uint16 = 1024
+	geoKeyRasterType         uint16 = 1025
+	geoKeyGeographicType     uint16 = 2048
+	geoKeyProjectedType      uint16 = 3072
+	geoModelProjected        uint16 = 1
+	geoModelGeographic       uint16 = 2
+	geoRasterPixelIsPoint    uint16 = 2
+	tiffSampleFormatUint     uint16 = 1
+	tiffSampleFormatInt      uint16 = 2
+	tiffSampleFormatFloat    uint16 = 3
+	tiffPlanarChunky         uint16 = 1
+	tiffPlanarSeparate       uint16 = 2
+	tiffPhotometricMinIsZero uint16 = 1
+)
+
+// Writes the layer with the given index from the start of w as a tiled GeoTIFF, with the first two
+// dimensions as columns and rows and the channels (or a third dimension, see GeoTIFFOptions) as bands of a
+// single type, followed by any overviews the options ask for. The axes, fill value, channel names and units
+// of the layer are written as GeoTIFF and GDAL metadata. Float8, bfloat16, and 128-bit channels cannot be
+// written.
+func (p *Pixi) WriteGeoTIFF(r io.ReadSeeker, w io.WriteSeeker, layerIndex int, options GeoTIFFOptions) error {
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	image, err := newGeoTIFFImage(p.Header, p.Layers[layerIndex], options)
+	if err != nil {
+		return err
+	}
+
+	levels := options.Overviews
+	if options.COG && levels == 0 {
+		for image.levelSize(levels, 0) > image.tileSize[0] || image.levelSize(levels, 1) > image.tileSize[1] {
+			levels++
+		}
+	}
+	levels++
+
+	// use BigTIFF if the file could exceed 32-bit offsets, allowing for tiles that do not compress
+	bound := int64(1 << 16)
+	for level := range levels {
+		tiles := int64(image.tiles(level))
+		bound += tiles * (int64(image.tileBytes())*101/100 + 1024)
+	}
+	t := tiffWriter{order: p.Header.ByteOrder, big: bound > math.MaxUint32}
+	ifds := make([][]tiffEntry, levels)
+	for level := range levels {
+		ifds[level] = image.entries(t, level)
+	}
+	section, positions := t.layout(ifds)
+
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.Write(section); err != nil {
+		return err
+	}
+	compressor, err := newTIFFCompressor(image.compression)
+	if err != nil {
+		return err
+	}
+	offsets, counts := make([][]uint64, levels), make([][]uint64, levels)
+	at := int64(len(section))
+	for level := levels - 1; level >= 0; level-- {
+		for tile := range image.tiles(level) {
+			data, err := image.readTile(r, level, tile)
+			if err != nil {
+				return err
+			}
+			if data, err = compressor.compress(data); err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			offsets[level] = append(offsets[level], uint64(at))
+			counts[level] = append(counts[level], uint64(len(data)))
+			at += int64(len(data))
+		}
+	}
+	for level := range levels {
+		for tag, values := range map[uint16][]uint64{tiffTileOffsets: offsets[level], tiffTileByteCounts: counts[level]} {
+			if _, err := w.Seek(positions[level][tag], io.SeekStart); err != nil {
+				return err
+			}
+			if _, err := w.Write(t.offsets(tag, values).data); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// A layer as the image of a GeoTIFF file, with the bands written for it.
+type geoTIFFImage struct {
+	header      Header
+	layer       Layer
+	options     GeoTIFFOptions
+	channels    []string // the channel of each band, or the single channel of all bands along the third dimension
+	bandType    ChannelType
+	planar      bool
+	tileSize    [2]int
+	compression uint16
+}
+
+func newGeoTIFFImage(h Header, layer Layer, options GeoTIFFOptions) (geoTIFFImage, error) {
+	image := geoTIFFImage{header: h, layer: layer, options: options, channels: options.Channels}
+	switch len(layer.Dimensions) {
+	case 2:
+		if len(image.channels) == 0 {
+			for _, channel := range layer.Channels {
+				image.channels = append(image.channels, channel.Name)
+			}
+		}
+		image.planar = layer.Separated
+	case 3:
+		if len(image.channels) == 0 && len(layer.Channels) == 1 {
+			image.channels = []string{layer.Channels[0].Name}
+		}
+		if len(image.channels) != 1 {
+			return geoTIFFImage{}, ErrFormat(fmt.Sprintf("layer '%s' has bands along its third dimension, so a single channel must be written", layer.Name))
+		}
+		image.planar = true
+	default:
+		return geoTIFFImage{}, ErrUnsupported(fmt.Sprintf("layer '%s' has %d dimensions, but GeoTIFF images have two (and bands)", layer.Name, len(layer.Dimensions)))
+	}
+	for i, name := range image.channels {
+		c := layer.Channels.Index(name)
+		if c < 0 {
+			return geoTIFFImage{}, ErrChannelNotFound{ChannelName: name}
+		}
+		if i == 0 {
+			image.bandType = layer.Channels[c].Type.Base()
+		} else if layer.Channels[c].Type.Base() != image.bandType {
+			return geoTIFFImage{}, ErrFormat(fmt.Sprintf("GeoTIFF bands must have the same type, but channel '%s' is %v and '%s' %v", name, layer.Channels[c].Type.Base(), image.channels[0], image.bandType))
+		}
+	}
+	if _, err := geoTIFFSampleFormat(image.bandType); err != nil {
+		return geoTIFFImage{}, err
+	}
+	for d := range image.tileSize {
+		image.tileSize[d] = layer.Dimensions[d].TileSize
+		if image.tileSize[d]%16 != 0 {
+			image.tileSize[d] = DefaultGeoTIFFTileSize
+		}
+	}
+	switch layer.Compression {
+	case CompressionNone:
+		image.compression = tiffCompressionNone
+	case CompressionZstd:
+		image.compression = tiffCompressionZstd
+	default:
+		image.compression = tiffCompressionDeflate
+	}
+	return image, nil
+}
+
+// The number of bands of the image.
+func (g geoTIFFImage) bands() int {
+	if len(g.layer.Dimensions) == 3 {
+		return g.layer.Dimensions[2].Size
+	}
+	return len(g.channels)
+}
+
+// The size along the first (columns) or second (rows) dimension of the image at the overview level, the
+// full resolution image being level zero.
+func (g geoTIFFImage) levelSize(level int, d int) int {
+	stride := 1 << level
+	return (g.layer.Dimensions[d].Size + stride - 1) / stride
+}
+
+func (g geoTIFFImage) tilesAcross(level int, d int) int {
+	return (g.levelSize(level, d) + g.tileSize[d] - 1) / g.tileSize[d]
+}
+
+// The number of tiles of the image at the overview level, counting those of every band for planar images.
+func (g geoTIFFImage) tiles(level int) int {
+	tiles := g.tilesAcross(level, 0) * g.tilesAcross(level, 1)
+	if g.planar {
+		tiles *= g.bands()
+	}
+	return tiles
+}
+
+// The number of bytes of each uncompressed tile.
+func (g geoTIFFImage) tileBytes() int {
+	bytes := g.tileSize[0] * g.tileSize[1] * g.bandType.Size()
+	if !g.planar {
+		bytes *= g.bands()
+	}
+	return bytes
+}
+
+// Reads the tile of the image at the overview level, padding tiles at the edges of the image with zeros.
+// The tiles of planar images are ordered by band, as in TIFF.
+func (g geoTIFFImage) readTile(r io.ReadSeeker, level int, tile int) ([]byte, error) {
+	band, channels := 0, g.channels
+	if g.planar {
+		perBand := g.tilesAcross(level, 0) * g.tilesAcross(level, 1)
+		band, tile = tile/perBand, tile%perBand
+		if len(g.layer.Dimensions) == 2 {
+			channels = g.channels[band : band+1]
+		}
+	}
+	stride := 1 << level
+	across := g.tilesAcross(level, 0)
+	column, row := tile%across*g.tileSize[0], tile/across*g.tileSize[1]
+	width, height := min(g.tileSize[0], g.levelSize(level, 0)-column), min(g.tileSize[1], g.levelSize(level, 1)-row)
+
+	start := []int{column * stride, row * stride}
+	count := []int{min(g.tileSize[0]*stride, g.layer.Dimensions[0].Size-start[0]), min(g.tileSize[1]*stride, g.layer.Dimensions[1].Size-start[1])}
+	strides := []int{stride, stride}
+	if len(g.layer.Dimensions) == 3 {
+		start, count, strides = append(start, band), append(count, 1), append(strides, 1)
+	}
+	values, err := g.layer.ReadRange(r, g.header, start, count, WithChannels(channels...), WithStride(strides...))
+	if err != nil {
+		return nil, err
+	}
+	pixel := len(channels) * g.bandType.Size()
+	data := make([]byte, g.tileBytes())
+	for y := range height {
+		copy(data[y*g.tileSize[0]*pixel:], values[y*width*pixel:(y+1)*width*pixel])
+	}
+	return data, nil
+}
+
+// The entries of the image file directory of the overview level, with placeholders for the tile offsets
+// and byte counts. Only the full resolution image is given the georeferencing and metadata of the layer.
+func (g geoTIFFImage) entries(t tiffWriter, level int) []tiffEntry {
+	bands := g.bands()
+	format, _ := geoTIFFSampleFormat(g.bandType)
+	bits := uint16(g.bandType.Size() * 8)
+	planar := tiffPlanarChunky
+	if g.planar {
+		planar = tiffPlanarSeparate
+	}
+	subfile := uint32(0)
+	if level > 0 {
+		subfile = 1 // a reduced resolution version of the image
+	}
+	entries := []tiffEntry{
+		t.longs(tiffNewSubfileType, subfile),
+		t.longs(tiffImageWidth, uint32(g.levelSize(level, 0))),
+		t.longs(tiffImageLength, uint32(g.levelSize(level, 1))),
+		t.shorts(tiffBitsPerSample, slices.Repeat([]uint16{bits}, bands)...),
+		t.shorts(tiffCompression, g.compression),
+		t.shorts(tiffPhotometric, tiffPhotometricMinIsZero),
+		t.shorts(tiffSamplesPerPixel, uint16(bands)),
+		t.shorts(tiffPlanarConfiguration, planar),
+		t.longs(tiffTileWidth, uint32(g.tileSize[0])),
+		t.longs(tiffTileLength, uint32(g.tileSize[1])),
+		t.offsets(tiffTileOffsets, make([]uint64, g.tiles(level))),
+		t.offsets(tiffTileByteCounts, make([]uint64, g.tiles(level))),
+		t.shorts(tiffSampleFormat, slices.Repeat([]uint16{format}, bands)...),
+	}
+	if bands > 1 {
+		entries = append(entries, t.shorts(tiffExtraSamples, make([]uint16, bands-1)...))
+	}
+	if level > 0 {
+		return entries
+	}
+
+	x, y := g.layer.Dimensions[0].Axis, g.layer.Dimensions[1].Axis
+	x0, xStep := axisTransform(x)
+	y0, yStep := axisTransform(y)
+	if x != nil || y != nil || g.options.EPSG != 0 {
+		if xStep > 0 && yStep < 0 {
+			entries = append(entries,
+				t.doubles(tiffModelPixelScale, xStep, -yStep, 0),
+				t.doubles(tiffModelTiepoint, 0, 0, 0, x0, y0, 0))
+		} else {
+			entries = append(entries, t.doubles(tiffModelTransformation, xStep, 0, 0, x0, 0, yStep, 0, y0, 0, 0, 0, 0, 0, 0, 0, 1))
+		}
+		keys := [][2]uint16{{geoKeyRasterType, geoRasterPixelIsPoint}}
+		if g.options.EPSG != 0 {
+			if g.options.Geographic {
+				keys = append(keys, [2]uint16{geoKeyModelType, geoModelGeographic}, [2]uint16{geoKeyGeographicType, uint16(g.options.EPSG)})
+			} else {
+				keys = append(keys, [2]uint16{geoKeyModelType, geoModelProjected}, [2]uint16{geoKeyProjectedType, uint16(g.options.EPSG)})
+			}
+		}
+		slices.SortFunc(keys, func(a, b [2]uint16) int { return int(a[0]) - int(b[0]) })
+		directory := []uint16{1, 1, 0, uint16(len(keys))}
+		for _, key := range keys {
+			directory = append(directory, key[0], 0, 1, key[1])
+		}
+		entries = append(entries, t.shorts(tiffGeoKeyDirectory, directory...))
+	}
+
+	var metadata strings.Builder
+	for i := range bands {
+		channel := g.layer.Channels[g.layer.Channels.Index(g.channels[min(i, len(g.channels)-1)])]
+		description := channel.Name
+		if len(g.layer.Dimensions) == 3 {
+			description = fmt.Sprintf("%s %s=%d", channel.Name, g.layer.Dimensions[2].Name, i)
+		}
+		fmt.Fprintf(&metadata, `<Item name="DESCRIPTION" sample="%d" role="description">%s</Item>`, i, xmlText(description))
+		if channel.Unit != "" {
+			fmt.Fprintf(&metadata, `<Item name="UNITTYPE" sample="%d" role="unittype">%s</Item>`, i, xmlText(channel.Unit))
+		}
+	}
+	entries = append(entries, t.ascii(tiffGDALMetadata, "<GDALMetadata>"+metadata.String()+"</GDALMetadata>"))
+	if fill := g.layer.Channels[g.layer.Channels.Index(g.channels[0])].FillValue; fill != nil {
+		value, _ := g.bandType.ToFloat64(fill)
+		entries = append(entries, t.ascii(tiffGDALNoData, strconv.FormatFloat(value, 'g', -1, 64)))
+	}
+	return entries
+}
+
+// The coordinate at index zero of the axis and its step between indices, or the index itself for a nil
+// or incomplete axis.
+func axisTransform(axis *Axis) (float64, float64) {
+	if axis == nil || axis.Minimum == nil || axis.Step == nil {
+		return 0, 1
+	}
+	start, _ := axis.Type.ToFloat64(axis.Minimum)
+	step, _ := axis.Type.ToFloat64(axis.Step)
+	return start, step
+}
+
+func xmlText(s string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(s))
+	return escaped.String()
+}
+
+// The TIFF SampleFormat of the values of a channel type.
+func geoTIFFSampleFormat(t ChannelType) (uint16, error) {
+	switch t.Base() {
+	case ChannelBool, ChannelUint8, ChannelUint16, ChannelUint32, ChannelUint64:
+		return tiffSampleFormatUint, nil
+	case ChannelInt8, ChannelInt16, ChannelInt32, ChannelInt64:
+		return tiffSampleFormatInt, nil
+	case ChannelFloat16, ChannelFloat32, ChannelFloat64:
+		return tiffSampleFormatFloat, nil
+	}
+	return 0, ErrUnsupported(fmt.Sprintf("channel type %v has no GeoTIFF equivalent", t.Base()))
+}
+
+// An entry of a TIFF image file directory, with its values encoded.
+type tiffEntry struct {
+	tag   uint16
+	kind  uint16
+	count int
+	data  []byte
+}
+
+// Encodes the entries and structure of a classic or BigTIFF file in its byte order.
+type tiffWriter struct {
+	order binary.ByteOrder
+	big   bool
+}
+
+func (t tiffWriter) shorts(tag uint16, values ...uint16) tiffEntry {
+	data := []byte{}
+	for _, v := range values {
+		data = t.appendUint16(data, v)
+	}
+	return tiffEntry{tag: tag, kind: tiffTypeShort, count: len(values), data: data}
+}
+
+func (t tiffWriter) longs(tag uint16, values ...uint32) tiffEntry {
+	data := []byte{}
+	for _, v := range values {
+		data = t.appendUint32(data, v)
+	}
+	return tiffEntry{tag: tag, kind: tiffTypeLong, count: len(values), data: data}
+}
+
+func (t tiffWriter) doubles(tag uint16, values ...float64) tiffEntry {
+	data := []byte{}
+	for _, v := range values {
+		data = t.appendUint64(data, math.Float64bits(v))
+	}
+	return tiffEntry{tag: tag, kind: tiffTypeDouble, count: len(values), data: data}
+}
+
+func (t tiffWriter) ascii(tag uint16, s string) tiffEntry {
+	return tiffEntry{tag: tag, kind: tiffTypeASCII, count: len(s) + 1, data: append([]byte(s), 0)}
+}
+
+// An entry of file offsets or byte counts, which are 64-bit in BigTIFF files.
+func (t tiffWriter) offsets(tag uint16, values []uint64) tiffEntry {
+	if !t.big {
+		longs := make([]uint32, len(values))
+		for i, v := range values {
+			longs[i] = uint32(v)
+		}
+		return t.longs(tag, longs...)
+	}
+	data := []byte{}
+	for _, v := range values {
+		data = t.appendUint64(data, v)
+	}
+	return tiffEntry{tag: tag, kind: tiffTypeLong8, count: len(values), data: data}
+}
+
+func (t tiffWriter) appendUint16(data []byte, v uint16) []byte {
+	data = append(data, make([]byte, 2)...)
+	t.order.PutUint16(data[len(data)-2:], v)
+	return data
+}
+
+func (t tiffWriter) appendUint32(data []byte, v uint32) []byte {
+	data = append(data, make([]byte, 4)...)
+	t.order.PutUint32(data[len(data)-4:], v)
+	return data
+}
+
+func (t tiffWriter) appendUint64(data []byte, v uint64) []byte {
+	data = append(data, make([]byte, 8)...)
+	t.order.PutUint64(data[len(data)-8:], v)
+	return data
+}
+
+// The size of offsets, and so of the values held in the entries of image file directories.
+func (t tiffWriter) offsetSize() int {
+	if t.big {
+		return 8
+	}
+	return 4
+}
+
+func (t tiffWriter) putOffset(data []byte, offset uint64) []byte {
+	if t.big {
+		return t.appendUint64(data, offset)
+	}
+	return t.appendUint32(data, uint32(offset))
+}
+
+// Lays out the file header and the chain of image file directories with the values of their entries,
+// returning their bytes from the start of the file along with the position of the values of each entry.
+func (t tiffWriter) layout(ifds [][]tiffEntry) ([]byte, []map[uint16]int64) {
+	section := []byte{}
+	if t.order.Uint16([]byte{1, 0}) == 1 {
+		section = append(section, 'I', 'I')
+	} else {
+		section = append(section, 'M', 'M')
+	}
+	if t.big {
+		section = t.appendUint16(section, 43)
+		section = t.appendUint16(section, 8)
+		section = t.appendUint16(section, 0)
+	} else {
+		section = t.appendUint16(section, 42)
+	}
+	section = t.putOffset(section, uint64(len(section)+t.offsetSize()))
+
+	size := t.offsetSize()
+	positions := make([]map[uint16]int64, len(ifds))
+	for i, entries := range ifds {
+		slices.SortFunc(entries, func(a, b tiffEntry) int { return int(a.tag) - int(b.tag) })
+		start := len(section)
+		directory := 2 + len(entries)*12 + 4
+		if t.big {
+			directory = 8 + len(entries)*20 + 8
+		}
+		values := start + directory
+		extra := []byte{}
+		if t.big {
+			section = t.appendUint64(section, uint64(len(entries)))
+		} else {
+			section = t.appendUint16(section, uint16(len(entries)))
+		}
+		positions[i] = map[uint16]int64{}
+		for _, entry := range entries {
+			section = t.appendUint16(section, entry.tag)
+			section = t.appendUint16(section, entry.kind)
+			section = t.putOffset(section, uint64(entry.count))
+			if len(entry.data) <= size {
+				positions[i][entry.tag] = int64(len(section))
+				section = append(section, entry.data...)
+				section = append(section, make([]byte, size-len(entry.data))...)
+				continue
+			}
+			at := values + len(extra)
+			positions[i][entry.tag] = int64(at)
+			section = t.putOffset(section, uint64(at))
+			extra = append(extra, entry.data...)
+			if len(extra)%2 != 0 {
+				extra = append(extra, 0)
+			}
+		}
+		next := uint64(0)
+		if i < len(ifds)-1 {
+			next = uint64(values + len(extra))
+		}
+		section = t.putOffset(section, next)
+		section = append(section, extra...)
+	}
+	return section, positions
+}
+
+// Compresses the tiles of a TIFF file.
+type tiffCompressor struct {
+	compression uint16
+	zstd        *zstd.Encoder
+}
+
+func newTIFFCompressor(compression uint16) (*tiffCompressor, error) {
+	c := &tiffCompressor{compression: compression}
+	if compression == tiffCompressionZstd {
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		c.zstd = encoder
+	}
+	return c, nil
+}
+
+func (c *tiffCompressor) compress(data []byte) ([]byte, error) {
+	switch c.compression {
+	case tiffCompressionDeflate:
+		var buf bytes.Buffer
+		writer := zlib.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case tiffCompressionZstd:
+		return c.zstd.EncodeAll(data, nil), nil
+	}
+	return data, nil
+}
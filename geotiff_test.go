@@ -0,0 +1,353 @@
+package gopixi
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+	"github.com/gracefulearth/image/tiff"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The values of an entry of a TIFF image file directory, as integers, doubles, or text by type.
+type testTIFFEntry struct {
+	ints    []uint64
+	doubles []float64
+	text    string
+}
+
+// Parses the image file directories of a classic or BigTIFF file, in the order they are chained.
+func parseTestTIFF(t *testing.T, data []byte) (binary.ByteOrder, []map[uint16]testTIFFEntry) {
+	t.Helper()
+	var order binary.ByteOrder = binary.LittleEndian
+	if string(data[:2]) == "MM" {
+		order = binary.BigEndian
+	} else if string(data[:2]) != "II" {
+		t.Fatalf("unexpected byte order mark %q", data[:2])
+	}
+	big := order.Uint16(data[2:]) == 43
+	offset := func(at uint64) uint64 {
+		if big {
+			return order.Uint64(data[at:])
+		}
+		return uint64(order.Uint32(data[at:]))
+	}
+	size := uint64(4)
+	if big {
+		size = 8
+	}
+	ifds := []map[uint16]testTIFFEntry{}
+	for next := offset(4 + 4*boolInt(big)); next != 0; {
+		entries := map[uint16]testTIFFEntry{}
+		count, at := uint64(order.Uint16(data[next:])), next+2
+		if big {
+			count, at = order.Uint64(data[next:]), next+8
+		}
+		for range count {
+			tag, kind := order.Uint16(data[at:]), order.Uint16(data[at+2:])
+			n := offset(at + 4)
+			width := map[uint16]uint64{tiffTypeASCII: 1, tiffTypeShort: 2, tiffTypeLong: 4, tiffTypeDouble: 8, tiffTypeLong8: 8}[kind]
+			values := at + 4 + size
+			if n*width > size {
+				values = offset(values)
+			}
+			entry := testTIFFEntry{}
+			for i := range n {
+				v := data[values+i*width:]
+				switch kind {
+				case tiffTypeASCII:
+					entry.text = strings.TrimRight(string(data[values:values+n]), "\x00")
+				case tiffTypeShort:
+					entry.ints = append(entry.ints, uint64(order.Uint16(v)))
+				case tiffTypeLong:
+					entry.ints = append(entry.ints, uint64(order.Uint32(v)))
+				case tiffTypeLong8:
+					entry.ints = append(entry.ints, order.Uint64(v))
+				case tiffTypeDouble:
+					entry.doubles = append(entry.doubles, math.Float64frombits(order.Uint64(v)))
+				}
+			}
+			entries[tag] = entry
+			at += 4 + 2*size
+		}
+		ifds = append(ifds, entries)
+		next = offset(at)
+	}
+	return order, ifds
+}
+
+func boolInt(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Returns the decompressed tile of the image file directory.
+func testTIFFTile(t *testing.T, data []byte, ifd map[uint16]testTIFFEntry, tile int) []byte {
+	t.Helper()
+	offset, count := ifd[tiffTileOffsets].ints[tile], ifd[tiffTileByteCounts].ints[tile]
+	compressed := data[offset : offset+count]
+	switch uint16(ifd[tiffCompression].ints[0]) {
+	case tiffCompressionDeflate:
+		reader, err := zlib.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tileData, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tileData
+	case tiffCompressionZstd:
+		decoder, _ := zstd.NewReader(nil)
+		defer decoder.Close()
+		tileData, err := decoder.DecodeAll(compressed, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tileData
+	}
+	return compressed
+}
+
+func TestWriteGeoTIFF(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.LittleEndian, OffsetSize4)
+	layers := []Layer{NewLayer("grid", DimensionSet{
+		{Name: "lon", Size: 40, TileSize: 16, Axis: &Axis{Type: ChannelFloat64, Minimum: -10.0, Step: 0.5}},
+		{Name: "lat", Size: 24, TileSize: 16, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(50), Step: float32(-0.25)}},
+	}, ChannelSet{
+		{Name: "a", Type: ChannelUint16, Unit: "K", FillValue: uint16(9999)},
+		{Name: "b", Type: ChannelUint16},
+	}, WithCompression(CompressionFlate))}
+	value := func(x, y int) (uint16, uint16) { return uint16(x + 100*y), uint16(x * y) }
+	written := writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		a, b := value(coord[0], coord[1])
+		return Sample{a, b}
+	})
+
+	out := buffer.NewBuffer(10)
+	if err := written.WriteGeoTIFF(buffer.NewBufferFrom(buf.Bytes()), out, 0, GeoTIFFOptions{COG: true, EPSG: 4326, Geographic: true}); err != nil {
+		t.Fatal(err)
+	}
+	data := out.Bytes()
+	order, ifds := parseTestTIFF(t, data)
+	if order != binary.LittleEndian || binary.LittleEndian.Uint16(data[2:]) != 42 {
+		t.Fatalf("expected a little-endian classic TIFF, got %q", data[:4])
+	}
+	// 40x24 halves to 20x12 and 10x6, which fits in a single 16x16 tile
+	if len(ifds) != 3 {
+		t.Fatalf("expected the image and two overviews, got %d image file directories", len(ifds))
+	}
+
+	for level, ifd := range ifds {
+		width, height := int(ifd[tiffImageWidth].ints[0]), int(ifd[tiffImageLength].ints[0])
+		if width != (40+(1<<level)-1)>>level || height != (24+(1<<level)-1)>>level {
+			t.Errorf("level %d: unexpected size %dx%d", level, width, height)
+		}
+		if ifd[tiffNewSubfileType].ints[0] != uint64(min(level, 1)) || ifd[tiffPlanarConfiguration].ints[0] != 1 ||
+			!slices.Equal(ifd[tiffBitsPerSample].ints, []uint64{16, 16}) || !slices.Equal(ifd[tiffSampleFormat].ints, []uint64{1, 1}) ||
+			!slices.Equal(ifd[tiffExtraSamples].ints, []uint64{0}) || ifd[tiffCompression].ints[0] != uint64(tiffCompressionDeflate) {
+			t.Errorf("level %d: unexpected entries %+v", level, ifd)
+		}
+		across := (width + 15) / 16
+		for y := range height {
+			for x := range width {
+				tile := testTIFFTile(t, data, ifd, y/16*across+x/16)
+				at := ((y%16)*16 + x%16) * 4
+				a, b := value(x<<level, y<<level)
+				if got := [2]uint16{order.Uint16(tile[at:]), order.Uint16(tile[at+2:])}; got != [2]uint16{a, b} {
+					t.Fatalf("level %d: expected (%d, %d) at %d,%d, got %v", level, a, b, x, y, got)
+				}
+			}
+		}
+	}
+
+	// the tiles of the smallest overview come first, after every image file directory
+	last := uint64(0)
+	for level := len(ifds) - 1; level >= 0; level-- {
+		for _, offset := range ifds[level][tiffTileOffsets].ints {
+			if offset <= last {
+				t.Fatalf("level %d: expected tiles in order of increasing resolution, got offset %d after %d", level, offset, last)
+			}
+			last = offset
+		}
+	}
+
+	main := ifds[0]
+	if !slices.Equal(main[tiffModelPixelScale].doubles, []float64{0.5, 0.25, 0}) ||
+		!slices.Equal(main[tiffModelTiepoint].doubles, []float64{0, 0, 0, -10, 50, 0}) {
+		t.Errorf("unexpected georeferencing %+v and %+v", main[tiffModelPixelScale], main[tiffModelTiepoint])
+	}
+	if keys := main[tiffGeoKeyDirectory].ints; !slices.Equal(keys, []uint64{1, 1, 0, 3, 1024, 0, 1, 2, 1025, 0, 1, 2, 2048, 0, 1, 4326}) {
+		t.Errorf("unexpected GeoKeys %v", keys)
+	}
+	if main[tiffGDALNoData].text != "9999" {
+		t.Errorf("expected the fill value as no data, got %q", main[tiffGDALNoData].text)
+	}
+	metadata := main[tiffGDALMetadata].text
+	for _, item := range []string{`sample="0" role="description">a<`, `sample="1" role="description">b<`, `sample="0" role="unittype">K<`} {
+		if !strings.Contains(metadata, item) {
+			t.Errorf("expected %s in metadata %s", item, metadata)
+		}
+	}
+	if _, ok := ifds[1][tiffModelPixelScale]; ok {
+		t.Error("expected overviews without georeferencing")
+	}
+
+	// one channel, without overviews
+	out = buffer.NewBuffer(10)
+	if err := written.WriteGeoTIFF(buffer.NewBufferFrom(buf.Bytes()), out, 0, GeoTIFFOptions{Channels: []string{"b"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, ifds = parseTestTIFF(t, out.Bytes())
+	if len(ifds) != 1 || ifds[0][tiffSamplesPerPixel].ints[0] != 1 || ifds[0][tiffGDALNoData].text != "" {
+		t.Fatalf("expected a single band image without no data, got %+v", ifds)
+	}
+	if tile := testTIFFTile(t, out.Bytes(), ifds[0], 4); order.Uint16(tile[(3*16+5)*2:]) != 21*19 {
+		t.Errorf("expected channel b of sample 21,19, got %d", order.Uint16(tile[(3*16+5)*2:]))
+	}
+}
+
+func TestWriteGeoTIFFBandDimension(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.BigEndian, OffsetSize8)
+	layers := []Layer{NewLayer("cube", DimensionSet{
+		{Name: "x", Size: 20, TileSize: 10}, {Name: "y", Size: 12, TileSize: 6}, {Name: "band", Size: 3, TileSize: 1},
+	}, ChannelSet{{Name: "v", Type: ChannelFloat32}, {Name: "q", Type: ChannelUint8}}, WithCompression(CompressionZstd))}
+	value := func(coord SampleCoordinate) float32 {
+		return float32(coord[0]) + float32(coord[1])/100 + float32(coord[2])*1000
+	}
+	written := writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{value(coord), uint8(0)}
+	})
+
+	out := buffer.NewBuffer(10)
+	if err := written.WriteGeoTIFF(buffer.NewBufferFrom(buf.Bytes()), out, 0, GeoTIFFOptions{Channels: []string{"v"}, Overviews: 1, EPSG: 32633}); err != nil {
+		t.Fatal(err)
+	}
+	data := out.Bytes()
+	order, ifds := parseTestTIFF(t, data)
+	if order != binary.BigEndian || len(ifds) != 2 {
+		t.Fatalf("expected a big-endian image with one overview, got %v and %d image file directories", order, len(ifds))
+	}
+	main := ifds[0]
+	if main[tiffPlanarConfiguration].ints[0] != 2 || main[tiffSamplesPerPixel].ints[0] != 3 || main[tiffTileWidth].ints[0] != DefaultGeoTIFFTileSize ||
+		len(main[tiffTileOffsets].ints) != 3 || !slices.Equal(main[tiffSampleFormat].ints, []uint64{3, 3, 3}) ||
+		main[tiffCompression].ints[0] != uint64(tiffCompressionZstd) {
+		t.Fatalf("expected three float bands in separate tiles, got %+v", main)
+	}
+	if !slices.Equal(main[tiffModelTransformation].doubles, []float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}) {
+		t.Errorf("expected an index transformation, got %v", main[tiffModelTransformation].doubles)
+	}
+	if keys := main[tiffGeoKeyDirectory].ints; !slices.Equal(keys[4:], []uint64{1024, 0, 1, 1, 1025, 0, 1, 2, 3072, 0, 1, 32633}) {
+		t.Errorf("unexpected GeoKeys %v", keys)
+	}
+	if !strings.Contains(main[tiffGDALMetadata].text, `sample="2" role="description">v band=2<`) {
+		t.Errorf("expected band descriptions, got %s", main[tiffGDALMetadata].text)
+	}
+	for level, ifd := range ifds {
+		for band := range 3 {
+			tile := testTIFFTile(t, data, ifd, band)
+			if len(tile) != DefaultGeoTIFFTileSize*DefaultGeoTIFFTileSize*4 {
+				t.Fatalf("expected padded tiles, got %d bytes", len(tile))
+			}
+			for _, xy := range [][2]int{{0, 0}, {7, 5}, {(19 >> level), (11 >> level)}} {
+				at := (xy[1]*DefaultGeoTIFFTileSize + xy[0]) * 4
+				want := value(SampleCoordinate{xy[0] << level, xy[1] << level, band})
+				if got := math.Float32frombits(order.Uint32(tile[at:])); got != want {
+					t.Errorf("level %d band %d: expected %v at %v, got %v", level, band, want, xy, got)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteGeoTIFFDecode(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.LittleEndian, OffsetSize4)
+	layers := []Layer{NewLayer("gray", DimensionSet{{Name: "x", Size: 50, TileSize: 32}, {Name: "y", Size: 20, TileSize: 16}},
+		ChannelSet{{Name: "v", Type: ChannelUint16}}, WithCompression(CompressionFlate))}
+	written := writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint16(coord[0]*500 + coord[1])}
+	})
+	out := buffer.NewBuffer(10)
+	if err := written.WriteGeoTIFF(buffer.NewBufferFrom(buf.Bytes()), out, 0, GeoTIFFOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := tiff.Decode(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 8-bit images are avoided, as the decoder does not skip the padding of their edge tiles
+	gray, ok := img.(*image.Gray16)
+	if !ok || img.Bounds() != image.Rect(0, 0, 50, 20) {
+		t.Fatalf("expected a 50x20 16-bit gray image, got %T with bounds %v", img, img.Bounds())
+	}
+	for y := range 20 {
+		for x := range 50 {
+			if got := gray.Gray16At(x, y).Y; got != uint16(x*500+y) {
+				t.Fatalf("expected %d at %d,%d, got %d", x*500+y, x, y, got)
+			}
+		}
+	}
+}
+
+func TestWriteGeoTIFFErrors(t *testing.T) {
+	pixi := &Pixi{Header: NewHeader(binary.LittleEndian, OffsetSize4), Layers: []Layer{
+		NewLayer("line", DimensionSet{{Name: "x", Size: 10, TileSize: 10}}, ChannelSet{{Name: "v", Type: ChannelUint8}}),
+		NewLayer("mixed", DimensionSet{{Name: "x", Size: 10, TileSize: 10}, {Name: "y", Size: 10, TileSize: 10}},
+			ChannelSet{{Name: "a", Type: ChannelUint8}, {Name: "b", Type: ChannelFloat32}, {Name: "c", Type: ChannelBFloat16}}),
+		NewLayer("cube", DimensionSet{{Name: "x", Size: 10, TileSize: 10}, {Name: "y", Size: 10, TileSize: 10}, {Name: "z", Size: 2, TileSize: 2}},
+			ChannelSet{{Name: "a", Type: ChannelUint8}, {Name: "b", Type: ChannelUint8}}),
+	}}
+	var unsupported ErrUnsupported
+	var format ErrFormat
+	var notFound ErrChannelNotFound
+	for _, test := range []struct {
+		layer   int
+		options GeoTIFFOptions
+		target  any
+	}{
+		{0, GeoTIFFOptions{}, &unsupported},
+		{1, GeoTIFFOptions{}, &format},
+		{1, GeoTIFFOptions{Channels: []string{"c"}}, &unsupported},
+		{1, GeoTIFFOptions{Channels: []string{"missing"}}, &notFound},
+		{2, GeoTIFFOptions{}, &format},
+		{3, GeoTIFFOptions{}, &format},
+	} {
+		err := pixi.WriteGeoTIFF(buffer.NewBuffer(0), buffer.NewBuffer(0), test.layer, test.options)
+		if !errors.As(err, test.target) {
+			t.Errorf("layer %d with %+v: expected %T, got %v", test.layer, test.options, test.target, err)
+		}
+	}
+}
+
+func TestTIFFWriterBigLayout(t *testing.T) {
+	w := tiffWriter{order: binary.BigEndian, big: true}
+	ifds := [][]tiffEntry{
+		{w.longs(tiffImageWidth, 7), w.offsets(tiffTileOffsets, []uint64{1 << 40, 2}), w.ascii(tiffGDALNoData, "-1")},
+		{w.longs(tiffImageWidth, 3)},
+	}
+	section, positions := w.layout(ifds)
+	order, parsed := parseTestTIFF(t, section)
+	if order != binary.BigEndian || binary.BigEndian.Uint16(section[2:]) != 43 || len(parsed) != 2 {
+		t.Fatalf("expected a big-endian BigTIFF with two image file directories, got %q and %d", section[:4], len(parsed))
+	}
+	if parsed[0][tiffImageWidth].ints[0] != 7 || !slices.Equal(parsed[0][tiffTileOffsets].ints, []uint64{1 << 40, 2}) ||
+		parsed[0][tiffGDALNoData].text != "-1" || parsed[1][tiffImageWidth].ints[0] != 3 {
+		t.Errorf("unexpected entries %+v", parsed)
+	}
+	if at := positions[0][tiffTileOffsets]; binary.BigEndian.Uint64(section[at:]) != 1<<40 {
+		t.Errorf("expected the position of the tile offsets, got %d", at)
+	}
+}
@@ -1,14 +1,16 @@
 module github.com/gracefulearth/gopixi
 
-go 1.25.4
-
-require github.com/x448/float16 v0.8.4
+go 1.26.0
 
 require (
 	github.com/chenxingqiang/go-floatx v0.0.0-20240103165049-2f5e300cb3c3
 	github.com/gracefulearth/go-colorext v0.0.0-20251216211757-b64b7ec8ef8e
 	github.com/gracefulearth/image v0.0.0-20251216234636-b99e27345f8c
+	github.com/klauspost/compress v1.20.1
 	github.com/kshard/float8 v0.0.3
+	github.com/pierrec/lz4/v4 v4.1.30
 	github.com/shogo82148/float128 v0.3.0
 	github.com/shogo82148/int128 v0.2.1
+	github.com/x448/float16 v0.8.4
+	golang.org/x/text v0.42.0
 )
@@ -0,0 +1,199 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Decodes every "<name>.pixi" file at the root of the corpus, checking it against the FixtureExpectation in
+// the accompanying "<name>.json" file, as written by WriteConformanceSuite.
+func VerifyCorpus(corpus fs.FS) error {
+	names, err := fs.Glob(corpus, "*.pixi")
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return ErrFormat("corpus contains no pixi files")
+	}
+	failures := []string{}
+	for _, name := range names {
+		if err := verifyCorpusFile(corpus, name); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(failures) > 0 {
+		return ErrFormat("corpus files did not decode as expected:\n" + strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+func verifyCorpusFile(corpus fs.FS, name string) error {
+	data, err := fs.ReadFile(corpus, name)
+	if err != nil {
+		return err
+	}
+	expected, err := fs.ReadFile(corpus, strings.TrimSuffix(name, path.Ext(name))+".json")
+	if err != nil {
+		return err
+	}
+	var expectation FixtureExpectation
+	if err := json.Unmarshal(expected, &expectation); err != nil {
+		return err
+	}
+
+	r := bytes.NewReader(data)
+	read, err := ReadPixi(r)
+	if err != nil {
+		return err
+	}
+	if read.Header.Version != expectation.Version || read.Header.ByteOrder.String() != expectation.ByteOrder || int(read.Header.OffsetSize) != expectation.OffsetSize {
+		return fmt.Errorf("header is version %d, %v, %d-byte offsets; expected version %d, %s, %d-byte offsets",
+			read.Header.Version, read.Header.ByteOrder, read.Header.OffsetSize, expectation.Version, expectation.ByteOrder, expectation.OffsetSize)
+	}
+	if expectation.Checksum == "" {
+		expectation.Checksum = ChecksumCRC32.String() // not recorded before VersionExtensions
+	}
+	if read.Header.Checksum.String() != expectation.Checksum {
+		return fmt.Errorf("tiles have %v checksums, expected %s", read.Header.Checksum, expectation.Checksum)
+	}
+	if tags := read.AllTags(); !maps.Equal(tags, expectation.Tags) {
+		return fmt.Errorf("tags are %v, expected %v", tags, expectation.Tags)
+	}
+	if attributes := attributeExpectations(read.AllAttributes()); !sameJSON(attributes, expectation.Attributes) {
+		return fmt.Errorf("attributes are %v, expected %v", attributes, expectation.Attributes)
+	}
+	if axes := axisExpectations(read.SharedAxes()); !sameJSON(axes, expectation.Axes) {
+		return fmt.Errorf("shared axes are %v, expected %v", axes, expectation.Axes)
+	}
+	if len(read.Layers) != len(expectation.Layers) {
+		return fmt.Errorf("has %d layers, expected %d", len(read.Layers), len(expectation.Layers))
+	}
+	for i, layer := range read.Layers {
+		if err := verifyCorpusLayer(r, read.Header, layer, expectation.Layers[i]); err != nil {
+			return fmt.Errorf("layer %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func verifyCorpusLayer(r *bytes.Reader, h Header, layer Layer, expected LayerExpectation) error {
+	if layer.Name != expected.Name || layer.Separated != expected.Separated || layer.Compression.String() != expected.Compression {
+		return fmt.Errorf("is '%s' (separated %t, %s compression), expected '%s' (separated %t, %s compression)",
+			layer.Name, layer.Separated, layer.Compression, expected.Name, expected.Separated, expected.Compression)
+	}
+	if attributes := attributeExpectations(layer.Attributes); !sameJSON(attributes, expected.Attributes) {
+		return fmt.Errorf("attributes are %v, expected %v", attributes, expected.Attributes)
+	}
+	if georeference := georeferenceExpectation(layer.Georeference); !sameJSON(georeference, expected.Georeference) {
+		return fmt.Errorf("georeference is %v, expected %v", georeference, expected.Georeference)
+	}
+	if len(layer.Dimensions) != len(expected.Dimensions) || len(layer.Channels) != len(expected.Channels) {
+		return fmt.Errorf("has %d dimensions and %d channels, expected %d and %d",
+			len(layer.Dimensions), len(layer.Channels), len(expected.Dimensions), len(expected.Channels))
+	}
+	for d, dim := range layer.Dimensions {
+		want := expected.Dimensions[d]
+		if dim.Name != want.Name || dim.Size != want.Size || dim.TileSize != want.TileSize || dim.Halo != want.Halo {
+			return fmt.Errorf("dimension %d is %v with halo %d, expected %s(%d / %d) with halo %d", d, dim, dim.Halo, want.Name, want.Size, want.TileSize, want.Halo)
+		}
+		if (dim.Axis == nil) != (want.Axis == nil) {
+			return fmt.Errorf("dimension '%s' axis is %v, expected %v", dim.Name, dim.Axis, want.Axis)
+		}
+		if got := axisExpectation(dim.Axis); got != nil && !sameJSON(*got, *want.Axis) {
+			return fmt.Errorf("dimension '%s' axis is %+v, expected %+v", dim.Name, *got, *want.Axis)
+		}
+	}
+	for c, channel := range layer.Channels {
+		got := ChannelExpectation{
+			Name:      channel.Name,
+			Type:      channel.Type.Base().String(),
+			Unit:      channel.Unit,
+			Min:       expectedValue(channel.Type, channel.Min),
+			Max:       expectedValue(channel.Type, channel.Max),
+			FillValue: expectedValue(channel.Type, channel.FillValue),
+		}.withMoments(channel, h)
+		if got != expected.Channels[c] {
+			return fmt.Errorf("channel %d is %+v, expected %+v", c, got, expected.Channels[c])
+		}
+	}
+	for tile := range layer.DiskTiles() {
+		if absent := slices.Contains(expected.AbsentTiles, tile); absent != (layer.TileBytes[tile] == 0) {
+			return fmt.Errorf("tile %d absent is %t, expected %t", tile, !absent, absent)
+		}
+	}
+
+	opts := []ReadOption{WithAbsentTileZeros()}
+	if layer.Channels.HasFillValues() {
+		opts = nil
+	}
+	samples, err := layer.ReadRegion(r, h, FullRegion(layer.Dimensions), opts...)
+	if err != nil {
+		return err
+	}
+	if len(samples) != len(expected.Samples) {
+		return fmt.Errorf("has %d samples, expected %d", len(samples), len(expected.Samples))
+	}
+	for i, sample := range samples {
+		for c, value := range sample {
+			want := expected.Samples[i][c]
+			if got := expectedValue(layer.Channels[c].Type, value); want != nil && got != want {
+				return fmt.Errorf("sample %d channel '%s' is %v, expected %v", i, layer.Channels[c].Name, got, want)
+			}
+		}
+	}
+	if layer.Dimensions.HasHalo() {
+		return verifyCorpusHalos(NewFifoCacheReadLayer(r, h, layer, 4), expected)
+	}
+	return nil
+}
+
+// Reports whether two values have the same JSON encoding, so that decoded values can be compared with
+// expectations read back from JSON, in which every number is a float64.
+func sameJSON(a any, b any) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// Checks that the halo stored with every tile of the layer holds the expected samples of its neighbors.
+func verifyCorpusHalos(access TileAccessLayer, expected LayerExpectation) error {
+	layer := access.Layer()
+	for tile := range layer.Dimensions.Tiles() {
+		halo, err := ReadHaloTile(access, tile)
+		if err != nil {
+			return err
+		}
+		origin := halo.Origin()
+		var haloErr error
+		layer.forEachHaloSample(tile, func(slot int, coord SampleCoordinate) {
+			if haloErr != nil {
+				return
+			}
+			offset := make([]int, len(coord))
+			index, stride := 0, 1
+			for d, dim := range layer.Dimensions {
+				offset[d] = coord[d] - origin[d]
+				index += coord[d] * stride
+				stride *= dim.Size
+			}
+			sample, _ := halo.SampleAt(offset)
+			for c, value := range sample {
+				want := expected.Samples[index][c]
+				if got := expectedValue(layer.Channels[c].Type, value); want != nil && got != want {
+					haloErr = fmt.Errorf("tile %d halo sample %v channel '%s' is %v, expected %v", tile, coord, layer.Channels[c].Name, got, want)
+					return
+				}
+			}
+		})
+		if haloErr != nil {
+			return haloErr
+		}
+	}
+	return nil
+}
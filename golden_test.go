@@ -0,0 +1,93 @@
+package gopixi
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// The golden compatibility corpus: tiny canonical files, written once by this package and never
+// regenerated, for every on-disk format version and the main features of the format. Each "<name>.pixi"
+// file is accompanied by a "<name>.json" file holding its FixtureExpectation. Because the files do not
+// change as the package does, decoding them guards against changes to the read paths that would break
+// compatibility with files already in the wild.
+func goldenCorpus() fs.FS {
+	return os.DirFS(goldenDir)
+}
+
+const goldenDir = "testdata/golden"
+
+// Existing files of the corpus are never rewritten; updating only adds the files of new fixtures.
+var updateGolden = flag.Bool("update-golden", false, "add the files of new conformance fixtures to the golden corpus")
+
+func TestGoldenCorpus(t *testing.T) {
+	if *updateGolden {
+		dir := t.TempDir()
+		if err := WriteConformanceSuite(dir); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			target := filepath.Join(goldenDir, entry.Name())
+			if _, err := os.Stat(target); err == nil {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(target, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("added %s", target)
+		}
+		t.Skip("golden corpus updated, rerun without -update-golden")
+	}
+	if err := VerifyCorpus(goldenCorpus()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGoldenCorpusCoversVersions(t *testing.T) {
+	for version := 1; version <= Version; version++ {
+		for _, storage := range []string{"contiguous", "separated"} {
+			for _, variant := range []string{"le4", "le8", "be4", "be8"} {
+				name := fmt.Sprintf("v%d-%s-types-%s.pixi", version, variant, storage)
+				if _, err := fs.Stat(goldenCorpus(), name); err != nil {
+					t.Errorf("golden corpus is missing %s", name)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyCorpusDetectsChanges(t *testing.T) {
+	corpus := goldenCorpus()
+	data, err := fs.ReadFile(corpus, "metadata.pixi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectation, err := fs.ReadFile(corpus, "metadata.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := bytes.Replace(expectation, []byte(`"unit": "K"`), []byte(`"unit": "C"`), 1)
+	modified := fstest.MapFS{
+		"metadata.pixi": {Data: data},
+		"metadata.json": {Data: changed},
+	}
+	if err := VerifyCorpus(modified); err == nil {
+		t.Error("expected a changed expectation to be reported")
+	}
+	if err := VerifyCorpus(fstest.MapFS{}); err == nil {
+		t.Error("expected an empty corpus to be reported")
+	}
+}
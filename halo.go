@@ -0,0 +1,299 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+type haloOption struct {
+	halo []int
+}
+
+func (o haloOption) applyLayer(opts *layerOptions) {
+	opts.halo = o.halo
+}
+
+// Stores a halo around every tile of the layer, duplicating the given number of samples of the neighboring
+// tiles on either side of each tile (see ReadHaloTile). Either a single halo is given for every dimension, or
+// one for each dimension. Requires VersionExtensions, and layers with halos are written with AppendHaloLayer.
+func WithHalo(halo ...int) LayerOption {
+	return haloOption{halo: halo}
+}
+
+// Applies the halos given to WithHalo to the dimensions, which are not modified.
+func withHalos(dims DimensionSet, halo []int) DimensionSet {
+	if len(halo) == 0 {
+		return dims
+	}
+	dims = slices.Clone(dims)
+	for i := range dims {
+		if len(halo) == 1 {
+			dims[i].Halo = halo[0]
+		} else if i < len(halo) {
+			dims[i].Halo = halo[i]
+		}
+	}
+	return dims
+}
+
+// True if any dimension of the set has a halo.
+func (d DimensionSet) HasHalo() bool {
+	return slices.ContainsFunc(d, func(dim Dimension) bool { return dim.Halo > 0 })
+}
+
+// The number of halo samples stored in each tile in addition to its TileSamples. The samples of a tile are
+// stored first, in the usual order, followed by the samples of its halo: those of the box extending each
+// dimension of the tile by its Halo on either side that lie outside the tile, in the same order (the first
+// dimension changing most frequently). Halo samples beyond the edges of the layer are padding.
+func (d DimensionSet) HaloSamples() int {
+	if len(d) <= 0 {
+		return 0
+	}
+	samples := 1
+	for _, dim := range d {
+		samples *= dim.TileSize + 2*dim.Halo
+	}
+	return samples - d.TileSamples()
+}
+
+// For every position of the box covering a tile and its halo, in storage order, the index of the halo
+// sample stored there (counting from zero after the samples of the tile), or -1 if the position is within
+// the tile itself.
+func haloSlots(dims DimensionSet) []int {
+	extent := make([]int, len(dims))
+	total := 1
+	for i, dim := range dims {
+		extent[i] = dim.TileSize + 2*dim.Halo
+		total *= extent[i]
+	}
+	slots := make([]int, total)
+	position := make([]int, len(dims))
+	slot := 0
+	for index := range total {
+		inside := true
+		for i, dim := range dims {
+			if position[i] < dim.Halo || position[i] >= dim.Halo+dim.TileSize {
+				inside = false
+				break
+			}
+		}
+		if inside {
+			slots[index] = -1
+		} else {
+			slots[index] = slot
+			slot++
+		}
+		for i := range position {
+			position[i]++
+			if position[i] < extent[i] {
+				break
+			}
+			position[i] = 0
+		}
+	}
+	return slots
+}
+
+// Calls the given function for every halo sample of the (non-separated) tile that lies within the bounds
+// of the layer, passing the index of the halo sample and its coordinate in the layer.
+func (l Layer) forEachHaloSample(tile int, f func(slot int, coord SampleCoordinate)) {
+	dims := l.Dimensions
+	origin := TileSelector{Tile: tile}.ToTileCoordinate(dims).ToSampleCoordinate(dims)
+	extent := make([]int, len(dims))
+	for i, dim := range dims {
+		extent[i] = dim.TileSize + 2*dim.Halo
+	}
+	position := make([]int, len(dims))
+	for _, slot := range haloSlots(dims) {
+		if slot >= 0 {
+			coord := make(SampleCoordinate, len(dims))
+			inside := true
+			for i, dim := range dims {
+				coord[i] = origin[i] + position[i] - dim.Halo
+				inside = inside && coord[i] >= 0 && coord[i] < dim.Size
+			}
+			if inside {
+				f(slot, coord)
+			}
+		}
+		for i := range position {
+			position[i]++
+			if position[i] < extent[i] {
+				break
+			}
+			position[i] = 0
+		}
+	}
+}
+
+// Appends a layer with halos (see WithHalo) to the end of the file, taking the samples of the layer from
+// the source, which must have the same dimensions, tiling, channels, and arrangement as the layer except for
+// halos. The samples of each tile are copied from the matching tile of the source, and its halo is filled
+// from the neighboring tiles.
+func (p *Pixi) AppendHaloLayer(w io.WriteSeeker, layer Layer, source TileAccessLayer) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append layer"}
+	}
+	if layer.Dimensions.HasHalo() && p.Header.Version < VersionExtensions {
+		return ErrFormat(fmt.Sprintf("layer halos require version %d or later", VersionExtensions))
+	}
+	src := source.Layer()
+	if src.Separated != layer.Separated || len(src.Dimensions) != len(layer.Dimensions) || len(src.Channels) != len(layer.Channels) {
+		return ErrFormat("halo layer source must have the same dimensions, channels, and arrangement as the layer")
+	}
+	for i, dim := range src.Dimensions {
+		if dim.Size != layer.Dimensions[i].Size || dim.TileSize != layer.Dimensions[i].TileSize {
+			return ErrFormat("halo layer source must have the same dimensions and tiling as the layer")
+		}
+	}
+	for i, channel := range src.Channels {
+		if channel.Type.Base() != layer.Channels[i].Type.Base() {
+			return ErrFormat("halo layer source must have the same channel types as the layer")
+		}
+	}
+	p.checkpointed = false
+
+	layer.Channels = layer.Channels.withoutDistributions()
+	layer.TileBytes = make([]int64, layer.DiskTiles())
+	layer.TileOffsets = make([]int64, layer.DiskTiles())
+	layer.NextLayerStart = 0
+	tiles := layer.Dimensions.Tiles()
+	samples := layer.Dimensions.TileSamples()
+	encoder := p.newTileEncoder(len(p.Layers))
+	for tile := range layer.DiskTiles() {
+		srcData, err := source.Tile(tile)
+		if err != nil {
+			return err
+		}
+		data := make([]byte, layer.DiskTileSize(tile))
+		channelIndex := tile / tiles
+		coreBytes := samples * layer.Channels.Size()
+		if layer.Separated {
+			coreBytes = samples * layer.Channels[channelIndex].Size()
+			if layer.Channels[channelIndex].Type == ChannelBool {
+				coreBytes = (samples + 7) / 8
+			}
+		}
+		copy(data, srcData[:min(coreBytes, len(srcData))])
+
+		var haloErr error
+		layer.forEachHaloSample(tile%tiles, func(slot int, coord SampleCoordinate) {
+			if haloErr != nil {
+				return
+			}
+			sample, err := SampleAt(source, coord)
+			if err != nil {
+				haloErr = err
+				return
+			}
+			layer.putStoredSample(p.Header, data, channelIndex, samples+slot, sample)
+		})
+		if haloErr != nil {
+			return haloErr
+		}
+
+		// the source may read from the same stream, so seek back to the end for every tile
+		if _, err := w.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+		if err := layer.writeTileWith(encoder, w, p.Header, tile, data); err != nil {
+			return err
+		}
+		layer.updateTileStatistics(p.Header, tile, data)
+	}
+	return p.appendLayerHeader(w, layer)
+}
+
+// Writes the sample into the given stored position (a tile or halo sample index) of a decoded tile. For
+// separated layers only the value of the tile's channel is written.
+func (l Layer) putStoredSample(h Header, data []byte, channelIndex int, position int, sample Sample) {
+	if l.Separated {
+		l.putStoredValue(h, data, channelIndex, position, sample[channelIndex])
+		return
+	}
+	for i := range l.Channels {
+		l.putStoredValue(h, data, i, position, sample[i])
+	}
+}
+
+// A tile read together with its halo, giving access to the samples of the tile and of the halo around it
+// without reading the neighboring tiles.
+type HaloTile struct {
+	layer  Layer
+	header Header
+	origin SampleCoordinate
+	data   [][]byte // the decoded tile of each channel for separated layers, or the single tile
+	slots  []int
+}
+
+// Reads the (non-separated) tile with the given index, and its halo, from the layer.
+func ReadHaloTile(access TileAccessLayer, tile int) (*HaloTile, error) {
+	layer := access.Layer()
+	dims := layer.Dimensions
+	if tile < 0 || tile >= dims.Tiles() {
+		return nil, ErrTileNotFound{TileIndex: tile}
+	}
+	t := &HaloTile{
+		layer:  layer,
+		header: access.Header(),
+		origin: TileSelector{Tile: tile}.ToTileCoordinate(dims).ToSampleCoordinate(dims),
+		slots:  haloSlots(dims),
+	}
+	diskTiles := 1
+	if layer.Separated {
+		diskTiles = len(layer.Channels)
+	}
+	for channel := range diskTiles {
+		data, err := access.Tile(tile + dims.Tiles()*channel)
+		if err != nil {
+			return nil, err
+		}
+		t.data = append(t.data, data)
+	}
+	return t, nil
+}
+
+// The sample coordinate in the layer of the first sample of the tile.
+func (t *HaloTile) Origin() SampleCoordinate {
+	return t.origin
+}
+
+// The sample at the given offset from the origin of the tile, where each element of the offset may range
+// from -Halo up to TileSize+Halo (exclusive) of its dimension. Returns false if the offset reaches beyond
+// the halo or the edges of the layer.
+func (t *HaloTile) SampleAt(offset []int) (Sample, bool) {
+	dims := t.layer.Dimensions
+	if len(offset) != len(dims) {
+		return nil, false
+	}
+	index, stride := 0, 1
+	for i, dim := range dims {
+		position := offset[i] + dim.Halo
+		coord := t.origin[i] + offset[i]
+		if position < 0 || position >= dim.TileSize+2*dim.Halo || coord < 0 || coord >= dim.Size {
+			return nil, false
+		}
+		index += position * stride
+		stride *= dim.TileSize + 2*dim.Halo
+	}
+
+	var stored int
+	if slot := t.slots[index]; slot >= 0 {
+		stored = dims.TileSamples() + slot
+	} else {
+		inTile := make([]int, len(dims))
+		copy(inTile, offset)
+		stored = TileCoordinate{Tile: make([]int, len(dims)), InTile: inTile}.ToTileSelector(dims).InTile
+	}
+
+	sample := make(Sample, len(t.layer.Channels))
+	for i := range t.layer.Channels {
+		if t.layer.Separated {
+			sample[i] = t.layer.storedValue(t.header, t.data[i], i, stored)
+		} else {
+			sample[i] = t.layer.storedValue(t.header, t.data[0], i, stored)
+		}
+	}
+	return sample, true
+}
@@ -0,0 +1,188 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestHaloSamples(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 10, TileSize: 4, Halo: 1}, {Name: "y", Size: 10, TileSize: 3, Halo: 2}}
+	if samples := dims.HaloSamples(); samples != 6*7-4*3 {
+		t.Errorf("expected %d halo samples, got %d", 6*7-4*3, samples)
+	}
+	if samples := (DimensionSet{{Name: "x", Size: 10, TileSize: 4}}).HaloSamples(); samples != 0 {
+		t.Errorf("expected no halo samples, got %d", samples)
+	}
+	slots := haloSlots(dims)
+	inside, next := 0, 0
+	for _, slot := range slots {
+		if slot < 0 {
+			inside++
+		} else if slot != next {
+			t.Fatalf("expected halo slot %d, got %d", next, slot)
+		} else {
+			next++
+		}
+	}
+	if inside != dims.TileSamples() || next != dims.HaloSamples() {
+		t.Errorf("expected %d tile and %d halo positions, got %d and %d", dims.TileSamples(), dims.HaloSamples(), inside, next)
+	}
+}
+
+func TestWithHalo(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 10, TileSize: 4}, {Name: "y", Size: 10, TileSize: 4}}
+	layer := NewLayer("halo", dims, ChannelSet{{Name: "v", Type: ChannelUint8}}, WithHalo(2))
+	if layer.Dimensions[0].Halo != 2 || layer.Dimensions[1].Halo != 2 {
+		t.Errorf("expected halo of 2 in every dimension, got %v", layer.Dimensions)
+	}
+	if dims[0].Halo != 0 {
+		t.Error("expected the given dimensions to be unchanged")
+	}
+	layer = NewLayer("halo", dims, ChannelSet{{Name: "v", Type: ChannelUint8}}, WithHalo(1, 0))
+	if layer.Dimensions[0].Halo != 1 || layer.Dimensions[1].Halo != 0 {
+		t.Errorf("expected per-dimension halos, got %v", layer.Dimensions)
+	}
+	if size := layer.DiskTileSize(0); size != 6*4 {
+		t.Errorf("expected disk tile size %d, got %d", 6*4, size)
+	}
+}
+
+func haloTestSample(coord SampleCoordinate) Sample {
+	return Sample{uint16(coord[0]*100 + coord[1]), coord[0]%2 == coord[1]%3}
+}
+
+func TestAppendHaloLayer(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 11, TileSize: 4}, {Name: "y", Size: 7, TileSize: 3}}
+	channels := ChannelSet{{Name: "v", Type: ChannelUint16}, {Name: "b", Type: ChannelBool}}
+
+	for _, separated := range []bool{false, true} {
+		opts := []LayerOption{WithCompression(CompressionFlate)}
+		if separated {
+			opts = append(opts, WithPlanar())
+		}
+		buf := buffer.NewBuffer(10)
+		header := NewHeader(binary.LittleEndian, OffsetSize4)
+		source := NewLayer("source", dims, channels, opts...)
+		p := writeTestPixi(t, buf, header, nil, []Layer{source}, func(layer int, coord SampleCoordinate) Sample {
+			return haloTestSample(coord)
+		})
+		source = p.Layers[0]
+
+		layer := NewLayer("halo", dims, channels, append(opts, WithHalo(2, 1))...)
+		if err := p.AppendHaloLayer(buf, layer, NewFifoCacheReadLayer(buf, p.Header, source, 4)); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.AddChannel(buf, 1, Channel{Name: "w", Type: ChannelInt32}, func(coord SampleCoordinate) any {
+			return int32(-coord[0] - coord[1])
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		r := buffer.NewBufferFrom(buf.Bytes())
+		read, err := ReadPixi(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		haloLayer := read.Layers[1]
+		if haloLayer.Dimensions[0].Halo != 2 || haloLayer.Dimensions[1].Halo != 1 {
+			t.Fatalf("expected halos to be read, got %v", haloLayer.Dimensions)
+		}
+		if haloLayer.Channels[0].Min != uint16(0) || haloLayer.Channels[0].Max != uint16(1006) {
+			t.Errorf("unexpected channel statistics %v", haloLayer.Channels[0])
+		}
+		access := NewFifoCacheReadLayer(r, read.Header, haloLayer, 4)
+		for tile := range haloLayer.Dimensions.Tiles() {
+			halo, err := ReadHaloTile(access, tile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			origin := halo.Origin()
+			for dx := -3; dx < 7; dx++ {
+				for dy := -2; dy < 5; dy++ {
+					coord := SampleCoordinate{origin[0] + dx, origin[1] + dy}
+					sample, ok := halo.SampleAt([]int{dx, dy})
+					expectOk := dx >= -2 && dx < 6 && dy >= -1 && dy < 4 && dims.ContainsCoordinate(coord)
+					if ok != expectOk {
+						t.Fatalf("separated %t tile %d offset (%d, %d): expected ok %t, got %t", separated, tile, dx, dy, expectOk, ok)
+					}
+					if !ok {
+						continue
+					}
+					want := append(haloTestSample(coord), int32(-coord[0]-coord[1]))
+					for c := range want {
+						if sample[c] != want[c] {
+							t.Fatalf("separated %t tile %d offset (%d, %d): expected %v, got %v", separated, tile, dx, dy, want, sample)
+						}
+					}
+				}
+			}
+		}
+
+		samples, err := haloLayer.ReadRegion(r, read.Header, FullRegion(haloLayer.Dimensions))
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := 0
+		for coord := range haloLayer.Dimensions.SampleCoordinates() {
+			if samples[i][0] != haloTestSample(coord)[0] {
+				t.Fatalf("sample %v read as %v", coord, samples[i])
+			}
+			i++
+		}
+	}
+}
+
+func TestHaloLayerErrors(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 8, TileSize: 4}}
+	channels := ChannelSet{{Name: "v", Type: ChannelUint8}}
+	layer := NewLayer("halo", dims, channels, WithHalo(1))
+
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{NewLayer("source", dims, channels)}, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+	writer := NewTileOrderWriteIterator(buf, p.Header, layer)
+	err := p.AppendIterativeLayer(buf, layer, writer, func(writer IterativeLayerWriter) error { return nil })
+	var unsupported ErrUnsupported
+	if !errors.As(err, &unsupported) {
+		t.Errorf("expected iterative layers with halos to be unsupported, got %v", err)
+	}
+	deferred, err := NewDeferredWriter(buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deferred.AppendLayer(layer, func(writer IterativeLayerWriter) error { return nil }); !errors.As(err, &unsupported) {
+		t.Errorf("expected deferred layers with halos to be unsupported, got %v", err)
+	}
+	streamed, err := NewStreamWriter(buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := streamed.AppendLayer(layer, func(writer IterativeLayerWriter) error { return nil }); !errors.As(err, &unsupported) {
+		t.Errorf("expected streamed layers with halos to be unsupported, got %v", err)
+	}
+
+	source := NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 4)
+	mismatched := NewLayer("halo", DimensionSet{{Name: "x", Size: 8, TileSize: 2}}, channels, WithHalo(1))
+	if err := p.AppendHaloLayer(buf, mismatched, source); err == nil {
+		t.Error("expected an error for a source with different tiling")
+	}
+
+	old := &Pixi{Header: Header{Version: VersionExtensions - 1, ByteOrder: binary.LittleEndian, OffsetSize: OffsetSize4}}
+	if err := old.AppendHaloLayer(buf, layer, source); err == nil {
+		t.Error("expected an error for halos in an older version")
+	}
+
+	if err := p.AppendHaloLayer(buf, layer, source); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.UpdateTiles(buf, 1, map[int][]byte{0: make([]byte, layer.DiskTileSize(0))}); !errors.As(err, &unsupported) {
+		t.Errorf("expected updating tiles of layers with halos to be unsupported, got %v", err)
+	}
+	if err := p.RewriteTiles(buf, 1, map[int][]byte{0: make([]byte, layer.DiskTileSize(0))}); !errors.As(err, &unsupported) {
+		t.Errorf("expected rewriting tiles of layers with halos to be unsupported, got %v", err)
+	}
+}
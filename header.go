@@ -1,10 +1,15 @@
 package gopixi
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 const (
@@ -26,6 +31,19 @@ type Header struct {
 	ByteOrder        binary.ByteOrder
 	FirstLayerOffset int64
 	FirstTagsOffset  int64
+	// The algorithm of the checksum stored after every tile, recorded after the offsets from VersionExtensions
+	// onward. Files of earlier versions always use ChecksumCRC32.
+	Checksum ChecksumAlgorithm
+
+	// The maximum number of bytes allowed in friendly strings (names, units, tags) written or read
+	// with this header. Not stored in the file. Zero (or any value above the format limit) means the
+	// limit imposed by the format version: MaxFriendlyLength, or MaxLongFriendlyLength from
+	// VersionLongStrings onward.
+	MaxFriendlyLength int
+
+	// The string table of the layer header being read or written, if it was written with
+	// WithHeaderDictionary, through which friendly strings are written as indices.
+	dictionary *stringTable
 }
 
 // Creates a new Pixi header struct with the given byte order and offset size, setting
@@ -40,7 +58,11 @@ func NewHeader(byteOrder binary.ByteOrder, offsetSize OffsetSize) Header {
 
 // Get the size in bytes of the full Pixi header (including first tag section and first layer offsets) as it is laid out and written to disk.
 func (s Header) DiskSize() int {
-	return 4 + 2 + 1 + 1 + 2*int(s.OffsetSize)
+	size := 4 + 2 + 1 + 1 + 2*int(s.OffsetSize)
+	if s.Version >= VersionExtensions {
+		size += 4 // four bytes for the checksum algorithm
+	}
+	return size
 }
 
 // Writes a fixed size value, or a slice of such values, using the byte order given in the header.
@@ -121,34 +143,133 @@ func (s Header) ReadOffsets(r io.Reader, offsets []int64) error {
 	panic("pixi: unsupported offset size")
 }
 
+const (
+	// The maximum number of bytes in a friendly string before VersionLongStrings, as limited by its
+	// 16-bit length prefix.
+	MaxFriendlyLength int = math.MaxUint16
+	// The maximum number of bytes in a friendly string from VersionLongStrings onward. The extended
+	// length is stored in 32 bits, but is limited to the signed range so it fits in an int everywhere.
+	MaxLongFriendlyLength int = math.MaxInt32
+
+	// The 16-bit length prefix value indicating that a 32-bit extended length follows.
+	friendlyExtendedLength uint16 = math.MaxUint16
+)
+
 // Writes a 'friendly' name from to the writer stream at the current position. A
 // 'friendly' string is always the same format, specified by a 16-bit length followed
-// by that number of bytes of UTF8 string.
+// by that number of bytes of UTF8 string, normalized to NFC. Returns an ErrFriendlyString
+// error if the string is not valid UTF-8 or is longer than the header allows.
 func (s Header) WriteFriendly(w io.Writer, friendly string) error {
-	strBytes := []byte(friendly)
-	err := s.Write(w, uint16(len(strBytes)))
+	strBytes, err := s.normalizeFriendly(friendly)
 	if err != nil {
-		return nil
+		return err
+	}
+	if s.dictionary != nil {
+		index, ok := s.dictionary.index(strBytes)
+		if !ok {
+			return ErrFriendlyString{Value: friendly, Reason: "not in the string table of the layer header"}
+		}
+		return s.Write(w, uint32(index))
+	}
+	if s.Version >= VersionLongStrings && len(strBytes) >= int(friendlyExtendedLength) {
+		err = s.Write(w, friendlyExtendedLength)
+		if err == nil {
+			err = s.Write(w, uint32(len(strBytes)))
+		}
+	} else {
+		err = s.Write(w, uint16(len(strBytes)))
+	}
+	if err != nil {
+		return err
 	}
 	return s.Write(w, strBytes)
 }
 
 // Read a 'friendly' name from the reader stream at the current position. 'Friendly'
 // strings are always the same format, specified by a 16-bit length followed by that
-// number of bytes interpreted as a UTF8 string.
+// number of bytes interpreted as a UTF8 string, returned normalized to NFC.
 func (s Header) ReadFriendly(r io.Reader) (string, error) {
-	var strLen uint16
-	err := s.Read(r, &strLen)
+	if s.dictionary != nil {
+		var index uint32
+		if err := s.Read(r, &index); err != nil {
+			return "", err
+		}
+		return s.dictionary.lookup(index)
+	}
+	var shortLen uint16
+	err := s.Read(r, &shortLen)
+	if err != nil {
+		return "", err
+	}
+	strLen := int(shortLen)
+	if s.Version >= VersionLongStrings && shortLen == friendlyExtendedLength {
+		var longLen uint32
+		err = s.Read(r, &longLen)
+		if err != nil {
+			return "", err
+		}
+		strLen = int(longLen)
+	}
+	if strLen > s.friendlyLimit() {
+		return "", ErrFriendlyString{Reason: fmt.Sprintf("length %d exceeds limit of %d bytes", strLen, s.friendlyLimit())}
+	}
+
+	// copy rather than allocating the full length up front, in case a corrupted length is huge
+	strBuf := bytes.NewBuffer(make([]byte, 0, min(strLen, int(friendlyExtendedLength))))
+	_, err = io.CopyN(strBuf, r, int64(strLen))
 	if err != nil {
 		return "", err
 	}
-	strBytes := make([]byte, int(strLen))
-	err = s.Read(r, strBytes)
-	return string(strBytes), err
+	strBytes := strBuf.Bytes()
+	if !utf8.Valid(strBytes) {
+		return "", ErrFriendlyString{Value: string(strBytes), Reason: "not valid UTF-8"}
+	}
+	return norm.NFC.String(string(strBytes)), nil
+}
+
+// Get the size in bytes of a friendly string as it is written to disk, including its length prefix.
+func (s Header) FriendlySize(friendly string) int {
+	if s.dictionary != nil {
+		return 4
+	}
+	strLen := len(norm.NFC.String(friendly))
+	if s.Version >= VersionLongStrings && strLen >= int(friendlyExtendedLength) {
+		return 2 + 4 + strLen
+	}
+	return 2 + strLen
+}
+
+func (s Header) friendlyLimit() int {
+	formatLimit := MaxFriendlyLength
+	if s.Version >= VersionLongStrings {
+		formatLimit = MaxLongFriendlyLength
+	}
+	if s.MaxFriendlyLength <= 0 || s.MaxFriendlyLength > formatLimit {
+		return formatLimit
+	}
+	return s.MaxFriendlyLength
+}
+
+func (s Header) normalizeFriendly(friendly string) ([]byte, error) {
+	if !utf8.ValidString(friendly) {
+		return nil, ErrFriendlyString{Value: friendly, Reason: "not valid UTF-8"}
+	}
+	strBytes := []byte(norm.NFC.String(friendly))
+	if len(strBytes) > s.friendlyLimit() {
+		return nil, ErrFriendlyString{Value: friendly, Reason: fmt.Sprintf("length %d exceeds limit of %d bytes", len(strBytes), s.friendlyLimit())}
+	}
+	return strBytes, nil
 }
 
 // Write the information in this header to the current position in the writer stream.
 func (h Header) WriteHeader(w io.Writer) error {
+	if h.Checksum.Size() == 0 {
+		return ErrFormat(fmt.Sprintf("unknown checksum algorithm %v", h.Checksum))
+	}
+	if h.Checksum != ChecksumCRC32 && h.Version < VersionExtensions {
+		return ErrFormat(fmt.Sprintf("checksum algorithms other than crc32 require version %d or later", VersionExtensions))
+	}
+
 	// write file type (4 bytes)
 	_, err := w.Write([]byte(FileType))
 	if err != nil {
@@ -184,7 +305,13 @@ func (h Header) WriteHeader(w io.Writer) error {
 	}
 
 	// write first tags offset
-	return h.WriteOffset(w, h.FirstTagsOffset)
+	err = h.WriteOffset(w, h.FirstTagsOffset)
+	if err != nil || h.Version < VersionExtensions {
+		return err
+	}
+
+	// write checksum algorithm
+	return h.Write(w, h.Checksum)
 }
 
 // Read Pixi header information into this struct from the current position in the reader stream.
@@ -251,6 +378,18 @@ func (h *Header) ReadHeader(r io.Reader) error {
 	}
 	h.FirstTagsOffset = firstTagsOffset
 
+	// read checksum algorithm
+	h.Checksum = ChecksumCRC32
+	if h.Version >= VersionExtensions {
+		err = h.Read(r, &h.Checksum)
+		if err != nil {
+			return err
+		}
+		if h.Checksum.Size() == 0 {
+			return ErrFormat(fmt.Sprintf("unknown checksum algorithm %v", h.Checksum))
+		}
+	}
+
 	return nil
 }
 
@@ -258,7 +397,7 @@ func (h *Header) ReadHeader(r io.Reader) error {
 // and the first tags sections. Useful during initial file creation or editing, especially for large data
 // that is difficult to know the size of in advance. After completing the offsets overwrite, or upon encountering
 // an error in attempting to do so, this function will return the cursor to the position at which it was
-// when the call to this function was made.
+// when the call to this function was made. Both offsets are written at once.
 func (h *Header) OverwriteOffsets(w io.WriteSeeker, firstLayer int64, firstTags int64) error {
 	oldPos, err := w.Seek(0, io.SeekCurrent)
 	if err != nil {
@@ -270,16 +409,17 @@ func (h *Header) OverwriteOffsets(w io.WriteSeeker, firstLayer int64, firstTags
 		return err
 	}
 
-	err = h.WriteOffset(w, firstLayer)
-	if err != nil {
+	offsets := &bytes.Buffer{}
+	if err = h.WriteOffset(offsets, firstLayer); err != nil {
 		return err
 	}
-	h.FirstLayerOffset = firstLayer
-
-	err = h.WriteOffset(w, firstTags)
-	if err != nil {
+	if err = h.WriteOffset(offsets, firstTags); err != nil {
 		return err
 	}
+	if _, err = w.Write(offsets.Bytes()); err != nil {
+		return err
+	}
+	h.FirstLayerOffset = firstLayer
 	h.FirstTagsOffset = firstTags
 
 	return nil
@@ -2,7 +2,9 @@ package gopixi
 
 import (
 	"bytes"
+	"errors"
 	"math/rand/v2"
+	"strings"
 	"testing"
 
 	"github.com/gracefulearth/gopixi/internal/buffer"
@@ -58,3 +60,97 @@ func TestWriteReadHeader(t *testing.T) {
 		}
 	}
 }
+
+func TestFriendlyNormalization(t *testing.T) {
+	decomposed := "cafe\u0301"
+	composed := "caf\u00e9"
+	for _, header := range allHeaderVariants(Version) {
+		buf := buffer.NewBuffer(10)
+		err := header.WriteFriendly(buf, decomposed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(buf.Bytes()) != header.FriendlySize(decomposed) {
+			t.Errorf("expected friendly size %d, wrote %d bytes", header.FriendlySize(decomposed), len(buf.Bytes()))
+		}
+		read, err := header.ReadFriendly(buffer.NewBufferFrom(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read != composed {
+			t.Errorf("expected NFC string %q, got %q", composed, read)
+		}
+	}
+}
+
+func TestFriendlyInvalid(t *testing.T) {
+	header := allHeaderVariants(Version)[0]
+
+	err := header.WriteFriendly(buffer.NewBuffer(10), "bad\xffname")
+	var friendlyErr ErrFriendlyString
+	if !errors.As(err, &friendlyErr) {
+		t.Errorf("expected ErrFriendlyString for invalid UTF-8, got %v", err)
+	}
+
+	// bypass the writer checks to simulate a file written by another implementation
+	buf := buffer.NewBuffer(10)
+	header.Write(buf, uint16(3))
+	buf.Write([]byte{'a', 0xc3, 'b'})
+	_, err = header.ReadFriendly(buffer.NewBufferFrom(buf.Bytes()))
+	if !errors.As(err, &friendlyErr) {
+		t.Errorf("expected ErrFriendlyString reading invalid UTF-8, got %v", err)
+	}
+
+	v1 := header
+	v1.Version = 1
+	err = v1.WriteFriendly(buffer.NewBuffer(10), strings.Repeat("x", MaxFriendlyLength+1))
+	if !errors.As(err, &friendlyErr) {
+		t.Errorf("expected ErrFriendlyString for string longer than the format allows, got %v", err)
+	}
+
+	limited := header
+	limited.MaxFriendlyLength = 4
+	err = limited.WriteFriendly(buffer.NewBuffer(10), "toolong")
+	if !errors.As(err, &friendlyErr) {
+		t.Errorf("expected ErrFriendlyString for string longer than the configured limit, got %v", err)
+	}
+	buf = buffer.NewBuffer(10)
+	err = header.WriteFriendly(buf, "toolong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = limited.ReadFriendly(buffer.NewBufferFrom(buf.Bytes()))
+	if !errors.As(err, &friendlyErr) {
+		t.Errorf("expected ErrFriendlyString reading string longer than the configured limit, got %v", err)
+	}
+}
+
+func TestFriendlyLongStrings(t *testing.T) {
+	for _, header := range allHeaderVariants(Version) {
+		for _, length := range []int{MaxFriendlyLength - 1, MaxFriendlyLength, 200000} {
+			long := strings.Repeat("λ", length/2) + strings.Repeat("x", length%2)
+			buf := buffer.NewBuffer(10)
+			err := header.WriteFriendly(buf, long)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(buf.Bytes()) != header.FriendlySize(long) {
+				t.Errorf("expected friendly size %d, wrote %d bytes", header.FriendlySize(long), len(buf.Bytes()))
+			}
+			read, err := header.ReadFriendly(buffer.NewBufferFrom(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if read != long {
+				t.Errorf("expected long string of %d bytes to round trip, got %d bytes", len(long), len(read))
+			}
+		}
+	}
+
+	// version 1 has no extended lengths, but still allows the full 16-bit length
+	v1 := allHeaderVariants(1)[0]
+	err := v1.WriteFriendly(buffer.NewBuffer(10), strings.Repeat("x", MaxFriendlyLength))
+	if err != nil {
+		t.Errorf("expected version 1 to allow strings of exactly %d bytes, got %v", MaxFriendlyLength, err)
+	}
+}
@@ -0,0 +1,161 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type headerDictionaryOption struct{}
+
+func (o headerDictionaryOption) applyLayer(opts *layerOptions) {
+	opts.headerDictionary = true
+}
+
+// Writes the friendly strings of the layer header through a string table stored at the start of the header,
+// storing repeated strings once. Requires VersionExtensions or later.
+func WithHeaderDictionary() LayerOption {
+	return headerDictionaryOption{}
+}
+
+// The distinct friendly strings of a layer header, in normalization form C and sorted so that strings
+// sharing a prefix are next to each other. While a layer header is read or written, the header it is read
+// or written with holds the table, and friendly strings are written as their index in the table.
+//
+// The table is stored as a 32-bit count of strings followed by each string in turn, as a 32-bit number of
+// leading bytes it shares with the previous string and a friendly string holding the rest of its bytes.
+type stringTable struct {
+	strings []string
+	indices map[string]int
+}
+
+func newStringTable(values []string) *stringTable {
+	table := &stringTable{indices: map[string]int{}}
+	for _, value := range values {
+		table.indices[norm.NFC.String(value)] = 0
+	}
+	for value := range table.indices {
+		table.strings = append(table.strings, value)
+	}
+	slices.Sort(table.strings)
+	for i, value := range table.strings {
+		table.indices[value] = i
+	}
+	return table
+}
+
+// The string table holding every friendly string of the layer header.
+func (d Layer) stringTable() *stringTable {
+	values := []string{d.Name}
+	for _, dim := range d.Dimensions {
+		values = append(values, dim.Name)
+		if dim.Axis != nil && dim.Axis.Ref != "" {
+			values = append(values, dim.Axis.Ref)
+		} else if dim.Axis != nil {
+			values = append(values, dim.Axis.Unit)
+		}
+	}
+	for _, channel := range d.Channels {
+		values = append(values, channel.Name)
+		if channel.Unit != "" {
+			values = append(values, channel.Unit)
+		}
+	}
+	values = append(values, attributeStrings(d.Attributes)...)
+	if d.Georeference != nil {
+		values = append(values, d.Georeference.CRS.WKT)
+	}
+	return newStringTable(values)
+}
+
+// The number of leading bytes two strings share, without splitting a UTF-8 encoded rune or a combining
+// sequence, so that the remainder of the second string is valid UTF-8 in normalization form C on its own.
+func sharedPrefix(a string, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	for n > 0 && n < len(b) && (!utf8.RuneStart(b[n]) || !norm.NFC.PropertiesString(b[n:]).BoundaryBefore()) {
+		n--
+	}
+	return n
+}
+
+// The size in bytes of the table as it is written to disk.
+func (t *stringTable) DiskSize(h Header) int {
+	h.dictionary = nil
+	size := 4
+	previous := ""
+	for _, value := range t.strings {
+		size += 4 + h.FriendlySize(value[sharedPrefix(previous, value):])
+		previous = value
+	}
+	return size
+}
+
+// Writes the table to the current position in the writer stream.
+func (t *stringTable) Write(w io.Writer, h Header) error {
+	h.dictionary = nil
+	if err := h.Write(w, uint32(len(t.strings))); err != nil {
+		return err
+	}
+	previous := ""
+	for _, value := range t.strings {
+		shared := sharedPrefix(previous, value)
+		if err := h.Write(w, uint32(shared)); err != nil {
+			return err
+		}
+		if err := h.WriteFriendly(w, value[shared:]); err != nil {
+			return err
+		}
+		previous = value
+	}
+	return nil
+}
+
+// Reads a table from the current position in the reader stream.
+func readStringTable(r io.Reader, h Header) (*stringTable, error) {
+	h.dictionary = nil
+	var count uint32
+	if err := h.Read(r, &count); err != nil {
+		return nil, ErrFormat(fmt.Sprintf("reading string table count: %s", err))
+	}
+	table := &stringTable{indices: map[string]int{}}
+	previous := ""
+	for i := range int(count) {
+		var shared uint32
+		if err := h.Read(r, &shared); err != nil {
+			return nil, ErrFormat(fmt.Sprintf("reading string table entry %d: %s", i, err))
+		}
+		if int(shared) > len(previous) {
+			return nil, ErrFormat(fmt.Sprintf("string table entry %d shares %d bytes with an entry of %d bytes", i, shared, len(previous)))
+		}
+		suffix, err := h.ReadFriendly(r)
+		if err != nil {
+			return nil, ErrFormat(fmt.Sprintf("reading string table entry %d: %s", i, err))
+		}
+		value := previous[:shared] + suffix
+		if len(value) > h.friendlyLimit() {
+			return nil, ErrFriendlyString{Reason: fmt.Sprintf("length %d exceeds limit of %d bytes", len(value), h.friendlyLimit())}
+		}
+		table.strings = append(table.strings, value)
+		table.indices[value] = i
+		previous = value
+	}
+	return table, nil
+}
+
+func (t *stringTable) index(value []byte) (int, bool) {
+	index, ok := t.indices[string(value)]
+	return index, ok
+}
+
+func (t *stringTable) lookup(index uint32) (string, error) {
+	if int64(index) >= int64(len(t.strings)) {
+		return "", ErrFormat(fmt.Sprintf("string index %d is outside of the string table of %d strings", index, len(t.strings)))
+	}
+	return t.strings[index], nil
+}
@@ -0,0 +1,107 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func dictionaryLayer(opts ...LayerOption) Layer {
+	channels := ChannelSet{}
+	for i := range 200 {
+		channels = append(channels, Channel{Name: fmt.Sprintf("atmosphere_mole_content_of_ozone_band_%03d", i), Type: ChannelFloat32, Unit: "mol m-2"})
+	}
+	channels = append(channels, Channel{Name: "caf\u00e9", Type: ChannelUint8}, Channel{Name: "caf\u00e9s", Type: ChannelUint8})
+	return NewLayer("ozone", DimensionSet{
+		{Name: "x", Size: 4, TileSize: 2, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(0), Step: float32(1), Unit: "m"}},
+		{Name: "y", Size: 4, TileSize: 2, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(0), Step: float32(1), Unit: "m"}},
+	}, channels, append(opts, WithPlanar())...)
+}
+
+func TestHeaderDictionaryWriteRead(t *testing.T) {
+	for _, h := range allHeaderVariants(Version) {
+		plain := dictionaryLayer()
+		layer := dictionaryLayer(WithHeaderDictionary())
+		buf := buffer.NewBuffer(10)
+		if err := layer.WriteHeader(buf, h); err != nil {
+			t.Fatal(err)
+		}
+		if len(buf.Bytes()) != layer.HeaderSize(h) {
+			t.Errorf("wrote %d bytes but expected a header size of %d", len(buf.Bytes()), layer.HeaderSize(h))
+		}
+		index := 2 * layer.DiskTiles() * int(h.OffsetSize)
+		if layer.HeaderSize(h)-index > (plain.HeaderSize(h)-index)/2 {
+			t.Errorf("expected the dictionary to at least halve the header metadata, got %d bytes from %d", layer.HeaderSize(h)-index, plain.HeaderSize(h)-index)
+		}
+
+		read := Layer{}
+		if err := read.ReadLayer(buffer.NewBufferFrom(buf.Bytes()), h); err != nil {
+			t.Fatal(err)
+		}
+		if !read.HeaderDictionary {
+			t.Error("expected the layer to be read with its header dictionary")
+		}
+		read.HeaderDictionary = false
+		if !reflect.DeepEqual(read, plain) {
+			t.Errorf("layer read back differently: %v, expected %v", read, plain)
+		}
+	}
+}
+
+func TestHeaderDictionaryRequiresVersion(t *testing.T) {
+	layer := dictionaryLayer(WithHeaderDictionary())
+	h := NewHeader(binary.LittleEndian, OffsetSize4)
+	h.Version = VersionExtensions - 1
+	if err := layer.WriteHeader(buffer.NewBuffer(10), h); err == nil {
+		t.Errorf("expected error writing a header dictionary in version %d", h.Version)
+	}
+}
+
+func TestHeaderDictionaryCorrupt(t *testing.T) {
+	h := NewHeader(binary.BigEndian, OffsetSize4)
+	layer := NewLayer("a", DimensionSet{{Name: "x", Size: 2, TileSize: 2}}, ChannelSet{{Name: "b", Type: ChannelUint8}}, WithHeaderDictionary())
+	buf := buffer.NewBuffer(10)
+	if err := layer.WriteHeader(buf, h); err != nil {
+		t.Fatal(err)
+	}
+	table := layer.stringTable()
+	nameIndex := 8 + table.DiskSize(h)
+
+	corrupt := append([]byte{}, buf.Bytes()...)
+	binary.BigEndian.PutUint32(corrupt[nameIndex:], 3)
+	var format ErrFormat
+	if err := (&Layer{}).ReadLayer(buffer.NewBufferFrom(corrupt), h); !errors.As(err, &format) {
+		t.Errorf("expected ErrFormat for a string index outside of the table, got %v", err)
+	}
+
+	corrupt = append([]byte{}, buf.Bytes()...)
+	binary.BigEndian.PutUint32(corrupt[8+4:], 3)
+	if err := (&Layer{}).ReadLayer(buffer.NewBufferFrom(corrupt), h); !errors.As(err, &format) {
+		t.Errorf("expected ErrFormat for a string sharing more bytes than its predecessor has, got %v", err)
+	}
+}
+
+func TestSharedPrefix(t *testing.T) {
+	cases := []struct {
+		a, b string
+		n    int
+	}{
+		{"", "abc", 0},
+		{"abc", "abd", 2},
+		{"abc", "abc", 3},
+		{"abcd", "ab", 2},
+		{"caf\u00e9", "caf\u00e8", 3},    // the runes share their first byte
+		{"cafe", "cafe\u0301s", 3},       // the combining accent belongs with the e
+		{"cafe\u0301", "cafe\u0301s", 6}, // whole combining sequences may be shared
+		{"elevation", "elevation_max", 9},
+	}
+	for _, tc := range cases {
+		if n := sharedPrefix(tc.a, tc.b); n != tc.n {
+			t.Errorf("sharedPrefix(%q, %q) = %d, expected %d", tc.a, tc.b, n, tc.n)
+		}
+	}
+}
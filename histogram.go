@@ -0,0 +1,151 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Computes a histogram of the valid values of the named channel of the layer (neither NaN nor its fill value)
+// with the given number of bins, up to MaxSummaryBins, over the range of the channel statistics or else of its
+// values. Unwritten tiles are left out. Histograms of string channels are unsupported.
+func (l Layer) Histogram(r io.ReadSeeker, h Header, channel string, bins int) (*SummaryHistogram, error) {
+	c := l.Channels.Index(channel)
+	if c < 0 {
+		return nil, ErrChannelNotFound{ChannelName: channel}
+	}
+	if l.Channels[c].Type.Base() == ChannelString {
+		return nil, ErrUnsupported("histograms of string channels")
+	}
+	if bins < 1 || bins > MaxSummaryBins {
+		return nil, ErrFormat(fmt.Sprintf("%d histogram bins requested, expected from 1 to %d", bins, MaxSummaryBins))
+	}
+
+	// unwritten tiles read as fill values, which are missing, or fail and are skipped without them
+	access, err := NewAbsentFillLayer(NewFifoCacheReadLayer(r, h, l, 1))
+	if err != nil {
+		return nil, err
+	}
+	region := FullRegion(l.Dimensions)
+	channels := []int{c}
+	lo, hi, ok := l.Channels[c].statisticsRange()
+	if !ok {
+		var stats ZoneStatistics
+		err := forEachRegionValue(access, channels, region, func(_ int, value float64, missing bool) {
+			if !missing {
+				stats.add(value)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		lo, hi = stats.Min, stats.Max
+	}
+	histogram := newSummaryHistogram(lo, hi, bins)
+	err = forEachRegionValue(access, channels, region, func(_ int, value float64, missing bool) {
+		if !missing {
+			histogram.add(value)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return histogram, nil
+}
+
+// Computes the histogram of the named channel of the layer with the given index, as Layer.Histogram does, and
+// stores it in the channel description by rewriting the layer header. Requires VersionExtensions.
+func (p *Pixi) StoreHistogram(rw io.ReadWriteSeeker, layerIndex int, channel string, bins int) (*SummaryHistogram, error) {
+	if p.ReadOnly {
+		return nil, ErrReadOnly{Operation: "store histogram"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return nil, ErrFormat("layer index out of range")
+	}
+	if p.Header.Version < VersionExtensions {
+		return nil, ErrFormat(fmt.Sprintf("channel histograms require version %d or later", VersionExtensions))
+	}
+	layer := p.Layers[layerIndex]
+	histogram, err := layer.Histogram(rw, p.Header, channel, bins)
+	if err != nil {
+		return nil, err
+	}
+	layer.Channels = slices.Clone(layer.Channels)
+	layer.Channels[layer.Channels.Index(channel)].Histogram = histogram
+	if err := p.UpdateLayerHeader(rw, layerIndex, layer); err != nil {
+		return nil, err
+	}
+	return histogram, nil
+}
+
+// The value below which the given percent of the values counted by the histogram fall, interpolated linearly
+// within its bin. Returns false if the histogram counts no values or the percent is outside of 0 to 100.
+func (h *SummaryHistogram) Percentile(percent float64) (float64, bool) {
+	if h == nil || !(percent >= 0 && percent <= 100) {
+		return 0, false
+	}
+	total := h.Below + h.Above
+	for _, n := range h.Counts {
+		total += n
+	}
+	if total == 0 {
+		return 0, false
+	}
+	rank := percent / 100 * float64(total)
+	seen := float64(h.Below)
+	if h.Below > 0 && rank <= seen {
+		return h.Min, true
+	}
+	width := (h.Max - h.Min) / float64(len(h.Counts))
+	for i, n := range h.Counts {
+		if n > 0 && rank <= seen+float64(n) {
+			return h.Min + (float64(i)+(rank-seen)/float64(n))*width, true
+		}
+		seen += float64(n)
+	}
+	return h.Max, true
+}
+
+// Writes the histogram as it is stored in a channel description: its number of bins, range, counts of values
+// outside of the range, and the count of each bin.
+func (h *SummaryHistogram) write(w io.Writer, header Header) error {
+	err := header.Write(w, uint32(len(h.Counts)))
+	if err != nil {
+		return err
+	}
+	err = header.Write(w, [2]float64{h.Min, h.Max})
+	if err != nil {
+		return err
+	}
+	err = header.Write(w, [2]int64{h.Below, h.Above})
+	if err != nil {
+		return err
+	}
+	return header.Write(w, h.Counts)
+}
+
+// Reads the histogram as it is stored in a channel description, rejecting histograms of more than
+// MaxSummaryBins bins.
+func (h *SummaryHistogram) read(r io.Reader, header Header) error {
+	var bins uint32
+	err := header.Read(r, &bins)
+	if err != nil {
+		return err
+	}
+	if bins == 0 || bins > uint32(MaxSummaryBins) {
+		return ErrFormat(fmt.Sprintf("channel histogram of %d bins, expected from 1 to %d", bins, MaxSummaryBins))
+	}
+	var bounds [2]float64
+	err = header.Read(r, &bounds)
+	if err != nil {
+		return err
+	}
+	var outside [2]int64
+	err = header.Read(r, &outside)
+	if err != nil {
+		return err
+	}
+	h.Min, h.Max, h.Below, h.Above = bounds[0], bounds[1], outside[0], outside[1]
+	h.Counts = make([]int64, bins)
+	return header.Read(r, h.Counts)
+}
@@ -0,0 +1,198 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestLayerHistogram(t *testing.T) {
+	// the products x*y of the data layer range from 0 to 18, in bins of width 3
+	expected := &SummaryHistogram{Min: 0, Max: 18, Counts: make([]int64, 6)}
+	for x := range 7 {
+		for y := range 4 {
+			expected.Counts[min(x*y/3, 5)]++
+		}
+	}
+	for name, opts := range map[string][]LayerOption{"contiguous": {WithCompression(CompressionFlate)}, "separated": {WithPlanar()}} {
+		buf, pixi := newEditTestPixi(t, opts...)
+		layer := pixi.Layers[0]
+		histogram, err := layer.Histogram(buf, pixi.Header, "a", 6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(histogram, expected) {
+			t.Errorf("%s: expected %+v, got %+v", name, expected, histogram)
+		}
+
+		// without statistics, the range is found from the values
+		layer.Channels = layer.Channels.withoutDistributions()
+		layer.Channels[0].Min, layer.Channels[0].Max = nil, nil
+		if histogram, err := layer.Histogram(buf, pixi.Header, "a", 6); err != nil || !reflect.DeepEqual(histogram, expected) {
+			t.Errorf("%s: expected the range of the values, got %+v (%v)", name, histogram, err)
+		}
+	}
+
+	// NaN and fill values are left out
+	values := []float32{-1, 2, float32(math.NaN()), 4, -1, 6}
+	layers := []Layer{NewLayer("series", DimensionSet{{Name: "t", Size: 6, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelFloat32, FillValue: float32(-1)}})}
+	buf := buffer.NewBuffer(10)
+	pixi := writeTestPixi(t, buf, NewHeader(binary.BigEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{values[coord[0]]}
+	})
+	histogram, err := pixi.Layers[0].Histogram(buf, pixi.Header, "v", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(histogram.Counts, []int64{1, 2}) || histogram.Below != 0 || histogram.Above != 0 {
+		t.Errorf("expected only the valid values to be counted, got %+v", histogram)
+	}
+
+	// tiles that were never written hold only fill values, or missing samples without them
+	for _, fill := range []any{float32(-1), nil} {
+		sparse := []Layer{NewLayer("sparse", DimensionSet{{Name: "t", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelFloat32, FillValue: fill}}, WithSparseTiles())}
+		buf := buffer.NewBuffer(10)
+		pixi := writeTestPixi(t, buf, NewHeader(binary.BigEndian, OffsetSize4), nil, sparse, func(layer int, coord SampleCoordinate) Sample {
+			if coord[0] < 4 {
+				return Sample{float32(coord[0])}
+			}
+			return Sample{float32(-1)}
+		})
+		layer := pixi.Layers[0]
+		layer.Channels = layer.Channels.withoutDistributions()
+		layer.Channels[0].Min, layer.Channels[0].Max = nil, nil
+		if fill == nil {
+			layer.TileBytes = []int64{layer.TileBytes[0], 0}
+		} else if layer.TileBytes[1] != 0 {
+			t.Fatalf("expected the fill tile to be absent, got tile bytes %v", layer.TileBytes)
+		}
+		histogram, err := layer.Histogram(buf, pixi.Header, "v", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(histogram.Counts, []int64{2, 2}) || histogram.Below != 0 || histogram.Above != 0 {
+			t.Errorf("fill %v: expected only the written values to be counted, got %+v", fill, histogram)
+		}
+	}
+
+	if _, err := pixi.Layers[0].Histogram(buf, pixi.Header, "missing", 2); !errors.As(err, new(ErrChannelNotFound)) {
+		t.Errorf("expected an unknown channel to be reported, got %v", err)
+	}
+	if _, err := pixi.Layers[0].Histogram(buf, pixi.Header, "v", 0); err == nil {
+		t.Error("expected zero bins to be rejected")
+	}
+	file := newStringTestFile(t)
+	if _, err := file.Layers[0].Histogram(file.Stream(), file.Header, file.Layers[0].Channels[0].Name, 2); !errors.As(err, new(ErrUnsupported)) {
+		t.Errorf("expected histograms of string channels to be unsupported, got %v", err)
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	histogram := &SummaryHistogram{Min: 0, Max: 10, Counts: []int64{5, 0, 5}}
+	for percent, want := range map[float64]float64{0: 0, 20: 4.0 / 3, 50: 10.0 / 3, 75: 25.0 / 3, 100: 10} {
+		if value, ok := histogram.Percentile(percent); !ok || math.Abs(value-want) > 1e-9 {
+			t.Errorf("expected percentile %v to be %v, got %v", percent, want, value)
+		}
+	}
+	if _, ok := histogram.Percentile(101); ok {
+		t.Error("expected a percent above 100 to be rejected")
+	}
+
+	// values outside of the range are at its bounds
+	histogram.Below, histogram.Above = 2, 2
+	if lo, _ := histogram.Percentile(10); lo != 0 {
+		t.Errorf("expected the values below the range at its minimum, got %v", lo)
+	}
+	if hi, _ := histogram.Percentile(90); hi != 10 {
+		t.Errorf("expected the values above the range at its maximum, got %v", hi)
+	}
+
+	if _, ok := (&SummaryHistogram{Counts: make([]int64, 4)}).Percentile(50); ok {
+		t.Error("expected an empty histogram to have no percentiles")
+	}
+}
+
+func TestStoreHistogram(t *testing.T) {
+	buf, pixi := newEditTestPixi(t)
+	histogram, err := pixi.StoreHistogram(buf, 1, "b", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(histogram.Counts, []int64{1, 1, 1}) {
+		t.Errorf("expected one value in each bin, got %+v", histogram)
+	}
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := read.Layers[1].Channels[0].Histogram; !reflect.DeepEqual(stored, histogram) {
+		t.Errorf("expected the stored histogram %+v, got %+v", histogram, stored)
+	}
+	if stats, ok := read.Layers[1].Channels[0].Stats(); !ok || stats.ValidCount != 3 {
+		t.Errorf("expected the moments to be kept, got %+v", stats)
+	}
+
+	// changed tiles clear the histogram
+	if err := pixi.UpdateTiles(buf, 1, map[int][]byte{0: {5, 6, 7}}); err != nil {
+		t.Fatal(err)
+	}
+	if read, err = ReadPixi(buffer.NewBufferFrom(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if read.Layers[1].Channels[0].Histogram != nil || pixi.Layers[1].Channels[0].Histogram != nil {
+		t.Error("expected updating tiles to clear the histogram")
+	}
+
+	if _, err := pixi.StoreHistogram(buf, 2, "b", 3); err == nil {
+		t.Error("expected an out of range layer index to fail")
+	}
+	pixi.Header.Version = VersionExtensions - 1
+	if _, err := pixi.StoreHistogram(buf, 1, "b", 3); !errors.As(err, new(ErrFormat)) {
+		t.Errorf("expected storing a histogram in an earlier version to fail, got %v", err)
+	}
+	pixi.ReadOnly = true
+	if _, err := pixi.StoreHistogram(buf, 1, "b", 3); !errors.As(err, new(ErrReadOnly)) {
+		t.Errorf("expected storing a histogram in a read-only file to fail, got %v", err)
+	}
+}
+
+func TestChannelHistogramHeader(t *testing.T) {
+	channel := Channel{Name: "v", Type: ChannelUint8, Min: uint8(0), Max: uint8(200),
+		Histogram: &SummaryHistogram{Min: 0, Max: 200, Counts: []int64{4, 0, 9}, Below: 1, Above: 2}}
+	for _, version := range []int{Version, VersionExtensions - 1} {
+		header := NewHeader(binary.BigEndian, OffsetSize8)
+		header.Version = version
+		buf := buffer.NewBuffer(10)
+		if err := channel.Write(buf, header); err != nil {
+			t.Fatal(err)
+		}
+		if len(buf.Bytes()) != channel.HeaderSize(header) {
+			t.Errorf("version %d: expected %d header bytes, wrote %d", version, channel.HeaderSize(header), len(buf.Bytes()))
+		}
+		var read Channel
+		if err := read.Read(buffer.NewBufferFrom(buf.Bytes()), header); err != nil {
+			t.Fatal(err)
+		}
+		expected := channel
+		if version < VersionExtensions {
+			expected.Histogram = nil
+		}
+		if !reflect.DeepEqual(read, expected) {
+			t.Errorf("version %d: expected %+v, got %+v", version, expected, read)
+		}
+	}
+
+	// implausibly many bins are rejected
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.BigEndian, OffsetSize8)
+	if err := header.Write(buf, uint32(MaxSummaryBins+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(SummaryHistogram).read(buffer.NewBufferFrom(buf.Bytes()), header); !errors.As(err, new(ErrFormat)) {
+		t.Errorf("expected too many bins to be rejected, got %v", err)
+	}
+}
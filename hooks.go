@@ -0,0 +1,62 @@
+package gopixi
+
+// Callbacks run as the tiles and layers of a file are written. Either callback may be nil. An error returned
+// by a callback stops the write that called it and is returned from it.
+type WriteHooks struct {
+	// Called after each tile of a layer is written with the index the layer has (or will have) in the file,
+	// the layer being written, the disk tile index, and the uncompressed tile data, which is only valid for
+	// the duration of the call. Fill tiles of sparse layers are passed even though they are not stored. The
+	// statistics and tile offsets of the layer are not complete until it is finalized. Tiles are passed one
+	// at a time, but possibly from a goroutine other than the one that started the write.
+	OnTileWritten func(layerIndex int, layer Layer, tileIndex int, data []byte) error
+	// Called once the header of a layer has been written and linked into the file, when the layer is added
+	// and again when its tiles are replaced with UpdateTiles or RewriteTiles, with the layer as it is now
+	// recorded in the file. Not called for the provisional headers written by Checkpoint. Layers written by a
+	// StreamWriter or DeferredWriter (whose hooks are those of its Pixi) are finalized once their last tile
+	// is written, as their headers are only written with the footer index.
+	OnLayerFinalized func(layerIndex int, layer Layer) error
+}
+
+type writeHooksOption struct {
+	hooks WriteHooks
+}
+
+func (o writeHooksOption) applyCreate(opts *createOptions) {
+	opts.writeHooks = &o.hooks
+}
+
+// Runs the given hooks as tiles and layers are written to the file, as described for WriteHooks.
+func WithWriteHooks(hooks WriteHooks) CreateOption {
+	return writeHooksOption{hooks: hooks}
+}
+
+// An encoder for the tiles of the layer with the given index that runs the write hooks of the file.
+func (p *Pixi) newTileEncoder(layerIndex int) *tileEncoder {
+	return &tileEncoder{hooks: p.WriteHooks, layerIndex: layerIndex}
+}
+
+// Runs the OnTileWritten hook of the encoder, if any, for a tile just written.
+func (enc *tileEncoder) tileWritten(layer Layer, tileIndex int, data []byte) error {
+	if enc.hooks == nil || enc.hooks.OnTileWritten == nil {
+		return nil
+	}
+	return enc.hooks.OnTileWritten(enc.layerIndex, layer, tileIndex, data)
+}
+
+// Runs the OnLayerFinalized hook of the file, if any, for the layer with the given index.
+func (p *Pixi) layerFinalized(layerIndex int) error {
+	if p.WriteHooks == nil || p.WriteHooks.OnLayerFinalized == nil {
+		return nil
+	}
+	return p.WriteHooks.OnLayerFinalized(layerIndex, p.Layers[layerIndex])
+}
+
+// Implemented by layer writers that encode their tiles with a tileEncoder, so that the write hooks of the
+// file a layer is appended to can be run for them.
+type hookedLayerWriter interface {
+	setWriteHooks(hooks *WriteHooks, layerIndex int)
+}
+
+func (t *TileOrderWriteIterator) setWriteHooks(hooks *WriteHooks, layerIndex int) {
+	t.encoder.hooks, t.encoder.layerIndex = hooks, layerIndex
+}
@@ -0,0 +1,203 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+// Records the calls made to a set of write hooks.
+type hookRecorder struct {
+	tiles     map[[2]int]uint32 // checksum of the data of each written tile, by layer and tile index
+	finalized []int
+	layers    []Layer
+}
+
+func (h *hookRecorder) hooks() WriteHooks {
+	h.tiles = map[[2]int]uint32{}
+	return WriteHooks{
+		OnTileWritten: func(layerIndex int, layer Layer, tileIndex int, data []byte) error {
+			h.tiles[[2]int{layerIndex, tileIndex}] = crc32.ChecksumIEEE(data)
+			return nil
+		},
+		OnLayerFinalized: func(layerIndex int, layer Layer) error {
+			h.finalized = append(h.finalized, layerIndex)
+			h.layers = append(h.layers, layer)
+			return nil
+		},
+	}
+}
+
+// Checks that the recorded checksum of every tile of the layer matches the data stored in the file.
+func (h *hookRecorder) check(t *testing.T, r io.ReadSeeker, header Header, layerIndex int, layer Layer) {
+	t.Helper()
+	for tile := range layer.DiskTiles() {
+		data := make([]byte, layer.DiskTileSize(tile))
+		if err := layer.ReadTile(r, header, tile, data); err != nil {
+			t.Fatal(err)
+		}
+		if sum, ok := h.tiles[[2]int{layerIndex, tile}]; !ok || sum != crc32.ChecksumIEEE(data) {
+			t.Errorf("layer %d tile %d: expected the hook to see the stored data", layerIndex, tile)
+		}
+	}
+}
+
+func TestWriteHooks(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	recorder := &hookRecorder{}
+	pixi, err := Create(buf, NewHeader(binary.LittleEndian, OffsetSize4), WithWriteHooks(recorder.hooks()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("layer", DimensionSet{{Name: "x", Size: 8, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}},
+		ChannelSet{{Name: "a", Type: ChannelUint16}, {Name: "b", Type: ChannelBool}}, WithPlanar(), WithCompression(CompressionFlate))
+	writer := NewTileOrderWriteIterator(buf, pixi.Header, layer)
+	err = pixi.AppendIterativeLayer(buf, layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			coord := writer.Coordinate()
+			if coord[0] == 4 && coord[1] == 0 {
+				// provisional headers do not finalize the layer
+				if err := pixi.Checkpoint(buf, writer); err != nil {
+					return err
+				}
+			}
+			writer.SetSample(Sample{uint16(coord[0] * coord[1]), coord[0] > 3})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.tiles) != pixi.Layers[0].DiskTiles() {
+		t.Fatalf("expected a hook call for each of %d tiles, got %d", pixi.Layers[0].DiskTiles(), len(recorder.tiles))
+	}
+	recorder.check(t, buf, pixi.Header, 0, pixi.Layers[0])
+	if !slices.Equal(recorder.finalized, []int{0}) || !slices.Equal(recorder.layers[0].TileOffsets, pixi.Layers[0].TileOffsets) {
+		t.Fatalf("expected the layer to be finalized once with its final header, got %v", recorder.finalized)
+	}
+
+	// tiles of other layers are passed with their own layer index
+	raw := bytes.Repeat([]byte{1, 2, 3, 4}, 6)
+	rawLayer := NewLayer("raw", DimensionSet{{Name: "x", Size: 6, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelFloat32}})
+	if err := pixi.AppendRawLayer(buf, rawLayer, bytes.NewReader(raw), RawArray{Dimensions: DimensionSet{{Name: "x", Size: 6}}, Channels: rawLayer.Channels, ByteOrder: binary.LittleEndian}); err != nil {
+		t.Fatal(err)
+	}
+	recorder.check(t, buf, pixi.Header, 1, pixi.Layers[1])
+	if !slices.Equal(recorder.finalized, []int{0, 1}) {
+		t.Fatalf("expected the raw layer to be finalized, got %v", recorder.finalized)
+	}
+
+	// replacing tiles runs the hooks again
+	replacement := make([]byte, pixi.Layers[0].DiskTileSize(2))
+	replacement[0] = 0xff
+	if err := pixi.UpdateTiles(buf, 0, map[int][]byte{2: replacement}); err != nil {
+		t.Fatal(err)
+	}
+	recorder.check(t, buf, pixi.Header, 0, pixi.Layers[0])
+	if !slices.Equal(recorder.finalized, []int{0, 1, 0}) || recorder.layers[2].TileOffsets[2] != pixi.Layers[0].TileOffsets[2] {
+		t.Fatalf("expected the updated layer to be finalized, got %v", recorder.finalized)
+	}
+}
+
+func TestWriteHooksError(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	failure := errors.New("derived product failed")
+	finalized := false
+	pixi, err := Create(buf, NewHeader(binary.BigEndian, OffsetSize8), WithWriteHooks(WriteHooks{
+		OnTileWritten: func(layerIndex int, layer Layer, tileIndex int, data []byte) error {
+			if tileIndex == 1 {
+				return failure
+			}
+			return nil
+		},
+		OnLayerFinalized: func(layerIndex int, layer Layer) error {
+			finalized = true
+			return nil
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("layer", DimensionSet{{Name: "x", Size: 6, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	err = pixi.AppendIterativeLayer(buf, layer, NewTileOrderWriteIterator(buf, pixi.Header, layer), func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{uint8(writer.Coordinate()[0])})
+		}
+		return nil
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the hook error, got %v", err)
+	}
+	if finalized || len(pixi.Layers) != 0 {
+		t.Errorf("expected the failed layer not to be finalized or added")
+	}
+}
+
+// Appends two layers of five tiles in all with the given function, recording the hooks run for them.
+func appendHookedLayers(t *testing.T, p *Pixi, appendLayer func(layer Layer, generator func(writer IterativeLayerWriter) error) error) *hookRecorder {
+	t.Helper()
+	recorder := &hookRecorder{}
+	hooks := recorder.hooks()
+	p.WriteHooks = &hooks
+	for _, size := range []int{5, 3} {
+		layer := NewLayer("layer", DimensionSet{{Name: "x", Size: size, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelInt32}})
+		err := appendLayer(layer, func(writer IterativeLayerWriter) error {
+			for writer.Next() {
+				writer.SetSample(Sample{int32(writer.Coordinate()[0] - 2)})
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return recorder
+}
+
+// Checks that the hooks were run for every tile and layer of the file written by appendHookedLayers.
+func checkHookedLayers(t *testing.T, recorder *hookRecorder, contents []byte) {
+	t.Helper()
+	file := buffer.NewBufferFrom(contents)
+	read, err := ReadPixi(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for layerIndex, layer := range read.Layers {
+		recorder.check(t, file, read.Header, layerIndex, layer)
+	}
+	if !slices.Equal(recorder.finalized, []int{0, 1}) || len(recorder.tiles) != 5 {
+		t.Errorf("expected both layers and all five tiles to be seen, got %v and %d tiles", recorder.finalized, len(recorder.tiles))
+	}
+}
+
+func TestStreamWriterHooks(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewStreamWriter(&out, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := appendHookedLayers(t, writer.Pixi(), writer.AppendLayer)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkHookedLayers(t, recorder, out.Bytes())
+}
+
+func TestDeferredWriterHooks(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	writer, err := NewDeferredWriter(buf, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := appendHookedLayers(t, writer.Pixi(), writer.AppendLayer)
+	if err := writer.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+	checkHookedLayers(t, recorder, buf.Bytes())
+}
@@ -2,34 +2,119 @@ package gopixi
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
+type httpOptions struct {
+	credentials    Credentials
+	partSize       int
+	maxConcurrency int
+	multiRange     bool
+	s3Endpoint     string
+	throttle       *Throttle
+}
+
+type HttpOption interface {
+	applyHttp(*httpOptions)
+}
+
+type credentialsOption struct {
+	credentials Credentials
+}
+
+func (o credentialsOption) applyHttp(opts *httpOptions) {
+	opts.credentials = o.credentials
+}
+
+// Authenticates every request made to the remote resource using the given credentials provider.
+func WithCredentials(c Credentials) HttpOption {
+	return credentialsOption{credentials: c}
+}
+
+// The number of ranged requests HttpReadSeeker.ReadRanges issues at once unless another limit is given
+// with WithMaxConcurrentRequests.
+const DefaultMaxConcurrentRequests int = 8
+
+type maxConcurrencyOption struct {
+	maxConcurrency int
+}
+
+func (o maxConcurrencyOption) applyHttp(opts *httpOptions) {
+	opts.maxConcurrency = o.maxConcurrency
+}
+
+// Limits the number of ranged requests issued concurrently when fetching several byte ranges at once.
+func WithMaxConcurrentRequests(n int) HttpOption {
+	return maxConcurrencyOption{maxConcurrency: n}
+}
+
+type multiRangeOption struct{}
+
+func (o multiRangeOption) applyHttp(opts *httpOptions) {
+	opts.multiRange = true
+}
+
+// Fetches several byte ranges at once with a single request for all of them, for servers that respond
+// with multipart/byteranges. Any ranges missing from the response are fetched individually.
+func WithMultiRangeRequests() HttpOption {
+	return multiRangeOption{}
+}
+
 type HttpReadSeeker struct {
-	url    *url.URL
-	client *http.Client
-	ctx    context.Context
-	header http.Header
-	size   int64
-	offset int64
+	url            *url.URL
+	client         *http.Client
+	ctx            context.Context
+	header         http.Header
+	credentials    Credentials
+	maxConcurrency int
+	multiRange     bool
+	throttle       *Throttle
+	size           int64
+	offset         int64
 }
 
-func OpenHttp(url *url.URL, client *http.Client) (*HttpReadSeeker, error) {
+var _ RangeReader = (*HttpReadSeeker)(nil)
+var _ RemoteStream = (*HttpReadSeeker)(nil)
+
+func OpenHttp(url *url.URL, client *http.Client, opts ...HttpOption) (*HttpReadSeeker, error) {
 	if client == nil {
 		client = http.DefaultClient
 	}
+	options := httpOptions{}
+	for _, o := range opts {
+		o.applyHttp(&options)
+	}
 
 	// determine whether the resource is rangeable
-	resp, err := client.Head(url.String())
+	req, err := http.NewRequest("HEAD", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if options.credentials != nil {
+		if err := options.credentials.Authorize(req); err != nil {
+			return nil, err
+		}
+	}
+	if err := options.throttle.Wait(context.Background(), 0); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return nil, fmt.Errorf("unsuccessful http request: response code %d", resp.StatusCode)
@@ -39,32 +124,67 @@ func OpenHttp(url *url.URL, client *http.Client) (*HttpReadSeeker, error) {
 		return nil, fmt.Errorf("the resource does not support byte range requests")
 	}
 
+	if options.maxConcurrency <= 0 {
+		options.maxConcurrency = DefaultMaxConcurrentRequests
+	}
 	return &HttpReadSeeker{
-		url:    url,
-		client: client,
-		size:   resp.ContentLength,
+		url:            url,
+		client:         client,
+		credentials:    options.credentials,
+		maxConcurrency: options.maxConcurrency,
+		multiRange:     options.multiRange,
+		throttle:       options.throttle,
+		size:           resp.ContentLength,
 	}, nil
 }
 
+// The latency assumed for each read of the resource, for TileCache.
+func (h *HttpReadSeeker) FetchLatency() time.Duration {
+	return DefaultRemoteFetchLatency
+}
+
 func (h *HttpReadSeeker) WithContext(ctx context.Context) *HttpReadSeeker {
 	return &HttpReadSeeker{
-		url:    h.url,
-		client: h.client,
-		ctx:    ctx,
-		header: h.header,
-		size:   h.size,
-		offset: h.offset,
+		url:            h.url,
+		client:         h.client,
+		ctx:            ctx,
+		header:         h.header,
+		credentials:    h.credentials,
+		maxConcurrency: h.maxConcurrency,
+		multiRange:     h.multiRange,
+		throttle:       h.throttle,
+		size:           h.size,
+		offset:         h.offset,
 	}
 }
 
 func (h *HttpReadSeeker) WithHeader(header http.Header) *HttpReadSeeker {
 	return &HttpReadSeeker{
-		url:    h.url,
-		client: h.client,
-		ctx:    h.ctx,
-		header: header,
-		size:   h.size,
-		offset: h.offset,
+		url:            h.url,
+		client:         h.client,
+		ctx:            h.ctx,
+		header:         header,
+		credentials:    h.credentials,
+		maxConcurrency: h.maxConcurrency,
+		multiRange:     h.multiRange,
+		throttle:       h.throttle,
+		size:           h.size,
+		offset:         h.offset,
+	}
+}
+
+func (h *HttpReadSeeker) WithCredentials(credentials Credentials) *HttpReadSeeker {
+	return &HttpReadSeeker{
+		url:            h.url,
+		client:         h.client,
+		ctx:            h.ctx,
+		header:         h.header,
+		credentials:    credentials,
+		maxConcurrency: h.maxConcurrency,
+		multiRange:     h.multiRange,
+		throttle:       h.throttle,
+		size:           h.size,
+		offset:         h.offset,
 	}
 }
 
@@ -73,10 +193,45 @@ func (h *HttpReadSeeker) Read(p []byte) (n int, err error) {
 		return 0, io.EOF
 	}
 
-	req, err := http.NewRequest("GET", h.url.String(), nil)
+	// set the range header to read from the current offset
+	req, err := h.rangeRequest(fmt.Sprintf("bytes=%d-%d", h.offset, h.size-1))
+	if err != nil {
+		return 0, err
+	}
+	if err := h.throttle.Wait(req.Context(), min(int64(len(p)), h.size-h.offset)); err != nil {
+		return 0, err
+	}
+
+	resp, err := h.client.Do(req)
 	if err != nil {
 		return 0, err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("unexpected response code: %d", resp.StatusCode)
+	}
+
+	n, err = resp.Body.Read(p)
+	if n > 0 {
+		h.offset += int64(n)
+	}
+
+	// eof of current response body is not necessarily (or even usually) the end of the entire resource
+	if err != nil && errors.Is(err, io.EOF) && h.offset+int64(n) < h.size {
+		return n, nil
+	}
+	return n, err
+}
+
+// Creates a GET request for the given byte ranges of the resource, carrying the context, headers, and
+// credentials of the reader. The Range header is set before the request is authorized, so that
+// credentials signing the request headers (as S3Credentials do) cover it.
+func (h *HttpReadSeeker) rangeRequest(byteRange string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", h.url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
 
 	// copy some http request properties
 	if h.ctx != nil {
@@ -90,29 +245,176 @@ func (h *HttpReadSeeker) Read(p []byte) (n int, err error) {
 		}
 	}
 
-	// set the range header to read from the current offset
-	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", h.offset, h.size-1))
+	req.Header.Set("Range", byteRange)
+	if h.credentials != nil {
+		if err := h.credentials.Authorize(req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// Fetches several byte ranges of the resource concurrently without changing the current offset, using a
+// single multi-range request first if enabled (see WithMultiRangeRequests).
+func (h *HttpReadSeeker) ReadRanges(ranges []ByteRange) ([][]byte, error) {
+	result := make([][]byte, len(ranges))
+	remaining := make([]int, 0, len(ranges))
+	for i, r := range ranges {
+		if r.Offset < 0 || r.Length < 0 || r.End() > h.size {
+			return nil, fmt.Errorf("byte range out of bounds: %d+%d", r.Offset, r.Length)
+		}
+		if r.Length == 0 {
+			result[i] = []byte{}
+		} else {
+			remaining = append(remaining, i)
+		}
+	}
+
+	if h.multiRange && len(remaining) > 1 {
+		segments, err := h.fetchMultiRange(ranges, remaining)
+		if err != nil {
+			return nil, err
+		}
+		remaining = slices.DeleteFunc(remaining, func(i int) bool {
+			for _, segment := range segments {
+				if segment.Offset <= ranges[i].Offset && ranges[i].End() <= segment.End() {
+					start := ranges[i].Offset - segment.Offset
+					result[i] = segment.data[start : start+ranges[i].Length]
+					return true
+				}
+			}
+			return false
+		})
+	}
 
+	maxConcurrency := h.maxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrentRequests
+	}
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	var firstErr error
+	limit := make(chan struct{}, maxConcurrency)
+	for _, i := range remaining {
+		limit <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-limit }()
+			data, err := h.fetchRange(ranges[i])
+			if err != nil {
+				errLock.Lock()
+				firstErr = cmp.Or(firstErr, err)
+				errLock.Unlock()
+				return
+			}
+			result[i] = data
+		})
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+func (h *HttpReadSeeker) fetchRange(r ByteRange) ([]byte, error) {
+	req, err := h.rangeRequest(fmt.Sprintf("bytes=%d-%d", r.Offset, r.End()-1))
+	if err != nil {
+		return nil, err
+	}
+	if err := h.throttle.Wait(req.Context(), r.Length); err != nil {
+		return nil, err
+	}
 	resp, err := h.client.Do(req)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusPartialContent {
-		return 0, fmt.Errorf("unexpected response code: %d", resp.StatusCode)
+		return nil, fmt.Errorf("unexpected response code: %d", resp.StatusCode)
+	}
+	data := make([]byte, r.Length)
+	_, err = io.ReadFull(resp.Body, data)
+	if err != nil {
+		return nil, err
 	}
+	return data, nil
+}
 
-	n, err = resp.Body.Read(p)
-	if n > 0 {
-		h.offset += int64(n)
+// A contiguous span of the resource returned by the server in response to a multi-range request.
+type httpSegment struct {
+	ByteRange
+	data []byte
+}
+
+// Requests the selected ranges in a single request, returning whatever spans of the resource the
+// server sent back: one per part of a multipart/byteranges response, or a single span if the server
+// honored only one (possibly coalesced) range. If the server ignored the ranges entirely, no spans are
+// returned and the body is closed unread, so that the ranges are fetched individually rather than by
+// downloading the whole resource.
+func (h *HttpReadSeeker) fetchMultiRange(ranges []ByteRange, selected []int) ([]httpSegment, error) {
+	specs := make([]string, len(selected))
+	for i, index := range selected {
+		specs[i] = fmt.Sprintf("%d-%d", ranges[index].Offset, ranges[index].End()-1)
+	}
+	req, err := h.rangeRequest("bytes=" + strings.Join(specs, ","))
+	if err != nil {
+		return nil, err
+	}
+	total := int64(0)
+	for _, index := range selected {
+		total += ranges[index].Length
+	}
+	if err := h.throttle.Wait(req.Context(), total); err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// eof of current response body is not necessarily (or even usually) the end of the entire resource
-	if err != nil && errors.Is(err, io.EOF) && h.offset+int64(n) < h.size {
-		return n, nil
+	readSegment := func(body io.Reader, contentRange string) (httpSegment, error) {
+		var start, end int64
+		if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/", &start, &end); err != nil {
+			return httpSegment{}, fmt.Errorf("invalid content range '%s'", contentRange)
+		}
+		data := make([]byte, end-start+1)
+		_, err := io.ReadFull(body, data)
+		return httpSegment{ByteRange: ByteRange{Offset: start, Length: end - start + 1}, data: data}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil, nil
+	case http.StatusPartialContent:
+		mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil || mediaType != "multipart/byteranges" {
+			segment, err := readSegment(resp.Body, resp.Header.Get("Content-Range"))
+			if err != nil {
+				return nil, err
+			}
+			return []httpSegment{segment}, nil
+		}
+		parts := multipart.NewReader(resp.Body, params["boundary"])
+		segments := []httpSegment{}
+		for {
+			part, err := parts.NextPart()
+			if errors.Is(err, io.EOF) {
+				return segments, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			segment, err := readSegment(part, part.Header.Get("Content-Range"))
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected response code: %d", resp.StatusCode)
 	}
-	return n, err
 }
 
 func (h *HttpReadSeeker) Seek(offset int64, whence int) (int64, error) {
@@ -141,8 +443,8 @@ type BufferedHttpReadSeeker struct {
 	buffer *bufio.Reader
 }
 
-func OpenBufferedHttp(url *url.URL, client *http.Client) (*BufferedHttpReadSeeker, error) {
-	httpReader, err := OpenHttp(url, client)
+func OpenBufferedHttp(url *url.URL, client *http.Client, opts ...HttpOption) (*BufferedHttpReadSeeker, error) {
+	httpReader, err := OpenHttp(url, client, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +38,10 @@ type FromImageOptions struct {
 }
 
 func (p *Pixi) AppendImage(w io.WriteSeeker, img image.Image, options FromImageOptions) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append image"}
+	}
+
 	layer, err := ImageToLayer(img, "image", false, options.Compression, options.XTileSize, options.YTileSize)
 	if err != nil {
 		return err
@@ -49,46 +53,54 @@ func (p *Pixi) AppendImage(w io.WriteSeeker, img image.Image, options FromImageO
 	return p.AppendIterativeLayer(w, layer, iterator, func(writerIterator IterativeLayerWriter) error {
 		for writerIterator.Next() {
 			coord := writerIterator.Coordinate()
-			pixel := img.At(coord[0], coord[1])
-			switch img.ColorModel() {
-			case color.NRGBAModel:
-				col := pixel.(color.NRGBA)
-				writerIterator.SetSample([]any{col.R, col.G, col.B, col.A})
-			case color.NRGBA64Model:
-				col := pixel.(color.NRGBA64)
-				writerIterator.SetSample([]any{col.R, col.G, col.B, col.A})
-			case color.RGBAModel:
-				col := pixel.(color.RGBA)
-				writerIterator.SetSample([]any{col.R, col.G, col.B, col.A})
-			case color.RGBA64Model:
-				col := pixel.(color.RGBA64)
-				writerIterator.SetSample([]any{col.R, col.G, col.B, col.A})
-			case color.CMYKModel:
-				col := pixel.(color.CMYK)
-				writerIterator.SetSample([]any{col.C, col.M, col.Y, col.K})
-			case color.YCbCrModel:
-				col := pixel.(color.YCbCr)
-				writerIterator.SetSample([]any{col.Y, col.Cb, col.Cr})
-			case color.NYCbCrAModel:
-				col := pixel.(color.NYCbCrA)
-				writerIterator.SetSample([]any{col.Y, col.Cb, col.Cr, col.A})
-			case color.GrayModel:
-				col := pixel.(color.Gray)
-				writerIterator.SetSample([]any{col.Y})
-			case color.Gray16Model:
-				col := pixel.(color.Gray16)
-				writerIterator.SetSample([]any{col.Y})
-			case colorext.GrayS16Model:
-				col := pixel.(colorext.GrayS16)
-				writerIterator.SetSample([]any{col.Y})
-			default:
-				return fmt.Errorf("unsupported color model")
+			sample, err := imageSample(img.ColorModel(), img.At(coord[0], coord[1]))
+			if err != nil {
+				return err
 			}
+			writerIterator.SetSample(sample)
 		}
 		return nil
 	})
 }
 
+// The sample of the Pixi layer made by ImageToLayer for a pixel of an image with the given color model.
+func imageSample(model color.Model, pixel color.Color) (Sample, error) {
+	switch model {
+	case color.NRGBAModel:
+		col := pixel.(color.NRGBA)
+		return Sample{col.R, col.G, col.B, col.A}, nil
+	case color.NRGBA64Model:
+		col := pixel.(color.NRGBA64)
+		return Sample{col.R, col.G, col.B, col.A}, nil
+	case color.RGBAModel:
+		col := pixel.(color.RGBA)
+		return Sample{col.R, col.G, col.B, col.A}, nil
+	case color.RGBA64Model:
+		col := pixel.(color.RGBA64)
+		return Sample{col.R, col.G, col.B, col.A}, nil
+	case color.CMYKModel:
+		col := pixel.(color.CMYK)
+		return Sample{col.C, col.M, col.Y, col.K}, nil
+	case color.YCbCrModel:
+		col := pixel.(color.YCbCr)
+		return Sample{col.Y, col.Cb, col.Cr}, nil
+	case color.NYCbCrAModel:
+		col := pixel.(color.NYCbCrA)
+		return Sample{col.Y, col.Cb, col.Cr, col.A}, nil
+	case color.GrayModel:
+		col := pixel.(color.Gray)
+		return Sample{col.Y}, nil
+	case color.Gray16Model:
+		col := pixel.(color.Gray16)
+		return Sample{col.Y}, nil
+	case colorext.GrayS16Model:
+		col := pixel.(colorext.GrayS16)
+		return Sample{col.Y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported color model")
+	}
+}
+
 func ImageToLayer(img image.Image, layerName string, separated bool, compression Compression, xTileSize int, yTileSize int) (Layer, error) {
 	var channels ChannelSet
 	switch img.ColorModel() {
@@ -0,0 +1,180 @@
+package gopixi
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Describes how AppendImageSequence assembles a stack of image frames into a layer.
+type ImageSequenceOptions struct {
+	LayerName     string // The name of the layer, "frames" if empty.
+	Compression   Compression
+	Separated     bool
+	XTileSize     int // The tile size of the x dimension, the width of the frames if zero.
+	YTileSize     int // The tile size of the y dimension, the height of the frames if zero.
+	FrameTileSize int // The number of frames in each tile, one if zero. Each tile holds this many frames in memory.
+
+	// If set, parses the axis value of each frame from its file name (such as a timestamp, see
+	// TimestampFromName), and the frames are ordered by their values rather than their names. The values must
+	// be evenly spaced, and become a frame axis of FrameAxisType (float64 if unset) with FrameUnit.
+	FrameValue    func(name string) (float64, error)
+	FrameAxisType ChannelType
+	FrameUnit     string
+}
+
+// Appends a layer over the dimensions x, y, and frame to the end of the file, assembled from the image
+// frames in the file system whose paths match the pattern (as for fs.Glob), ordered by name unless the
+// options give values to order them by. Every frame must share the size and color model of the first.
+// Frames are decoded with image.Decode, so formats other than PNG, JPEG, and GIF must be registered.
+func (p *Pixi) AppendImageSequence(w io.WriteSeeker, fsys fs.FS, pattern string, options ImageSequenceOptions) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append image sequence"}
+	}
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return ErrFormat(fmt.Sprintf("no image frames match '%s'", pattern))
+	}
+	slices.Sort(names)
+	axis, err := frameAxis(names, options)
+	if err != nil {
+		return err
+	}
+
+	first, err := decodeFrame(fsys, names[0])
+	if err != nil {
+		return err
+	}
+	name := options.LayerName
+	if name == "" {
+		name = "frames"
+	}
+	planar, err := ImageToLayer(first, name, options.Separated, options.Compression, options.XTileSize, options.YTileSize)
+	if err != nil {
+		return err
+	}
+	frameTileSize := options.FrameTileSize
+	if frameTileSize <= 0 {
+		frameTileSize = 1
+	}
+	dims := append(planar.Dimensions, Dimension{Name: "frame", Size: len(names), TileSize: min(frameTileSize, len(names)), Axis: axis})
+	opts := []LayerOption{WithCompression(options.Compression)}
+	if options.Separated {
+		opts = append(opts, WithPlanar())
+	}
+	layer := NewLayer(name, dims, planar.Channels, opts...)
+
+	// the frames of the tile being written, by frame index
+	frames := map[int]image.Image{0: first}
+	frameTile := 0
+	iterator := NewTileOrderWriteIterator(w, p.Header, layer)
+	return p.AppendIterativeLayer(w, layer, iterator, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			coord := writer.Coordinate()
+			if coord[2] >= len(names) {
+				continue
+			}
+			if tile := coord[2] / layer.Dimensions[2].TileSize; tile != frameTile {
+				clear(frames)
+				frameTile = tile
+			}
+			frame, ok := frames[coord[2]]
+			if !ok {
+				if frame, err = decodeFrame(fsys, names[coord[2]]); err != nil {
+					return err
+				}
+				if frame.Bounds().Size() != first.Bounds().Size() || frame.ColorModel() != first.ColorModel() {
+					return ErrFormat(fmt.Sprintf("frame '%s' does not have the size and color model of frame '%s'", names[coord[2]], names[0]))
+				}
+				frames[coord[2]] = frame
+			}
+			origin := frame.Bounds().Min
+			sample, err := imageSample(frame.ColorModel(), frame.At(origin.X+coord[0], origin.Y+coord[1]))
+			if err != nil {
+				return err
+			}
+			writer.SetSample(sample)
+		}
+		return nil
+	})
+}
+
+func decodeFrame(fsys fs.FS, name string) (image.Image, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, ErrFormat(fmt.Sprintf("decoding frame '%s': %s", name, err))
+	}
+	return img, nil
+}
+
+// Orders the frame names by the values the options parse from them, returning the axis of the frame
+// dimension, or nil if the options do not parse frame values.
+func frameAxis(names []string, options ImageSequenceOptions) (*Axis, error) {
+	if options.FrameValue == nil {
+		return nil, nil
+	}
+	values := make(map[string]float64, len(names))
+	for _, name := range names {
+		value, err := options.FrameValue(name)
+		if err != nil {
+			return nil, ErrFormat(fmt.Sprintf("parsing the value of frame '%s': %s", name, err))
+		}
+		values[name] = value
+	}
+	slices.SortStableFunc(names, func(a, b string) int {
+		if values[a] < values[b] {
+			return -1
+		} else if values[a] > values[b] {
+			return 1
+		}
+		return 0
+	})
+
+	minimum, step := values[names[0]], 0.0
+	if len(names) > 1 {
+		step = values[names[1]] - minimum
+	}
+	for i, name := range names {
+		if expected := minimum + float64(i)*step; math.Abs(values[name]-expected) > 1e-9*max(math.Abs(step), 1) {
+			return nil, ErrFormat(fmt.Sprintf("frame '%s' has value %v, expected %v for evenly spaced frames", name, values[name], expected))
+		}
+	}
+	axisType := options.FrameAxisType.Base()
+	if axisType == ChannelUnknown {
+		axisType = ChannelFloat64
+	}
+	return &Axis{Type: axisType, Minimum: axisType.FromFloat64(minimum), Step: axisType.FromFloat64(step), Unit: options.FrameUnit}, nil
+}
+
+// Returns a function for ImageSequenceOptions.FrameValue parsing the end of each file name, once its
+// directory and extension are removed, as a time in the given layout (see time.Parse), giving the time in
+// seconds since the Unix epoch. For example, the layout "20060102T1504" parses frames named like
+// "radar_20240131T0930.png".
+func TimestampFromName(layout string) func(name string) (float64, error) {
+	return func(name string) (float64, error) {
+		base := path.Base(name)
+		base = strings.TrimSuffix(base, path.Ext(base))
+		if len(base) < len(layout) {
+			return 0, fmt.Errorf("name '%s' is too short for the layout '%s'", name, layout)
+		}
+		t, err := time.Parse(layout, base[len(base)-len(layout):])
+		if err != nil {
+			return 0, err
+		}
+		return float64(t.Unix()), nil
+	}
+}
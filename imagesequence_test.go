@@ -0,0 +1,104 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func imageSequenceFrame(t *testing.T, width int, height int, frame int) *fstest.MapFile {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetGray(x, y, color.Gray{Y: uint8(frame*50 + y*width + x)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return &fstest.MapFile{Data: buf.Bytes()}
+}
+
+func TestAppendImageSequence(t *testing.T) {
+	// named so that ordering by name differs from ordering by time
+	fsys := fstest.MapFS{
+		"radar/b_20240131T1000.png": imageSequenceFrame(t, 5, 3, 2),
+		"radar/c_20240131T0900.png": imageSequenceFrame(t, 5, 3, 0),
+		"radar/a_20240131T0930.png": imageSequenceFrame(t, 5, 3, 1),
+		"radar/notes.txt":           &fstest.MapFile{Data: []byte("not a frame")},
+	}
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = file.AppendImageSequence(file.Stream(), fsys, "radar/*.png", ImageSequenceOptions{
+		Compression:   CompressionFlate,
+		XTileSize:     4,
+		FrameTileSize: 2,
+		FrameValue:    TimestampFromName("20060102T1504"),
+		FrameAxisType: ChannelInt64,
+		FrameUnit:     "s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layer := file.Layers[0]
+	frame := layer.Dimensions[2]
+	start := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC).Unix()
+	if layer.Name != "frames" || layer.Dimensions[0].Size != 5 || layer.Dimensions[1].Size != 3 || frame.Name != "frame" || frame.Size != 3 || frame.TileSize != 2 {
+		t.Fatalf("unexpected layer %s with dimensions %v", layer.Name, layer.Dimensions)
+	}
+	if frame.Axis == nil || frame.Axis.Minimum != start || frame.Axis.Step != int64(1800) || frame.Axis.Unit != "s" {
+		t.Errorf("unexpected frame axis %+v", frame.Axis)
+	}
+	values, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for coord := range layer.Dimensions.SampleCoordinates() {
+		expected := uint8(coord[2]*50 + coord[1]*5 + coord[0])
+		if sample, err := SampleAt(values, coord); err != nil || sample[0] != expected {
+			t.Errorf("sample %v: got %v (%v), expected %d", coord, sample, err, expected)
+		}
+	}
+}
+
+func TestAppendImageSequenceInvalid(t *testing.T) {
+	cases := map[string]struct {
+		fsys    fstest.MapFS
+		options ImageSequenceOptions
+	}{
+		"no frames": {fstest.MapFS{}, ImageSequenceOptions{}},
+		"mismatched sizes": {fstest.MapFS{
+			"0.png": imageSequenceFrame(t, 4, 4, 0),
+			"1.png": imageSequenceFrame(t, 4, 3, 1),
+		}, ImageSequenceOptions{}},
+		"uneven frame values": {fstest.MapFS{
+			"f_0000.png": imageSequenceFrame(t, 2, 2, 0),
+			"f_0100.png": imageSequenceFrame(t, 2, 2, 1),
+			"f_0300.png": imageSequenceFrame(t, 2, 2, 2),
+		}, ImageSequenceOptions{FrameValue: TimestampFromName("1504")}},
+		"unparseable frame values": {fstest.MapFS{
+			"f_ab.png": imageSequenceFrame(t, 2, 2, 0),
+		}, ImageSequenceOptions{FrameValue: TimestampFromName("1504")}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := file.AppendImageSequence(file.Stream(), c.fsys, "*.png", c.options); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
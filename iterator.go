@@ -2,6 +2,7 @@ package gopixi
 
 import (
 	"io"
+	"slices"
 	"sync"
 
 	"github.com/gracefulearth/gopixi/internal/preload"
@@ -174,53 +175,115 @@ type TileOrderWriteIterator struct {
 	sampleInTile int
 
 	wg           sync.WaitGroup
+	pending      sync.WaitGroup // tiles queued but not yet written
 	writeLock    sync.RWMutex
 	writeQueue   chan map[int][]byte
 	currentError error
 
 	tiles map[int][]byte
+
+	// reused across tiles, and across layers when the iterator is Reset
+	encoder   tileEncoder
+	freeLock  sync.Mutex
+	freeTiles [][]byte
 }
 
-var _ IterativeLayerWriter = (*TileOrderWriteIterator)(nil)
+var _ FlushableLayerWriter = (*TileOrderWriteIterator)(nil)
 
-func NewTileOrderWriteIterator(backing io.WriteSeeker, header Header, layer Layer) *TileOrderWriteIterator {
-	iterator := &TileOrderWriteIterator{
-		backing: backing,
-		header:  header,
-		layer:   layer,
+// The maximum number of tile buffers kept for reuse by a write iterator.
+const maxFreeTiles = 128
 
-		sampleInTile: -1, // so first Next() goes to 0
+func NewTileOrderWriteIterator(backing io.WriteSeeker, header Header, layer Layer) *TileOrderWriteIterator {
+	iterator := &TileOrderWriteIterator{}
+	iterator.start(backing, header, layer)
+	return iterator
+}
 
-		writeQueue: make(chan map[int][]byte, 100),
+// Prepares the iterator to write another layer, possibly to a different stream, as though it had
+// just been created with NewTileOrderWriteIterator. The tile buffers and compressors used for the
+// previous layer are kept and reused, avoiding the cost of reallocating them in batch jobs that write
+// many small files. Must only be called once the previous layer is complete (after Done).
+func (t *TileOrderWriteIterator) Reset(backing io.WriteSeeker, header Header, layer Layer) {
+	t.start(backing, header, layer)
+}
 
-		tiles: make(map[int][]byte),
+func (t *TileOrderWriteIterator) start(backing io.WriteSeeker, header Header, layer Layer) {
+	t.backing = backing
+	t.header = header
+	t.layer = layer
+	for i := range layer.Channels {
+		layer.Channels[i].clearDistribution()
 	}
 
-	if layer.Separated {
-		for channelIndex := range layer.Channels {
-			tileSize := layer.DiskTileSize(layer.Dimensions.Tiles() * channelIndex)
-			iterator.tiles[channelIndex] = make([]byte, tileSize)
-		}
-	} else {
-		tileSize := layer.DiskTileSize(0)
-		iterator.tiles[nonSeparatedKey] = make([]byte, tileSize)
-	}
+	t.tile = 0
+	t.sampleInTile = -1 // so first Next() goes to 0
 
-	iterator.wg.Go(func() {
+	t.writeQueue = make(chan map[int][]byte, 100)
+	t.currentError = nil
+
+	t.tiles = t.allocTiles(0)
+
+	// the encoder only needs the channel types, so it works on a snapshot of the channels rather than
+	// racing with the Min/Max updates made as samples are set
+	writeLayer := layer
+	writeLayer.Channels = slices.Clone(layer.Channels)
+	t.wg.Go(func() {
 		tileIndex := 0
-		for tiles := range iterator.writeQueue {
-			err := iterator.writeTiles(tiles, tileIndex)
-			if err != nil {
-				iterator.writeLock.Lock()
-				iterator.currentError = err
-				iterator.writeLock.Unlock()
-				return
+		for tiles := range t.writeQueue {
+			// after a failure, remaining tiles are drained without being written so that Flush returns
+			if t.Error() == nil {
+				err := t.writeTiles(writeLayer, tiles, tileIndex)
+				if err != nil {
+					t.writeLock.Lock()
+					t.currentError = err
+					t.writeLock.Unlock()
+				}
 			}
+			t.releaseTiles(tiles)
 			tileIndex += 1
+			t.pending.Done()
 		}
 	})
+}
 
-	return iterator
+// Gets zeroed buffers for the tile (or tiles, if separated) at the given index, reusing released
+// buffers where possible.
+func (t *TileOrderWriteIterator) allocTiles(tile int) map[int][]byte {
+	tiles := make(map[int][]byte)
+	if t.layer.Separated {
+		for channelIndex := range t.layer.Channels {
+			tiles[channelIndex] = t.allocTile(t.layer.DiskTileSize(tile + t.layer.Dimensions.Tiles()*channelIndex))
+		}
+	} else {
+		tiles[nonSeparatedKey] = t.allocTile(t.layer.DiskTileSize(tile))
+	}
+	return tiles
+}
+
+func (t *TileOrderWriteIterator) allocTile(size int) []byte {
+	t.freeLock.Lock()
+	defer t.freeLock.Unlock()
+	for i, buf := range t.freeTiles {
+		if cap(buf) >= size {
+			last := len(t.freeTiles) - 1
+			t.freeTiles[i] = t.freeTiles[last]
+			t.freeTiles = t.freeTiles[:last]
+			buf = buf[:size]
+			clear(buf)
+			return buf
+		}
+	}
+	return make([]byte, size)
+}
+
+func (t *TileOrderWriteIterator) releaseTiles(tiles map[int][]byte) {
+	t.freeLock.Lock()
+	defer t.freeLock.Unlock()
+	for _, buf := range tiles {
+		if len(t.freeTiles) < maxFreeTiles {
+			t.freeTiles = append(t.freeTiles, buf)
+		}
+	}
 }
 
 func (t *TileOrderWriteIterator) Layer() Layer {
@@ -232,12 +295,30 @@ func (t *TileOrderWriteIterator) Done() {
 	t.wg.Wait()
 }
 
+// Blocks until every completed tile has been written to the backing stream, returning any error that
+// occurred while writing. The tile currently being filled is not written until it is complete. While no
+// tiles are pending the backing stream is idle, so it may be safely used (e.g., by Pixi.Checkpoint) as long
+// as its position is restored to where the next tile should be written before iteration resumes.
+func (t *TileOrderWriteIterator) Flush() error {
+	t.pending.Wait()
+	return t.Error()
+}
+
 func (t *TileOrderWriteIterator) Error() error {
 	t.writeLock.RLock()
 	defer t.writeLock.RUnlock()
 	return t.currentError
 }
 
+// Records an error in a sample set by the caller, stopping iteration as a write failure does.
+func (t *TileOrderWriteIterator) fail(err error) {
+	t.writeLock.Lock()
+	defer t.writeLock.Unlock()
+	if t.currentError == nil {
+		t.currentError = err
+	}
+}
+
 func (t *TileOrderWriteIterator) Next() bool {
 	if t.Error() != nil {
 		return false
@@ -249,23 +330,16 @@ func (t *TileOrderWriteIterator) Next() bool {
 		t.sampleInTile = 0
 		t.tile += 1
 
+		t.pending.Add(1)
 		t.writeQueue <- t.tiles
-		t.tiles = make(map[int][]byte)
+		t.tiles = nil
 
 		// check if we are done
 		if t.tile >= t.layer.Dimensions.Tiles() {
 			return false
 		} else {
 			// load the next tile (or tiles, if separated)
-			if t.layer.Separated {
-				for channelIndex := range t.layer.Channels {
-					tileSize := t.layer.DiskTileSize(t.tile + t.layer.Dimensions.Tiles()*channelIndex)
-					t.tiles[channelIndex] = make([]byte, tileSize)
-				}
-			} else {
-				tileSize := t.layer.DiskTileSize(t.tile)
-				t.tiles[nonSeparatedKey] = make([]byte, tileSize)
-			}
+			t.tiles = t.allocTiles(t.tile)
 		}
 	}
 
@@ -287,9 +361,20 @@ func (t *TileOrderWriteIterator) SetChannel(channelIndex int, value any) {
 	if t.Error() != nil {
 		return
 	}
+	if err := t.layer.Channels[channelIndex].CheckValue(value); err != nil {
+		t.fail(withCoordinate(err, t.Coordinate()))
+		return
+	}
+	if len(t.layer.NonFinite) > 0 {
+		var err error
+		if value, _, err = t.layer.applyNonFiniteValue(t.Coordinate(), channelIndex, value); err != nil {
+			t.fail(err)
+			return
+		}
+	}
 
 	// Update Min/Max for the channel
-	t.layer.Channels[channelIndex] = t.layer.Channels[channelIndex].WithMinMax(value)
+	t.layer.Channels[channelIndex] = t.layer.Channels[channelIndex].withStatistics(value)
 
 	if t.layer.Separated {
 		tileData := t.tiles[channelIndex]
@@ -297,24 +382,45 @@ func (t *TileOrderWriteIterator) SetChannel(channelIndex int, value any) {
 			PackBool(value.(bool), tileData, t.sampleInTile)
 		} else {
 			inTileOffset := t.sampleInTile * t.layer.Channels[channelIndex].Size()
-			t.layer.Channels[channelIndex].PutValue(value, t.header.ByteOrder, tileData[inTileOffset:])
+			t.putValue(channelIndex, channelIndex, inTileOffset, value)
 		}
 	} else {
-		tileData := t.tiles[nonSeparatedKey]
 		inTileOffset := t.sampleInTile * t.layer.Channels.Size()
 		channelOffset := t.layer.Channels.Offset(channelIndex)
-		t.layer.Channels[channelIndex].PutValue(value, t.header.ByteOrder, tileData[inTileOffset+channelOffset:])
+		t.putValue(nonSeparatedKey, channelIndex, inTileOffset+channelOffset, value)
 	}
 }
 
+// Writes the value of the channel at the given byte offset of the tile buffer with the given key, appending
+// strings to the string data block of the tile.
+func (t *TileOrderWriteIterator) putValue(key int, channelIndex int, offset int, value any) {
+	channel := t.layer.Channels[channelIndex]
+	if channel.Type == ChannelString {
+		t.tiles[key] = putString(t.tiles[key], offset, value.(string), t.header.ByteOrder)
+		return
+	}
+	channel.PutValue(value, t.header.ByteOrder, t.tiles[key][offset:])
+}
+
 func (t *TileOrderWriteIterator) SetSample(value Sample) {
 	if t.Error() != nil {
 		return
 	}
+	if err := t.layer.Channels.CheckSample(value); err != nil {
+		t.fail(withCoordinate(err, t.Coordinate()))
+		return
+	}
+	if len(t.layer.NonFinite) > 0 {
+		var err error
+		if value, err = t.layer.applyNonFinite(t.Coordinate(), value); err != nil {
+			t.fail(err)
+			return
+		}
+	}
 
 	// Update Min/Max for all channels in the sample
 	for channelIndex, channelValue := range value {
-		t.layer.Channels[channelIndex] = t.layer.Channels[channelIndex].WithMinMax(channelValue)
+		t.layer.Channels[channelIndex] = t.layer.Channels[channelIndex].withStatistics(channelValue)
 	}
 
 	if t.layer.Separated {
@@ -324,30 +430,29 @@ func (t *TileOrderWriteIterator) SetSample(value Sample) {
 				PackBool(value[channelIndex].(bool), tileData, t.sampleInTile)
 			} else {
 				inTileOffset := t.sampleInTile * channel.Size()
-				channel.PutValue(value[channelIndex], t.header.ByteOrder, tileData[inTileOffset:])
+				t.putValue(channelIndex, channelIndex, inTileOffset, value[channelIndex])
 			}
 		}
 	} else {
-		tileData := t.tiles[nonSeparatedKey]
 		inTileOffset := t.sampleInTile * t.layer.Channels.Size()
 		for channelIndex, channel := range t.layer.Channels {
-			channel.PutValue(value[channelIndex], t.header.ByteOrder, tileData[inTileOffset:])
+			t.putValue(nonSeparatedKey, channelIndex, inTileOffset, value[channelIndex])
 			inTileOffset += channel.Size()
 		}
 	}
 }
 
-func (t *TileOrderWriteIterator) writeTiles(tiles map[int][]byte, tileIndex int) error {
-	if t.layer.Separated {
-		for channelIndex := range t.layer.Channels {
-			channelTile := tileIndex + t.layer.Dimensions.Tiles()*channelIndex
-			err := t.layer.WriteTile(t.backing, t.header, channelTile, tiles[channelIndex])
+func (t *TileOrderWriteIterator) writeTiles(layer Layer, tiles map[int][]byte, tileIndex int) error {
+	if layer.Separated {
+		for channelIndex := range layer.Channels {
+			channelTile := tileIndex + layer.Dimensions.Tiles()*channelIndex
+			err := layer.writeTileWith(&t.encoder, t.backing, t.header, channelTile, tiles[channelIndex])
 			if err != nil {
 				return err
 			}
 		}
 		return nil
 	} else {
-		return t.layer.WriteTile(t.backing, t.header, tileIndex, tiles[nonSeparatedKey])
+		return layer.writeTileWith(&t.encoder, t.backing, t.header, tileIndex, tiles[nonSeparatedKey])
 	}
 }
@@ -2,6 +2,8 @@ package gopixi
 
 import (
 	"encoding/binary"
+	"errors"
+	"slices"
 	"testing"
 
 	"github.com/gracefulearth/gopixi/internal/buffer"
@@ -167,3 +169,79 @@ func TestTileOrderWriteIterator(t *testing.T) {
 		}
 	}
 }
+
+func TestTileOrderWriteIteratorReset(t *testing.T) {
+	var writer *TileOrderWriteIterator
+	for fileIndex, compression := range []Compression{CompressionFlate, CompressionLzwMsb, CompressionFlate, CompressionNone} {
+		buf := buffer.NewBuffer(10)
+		pixi, err := Create(buf, NewHeader(binary.LittleEndian, OffsetSize4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		layer := NewLayer("reset",
+			DimensionSet{{Name: "x", Size: 7, TileSize: 4}, {Name: "y", Size: 5, TileSize: 2}},
+			ChannelSet{{Name: "v", Type: ChannelUint16}},
+			WithCompression(compression))
+		if writer == nil {
+			writer = NewTileOrderWriteIterator(buf, pixi.Header, layer)
+		} else {
+			writer.Reset(buf, pixi.Header, layer)
+		}
+		err = pixi.AppendIterativeLayer(buf, layer, writer, func(writer IterativeLayerWriter) error {
+			for writer.Next() {
+				coord := writer.Coordinate()
+				if layer.Dimensions.ContainsCoordinate(coord) {
+					writer.SetSample(Sample{uint16(fileIndex*100 + coord[0] + coord[1]*7)})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := NewMemoryLayer(buffer.NewBufferFrom(buf.Bytes()), read.Header, read.Layers[0])
+		for coord := range read.Layers[0].Dimensions.SampleCoordinates() {
+			sample, err := SampleAt(data, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sample[0] != uint16(fileIndex*100+coord[0]+coord[1]*7) {
+				t.Fatalf("file %d: unexpected sample %v at %v", fileIndex, sample, coord)
+			}
+		}
+		if read.Layers[0].Channels[0].Max != uint16(fileIndex*100+34) {
+			t.Errorf("file %d: expected channel max to be computed for the new layer, got %v", fileIndex, read.Layers[0].Channels[0].Max)
+		}
+	}
+}
+
+func TestTileOrderWriteIteratorValueTypeError(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 6, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelInt16}})
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			if x := writer.Coordinate()[0]; x == 3 {
+				writer.SetSample(Sample{x}) // an int rather than an int16
+			} else {
+				writer.SetSample(Sample{int16(x)})
+			}
+		}
+		return nil
+	})
+	var mismatch ErrValueType
+	if !errors.As(err, &mismatch) || mismatch.Channel != "v" || mismatch.Got != "int" || !slices.Equal(mismatch.Coordinate, SampleCoordinate{3}) {
+		t.Errorf("expected type mismatch at coordinate 3, got %v", err)
+	}
+	if len(file.Layers) != 0 {
+		t.Error("expected the layer not to be appended")
+	}
+}
@@ -2,19 +2,34 @@ package gopixi
 
 import (
 	"fmt"
-	"hash/crc32"
 	"io"
+	"slices"
 )
 
 type layerOptions struct {
-	separated   bool
-	compression Compression
+	separated        bool
+	compression      Compression
+	compressionLevel int
+	targetTileBytes  int
+	halo             []int
+	nonFinite        map[string]NonFiniteRule
+	sparseTiles      bool
+	headerDictionary bool
+	georeference     *Georeference
 }
 
 type LayerOption interface {
 	applyLayer(*layerOptions)
 }
 
+// The bits of the configuration field of a layer header.
+const (
+	layerSeparated        uint32 = 1 << 0
+	layerHeaderDictionary uint32 = 1 << 1
+	layerAttributes       uint32 = 1 << 2
+	layerGeoreference     uint32 = 1 << 3
+)
+
 type separatedOption struct {
 	separated bool
 }
@@ -39,6 +54,20 @@ func WithCompression(c Compression) LayerOption {
 	return compressionOption{compression: c}
 }
 
+type compressionLevelOption struct {
+	level int
+}
+
+func (o compressionLevelOption) applyLayer(opts *layerOptions) {
+	opts.compressionLevel = o.level
+}
+
+// Sets the level at which tiles are compressed, for compressions that support levels (see
+// Layer.CompressionLevel).
+func WithCompressionLevel(level int) LayerOption {
+	return compressionLevelOption{level: level}
+}
+
 // Pixi files are composed of one or more layers. Generally, layers are used to represent the same data set
 // at different 'zoom levels'. For example, a large digital elevation model data set might have a layer
 // that shows a zoomed-out view of the terrain at a much smaller footprint, useful for thumbnails and previews.
@@ -51,6 +80,29 @@ type Layer struct {
 	// other at the same index.
 	Separated   bool
 	Compression Compression // The type of compression used on this dataset (e.g., Flate, lz4).
+	// The level at which tiles are compressed when written, for compressions that support levels: for
+	// CompressionZstd, a standard zstd level from 1 (fastest) to 22 (smallest), and for CompressionLz4, a
+	// high compression level from 1 to 9, which is slower to write but just as fast to read. Zero selects the
+	// default level of the compression. The level is not stored in the file, as it is not needed to decode tiles.
+	CompressionLevel int
+	// How samples written to each named floating point channel treat NaN and infinite values; channels
+	// without a rule are written as they are. Like the compression level, the rules are not stored in the file.
+	NonFinite map[string]NonFiniteRule
+	// Whether tiles whose samples all hold the fill values of their channels (see Channel.FillValue) are left
+	// unwritten, to be read back as fill. Layers without channel fill values are always written in full. Like
+	// the compression level, this is not stored in the file.
+	SparseTiles bool
+	// Whether the friendly strings of the layer header are written through a string table of the distinct
+	// strings, as by WithHeaderDictionary. Requires VersionExtensions or later.
+	HeaderDictionary bool
+	// Typed metadata describing the layer, such as its provenance, sensor, or processing parameters. Values
+	// are strings, booleans, integers (read back as int64), or floating point numbers (read back as float64),
+	// or slices of values of one of these kinds. Requires VersionExtensions or later when not empty.
+	Attributes map[string]any
+	// The coordinate reference system and geotransform placing the first two dimensions of the layer on the
+	// Earth, or nil if the layer is not georeferenced (see Layer.SetCRS). Requires VersionExtensions or
+	// later when set.
+	Georeference *Georeference
 	// A slice of Dimension structs representing the dimensions and tiling of this dataset.
 	// No dimensions equals an empty dataset. Dimensions are stored and iterated such that the
 	// samples for the first dimension are the closest together in memory, with progressively
@@ -60,47 +112,70 @@ type Layer struct {
 	TileBytes      []int64    // An array of byte counts representing (compressed) size of each tile in bytes for this dataset.
 	TileOffsets    []int64    // An array of byte offsets representing the position in the file of each tile in the dataset.
 	NextLayerStart int64      // The byte-index offset of the next layer in the file, from the start of the file. 0 if this is the last layer in the file.
+	// The size in bytes of the string data block at the end of each decoded disk tile, holding the strings of
+	// the string channels of the tile, for layers with string channels (see ChannelString). Nil otherwise.
+	StringBytes []int64
 }
 
 // Helper constructor to ensure that certain invariants in a layer are maintained when it is created.
+// Dimensions with a TileSize of zero are given a tile size automatically (see ChooseTileSizes and
+// WithTargetTileBytes).
 func NewLayer(name string, dimensions DimensionSet, channels ChannelSet, opts ...LayerOption) Layer {
-	options := layerOptions{} // zero values are defaults, interleaved sample values and no compression
+	// zero values are defaults, interleaved sample values and no compression
+	options := layerOptions{targetTileBytes: DefaultTargetTileBytes}
 	for _, o := range opts {
 		o.applyLayer(&options)
 	}
+	dimensions = ChooseTileSizes(dimensions, channels, options.separated, options.compression, options.targetTileBytes)
+	dimensions = withHalos(dimensions, options.halo)
 
 	l := Layer{
-		Name:        name,
-		Separated:   options.separated,
-		Compression: options.compression,
-		Dimensions:  dimensions,
-		Channels:    channels,
+		Name:             name,
+		Separated:        options.separated,
+		Compression:      options.compression,
+		CompressionLevel: options.compressionLevel,
+		NonFinite:        options.nonFinite,
+		SparseTiles:      options.sparseTiles,
+		HeaderDictionary: options.headerDictionary,
+		Georeference:     options.georeference,
+		Dimensions:       dimensions,
+		Channels:         channels,
 	}
 
 	l.TileBytes = make([]int64, l.DiskTiles())
 	l.TileOffsets = make([]int64, l.DiskTiles())
+	l.StringBytes = l.emptyStringBytes()
 	return l
 }
 
 // The size of the requested disk tile in bytes. For contiguous files, the size of each tile is always
 // the same. However, for separated data sets, each channel is tiled (so the number of on-disk
 // tiles is actually channelCount * Tiles()). Hence, the tile size changes depending on which
-// channel is being accessed.
+// channel is being accessed. Tiles with halos or string channels are larger still.
 func (d Layer) DiskTileSize(tileIndex int) int {
+	size := d.slotsTileSize(tileIndex)
+	if tileIndex >= 0 && tileIndex < len(d.StringBytes) {
+		size += int(d.StringBytes[tileIndex])
+	}
+	return size
+}
+
+// The size in bytes of the fixed-size values of the samples of the disk tile, before any string data block.
+func (d Layer) slotsTileSize(tileIndex int) int {
 	if d.Dimensions.Tiles() == 0 {
 		return 0
 	}
+	samples := d.Dimensions.TileSamples() + d.Dimensions.HaloSamples()
 	if d.Separated {
 		channel := tileIndex / d.Dimensions.Tiles()
 		channelType := d.Channels[channel].Type
 		if channelType == ChannelBool {
 			// For boolean channels in separated mode, use bit packing
-			samples := d.Dimensions.TileSamples()
 			return (samples + 7) / 8 // Round up to nearest byte
 		}
-		return d.Dimensions.TileSamples() * d.Channels[channel].Size()
+		return samples * d.Channels[channel].Size()
 	} else {
-		return d.Dimensions.TileSamples() * d.Channels.Size()
+		return samples * d.Channels.Size()
 	}
 }
 
@@ -115,11 +190,35 @@ func (d Layer) DiskTiles() int {
 	return tiles
 }
 
+// Checks that the sample can be written to the layer at the given coordinate, as a schema check that
+// surfaces ingest bugs before any data is written: the coordinate must lie within the layer, and the sample
+// must have a value of the right Go type for every channel. Returns an ErrSampleCoordinateOutOfBounds or
+// an ErrValueType carrying the coordinate if not.
+func (d Layer) CheckSample(coord SampleCoordinate, sample Sample) error {
+	if !d.Dimensions.ContainsCoordinate(coord) {
+		return ErrSampleCoordinateOutOfBounds{Coordinate: coord, Dimensions: d.Dimensions}
+	}
+	return withCoordinate(d.Channels.CheckSample(sample), coord)
+}
+
+// Attaches the coordinate of the sample being written to an ErrValueType, returning other errors unchanged.
+func withCoordinate(err error, coord SampleCoordinate) error {
+	if mismatch, ok := err.(ErrValueType); ok {
+		mismatch.Coordinate = slices.Clone(coord)
+		return mismatch
+	}
+	return err
+}
+
 // Get the total number of bytes that will be occupied in the file by this layer's header.
 func (d Layer) HeaderSize(h Header) int {
-	headerSize := 4 + 4                   // 4 bytes each for configuration and compression
-	headerSize += 2 + len([]byte(d.Name)) // 2 bytes for name length, then name
-	headerSize += 4                       // four bytes for dimension count
+	headerSize := 4 + 4 // 4 bytes each for configuration and compression
+	if d.HeaderDictionary {
+		h.dictionary = d.stringTable()
+		headerSize += h.dictionary.DiskSize(h) // the string table, after which strings are 4 byte indices
+	}
+	headerSize += h.FriendlySize(d.Name) // 2 bytes for name length, then name
+	headerSize += 4                      // four bytes for dimension count
 	for _, d := range d.Dimensions {
 		headerSize += d.HeaderSize(h) // add each dimension header size
 	}
@@ -127,9 +226,18 @@ func (d Layer) HeaderSize(h Header) int {
 	for _, f := range d.Channels {
 		headerSize += f.HeaderSize(h) // add each channel header size
 	}
+	if len(d.Attributes) > 0 {
+		headerSize += attributesSize(h, d.Attributes)
+	}
+	if d.Georeference != nil {
+		headerSize += d.Georeference.HeaderSize(h)
+	}
 	headerSize += d.DiskTiles() * int(h.OffsetSize) // offset size bytes for each real disk tile size in bytes
 	headerSize += d.DiskTiles() * int(h.OffsetSize) // offset size bytes for each tile offset
-	headerSize += int(h.OffsetSize)                 // offset size bytes for the next layer start offset
+	if d.Channels.HasStrings() {
+		headerSize += d.DiskTiles() * int(h.OffsetSize) // offset size bytes for each string data block size
+	}
+	headerSize += int(h.OffsetSize) // offset size bytes for the next layer start offset
 	return headerSize
 }
 
@@ -153,11 +261,38 @@ func (d Layer) WriteHeader(w io.Writer, h Header) error {
 	if tiles != len(d.TileOffsets) {
 		return ErrFormat("invalid TileOffsets: must have same number of elements as tiles in data set for valid pixi files")
 	}
+	if err := d.checkStrings(); err != nil {
+		return err
+	}
+
+	if d.HeaderDictionary && h.Version < VersionExtensions {
+		return ErrFormat(fmt.Sprintf("layer header dictionaries require version %d or later", VersionExtensions))
+	}
+	if len(d.Attributes) > 0 && h.Version < VersionExtensions {
+		return ErrFormat(fmt.Sprintf("layer attributes require version %d or later", VersionExtensions))
+	}
+	if d.Georeference != nil {
+		if h.Version < VersionExtensions {
+			return ErrFormat(fmt.Sprintf("layer georeferences require version %d or later", VersionExtensions))
+		}
+		if err := d.Georeference.check(d.Dimensions); err != nil {
+			return err
+		}
+	}
 
 	// write configuration and compression
 	configuration := uint32(0)
 	if d.Separated {
-		configuration = 1
+		configuration |= layerSeparated
+	}
+	if d.HeaderDictionary {
+		configuration |= layerHeaderDictionary
+	}
+	if len(d.Attributes) > 0 {
+		configuration |= layerAttributes
+	}
+	if d.Georeference != nil {
+		configuration |= layerGeoreference
 	}
 	err := h.Write(w, configuration)
 	if err != nil {
@@ -169,6 +304,15 @@ func (d Layer) WriteHeader(w io.Writer, h Header) error {
 		return err
 	}
 
+	// write the string table, through which the strings that follow are written
+	if d.HeaderDictionary {
+		h.dictionary = d.stringTable()
+		err = h.dictionary.Write(w, h)
+		if err != nil {
+			return err
+		}
+	}
+
 	// write layer name
 	err = h.WriteFriendly(w, d.Name)
 	if err != nil {
@@ -199,7 +343,23 @@ func (d Layer) WriteHeader(w io.Writer, h Header) error {
 		}
 	}
 
-	// write tile bytes, offsets, and start of next layer
+	// write attributes
+	if len(d.Attributes) > 0 {
+		err = writeAttributes(w, h, d.Attributes)
+		if err != nil {
+			return err
+		}
+	}
+
+	// write georeference
+	if d.Georeference != nil {
+		err = d.Georeference.Write(w, h)
+		if err != nil {
+			return err
+		}
+	}
+
+	// write tile bytes, offsets, string data block sizes, and start of next layer
 	err = h.WriteOffsets(w, d.TileBytes)
 	if err != nil {
 		return err
@@ -208,6 +368,12 @@ func (d Layer) WriteHeader(w io.Writer, h Header) error {
 	if err != nil {
 		return err
 	}
+	if d.Channels.HasStrings() {
+		err = h.WriteOffsets(w, d.StringBytes)
+		if err != nil {
+			return err
+		}
+	}
 	err = h.WriteOffset(w, d.NextLayerStart)
 	if err != nil {
 		return err
@@ -225,12 +391,21 @@ func (d *Layer) ReadLayer(r io.Reader, h Header) error {
 	if err != nil {
 		return err
 	}
-	d.Separated = configuration != 0
+	d.Separated = configuration&layerSeparated != 0
+	d.HeaderDictionary = h.Version >= VersionExtensions && configuration&layerHeaderDictionary != 0
 	err = h.Read(r, &d.Compression)
 	if err != nil {
 		return err
 	}
 
+	// read the string table, through which the strings that follow are read
+	if d.HeaderDictionary {
+		h.dictionary, err = readStringTable(r, h)
+		if err != nil {
+			return err
+		}
+	}
+
 	// read layer name
 	d.Name, err = h.ReadFriendly(r)
 	if err != nil {
@@ -275,7 +450,26 @@ func (d *Layer) ReadLayer(r io.Reader, h Header) error {
 		d.Channels[fInd] = *channel
 	}
 
-	// read tile bytes, offsets, and next layer start
+	// read attributes
+	d.Attributes = nil
+	if h.Version >= VersionExtensions && configuration&layerAttributes != 0 {
+		d.Attributes, err = readAttributes(r, h)
+		if err != nil {
+			return err
+		}
+	}
+
+	// read georeference
+	d.Georeference = nil
+	if h.Version >= VersionExtensions && configuration&layerGeoreference != 0 {
+		d.Georeference = &Georeference{}
+		err = d.Georeference.Read(r, h)
+		if err != nil {
+			return ErrFormat(fmt.Sprintf("reading georeference: %s", err))
+		}
+	}
+
+	// read tile bytes, offsets, string data block sizes, and next layer start
 	tiles := d.DiskTiles()
 	d.TileBytes = make([]int64, tiles)
 	err = h.ReadOffsets(r, d.TileBytes)
@@ -287,6 +481,13 @@ func (d *Layer) ReadLayer(r io.Reader, h Header) error {
 	if err != nil {
 		return err
 	}
+	d.StringBytes = d.emptyStringBytes()
+	if d.StringBytes != nil {
+		err = h.ReadOffsets(r, d.StringBytes)
+		if err != nil {
+			return err
+		}
+	}
 	d.NextLayerStart, err = h.ReadOffset(r)
 	if err != nil {
 		return err
@@ -319,24 +520,38 @@ func (l Layer) OverwriteHeader(w io.WriteSeeker, h Header, headerStartOffset int
 
 // Write the encoded tile data to the current stream position, updating the offset and byte count
 // for this tile in the layer header (but not writing those offsets to the stream just yet). The
-// data is written with a 4-byte checksum directly after it, which is used to verify data integrity
+// data is written with its checksum directly after it, which is used to verify data integrity
 // when reading the tile later. The compression attribute of the layer is used to apply compression
-// to the tile data before writing it to the stream.
+// to the tile data before writing it to the stream. Fill tiles of sparse layers are not written.
 func (l Layer) WriteTile(w io.WriteSeeker, h Header, tileIndex int, data []byte) error {
+	return l.writeTileWith(&tileEncoder{}, w, h, tileIndex, data)
+}
+
+// Writes the tile as in WriteTile, reusing the compressors and buffers held by the encoder.
+func (l Layer) writeTileWith(enc *tileEncoder, w io.WriteSeeker, h Header, tileIndex int, data []byte) error {
+	if l.StringBytes != nil {
+		l.StringBytes[tileIndex] = int64(len(data) - l.slotsTileSize(tileIndex))
+	}
+	if l.SparseTiles && l.isFillTile(h, tileIndex, data) {
+		l.TileOffsets[tileIndex], l.TileBytes[tileIndex] = 0, 0
+		return enc.tileWritten(l, tileIndex, data)
+	}
 	streamOffset, err := w.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return err
 	}
 	l.TileOffsets[tileIndex] = streamOffset
 
-	writeAmt, err := l.Compression.writeChunk(w, l, tileIndex, data)
+	writeAmt, err := l.Compression.writeChunkWith(enc, w, h, l, tileIndex, data)
 	if err != nil {
 		return err
 	}
 	l.TileBytes[tileIndex] = int64(writeAmt)
 
-	checksum := crc32.ChecksumIEEE(data)
-	return h.Write(w, checksum)
+	if err := h.writeChecksum(w, data); err != nil {
+		return err
+	}
+	return enc.tileWritten(l, tileIndex, data)
 }
 
 // Overwrite the already-written tile at the given tile index with new data. Seeks to the correct
@@ -357,10 +572,15 @@ func (l Layer) OverwriteTile(w io.WriteSeeker, h Header, tileIndex int, data []b
 
 // Read a raw tile (not yet decoded into sample channels) at the given tile index. The tile must
 // have been previously written (either in this session or a previous one) for this operation to succeed.
-// The data is verified for integrity using a four-byte checksum placed directly after the saved
+// The data is verified for integrity using the checksum placed directly after the saved
 // tile data, and an error is returned (along with the data read into the chunk) if the checksum
 // check fails.
 func (l Layer) ReadTile(r io.ReadSeeker, h Header, tileIndex int, data []byte) error {
+	return l.readTile(r, h, tileIndex, data, true)
+}
+
+// Reads the tile as in ReadTile, skipping the comparison with its stored checksum unless verify is set.
+func (l Layer) readTile(r io.ReadSeeker, h Header, tileIndex int, data []byte, verify bool) error {
 	if tileIndex < 0 || tileIndex >= len(l.TileBytes) {
 		return ErrTileNotFound{TileIndex: tileIndex}
 	}
@@ -374,7 +594,7 @@ func (l Layer) ReadTile(r io.ReadSeeker, h Header, tileIndex int, data []byte) e
 	}
 
 	_, err = l.Compression.readChunk(r, l, tileIndex, data)
-	if err != nil {
+	if err != nil || !verify {
 		return err
 	}
 
@@ -385,14 +605,41 @@ func (l Layer) ReadTile(r io.ReadSeeker, h Header, tileIndex int, data []byte) e
 		return err
 	}
 
-	var savedChecksum uint32
-	err = h.Read(r, &savedChecksum)
+	savedChecksum := make([]byte, h.Checksum.Size())
+	_, err = io.ReadFull(r, savedChecksum)
 	if err != nil {
 		return err
 	}
 
-	if savedChecksum != crc32.ChecksumIEEE(data) {
-		return ErrDataIntegrity{TileIndex: tileIndex, LayerName: l.Name}
+	if !h.checksumMatches(savedChecksum, data) {
+		return ErrChecksum{TileIndex: tileIndex, LayerName: l.Name}
 	}
 	return nil
 }
+
+// The value of a single channel at the given stored position (a tile or halo sample index) of a decoded
+// tile. For separated layers the tile must be one of the given channel.
+func (l Layer) storedValue(h Header, data []byte, channelIndex int, position int) any {
+	channel := l.Channels[channelIndex]
+	if l.Separated {
+		if channel.Type == ChannelBool {
+			return UnpackBool(data, position)
+		}
+		return channel.Value(data[position*channel.Size():], h.ByteOrder)
+	}
+	return channel.Value(data[position*l.Channels.Size()+l.Channels.Offset(channelIndex):], h.ByteOrder)
+}
+
+// Writes the value of a single channel into the given stored position of a decoded tile.
+func (l Layer) putStoredValue(h Header, data []byte, channelIndex int, position int, value any) {
+	channel := l.Channels[channelIndex]
+	if l.Separated {
+		if channel.Type == ChannelBool {
+			PackBool(value.(bool), data, position)
+		} else {
+			channel.PutValue(value, h.ByteOrder, data[position*channel.Size():])
+		}
+		return
+	}
+	channel.PutValue(value, h.ByteOrder, data[position*l.Channels.Size()+l.Channels.Offset(channelIndex):])
+}
@@ -2,6 +2,7 @@ package gopixi
 
 import (
 	"compress/flate"
+	"encoding/binary"
 	"errors"
 	"math/rand/v2"
 	"reflect"
@@ -222,6 +223,49 @@ func TestLayerTileWriteReadCorrupted(t *testing.T) {
 	}
 }
 
+func TestReadLayerVerifyChecksums(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 4, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{uint8(writer.Coordinate()[0])})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Bytes()[file.Layers[0].TileOffsets[0]+2] = 99 // corrupt the third sample of the uncompressed tile
+
+	var checksumErr ErrChecksum
+	verified, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SampleAt(verified, SampleCoordinate{2}); !errors.As(err, &checksumErr) || checksumErr.TileIndex != 0 {
+		t.Errorf("expected checksum error reading the corrupted tile, got %v", err)
+	}
+	if _, err := file.Layers[0].ReadRegion(file.Stream(), file.Header, Region{Start: []int{0}, End: []int{4}}); !errors.As(err, &checksumErr) {
+		t.Errorf("expected checksum error reading the corrupted region, got %v", err)
+	}
+
+	unverified, err := file.Layer(0, WithVerifyChecksums(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample, err := SampleAt(unverified, SampleCoordinate{2}); err != nil || sample[0] != uint8(99) {
+		t.Errorf("expected the corrupted sample without verification, got %v (%v)", sample, err)
+	}
+	samples, err := file.Layers[0].ReadRegion(file.Stream(), file.Header, Region{Start: []int{0}, End: []int{4}}, WithVerifyChecksums(false))
+	if err != nil || len(samples) != 4 || samples[2][0] != uint8(99) {
+		t.Errorf("expected the corrupted region without verification, got %v (%v)", samples, err)
+	}
+}
+
 func TestLayerDiskTileSize(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -370,3 +414,31 @@ func TestLayerDiskTileSize(t *testing.T) {
 		})
 	}
 }
+
+func TestWithCompressionLevel(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 64, TileSize: 32}, {Name: "y", Size: 16, TileSize: 8}}
+	channels := ChannelSet{{Name: "v", Type: ChannelUint16}}
+	for _, level := range []int{1, 19} {
+		layer := NewLayer("zstd", dims, channels, WithCompression(CompressionZstd), WithCompressionLevel(level))
+		if layer.CompressionLevel != level {
+			t.Fatalf("expected compression level %d, got %d", level, layer.CompressionLevel)
+		}
+		buf := buffer.NewBuffer(10)
+		writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{layer}, func(layer int, coord SampleCoordinate) Sample {
+			return Sample{uint16(coord[0] / 4 * coord[1])}
+		})
+		read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read.Layers[0].Compression != CompressionZstd || read.Layers[0].CompressionLevel != 0 {
+			t.Errorf("expected zstd compression without a stored level, got %v level %d", read.Layers[0].Compression, read.Layers[0].CompressionLevel)
+		}
+		access := NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), read.Header, read.Layers[0], 2)
+		for coord := range dims.SampleCoordinates() {
+			if sample, err := SampleAt(access, coord); err != nil || sample[0] != uint16(coord[0]/4*coord[1]) {
+				t.Fatalf("level %d sample %v: got %v (%v)", level, coord, sample, err)
+			}
+		}
+	}
+}
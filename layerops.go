@@ -0,0 +1,454 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// An elementwise operation combining two layers, or a layer and a constant, with AppendOperation.
+type Operation int
+
+const (
+	OperationAdd Operation = iota
+	OperationSubtract
+	OperationMultiply
+	OperationMin
+	OperationMax
+)
+
+func (o Operation) String() string {
+	switch o {
+	case OperationAdd:
+		return "add"
+	case OperationSubtract:
+		return "subtract"
+	case OperationMultiply:
+		return "multiply"
+	case OperationMin:
+		return "min"
+	case OperationMax:
+		return "max"
+	default:
+		return fmt.Sprintf("operation(%d)", int(o))
+	}
+}
+
+func (o Operation) apply(x float64, y float64) float64 {
+	switch o {
+	case OperationAdd:
+		return x + y
+	case OperationSubtract:
+		return x - y
+	case OperationMultiply:
+		return x * y
+	case OperationMin:
+		return math.Min(x, y)
+	case OperationMax:
+		return math.Max(x, y)
+	default:
+		return math.NaN()
+	}
+}
+
+// The operand of an operation: either a layer, or a constant if Layer is nil.
+type Operand struct {
+	Layer    TileAccessLayer
+	Constant float64
+	// The value marking missing samples of a layer operand (e.g., -9999). Samples missing in either operand,
+	// or that are NaN, are missing in the result.
+	Fill *float64
+}
+
+// A layer operand, whose samples equal to the fill value (if not nil) are missing.
+func LayerOperand(layer TileAccessLayer, fill *float64) Operand {
+	return Operand{Layer: layer, Fill: fill}
+}
+
+// A constant operand, combined with every sample of the other operand.
+func ConstantOperand(value float64) Operand {
+	return Operand{Constant: value}
+}
+
+// The type of a channel of the operand. Integral constants have the narrowest integer type holding them,
+// and other constants are float32, so that they promote to float32 with integers of up to 16 bits.
+func (o Operand) channelType(channelIndex int) ChannelType {
+	if o.Layer == nil {
+		if o.Constant == math.Trunc(o.Constant) && math.Abs(o.Constant) < 1<<53 {
+			return integerForConstant(o.Constant)
+		}
+		return ChannelFloat32
+	}
+	channels := o.Layer.Layer().Channels
+	return channels[min(channelIndex, len(channels)-1)].Type.Base()
+}
+
+// The narrowest integer type holding the integral constant.
+func integerForConstant(c float64) ChannelType {
+	switch {
+	case c >= 0 && c <= math.MaxUint8:
+		return ChannelUint8
+	case c >= math.MinInt8 && c <= math.MaxInt8:
+		return ChannelInt8
+	case c >= math.MinInt16 && c <= math.MaxInt16:
+		return ChannelInt16
+	case c >= math.MinInt32 && c <= math.MaxInt32:
+		return ChannelInt32
+	default:
+		return ChannelInt64
+	}
+}
+
+// The number of bits of an integer channel type, and whether it is signed. Booleans count as 8-bit
+// unsigned integers. Returns zero bits for floating point types.
+func integerBits(t ChannelType) (int, bool) {
+	switch t.Base() {
+	case ChannelBool, ChannelUint8:
+		return 8, false
+	case ChannelInt8:
+		return 8, true
+	case ChannelUint16:
+		return 16, false
+	case ChannelInt16:
+		return 16, true
+	case ChannelUint32:
+		return 32, false
+	case ChannelInt32:
+		return 32, true
+	case ChannelUint64:
+		return 64, false
+	case ChannelInt64:
+		return 64, true
+	case ChannelUint128:
+		return 128, false
+	case ChannelInt128:
+		return 128, true
+	default:
+		return 0, false
+	}
+}
+
+func integerType(bits int, signed bool) ChannelType {
+	types := map[int][2]ChannelType{
+		8: {ChannelUint8, ChannelInt8}, 16: {ChannelUint16, ChannelInt16}, 32: {ChannelUint32, ChannelInt32},
+		64: {ChannelUint64, ChannelInt64}, 128: {ChannelUint128, ChannelInt128},
+	}
+	if signed {
+		return types[bits][1]
+	}
+	return types[bits][0]
+}
+
+// The relative precision of a floating point channel type.
+func floatRank(t ChannelType) int {
+	switch t.Base() {
+	case ChannelFloat8:
+		return 1
+	case ChannelFloat16, ChannelBFloat16:
+		return 2
+	case ChannelFloat32:
+		return 3
+	case ChannelFloat64:
+		return 4
+	case ChannelFloat128:
+		return 5
+	default:
+		return 0
+	}
+}
+
+// The type of the result of combining values of the two channel types: the narrowest type that represents
+// every value of both, or float64 if there is none. Booleans promote as 8-bit unsigned integers.
+func PromoteTypes(a ChannelType, b ChannelType) ChannelType {
+	a, b = a.Base(), b.Base()
+	if a == b && a != ChannelBool {
+		return a
+	}
+	bitsA, signedA := integerBits(a)
+	bitsB, signedB := integerBits(b)
+	switch {
+	case bitsA > 0 && bitsB > 0:
+		if signedA == signedB {
+			return integerType(max(bitsA, bitsB), signedA)
+		}
+		signedBits, unsignedBits := bitsA, bitsB
+		if signedB {
+			signedBits, unsignedBits = bitsB, bitsA
+		}
+		if signedBits > unsignedBits {
+			return integerType(signedBits, true)
+		}
+		if unsignedBits < 128 {
+			return integerType(2*unsignedBits, true)
+		}
+		return ChannelFloat64
+	case bitsA > 0 || bitsB > 0:
+		float, bits := b, bitsA
+		if bitsB > 0 {
+			float, bits = a, bitsB
+		}
+		required := ChannelFloat64
+		switch bits {
+		case 8:
+			required = ChannelFloat16
+		case 16:
+			required = ChannelFloat32
+		}
+		if floatRank(float) >= floatRank(required) {
+			return float
+		}
+		return required
+	default:
+		switch {
+		case floatRank(a) > floatRank(b):
+			return a
+		case floatRank(b) > floatRank(a):
+			return b
+		default:
+			return ChannelFloat32 // float16 and bfloat16
+		}
+	}
+}
+
+// Combines the two operands elementwise with the operation, tile by tile, and appends the results to the
+// end of the file as a new layer with the given name and the layout of the first operand, which must be a
+// layer. The second may be a constant or a layer over the same or a subset of the dimensions of the first,
+// broadcast along the others. Channel types are promoted by PromoteTypes, and missing samples are written
+// as outputFill if it is not nil, or else as NaN or the fill value of an operand.
+func (p *Pixi) AppendOperation(w io.WriteSeeker, name string, op Operation, a Operand, b Operand, outputFill *float64) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append layer"}
+	}
+	if a.Layer == nil {
+		return ErrFormat("the first operand of an operation must be a layer")
+	}
+	aLayer := a.Layer.Layer()
+	aValues, err := a.bind(aLayer)
+	if err != nil {
+		return err
+	}
+	bValues, err := b.bind(aLayer)
+	if err != nil {
+		return err
+	}
+
+	// describe the result layer
+	channels := make(ChannelSet, len(aLayer.Channels))
+	for i, channel := range aLayer.Channels {
+		resultType := PromoteTypes(channel.Type, b.channelType(i))
+		if b.Layer == nil && floatRank(channel.Type) > 0 {
+			resultType = channel.Type.Base() // constants do not widen floating point layers
+		}
+		if floatRank(resultType) == 0 {
+			for _, t := range []ChannelType{channel.Type, b.channelType(i)} {
+				if bits, _ := integerBits(t); bits > 32 {
+					return ErrUnsupported(fmt.Sprintf("%v operation on channel '%s' of %v values with an integer result", op, channel.Name, t.Base()))
+				}
+			}
+		}
+		unit := channel.Unit
+		if b.Layer != nil {
+			other := b.Layer.Layer().Channels[min(i, len(b.Layer.Layer().Channels)-1)].Unit
+			var err error
+			if op == OperationMultiply {
+				unit, err = MultiplyUnits(channel.Unit, other)
+			} else {
+				unit, err = AddUnits(channel.Unit, other)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		channels[i] = Channel{Name: channel.Name, Type: resultType, Unit: unit}
+	}
+
+	x, y := make([]float64, len(channels)), make([]float64, len(channels))
+	return p.appendTilewise(w, derivedLayer(name, aLayer, channels), resultFills(channels, outputFill, a, b),
+		func(coord SampleCoordinate, result []float64) error {
+			if err := aValues(coord, x); err != nil {
+				return err
+			}
+			if err := bValues(coord, y); err != nil {
+				return err
+			}
+			for c := range result {
+				result[c] = op.apply(x[c], y[c])
+			}
+			return nil
+		})
+}
+
+// Returns a function giving the values of the operand at every coordinate of the target layer's grid, for
+// each channel of the target, with missing values as NaN. Layer operands are broadcast onto the grid of the
+// target, as described by AppendOperation.
+func (o Operand) bind(target Layer) (func(coord SampleCoordinate, values []float64) error, error) {
+	if o.Layer == nil {
+		return func(coord SampleCoordinate, values []float64) error {
+			for c := range values {
+				values[c] = o.Constant
+			}
+			return nil
+		}, nil
+	}
+
+	layer := o.Layer.Layer()
+	if len(layer.Channels) != len(target.Channels) && len(layer.Channels) != 1 {
+		return nil, ErrFormat(fmt.Sprintf("layer '%s' has %d channels, expected 1 or %d", layer.Name, len(layer.Channels), len(target.Channels)))
+	}
+	mapped, err := broadcastCoordinates(target, layer)
+	if err != nil {
+		return nil, err
+	}
+	return func(coord SampleCoordinate, values []float64) error {
+		sample, err := SampleAt(o.Layer, mapped(coord))
+		if err != nil {
+			return err
+		}
+		for c := range values {
+			channel := min(c, len(sample)-1)
+			value, ok := layer.Channels[channel].Type.ToFloat64(sample[channel])
+			if !ok || (o.Fill != nil && value == *o.Fill) {
+				value = math.NaN()
+			}
+			values[c] = value
+		}
+		return nil
+	}, nil
+}
+
+// Returns a function mapping coordinates of the target layer onto the source layer broadcast over its grid:
+// the dimensions of the source must be a subset of those of the target, matched by name, whose grids align
+// with the target lying within the source. The returned coordinate is reused between calls.
+func broadcastCoordinates(target Layer, source Layer) (func(coord SampleCoordinate) SampleCoordinate, error) {
+	mapping := []int{}
+	shared := Layer{Name: target.Name}
+	for _, dim := range source.Dimensions {
+		index := target.Dimensions.Index(dim.Name)
+		if index < 0 {
+			return nil, ErrAxisMismatch{Dimension: dim.Name, Kind: AxisMismatchDimensions,
+				Detail: fmt.Sprintf("layer '%s' has no dimension '%s'", target.Name, dim.Name)}
+		}
+		mapping = append(mapping, index)
+		shared.Dimensions = append(shared.Dimensions, target.Dimensions[index])
+	}
+	alignment, err := AlignAxes(shared, source)
+	if err != nil {
+		return nil, err
+	}
+	if !alignment.Within {
+		return nil, ErrAxisMismatch{Kind: AxisMismatchExtent,
+			Detail: fmt.Sprintf("layer '%s' does not lie within layer '%s'", target.Name, source.Name)}
+	}
+	mapped := make(SampleCoordinate, len(mapping))
+	return func(coord SampleCoordinate) SampleCoordinate {
+		for j, index := range mapping {
+			mapped[j] = coord[index] + alignment.Offsets[j]
+		}
+		return mapped
+	}, nil
+}
+
+// Describes a layer with the given channels computed over the grid of the source layer, with the same
+// dimensions, tiling, compression, and storage, but no halos.
+func derivedLayer(name string, source Layer, channels ChannelSet) Layer {
+	dims := slices.Clone(source.Dimensions)
+	for i := range dims {
+		dims[i].Halo = 0
+	}
+	opts := []LayerOption{WithCompression(source.Compression)}
+	if source.Separated {
+		opts = append(opts, WithPlanar())
+	}
+	return NewLayer(name, dims, channels, opts...)
+}
+
+// The values written for missing results in each channel: the output fill if given, otherwise NaN for
+// floating point channels, and for integer channels the fill value of the first operand that has one, or zero.
+func resultFills(channels ChannelSet, outputFill *float64, operands ...Operand) []float64 {
+	fills := make([]float64, len(channels))
+	for i, channel := range channels {
+		switch {
+		case outputFill != nil:
+			fills[i] = *outputFill
+		case floatRank(channel.Type) > 0:
+			fills[i] = math.NaN()
+		default:
+			for _, operand := range operands {
+				if operand.Fill != nil {
+					fills[i] = *operand.Fill
+					break
+				}
+			}
+		}
+	}
+	return fills
+}
+
+// Appends the layer to the end of the file, computing the values of every channel of each sample within the
+// layer, tile by tile, with the compute function. Results that are not finite are written as the fill value
+// of their channel, which is recorded as its FillValue if the version of the file allows.
+func (p *Pixi) appendTilewise(w io.WriteSeeker, layer Layer, fills []float64, compute func(coord SampleCoordinate, result []float64) error) error {
+	if p.Header.Version >= VersionExtensions {
+		layer.Channels = slices.Clone(layer.Channels)
+		for c, channel := range layer.Channels {
+			layer.Channels[c].FillValue = channel.Type.FromFloat64(fills[c])
+		}
+	}
+	result := make([]float64, len(layer.Channels))
+	return p.appendSamplewise(w, layer, func(coord SampleCoordinate, sample Sample) error {
+		if err := compute(coord, result); err != nil {
+			return err
+		}
+		for c, channel := range layer.Channels {
+			value := result[c]
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				value = fills[c]
+			}
+			sample[c] = channel.Type.FromFloat64(value)
+		}
+		return nil
+	})
+}
+
+// Appends the layer to the end of the file, computing each sample within the layer, tile by tile, with the
+// compute function, which must set a value of the type of every channel. The layer must not have string
+// channels.
+func (p *Pixi) appendSamplewise(w io.WriteSeeker, layer Layer, compute func(coord SampleCoordinate, sample Sample) error) error {
+	p.checkpointed = false
+	layer.Channels = layer.Channels.withoutDistributions()
+	tiles := layer.Dimensions.Tiles()
+	encoder := p.newTileEncoder(len(p.Layers))
+	sample := make(Sample, len(layer.Channels))
+	for tile := range layer.DiskTiles() {
+		data := make([]byte, layer.DiskTileSize(tile))
+		var sampleErr error
+		layer.forEachTileSample(tile%tiles, func(inTile int, coord SampleCoordinate) {
+			if sampleErr != nil {
+				return
+			}
+			if sampleErr = compute(coord, sample); sampleErr != nil {
+				return
+			}
+			for c := range layer.Channels {
+				if layer.Separated && c != tile/tiles {
+					continue
+				}
+				layer.putStoredValue(p.Header, data, c, inTile, sample[c])
+			}
+		})
+		if sampleErr != nil {
+			return sampleErr
+		}
+
+		// the inputs may be read from the same stream, so seek back to the end for every tile
+		if _, err := w.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+		if err := layer.writeTileWith(encoder, w, p.Header, tile, data); err != nil {
+			return err
+		}
+		layer.updateTileStatistics(p.Header, tile, data)
+	}
+	return p.appendLayerHeader(w, layer)
+}
@@ -0,0 +1,170 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestPromoteTypes(t *testing.T) {
+	cases := []struct{ a, b, want ChannelType }{
+		{ChannelUint8, ChannelUint8, ChannelUint8},
+		{ChannelUint8, ChannelInt16, ChannelInt16},
+		{ChannelUint8, ChannelInt8, ChannelInt16},
+		{ChannelUint32, ChannelInt32, ChannelInt64},
+		{ChannelUint64, ChannelInt8, ChannelInt128},
+		{ChannelUint128, ChannelInt64, ChannelFloat64},
+		{ChannelInt8, ChannelFloat16, ChannelFloat16},
+		{ChannelInt16, ChannelFloat16, ChannelFloat32},
+		{ChannelInt32, ChannelFloat32, ChannelFloat64},
+		{ChannelFloat16, ChannelBFloat16, ChannelFloat32},
+		{ChannelFloat32, ChannelFloat64, ChannelFloat64},
+		{ChannelBool, ChannelBool, ChannelUint8},
+		{ChannelBool, ChannelFloat32, ChannelFloat32},
+	}
+	for _, tc := range cases {
+		if got := PromoteTypes(tc.a, tc.b); got != tc.want {
+			t.Errorf("%v and %v: expected %v, got %v", tc.a, tc.b, tc.want, got)
+		}
+		if got := PromoteTypes(tc.b, tc.a); got != tc.want {
+			t.Errorf("%v and %v: expected %v, got %v", tc.b, tc.a, tc.want, got)
+		}
+	}
+}
+
+func TestAppendOperation(t *testing.T) {
+	grid := DimensionSet{
+		{Name: "x", Size: 5, TileSize: 2, Axis: &Axis{Type: ChannelFloat64, Minimum: 0.0, Step: 1.0}},
+		{Name: "y", Size: 4, TileSize: 3, Axis: &Axis{Type: ChannelFloat64, Minimum: 0.0, Step: 1.0}},
+		{Name: "t", Size: 3, TileSize: 2},
+	}
+	// the second layer covers a larger grid, offset by one sample in x, and no time dimension
+	wide := DimensionSet{
+		{Name: "x", Size: 7, TileSize: 4, Axis: &Axis{Type: ChannelFloat64, Minimum: -1.0, Step: 1.0}},
+		{Name: "y", Size: 4, TileSize: 4, Axis: &Axis{Type: ChannelFloat64, Minimum: 0.0, Step: 1.0}},
+	}
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{
+		NewLayer("a", grid, ChannelSet{{Name: "u", Type: ChannelUint8, Unit: "m"}, {Name: "v", Type: ChannelInt16, Unit: "m"}}),
+		NewLayer("b", wide, ChannelSet{{Name: "w", Type: ChannelInt8, Unit: "m"}}, WithPlanar()),
+	}, func(layer int, coord SampleCoordinate) Sample {
+		if layer == 0 {
+			if coord[0] == 4 && coord[1] == 3 {
+				return Sample{uint8(255), int16(-9999)} // missing in both channels
+			}
+			return Sample{uint8(coord[0] + 10*coord[2]), int16(100 * coord[1])}
+		}
+		return Sample{int8(coord[0] - 2*coord[1])}
+	})
+	a := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 4), nil)
+	missing := -9999.0
+	a.Fill = &missing
+	b := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[1], 4), nil)
+
+	if err := p.AppendOperation(buf, "sum", OperationSubtract, a, b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendOperation(buf, "scaled", OperationMultiply, a, ConstantOperand(0.5), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendOperation(buf, "clamped", OperationMax, a, ConstantOperand(3), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r := buffer.NewBufferFrom(buf.Bytes())
+	read, err := ReadPixi(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, scaled, clamped := read.Layers[2], read.Layers[3], read.Layers[4]
+	if sum.Channels[0].Type != ChannelInt16 || sum.Channels[1].Type != ChannelInt16 || sum.Channels[0].Unit != "m" {
+		t.Errorf("unexpected difference channels %v", sum.Channels)
+	}
+	if scaled.Channels[0].Type != ChannelFloat32 || scaled.Channels[1].Type != ChannelFloat32 {
+		t.Errorf("unexpected scaled channels %v", scaled.Channels)
+	}
+	if clamped.Channels[0].Type != ChannelUint8 || clamped.Channels[1].Type != ChannelInt16 {
+		t.Errorf("unexpected clamped channels %v", clamped.Channels)
+	}
+	// the values written for missing results are recorded as fill values
+	if sum.Channels[1].FillValue != int16(missing) {
+		t.Errorf("expected the fill value of the first operand to be recorded, got %v", sum.Channels[1].FillValue)
+	}
+	if fill, ok := scaled.Channels[1].FillValue.(float32); !ok || !math.IsNaN(float64(fill)) {
+		t.Errorf("expected NaN to be recorded as the fill value, got %v", scaled.Channels[1].FillValue)
+	}
+
+	for coord := range grid.SampleCoordinates() {
+		u, v := float64(coord[0]+10*coord[2]), float64(100*coord[1])
+		w := float64(coord[0] + 1 - 2*coord[1])
+		isMissing := coord[0] == 4 && coord[1] == 3
+		check := func(layer Layer, want []float64) {
+			t.Helper()
+			sample, err := SampleAt(NewFifoCacheReadLayer(r, read.Header, layer, 1), coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for c, value := range sample {
+				got, _ := layer.Channels[c].Type.ToFloat64(value)
+				if got != want[c] && !(math.IsNaN(got) && math.IsNaN(want[c])) {
+					t.Fatalf("%s at %v channel %d: expected %v, got %v", layer.Name, coord, c, want[c], got)
+				}
+			}
+		}
+		if isMissing {
+			check(sum, []float64{255 - w, missing})
+			check(scaled, []float64{127.5, math.NaN()})
+			continue
+		}
+		check(sum, []float64{u - w, v - w})
+		check(scaled, []float64{u / 2, v / 2})
+		check(clamped, []float64{max(u, 3), max(v, 3)})
+	}
+}
+
+func TestAppendOperationWideIntegers(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{
+		NewLayer("a", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "u", Type: ChannelInt64}}),
+		NewLayer("b", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "u", Type: ChannelUint8}}),
+	}, func(layer int, coord SampleCoordinate) Sample {
+		if layer == 0 {
+			return Sample{int64(1<<53 + coord[0])}
+		}
+		return Sample{uint8(coord[0])}
+	})
+	a := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 4), nil)
+	b := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[1], 4), nil)
+	var unsupported ErrUnsupported
+	if err := p.AppendOperation(buf, "sum", OperationAdd, a, ConstantOperand(1), nil); !errors.As(err, &unsupported) {
+		t.Errorf("expected integer operations on 64-bit integers to be unsupported, got %v", err)
+	}
+	if err := p.AppendOperation(buf, "sum", OperationAdd, b, a, nil); !errors.As(err, &unsupported) {
+		t.Errorf("expected integer operations with a 64-bit integer operand to be unsupported, got %v", err)
+	}
+	if err := p.AppendOperation(buf, "half", OperationMultiply, a, ConstantOperand(0.5), nil); err != nil {
+		t.Errorf("expected operations with floating point results to be supported, got %v", err)
+	}
+}
+
+func TestAppendOperationMismatch(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{
+		NewLayer("a", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "u", Type: ChannelUint8}}),
+		NewLayer("b", DimensionSet{{Name: "y", Size: 4, TileSize: 2}}, ChannelSet{{Name: "u", Type: ChannelUint8}}),
+		NewLayer("c", DimensionSet{{Name: "x", Size: 3, TileSize: 2}}, ChannelSet{{Name: "u", Type: ChannelUint8}}),
+	}, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+	a := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 4), nil)
+	for i, kind := range map[int]AxisMismatch{1: AxisMismatchDimensions, 2: AxisMismatchExtent} {
+		b := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[i], 4), nil)
+		var mismatch ErrAxisMismatch
+		if err := p.AppendOperation(buf, "bad", OperationAdd, a, b, nil); !errors.As(err, &mismatch) || mismatch.Kind != kind {
+			t.Errorf("layer %d: expected a %s mismatch, got %v", i, kind, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package gopixi
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// A machine-readable identifier of the kind of problem a LintFinding reports, stable across releases so
+// that policies can allow or forbid particular findings.
+type LintCode string
+
+const (
+	LintTileTooLarge       LintCode = "tile-too-large"        // Stored tiles average far more than the target tile size.
+	LintTileTooSmall       LintCode = "tile-too-small"        // Stored tiles average far less than the target tile size.
+	LintMissingUnit        LintCode = "missing-unit"          // A numeric channel has no unit.
+	LintMissingFillValue   LintCode = "missing-fill-value"    // A layer with absent tiles has channels without fill values.
+	LintMissingStatistics  LintCode = "missing-statistics"    // A channel has no Min/Max statistics.
+	LintOffsetSizeTooLarge LintCode = "offset-size-too-large" // The file uses 8-byte offsets where 4-byte offsets suffice.
+	LintUncompressedFloat  LintCode = "uncompressed-float"    // A layer with floating point channels is not compressed.
+)
+
+// A problem found by Lint: a pattern that is valid but likely to make the file slower, larger, or harder
+// to interpret than it needs to be. The layer and channel are empty for findings about the whole file or
+// a whole layer respectively.
+type LintFinding struct {
+	Code       LintCode `json:"code"`
+	Layer      string   `json:"layer,omitempty"`
+	Channel    string   `json:"channel,omitempty"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion"`
+}
+
+func (f LintFinding) String() string {
+	location := ""
+	switch {
+	case f.Layer != "" && f.Channel != "":
+		location = fmt.Sprintf("layer '%s' channel '%s': ", f.Layer, f.Channel)
+	case f.Layer != "":
+		location = fmt.Sprintf("layer '%s': ", f.Layer)
+	}
+	return fmt.Sprintf("%s: %s%s (%s)", f.Code, location, f.Message, f.Suggestion)
+}
+
+type lintOptions struct {
+	targetTileBytes int
+	skip            []LintCode
+}
+
+type LintOption interface {
+	applyLint(*lintOptions)
+}
+
+type lintTargetTileBytesOption struct {
+	bytes int
+}
+
+func (o lintTargetTileBytesOption) applyLint(opts *lintOptions) {
+	opts.targetTileBytes = o.bytes
+}
+
+// Sets the stored tile size in bytes that tiles are measured against. Tiles averaging more than four
+// times or less than a sixteenth of the target are reported. Defaults to DefaultTargetTileBytes.
+func WithLintTargetTileBytes(bytes int) LintOption {
+	return lintTargetTileBytesOption{bytes: max(bytes, 1)}
+}
+
+type skipLintOption struct {
+	codes []LintCode
+}
+
+func (o skipLintOption) applyLint(opts *lintOptions) {
+	opts.skip = append(opts.skip, o.codes...)
+}
+
+// Disables the checks reporting findings with the given codes, for policies that accept those patterns.
+func WithoutLintChecks(codes ...LintCode) LintOption {
+	return skipLintOption{codes: codes}
+}
+
+// Checks the metadata of the file for patterns that are valid but worth fixing, each reported as a finding
+// with a machine-readable code and a suggested fix. Findings about the whole file come first, then those of
+// each layer in turn.
+func Lint(p *Pixi, opts ...LintOption) []LintFinding {
+	options := lintOptions{targetTileBytes: DefaultTargetTileBytes}
+	for _, opt := range opts {
+		opt.applyLint(&options)
+	}
+	findings := []LintFinding{}
+	report := func(code LintCode, layer string, channel string, suggestion string, format string, args ...any) {
+		if slices.Contains(options.skip, code) {
+			return
+		}
+		findings = append(findings, LintFinding{
+			Code:       code,
+			Layer:      layer,
+			Channel:    channel,
+			Message:    fmt.Sprintf(format, args...),
+			Suggestion: suggestion,
+		})
+	}
+
+	if p.Header.OffsetSize == OffsetSize8 {
+		if extent := p.largestOffset(); extent <= math.MaxUint32 {
+			report(LintOffsetSizeTooLarge, "", "", "rewrite the file with OffsetSize4 to shrink every layer index by half",
+				"8-byte offsets are used but the file only extends to byte %d", extent)
+		}
+	}
+
+	for _, layer := range p.Layers {
+		preview := layer.Name == PreviewLayerName
+
+		bytes := layer.Bytes(p.Header)
+		if bytes.Written > 0 {
+			average := bytes.Stored / int64(bytes.Written)
+			target := int64(options.targetTileBytes)
+			retile := "retile the layer"
+			if suggested := layer.suggestedTileSizes(options.targetTileBytes); !slices.Equal(suggested, layer.Dimensions.tileSizes()) {
+				retile = fmt.Sprintf("retile the layer with tile sizes %v", suggested)
+			}
+			if average > 4*target {
+				report(LintTileTooLarge, layer.Name, "", retile,
+					"stored tiles average %d bytes, more than four times the %d byte target", average, target)
+			} else if average < target/16 && layer.DiskTiles() > len(layer.Channels) {
+				report(LintTileTooSmall, layer.Name, "", retile,
+					"stored tiles average %d bytes, less than a sixteenth of the %d byte target", average, target)
+			}
+		}
+
+		if layer.Compression == CompressionNone && slices.ContainsFunc(layer.Channels, func(c Channel) bool { return isFloatChannel(c.Type) }) {
+			report(LintUncompressedFloat, layer.Name, "", "compress the layer, e.g. with CompressionZstd or CompressionProgressive",
+				"floating point channels are stored uncompressed")
+		}
+
+		absent := bytes.Written < bytes.Tiles
+		for _, channel := range layer.Channels {
+			numeric := channel.Type.Base() != ChannelBool
+			if numeric && !preview && channel.Unit == "" {
+				report(LintMissingUnit, layer.Name, channel.Name, "set the channel unit, e.g. with a UDUNITS string",
+					"channel has no unit")
+			}
+			if absent && !preview && channel.FillValue == nil {
+				report(LintMissingFillValue, layer.Name, channel.Name, "set a fill value so readers know what absent tiles hold",
+					"layer has %d of %d tiles absent but the channel has no fill value", bytes.Tiles-bytes.Written, bytes.Tiles)
+			}
+			if channel.Min == nil || channel.Max == nil {
+				report(LintMissingStatistics, layer.Name, channel.Name, "rewrite the layer or set its statistics with WithMinMax",
+					"channel has no Min/Max statistics")
+			}
+		}
+	}
+	return findings
+}
+
+// The offset of the end of the furthest part of the file referenced by its metadata.
+func (p *Pixi) largestOffset() int64 {
+	extent := max(p.LiveBytes(), p.Header.FirstTagsOffset)
+	for i, layer := range p.Layers {
+		extent = max(extent, p.layerHeaderOffset(i)+int64(layer.HeaderSize(p.Header)))
+		for tile, offset := range layer.TileOffsets {
+			if layer.TileBytes[tile] != 0 {
+				extent = max(extent, offset+layer.TileBytes[tile]+int64(p.Header.Checksum.Size()))
+			}
+		}
+	}
+	for _, t := range p.Tags {
+		extent = max(extent, t.NextTagsStart)
+	}
+	return extent
+}
+
+// The tile sizes ChooseTileSizes would choose for the dimensions of the layer, aiming for the given
+// stored tile size.
+func (l Layer) suggestedTileSizes(targetBytes int) []int {
+	dims := slices.Clone(l.Dimensions)
+	for i := range dims {
+		dims[i].TileSize = 0
+	}
+	return ChooseTileSizes(dims, l.Channels, l.Separated, l.Compression, targetBytes).tileSizes()
+}
+
+func (d DimensionSet) tileSizes() []int {
+	sizes := make([]int, len(d))
+	for i, dim := range d {
+		sizes[i] = dim.TileSize
+	}
+	return sizes
+}
+
+func isFloatChannel(t ChannelType) bool {
+	switch t.Base() {
+	case ChannelFloat8, ChannelFloat16, ChannelFloat32, ChannelFloat64, ChannelFloat128:
+		return true
+	}
+	return false
+}
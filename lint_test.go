@@ -0,0 +1,77 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func lintCodes(findings []LintFinding) []LintCode {
+	codes := []LintCode{}
+	for _, f := range findings {
+		codes = append(codes, f.Code)
+	}
+	return codes
+}
+
+func TestLintFindings(t *testing.T) {
+	sparse := NewLayer("sparse", DimensionSet{{Name: "x", Size: 64, TileSize: 8}},
+		ChannelSet{{Name: "height", Type: ChannelFloat32, Unit: "m"}, {Name: "mask", Type: ChannelBool}},
+		WithSparseTiles(), WithCompression(CompressionFlate))
+	sparse.Channels[0].FillValue = float32(0)
+	sparse.Channels[1].FillValue = false
+	raw := NewLayer("raw", DimensionSet{{Name: "x", Size: 64, TileSize: 64}},
+		ChannelSet{{Name: "temperature", Type: ChannelFloat64}})
+	file := writeTestPixi(t, buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize8), nil, []Layer{sparse, raw}, func(layer int, coord SampleCoordinate) Sample {
+		switch {
+		case layer == 1:
+			return Sample{float64(coord[0])}
+		case coord[0] < 8:
+			return Sample{float32(coord[0] + 1), true}
+		default:
+			return Sample{float32(0), false}
+		}
+	})
+	first := *file
+	first.Layers = file.Layers[:1]
+	if findings := Lint(&first, WithoutLintChecks(LintOffsetSizeTooLarge, LintTileTooSmall)); len(findings) != 0 {
+		t.Fatalf("expected no findings for the sparse layer, got %v", findings)
+	}
+	file.Layers[1].Channels[0].Min = nil
+
+	findings := Lint(file)
+	expected := []LintCode{LintOffsetSizeTooLarge, LintTileTooSmall, LintUncompressedFloat, LintMissingUnit, LintMissingStatistics}
+	if !slices.Equal(lintCodes(findings), expected) {
+		t.Fatalf("expected findings %v, got %v", expected, findings)
+	}
+	if findings[0].Layer != "" || findings[1].Layer != "sparse" || findings[2].Layer != "raw" || findings[3].Channel != "temperature" {
+		t.Errorf("findings reported at unexpected locations: %v", findings)
+	}
+
+	// fill values are only expected of layers with absent tiles
+	for _, layer := range file.Layers {
+		for i := range layer.Channels {
+			layer.Channels[i].FillValue = nil
+		}
+	}
+	missing := Lint(file, WithoutLintChecks(LintOffsetSizeTooLarge, LintUncompressedFloat, LintMissingUnit, LintMissingStatistics),
+		WithLintTargetTileBytes(1))
+	expected = []LintCode{LintTileTooLarge, LintMissingFillValue, LintMissingFillValue, LintTileTooLarge}
+	if !slices.Equal(lintCodes(missing), expected) {
+		t.Errorf("expected findings %v, got %v", expected, missing)
+	}
+}
+
+func TestLintCleanFile(t *testing.T) {
+	layer := NewLayer("heights", DimensionSet{{Name: "x", Size: 512}, {Name: "y", Size: 512}},
+		ChannelSet{{Name: "height", Type: ChannelFloat32, Unit: "m"}}, WithCompression(CompressionFlate),
+		WithTargetTileBytes(64*1024))
+	file := writeTestPixi(t, buffer.NewBuffer(10), NewHeader(binary.BigEndian, OffsetSize4), nil, []Layer{layer}, func(_ int, coord SampleCoordinate) Sample {
+		return Sample{float32(coord[0]*7919+coord[1]*104729) / 3}
+	})
+	if findings := Lint(file, WithLintTargetTileBytes(64*1024)); len(findings) != 0 {
+		t.Errorf("expected no findings, got %v", findings)
+	}
+}
@@ -0,0 +1,247 @@
+package gopixi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Names a group of related Pixi files that together make up one logical product, such as the bands of a
+// scene delivered as separate files, to be opened as a single Dataset with OpenManifest. Manifests are
+// stored as JSON.
+type Manifest struct {
+	Name string            `json:"name,omitempty"`
+	Tags map[string]string `json:"tags,omitempty"` // Tags of the product, taking precedence over those of its files.
+	// The names of dimensions shared by the files: every layer of the product with a dimension of one of
+	// these names must agree on its size and axis.
+	SharedDimensions []string `json:"sharedDimensions,omitempty"`
+	// Shared axes of the product by identifier, to which the axes of dimensions of its files may refer (see
+	// Axis.Ref). They take precedence over shared axes of the same identifier stored in the files.
+	Axes  map[string]ManifestAxis `json:"axes,omitempty"`
+	Files []ManifestFile          `json:"files"`
+}
+
+// A shared axis of a manifest. Values are given as JSON numbers and converted to the type of the axis, so
+// 64-bit and 128-bit integer values beyond the precision of float64 cannot be represented.
+type ManifestAxis struct {
+	Type        string    `json:"type"` // The name of the type of the axis values, such as "float64" or "int32".
+	Minimum     *float64  `json:"minimum,omitempty"`
+	Step        *float64  `json:"step,omitempty"`
+	Unit        string    `json:"unit,omitempty"`
+	Coordinates []float64 `json:"coordinates,omitempty"` // Explicit coordinates, in place of the minimum and step.
+	Calendar    string    `json:"calendar,omitempty"`    // The name of the calendar of a time axis, as in ParseCalendar.
+}
+
+// Converts the manifest axis to an Axis with values of its type.
+func (m ManifestAxis) Axis() (*Axis, error) {
+	axis := &Axis{Unit: m.Unit}
+	for t := ChannelInt8; t <= ChannelBFloat16; t++ {
+		if t.String() == m.Type {
+			axis.Type = t
+		}
+	}
+	if axis.Type == ChannelUnknown {
+		return nil, ErrFormat(fmt.Sprintf("unknown axis type '%s'", m.Type))
+	}
+	if m.Calendar != "" {
+		calendar, err := ParseCalendar(m.Calendar)
+		if err != nil {
+			return nil, err
+		}
+		axis.Calendar = calendar
+	}
+	switch {
+	case m.Coordinates != nil:
+		axis.Coordinates = make([]any, len(m.Coordinates))
+		for i, value := range m.Coordinates {
+			axis.Coordinates[i] = axis.Type.FromFloat64(value)
+		}
+	case m.Minimum != nil && m.Step != nil:
+		axis.Minimum = axis.Type.FromFloat64(*m.Minimum)
+		axis.Step = axis.Type.FromFloat64(*m.Step)
+	default:
+		return nil, ErrFormat("manifest axis must have coordinates or both minimum and step values")
+	}
+	return axis, nil
+}
+
+// The shared axes of the manifest, converted to Axis values by identifier.
+func (m Manifest) sharedAxes() (map[string]*Axis, error) {
+	axes := make(map[string]*Axis, len(m.Axes))
+	for id, manifestAxis := range m.Axes {
+		axis, err := manifestAxis.Axis()
+		if err != nil {
+			return nil, fmt.Errorf("axis '%s': %w", id, err)
+		}
+		if err := checkSharedAxis(id, axis); err != nil {
+			return nil, err
+		}
+		axes[id] = axis
+	}
+	return axes, nil
+}
+
+// A file of a manifest, and the layers of it that are part of the product.
+type ManifestFile struct {
+	// The path of the file, relative to the directory of the manifest, using forward slashes.
+	Path string `json:"path"`
+	// The names of the layers of the file included in the product, or every layer except the preview if empty.
+	Layers []string `json:"layers,omitempty"`
+}
+
+// Decodes a manifest from its JSON form.
+func ReadManifest(r io.Reader) (Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return Manifest{}, err
+	}
+	if len(m.Files) == 0 {
+		return Manifest{}, ErrFormat("manifest names no files")
+	}
+	for _, file := range m.Files {
+		if file.Path == "" {
+			return Manifest{}, ErrFormat("manifest file has no path")
+		}
+	}
+	if _, err := m.sharedAxes(); err != nil {
+		return Manifest{}, err
+	}
+	return m, nil
+}
+
+// Encodes the manifest in its JSON form.
+func (m Manifest) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
+
+// A layer of a Dataset, and the file of the manifest it is stored in.
+type DatasetLayer struct {
+	Layer
+	File  string // The path of the file holding the layer, as given in the manifest.
+	index int    // The index of the layer in its file.
+}
+
+// A group of Pixi files presented as a single product, as described by a Manifest. The files are opened
+// lazily and share one tile cache through a Catalog, so a Dataset is safe for concurrent use.
+type Dataset struct {
+	Manifest Manifest
+	Layers   []DatasetLayer // The layers of every file of the product, in manifest order.
+	tags     map[string]string
+	catalog  *Catalog
+}
+
+// Opens the product described by the manifest with the given name in the file system. The metadata of
+// every file is read to check that the layer names of the product are unique and that its shared dimensions
+// agree, but tiles are only read when requested. Paths of the manifest are resolved relative to its
+// directory, and its files must be seekable. The options configure the underlying Catalog.
+func OpenManifest(fsys fs.FS, name string, opts ...CatalogOption) (*Dataset, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ReadManifest(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Dir(name)
+	return OpenDataset(manifest, func(filePath string) (io.ReadSeekCloser, error) {
+		f, err := fsys.Open(path.Join(dir, filePath))
+		if err != nil {
+			return nil, err
+		}
+		seeker, ok := f.(io.ReadSeekCloser)
+		if !ok {
+			f.Close()
+			return nil, ErrUnsupported(fmt.Sprintf("manifest file '%s' is not seekable", filePath))
+		}
+		return seeker, nil
+	}, opts...)
+}
+
+// Opens the product described by the manifest, opening each of its files by path with the open function.
+func OpenDataset(manifest Manifest, open func(path string) (io.ReadSeekCloser, error), opts ...CatalogOption) (*Dataset, error) {
+	axes, err := manifest.sharedAxes()
+	if err != nil {
+		return nil, err
+	}
+	d := &Dataset{Manifest: manifest, tags: map[string]string{}, catalog: NewCatalog(open, opts...)}
+	shared := map[string]Dimension{}
+	for _, file := range manifest.Files {
+		p, err := d.catalog.Pixi(file.Path)
+		if err != nil {
+			d.catalog.Close()
+			return nil, fmt.Errorf("%s: %w", file.Path, err)
+		}
+		maps.Copy(d.tags, p.AllTags())
+		for index, layer := range p.Layers {
+			included := slices.Contains(file.Layers, layer.Name) || (len(file.Layers) == 0 && layer.Name != PreviewLayerName)
+			if !included {
+				continue
+			}
+			layer = resolveLayerAxes(layer, axes)
+			if slices.ContainsFunc(d.Layers, func(l DatasetLayer) bool { return l.Name == layer.Name }) {
+				d.catalog.Close()
+				return nil, ErrFormat(fmt.Sprintf("layer '%s' of %s is already part of the dataset", layer.Name, file.Path))
+			}
+			for _, dim := range layer.Dimensions {
+				if !slices.Contains(manifest.SharedDimensions, dim.Name) {
+					continue
+				}
+				if first, ok := shared[dim.Name]; !ok {
+					shared[dim.Name] = dim
+				} else if first.Size != dim.Size || !axesEqual(first.Axis, dim.Axis) {
+					d.catalog.Close()
+					return nil, ErrFormat(fmt.Sprintf("shared dimension '%s' of layer '%s' in %s is %v, expected %v", dim.Name, layer.Name, file.Path, dim, first))
+				}
+			}
+			d.Layers = append(d.Layers, DatasetLayer{Layer: layer, File: file.Path, index: index})
+		}
+		for _, name := range file.Layers {
+			if !slices.ContainsFunc(p.Layers, func(l Layer) bool { return l.Name == name }) {
+				d.catalog.Close()
+				return nil, ErrFormat(fmt.Sprintf("%s has no layer '%s'", file.Path, name))
+			}
+		}
+	}
+	maps.Copy(d.tags, manifest.Tags)
+	return d, nil
+}
+
+// The tags of the product: those of every file, with later files taking precedence over earlier ones, and
+// the tags of the manifest taking precedence over all.
+func (d *Dataset) Tags() map[string]string {
+	return maps.Clone(d.tags)
+}
+
+// The names of the layers of the product, in manifest order.
+func (d *Dataset) LayerNames() []string {
+	names := make([]string, len(d.Layers))
+	for i, layer := range d.Layers {
+		names[i] = layer.Name
+	}
+	return names
+}
+
+// Provides access to the layer of the product with the given name, for use with sample accessors such as
+// SampleAt. Its tiles are read from the file holding it through the shared tile cache.
+func (d *Dataset) Layer(name string) (TileAccessLayer, error) {
+	for _, layer := range d.Layers {
+		if layer.Name == name {
+			return d.catalog.Layer(layer.File, layer.index)
+		}
+	}
+	return nil, ErrFormat(fmt.Sprintf("dataset has no layer '%s'; it has %s", name, strings.Join(d.LayerNames(), ", ")))
+}
+
+// Closes every open file of the product.
+func (d *Dataset) Close() error {
+	return d.catalog.Close()
+}
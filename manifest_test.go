@@ -0,0 +1,112 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func manifestTestFile(t *testing.T, tags map[string]string, layers ...Layer) *fstest.MapFile {
+	t.Helper()
+	buf := buffer.NewBuffer(10)
+	writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), tags, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint16(coord[0] + 10*coord[1] + 100*layer)}
+	})
+	return &fstest.MapFile{Data: buf.Bytes()}
+}
+
+func manifestTestFS(t *testing.T, manifest Manifest) fstest.MapFS {
+	t.Helper()
+	grid := DimensionSet{{Name: "x", Size: 4, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}}
+	other := DimensionSet{{Name: "x", Size: 5, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}}
+	channels := ChannelSet{{Name: "v", Type: ChannelUint16}}
+	var encoded bytes.Buffer
+	if err := manifest.Write(&encoded); err != nil {
+		t.Fatal(err)
+	}
+	return fstest.MapFS{
+		"scene/red.pixi":   manifestTestFile(t, map[string]string{"sensor": "A", "band": "red"}, NewLayer("red", grid, channels)),
+		"scene/nir.pixi":   manifestTestFile(t, map[string]string{"band": "nir"}, NewLayer("scratch", other, channels), NewLayer("nir", grid, channels)),
+		"scene/scene.json": {Data: encoded.Bytes()},
+	}
+}
+
+func TestOpenManifest(t *testing.T) {
+	manifest := Manifest{
+		Name:             "scene",
+		Tags:             map[string]string{"product": "scene"},
+		SharedDimensions: []string{"x", "y"},
+		Files:            []ManifestFile{{Path: "red.pixi"}, {Path: "nir.pixi", Layers: []string{"nir"}}},
+	}
+	dataset, err := OpenManifest(manifestTestFS(t, manifest), "scene/scene.json", WithMaxOpenDatasets(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dataset.Close()
+
+	if names := dataset.LayerNames(); !slices.Equal(names, []string{"red", "nir"}) {
+		t.Errorf("unexpected layers %v", names)
+	}
+	if dataset.Layers[1].File != "nir.pixi" {
+		t.Errorf("expected nir layer to come from nir.pixi, got %s", dataset.Layers[1].File)
+	}
+	tags := dataset.Tags()
+	if tags["sensor"] != "A" || tags["band"] != "nir" || tags["product"] != "scene" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+	for _, name := range []string{"red", "nir", "red"} {
+		layer, err := dataset.Layer(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sample, err := SampleAt(layer, SampleCoordinate{3, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := uint16(23)
+		if name == "nir" {
+			want += 100
+		}
+		if sample[0] != want {
+			t.Errorf("layer %s: expected %d, got %v", name, want, sample[0])
+		}
+	}
+	if _, err := dataset.Layer("scratch"); err == nil {
+		t.Error("expected excluded layers to be missing from the dataset")
+	}
+}
+
+func TestOpenManifestErrors(t *testing.T) {
+	cases := map[string]Manifest{
+		"mismatched shared dimension": {SharedDimensions: []string{"x"}, Files: []ManifestFile{{Path: "red.pixi"}, {Path: "nir.pixi"}}},
+		"duplicate layer":             {Files: []ManifestFile{{Path: "red.pixi"}, {Path: "red.pixi"}}},
+		"missing layer":               {Files: []ManifestFile{{Path: "red.pixi", Layers: []string{"blue"}}}},
+		"missing file":                {Files: []ManifestFile{{Path: "blue.pixi"}}},
+		"no files":                    {},
+	}
+	for name, manifest := range cases {
+		if _, err := OpenManifest(manifestTestFS(t, manifest), "scene/scene.json"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	manifest := Manifest{Name: "scene", SharedDimensions: []string{"x"}, Files: []ManifestFile{{Path: "a.pixi", Layers: []string{"a"}}, {Path: "b/b.pixi"}}}
+	var buf bytes.Buffer
+	if err := manifest.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Name != manifest.Name || !slices.Equal(read.SharedDimensions, manifest.SharedDimensions) || len(read.Files) != 2 ||
+		read.Files[1].Path != "b/b.pixi" || !slices.Equal(read.Files[0].Layers, []string{"a"}) {
+		t.Errorf("manifest read as %+v", read)
+	}
+}
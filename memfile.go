@@ -0,0 +1,62 @@
+package gopixi
+
+import (
+	"bytes"
+	"io"
+	"slices"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+// A Pixi file held entirely in memory. Tags and layers are appended with the methods of its Pixi, passing
+// Stream as the stream to write to. A memory file is not safe for concurrent use.
+type MemoryFile struct {
+	*Pixi
+	buffer *buffer.Buffer
+}
+
+// Starts a new empty file in memory with the given header.
+func NewMemoryFile(header Header, opts ...CreateOption) (*MemoryFile, error) {
+	buf := buffer.NewBuffer(0)
+	p, err := Create(buf, header, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryFile{Pixi: p, buffer: buf}, nil
+}
+
+// Reads a file from its bytes, such as those returned by Bytes. The bytes are copied, so the slice may be
+// reused once FromBytes returns.
+func FromBytes(data []byte) (*MemoryFile, error) {
+	buf := buffer.NewBufferFrom(slices.Clone(data))
+	p, err := ReadPixi(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryFile{Pixi: p, buffer: buf}, nil
+}
+
+// The stream holding the file, for reading and appending to it with the methods of Pixi and of layers.
+func (m *MemoryFile) Stream() io.ReadWriteSeeker {
+	return m.buffer
+}
+
+// Opens the layer with the given index for reading, as in Pixi.ReadLayer, caching every tile of the layer.
+func (m *MemoryFile) Layer(layerIndex int, opts ...ReadOption) (TileAccessLayer, error) {
+	cacheSize := 1
+	if layerIndex >= 0 && layerIndex < len(m.Layers) {
+		cacheSize = max(cacheSize, m.Layers[layerIndex].DiskTiles())
+	}
+	return m.ReadLayer(m.buffer, layerIndex, cacheSize, opts...)
+}
+
+// The bytes of the file. The slice shares memory with the file, so it is only valid until the file is next
+// written to, and must not be modified.
+func (m *MemoryFile) Bytes() []byte {
+	return m.buffer.Bytes()
+}
+
+// Writes the bytes of the file to the writer, implementing io.WriterTo.
+func (m *MemoryFile) WriteTo(w io.Writer) (int64, error) {
+	return bytes.NewReader(m.buffer.Bytes()).WriteTo(w)
+}
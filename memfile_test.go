@@ -0,0 +1,63 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestMemoryFileRoundTrip(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 5, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}},
+		ChannelSet{{Name: "v", Type: ChannelInt32}}, WithCompression(CompressionFlate))
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			coord := writer.Coordinate()
+			writer.SetSample(Sample{int32(coord[0]*10 + coord[1])})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.AppendTags(file.Stream(), map[string]string{"kind": "memory"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := file.Bytes()
+	var out bytes.Buffer
+	if n, err := file.WriteTo(&out); err != nil || n != int64(len(data)) || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("WriteTo wrote %d bytes (%v), expected the %d bytes of the file", n, err, len(data))
+	}
+
+	read, err := FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clear(data) // the read file must not share the bytes it was read from
+	if read.AllTags()["kind"] != "memory" || len(read.Layers) != 1 {
+		t.Fatalf("unexpected file with tags %v and %d layers", read.AllTags(), len(read.Layers))
+	}
+	values, err := read.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for coord := range layer.Dimensions.SampleCoordinates() {
+		if sample, err := SampleAt(values, coord); err != nil || sample[0] != int32(coord[0]*10+coord[1]) {
+			t.Errorf("sample %v: got %v (%v)", coord, sample, err)
+		}
+	}
+	if _, err := read.Layer(1); err == nil {
+		t.Error("expected error for missing layer")
+	}
+}
+
+func TestFromBytesInvalid(t *testing.T) {
+	if _, err := FromBytes([]byte("not a pixi file")); err == nil {
+		t.Error("expected error reading invalid bytes")
+	}
+}
@@ -0,0 +1,167 @@
+package gopixi
+
+import (
+	"io"
+	"maps"
+	"slices"
+)
+
+// Determines what happens when metadata being merged from a reference file is already present, with a
+// different value, in the destination file.
+type MergePolicy int
+
+const (
+	MergeKeepExisting MergePolicy = iota // Only metadata missing from the destination is copied.
+	MergeOverwrite                       // Metadata from the reference replaces that of the destination.
+	MergeError                           // Conflicting metadata aborts the merge with ErrMergeConflict.
+)
+
+func (m MergePolicy) String() string {
+	switch m {
+	case MergeKeepExisting:
+		return "keep"
+	case MergeOverwrite:
+		return "overwrite"
+	case MergeError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Copies curated metadata from the reference file into this file, resolving conflicts according to the
+// given policy. Tags are merged at the file level, and axes, channel statistics, and units onto the layers,
+// dimensions, and channels of the same name. A MergeError failure leaves the destination untouched.
+func (p *Pixi) MergeMetadata(w io.WriteSeeker, ref *Pixi, policy MergePolicy) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "merge metadata"}
+	}
+
+	// merge tags
+	existingTags := p.AllTags()
+	newTags := map[string]string{}
+	for key, refVal := range ref.AllTags() {
+		existingVal, exists := existingTags[key]
+		if !exists {
+			newTags[key] = refVal
+		} else if existingVal != refVal {
+			switch policy {
+			case MergeOverwrite:
+				newTags[key] = refVal
+			case MergeError:
+				return ErrMergeConflict{Kind: "tag", Name: key}
+			}
+		}
+	}
+
+	// merge layer metadata
+	updatedLayers := map[int]Layer{}
+	for layerIndex, layer := range p.Layers {
+		refIndex := slices.IndexFunc(ref.Layers, func(l Layer) bool { return l.Name == layer.Name })
+		if refIndex < 0 {
+			continue
+		}
+		merged, changed, err := mergeLayerMetadata(layer, ref.Layers[refIndex], policy)
+		if err != nil {
+			return err
+		}
+		if changed {
+			updatedLayers[layerIndex] = merged
+		}
+	}
+
+	if len(newTags) > 0 {
+		err := p.AppendTags(w, newTags)
+		if err != nil {
+			return err
+		}
+	}
+	for _, layerIndex := range slices.Sorted(maps.Keys(updatedLayers)) {
+		err := p.UpdateLayerHeader(w, layerIndex, updatedLayers[layerIndex])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeLayerMetadata(layer Layer, ref Layer, policy MergePolicy) (Layer, bool, error) {
+	changed := false
+	layer.Dimensions = slices.Clone(layer.Dimensions)
+	layer.Channels = slices.Clone(layer.Channels)
+
+	for dimIndex, dim := range layer.Dimensions {
+		refDimIndex := slices.IndexFunc(ref.Dimensions, func(d Dimension) bool { return d.Name == dim.Name })
+		if refDimIndex < 0 || ref.Dimensions[refDimIndex].Axis == nil {
+			continue
+		}
+		refAxis := ref.Dimensions[refDimIndex].Axis
+		if dim.Axis == nil {
+			layer.Dimensions[dimIndex].Axis = refAxis
+			changed = true
+		} else if !axesEqual(dim.Axis, refAxis) {
+			switch policy {
+			case MergeOverwrite:
+				layer.Dimensions[dimIndex].Axis = refAxis
+				changed = true
+			case MergeError:
+				return layer, false, ErrMergeConflict{Kind: "axis", Name: layer.Name + "/" + dim.Name}
+			}
+		}
+	}
+
+	for channelIndex, channel := range layer.Channels {
+		refChannelIndex := ref.Channels.Index(channel.Name)
+		if refChannelIndex < 0 || ref.Channels[refChannelIndex].Type != channel.Type {
+			continue
+		}
+		refChannel := ref.Channels[refChannelIndex]
+		name := layer.Name + "/" + channel.Name
+		minVal, minChanged, err := mergeValue(channel.Min, refChannel.Min, policy, "channel min", name)
+		if err != nil {
+			return layer, false, err
+		}
+		maxVal, maxChanged, err := mergeValue(channel.Max, refChannel.Max, policy, "channel max", name)
+		if err != nil {
+			return layer, false, err
+		}
+		unit, unitChanged, err := mergeValue(optionalString(channel.Unit), optionalString(refChannel.Unit), policy, "channel unit", name)
+		if err != nil {
+			return layer, false, err
+		}
+		layer.Channels[channelIndex].Min = minVal
+		layer.Channels[channelIndex].Max = maxVal
+		layer.Channels[channelIndex].Unit, _ = unit.(string)
+		changed = changed || minChanged || maxChanged || unitChanged
+	}
+
+	return layer, changed, nil
+}
+
+func mergeValue(existing any, ref any, policy MergePolicy, kind string, name string) (any, bool, error) {
+	if ref == nil {
+		return existing, false, nil
+	}
+	if existing == nil {
+		return ref, true, nil
+	}
+	if existing == ref {
+		return existing, false, nil
+	}
+	switch policy {
+	case MergeOverwrite:
+		return ref, true, nil
+	case MergeError:
+		return existing, false, ErrMergeConflict{Kind: kind, Name: name}
+	default:
+		return existing, false, nil
+	}
+}
+
+// Treats an empty string as a missing value when merging.
+func optionalString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
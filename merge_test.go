@@ -0,0 +1,104 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func newMergeTestPixi(t *testing.T, tags map[string]string, axis *Axis, channelMax any) (*buffer.Buffer, *Pixi) {
+	t.Helper()
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{
+		NewLayer("first", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}}),
+		NewLayer("second", DimensionSet{{Name: "x", Size: 4, TileSize: 4, Axis: axis}}, ChannelSet{{Name: "w", Type: ChannelUint8}}),
+	}
+	writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), tags, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+	pixi, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channelMax != nil {
+		pixi.Layers[1].Channels[0].Max = channelMax
+	}
+	return buf, pixi
+}
+
+func TestMergeMetadataKeepExisting(t *testing.T) {
+	refAxis := &Axis{Type: ChannelFloat64, Minimum: 1.0, Step: 0.5, Unit: "m"}
+	_, ref := newMergeTestPixi(t, map[string]string{"shared": "ref", "curated": "yes"}, refAxis, uint8(200))
+	ref.Layers[1].Channels[0].Unit = "K"
+	dstBuf, dst := newMergeTestPixi(t, map[string]string{"shared": "dst"}, nil, nil)
+
+	err := dst.MergeMetadata(dstBuf, ref, MergeKeepExisting)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadPixi(buffer.NewBufferFrom(dstBuf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := read.AllTags()
+	if tags["shared"] != "dst" || tags["curated"] != "yes" {
+		t.Errorf("unexpected merged tags %v", tags)
+	}
+	if axis := read.Layers[1].Dimensions[0].Axis; axis == nil || !axesEqual(axis, refAxis) {
+		t.Errorf("expected axis to be merged, got %v", axis)
+	}
+	if read.Layers[1].Channels[0].Max != uint8(3) {
+		t.Errorf("expected existing channel max to be kept, got %v", read.Layers[1].Channels[0].Max)
+	}
+	if read.Layers[1].Channels[0].Unit != "K" {
+		t.Errorf("expected channel unit to be merged, got '%s'", read.Layers[1].Channels[0].Unit)
+	}
+
+	// tile data must still be readable after the header was relocated
+	data := NewFifoCacheReadLayer(buffer.NewBufferFrom(dstBuf.Bytes()), read.Header, read.Layers[1], 1)
+	sample, err := SampleAt(data, SampleCoordinate{3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample[0] != uint8(3) {
+		t.Errorf("expected sample 3, got %v", sample[0])
+	}
+}
+
+func TestMergeMetadataOverwriteAndError(t *testing.T) {
+	refAxis := &Axis{Type: ChannelFloat64, Minimum: 1.0, Step: 0.5, Unit: "m"}
+	dstAxis := &Axis{Type: ChannelFloat64, Minimum: 0.0, Step: 1.0, Unit: "m"}
+	_, ref := newMergeTestPixi(t, map[string]string{"shared": "ref"}, refAxis, uint8(200))
+
+	dstBuf, dst := newMergeTestPixi(t, map[string]string{"shared": "dst"}, dstAxis, nil)
+	before := append([]byte{}, dstBuf.Bytes()...)
+	err := dst.MergeMetadata(dstBuf, ref, MergeError)
+	var conflict ErrMergeConflict
+	if !errors.As(err, &conflict) || conflict.Kind != "tag" {
+		t.Errorf("expected tag merge conflict, got %v", err)
+	}
+	if string(before) != string(dstBuf.Bytes()) {
+		t.Error("expected failed merge to leave destination untouched")
+	}
+
+	err = dst.MergeMetadata(dstBuf, ref, MergeOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPixi(buffer.NewBufferFrom(dstBuf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.AllTags()["shared"] != "ref" {
+		t.Errorf("expected reference tag to overwrite, got %v", read.AllTags())
+	}
+	if axis := read.Layers[1].Dimensions[0].Axis; axis == nil || !axesEqual(axis, refAxis) {
+		t.Errorf("expected reference axis to overwrite, got %v", axis)
+	}
+	if read.Layers[1].Channels[0].Max != uint8(200) {
+		t.Errorf("expected reference channel max to overwrite, got %v", read.Layers[1].Channels[0].Max)
+	}
+}
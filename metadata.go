@@ -0,0 +1,233 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// Stages changes to the metadata of a file (its tags and attributes, and the names, attributes,
+// georeferences, and channel ranges of its layers) to be committed together by Pixi.EditMetadata. Staged
+// changes are validated as they are made, and are seen by later changes and by Layer, but nothing is
+// written to the file until the edit function returns.
+type MetadataEditor struct {
+	pixi     *Pixi
+	layers   []Layer
+	sections []TagSection
+	pending  TagSection // tags and attributes set during the edit, committed as a new tag section
+	edited   int        // one more than the index of the last layer changed, or zero if none were
+	retagged bool       // whether the tags or attributes of the file were changed
+}
+
+// Edits the metadata of the file in a single transaction: the changes staged by the edit function with the
+// MetadataEditor are committed at once if it returns nil, and discarded if it returns an error. A commit that
+// is interrupted leaves the file as it was before the edit. Tile data is untouched.
+func (p *Pixi) EditMetadata(w io.WriteSeeker, edit func(m *MetadataEditor) error) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "edit metadata"}
+	}
+	m := &MetadataEditor{
+		pixi:    p,
+		layers:  slices.Clone(p.Layers),
+		pending: TagSection{Tags: map[string]string{}, Attributes: map[string]any{}},
+	}
+	for _, section := range p.Tags {
+		section.Tags = maps.Clone(section.Tags)
+		section.Attributes = maps.Clone(section.Attributes)
+		m.sections = append(m.sections, section)
+	}
+	if err := edit(m); err != nil {
+		return err
+	}
+	if m.edited == 0 && !m.retagged {
+		return nil
+	}
+	return m.commit(w)
+}
+
+// The layer with the given index, with the changes staged so far.
+func (m *MetadataEditor) Layer(layerIndex int) (Layer, error) {
+	if layerIndex < 0 || layerIndex >= len(m.layers) {
+		return Layer{}, ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	return m.layers[layerIndex], nil
+}
+
+// Stages the change of the layer with the given index made by the function, unless it returns an error.
+func (m *MetadataEditor) stage(layerIndex int, change func(l Layer) (Layer, error)) error {
+	layer, err := m.Layer(layerIndex)
+	if err != nil {
+		return err
+	}
+	if layer, err = change(layer); err != nil {
+		return err
+	}
+	m.layers[layerIndex] = layer
+	m.edited = max(m.edited, layerIndex+1)
+	return nil
+}
+
+// Stages renaming the layer with the given index.
+func (m *MetadataEditor) RenameLayer(layerIndex int, newName string) error {
+	return m.stage(layerIndex, func(l Layer) (Layer, error) {
+		l.Name = newName
+		return l, nil
+	})
+}
+
+// Stages renaming a channel of the layer with the given index, as by Pixi.RenameChannel.
+func (m *MetadataEditor) RenameChannel(layerIndex int, oldName string, newName string) error {
+	return m.stage(layerIndex, func(l Layer) (Layer, error) { return l.renamedChannel(oldName, newName) })
+}
+
+// Stages renaming a dimension of the layer with the given index, as by Pixi.RenameDimension.
+func (m *MetadataEditor) RenameDimension(layerIndex int, oldName string, newName string) error {
+	return m.stage(layerIndex, func(l Layer) (Layer, error) { return l.renamedDimension(oldName, newName) })
+}
+
+// Stages setting attributes of the layer with the given index, as by Pixi.SetLayerAttributes.
+func (m *MetadataEditor) SetLayerAttributes(layerIndex int, attributes map[string]any) error {
+	return m.stage(layerIndex, func(l Layer) (Layer, error) { return l.withAttributes(attributes) })
+}
+
+// Stages setting the georeference of the layer with the given index, as by Pixi.SetLayerGeoreference.
+func (m *MetadataEditor) SetLayerGeoreference(layerIndex int, georeference *Georeference) error {
+	return m.stage(layerIndex, func(l Layer) (Layer, error) { return l.withGeoreference(georeference) })
+}
+
+// Stages setting the Min and Max statistics of the named channel of the layer with the given index, such
+// as to ranges computed by a separate pass over the samples. Either may be nil to clear it.
+func (m *MetadataEditor) SetChannelRange(layerIndex int, channel string, minimum any, maximum any) error {
+	return m.stage(layerIndex, func(l Layer) (Layer, error) {
+		channelIndex := l.Channels.Index(channel)
+		if channelIndex < 0 {
+			return l, ErrChannelNotFound{ChannelName: channel}
+		}
+		c := l.Channels[channelIndex]
+		for _, value := range []any{minimum, maximum} {
+			if value == nil {
+				continue
+			}
+			if err := c.CheckValue(value); err != nil {
+				return l, err
+			}
+		}
+		if minimum != nil && maximum != nil && c.Type.CompareValues(minimum, maximum) > 0 {
+			return l, ErrFormat(fmt.Sprintf("minimum %v of channel '%s' is greater than its maximum %v", minimum, channel, maximum))
+		}
+		l.Channels = slices.Clone(l.Channels)
+		l.Channels[channelIndex].Min = minimum
+		l.Channels[channelIndex].Max = maximum
+		return l, nil
+	})
+}
+
+// Stages setting tags of the file, replacing those of the same name.
+func (m *MetadataEditor) SetTags(tags map[string]string) {
+	maps.Copy(m.pending.Tags, tags)
+	m.retagged = true
+}
+
+// Stages removing the named tags from every tag section of the file.
+func (m *MetadataEditor) DeleteTags(names ...string) {
+	for _, name := range names {
+		for _, section := range m.sections {
+			delete(section.Tags, name)
+		}
+		delete(m.pending.Tags, name)
+	}
+	m.retagged = true
+}
+
+// Stages setting attributes of the file, replacing those of the same name, and removing those given a nil
+// value from every tag section of the file.
+func (m *MetadataEditor) SetAttributes(attributes map[string]any) error {
+	set := map[string]any{}
+	for name, value := range attributes {
+		if value != nil {
+			set[name] = value
+		}
+	}
+	if err := checkAttributes(set); err != nil {
+		return err
+	}
+	for name, value := range attributes {
+		if value != nil {
+			continue
+		}
+		for _, section := range m.sections {
+			delete(section.Attributes, name)
+		}
+		delete(m.pending.Attributes, name)
+	}
+	maps.Copy(m.pending.Attributes, set)
+	m.retagged = true
+	return nil
+}
+
+// Writes the staged metadata to the end of the file and switches the file header to it.
+func (m *MetadataEditor) commit(w io.WriteSeeker) error {
+	p := m.pixi
+	start, err := w.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	// lay out the new tag sections, then the headers of the edited layers, from the end of the file
+	firstTags, sections := p.Header.FirstTagsOffset, p.Tags
+	offset := start
+	if m.retagged {
+		sections = slices.Clone(m.sections)
+		if len(m.pending.Tags) > 0 || len(m.pending.Attributes) > 0 {
+			sections = append(sections, m.pending)
+		}
+		firstTags = 0
+		if len(sections) > 0 {
+			firstTags = offset
+		}
+		for i := range sections {
+			offset += int64(sections[i].DiskSize(p.Header))
+			sections[i].NextTagsStart = 0
+			if i < len(sections)-1 {
+				sections[i].NextTagsStart = offset
+			}
+		}
+	}
+	firstLayer, layers := p.Header.FirstLayerOffset, slices.Clone(m.layers)
+	if m.edited > 0 {
+		firstLayer = offset
+		for i := range m.edited {
+			offset += int64(layers[i].HeaderSize(p.Header) + max(p.HeaderPadding, 0))
+			layers[i].NextLayerStart = p.Layers[i].NextLayerStart
+			if i < m.edited-1 {
+				layers[i].NextLayerStart = offset
+			}
+		}
+	}
+
+	// write them, and only then point the file header at them
+	if m.retagged {
+		for _, section := range sections {
+			if err := section.Write(w, p.Header); err != nil {
+				return err
+			}
+		}
+	}
+	for _, layer := range layers[:m.edited] {
+		if err := layer.WriteHeader(w, p.Header); err != nil {
+			return err
+		}
+		if err := p.writeHeaderPadding(w); err != nil {
+			return err
+		}
+	}
+	if err := p.Header.OverwriteOffsets(w, firstLayer, firstTags); err != nil {
+		return err
+	}
+	p.Tags, p.Layers = sections, layers
+	for i := range m.edited {
+		p.TileCache.evictLayer(p, i)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package gopixi
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+// A stream that fails to write to the header at the start of the file, as if the process crashed just
+// before the header were written.
+type headerlessWriter struct {
+	io.WriteSeeker
+	headerSize int64
+}
+
+func (w headerlessWriter) Write(p []byte) (int, error) {
+	if pos, _ := w.Seek(0, io.SeekCurrent); pos < w.headerSize {
+		return 0, errors.New("interrupted")
+	}
+	return w.WriteSeeker.Write(p)
+}
+
+func TestEditMetadata(t *testing.T) {
+	buf, pixi := newEditTestPixi(t)
+	if err := pixi.AppendTags(buf, map[string]string{"title": "edits", "draft": "yes"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.AppendAttributes(buf, map[string]any{"scale": 0.5, "stale": true}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := pixi.EditMetadata(buf, func(m *MetadataEditor) error {
+		if err := m.RenameLayer(1, "renamed"); err != nil {
+			return err
+		}
+		if err := m.RenameChannel(0, "a", "alpha"); err != nil {
+			return err
+		}
+		if err := m.RenameDimension(0, "y", "row"); err != nil {
+			return err
+		}
+		if err := m.SetLayerAttributes(0, map[string]any{"source": "edit test"}); err != nil {
+			return err
+		}
+		// later changes see those staged before them
+		if err := m.SetChannelRange(0, "alpha", uint16(0), uint16(18)); err != nil {
+			return err
+		}
+		m.SetTags(map[string]string{"title": "edited"})
+		m.DeleteTags("draft")
+		return m.SetAttributes(map[string]any{"scale": 0.25, "stale": nil})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*Pixi{pixi, read} {
+		if p.Layers[1].Name != "renamed" || p.Layers[0].Channels[0].Name != "alpha" || p.Layers[0].Dimensions[1].Name != "row" {
+			t.Errorf("expected the layers to be renamed, got %s, %s, %s", p.Layers[1].Name, p.Layers[0].Channels[0].Name, p.Layers[0].Dimensions[1].Name)
+		}
+		if channel := p.Layers[0].Channels[0]; channel.Min != uint16(0) || channel.Max != uint16(18) {
+			t.Errorf("expected the channel range to be set, got %v to %v", channel.Min, channel.Max)
+		}
+		if p.Layers[0].Attributes["source"] != "edit test" {
+			t.Errorf("expected the layer attributes to be set, got %v", p.Layers[0].Attributes)
+		}
+		if tags := p.AllTags(); !reflect.DeepEqual(tags, map[string]string{"title": "edited"}) {
+			t.Errorf("unexpected tags %v", tags)
+		}
+		if attributes := p.AllAttributes(); !reflect.DeepEqual(attributes, map[string]any{"scale": 0.25}) {
+			t.Errorf("unexpected attributes %v", attributes)
+		}
+	}
+	data := NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), read.Header, read.Layers[0], 4)
+	if sample, err := SampleAt(data, SampleCoordinate{6, 3}); err != nil || sample[0] != uint16(18) {
+		t.Errorf("expected the tiles to be untouched, got %v (%v)", sample, err)
+	}
+
+	// the superseded metadata is dead space, reclaimed by compacting
+	compacted := buffer.NewBuffer(10)
+	compactPixi, err := Compact(buffer.NewBufferFrom(buf.Bytes()), compacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compacted.Bytes()) >= len(buf.Bytes()) || compactPixi.Layers[1].Name != "renamed" {
+		t.Errorf("expected a smaller compacted file of %d bytes with the edits, got %d bytes", len(buf.Bytes()), len(compacted.Bytes()))
+	}
+}
+
+func TestEditMetadataAtomic(t *testing.T) {
+	buf, pixi := newEditTestPixi(t)
+	before := len(buf.Bytes())
+
+	// an edit that fails stages nothing
+	err := pixi.EditMetadata(buf, func(m *MetadataEditor) error {
+		if err := m.RenameLayer(0, "partial"); err != nil {
+			return err
+		}
+		return m.RenameChannel(0, "a", "flag")
+	})
+	if err == nil {
+		t.Fatal("expected renaming to an existing channel to fail")
+	}
+	if len(buf.Bytes()) != before || pixi.Layers[0].Name != "data" {
+		t.Errorf("expected a failed edit to write nothing")
+	}
+	if err := pixi.EditMetadata(buf, func(m *MetadataEditor) error { return m.SetChannelRange(0, "a", uint16(5), uint16(1)) }); err == nil {
+		t.Error("expected an inverted channel range to be rejected")
+	}
+
+	// a commit interrupted before the header is switched leaves the file as it was
+	err = pixi.EditMetadata(headerlessWriter{buf, int64(pixi.Header.DiskSize())}, func(m *MetadataEditor) error {
+		m.SetTags(map[string]string{"title": "lost"})
+		return m.RenameLayer(1, "lost")
+	})
+	if err == nil {
+		t.Fatal("expected the interrupted commit to fail")
+	}
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.AllTags()) != 0 || read.Layers[1].Name != "other" {
+		t.Errorf("expected none of the interrupted changes, got tags %v and layer %s", read.AllTags(), read.Layers[1].Name)
+	}
+
+	pixi.ReadOnly = true
+	if err := pixi.EditMetadata(buf, func(m *MetadataEditor) error { return nil }); !errors.As(err, new(ErrReadOnly)) {
+		t.Errorf("expected editing a read-only file to fail, got %v", err)
+	}
+}
@@ -0,0 +1,864 @@
+package gopixi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The external data types of the NetCDF classic format.
+const (
+	ncByte   uint32 = 1
+	ncChar   uint32 = 2
+	ncShort  uint32 = 3
+	ncInt    uint32 = 4
+	ncFloat  uint32 = 5
+	ncDouble uint32 = 6
+)
+
+const (
+	ncDimensionTag uint32 = 0x0A
+	ncVariableTag  uint32 = 0x0B
+	ncAttributeTag uint32 = 0x0C
+	ncStreaming    uint32 = 0xFFFFFFFF // the number of records of a file still being written
+)
+
+type ncDimension struct {
+	name string
+	size int64 // zero for the record dimension
+}
+
+type ncAttribute struct {
+	name   string
+	kind   uint32
+	values any // a string for ncChar, otherwise a slice of the Go type of the values
+}
+
+type ncVariable struct {
+	name       string
+	dimensions []int
+	attributes []ncAttribute
+	kind       uint32
+	size       int64 // the padded size of the variable, or of one record of it for record variables
+	begin      int64
+}
+
+// The header of a NetCDF classic (CDF-1) or 64-bit offset (CDF-2) file.
+type ncFile struct {
+	version    byte
+	records    int64
+	dimensions []ncDimension
+	attributes []ncAttribute
+	variables  []ncVariable
+}
+
+// Appends a layer to the end of the file for every variable of the NetCDF classic or 64-bit offset format
+// file read from r, with the values of the variable in a single channel of the same name and its dimensions
+// in reverse, so that temp(time, lat, lon) becomes a layer 'temp' over lon, lat, and time. Evenly spaced
+// coordinate variables become axes, "units" and "_FillValue" attributes become channel units and fill
+// values, and other attributes become tags. NetCDF-4 and CDF-5 files are unsupported.
+func (p *Pixi) AppendNetCDF(w io.WriteSeeker, r io.ReaderAt, opts ...LayerOption) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append NetCDF"}
+	}
+	nc, err := readNetCDFHeader(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err != nil {
+		return err
+	}
+
+	tags := map[string]string{}
+	for _, attr := range nc.attributes {
+		tags[attr.name] = attr.text()
+	}
+	axes := map[int]*Axis{}
+	for _, v := range nc.variables {
+		if !nc.isCoordinate(v) {
+			continue
+		}
+		axis, err := nc.coordinateAxis(r, v)
+		if err != nil {
+			return err
+		}
+		if axis == nil {
+			continue
+		}
+		axes[v.dimensions[0]] = axis
+		for _, attr := range v.attributes {
+			if attr.kind != ncChar || (attr.name != "units" && (attr.name != "calendar" || !isCalendar(attr.values.(string)))) {
+				tags[v.name+":"+attr.name] = attr.text()
+			}
+		}
+	}
+
+	for _, v := range nc.variables {
+		if v.kind == ncChar || (nc.isCoordinate(v) && axes[v.dimensions[0]] != nil) {
+			continue
+		}
+		channel := Channel{Name: v.name, Type: v.channelType()}
+		for _, attr := range v.attributes {
+			switch {
+			case attr.name == "units" && attr.kind == ncChar:
+				channel.Unit = attr.values.(string)
+			case attr.name == "_FillValue" && attr.kind == v.kind && attr.count() == 1:
+				channel.FillValue = attr.value(0)
+			default:
+				tags[v.name+":"+attr.name] = attr.text()
+			}
+		}
+		if len(v.dimensions) == 0 {
+			data := make([]byte, ncTypeSize(v.kind))
+			if err := readFullAt(r, data, v.begin); err != nil {
+				return err
+			}
+			tags[v.name] = fmt.Sprint(ncValue(v.kind, data))
+			continue
+		}
+
+		dims := make(DimensionSet, len(v.dimensions))
+		for i, d := range v.dimensions {
+			dim := nc.dimensions[d]
+			size := dim.size
+			if size == 0 {
+				size = nc.records
+			}
+			dims[len(dims)-1-i] = Dimension{Name: dim.name, Size: int(size), Axis: axes[d]}
+		}
+		if dims.Samples() == 0 {
+			continue
+		}
+		layer := NewLayer(v.name, dims, ChannelSet{channel}, opts...)
+		if err := p.appendNetCDFVariable(w, r, nc, v, layer); err != nil {
+			return err
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return p.AppendTags(w, tags)
+}
+
+// Writes the values of the variable from r as the single channel of the layer, whose dimensions are those
+// of the variable in reverse.
+func (p *Pixi) appendNetCDFVariable(w io.WriteSeeker, r io.ReaderAt, nc ncFile, v ncVariable, layer Layer) error {
+	last := len(layer.Dimensions) - 1
+	valueSize := ncTypeSize(v.kind)
+	slabSize := valueSize
+	for _, dim := range layer.Dimensions[:last] {
+		slabSize *= int64(dim.Size)
+	}
+	slabStride := slabSize
+	if nc.isRecord(v) {
+		slabStride = nc.recordSize()
+	}
+
+	// the slabs along the last dimension held by the tiles being written, read as their tiles are reached
+	loaded, slabs := -1, []byte(nil)
+	tileSize := layer.Dimensions[last].TileSize
+	writer := NewTileOrderWriteIterator(w, p.Header, layer)
+	return p.AppendIterativeLayer(w, layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			coord := writer.Coordinate()
+			if tile := coord[last] / tileSize; tile != loaded {
+				first, end := tile*tileSize, min((tile+1)*tileSize, layer.Dimensions[last].Size)
+				slabs = make([]byte, int64(end-first)*slabSize)
+				for s := first; s < end; s++ {
+					at := int64(s-first) * slabSize
+					if err := readFullAt(r, slabs[at:at+slabSize], v.begin+int64(s)*slabStride); err != nil {
+						return err
+					}
+				}
+				loaded = tile
+			}
+			// the first dimension changes the most frequently within each slab
+			index := int64(coord[last] % tileSize)
+			for d := last; d > 0; d-- {
+				index = index*int64(layer.Dimensions[d-1].Size) + int64(coord[d-1])
+			}
+			index *= valueSize
+			writer.SetChannel(0, ncValue(v.kind, slabs[index:]))
+		}
+		return nil
+	})
+}
+
+// Writes the layers of the file to w in the NetCDF classic format as the inverse of AppendNetCDF, with a
+// variable for every channel. Dimensions are shared between layers by name and must have the same size in
+// each. Channels are stored as the smallest NetCDF type holding all of their values; 64-bit and 128-bit
+// integer channels cannot be written.
+func (p *Pixi) WriteNetCDF(r io.ReadSeeker, w io.Writer) error {
+	nc, sources, err := p.netCDFLayout()
+	if err != nil {
+		return err
+	}
+	var header bytes.Buffer
+	if err := nc.writeHeader(&header); err != nil {
+		return err
+	}
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriter(w)
+	for i, v := range nc.variables {
+		source := sources[i]
+		var err error
+		if source.axis != nil {
+			err = writeNetCDFAxis(buffered, v, source.axis, int(nc.dimensions[v.dimensions[0]].size))
+		} else {
+			err = p.writeNetCDFChannel(r, buffered, v, source.layer, source.channel)
+		}
+		if err != nil {
+			return err
+		}
+		if pad := v.size - ncTypeSize(v.kind)*nc.elements(v); pad > 0 {
+			if _, err := buffered.Write(make([]byte, pad)); err != nil {
+				return err
+			}
+		}
+	}
+	return buffered.Flush()
+}
+
+// Where the values of a NetCDF variable written by WriteNetCDF come from: an axis, or a channel of a layer.
+type ncSource struct {
+	axis    *Axis
+	layer   Layer
+	channel int
+}
+
+// A dimension shared by name between the layers of a file, as written to the formats (NetCDF and Zarr) that
+// name the dimensions of their variables.
+type sharedDimension struct {
+	name string
+	size int
+	axis *Axis // from the first layer giving the dimension a complete axis
+	held bool  // whether a one-dimensional layer holds the values of the dimension in a channel of its name
+}
+
+// The values of the dimension to write as a coordinate variable, or nil if it has no axis or a channel
+// already holds them.
+func (d sharedDimension) writtenAxis() *Axis {
+	if d.held {
+		return nil
+	}
+	return d.axis
+}
+
+// The dimensions of the layers of the file in the order they first appear, which must have the same size in
+// every layer naming them.
+func (p *Pixi) sharedDimensions() ([]sharedDimension, error) {
+	dims := []sharedDimension{}
+	index := map[string]int{}
+	for _, layer := range p.Layers {
+		for _, dim := range layer.Dimensions {
+			d, ok := index[dim.Name]
+			if !ok {
+				d = len(dims)
+				index[dim.Name] = d
+				dims = append(dims, sharedDimension{name: dim.Name, size: dim.Size})
+			} else if dims[d].size != dim.Size {
+				return nil, ErrFormat(fmt.Sprintf("dimension '%s' of layer '%s' has size %d, but %d in an earlier layer", dim.Name, layer.Name, dim.Size, dims[d].size))
+			}
+			if dims[d].axis == nil && dim.Axis != nil && dim.Axis.Type.Base() != ChannelUnknown && (dim.Axis.Minimum != nil || dim.Axis.Coordinates != nil) {
+				dims[d].axis = dim.Axis
+			}
+		}
+		if len(layer.Dimensions) == 1 && slices.ContainsFunc(layer.Channels, func(c Channel) bool { return c.Name == layer.Dimensions[0].Name }) {
+			dims[index[layer.Dimensions[0].Name]].held = true
+		}
+	}
+	return dims, nil
+}
+
+// Removes the tags named "variable:attribute" for the named variable from the tags, returning their values
+// by attribute name.
+// Whether the name is that of a calendar, as accepted by ParseCalendar.
+func isCalendar(name string) bool {
+	_, err := ParseCalendar(name)
+	return err == nil
+}
+
+func variableTags(tags map[string]string, variable string) map[string]string {
+	values := map[string]string{}
+	for key, value := range tags {
+		if attr, ok := strings.CutPrefix(key, variable+":"); ok && attr != "" {
+			values[attr] = value
+			delete(tags, key)
+		}
+	}
+	return values
+}
+
+// Lays out the header of the NetCDF file that WriteNetCDF writes, returning it with the sources of the
+// values of its variables.
+func (p *Pixi) netCDFLayout() (ncFile, []ncSource, error) {
+	nc := ncFile{version: 1}
+	dims, err := p.sharedDimensions()
+	if err != nil {
+		return ncFile{}, nil, err
+	}
+	dimensionIndex := map[string]int{}
+	for _, dim := range dims {
+		dimensionIndex[dim.name] = len(nc.dimensions)
+		nc.dimensions = append(nc.dimensions, ncDimension{name: dim.name, size: int64(dim.size)})
+	}
+
+	tags := p.AllTags()
+	attributes := func(name string) []ncAttribute {
+		values := variableTags(tags, name)
+		attrs := []ncAttribute{}
+		for _, attr := range slices.Sorted(maps.Keys(values)) {
+			attrs = append(attrs, ncAttribute{name: attr, kind: ncChar, values: values[attr]})
+		}
+		return attrs
+	}
+
+	sources := []ncSource{}
+	names := map[string]bool{}
+	for _, dim := range dims {
+		axis := dim.writtenAxis()
+		if axis == nil {
+			continue
+		}
+		kind, err := ncTypeOf(axis.Type)
+		if err != nil {
+			return ncFile{}, nil, ErrUnsupported(fmt.Sprintf("axis of dimension '%s': %v", dim.name, err))
+		}
+		v := ncVariable{name: dim.name, dimensions: []int{dimensionIndex[dim.name]}, kind: kind}
+		if axis.Unit != "" {
+			v.attributes = append(v.attributes, ncAttribute{name: "units", kind: ncChar, values: axis.Unit})
+		}
+		attrs := attributes(v.name)
+		if axis.Calendar != CalendarStandard {
+			attrs = slices.DeleteFunc(attrs, func(attr ncAttribute) bool { return attr.name == "calendar" })
+			v.attributes = append(v.attributes, ncAttribute{name: "calendar", kind: ncChar, values: axis.Calendar.String()})
+		}
+		v.attributes = append(v.attributes, attrs...)
+		nc.variables = append(nc.variables, v)
+		sources = append(sources, ncSource{axis: axis})
+		names[v.name] = true
+	}
+	for _, layer := range p.Layers {
+		for c, channel := range layer.Channels {
+			kind, err := ncTypeOf(channel.Type)
+			if err != nil {
+				return ncFile{}, nil, ErrUnsupported(fmt.Sprintf("channel '%s' of layer '%s': %v", channel.Name, layer.Name, err))
+			}
+			v := ncVariable{name: channel.Name, kind: kind}
+			if names[v.name] {
+				v.name = layer.Name + "_" + channel.Name
+			}
+			for d := len(layer.Dimensions) - 1; d >= 0; d-- {
+				v.dimensions = append(v.dimensions, dimensionIndex[layer.Dimensions[d].Name])
+			}
+			if channel.Unit != "" {
+				v.attributes = append(v.attributes, ncAttribute{name: "units", kind: ncChar, values: channel.Unit})
+			}
+			if channel.FillValue != nil {
+				fill, _ := channel.Type.ToFloat64(channel.FillValue)
+				v.attributes = append(v.attributes, ncAttribute{name: "_FillValue", kind: kind, values: ncValues(kind, []float64{fill})})
+			}
+			v.attributes = append(v.attributes, attributes(v.name)...)
+			nc.variables = append(nc.variables, v)
+			sources = append(sources, ncSource{layer: layer, channel: c})
+			names[v.name] = true
+		}
+	}
+	keys := []string{}
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		nc.attributes = append(nc.attributes, ncAttribute{name: key, kind: ncChar, values: tags[key]})
+	}
+
+	for i := range nc.variables {
+		nc.variables[i].size = pad4(ncTypeSize(nc.variables[i].kind) * nc.elements(nc.variables[i]))
+	}
+	for _, version := range []byte{1, 2} {
+		nc.version = version
+		begin := nc.headerSize()
+		for i := range nc.variables {
+			nc.variables[i].begin = begin
+			begin += nc.variables[i].size
+		}
+		if version == 2 || nc.variables == nil || nc.variables[len(nc.variables)-1].begin <= math.MaxInt32 {
+			break
+		}
+	}
+	return nc, sources, nil
+}
+
+// Writes the values of the axis at each index of a dimension of the given size as the variable.
+func writeNetCDFAxis(w io.Writer, v ncVariable, axis *Axis, size int) error {
+	values := make([]float64, size)
+	for i := range values {
+		values[i], _ = axis.Type.ToFloat64(axis.StepValue(i))
+	}
+	return binary.Write(w, binary.BigEndian, ncValues(v.kind, values))
+}
+
+// Writes the values of the channel of the layer as the variable, one slice of the last dimension at a time.
+func (p *Pixi) writeNetCDFChannel(r io.ReadSeeker, w io.Writer, v ncVariable, layer Layer, channelIndex int) error {
+	channel := layer.Channels[channelIndex]
+	last := len(layer.Dimensions) - 1
+	start := make([]int, len(layer.Dimensions))
+	count := make([]int, len(layer.Dimensions))
+	for d, dim := range layer.Dimensions {
+		count[d] = dim.Size
+	}
+	count[last] = 1
+	size := channel.Size()
+	for s := range layer.Dimensions[last].Size {
+		start[last] = s
+		data, err := layer.ReadRange(r, p.Header, start, count, WithChannels(channel.Name))
+		if err != nil {
+			return err
+		}
+		values := make([]float64, len(data)/size)
+		for i := range values {
+			values[i], _ = channel.Type.ToFloat64(channel.Value(data[i*size:], p.Header.ByteOrder))
+		}
+		if err := binary.Write(w, binary.BigEndian, ncValues(v.kind, values)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The NetCDF classic type holding every value of the channel type.
+func ncTypeOf(t ChannelType) (uint32, error) {
+	switch t.Base() {
+	case ChannelBool, ChannelInt8:
+		return ncByte, nil
+	case ChannelUint8, ChannelInt16:
+		return ncShort, nil
+	case ChannelUint16, ChannelInt32:
+		return ncInt, nil
+	case ChannelFloat8, ChannelFloat16, ChannelFloat32:
+		return ncFloat, nil
+	case ChannelUint32, ChannelFloat64:
+		return ncDouble, nil
+	}
+	return 0, fmt.Errorf("type %v has no NetCDF classic equivalent", t.Base())
+}
+
+// The channel type holding the values of the variable.
+func (v ncVariable) channelType() ChannelType {
+	switch v.kind {
+	case ncByte:
+		return ChannelInt8
+	case ncShort:
+		return ChannelInt16
+	case ncInt:
+		return ChannelInt32
+	case ncFloat:
+		return ChannelFloat32
+	default:
+		return ChannelFloat64
+	}
+}
+
+func ncTypeSize(kind uint32) int64 {
+	switch kind {
+	case ncByte, ncChar:
+		return 1
+	case ncShort:
+		return 2
+	case ncInt, ncFloat:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func pad4(n int64) int64 {
+	return (n + 3) / 4 * 4
+}
+
+// Decodes the big-endian value of the NetCDF type at the start of the data as the Go type of its channel type.
+func ncValue(kind uint32, data []byte) any {
+	switch kind {
+	case ncByte:
+		return int8(data[0])
+	case ncShort:
+		return int16(binary.BigEndian.Uint16(data))
+	case ncInt:
+		return int32(binary.BigEndian.Uint32(data))
+	case ncFloat:
+		return math.Float32frombits(binary.BigEndian.Uint32(data))
+	default:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+}
+
+// Converts the values to a slice of the Go type of the NetCDF type, for writing with binary.Write.
+func ncValues(kind uint32, values []float64) any {
+	switch kind {
+	case ncByte:
+		return convertValues[int8](values)
+	case ncShort:
+		return convertValues[int16](values)
+	case ncInt:
+		return convertValues[int32](values)
+	case ncFloat:
+		return convertValues[float32](values)
+	default:
+		return values
+	}
+}
+
+func convertValues[T Number](values []float64) []T {
+	converted := make([]T, len(values))
+	for i, v := range values {
+		converted[i] = T(v)
+	}
+	return converted
+}
+
+// Whether the variable is a coordinate variable: one-dimensional, and named for its dimension.
+func (nc ncFile) isCoordinate(v ncVariable) bool {
+	return len(v.dimensions) == 1 && nc.dimensions[v.dimensions[0]].name == v.name
+}
+
+// Whether the variable is a record variable, whose first dimension is the record dimension.
+func (nc ncFile) isRecord(v ncVariable) bool {
+	return len(v.dimensions) > 0 && nc.dimensions[v.dimensions[0]].size == 0
+}
+
+// The number of values of the variable, or of one record of it for record variables.
+func (nc ncFile) elements(v ncVariable) int64 {
+	elements := int64(1)
+	for i, d := range v.dimensions {
+		if i == 0 && nc.isRecord(v) {
+			continue
+		}
+		elements *= nc.dimensions[d].size
+	}
+	return elements
+}
+
+// The number of bytes between the records of each record variable. Records of a file with a single record
+// variable are not padded.
+func (nc ncFile) recordSize() int64 {
+	sizes := []int64{}
+	for _, v := range nc.variables {
+		if nc.isRecord(v) {
+			sizes = append(sizes, ncTypeSize(v.kind)*nc.elements(v))
+		}
+	}
+	if len(sizes) == 1 {
+		return sizes[0]
+	}
+	size := int64(0)
+	for _, s := range sizes {
+		size += pad4(s)
+	}
+	return size
+}
+
+// Reads the values of the coordinate variable and returns the axis they fall on, or nil if they are not
+// evenly spaced.
+func (nc ncFile) coordinateAxis(r io.ReaderAt, v ncVariable) (*Axis, error) {
+	count := nc.dimensions[v.dimensions[0]].size
+	stride := ncTypeSize(v.kind)
+	if nc.isRecord(v) {
+		count, stride = nc.records, nc.recordSize()
+	}
+	if count < 2 {
+		return nil, nil
+	}
+	values := make([]float64, count)
+	data := make([]byte, ncTypeSize(v.kind))
+	t := v.channelType()
+	for i := range values {
+		if err := readFullAt(r, data, v.begin+int64(i)*stride); err != nil {
+			return nil, err
+		}
+		values[i], _ = t.ToFloat64(ncValue(v.kind, data))
+	}
+	axis := regularAxis(t, values)
+	if axis == nil {
+		return nil, nil
+	}
+	for _, attr := range v.attributes {
+		if attr.name == "units" && attr.kind == ncChar {
+			axis.Unit = attr.values.(string)
+		}
+		if attr.name == "calendar" && attr.kind == ncChar {
+			axis.Calendar, _ = ParseCalendar(attr.values.(string))
+		}
+	}
+	return axis, nil
+}
+
+func (a ncAttribute) count() int {
+	switch values := a.values.(type) {
+	case string:
+		return len(values)
+	case []int8:
+		return len(values)
+	case []int16:
+		return len(values)
+	case []int32:
+		return len(values)
+	case []float32:
+		return len(values)
+	case []float64:
+		return len(values)
+	}
+	return 0
+}
+
+// The value of the attribute at the given index, as the Go type of its channel type.
+func (a ncAttribute) value(i int) any {
+	switch values := a.values.(type) {
+	case []int8:
+		return values[i]
+	case []int16:
+		return values[i]
+	case []int32:
+		return values[i]
+	case []float32:
+		return values[i]
+	case []float64:
+		return values[i]
+	}
+	return nil
+}
+
+// The values of the attribute as text, separated by commas if there are several.
+func (a ncAttribute) text() string {
+	if s, ok := a.values.(string); ok {
+		return s
+	}
+	values := make([]string, a.count())
+	for i := range values {
+		switch v := a.value(i).(type) {
+		case float32:
+			values[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+		case float64:
+			values[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			values[i] = fmt.Sprint(v)
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+// Reads the values of a NetCDF header with a sticky error, so that a list can be parsed without checking
+// after every field.
+type ncDecoder struct {
+	r       io.Reader
+	version byte
+	err     error
+}
+
+func (d *ncDecoder) uint32() uint32 {
+	var v uint32
+	if d.err == nil {
+		d.err = binary.Read(d.r, binary.BigEndian, &v)
+	}
+	return v
+}
+
+func (d *ncDecoder) bytes(n int64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > 1<<24 {
+		d.err = ErrFormat(fmt.Sprintf("NetCDF header field of %d bytes is too long", n))
+		return nil
+	}
+	data := make([]byte, pad4(n))
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		d.err = err
+		return nil
+	}
+	return data[:n]
+}
+
+func (d *ncDecoder) name() string {
+	return string(d.bytes(int64(d.uint32())))
+}
+
+// Reads the tag and length of a list, returning zero for an absent list.
+func (d *ncDecoder) list(tag uint32) int {
+	found, count := d.uint32(), d.uint32()
+	if d.err == nil && found != tag && (found != 0 || count != 0) {
+		d.err = ErrFormat(fmt.Sprintf("expected NetCDF list tag %#x, found %#x", tag, found))
+	}
+	if count > 1<<24 {
+		d.err = ErrFormat(fmt.Sprintf("NetCDF list of %d elements is too long", count))
+	}
+	return int(count)
+}
+
+func (d *ncDecoder) attributes() []ncAttribute {
+	attrs := make([]ncAttribute, d.list(ncAttributeTag))
+	for i := range attrs {
+		attrs[i].name = d.name()
+		attrs[i].kind = d.uint32()
+		count := int64(d.uint32())
+		if attrs[i].kind < ncByte || attrs[i].kind > ncDouble {
+			if d.err == nil {
+				d.err = ErrFormat(fmt.Sprintf("attribute '%s' has unknown NetCDF type %d", attrs[i].name, attrs[i].kind))
+			}
+			return nil
+		}
+		data := d.bytes(count * ncTypeSize(attrs[i].kind))
+		if d.err != nil {
+			return nil
+		}
+		if attrs[i].kind == ncChar {
+			attrs[i].values = strings.TrimRight(string(data), "\x00")
+			continue
+		}
+		values := make([]float64, count)
+		t := ncVariable{kind: attrs[i].kind}.channelType()
+		for j := range values {
+			values[j], _ = t.ToFloat64(ncValue(attrs[i].kind, data[int64(j)*ncTypeSize(attrs[i].kind):]))
+		}
+		attrs[i].values = ncValues(attrs[i].kind, values)
+	}
+	return attrs
+}
+
+func readNetCDFHeader(r io.Reader) (ncFile, error) {
+	d := &ncDecoder{r: bufio.NewReader(r)}
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(d.r, magic); err != nil {
+		return ncFile{}, err
+	}
+	switch {
+	case bytes.Equal(magic, []byte("\x89HDF")):
+		return ncFile{}, ErrUnsupported("NetCDF-4 (HDF5) files cannot be read, only the classic and 64-bit offset formats")
+	case string(magic) == "CDF\x05":
+		return ncFile{}, ErrUnsupported("the NetCDF CDF-5 format cannot be read, only the classic and 64-bit offset formats")
+	case string(magic) != "CDF\x01" && string(magic) != "CDF\x02":
+		return ncFile{}, ErrFormat("not a NetCDF classic or 64-bit offset file")
+	}
+	nc := ncFile{version: magic[3]}
+	if records := d.uint32(); records != ncStreaming {
+		nc.records = int64(records)
+	}
+
+	nc.dimensions = make([]ncDimension, d.list(ncDimensionTag))
+	for i := range nc.dimensions {
+		nc.dimensions[i] = ncDimension{name: d.name(), size: int64(d.uint32())}
+	}
+	nc.attributes = d.attributes()
+	nc.variables = make([]ncVariable, d.list(ncVariableTag))
+	for i := range nc.variables {
+		v := &nc.variables[i]
+		v.name = d.name()
+		v.dimensions = make([]int, d.uint32())
+		for j := range v.dimensions {
+			v.dimensions[j] = int(d.uint32())
+			if d.err == nil && v.dimensions[j] >= len(nc.dimensions) {
+				return ncFile{}, ErrFormat(fmt.Sprintf("variable '%s' has unknown dimension %d", v.name, v.dimensions[j]))
+			}
+			if d.err == nil && j > 0 && nc.dimensions[v.dimensions[j]].size == 0 {
+				return ncFile{}, ErrFormat(fmt.Sprintf("variable '%s' has the record dimension after its first", v.name))
+			}
+		}
+		v.attributes = d.attributes()
+		v.kind = d.uint32()
+		if d.err == nil && (v.kind < ncByte || v.kind > ncDouble) {
+			return ncFile{}, ErrFormat(fmt.Sprintf("variable '%s' has unknown NetCDF type %d", v.name, v.kind))
+		}
+		v.size = int64(d.uint32())
+		if nc.version == 2 {
+			v.begin = int64(d.uint32())<<32 | int64(d.uint32())
+		} else {
+			v.begin = int64(d.uint32())
+		}
+	}
+	return nc, d.err
+}
+
+// The size in bytes of the header as written by writeHeader.
+func (nc ncFile) headerSize() int64 {
+	var counter countingWriter
+	nc.writeHeader(&counter)
+	return counter.n
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+func (nc ncFile) writeHeader(w io.Writer) error {
+	var buf bytes.Buffer
+	u32 := func(v uint32) { binary.Write(&buf, binary.BigEndian, v) }
+	padded := func(data []byte) {
+		buf.Write(data)
+		buf.Write(make([]byte, pad4(int64(len(data)))-int64(len(data))))
+	}
+	name := func(s string) {
+		u32(uint32(len(s)))
+		padded([]byte(s))
+	}
+	list := func(tag uint32, count int) {
+		if count == 0 {
+			tag = 0
+		}
+		u32(tag)
+		u32(uint32(count))
+	}
+	attributes := func(attrs []ncAttribute) {
+		list(ncAttributeTag, len(attrs))
+		for _, attr := range attrs {
+			name(attr.name)
+			u32(attr.kind)
+			u32(uint32(attr.count()))
+			if s, ok := attr.values.(string); ok {
+				padded([]byte(s))
+				continue
+			}
+			var values bytes.Buffer
+			binary.Write(&values, binary.BigEndian, attr.values)
+			padded(values.Bytes())
+		}
+	}
+
+	buf.WriteString("CDF")
+	buf.WriteByte(nc.version)
+	u32(uint32(nc.records))
+	list(ncDimensionTag, len(nc.dimensions))
+	for _, dim := range nc.dimensions {
+		name(dim.name)
+		u32(uint32(dim.size))
+	}
+	attributes(nc.attributes)
+	list(ncVariableTag, len(nc.variables))
+	for _, v := range nc.variables {
+		name(v.name)
+		u32(uint32(len(v.dimensions)))
+		for _, d := range v.dimensions {
+			u32(uint32(d))
+		}
+		attributes(v.attributes)
+		u32(v.kind)
+		u32(uint32(min(v.size, math.MaxUint32)))
+		if nc.version == 2 {
+			u32(uint32(v.begin >> 32))
+		}
+		u32(uint32(v.begin))
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,249 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestNetCDFRoundTrip(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("weather", DimensionSet{
+		{Name: "lon", Size: 5, TileSize: 2, Axis: &Axis{Type: ChannelFloat64, Minimum: -10.0, Step: 2.5, Unit: "degrees_east"}},
+		{Name: "lat", Size: 4, TileSize: 4, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(50), Step: float32(-0.5), Unit: "degrees_north"}},
+		{Name: "time", Size: 3, TileSize: 2},
+	}, ChannelSet{
+		{Name: "temp", Type: ChannelFloat32, Unit: "K", FillValue: float32(-999)},
+		{Name: "mask", Type: ChannelBool},
+		{Name: "count", Type: ChannelUint16},
+	})
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			c := writer.Coordinate()
+			writer.SetSample(Sample{float32(c[0]) + float32(c[1])/4 + float32(c[2])*10, (c[0]+c[2])%2 == 0, uint16(60000 + c[0]*c[1])})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.AppendTags(file.Stream(), map[string]string{"title": "test weather", "temp:long_name": "air temperature"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var nc bytes.Buffer
+	if err := file.WriteNetCDF(file.Stream(), &nc); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(nc.Bytes(), []byte("CDF\x01")) {
+		t.Fatalf("expected a classic NetCDF file, got magic %q", nc.Bytes()[:4])
+	}
+
+	imported, err := NewMemoryFile(NewHeader(binary.BigEndian, OffsetSize8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imported.AppendNetCDF(imported.Stream(), bytes.NewReader(nc.Bytes()), WithCompression(CompressionFlate)); err != nil {
+		t.Fatal(err)
+	}
+	reread, err := FromBytes(imported.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, l := range reread.Layers {
+		names = append(names, l.Name)
+	}
+	if !slices.Equal(names, []string{"temp", "mask", "count"}) {
+		t.Fatalf("expected a layer per channel, got %v", names)
+	}
+	if tags := reread.AllTags(); tags["title"] != "test weather" || tags["temp:long_name"] != "air temperature" {
+		t.Errorf("expected the attributes to be kept as tags, got %v", tags)
+	}
+
+	temp := reread.Layers[0]
+	if temp.Channels[0].Unit != "K" || temp.Channels[0].FillValue != float32(-999) || temp.Channels[0].Type != ChannelFloat32 {
+		t.Errorf("expected the units and fill value to round trip, got %v", temp.Channels[0])
+	}
+	for d, dim := range temp.Dimensions {
+		want := layer.Dimensions[d]
+		if dim.Name != want.Name || dim.Size != want.Size {
+			t.Errorf("expected dimension %v, got %v", want, dim)
+		}
+		if !axesEqual(dim.Axis, want.Axis) {
+			t.Errorf("expected axis %v of dimension %s, got %v", want.Axis, dim.Name, dim.Axis)
+		}
+	}
+	if c := reread.Layers[1].Channels[0]; c.Type != ChannelInt8 {
+		t.Errorf("expected booleans to import as int8, got %v", c.Type)
+	}
+	if c := reread.Layers[2].Channels[0]; c.Type != ChannelInt32 {
+		t.Errorf("expected uint16 values to import as int32, got %v", c.Type)
+	}
+
+	original, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessors := make([]TileAccessLayer, len(reread.Layers))
+	for i := range reread.Layers {
+		if accessors[i], err = reread.Layer(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for coord := range layer.Dimensions.SampleCoordinates() {
+		want, err := SampleAt(original, coord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for c, accessor := range accessors {
+			got, err := ReadSamples[float64](accessor, reread.Layers[c].Channels[0].Name, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, _ := layer.Channels[c].Type.ToFloat64(want[c])
+			if got[0] != expected {
+				t.Fatalf("channel %s at %v imported as %v, expected %v", layer.Channels[c].Name, coord, got[0], expected)
+			}
+		}
+	}
+}
+
+// Builds a classic NetCDF file with a record dimension of two records, an unevenly spaced coordinate
+// variable, a scalar, and two record variables whose records are interleaved.
+func recordNetCDF(t *testing.T) []byte {
+	t.Helper()
+	nc := ncFile{
+		version:    1,
+		records:    2,
+		dimensions: []ncDimension{{name: "time", size: 0}, {name: "x", size: 3}},
+		attributes: []ncAttribute{{name: "history", kind: ncChar, values: "created by hand"}},
+		variables: []ncVariable{
+			{name: "x", dimensions: []int{1}, kind: ncDouble, size: 24},
+			{name: "scale", kind: ncDouble, size: 8},
+			{name: "a", dimensions: []int{0, 1}, kind: ncShort, size: 8, attributes: []ncAttribute{
+				{name: "units", kind: ncChar, values: "m"},
+				{name: "valid_range", kind: ncShort, values: []int16{-5, 5}},
+			}},
+			{name: "b", dimensions: []int{0}, kind: ncInt, size: 4},
+		},
+	}
+	header := nc.headerSize()
+	begins := []int64{header, header + 24, header + 32, header + 40}
+	for i := range nc.variables {
+		nc.variables[i].begin = begins[i]
+	}
+	var buf bytes.Buffer
+	if err := nc.writeHeader(&buf); err != nil {
+		t.Fatal(err)
+	}
+	binary.Write(&buf, binary.BigEndian, []float64{0, 1, 5})
+	binary.Write(&buf, binary.BigEndian, 0.5)
+	for record := range 2 {
+		binary.Write(&buf, binary.BigEndian, []int16{int16(record*10 + 1), int16(record*10 + 2), int16(record*10 + 3), 0})
+		binary.Write(&buf, binary.BigEndian, int32(100+record))
+	}
+	return buf.Bytes()
+}
+
+func TestAppendNetCDFRecords(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.AppendNetCDF(file.Stream(), bytes.NewReader(recordNetCDF(t))); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, l := range file.Layers {
+		names = append(names, l.Name)
+	}
+	if !slices.Equal(names, []string{"x", "a", "b"}) {
+		t.Fatalf("expected the uneven coordinate variable to be kept as a layer, got %v", names)
+	}
+	if tags := file.AllTags(); tags["history"] != "created by hand" || tags["scale"] != "0.5" || tags["a:valid_range"] != "-5,5" {
+		t.Errorf("expected global attributes, scalars, and other attributes as tags, got %v", tags)
+	}
+
+	a := file.Layers[1]
+	if a.Dimensions[0].Name != "x" || a.Dimensions[1].Name != "time" || a.Dimensions[1].Size != 2 || a.Channels[0].Unit != "m" {
+		t.Fatalf("expected a over x and two records of time in meters, got %v", a)
+	}
+	accessor, err := file.Layer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := ReadSamples[int16](accessor, "a", SampleCoordinate{0, 0}, SampleCoordinate{2, 0}, SampleCoordinate{0, 1}, SampleCoordinate{2, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(values, []int16{1, 3, 11, 13}) {
+		t.Errorf("expected the records of a to read as [1 3 11 13], got %v", values)
+	}
+	accessor, err = file.Layer(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values, err := ReadSamples[int32](accessor, "b", SampleCoordinate{0}, SampleCoordinate{1}); err != nil || !slices.Equal(values, []int32{100, 101}) {
+		t.Errorf("expected the records of b to read as [100 101], got %v (%v)", values, err)
+	}
+}
+
+func TestNetCDFAxisFromCoordinateVariable(t *testing.T) {
+	data := recordNetCDF(t)
+	nc, err := readNetCDFHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// make the coordinate variable evenly spaced
+	binary.BigEndian.PutUint64(data[nc.variables[0].begin+16:], math.Float64bits(2))
+
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.AppendNetCDF(file.Stream(), bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Layers) != 2 {
+		t.Fatalf("expected the coordinate variable to become an axis, got %d layers", len(file.Layers))
+	}
+	axis := file.Layers[0].Dimensions[0].Axis
+	if axis == nil || axis.Minimum != 0.0 || axis.Step != 1.0 {
+		t.Errorf("expected an axis from 0 by 1, got %v", axis)
+	}
+}
+
+func TestNetCDFUnsupported(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unsupported ErrUnsupported
+	if err := file.AppendNetCDF(file.Stream(), bytes.NewReader([]byte("\x89HDF\r\n\x1a\n"))); !errors.As(err, &unsupported) {
+		t.Errorf("expected ErrUnsupported for a NetCDF-4 file, got %v", err)
+	}
+	var format ErrFormat
+	if err := file.AppendNetCDF(file.Stream(), bytes.NewReader([]byte("GIF89a..."))); !errors.As(err, &format) {
+		t.Errorf("expected ErrFormat for a file that is not NetCDF, got %v", err)
+	}
+
+	file.Layers = []Layer{NewLayer("wide", DimensionSet{{Name: "x", Size: 2, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelInt64}})}
+	if err := file.WriteNetCDF(file.Stream(), &bytes.Buffer{}); !errors.As(err, &unsupported) {
+		t.Errorf("expected ErrUnsupported for an int64 channel, got %v", err)
+	}
+	file.Layers = []Layer{
+		NewLayer("one", DimensionSet{{Name: "x", Size: 2, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelInt8}}),
+		NewLayer("two", DimensionSet{{Name: "x", Size: 3, TileSize: 3}}, ChannelSet{{Name: "v", Type: ChannelInt8}}),
+	}
+	if err := file.WriteNetCDF(file.Stream(), &bytes.Buffer{}); !errors.As(err, &format) {
+		t.Errorf("expected ErrFormat for dimensions of different sizes, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package gopixi
+
+import (
+	"math"
+	"slices"
+)
+
+// How a writer treats NaN and infinite values of a floating point channel.
+type NonFinitePolicy int
+
+const (
+	NonFiniteAllow NonFinitePolicy = iota // Write the value as it is. This is the default.
+	NonFiniteFill                         // Write the fill value of the rule instead.
+	NonFiniteError                        // Fail with an ErrNonFinite.
+)
+
+// The treatment of non-finite values written to a channel, as set with WithNonFinite.
+type NonFiniteRule struct {
+	Policy NonFinitePolicy
+	Fill   float64 // The value written in place of non-finite values with NonFiniteFill.
+}
+
+type nonFiniteOption struct {
+	channel string
+	rule    NonFiniteRule
+}
+
+func (o nonFiniteOption) applyLayer(opts *layerOptions) {
+	if opts.nonFinite == nil {
+		opts.nonFinite = map[string]NonFiniteRule{}
+	}
+	opts.nonFinite[o.channel] = o.rule
+}
+
+// Sets how samples written to the named channel treat NaN and infinite values (see Layer.NonFinite). The
+// fill value is only used with NonFiniteFill.
+func WithNonFinite(channel string, policy NonFinitePolicy, fill float64) LayerOption {
+	return nonFiniteOption{channel: channel, rule: NonFiniteRule{Policy: policy, Fill: fill}}
+}
+
+// Applies the non-finite rules of the layer to a sample about to be written at the coordinate, returning
+// the sample to write. The given sample is not modified; a copy is returned if any value is replaced.
+func (l Layer) applyNonFinite(coord SampleCoordinate, sample Sample) (Sample, error) {
+	result, copied := sample, false
+	for channelIndex := range l.Channels {
+		value, replaced, err := l.applyNonFiniteValue(coord, channelIndex, sample[channelIndex])
+		if err != nil {
+			return nil, err
+		}
+		if replaced {
+			if !copied {
+				result, copied = slices.Clone(sample), true
+			}
+			result[channelIndex] = value
+		}
+	}
+	return result, nil
+}
+
+// Applies the non-finite rule of a channel of the layer, if it has one, to a value about to be written,
+// returning the value to write and whether it replaces the given value.
+func (l Layer) applyNonFiniteValue(coord SampleCoordinate, channelIndex int, value any) (any, bool, error) {
+	channel := l.Channels[channelIndex]
+	rule, ok := l.NonFinite[channel.Name]
+	if !ok || rule.Policy == NonFiniteAllow || floatRank(channel.Type) == 0 {
+		return value, false, nil
+	}
+	f, _ := channel.Type.ToFloat64(value)
+	if !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return value, false, nil
+	}
+	if rule.Policy == NonFiniteError {
+		return nil, false, ErrNonFinite{Channel: channel.Name, Value: f, Coordinate: slices.Clone(coord)}
+	}
+	return channel.Type.FromFloat64(rule.Fill), true, nil
+}
+
+// A read transform replacing the NaN and infinite values of the named channels (or every floating point
+// channel, if none are named) with Fill, converted to the type of each channel.
+type ReplaceNonFinite struct {
+	Channels []string
+	Fill     float64
+}
+
+func (r ReplaceNonFinite) Bind(channels ChannelSet) (ChannelSet, func(SampleCoordinate, Sample) Sample, error) {
+	indices, err := channelIndices(channels, r.Channels)
+	if err != nil {
+		return nil, nil, err
+	}
+	indices = slices.DeleteFunc(indices, func(index int) bool { return floatRank(channels[index].Type) == 0 })
+	fills := make([]any, len(indices))
+	for i, index := range indices {
+		fills[i] = channels[index].Type.FromFloat64(r.Fill)
+	}
+	return channels, func(coord SampleCoordinate, sample Sample) Sample {
+		for i, index := range indices {
+			if f, _ := channels[index].Type.ToFloat64(sample[index]); math.IsNaN(f) || math.IsInf(f, 0) {
+				sample[index] = fills[i]
+			}
+		}
+		return sample
+	}, nil
+}
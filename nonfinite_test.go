@@ -0,0 +1,114 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestWriteNonFinitePolicies(t *testing.T) {
+	values := []float64{1, math.NaN(), math.Inf(1), -2, math.Inf(-1)}
+	newLayer := func(opts ...LayerOption) Layer {
+		return NewLayer("values", DimensionSet{{Name: "x", Size: len(values), TileSize: 4}},
+			ChannelSet{{Name: "kept", Type: ChannelFloat32}, {Name: "filled", Type: ChannelFloat64}}, opts...)
+	}
+	write := func(layer Layer) (*MemoryFile, error) {
+		file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+		return file, file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+			for writer.Next() {
+				if x := writer.Coordinate()[0]; x < len(values) {
+					writer.SetSample(Sample{float32(values[x]), values[x]})
+				}
+			}
+			return nil
+		})
+	}
+
+	file, err := write(newLayer(WithNonFinite("filled", NonFiniteFill, -9999)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, value := range values {
+		sample, err := SampleAt(read, SampleCoordinate{x})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept := float64(sample[0].(float32))
+		if kept != value && !(math.IsNaN(kept) && math.IsNaN(value)) {
+			t.Errorf("sample %d: expected %v to be kept, got %v", x, value, kept)
+		}
+		want := value
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			want = -9999
+		}
+		if sample[1] != want {
+			t.Errorf("sample %d: expected %v to be filled as %v, got %v", x, value, want, sample[1])
+		}
+	}
+
+	_, err = write(newLayer(WithNonFinite("kept", NonFiniteError, 0)))
+	var nonFinite ErrNonFinite
+	if !errors.As(err, &nonFinite) || nonFinite.Channel != "kept" || !math.IsNaN(nonFinite.Value) || !slices.Equal(nonFinite.Coordinate, SampleCoordinate{1}) {
+		t.Errorf("expected non-finite error at coordinate 1, got %v", err)
+	}
+
+	memLayer := NewMemoryLayer(buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize4), newLayer(WithNonFinite("filled", NonFiniteError, 0)))
+	if err := SetChannelAt(memLayer, SampleCoordinate{0}, 1, math.Inf(-1)); !errors.As(err, &nonFinite) {
+		t.Errorf("expected non-finite error setting a channel, got %v", err)
+	}
+	if err := SetSampleAt(memLayer, SampleCoordinate{0}, Sample{float32(math.NaN()), 1.0}); err != nil {
+		t.Errorf("expected NaN to be allowed in a channel without a rule, got %v", err)
+	}
+}
+
+func TestReplaceNonFinite(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 3, TileSize: 2}},
+		ChannelSet{{Name: "a", Type: ChannelFloat32}, {Name: "b", Type: ChannelFloat64}, {Name: "count", Type: ChannelUint8}})
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			switch writer.Coordinate()[0] {
+			case 0:
+				writer.SetSample(Sample{float32(math.NaN()), math.Inf(1), uint8(1)})
+			default:
+				writer.SetSample(Sample{float32(2), 3.0, uint8(4)})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file.RegisterReadTransform("values", ReplaceNonFinite{Fill: -1})
+	read, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample, err := SampleAt(read, SampleCoordinate{0}); err != nil || sample[0] != float32(-1) || sample[1] != -1.0 || sample[2] != uint8(1) {
+		t.Errorf("expected non-finite values to read as the fill, got %v (%v)", sample, err)
+	}
+	if sample, err := SampleAt(read, SampleCoordinate{1}); err != nil || sample[0] != float32(2) || sample[1] != 3.0 {
+		t.Errorf("expected finite values to be preserved, got %v (%v)", sample, err)
+	}
+
+	if _, _, err := (ReplaceNonFinite{Channels: []string{"missing"}}).Bind(layer.Channels); err == nil {
+		t.Error("expected error binding to a missing channel")
+	}
+}
@@ -0,0 +1,225 @@
+package gopixi
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+const (
+	OverviewOfAttribute     string = "pixi.overview.of"     // The layer attribute naming the layer an overview reduces.
+	OverviewFactorAttribute string = "pixi.overview.factor" // The layer attribute holding the reduction factor of an overview.
+)
+
+// How the samples of each block of a layer are combined into one sample of an overview by BuildOverviews.
+type Resampling int
+
+const (
+	ResampleNearest Resampling = iota // The first sample of the block.
+	ResampleMean                      // The mean of the samples of the block, rounded for integer channels.
+	ResampleMin                       // The least sample of the block.
+	ResampleMax                       // The greatest sample of the block.
+)
+
+func (r Resampling) String() string {
+	switch r {
+	case ResampleNearest:
+		return "nearest"
+	case ResampleMean:
+		return "mean"
+	case ResampleMin:
+		return "min"
+	case ResampleMax:
+		return "max"
+	default:
+		return fmt.Sprintf("resampling(%d)", int(r))
+	}
+}
+
+// A reduced-resolution copy of a layer, stored as a layer of its own, as built by BuildOverviews.
+type Overview struct {
+	LayerIndex int // The index of the overview layer in the file.
+	Factor     int // The factor by which the first two dimensions of the original layer are reduced.
+}
+
+// The overviews of the layer with the given index, from the finest to the coarsest. Overviews are ordinary
+// layers, found by their OverviewOfAttribute and OverviewFactorAttribute attributes, and can be read with
+// any of the layer access methods.
+func (p *Pixi) Overviews(layerIndex int) []Overview {
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return nil
+	}
+	name := p.Layers[layerIndex].Name
+	overviews := []Overview{}
+	for i, layer := range p.Layers {
+		factor, ok := layer.Attributes[OverviewFactorAttribute].(int64)
+		if i != layerIndex && layer.Attributes[OverviewOfAttribute] == name && ok && factor > 1 {
+			overviews = append(overviews, Overview{LayerIndex: i, Factor: int(factor)})
+		}
+	}
+	slices.SortFunc(overviews, func(a, b Overview) int { return cmp.Compare(a.Factor, b.Factor) })
+	return overviews
+}
+
+// The index of the layer best read to show the layer with the given index reduced by the factor, such as
+// when drawing it zoomed out: its coarsest overview whose factor is no greater, or the layer itself if it
+// has none.
+func (p *Pixi) BestOverview(layerIndex int, factor float64) int {
+	best := layerIndex
+	for _, overview := range p.Overviews(layerIndex) {
+		if float64(overview.Factor) <= factor {
+			best = overview.LayerIndex
+		}
+	}
+	return best
+}
+
+// The number of overview levels after which the first two dimensions of the layer are no larger than
+// maxSize, such as the size of a single tile.
+func OverviewLevels(layer Layer, maxSize int) int {
+	maxSize = max(maxSize, 1)
+	levels := 0
+	for i, dim := range layer.Dimensions[:min(2, len(layer.Dimensions))] {
+		size, n := dim.Size, 0
+		for ; size > maxSize; n++ {
+			size = (size + 1) / 2
+		}
+		if i == 0 || n > levels {
+			levels = n
+		}
+	}
+	return levels
+}
+
+// Appends the given number of overview levels of the layer at the given index to the end of the file, each
+// halving the first two dimensions of the level before it, named "pixi.overview.<layer>.<factor>". Fill
+// values and NaN are left out of each resampled block. Layers with string channels, layers that already
+// have overviews, and overviews themselves are unsupported. Levels appended before an error are kept.
+func (p *Pixi) BuildOverviews(rw io.ReadWriteSeeker, layerIndex int, levels int, resampling Resampling) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "build overviews"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat("layer index out of range")
+	}
+	if levels < 1 {
+		return ErrFormat(fmt.Sprintf("%d overview levels requested, expected at least one", levels))
+	}
+	if resampling < ResampleNearest || resampling > ResampleMax {
+		return ErrUnsupported(fmt.Sprintf("resampling %v", resampling))
+	}
+	base := p.Layers[layerIndex]
+	switch {
+	case base.Channels.HasStrings():
+		return ErrUnsupported("overviews of layers with string channels")
+	case len(p.Overviews(layerIndex)) > 0:
+		return ErrUnsupported(fmt.Sprintf("layer '%s' already has overviews", base.Name))
+	case base.Attributes[OverviewOfAttribute] != nil:
+		return ErrUnsupported(fmt.Sprintf("layer '%s' is itself an overview", base.Name))
+	}
+
+	// each block of a level spans at most three tiles of the level before it in each reduced dimension
+	cached := 9
+	if base.Separated {
+		cached *= len(base.Channels)
+	}
+	previous := base
+	for level := 1; level <= levels; level++ {
+		overview := newOverviewLayer(base, previous, 1<<level)
+		source := newFilledReadLayer(rw, p.Header, previous, cached)
+		if err := p.appendSamplewise(rw, overview, resampleBlock(source, resampling)); err != nil {
+			return err
+		}
+		previous = p.Layers[len(p.Layers)-1]
+	}
+	return nil
+}
+
+// The description of the overview of the base layer reducing it by the factor, built from the previous level.
+func newOverviewLayer(base Layer, previous Layer, factor int) Layer {
+	reduced := previous
+	reduced.Dimensions = slices.Clone(previous.Dimensions)
+	for i := range min(2, len(reduced.Dimensions)) {
+		dim := &reduced.Dimensions[i]
+		dim.Size = (dim.Size + 1) / 2
+		dim.TileSize = min(dim.TileSize, dim.Size)
+		dim.Axis = dim.Axis.strided(2)
+	}
+	channels := slices.Clone(previous.Channels)
+	for i := range channels {
+		channels[i].Min = nil
+		channels[i].Max = nil
+	}
+	overview := derivedLayer(fmt.Sprintf("pixi.overview.%s.%d", base.Name, factor), reduced, channels)
+	overview.Attributes = map[string]any{OverviewOfAttribute: base.Name, OverviewFactorAttribute: int64(factor)}
+	overview.Georeference = base.Georeference.scaled(factor)
+	return overview
+}
+
+// Computes each sample of an overview from the block of samples of the source level it reduces.
+func resampleBlock(source TileAccessLayer, resampling Resampling) func(coord SampleCoordinate, sample Sample) error {
+	layer := source.Layer()
+	reduced := min(2, len(layer.Dimensions))
+	sourceCoord := make(SampleCoordinate, len(layer.Dimensions))
+	block := make([]Sample, 0, 4)
+	return func(coord SampleCoordinate, sample Sample) error {
+		block = block[:0]
+		copy(sourceCoord, coord)
+		for offset := range 1 << reduced {
+			inBounds := true
+			for i := range reduced {
+				sourceCoord[i] = 2*coord[i] + (offset>>i)&1
+				inBounds = inBounds && sourceCoord[i] < layer.Dimensions[i].Size
+			}
+			if !inBounds {
+				continue
+			}
+			value, err := SampleAt(source, sourceCoord)
+			if err != nil {
+				return err
+			}
+			block = append(block, value)
+			if resampling == ResampleNearest {
+				break
+			}
+		}
+		for c, channel := range layer.Channels {
+			sample[c] = resampleValue(channel, block, c, resampling)
+		}
+		return nil
+	}
+}
+
+// The value of the channel with the given index resampled from the block, whose first sample is always
+// present. A block with no samples that are not missing resamples to its first, itself missing, value.
+func resampleValue(channel Channel, block []Sample, c int, resampling Resampling) any {
+	if resampling == ResampleNearest || (resampling == ResampleMean && channel.Type.Base() == ChannelBool) {
+		return block[0][c]
+	}
+	var chosen any
+	var stats ZoneStatistics
+	for _, sample := range block {
+		value := sample[c]
+		if f, _ := channel.Type.ToFloat64(value); math.IsNaN(f) || (channel.FillValue != nil && value == channel.FillValue) {
+			continue
+		}
+		switch {
+		case resampling == ResampleMean:
+			f, _ := channel.Type.ToFloat64(value)
+			stats.add(f)
+		case chosen == nil,
+			resampling == ResampleMin && channel.Type.CompareValues(value, chosen) < 0,
+			resampling == ResampleMax && channel.Type.CompareValues(value, chosen) > 0:
+			chosen = value
+		}
+	}
+	if stats.Count > 0 {
+		return channel.Type.FromFloat64(stats.Mean())
+	}
+	if chosen != nil {
+		return chosen
+	}
+	return block[0][c]
+}
@@ -0,0 +1,144 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestBuildOverviews(t *testing.T) {
+	for name, opts := range map[string][]LayerOption{
+		"contiguous": {WithCompression(CompressionFlate)},
+		"separated":  {WithPlanar()},
+	} {
+		opts = append(opts, WithCRS(CRS{EPSG: 32633}, GeoTransform{1000, 30, 0, 2000, 0, -30}))
+		buf, pixi := newEditTestPixi(t, opts...)
+		if err := pixi.BuildOverviews(buf, 0, 2, ResampleMean); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Overview{{LayerIndex: 2, Factor: 2}, {LayerIndex: 3, Factor: 4}}
+		if overviews := read.Overviews(0); !reflect.DeepEqual(overviews, expected) {
+			t.Fatalf("%s: expected overviews %v, got %v", name, expected, overviews)
+		}
+		if overviews := read.Overviews(1); len(overviews) != 0 {
+			t.Errorf("%s: expected the other layer to have no overviews, got %v", name, overviews)
+		}
+
+		level1, level2 := read.Layers[2], read.Layers[3]
+		if level1.Name != "pixi.overview.data.2" || level1.Separated != read.Layers[0].Separated || level1.Compression != read.Layers[0].Compression {
+			t.Errorf("%s: unexpected overview layer %s", name, level1.Name)
+		}
+		if level1.Dimensions[0].Size != 4 || level1.Dimensions[1].Size != 2 || level2.Dimensions[0].Size != 2 || level2.Dimensions[1].Size != 1 {
+			t.Errorf("%s: expected levels of 4x2 and 2x1, got %v and %v", name, level1.Dimensions, level2.Dimensions)
+		}
+		if _, transform, ok := level2.CRS(); !ok || transform != (GeoTransform{1000, 120, 0, 2000, 0, -120}) {
+			t.Errorf("%s: expected the transform to be scaled by 4, got %v", name, transform)
+		}
+		if channel := level1.Channels[0]; channel.Min != uint16(0) || channel.Max != uint16(15) {
+			t.Errorf("%s: expected the first level to range from 0 to 15, got %v to %v", name, channel.Min, channel.Max)
+		}
+
+		// means are rounded, and the blocks of the last index of an odd dimension are smaller
+		for layerIndex, samples := range map[int]map[[2]int]Sample{
+			2: {{1, 1}: {uint16(6), true}, {3, 1}: {uint16(15), true}, {1, 0}: {uint16(1), true}, {0, 1}: {uint16(1), true}},
+			3: {{0, 0}: {uint16(2), true}, {1, 0}: {uint16(8), true}},
+		} {
+			layer := NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), read.Header, read.Layers[layerIndex], 4)
+			for coord, want := range samples {
+				if sample, err := SampleAt(layer, SampleCoordinate{coord[0], coord[1]}); err != nil || !reflect.DeepEqual(sample, want) {
+					t.Errorf("%s: layer %d sample %v: expected %v, got %v (%v)", name, layerIndex, coord, want, sample, err)
+				}
+			}
+		}
+
+		for factor, want := range map[float64]int{1: 0, 3: 2, 4: 3, 100: 3} {
+			if best := read.BestOverview(0, factor); best != want {
+				t.Errorf("%s: expected layer %d to show a reduction by %v, got %d", name, want, factor, best)
+			}
+		}
+	}
+}
+
+func TestOverviewResampling(t *testing.T) {
+	for resampling, expected := range map[Resampling][2]uint16{
+		ResampleNearest: {12, 0},
+		ResampleMin:     {12, 0},
+		ResampleMax:     {18, 18},
+	} {
+		buf, pixi := newEditTestPixi(t)
+		if err := pixi.BuildOverviews(buf, 0, 2, resampling); err != nil {
+			t.Fatal(err)
+		}
+		for i, layerIndex := range []int{2, 3} {
+			coord := SampleCoordinate{3, 1}
+			if i == 1 {
+				coord = SampleCoordinate{1, 0}
+			}
+			layer := NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), pixi.Header, pixi.Layers[layerIndex], 4)
+			if sample, err := SampleAt(layer, coord); err != nil || sample[0] != expected[i] {
+				t.Errorf("%v: layer %d sample %v: expected %d, got %v (%v)", resampling, layerIndex, coord, expected[i], sample, err)
+			}
+		}
+	}
+
+	// missing samples are left out of blocks, which are missing if none remain, as are unwritten tiles
+	for _, opts := range [][]LayerOption{nil, {WithSparseTiles()}} {
+		buf := buffer.NewBuffer(10)
+		values := []float32{-1, -1, 2, float32(math.NaN()), 4}
+		layers := []Layer{NewLayer("series", DimensionSet{{Name: "t", Size: 5, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelFloat32, FillValue: float32(-1)}}, opts...)}
+		pixi := writeTestPixi(t, buf, NewHeader(binary.BigEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+			return Sample{values[coord[0]]}
+		})
+		if err := pixi.BuildOverviews(buf, 0, 1, ResampleMean); err != nil {
+			t.Fatal(err)
+		}
+		layer := NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), pixi.Header, pixi.Layers[1], 2)
+		for i, want := range []float32{-1, 2, 4} {
+			if sample, err := SampleAt(layer, SampleCoordinate{i}); err != nil || sample[0] != want {
+				t.Errorf("sample %d: expected %v, got %v (%v)", i, want, sample, err)
+			}
+		}
+	}
+}
+
+func TestBuildOverviewsInvalid(t *testing.T) {
+	buf, pixi := newEditTestPixi(t)
+	if err := pixi.BuildOverviews(buf, 0, 0, ResampleMean); err == nil {
+		t.Error("expected zero levels to be rejected")
+	}
+	if err := pixi.BuildOverviews(buf, 0, 1, Resampling(10)); !errors.As(err, new(ErrUnsupported)) {
+		t.Errorf("expected an unknown resampling to be unsupported, got %v", err)
+	}
+	if err := pixi.BuildOverviews(buf, 0, 1, ResampleNearest); err != nil {
+		t.Fatal(err)
+	}
+	if err := pixi.BuildOverviews(buf, 0, 1, ResampleNearest); !errors.As(err, new(ErrUnsupported)) {
+		t.Errorf("expected overviews to be built only once, got %v", err)
+	}
+	if err := pixi.BuildOverviews(buf, 2, 1, ResampleNearest); !errors.As(err, new(ErrUnsupported)) {
+		t.Errorf("expected an overview not to have overviews of its own, got %v", err)
+	}
+
+	file := newStringTestFile(t)
+	if err := file.BuildOverviews(file.Stream(), 0, 1, ResampleNearest); !errors.As(err, new(ErrUnsupported)) {
+		t.Errorf("expected overviews of string channels to be unsupported, got %v", err)
+	}
+	pixi.ReadOnly = true
+	if err := pixi.BuildOverviews(buf, 1, 1, ResampleNearest); !errors.As(err, new(ErrReadOnly)) {
+		t.Errorf("expected building overviews of a read-only file to fail, got %v", err)
+	}
+
+	for maxSize, levels := range map[int]int{1: 3, 2: 2, 4: 1, 7: 0} {
+		if n := OverviewLevels(pixi.Layers[0], maxSize); n != levels {
+			t.Errorf("expected %d levels to reach size %d, got %d", levels, maxSize, n)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package gopixi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// The number of tiles that may wait between each pair of pipeline stages unless another capacity is given
+// with WithStageBuffer.
+const DefaultStageBuffer int = 4
+
+// A tile passing through a Pipeline. Data holds the decoded tile in the layout of the source layer until
+// a stage replaces it; by the time it reaches the write stage it must be laid out as a tile of the
+// destination layer (as returned by Layer.DiskTileSize).
+type PipelineTile struct {
+	Index int // The index of the tile in both the source and destination layers.
+	Data  []byte
+}
+
+// A user transform inserted into a Pipeline between the decode and encode stages. Stages run in their
+// own goroutines and may modify the tile data in place.
+type TileStage func(ctx context.Context, tile PipelineTile) (PipelineTile, error)
+
+type pipelineOptions struct {
+	buffer int
+}
+
+type PipelineOption interface {
+	applyPipeline(*pipelineOptions)
+}
+
+type stageBufferOption struct {
+	buffer int
+}
+
+func (o stageBufferOption) applyPipeline(opts *pipelineOptions) {
+	opts.buffer = o.buffer
+}
+
+// Sets the number of tiles that may wait between each pair of stages. Smaller buffers use less memory
+// but give stages less slack to absorb variation in each others' speed.
+func WithStageBuffer(tiles int) PipelineOption {
+	return stageBufferOption{buffer: max(tiles, 0)}
+}
+
+// Copies a layer from one file into another through a chain of concurrent stages: read, decode, any user
+// transforms, then encode and write. At most a fixed number of tiles (set with WithStageBuffer) are held
+// between each pair of stages.
+type Pipeline struct {
+	stages []TileStage
+	buffer int
+}
+
+// Creates a pipeline that applies the given transforms, in order, to every tile.
+func NewPipeline(stages []TileStage, opts ...PipelineOption) *Pipeline {
+	options := pipelineOptions{buffer: DefaultStageBuffer}
+	for _, o := range opts {
+		o.applyPipeline(&options)
+	}
+	return &Pipeline{stages: slices.Clone(stages), buffer: options.buffer}
+}
+
+// Returns a new pipeline that applies the given transform after all of this pipeline's transforms.
+func (p *Pipeline) Then(stage TileStage) *Pipeline {
+	return &Pipeline{stages: append(slices.Clone(p.stages), stage), buffer: p.buffer}
+}
+
+// Runs every tile of srcLayer, read from src, through the pipeline and appends the results to dst as
+// dstLayer, which must have the same dimensions and tiling as the source. Tiles missing from the source are
+// skipped. The first error from any stage cancels the others and is returned.
+func (p *Pipeline) Run(ctx context.Context, src io.ReadSeeker, srcHeader Header, srcLayer Layer, dst *Pixi, w io.WriteSeeker, dstLayer Layer) error {
+	if dst.ReadOnly {
+		return ErrReadOnly{Operation: "append layer"}
+	}
+	if srcLayer.DiskTiles() != dstLayer.DiskTiles() || srcLayer.Separated != dstLayer.Separated {
+		return ErrFormat("pipeline destination layer must have the same tiling as the source layer")
+	}
+	for i, dim := range srcLayer.Dimensions {
+		if i >= len(dstLayer.Dimensions) || dim.Size != dstLayer.Dimensions[i].Size || dim.TileSize != dstLayer.Dimensions[i].TileSize {
+			return ErrFormat("pipeline destination layer must have the same dimensions as the source layer")
+		}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var wg sync.WaitGroup
+	fail := func(err error) {
+		cancel(err)
+	}
+	send := func(out chan<- PipelineTile, tile PipelineTile) bool {
+		select {
+		case out <- tile:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// read: the source stream is only touched by this stage, one tile at a time
+	type rawTile struct {
+		index int
+		data  []byte
+	}
+	raw := make(chan rawTile, p.buffer)
+	wg.Go(func() {
+		defer close(raw)
+		for tile := range srcLayer.DiskTiles() {
+			if srcLayer.TileBytes[tile] == 0 {
+				continue
+			}
+			tileRange := srcLayer.TileRange(srcHeader, tile)
+			data := make([]byte, tileRange.Length)
+			if _, err := src.Seek(tileRange.Offset, io.SeekStart); err != nil {
+				fail(err)
+				return
+			}
+			if _, err := io.ReadFull(src, data); err != nil {
+				fail(err)
+				return
+			}
+			select {
+			case raw <- rawTile{index: tile, data: data}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	// decode
+	decoded := make(chan PipelineTile, p.buffer)
+	wg.Go(func() {
+		defer close(decoded)
+		for tile := range raw {
+			data := make([]byte, srcLayer.DiskTileSize(tile.index))
+			prefetched := newPrefetchedReader([]ByteRange{srcLayer.TileRange(srcHeader, tile.index)}, [][]byte{tile.data})
+			if err := srcLayer.ReadTile(prefetched, srcHeader, tile.index, data); err != nil {
+				fail(err)
+				return
+			}
+			if !send(decoded, PipelineTile{Index: tile.index, Data: data}) {
+				return
+			}
+		}
+	})
+
+	// user transforms
+	in := (<-chan PipelineTile)(decoded)
+	for _, stage := range p.stages {
+		out := make(chan PipelineTile, p.buffer)
+		stageIn := in
+		wg.Go(func() {
+			defer close(out)
+			for tile := range stageIn {
+				index := tile.Index
+				tile, err := stage(ctx, tile)
+				if err != nil {
+					fail(err)
+					return
+				}
+				if tile.Index != index {
+					fail(ErrFormat(fmt.Sprintf("pipeline stage changed tile index %d to %d", index, tile.Index)))
+					return
+				}
+				if !send(out, tile) {
+					return
+				}
+			}
+		})
+		in = out
+	}
+
+	// encode and write, in tile order, to the end of the destination
+	dstLayer.Channels = dstLayer.Channels.withoutDistributions()
+	dstLayer.TileBytes = make([]int64, dstLayer.DiskTiles())
+	dstLayer.TileOffsets = make([]int64, dstLayer.DiskTiles())
+	dstLayer.StringBytes = dstLayer.emptyStringBytes()
+	dstLayer.NextLayerStart = 0
+	wg.Go(func() {
+		encoder := dst.newTileEncoder(len(dst.Layers))
+		if _, err := w.Seek(0, io.SeekEnd); err != nil {
+			fail(err)
+			return
+		}
+		for tile := range in {
+			if !dstLayer.validTileSize(tile.Index, len(tile.Data)) {
+				fail(ErrFormat(fmt.Sprintf("pipeline tile %d has %d bytes, expected %d", tile.Index, len(tile.Data), dstLayer.slotsTileSize(tile.Index))))
+				return
+			}
+			dstLayer.updateTileStatistics(dst.Header, tile.Index, tile.Data)
+			if err := dstLayer.writeTileWith(encoder, w, dst.Header, tile.Index, tile.Data); err != nil {
+				fail(err)
+				return
+			}
+		}
+	})
+
+	wg.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+	return dst.appendLayerHeader(w, dstLayer)
+}
+
+// Widens the Min/Max statistics of the layer's channels to include the samples of the given decoded tile,
+// ignoring the padding samples of tiles that extend past the edge of the layer.
+func (l Layer) updateTileStatistics(h Header, tileIndex int, data []byte) {
+	dims := l.Dimensions
+	selectorTile := tileIndex % dims.Tiles()
+	for inTile := range dims.TileSamples() {
+		coord := TileSelector{Tile: selectorTile, InTile: inTile}.ToTileCoordinate(dims).ToSampleCoordinate(dims)
+		if !dims.ContainsCoordinate(coord) {
+			continue
+		}
+		if l.Separated {
+			channelIndex := tileIndex / dims.Tiles()
+			channel := l.Channels[channelIndex]
+			var value any
+			if channel.Type == ChannelBool {
+				value = UnpackBool(data, inTile)
+			} else {
+				value = channel.Value(data[inTile*channel.Size():], h.ByteOrder)
+			}
+			l.Channels[channelIndex] = channel.withStatistics(value)
+		} else {
+			offset := inTile * l.Channels.Size()
+			for channelIndex, channel := range l.Channels {
+				l.Channels[channelIndex] = channel.withStatistics(channel.Value(data[offset:], h.ByteOrder))
+				offset += channel.Size()
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package gopixi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func newPipelineTestSource(t *testing.T) (*buffer.Buffer, *Pixi) {
+	t.Helper()
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("source", DimensionSet{{Name: "x", Size: 30, TileSize: 4}, {Name: "y", Size: 6, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint16}}, WithCompression(CompressionFlate))}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint16(coord[0] + coord[1]*30)}
+	})
+	return buf, written
+}
+
+func TestPipelineRun(t *testing.T) {
+	srcBuf, src := newPipelineTestSource(t)
+	srcLayer := src.Layers[0]
+
+	// doubles every value, then casts to a float32 channel
+	double := func(ctx context.Context, tile PipelineTile) (PipelineTile, error) {
+		for i := 0; i < len(tile.Data); i += 2 {
+			binary.LittleEndian.PutUint16(tile.Data[i:], binary.LittleEndian.Uint16(tile.Data[i:])*2)
+		}
+		return tile, nil
+	}
+	dstLayer := NewLayer("derived", srcLayer.Dimensions, ChannelSet{{Name: "f", Type: ChannelFloat32}})
+	cast := func(ctx context.Context, tile PipelineTile) (PipelineTile, error) {
+		out := make([]byte, len(tile.Data)*2)
+		for i := 0; i < len(tile.Data); i += 2 {
+			dstLayer.Channels[0].PutValue(float32(binary.LittleEndian.Uint16(tile.Data[i:])), binary.LittleEndian, out[i*2:])
+		}
+		return PipelineTile{Index: tile.Index, Data: out}, nil
+	}
+
+	dstBuf := buffer.NewBuffer(10)
+	dst, err := Create(dstBuf, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline := NewPipeline([]TileStage{double}, WithStageBuffer(1)).Then(cast)
+	err = pipeline.Run(context.Background(), buffer.NewBufferFrom(srcBuf.Bytes()), src.Header, srcLayer, dst, dstBuf, dstLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadPixi(buffer.NewBufferFrom(dstBuf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := read.Layers[0]
+	if layer.Channels[0].Min != float32(0) || layer.Channels[0].Max != float32(2*(29+5*30)) {
+		t.Errorf("unexpected statistics %v/%v", layer.Channels[0].Min, layer.Channels[0].Max)
+	}
+	samples, err := layer.ReadRegion(buffer.NewBufferFrom(dstBuf.Bytes()), read.Header, FullRegion(layer.Dimensions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := 0
+	for coord := range FullRegion(layer.Dimensions).Coordinates() {
+		if expected := float32(2 * (coord[0] + coord[1]*30)); samples[i][0] != expected {
+			t.Errorf("sample %v: expected %v, got %v", coord, expected, samples[i][0])
+		}
+		i++
+	}
+}
+
+func TestPipelineBackPressure(t *testing.T) {
+	srcBuf, src := newPipelineTestSource(t)
+	var produced, consumed, peak atomic.Int64
+	fast := func(ctx context.Context, tile PipelineTile) (PipelineTile, error) {
+		ahead := produced.Add(1) - consumed.Load()
+		for {
+			current := peak.Load()
+			if ahead <= current || peak.CompareAndSwap(current, ahead) {
+				break
+			}
+		}
+		return tile, nil
+	}
+	slow := func(ctx context.Context, tile PipelineTile) (PipelineTile, error) {
+		time.Sleep(2 * time.Millisecond)
+		consumed.Add(1)
+		return tile, nil
+	}
+
+	dstBuf := buffer.NewBuffer(10)
+	dst, err := Create(dstBuf, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstLayer := NewLayer("copy", src.Layers[0].Dimensions, src.Layers[0].Channels)
+	err = NewPipeline([]TileStage{fast, slow}, WithStageBuffer(1)).Run(context.Background(), buffer.NewBufferFrom(srcBuf.Bytes()), src.Header, src.Layers[0], dst, dstBuf, dstLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one tile waiting in the channel, one held by each of the two stages
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 tiles between the fast and slow stages, got %d", peak.Load())
+	}
+	if consumed.Load() != int64(src.Layers[0].DiskTiles()) {
+		t.Errorf("expected all %d tiles to be processed, got %d", src.Layers[0].DiskTiles(), consumed.Load())
+	}
+}
+
+func TestPipelineStageError(t *testing.T) {
+	srcBuf, src := newPipelineTestSource(t)
+	failure := errors.New("stage failed")
+	failing := func(ctx context.Context, tile PipelineTile) (PipelineTile, error) {
+		if tile.Index == 3 {
+			return tile, failure
+		}
+		return tile, nil
+	}
+	dstBuf := buffer.NewBuffer(10)
+	dst, err := Create(dstBuf, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstLayer := NewLayer("copy", src.Layers[0].Dimensions, src.Layers[0].Channels)
+	err = NewPipeline([]TileStage{failing}).Run(context.Background(), buffer.NewBufferFrom(srcBuf.Bytes()), src.Header, src.Layers[0], dst, dstBuf, dstLayer)
+	if !errors.Is(err, failure) {
+		t.Errorf("expected stage error, got %v", err)
+	}
+	if len(dst.Layers) != 0 {
+		t.Error("expected no layer to be appended after a failure")
+	}
+
+	wrongDims := NewLayer("copy", DimensionSet{{Name: "x", Size: 30, TileSize: 8}, {Name: "y", Size: 6, TileSize: 4}}, src.Layers[0].Channels)
+	err = NewPipeline(nil).Run(context.Background(), buffer.NewBufferFrom(srcBuf.Bytes()), src.Header, src.Layers[0], dst, dstBuf, wrongDims)
+	if err == nil {
+		t.Error("expected mismatched tiling to be rejected")
+	}
+}
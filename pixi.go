@@ -4,12 +4,21 @@ import (
 	"fmt"
 	"io"
 	"maps"
+	"math"
 	"slices"
 )
 
 const (
 	FileType string = "pixi" // Every file starts with these four bytes.
-	Version  int    = 1      // Every file has a version number as the second set of four bytes.
+	Version  int    = 3      // Every file has a version number as the second set of four bytes.
+
+	VersionLongStrings int = 2 // The first version in which friendly strings may be longer than MaxFriendlyLength.
+	// The first version in which the header records the checksum algorithm of tiles, dimensions record the
+	// halo stored around each tile, and layer headers may hold the optional sections flagged in their
+	// configuration, dimension axis types, and channel types: string tables, attributes, axis coordinates,
+	// shared axis references and calendars, string channels, georeferences, fill values, and the moments and
+	// histograms of channels.
+	VersionExtensions int = 3
 )
 
 // Represents a single pixi file composed of one or more layers. Functions as a handle
@@ -18,11 +27,113 @@ type Pixi struct {
 	Header Header       // The metadata about the file version and how to read information from the file.
 	Layers []Layer      // The metadata information about each layer in the file.
 	Tags   []TagSection // The string tags of the file, broken up into sections for easy appending.
+
+	// If true, the file was opened in read-only mode and all mutating operations return ErrReadOnly.
+	ReadOnly bool
+	// The number of zeroed bytes reserved after each layer header written to the file, allowing later
+	// metadata edits (renames, added axes, updated statistics) to be done in place without relocating
+	// the header to the end of the file.
+	HeaderPadding int
+	// If greater than zero, an embedded preview layer no larger than this in any dimension is generated
+	// automatically after the first layer is appended to the file.
+	PreviewSize int
+	// If true, the prior versions of tiles replaced with RewriteTiles are retained and recorded as
+	// generations, so that earlier states of the file can be audited.
+	TileHistory bool
+	// If true, writes to the file are byte-for-byte reproducible: the same sequence of writes with the same
+	// input always produces an identical file, so content-addressed storage and reproducible pipelines can
+	// rely on stable hashes. Generations are recorded without a commit time, and headers rewritten in place
+	// zero any bytes left over from the header they replace.
+	Deterministic bool
+	// If set, the decoded tiles of layers opened with ReadLayer are cached here, shared by every read of the
+	// file, rather than only by the access layer each read opens.
+	TileCache *TileCache
+	// If set, the hooks run as the tiles and layers of the file are written.
+	WriteHooks *WriteHooks
+	// If set, the tiles and regions of the file read by a TileServer or StatsServer are recorded here, so
+	// that operators can see which parts of the file are most in demand.
+	AccessStats *AccessStats
+
+	// set when the layer being appended has a provisional header written by Checkpoint
+	checkpointed bool
+	// the transforms applied by ReadLayer, by layer name
+	readTransforms map[string][]ReadTransform
+}
+
+type createOptions struct {
+	headerPadding int
+	previewSize   int
+	tileHistory   bool
+	deterministic bool
+	writeHooks    *WriteHooks
+}
+
+type CreateOption interface {
+	applyCreate(*createOptions)
+}
+
+type headerPaddingOption struct {
+	padding int
+}
+
+func (o headerPaddingOption) applyCreate(opts *createOptions) {
+	opts.headerPadding = o.padding
+}
+
+// Reserves the given number of bytes of slack space after each layer header written to the file.
+func WithHeaderPadding(bytes int) CreateOption {
+	return headerPaddingOption{padding: max(bytes, 0)}
+}
+
+type deterministicOption struct{}
+
+func (o deterministicOption) applyCreate(opts *createOptions) {
+	opts.deterministic = true
+}
+
+// Makes every write to the file reproducible, as described for Pixi.Deterministic. Tags are always written
+// in order of their keys, and every compression is used with fixed parameters, regardless of this option.
+func WithDeterministicWrites() CreateOption {
+	return deterministicOption{}
+}
+
+// Starts a new Pixi file by writing the given header to the start of the stream, returning a handle to
+// which tags and layers can then be appended.
+func Create(w io.WriteSeeker, header Header, opts ...CreateOption) (*Pixi, error) {
+	options := createOptions{}
+	for _, o := range opts {
+		o.applyCreate(&options)
+	}
+
+	_, err := w.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	err = header.WriteHeader(w)
+	if err != nil {
+		return nil, err
+	}
+	return &Pixi{
+		Header:        header,
+		Layers:        make([]Layer, 0),
+		Tags:          make([]TagSection, 0),
+		HeaderPadding: options.headerPadding,
+		PreviewSize:   options.previewSize,
+		TileHistory:   options.tileHistory,
+		Deterministic: options.deterministic,
+		WriteHooks:    options.writeHooks,
+	}, nil
 }
 
 // Convenience function to read all the metadata information from a Pixi file into a single
-// containing struct.
+// containing struct. If the header at the start of the file references no layers or tags and
+// the file ends with a footer (as written by streaming writers), the metadata is read from the
+// footer instead.
 func ReadPixi(r io.ReadSeeker) (*Pixi, error) {
+	return readPixi(r, true)
+}
+
+func readPixi(r io.ReadSeeker, checkFooter bool) (*Pixi, error) {
 	pixi := &Pixi{
 		Header: Header{},
 		Layers: make([]Layer, 0),
@@ -37,6 +148,10 @@ func ReadPixi(r io.ReadSeeker) (*Pixi, error) {
 		return pixi, ErrFormat(fmt.Sprintf("reading pixi header: %s", err))
 	}
 
+	if checkFooter && pixi.Header.FirstLayerOffset == 0 && pixi.Header.FirstTagsOffset == 0 && HasFooter(r) {
+		return ReadFooter(r)
+	}
+
 	layerOffset := pixi.Header.FirstLayerOffset
 	for layerOffset != 0 {
 		if slices.Contains(seenOffsets, layerOffset) {
@@ -74,6 +189,8 @@ func ReadPixi(r io.ReadSeeker) (*Pixi, error) {
 		pixi.Tags = append(pixi.Tags, rdTags)
 		tagOffset = rdTags.NextTagsStart
 	}
+	pixi.TileHistory = len(pixi.Generations()) > 0
+	pixi.resolveAxes()
 
 	return pixi, nil
 }
@@ -100,14 +217,19 @@ func (d *Pixi) DiskDataBytes() int64 {
 
 // Appends a new tag section to the end of the file with the given tags.
 func (p *Pixi) AppendTags(w io.WriteSeeker, tags map[string]string) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append tags"}
+	}
+	return p.appendTagSection(w, TagSection{Tags: tags})
+}
+
+// Writes the tag section to the end of the file and links it after the last tag section of the file.
+func (p *Pixi) appendTagSection(w io.WriteSeeker, newTagSection TagSection) error {
 	// Append the new tag section to the end of the file
 	tagSectionStart, err := w.Seek(0, io.SeekEnd)
 	if err != nil {
 		return err
 	}
-	newTagSection := TagSection{
-		Tags: tags,
-	}
 	err = newTagSection.Write(w, p.Header)
 	if err != nil {
 		return err
@@ -143,6 +265,20 @@ func (p *Pixi) AppendTags(w io.WriteSeeker, tags map[string]string) error {
 
 // Appends a new layer to the end of the file, using the provided generator function for writing samples to the layer.
 func (p *Pixi) AppendIterativeLayer(w io.WriteSeeker, layer Layer, writer IterativeLayerWriter, generator func(writer IterativeLayerWriter) error) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append layer"}
+	}
+	if layer.Dimensions.HasHalo() {
+		return ErrUnsupported("layers with halos must be appended with AppendHaloLayer")
+	}
+	if err := layer.checkStrings(); err != nil {
+		return err
+	}
+	p.checkpointed = false
+	if hooked, ok := writer.(hookedLayerWriter); ok {
+		hooked.setWriteHooks(p.WriteHooks, len(p.Layers))
+	}
+
 	// append the new layer to the end of the file
 	_, err := w.Seek(0, io.SeekEnd)
 	if err != nil {
@@ -158,6 +294,79 @@ func (p *Pixi) AppendIterativeLayer(w io.WriteSeeker, layer Layer, writer Iterat
 		return err
 	}
 
+	if p.checkpointed {
+		p.checkpointed = false
+		if err = p.UpdateLayerHeader(w, len(p.Layers)-1, layer); err == nil {
+			err = p.layerFinalized(len(p.Layers) - 1)
+		}
+	} else {
+		err = p.appendLayerHeader(w, layer)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, hasPreview := p.Preview(); p.PreviewSize > 0 && !hasPreview {
+		rw, ok := w.(io.ReadWriteSeeker)
+		if !ok {
+			return ErrUnsupported("automatic preview generation requires a readable stream")
+		}
+		return p.AppendPreview(rw, len(p.Layers)-1, p.PreviewSize)
+	}
+	return nil
+}
+
+// Makes everything written so far by the writer of a layer being appended with AppendIterativeLayer durable
+// and readable by committing a provisional header, in which tiles not yet written are absent. Must be called
+// from within the generator. Streams supporting Sync (as *os.File does) are synced.
+func (p *Pixi) Checkpoint(w io.WriteSeeker, writer IterativeLayerWriter) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "checkpoint layer"}
+	}
+	flusher, ok := writer.(FlushableLayerWriter)
+	if !ok {
+		return ErrUnsupported("checkpointing requires a writer that can be flushed")
+	}
+	if err := flusher.Flush(); err != nil {
+		return err
+	}
+
+	// the writer is idle until the next tile completes, so the headers can be written safely
+	layer := writer.Layer()
+	var err error
+	if p.checkpointed {
+		err = p.UpdateLayerHeader(w, len(p.Layers)-1, layer)
+	} else {
+		err = p.linkLayerHeader(w, layer)
+	}
+	if err != nil {
+		return err
+	}
+	p.checkpointed = true
+
+	if syncer, ok := w.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			return err
+		}
+	}
+
+	// subsequent tiles are written after everything else in the file
+	_, err = w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// Writes the header of a layer whose tiles have already been written to the end of the file, and links
+// it into the chain of layers by updating the previous layer (or the file header if this is the first).
+// The layer is then complete, and the OnLayerFinalized hook of the file is run for it.
+func (p *Pixi) appendLayerHeader(w io.WriteSeeker, layer Layer) error {
+	if err := p.linkLayerHeader(w, layer); err != nil {
+		return err
+	}
+	return p.layerFinalized(len(p.Layers) - 1)
+}
+
+// Writes and links the header of a layer as in appendLayerHeader, without running any hooks.
+func (p *Pixi) linkLayerHeader(w io.WriteSeeker, layer Layer) error {
 	// write out the layer metadata
 	layerStart, err := w.Seek(0, io.SeekEnd)
 	if err != nil {
@@ -167,6 +376,10 @@ func (p *Pixi) AppendIterativeLayer(w io.WriteSeeker, layer Layer, writer Iterat
 	if err != nil {
 		return err
 	}
+	err = p.writeHeaderPadding(w)
+	if err != nil {
+		return err
+	}
 
 	// update the previous layer (or the header if this is the first)
 	if len(p.Layers) == 0 {
@@ -195,3 +408,159 @@ func (p *Pixi) AppendIterativeLayer(w io.WriteSeeker, layer Layer, writer Iterat
 	p.Layers = append(p.Layers, layer)
 	return nil
 }
+
+// The offset in the file at which the header of the layer with the given index starts.
+func (p *Pixi) layerHeaderOffset(layerIndex int) int64 {
+	if layerIndex == 0 {
+		return p.Header.FirstLayerOffset
+	}
+	return p.Layers[layerIndex-1].NextLayerStart
+}
+
+// Writes the reserved slack space after a layer header.
+func (p *Pixi) writeHeaderPadding(w io.Writer) error {
+	if p.HeaderPadding <= 0 {
+		return nil
+	}
+	_, err := w.Write(make([]byte, p.HeaderPadding))
+	return err
+}
+
+// The number of bytes available for rewriting the header of the given layer in place. This is the distance
+// from the start of the header to the next structure referenced in the file (or the end of the file), so it
+// includes any padding reserved when the header was written as well as any dead space that follows it.
+// A header at the very end of the file can always be rewritten in place. The tiles of the replacement
+// layer are also considered, as they may have been written after the header.
+func (p *Pixi) layerHeaderCapacity(w io.Seeker, layerIndex int, replacement Layer) (int64, error) {
+	fileEnd, err := w.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	headerStart := p.layerHeaderOffset(layerIndex)
+	next := fileEnd
+	consider := func(offset int64) {
+		if offset > headerStart && offset < next {
+			next = offset
+		}
+	}
+	consider(int64(p.Header.DiskSize()))
+	tagOffset := p.Header.FirstTagsOffset
+	for _, t := range p.Tags {
+		consider(tagOffset)
+		tagOffset = t.NextTagsStart
+	}
+	for i, l := range p.Layers {
+		consider(p.layerHeaderOffset(i))
+		for tile, offset := range l.TileOffsets {
+			if l.TileBytes[tile] != 0 {
+				consider(offset)
+			}
+		}
+	}
+	for tile, offset := range replacement.TileOffsets {
+		if replacement.TileBytes[tile] != 0 {
+			consider(offset)
+		}
+	}
+	for _, v := range p.retainedTileVersions() {
+		consider(v.Offset)
+	}
+	if next == fileEnd {
+		// nothing follows the header, so it can grow freely into the end of the file
+		return math.MaxInt64, nil
+	}
+	return next - headerStart, nil
+}
+
+// Replaces the header of an existing layer in the file with the given layer description, without touching
+// any tile data. The header is overwritten in place if it fits, and otherwise written to the end of the file
+// and linked in place of the old one.
+func (p *Pixi) UpdateLayerHeader(w io.WriteSeeker, layerIndex int, layer Layer) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "update layer header"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	p.TileCache.evictLayer(p, layerIndex)
+
+	oldLayer := p.Layers[layerIndex]
+	layer.NextLayerStart = oldLayer.NextLayerStart
+	headerStart := p.layerHeaderOffset(layerIndex)
+	capacity, err := p.layerHeaderCapacity(w, layerIndex, layer)
+	if err != nil {
+		return err
+	}
+
+	if int64(layer.HeaderSize(p.Header)) <= capacity {
+		_, err := w.Seek(headerStart, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		err = layer.WriteHeader(w, p.Header)
+		if err != nil {
+			return err
+		}
+		if leftover := oldLayer.HeaderSize(p.Header) - layer.HeaderSize(p.Header); p.Deterministic && leftover > 0 {
+			if _, err := w.Write(make([]byte, leftover)); err != nil {
+				return err
+			}
+		}
+		p.Layers[layerIndex] = layer
+		return nil
+	}
+
+	// relocate the header to the end of the file, then relink the previous layer (or file header) to it
+	newStart, err := w.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	err = layer.WriteHeader(w, p.Header)
+	if err != nil {
+		return err
+	}
+	err = p.writeHeaderPadding(w)
+	if err != nil {
+		return err
+	}
+
+	if layerIndex == 0 {
+		err = p.Header.OverwriteOffsets(w, newStart, p.Header.FirstTagsOffset)
+		if err != nil {
+			return err
+		}
+	} else {
+		prevLayer := p.Layers[layerIndex-1]
+		prevLayer.NextLayerStart = newStart
+		err = prevLayer.OverwriteHeader(w, p.Header, p.layerHeaderOffset(layerIndex-1))
+		if err != nil {
+			return err
+		}
+		p.Layers[layerIndex-1] = prevLayer
+	}
+
+	p.Layers[layerIndex] = layer
+	return nil
+}
+
+// The number of bytes in the file referenced by the header, tag sections, layer headers, and tiles
+// (including their checksums and any retained prior versions). Any remaining bytes in the file are reserved header padding or dead space
+// left behind by edits, which can be reclaimed by compacting the file with Compact.
+func (p *Pixi) LiveBytes() int64 {
+	size := int64(p.Header.DiskSize())
+	for _, t := range p.Tags {
+		size += int64(t.DiskSize(p.Header))
+	}
+	for _, l := range p.Layers {
+		size += int64(l.HeaderSize(p.Header))
+		for _, b := range l.TileBytes {
+			if b != 0 {
+				size += b + int64(p.Header.Checksum.Size())
+			}
+		}
+	}
+	for _, v := range p.retainedTileVersions() {
+		size += v.Bytes + int64(p.Header.Checksum.Size())
+	}
+	return size
+}
@@ -1,7 +1,13 @@
 package gopixi
 
 import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
 	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
 )
 
 func TestPixiSamples(t *testing.T) {
@@ -127,3 +133,204 @@ func TestPixiTiles(t *testing.T) {
 		})
 	}
 }
+
+// Writes a complete Pixi stream to w with the given tags and layers, filling each sample of each layer with
+// the values returned by the sample function. Returns the metadata describing the written stream.
+func writeTestPixi(t *testing.T, w io.WriteSeeker, header Header, tags map[string]string, layers []Layer, sample func(layer int, coord SampleCoordinate) Sample) *Pixi {
+	t.Helper()
+	err := header.WriteHeader(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pixi := &Pixi{Header: header}
+	if len(tags) > 0 {
+		err = pixi.AppendTags(w, tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for layerIndex, layer := range layers {
+		writer := NewTileOrderWriteIterator(w, pixi.Header, layer)
+		err = pixi.AppendIterativeLayer(w, layer, writer, func(writer IterativeLayerWriter) error {
+			for writer.Next() {
+				coord := writer.Coordinate()
+				if layer.Dimensions.ContainsCoordinate(coord) {
+					writer.SetSample(sample(layerIndex, coord))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return pixi
+}
+
+func TestCreateWithHeaderPadding(t *testing.T) {
+	for _, padding := range []int{0, 128} {
+		buf := buffer.NewBuffer(10)
+		pixi, err := Create(buf, NewHeader(binary.LittleEndian, OffsetSize4), WithHeaderPadding(padding))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range 2 {
+			layer := NewLayer("layer", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+			writer := NewTileOrderWriteIterator(buf, pixi.Header, layer)
+			err = pixi.AppendIterativeLayer(buf, layer, writer, func(writer IterativeLayerWriter) error {
+				for writer.Next() {
+					writer.SetSample(Sample{uint8(writer.Coordinate()[0] + i)})
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		sizeBefore := len(buf.Bytes())
+		err = pixi.RenameLayer(buf, 0, "a considerably longer name for the first layer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		grew := len(buf.Bytes()) > sizeBefore
+		if padding > 0 && grew {
+			t.Errorf("expected rename to fit in %d bytes of header padding, file grew from %d to %d", padding, sizeBefore, len(buf.Bytes()))
+		} else if padding == 0 && !grew {
+			t.Error("expected rename without header padding to relocate the header")
+		}
+
+		read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read.Layers) != 2 || read.Layers[0].Name != "a considerably longer name for the first layer" {
+			t.Fatalf("unexpected layers after rename %v", read.Layers)
+		}
+		data := NewFifoCacheReadLayer(buffer.NewBufferFrom(buf.Bytes()), read.Header, read.Layers[1], 2)
+		value, err := ChannelAt(data, SampleCoordinate{3}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != uint8(4) {
+			t.Errorf("expected second layer value 4, got %v", value)
+		}
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	pixi, err := Create(buf, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("layer", DimensionSet{{Name: "x", Size: 8, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	writer := NewTileOrderWriteIterator(buf, pixi.Header, layer)
+
+	var snapshots [][]byte
+	err = pixi.AppendIterativeLayer(buf, layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			x := writer.Coordinate()[0]
+			if x == 4 || x == 6 {
+				// the first two (then three) tiles are complete
+				if err := pixi.Checkpoint(buf, writer); err != nil {
+					return err
+				}
+				snapshots = append(snapshots, append([]byte{}, buf.Bytes()...))
+			}
+			writer.SetSample(Sample{uint8(x + 10)})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(data []byte, completeTiles int) {
+		t.Helper()
+		read, err := ReadPixi(buffer.NewBufferFrom(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read.Layers) != 1 {
+			t.Fatalf("expected 1 layer, got %d", len(read.Layers))
+		}
+		access := NewFifoCacheReadLayer(buffer.NewBufferFrom(data), read.Header, read.Layers[0], 4)
+		for x := range 8 {
+			sample, err := SampleAt(access, SampleCoordinate{x})
+			if x/2 < completeTiles {
+				if err != nil {
+					t.Fatalf("expected sample %d to be readable, got %v", x, err)
+				}
+				if sample[0] != uint8(x+10) {
+					t.Errorf("expected sample %d to be %d, got %v", x, x+10, sample[0])
+				}
+			} else if _, ok := err.(ErrTileNotFound); !ok {
+				t.Errorf("expected sample %d to be missing, got %v", x, err)
+			}
+		}
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 checkpoints, got %d", len(snapshots))
+	}
+	check(snapshots[0], 2)
+	check(snapshots[1], 3)
+	check(buf.Bytes(), 4)
+}
+
+func TestCreateWithDeterministicWrites(t *testing.T) {
+	write := func() []byte {
+		buf := buffer.NewBuffer(10)
+		p, err := Create(buf, NewHeader(binary.LittleEndian, OffsetSize8), WithDeterministicWrites(), WithTileHistory(), WithHeaderPadding(8))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tags := map[string]string{}
+		for i := range 20 {
+			tags[string(rune('a'+i))] = strings.Repeat("v", i)
+		}
+		if err := p.AppendTags(buf, tags); err != nil {
+			t.Fatal(err)
+		}
+		layer := NewLayer("a long layer name", DimensionSet{{Name: "x", Size: 16, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint16}}, WithCompression(CompressionFlate))
+		writer := NewTileOrderWriteIterator(buf, p.Header, layer)
+		err = p.AppendIterativeLayer(buf, layer, writer, func(writer IterativeLayerWriter) error {
+			for writer.Next() {
+				writer.SetSample(Sample{uint16(writer.Coordinate()[0] * 3)})
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.RewriteTiles(buf, 0, map[int][]byte{1: make([]byte, 8), 2: make([]byte, 8)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.RenameLayer(buf, 0, "short"); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	first := write()
+	for range 5 {
+		if !bytes.Equal(first, write()) {
+			t.Fatal("expected identical writes to produce identical files")
+		}
+	}
+	read, err := ReadPixi(buffer.NewBufferFrom(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generations := read.Generations(); len(generations) != 1 || !generations[0].Time.IsZero() {
+		t.Errorf("expected one generation without a commit time, got %v", generations)
+	}
+	if read.Layers[0].Name != "short" {
+		t.Errorf("expected renamed layer, got '%s'", read.Layers[0].Name)
+	}
+	// the rename shrank the header in place, leaving zeros where the old name ended
+	start := read.Header.FirstLayerOffset + int64(read.Layers[0].HeaderSize(read.Header))
+	if tail := first[start : start+int64(len("a long layer name")-len("short"))]; !bytes.Equal(tail, make([]byte, len(tail))) {
+		t.Errorf("expected leftover header bytes to be zeroed, got %v", tail)
+	}
+}
@@ -0,0 +1,215 @@
+package gopixi
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// How ExtractPoints samples a layer at axis values that lie between its samples.
+type PointSampling int
+
+const (
+	SampleNearest PointSampling = iota // The sample whose axis values are nearest to the point.
+	SampleLinear                       // Multilinear interpolation between the samples surrounding the point.
+)
+
+// A location at which ExtractPoints samples a layer, such as the latitude and longitude of a station: the
+// axis value of the location along each of the dimensions of the layer it names.
+type Point map[string]float64
+
+type extractOptions struct {
+	sampling PointSampling
+	ranges   map[string][2]float64
+}
+
+type ExtractOption interface {
+	applyExtract(*extractOptions)
+}
+
+type samplingOption struct {
+	sampling PointSampling
+}
+
+func (o samplingOption) applyExtract(opts *extractOptions) {
+	opts.sampling = o.sampling
+}
+
+// Sets how ExtractPoints samples between the samples of a layer; the default is SampleNearest.
+func WithSampling(sampling PointSampling) ExtractOption {
+	return samplingOption{sampling: sampling}
+}
+
+type seriesRangeOption struct {
+	dimension string
+	from, to  float64
+}
+
+func (o seriesRangeOption) applyExtract(opts *extractOptions) {
+	opts.ranges[o.dimension] = [2]float64{o.from, o.to}
+}
+
+// Limits the series extracted by ExtractPoints to the samples whose axis values along the named dimension
+// lie between the two values (inclusive, in either order), such as a range of times.
+func WithSeriesRange(dimension string, from float64, to float64) ExtractOption {
+	return seriesRangeOption{dimension: dimension, from: from, to: to}
+}
+
+// The series of values extracted at a point.
+type PointSeries struct {
+	Point Point
+	// False if the point lies outside the axis of any of its dimensions, in which case every value is missing.
+	InRange bool
+	// The values of each channel at every coordinate of the series region, in the order of its coordinates,
+	// with missing values as NaN.
+	Values [][]float64
+}
+
+// The series extracted at a list of points by ExtractPoints.
+type PointExtraction struct {
+	// The indices of the dimensions of the layer not named by the points, along which the series extend.
+	SeriesDimensions []int
+	// The indices along each series dimension covered by every series. Its coordinates list the position
+	// of each value of a series in the series dimensions.
+	Series Region
+	Points []PointSeries
+}
+
+// Extracts a series of values at each of the points from the source layer, such as the time series of a
+// list of stations. The points must all name the same dimensions of the layer, which must have regular
+// numeric axes, and every other dimension is extracted as a series, limited by any WithSeriesRange options.
+// Each tile is read once for all the points within it.
+func ExtractPoints(source Operand, points []Point, opts ...ExtractOption) (PointExtraction, error) {
+	options := extractOptions{ranges: map[string][2]float64{}}
+	for _, o := range opts {
+		o.applyExtract(&options)
+	}
+	if source.Layer == nil {
+		return PointExtraction{}, ErrFormat("points must be extracted from a layer")
+	}
+	if len(points) == 0 {
+		return PointExtraction{}, nil
+	}
+	layer := source.Layer.Layer()
+	dims := layer.Dimensions
+
+	// split the dimensions into those located by the points and those the series extend along
+	names := slices.Sorted(maps.Keys(points[0]))
+	pointDims := make([]int, len(names))
+	for i, name := range names {
+		if pointDims[i] = dims.Index(name); pointDims[i] < 0 {
+			return PointExtraction{}, ErrFormat(fmt.Sprintf("layer '%s' has no dimension '%s'", layer.Name, name))
+		}
+	}
+	extraction := PointExtraction{Points: make([]PointSeries, len(points))}
+	for d, dim := range dims {
+		if slices.Contains(pointDims, d) {
+			continue
+		}
+		start, end := 0, dim.Size
+		if r, ok := options.ranges[dim.Name]; ok {
+			if start, end, ok = dim.Select(r[0], r[1]); !ok {
+				return PointExtraction{}, ErrFormat(fmt.Sprintf("dimension '%s' has no regular numeric axis", dim.Name))
+			}
+		}
+		extraction.SeriesDimensions = append(extraction.SeriesDimensions, d)
+		extraction.Series.Start = append(extraction.Series.Start, start)
+		extraction.Series.End = append(extraction.Series.End, end)
+	}
+	for name := range options.ranges {
+		if d := dims.Index(name); d < 0 || slices.Contains(pointDims, d) {
+			return PointExtraction{}, ErrFormat(fmt.Sprintf("series range for '%s' is not a series dimension of layer '%s'", name, layer.Name))
+		}
+	}
+	steps := []SampleCoordinate{{}}
+	if len(extraction.SeriesDimensions) > 0 {
+		steps = steps[:0]
+		for step := range extraction.Series.Coordinates() {
+			steps = append(steps, slices.Clone(step))
+		}
+	}
+
+	// gather the weighted samples each value is computed from, so they can be read in tile order
+	type read struct {
+		tile   int
+		coord  SampleCoordinate
+		point  int
+		step   int
+		weight float64
+	}
+	reads := []read{}
+	for p, point := range points {
+		if len(point) != len(names) {
+			return PointExtraction{}, ErrFormat(fmt.Sprintf("point %d names different dimensions than the first", p))
+		}
+		series := PointSeries{Point: point, InRange: true, Values: make([][]float64, len(steps))}
+		for s := range steps {
+			series.Values[s] = make([]float64, len(layer.Channels))
+		}
+		corners := []read{{coord: make(SampleCoordinate, len(dims)), weight: 1}}
+		for i, name := range names {
+			value, ok := point[name]
+			if !ok {
+				return PointExtraction{}, ErrFormat(fmt.Sprintf("point %d names different dimensions than the first", p))
+			}
+			d := pointDims[i]
+			position, ok := dims[d].Locate(value)
+			if !ok {
+				return PointExtraction{}, ErrFormat(fmt.Sprintf("dimension '%s' has no regular numeric axis", name))
+			}
+			if !position.InRange {
+				series.InRange = false
+			}
+			if options.sampling == SampleNearest || position.Fraction == 0 {
+				for _, corner := range corners {
+					corner.coord[d] = position.Nearest()
+				}
+				continue
+			}
+			next := make([]read, 0, 2*len(corners))
+			for _, corner := range corners {
+				upper := read{coord: slices.Clone(corner.coord), weight: corner.weight * position.Fraction}
+				corner.coord[d], corner.weight = position.Index, corner.weight*(1-position.Fraction)
+				upper.coord[d] = position.Index + 1
+				next = append(next, corner, upper)
+			}
+			corners = next
+		}
+		extraction.Points[p] = series
+		if !series.InRange {
+			for _, values := range series.Values {
+				for c := range values {
+					values[c] = math.NaN()
+				}
+			}
+			continue
+		}
+		for s, step := range steps {
+			for _, corner := range corners {
+				coord := slices.Clone(corner.coord)
+				for i, d := range extraction.SeriesDimensions {
+					coord[d] = step[i]
+				}
+				reads = append(reads, read{tile: coord.ToTileSelector(dims).Tile, coord: coord, point: p, step: s, weight: corner.weight})
+			}
+		}
+	}
+	slices.SortStableFunc(reads, func(a, b read) int { return a.tile - b.tile })
+
+	for _, r := range reads {
+		sample, err := SampleAt(source.Layer, r.coord)
+		if err != nil {
+			return PointExtraction{}, err
+		}
+		values := extraction.Points[r.point].Values[r.step]
+		for c, channel := range layer.Channels {
+			value, ok := channel.Type.ToFloat64(sample[c])
+			if !ok || (source.Fill != nil && value == *source.Fill) {
+				value = math.NaN()
+			}
+			values[c] += r.weight * value
+		}
+	}
+	return extraction, nil
+}
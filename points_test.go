@@ -0,0 +1,88 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestExtractPoints(t *testing.T) {
+	dims := DimensionSet{
+		{Name: "lon", Size: 9, TileSize: 4, Axis: &Axis{Type: ChannelFloat64, Minimum: 10.0, Step: 0.5}},
+		{Name: "lat", Size: 6, TileSize: 4, Axis: &Axis{Type: ChannelFloat64, Minimum: 50.0, Step: -0.25}},
+		{Name: "time", Size: 5, TileSize: 2, Axis: &Axis{Type: ChannelInt32, Minimum: int32(1000), Step: int32(60)}},
+	}
+	value := func(coord SampleCoordinate) float64 { return float64(2*coord[0] + 3*coord[1] + 10*coord[2]) }
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{
+		NewLayer("temp", dims, ChannelSet{{Name: "t", Type: ChannelFloat32}, {Name: "q", Type: ChannelInt16}}, WithPlanar()),
+	}, func(layer int, coord SampleCoordinate) Sample {
+		if coord[0] == 8 && coord[1] == 5 {
+			return Sample{float32(-999), int16(-999)}
+		}
+		return Sample{float32(value(coord)), int16(coord[2])}
+	})
+	fill := -999.0
+	source := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 8), &fill)
+	points := []Point{
+		{"lon": 13.9, "lat": 49.6},  // nearest (8, 2), linear (7.8, 1.6)
+		{"lon": 10.0, "lat": 50.0},  // exactly the first sample
+		{"lon": 30.0, "lat": 49.0},  // outside the axes
+		{"lon": 14.0, "lat": 48.75}, // the missing sample
+	}
+
+	nearest, err := ExtractPoints(source, points, WithSeriesRange("time", 1170, 1060))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nearest.SeriesDimensions) != 1 || nearest.SeriesDimensions[0] != 2 || nearest.Series.Start[0] != 1 || nearest.Series.End[0] != 3 {
+		t.Fatalf("unexpected series %v of dimensions %v", nearest.Series, nearest.SeriesDimensions)
+	}
+	for s, step := range []int{1, 2} {
+		if v := nearest.Points[0].Values[s]; v[0] != value(SampleCoordinate{8, 2, step}) || v[1] != float64(step) {
+			t.Errorf("nearest at step %d: got %v", step, v)
+		}
+		if v := nearest.Points[1].Values[s]; v[0] != value(SampleCoordinate{0, 0, step}) {
+			t.Errorf("first sample at step %d: got %v", step, v)
+		}
+		if v := nearest.Points[2].Values[s]; nearest.Points[2].InRange || !math.IsNaN(v[0]) {
+			t.Errorf("expected the point outside the axes to be missing, got %v", v)
+		}
+		if v := nearest.Points[3].Values[s]; !math.IsNaN(v[0]) || !math.IsNaN(v[1]) {
+			t.Errorf("expected missing values, got %v", v)
+		}
+	}
+
+	linear, err := ExtractPoints(source, points[:2], WithSampling(SampleLinear))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(linear.Points[0].Values) != dims[2].Size {
+		t.Fatalf("expected the whole series, got %d values", len(linear.Points[0].Values))
+	}
+	for step, values := range linear.Points[0].Values {
+		want := 2*7.8 + 3*1.6 + 10*float64(step)
+		if math.Abs(values[0]-want) > 1e-9 || math.Abs(values[1]-float64(step)) > 1e-9 {
+			t.Errorf("linear at step %d: expected %v, got %v", step, want, values)
+		}
+	}
+	interpolatesMissing, err := ExtractPoints(source, []Point{{"lon": 13.8, "lat": 48.8}}, WithSampling(SampleLinear))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := interpolatesMissing.Points[0].Values[0][0]; !math.IsNaN(v) {
+		t.Errorf("expected interpolation with a missing sample to be missing, got %v", v)
+	}
+
+	if _, err := ExtractPoints(source, []Point{{"lon": 10}, {"lat": 50}}); err == nil {
+		t.Error("expected an error for points naming different dimensions")
+	}
+	if _, err := ExtractPoints(source, []Point{{"depth": 10}}); err == nil {
+		t.Error("expected an error for an unknown dimension")
+	}
+	if _, err := ExtractPoints(source, points, WithSeriesRange("lat", 0, 1)); err == nil {
+		t.Error("expected an error for a range over a point dimension")
+	}
+}
@@ -0,0 +1,104 @@
+package gopixi
+
+import (
+	"io"
+	"slices"
+)
+
+const (
+	PreviewLayerName   string = "pixi.preview" // The reserved name of the embedded preview layer.
+	DefaultPreviewSize int    = 256            // The default maximum size of each preview dimension.
+)
+
+type previewOption struct {
+	maxSize int
+}
+
+func (o previewOption) applyCreate(opts *createOptions) {
+	opts.previewSize = o.maxSize
+}
+
+// Automatically generates an embedded preview layer after the first layer is appended to the file,
+// downsampled so that no dimension is larger than maxSize. The stream the layer is appended to must
+// also be readable so the preview can be sampled from the written tiles.
+func WithPreview(maxSize int) CreateOption {
+	return previewOption{maxSize: max(maxSize, 1)}
+}
+
+// Creates the description of a preview layer for the source layer, with the same channels, in which every
+// dimension is downsampled by an integer stride to no larger than maxSize, stored as a single flate
+// compressed tile. Axes are adjusted to the axis values of the samples taken.
+func NewPreviewLayer(source Layer, maxSize int) Layer {
+	maxSize = max(maxSize, 1)
+	dims := make(DimensionSet, len(source.Dimensions))
+	for i, dim := range source.Dimensions {
+		stride := previewStride(dim, maxSize)
+		size := (dim.Size + stride - 1) / stride
+		dims[i] = Dimension{
+			Name:     dim.Name,
+			Size:     size,
+			TileSize: size,
+			Axis:     dim.Axis.strided(stride),
+		}
+	}
+
+	channels := slices.Clone(source.Channels)
+	for i := range channels {
+		channels[i].Min = nil
+		channels[i].Max = nil
+	}
+	return NewLayer(PreviewLayerName, dims, channels, WithCompression(CompressionFlate))
+}
+
+func previewStride(dim Dimension, maxSize int) int {
+	return max((dim.Size+maxSize-1)/maxSize, 1)
+}
+
+// Returns the embedded preview layer of the file, if it has one. The preview is an ordinary layer,
+// and can be read with any of the layer access methods, without touching the full-resolution tiles.
+func (p *Pixi) Preview() (Layer, bool) {
+	for _, layer := range p.Layers {
+		if layer.Name == PreviewLayerName {
+			return layer, true
+		}
+	}
+	return Layer{}, false
+}
+
+// Generates a preview of the layer at the given index by nearest-neighbor downsampling, and appends
+// it to the end of the file as the embedded preview layer. Returns an error if the file already has
+// a preview. The full preview is held in memory while it is generated, so maxSize should be kept
+// small (see DefaultPreviewSize).
+func (p *Pixi) AppendPreview(rw io.ReadWriteSeeker, layerIndex int, maxSize int) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append preview"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat("layer index out of range")
+	}
+	if _, ok := p.Preview(); ok {
+		return ErrUnsupported("file already has an embedded preview layer")
+	}
+
+	source := p.Layers[layerIndex]
+	preview := NewPreviewLayer(source, maxSize)
+	strides := make([]int, len(source.Dimensions))
+	for i, dim := range source.Dimensions {
+		strides[i] = previewStride(dim, max(maxSize, 1))
+	}
+
+	// the samples are gathered up front because the writer appends to the same stream being read, and only
+	// the source tiles holding a sample of the preview are read
+	samples, err := source.ReadRegion(rw, p.Header, FullRegion(source.Dimensions), WithStride(strides...))
+	if err != nil {
+		return err
+	}
+
+	writer := NewTileOrderWriteIterator(rw, p.Header, preview)
+	return p.AppendIterativeLayer(rw, preview, writer, func(writer IterativeLayerWriter) error {
+		for i := 0; writer.Next(); i++ {
+			writer.SetSample(samples[i])
+		}
+		return nil
+	})
+}
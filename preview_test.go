@@ -0,0 +1,111 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestNewPreviewLayer(t *testing.T) {
+	source := NewLayer("source",
+		DimensionSet{
+			{Name: "x", Size: 10, TileSize: 5, Axis: &Axis{Type: ChannelFloat64, Minimum: 1.0, Step: 0.5}},
+			{Name: "y", Size: 3, TileSize: 3},
+		},
+		ChannelSet{{Name: "v", Type: ChannelUint16, Min: uint16(1), Max: uint16(9)}})
+	preview := NewPreviewLayer(source, 4)
+
+	if preview.Name != PreviewLayerName {
+		t.Errorf("expected preview layer name %s, got %s", PreviewLayerName, preview.Name)
+	}
+	if preview.Dimensions[0].Size != 4 || preview.Dimensions[1].Size != 3 {
+		t.Errorf("expected preview dimensions 4x3, got %dx%d", preview.Dimensions[0].Size, preview.Dimensions[1].Size)
+	}
+	if preview.Dimensions.Tiles() != 1 {
+		t.Errorf("expected a single preview tile, got %d", preview.Dimensions.Tiles())
+	}
+	if preview.Dimensions[0].Axis.Minimum != 1.0 || preview.Dimensions[0].Axis.Step != 1.5 {
+		t.Errorf("expected strided axis with minimum 1 and step 1.5, got %+v", preview.Dimensions[0].Axis)
+	}
+	if source.Dimensions[0].Axis.Step != 0.5 {
+		t.Error("expected source axis to be left unchanged")
+	}
+	if preview.Channels[0].Min != nil || preview.Channels[0].Max != nil {
+		t.Error("expected preview channel statistics to be reset")
+	}
+}
+
+func TestCreateWithPreview(t *testing.T) {
+	for _, header := range allHeaderVariants(Version) {
+		buf := buffer.NewBuffer(10)
+		pixi, err := Create(buf, header, WithPreview(4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range 2 {
+			layer := NewLayer("data",
+				DimensionSet{{Name: "x", Size: 10, TileSize: 4}, {Name: "y", Size: 6, TileSize: 3}},
+				ChannelSet{{Name: "a", Type: ChannelInt32}, {Name: "b", Type: ChannelUint8}},
+				WithPlanar())
+			writer := NewTileOrderWriteIterator(buf, pixi.Header, layer)
+			err = pixi.AppendIterativeLayer(buf, layer, writer, func(writer IterativeLayerWriter) error {
+				for writer.Next() {
+					coord := writer.Coordinate()
+					if layer.Dimensions.ContainsCoordinate(coord) {
+						writer.SetSample(Sample{int32(coord[0] + coord[1]*10), uint8(coord[1])})
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err = buf.Seek(0, io.SeekStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadPixi(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read.Layers) != 3 {
+			t.Fatalf("expected two data layers and one preview, got %d layers", len(read.Layers))
+		}
+		preview, ok := read.Preview()
+		if !ok {
+			t.Fatal("expected file to have a preview layer")
+		}
+		if preview.Dimensions[0].Size != 4 || preview.Dimensions[1].Size != 3 {
+			t.Fatalf("expected preview dimensions 4x3, got %dx%d", preview.Dimensions[0].Size, preview.Dimensions[1].Size)
+		}
+
+		previewData := NewMemoryLayer(buf, read.Header, preview)
+		sample, err := SampleAt(previewData, SampleCoordinate{1, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sample[0] != int32(43) || sample[1] != uint8(4) {
+			t.Errorf("expected preview sample to match source sample (3, 4), got %v", sample)
+		}
+	}
+}
+
+func TestAppendPreviewTwice(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}})}
+	pixi := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+	err := pixi.AppendPreview(buf, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pixi.AppendPreview(buf, 0, 2)
+	if err == nil {
+		t.Error("expected error when appending a second preview")
+	}
+}
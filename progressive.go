@@ -0,0 +1,265 @@
+package gopixi
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Tiles compressed with CompressionProgressive start with a byte recording the byte order of their values
+// (so that they can be decoded without the file header, as the tiles sent by a TileServer are) and a byte
+// holding the number of byte planes, followed by the compressed size of each plane as a four-byte integer in
+// that byte order, and then the planes themselves.
+const (
+	progressiveLittleEndian byte = 0
+	progressiveBigEndian    byte = 1
+)
+
+// The byte sizes of the values of a tile of the layer, as a pattern repeated over the decoded tile: the size
+// of every channel of a contiguous layer, or the size of the one channel of a separated layer (one byte for
+// bit packed booleans, each byte of which is treated as a value).
+func (l Layer) planePattern(tile int) []int {
+	if len(l.Channels) == 0 {
+		return []int{1}
+	}
+	if l.Separated {
+		channel := l.Channels[tile/l.Dimensions.Tiles()]
+		if channel.Type == ChannelBool {
+			return []int{1}
+		}
+		return []int{channel.Size()}
+	}
+	pattern := make([]int, len(l.Channels))
+	for i, channel := range l.Channels {
+		pattern[i] = channel.Size()
+	}
+	return pattern
+}
+
+// The number of byte planes a tile of the layer is split into by CompressionProgressive: the size of its
+// largest value.
+func (l Layer) TilePlanes(tile int) int {
+	planes := 0
+	for _, size := range l.planePattern(tile) {
+		planes = max(planes, size)
+	}
+	return planes
+}
+
+// Calls the function with the offset in the decoded tile of each byte of the given plane, in the order the
+// plane holds them, along with the offset of the value it belongs to.
+func forEachPlaneByte(pattern []int, littleEndian bool, values int, plane int, f func(offset, value int)) {
+	stride := 0
+	for _, size := range pattern {
+		stride += size
+	}
+	for base := 0; base+stride <= values*stride; base += stride {
+		value := base
+		for _, size := range pattern {
+			if size > plane {
+				significance := plane
+				if littleEndian {
+					significance = size - 1 - plane
+				}
+				f(value+significance, value)
+			}
+			value += size
+		}
+	}
+}
+
+// Writes the chunk as byte planes, from the most significant byte of every value to the least, each
+// compressed with FLATE.
+func writeProgressive(enc *tileEncoder, w io.Writer, h Header, layer Layer, tileIndex int, chunk []byte) (int, error) {
+	pattern := layer.planePattern(tileIndex)
+	stride := 0
+	for _, size := range pattern {
+		stride += size
+	}
+	values := len(chunk) / stride
+	littleEndian := h.ByteOrder != binary.BigEndian
+	planes := layer.TilePlanes(tileIndex)
+
+	order, flag := binary.AppendByteOrder(binary.LittleEndian), progressiveLittleEndian
+	if !littleEndian {
+		order, flag = binary.BigEndian, progressiveBigEndian
+	}
+	compressed := make([]bytes.Buffer, planes)
+	plane := make([]byte, 0, values*len(pattern))
+	for p := range planes {
+		plane = plane[:0]
+		forEachPlaneByte(pattern, littleEndian, values, p, func(offset, _ int) {
+			plane = append(plane, chunk[offset])
+		})
+		if enc.flate == nil {
+			flateWriter, err := flate.NewWriter(&compressed[p], flate.BestCompression)
+			if err != nil {
+				return 0, err
+			}
+			enc.flate = flateWriter
+		} else {
+			enc.flate.Reset(&compressed[p])
+		}
+		if _, err := enc.flate.Write(plane); err != nil {
+			enc.flate.Close()
+			return 0, err
+		}
+		if err := enc.flate.Close(); err != nil {
+			return 0, err
+		}
+	}
+
+	buf := &enc.buf
+	buf.WriteByte(flag)
+	buf.WriteByte(byte(planes))
+	for p := range planes {
+		buf.Write(order.AppendUint32(buf.AvailableBuffer(), uint32(compressed[p].Len())))
+	}
+	for p := range planes {
+		buf.Write(compressed[p].Bytes())
+	}
+	return w.Write(buf.Bytes())
+}
+
+// Decodes as much of the tile as the stored bytes hold into the chunk, which must be the size of the decoded
+// tile. Every byte of a value is decoded once all of its planes are present; a value missing only its less
+// significant planes has the first missing byte set to 0x80 and the rest to zero, the middle of the range
+// of values it may have, and a value missing every plane is zero. Returns whether every plane was present.
+func decodeProgressive(stored []byte, layer Layer, tileIndex int, chunk []byte) (bool, error) {
+	planes := layer.TilePlanes(tileIndex)
+	tableSize := 2 + 4*planes
+	if len(stored) < tableSize {
+		return false, ErrFormat(fmt.Sprintf("progressive tile of %d bytes does not hold its %d byte plane table", len(stored), tableSize))
+	}
+	var order binary.ByteOrder
+	switch stored[0] {
+	case progressiveLittleEndian:
+		order = binary.LittleEndian
+	case progressiveBigEndian:
+		order = binary.BigEndian
+	default:
+		return false, ErrFormat(fmt.Sprintf("unknown progressive tile byte order %d", stored[0]))
+	}
+	if int(stored[1]) != planes {
+		return false, ErrFormat(fmt.Sprintf("progressive tile has %d byte planes, expected %d", stored[1], planes))
+	}
+
+	pattern := layer.planePattern(tileIndex)
+	stride := 0
+	for _, size := range pattern {
+		stride += size
+	}
+	values := len(chunk) / stride
+	littleEndian := order == binary.LittleEndian
+	// the number of planes decoded for each value, by offset of the value in the chunk
+	known := make([]uint8, len(chunk))
+	complete := true
+	offset := tableSize
+	for p := range planes {
+		size := int(order.Uint32(stored[2+4*p:]))
+		available := stored[min(offset, len(stored)):min(offset+size, len(stored))]
+		complete = complete && len(available) == size
+		offset += size
+
+		expected := 0
+		forEachPlaneByte(pattern, littleEndian, values, p, func(_, _ int) { expected++ })
+		plane := make([]byte, expected)
+		reader := flate.NewReader(bytes.NewReader(available))
+		n, err := io.ReadFull(reader, plane)
+		reader.Close()
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return false, err
+		}
+		complete = complete && n == expected
+
+		i := 0
+		forEachPlaneByte(pattern, littleEndian, values, p, func(at, value int) {
+			if i < n && int(known[value]) == p {
+				chunk[at] = plane[i]
+				known[value]++
+			}
+			i++
+		})
+	}
+	if complete {
+		return true, nil
+	}
+
+	base := 0
+	for range values {
+		for _, size := range pattern {
+			for b := int(known[base]); b < size; b++ {
+				significance := b
+				if littleEndian {
+					significance = size - 1 - b
+				}
+				chunk[base+significance] = 0
+				if b == int(known[base]) && b > 0 {
+					chunk[base+significance] = 0x80
+				}
+			}
+			base += size
+		}
+	}
+	return false, nil
+}
+
+// Decodes an approximation of a tile of a layer compressed with CompressionProgressive from a prefix of its
+// stored bytes, which must at least hold the plane table of the tile. Returns the decoded tile data and
+// whether it is exact.
+func (l Layer) DecodeTilePrefix(tile int, prefix []byte) ([]byte, bool, error) {
+	if l.Compression != CompressionProgressive {
+		return nil, false, ErrUnsupported(fmt.Sprintf("decoding tile prefixes of %s compressed layers", l.Compression))
+	}
+	if tile < 0 || tile >= l.DiskTiles() {
+		return nil, false, ErrTileNotFound{TileIndex: tile}
+	}
+	data := make([]byte, l.DiskTileSize(tile))
+	exact, err := decodeProgressive(prefix, l, tile, data)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, exact, nil
+}
+
+// Reads the first planes of a tile of a layer compressed with CompressionProgressive, fetching only the
+// stored bytes of those planes, and decodes an approximation of the tile as DecodeTilePrefix does. Reading
+// TilePlanes planes decodes the tile exactly, without verifying its checksum.
+func (l Layer) ReadTilePlanes(r io.ReadSeeker, tile int, planes int) ([]byte, error) {
+	if l.Compression != CompressionProgressive {
+		return nil, ErrUnsupported(fmt.Sprintf("reading tile planes of %s compressed layers", l.Compression))
+	}
+	if tile < 0 || tile >= len(l.TileBytes) || l.TileBytes[tile] == 0 {
+		return nil, ErrTileNotFound{TileIndex: tile}
+	}
+	tableSize := 2 + 4*l.TilePlanes(tile)
+	prefix := make([]byte, min(int64(tableSize), l.TileBytes[tile]))
+	if _, err := r.Seek(l.TileOffsets[tile], io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, err
+	}
+	length := int64(tableSize)
+	if len(prefix) == tableSize {
+		order := binary.ByteOrder(binary.LittleEndian)
+		if prefix[0] == progressiveBigEndian {
+			order = binary.BigEndian
+		}
+		for p := range min(planes, l.TilePlanes(tile)) {
+			length += int64(order.Uint32(prefix[2+4*p:]))
+		}
+	}
+	length = min(length, l.TileBytes[tile])
+	if length > int64(len(prefix)) {
+		prefix = append(prefix, make([]byte, length-int64(len(prefix)))...)
+		if _, err := io.ReadFull(r, prefix[tableSize:]); err != nil {
+			return nil, err
+		}
+	}
+	data, _, err := l.DecodeTilePrefix(tile, prefix)
+	return data, err
+}
@@ -0,0 +1,129 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func writeProgressiveLayer(t *testing.T, order binary.ByteOrder, opts ...LayerOption) *MemoryFile {
+	t.Helper()
+	file, err := NewMemoryFile(NewHeader(order, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("elevation", DimensionSet{{Name: "x", Size: 16, TileSize: 8}, {Name: "y", Size: 8, TileSize: 8}},
+		ChannelSet{{Name: "height", Type: ChannelFloat32}, {Name: "class", Type: ChannelUint8}, {Name: "depth", Type: ChannelInt16}},
+		append([]LayerOption{WithCompression(CompressionProgressive)}, opts...)...)
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			x, y := float64(writer.Coordinate()[0]), float64(writer.Coordinate()[1])
+			writer.SetSample(Sample{float32(1000 + 100*math.Sin(x/3) + y), uint8(x + y), int16(-50 * y)})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestProgressiveCompressionRoundTrip(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, opts := range [][]LayerOption{nil, {WithPlanar()}} {
+			file := writeProgressiveLayer(t, order, opts...)
+			layer := file.Layers[0]
+			for tile := range layer.DiskTiles() {
+				data := make([]byte, layer.DiskTileSize(tile))
+				if err := layer.ReadTile(file.Stream(), file.Header, tile, data); err != nil {
+					t.Fatal(err)
+				}
+				exact, err := layer.ReadTilePlanes(file.Stream(), tile, layer.TilePlanes(tile))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(data, exact) {
+					t.Errorf("%v separated %t: tile %d read by planes differs from the full tile", order, layer.Separated, tile)
+				}
+			}
+		}
+	}
+}
+
+func TestProgressiveTilePrefix(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		file := writeProgressiveLayer(t, order)
+		layer := file.Layers[0]
+		full, err := file.Layer(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exact, err := full.Tile(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored := file.Bytes()[layer.TileOffsets[0] : layer.TileOffsets[0]+layer.TileBytes[0]]
+
+		// two planes of the four byte heights hold their sign, exponent, and leading mantissa bits
+		twoPlanes, err := layer.ReadTilePlanes(file.Stream(), 0, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		approximate := tileMapLayer{layer: layer, header: file.Header, tiles: map[int][]byte{0: twoPlanes}}
+		for coord := range (Region{Start: SampleCoordinate{0, 0}, End: SampleCoordinate{8, 8}}).Coordinates() {
+			want, err := SampleAt(full, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := SampleAt(approximate, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if height := float64(got[0].(float32)); math.Abs(height-float64(want[0].(float32))) > float64(want[0].(float32))/256 {
+				t.Errorf("%v: sample %v height approximated as %v, expected about %v", order, coord, height, want[0])
+			}
+			if got[1] != want[1] || got[2] != want[2] {
+				t.Errorf("%v: sample %v read as %v, expected %v", order, coord, got, want)
+			}
+		}
+
+		// every prefix decodes, exactly only once it is whole
+		for n := 2 + 4*layer.TilePlanes(0); n <= len(stored); n += 7 {
+			_, complete, err := layer.DecodeTilePrefix(0, stored[:n])
+			if err != nil {
+				t.Fatalf("prefix of %d bytes: %v", n, err)
+			}
+			if complete != (n == len(stored)) {
+				t.Errorf("prefix of %d of %d bytes reported complete %t", n, len(stored), complete)
+			}
+		}
+		if data, complete, err := layer.DecodeTilePrefix(0, stored); err != nil || !complete || !bytes.Equal(data, exact) {
+			t.Errorf("expected the whole tile to decode exactly, got complete %t (%v)", complete, err)
+		}
+		if _, _, err := layer.DecodeTilePrefix(0, stored[:3]); err == nil {
+			t.Error("expected error for a prefix without the plane table")
+		}
+	}
+}
+
+func TestProgressiveTruncatedTileFails(t *testing.T) {
+	file := writeProgressiveLayer(t, binary.LittleEndian)
+	layer := file.Layers[0]
+	truncated := layer
+	truncated.TileBytes = slices.Clone(layer.TileBytes)
+	truncated.TileBytes[0] /= 2
+	data := make([]byte, layer.DiskTileSize(0))
+	if err := truncated.ReadTile(file.Stream(), file.Header, 0, data); err == nil {
+		t.Error("expected error reading a truncated progressive tile")
+	}
+	flate := layer
+	flate.Compression = CompressionFlate
+	var unsupported ErrUnsupported
+	if _, _, err := flate.DecodeTilePrefix(0, nil); !errors.As(err, &unsupported) {
+		t.Errorf("expected ErrUnsupported for a flate layer, got %v", err)
+	}
+}
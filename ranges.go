@@ -0,0 +1,178 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// A contiguous span of bytes within a stream.
+type ByteRange struct {
+	Offset int64
+	Length int64
+}
+
+// The offset just past the last byte of the range.
+func (b ByteRange) End() int64 {
+	return b.Offset + b.Length
+}
+
+// Implemented by streams that can fetch several byte ranges at once more efficiently than by seeking to and
+// reading each in turn, such as HttpReadSeeker. The returned slices are in the same order as the ranges.
+type RangeReader interface {
+	ReadRanges(ranges []ByteRange) ([][]byte, error)
+}
+
+// The byte range of the given tile in the file, including its trailing checksum.
+func (l Layer) TileRange(h Header, tileIndex int) ByteRange {
+	return ByteRange{Offset: l.TileOffsets[tileIndex], Length: l.TileBytes[tileIndex] + int64(h.Checksum.Size())}
+}
+
+type concurrencyOption struct {
+	workers int
+}
+
+func (o concurrencyOption) applyRead(opts *readOptions) {
+	opts.concurrency = o.workers
+}
+
+// Sets the number of workers that decompress and decode tiles concurrently when reading several tiles at
+// once, as ReadTiles and ReadRegion do, or one per available CPU if workers is zero or less. Tiles are
+// decoded one at a time by default. The stream itself is still read by a single goroutine.
+func WithConcurrency(workers int) ReadOption {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return concurrencyOption{workers: workers}
+}
+
+// Reads and decodes several tiles at once, returning their data keyed by tile index, fetching them in a
+// single batch if the stream is a RangeReader and decoding them concurrently if the options set a
+// concurrency. Tiles that were never written result in an ErrTileNotFound error, as with ReadTile.
+func (l Layer) ReadTiles(r io.ReadSeeker, h Header, tiles []int, opts ...ReadOption) (map[int][]byte, error) {
+	return l.readTiles(r, h, tiles, newReadOptions(opts))
+}
+
+func (l Layer) readTiles(r io.ReadSeeker, h Header, tiles []int, options readOptions) (map[int][]byte, error) {
+	for _, tile := range tiles {
+		if tile < 0 || tile >= len(l.TileBytes) || l.TileBytes[tile] == 0 {
+			return nil, ErrTileNotFound{TileIndex: tile}
+		}
+	}
+	verify := !options.skipChecksums
+	if options.concurrency > 1 && len(tiles) > 1 {
+		return l.readTilesConcurrently(r, h, tiles, verify, options.concurrency)
+	}
+
+	source := r
+	if ranger, ok := r.(RangeReader); ok {
+		ranges := make([]ByteRange, len(tiles))
+		for i, tile := range tiles {
+			ranges[i] = l.TileRange(h, tile)
+		}
+		data, err := ranger.ReadRanges(ranges)
+		if err != nil {
+			return nil, err
+		}
+		source = newPrefetchedReader(ranges, data)
+	}
+
+	result := make(map[int][]byte, len(tiles))
+	for _, tile := range tiles {
+		data := make([]byte, l.DiskTileSize(tile))
+		if err := l.readTile(source, h, tile, data, verify); err != nil {
+			return nil, err
+		}
+		result[tile] = data
+	}
+	return result, nil
+}
+
+// Fetches the stored bytes of every tile, then decodes them with the given number of workers.
+func (l Layer) readTilesConcurrently(r io.ReadSeeker, h Header, tiles []int, verify bool, workers int) (map[int][]byte, error) {
+	ranges := make([]ByteRange, len(tiles))
+	for i, tile := range tiles {
+		ranges[i] = l.TileRange(h, tile)
+	}
+	var stored [][]byte
+	if ranger, ok := r.(RangeReader); ok {
+		var err error
+		if stored, err = ranger.ReadRanges(ranges); err != nil {
+			return nil, err
+		}
+	} else {
+		stored = make([][]byte, len(ranges))
+		for i, tileRange := range ranges {
+			stored[i] = make([]byte, tileRange.Length)
+			if _, err := r.Seek(tileRange.Offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+			if _, err := io.ReadFull(r, stored[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	decoded := make([][]byte, len(tiles))
+	errs := make([]error, len(tiles))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(tiles)) {
+		wg.Go(func() {
+			for i := range next {
+				decoded[i] = make([]byte, l.DiskTileSize(tiles[i]))
+				prefetched := newPrefetchedReader(ranges[i:i+1], stored[i:i+1])
+				errs[i] = l.readTile(prefetched, h, tiles[i], decoded[i], verify)
+			}
+		})
+	}
+	for i := range tiles {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	result := make(map[int][]byte, len(tiles))
+	for i, tile := range tiles {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		result[tile] = decoded[i]
+	}
+	return result, nil
+}
+
+// Serves reads at absolute stream offsets from previously fetched byte ranges.
+type prefetchedReader struct {
+	ranges []ByteRange
+	data   [][]byte
+	offset int64
+}
+
+func newPrefetchedReader(ranges []ByteRange, data [][]byte) *prefetchedReader {
+	return &prefetchedReader{ranges: ranges, data: data}
+}
+
+func (p *prefetchedReader) Read(buf []byte) (int, error) {
+	index := slices.IndexFunc(p.ranges, func(b ByteRange) bool { return b.Offset <= p.offset && p.offset < b.End() })
+	if index < 0 {
+		return 0, io.EOF
+	}
+	n := copy(buf, p.data[index][p.offset-p.ranges[index].Offset:])
+	p.offset += int64(n)
+	return n, nil
+}
+
+func (p *prefetchedReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		p.offset = offset
+	case io.SeekCurrent:
+		p.offset += offset
+	default:
+		return 0, ErrUnsupported(fmt.Sprintf("seek with whence %d on prefetched data", whence))
+	}
+	return p.offset, nil
+}
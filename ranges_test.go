@@ -0,0 +1,172 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+// Serves the given file contents with support for single and multiple byte ranges, recording the number
+// of ranged requests and the most that were in flight at once. If ignoreMultiRange is set, requests for
+// multiple ranges are answered with the whole file, as by servers that do not support them.
+type rangeServer struct {
+	data             []byte
+	ignoreMultiRange bool
+	lock             sync.Mutex
+	requests         int
+	inFlight         int
+	peak             int
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		s.lock.Lock()
+		s.requests++
+		s.inFlight++
+		s.peak = max(s.peak, s.inFlight)
+		s.lock.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		defer func() {
+			s.lock.Lock()
+			s.inFlight--
+			s.lock.Unlock()
+		}()
+	}
+	if s.ignoreMultiRange && strings.Contains(r.Header.Get("Range"), ",") {
+		r.Header.Del("Range")
+	}
+	http.ServeContent(w, r, "file.pixi", time.Time{}, bytes.NewReader(s.data))
+}
+
+func TestLayerReadTilesHttp(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("layer", DimensionSet{{Name: "x", Size: 64, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint16}}, WithCompression(CompressionFlate))}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint16(coord[0] * 3)}
+	})
+	layer := written.Layers[0]
+	tiles := []int{1, 3, 4, 9, 15}
+
+	cases := []struct {
+		name             string
+		opts             []HttpOption
+		ignoreMultiRange bool
+		requests         int
+		maxInFlight      int
+		minInFlight      int
+	}{
+		{"concurrent", []HttpOption{WithMaxConcurrentRequests(2)}, false, len(tiles), 2, 2},
+		{"multi-range", []HttpOption{WithMultiRangeRequests()}, false, 1, 1, 1},
+		{"multi-range ignored", []HttpOption{WithMultiRangeRequests(), WithMaxConcurrentRequests(1)}, true, 1 + len(tiles), 1, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := &rangeServer{data: buf.Bytes(), ignoreMultiRange: c.ignoreMultiRange}
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+			fileUrl, _ := url.Parse(httpServer.URL)
+			reader, err := OpenHttp(fileUrl, httpServer.Client(), c.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := layer.ReadTiles(reader, written.Header, tiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tile := range tiles {
+				for inTile := range 4 {
+					x := tile*4 + inTile
+					got := written.Header.ByteOrder.Uint16(result[tile][inTile*2:])
+					if got != uint16(x*3) {
+						t.Errorf("tile %d sample %d: expected %d, got %d", tile, x, x*3, got)
+					}
+				}
+			}
+			if server.requests != c.requests {
+				t.Errorf("expected %d requests, got %d", c.requests, server.requests)
+			}
+			if server.peak > c.maxInFlight || server.peak < c.minInFlight {
+				t.Errorf("expected between %d and %d requests in flight, got %d", c.minInFlight, c.maxInFlight, server.peak)
+			}
+		})
+	}
+}
+
+func TestLayerReadTilesLocal(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("layer", DimensionSet{{Name: "x", Size: 8, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}})}
+	written := writeTestPixi(t, buf, NewHeader(binary.BigEndian, OffsetSize8), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0] + 1)}
+	})
+	result, err := written.Layers[0].ReadTiles(buffer.NewBufferFrom(buf.Bytes()), written.Header, []int{0, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result[0], []byte{1, 2}) || !bytes.Equal(result[3], []byte{7, 8}) {
+		t.Errorf("unexpected tile data %v", result)
+	}
+	if _, err := written.Layers[0].ReadTiles(buffer.NewBufferFrom(buf.Bytes()), written.Header, []int{4}); err == nil {
+		t.Error("expected reading a tile out of range to fail")
+	}
+}
+
+func TestLayerReadTilesConcurrently(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("layer", DimensionSet{{Name: "x", Size: 40, TileSize: 4}, {Name: "y", Size: 6, TileSize: 3}},
+		ChannelSet{{Name: "a", Type: ChannelInt32}, {Name: "b", Type: ChannelFloat32}}, WithCompression(CompressionZstd), WithPlanar())}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{int32(coord[0] * coord[1]), float32(coord[0]) / 4}
+	})
+	layer := written.Layers[0]
+	tiles := make([]int, layer.DiskTiles())
+	for i := range tiles {
+		tiles[i] = i
+	}
+
+	sequential, err := layer.ReadTiles(buffer.NewBufferFrom(buf.Bytes()), written.Header, tiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 3} {
+		concurrent, err := layer.ReadTiles(buffer.NewBufferFrom(buf.Bytes()), written.Header, tiles, WithConcurrency(workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tile := range tiles {
+			if !bytes.Equal(concurrent[tile], sequential[tile]) {
+				t.Errorf("workers %d: tile %d differs from the sequential read", workers, tile)
+			}
+		}
+	}
+
+	region := Region{Start: SampleCoordinate{3, 1}, End: SampleCoordinate{37, 6}}
+	want, err := layer.ReadRegion(buffer.NewBufferFrom(buf.Bytes()), written.Header, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := layer.ReadRegion(buffer.NewBufferFrom(buf.Bytes()), written.Header, region, WithConcurrency(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if want[i][0] != got[i][0] || want[i][1] != got[i][1] {
+			t.Errorf("sample %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+
+	// the error of a corrupted tile is returned once every worker finishes
+	corrupted := bytes.Clone(buf.Bytes())
+	corrupted[layer.TileOffsets[7]+layer.TileBytes[7]] ^= 0xff
+	if _, err := layer.ReadTiles(buffer.NewBufferFrom(corrupted), written.Header, tiles, WithConcurrency(4)); err == nil {
+		t.Error("expected error reading a corrupted tile concurrently")
+	}
+}
@@ -0,0 +1,254 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Describes a flat binary array stored without any header or tiling, such as the outputs of many numerical
+// models, to be ingested into a layer with AppendRawLayer.
+type RawArray struct {
+	// The sizes of the dimensions of the array, listed in the order in which they are stored: the first
+	// dimension changes fastest in the file. Arrays in C (row-major) order list their dimensions in reverse.
+	// The tile sizes are ignored.
+	Dimensions DimensionSet
+	Channels   ChannelSet       // The channels of each sample; boolean values are stored one per byte.
+	ByteOrder  binary.ByteOrder // The byte order of the multibyte values in the file.
+	// If true, every value of each channel is stored before the values of the next channel (band
+	// sequential). Otherwise the channel values of each sample are stored next to each other.
+	Separated bool
+	Offset    int64 // The byte offset of the first value of the array, skipping any leading header.
+}
+
+// The number of bytes occupied by the array in its file, excluding the leading offset.
+func (a RawArray) Size() int64 {
+	return int64(a.Dimensions.Samples()) * int64(a.Channels.Size())
+}
+
+// Picks tile sizes for which the tiles of a layer are contiguous runs of bytes in the array, so that
+// AppendRawLayer copies them verbatim: dimensions are kept whole, starting from the fastest changing, until
+// a tile holds about targetBytes, and the remaining dimensions have a tile size of one.
+func (a RawArray) AlignedDimensions(targetBytes int) DimensionSet {
+	dims := slices.Clone(a.Dimensions)
+	sampleBytes := a.Channels.Size()
+	if a.Separated {
+		sampleBytes = 0
+		for _, channel := range a.Channels {
+			sampleBytes = max(sampleBytes, channel.Size())
+		}
+	}
+	tileBytes := sampleBytes
+	split := false
+	for i := range dims {
+		switch {
+		case split:
+			dims[i].TileSize = 1
+		case tileBytes*dims[i].Size <= targetBytes:
+			dims[i].TileSize = dims[i].Size
+			tileBytes *= dims[i].Size
+		default:
+			dims[i].TileSize = max(1, targetBytes/tileBytes)
+			split = true
+		}
+	}
+	return dims
+}
+
+// Reports whether every tile of the layer is a contiguous run of bytes in the array that can be copied into
+// a file with the given header without decoding its samples: the layer is uncompressed, has no halos,
+// stores its channels the same way as the array, using the same byte order, and is tiled so that only one
+// dimension is split into tiles larger than a single sample, with every faster changing dimension whole.
+func (a RawArray) Aligned(layer Layer, h Header) bool {
+	if layer.Compression != CompressionNone || layer.Separated != a.Separated || layer.Dimensions.HasHalo() || h.ByteOrder != a.ByteOrder {
+		return false
+	}
+	if layer.Separated && slices.ContainsFunc(layer.Channels, func(c Channel) bool { return c.Type == ChannelBool }) {
+		return false // separated boolean channels are bit packed in tiles
+	}
+	split := false
+	for _, dim := range layer.Dimensions {
+		if split && dim.TileSize != 1 {
+			return false
+		}
+		if dim.TileSize != dim.Size {
+			split = true
+		}
+	}
+	return true
+}
+
+// Appends a layer to the end of the file holding the samples of the raw array, which must have the same
+// dimension sizes and channel types as the layer, streaming the array one tile at a time.
+func (p *Pixi) AppendRawLayer(w io.WriteSeeker, layer Layer, raw io.ReaderAt, array RawArray) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append layer"}
+	}
+	if layer.Dimensions.HasHalo() {
+		return ErrUnsupported("layers with halos must be appended with AppendHaloLayer")
+	}
+	if layer.Channels.HasStrings() {
+		return ErrUnsupported("raw arrays of string channels")
+	}
+	if len(array.Dimensions) != len(layer.Dimensions) || len(array.Channels) != len(layer.Channels) {
+		return ErrFormat("raw array must have the same dimensions and channels as the layer")
+	}
+	for i, dim := range array.Dimensions {
+		if dim.Size != layer.Dimensions[i].Size {
+			return ErrFormat(fmt.Sprintf("raw array dimension %d has size %d, layer has %d", i, dim.Size, layer.Dimensions[i].Size))
+		}
+	}
+	for i, channel := range array.Channels {
+		if channel.Type.Base() != layer.Channels[i].Type.Base() {
+			return ErrFormat(fmt.Sprintf("raw array channel %d has type %v, layer has %v", i, channel.Type, layer.Channels[i].Type))
+		}
+	}
+	if array.ByteOrder == nil {
+		array.ByteOrder = p.Header.ByteOrder
+	}
+	p.checkpointed = false
+
+	layer.Channels = layer.Channels.withoutDistributions()
+	layer.TileBytes = make([]int64, layer.DiskTiles())
+	layer.TileOffsets = make([]int64, layer.DiskTiles())
+	layer.NextLayerStart = 0
+	aligned := array.Aligned(layer, p.Header)
+	encoder := p.newTileEncoder(len(p.Layers))
+	for tile := range layer.DiskTiles() {
+		data := make([]byte, layer.DiskTileSize(tile))
+		var err error
+		if aligned {
+			err = array.readAlignedTile(raw, layer, tile, data)
+		} else {
+			err = array.gatherTile(raw, layer, p.Header, tile, data)
+		}
+		if err != nil {
+			return err
+		}
+		// the array may be read from the same stream, so seek back to the end for every tile
+		if _, err := w.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+		if err := layer.writeTileWith(encoder, w, p.Header, tile, data); err != nil {
+			return err
+		}
+		layer.updateTileStatistics(p.Header, tile, data)
+	}
+	return p.appendLayerHeader(w, layer)
+}
+
+// The byte offset in the raw file of the value of the given channel at the given sample index.
+func (a RawArray) valueOffset(sampleIndex int, channelIndex int) int64 {
+	if a.Separated {
+		return a.Offset + int64(a.Dimensions.Samples())*int64(a.Channels.Offset(channelIndex)) +
+			int64(sampleIndex)*int64(a.Channels[channelIndex].Size())
+	}
+	return a.Offset + int64(sampleIndex)*int64(a.Channels.Size()) + int64(a.Channels.Offset(channelIndex))
+}
+
+// The index of the sample at the coordinate in the array, with the first dimension changing fastest.
+func (a RawArray) sampleIndex(coord SampleCoordinate) int {
+	index, stride := 0, 1
+	for i, dim := range a.Dimensions {
+		index += coord[i] * stride
+		stride *= dim.Size
+	}
+	return index
+}
+
+// Copies the bytes of an aligned tile directly from the array, leaving any samples past the edge of the
+// layer as zero padding.
+func (a RawArray) readAlignedTile(raw io.ReaderAt, layer Layer, tile int, data []byte) error {
+	dims := layer.Dimensions
+	channelIndex := 0
+	if layer.Separated {
+		channelIndex = tile / dims.Tiles()
+	}
+	origin := TileSelector{Tile: tile % dims.Tiles()}.ToTileCoordinate(dims).ToSampleCoordinate(dims)
+	start := a.sampleIndex(origin)
+	// only the one split dimension can run past the end of the layer
+	samples := dims.TileSamples()
+	stride := 1
+	for i, dim := range dims {
+		if dim.TileSize != dim.Size {
+			samples = min(samples, (dim.Size-origin[i])*stride)
+			break
+		}
+		stride *= dim.Size
+	}
+	valueBytes := layer.Channels.Size()
+	if layer.Separated {
+		valueBytes = layer.Channels[channelIndex].Size()
+	}
+	return readFullAt(raw, data[:samples*valueBytes], a.valueOffset(start, channelIndex))
+}
+
+// Gathers the samples of a tile from runs of the array along its first dimension, converting each value to
+// the byte order of the file.
+func (a RawArray) gatherTile(raw io.ReaderAt, layer Layer, h Header, tile int, data []byte) error {
+	dims := layer.Dimensions
+	tiles := dims.Tiles()
+	channels := []int{}
+	if layer.Separated {
+		channels = append(channels, tile/tiles)
+	} else {
+		for i := range layer.Channels {
+			channels = append(channels, i)
+		}
+	}
+
+	runLength := dims[0].TileSize
+	run := make([]byte, runLength*a.Channels.Size())
+	for inTile := 0; inTile < dims.TileSamples(); inTile += runLength {
+		coord := TileSelector{Tile: tile % tiles, InTile: inTile}.ToTileCoordinate(dims).ToSampleCoordinate(dims)
+		if !dims.ContainsCoordinate(coord) {
+			continue
+		}
+		count := min(runLength, dims[0].Size-coord[0])
+		start := a.sampleIndex(coord)
+		for _, c := range channels {
+			channel := a.Channels[c]
+			if a.Separated {
+				if err := readFullAt(raw, run[:count*channel.Size()], a.valueOffset(start, c)); err != nil {
+					return err
+				}
+			} else if c == channels[0] {
+				if err := readFullAt(raw, run[:count*a.Channels.Size()], a.valueOffset(start, 0)); err != nil {
+					return err
+				}
+			}
+			for i := range count {
+				var value any
+				if a.Separated {
+					value = readRawValue(channel, run[i*channel.Size():], a.ByteOrder)
+				} else {
+					value = readRawValue(channel, run[i*a.Channels.Size()+a.Channels.Offset(c):], a.ByteOrder)
+				}
+				layer.putStoredValue(h, data, c, inTile+i, value)
+			}
+		}
+	}
+	return nil
+}
+
+// Reads exactly len(buf) bytes at the offset, ignoring the io.EOF a reader may return along with the final
+// bytes of its input.
+func readFullAt(r io.ReaderAt, buf []byte, offset int64) error {
+	n, err := r.ReadAt(buf, offset)
+	if n == len(buf) {
+		return nil
+	}
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Decodes a value of the channel from a raw array, in which boolean values are stored one per byte.
+func readRawValue(channel Channel, raw []byte, order binary.ByteOrder) any {
+	if channel.Type == ChannelBool {
+		return raw[0] != 0
+	}
+	return channel.Value(raw, order)
+}
@@ -0,0 +1,133 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+// Builds a raw array file holding rawTestSample at every coordinate, as described by the array.
+func writeRawArray(array RawArray) []byte {
+	data := make([]byte, array.Offset+array.Size())
+	for coord := range array.Dimensions.SampleCoordinates() {
+		sample := rawTestSample(coord)
+		for c, channel := range array.Channels {
+			offset := array.valueOffset(array.sampleIndex(coord), c)
+			if channel.Type == ChannelBool {
+				if sample[c].(bool) {
+					data[offset] = 1
+				}
+			} else {
+				channel.PutValue(sample[c], array.ByteOrder, data[offset:])
+			}
+		}
+	}
+	return data
+}
+
+func rawTestSample(coord SampleCoordinate) Sample {
+	return Sample{int16(coord[0] - 10*coord[1] + 100*coord[2]), float32(coord[0]) / 4, coord[1]%2 == 0}
+}
+
+func TestAppendRawLayer(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 5}, {Name: "y", Size: 4}, {Name: "z", Size: 3}}
+	channels := ChannelSet{{Name: "a", Type: ChannelInt16}, {Name: "b", Type: ChannelFloat32}, {Name: "c", Type: ChannelBool}}
+
+	cases := []struct {
+		name      string
+		array     RawArray
+		layerDims DimensionSet
+		opts      []LayerOption
+		aligned   bool
+	}{
+		{"aligned contiguous", RawArray{ByteOrder: binary.LittleEndian, Offset: 7}, DimensionSet{{Name: "x", Size: 5, TileSize: 5}, {Name: "y", Size: 4, TileSize: 3}, {Name: "z", Size: 3, TileSize: 1}}, nil, true},
+		{"aligned whole", RawArray{ByteOrder: binary.LittleEndian}, DimensionSet{{Name: "x", Size: 5, TileSize: 5}, {Name: "y", Size: 4, TileSize: 4}, {Name: "z", Size: 3, TileSize: 2}}, nil, true},
+		{"byte order", RawArray{ByteOrder: binary.BigEndian}, DimensionSet{{Name: "x", Size: 5, TileSize: 5}, {Name: "y", Size: 4, TileSize: 3}, {Name: "z", Size: 3, TileSize: 1}}, nil, false},
+		{"retiled separated", RawArray{ByteOrder: binary.BigEndian, Separated: true}, DimensionSet{{Name: "x", Size: 5, TileSize: 2}, {Name: "y", Size: 4, TileSize: 3}, {Name: "z", Size: 3, TileSize: 2}}, []LayerOption{WithPlanar(), WithCompression(CompressionFlate)}, false},
+		{"separated to contiguous", RawArray{ByteOrder: binary.LittleEndian, Separated: true}, DimensionSet{{Name: "x", Size: 5, TileSize: 3}, {Name: "y", Size: 4, TileSize: 4}, {Name: "z", Size: 3, TileSize: 3}}, nil, false},
+	}
+	for _, tc := range cases {
+		array := tc.array
+		array.Dimensions, array.Channels = dims, channels
+		raw := writeRawArray(array)
+
+		buf := buffer.NewBuffer(10)
+		p, err := Create(buf, NewHeader(binary.LittleEndian, OffsetSize4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		layer := NewLayer("raw", tc.layerDims, channels, tc.opts...)
+		if aligned := array.Aligned(layer, p.Header); aligned != tc.aligned {
+			t.Errorf("%s: expected aligned %t, got %t", tc.name, tc.aligned, aligned)
+		}
+		if err := p.AppendRawLayer(buf, layer, bytes.NewReader(raw), array); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		r := buffer.NewBufferFrom(buf.Bytes())
+		read, err := ReadPixi(r)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		samples, err := read.Layers[0].ReadRegion(r, read.Header, FullRegion(dims))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		i := 0
+		for coord := range dims.SampleCoordinates() {
+			want := rawTestSample(coord)
+			for c := range want {
+				if samples[i][c] != want[c] {
+					t.Fatalf("%s: sample %v read as %v, expected %v", tc.name, coord, samples[i], want)
+				}
+			}
+			i++
+		}
+		if read.Layers[0].Channels[0].Min != int16(-30) || read.Layers[0].Channels[0].Max != int16(204) {
+			t.Errorf("%s: unexpected statistics %v", tc.name, read.Layers[0].Channels[0])
+		}
+	}
+}
+
+func TestAppendRawLayerErrors(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 4, TileSize: 4}, {Name: "y", Size: 2, TileSize: 1}}
+	channels := ChannelSet{{Name: "a", Type: ChannelUint16}}
+	array := RawArray{Dimensions: dims, Channels: channels, ByteOrder: binary.LittleEndian}
+
+	buf := buffer.NewBuffer(10)
+	p, err := Create(buf, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	short := make([]byte, array.Size()-1)
+	if err := p.AppendRawLayer(buf, NewLayer("raw", dims, channels), bytes.NewReader(short), array); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected unexpected EOF for a short array, got %v", err)
+	}
+	other := NewLayer("raw", DimensionSet{{Name: "x", Size: 5, TileSize: 5}, {Name: "y", Size: 2, TileSize: 1}}, channels)
+	if err := p.AppendRawLayer(buf, other, bytes.NewReader(make([]byte, 20)), array); err == nil {
+		t.Error("expected an error for mismatched dimensions")
+	}
+}
+
+func TestRawAlignedDimensions(t *testing.T) {
+	array := RawArray{
+		Dimensions: DimensionSet{{Name: "x", Size: 100}, {Name: "y", Size: 50}, {Name: "z", Size: 10}},
+		Channels:   ChannelSet{{Name: "a", Type: ChannelFloat32}},
+		ByteOrder:  binary.LittleEndian,
+	}
+	dims := array.AlignedDimensions(4 * 100 * 20)
+	if dims[0].TileSize != 100 || dims[1].TileSize != 20 || dims[2].TileSize != 1 {
+		t.Errorf("unexpected aligned dimensions %v", dims)
+	}
+	layer := NewLayer("raw", dims, array.Channels)
+	if !array.Aligned(layer, NewHeader(binary.LittleEndian, OffsetSize8)) {
+		t.Error("expected aligned dimensions to be aligned")
+	}
+	if array.Aligned(NewLayer("raw", dims, array.Channels, WithCompression(CompressionFlate)), NewHeader(binary.LittleEndian, OffsetSize8)) {
+		t.Error("expected compressed layers not to be aligned")
+	}
+}
@@ -0,0 +1,60 @@
+package gopixi
+
+import (
+	"io"
+	"os"
+)
+
+// A local file opened strictly for reading (O_RDONLY). It satisfies io.Writer only so that it can be
+// handed to the mutating APIs of this package, which will then fail with ErrReadOnly. No file locks are
+// taken on the file.
+type ReadOnlyFile struct {
+	file *os.File
+}
+
+var _ io.ReadSeekCloser = (*ReadOnlyFile)(nil)
+var _ io.ReaderAt = (*ReadOnlyFile)(nil)
+
+// Opens the Pixi file at the given path in read-only mode, returning the file handle along with the
+// metadata read from it. The returned Pixi is marked as read-only, so any attempt to append tags or
+// layers to it will return ErrReadOnly.
+func OpenReadOnly(path string) (*ReadOnlyFile, *Pixi, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	roFile := &ReadOnlyFile{file: file}
+	pixi, err := ReadPixi(roFile)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	pixi.ReadOnly = true
+	return roFile, pixi, nil
+}
+
+func (f *ReadOnlyFile) Read(p []byte) (int, error) {
+	return f.file.Read(p)
+}
+
+func (f *ReadOnlyFile) ReadAt(p []byte, off int64) (int, error) {
+	return f.file.ReadAt(p, off)
+}
+
+func (f *ReadOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	return f.file.Seek(offset, whence)
+}
+
+// Always fails with ErrReadOnly; read-only files can never be written.
+func (f *ReadOnlyFile) Write(p []byte) (int, error) {
+	return 0, ErrReadOnly{Operation: "write to file opened in read-only mode"}
+}
+
+func (f *ReadOnlyFile) Close() error {
+	return f.file.Close()
+}
+
+// The name of the underlying file, as passed to OpenReadOnly.
+func (f *ReadOnlyFile) Name() string {
+	return f.file.Name()
+}
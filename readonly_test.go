@@ -0,0 +1,76 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestPixiFile(t *testing.T, path string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	layer := NewLayer("test", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint16}})
+	writeTestPixi(t, file, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{layer}, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint16(coord[0])}
+	})
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "readonly.pixi")
+	writeTestPixiFile(t, path)
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, pixi, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if !pixi.ReadOnly {
+		t.Error("expected pixi opened in read-only mode to be marked read-only")
+	}
+
+	// reads work as usual
+	cached := NewFifoCacheReadLayer(file, pixi.Header, pixi.Layers[0], 2)
+	sample, err := SampleAt(cached, SampleCoordinate{5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample[0] != uint16(5) {
+		t.Errorf("expected sample value 5, got %v", sample[0])
+	}
+
+	// every mutating operation fails with a typed error
+	var roErr ErrReadOnly
+	if err := pixi.AppendTags(file, map[string]string{"a": "b"}); !errors.As(err, &roErr) {
+		t.Errorf("expected ErrReadOnly appending tags, got %v", err)
+	}
+	if err := pixi.AppendIterativeLayer(file, pixi.Layers[0], nil, nil); !errors.As(err, &roErr) {
+		t.Errorf("expected ErrReadOnly appending layer, got %v", err)
+	}
+	if err := pixi.Layers[0].OverwriteHeader(file, pixi.Header, pixi.Header.FirstLayerOffset); !errors.As(err, &roErr) {
+		t.Errorf("expected ErrReadOnly overwriting layer header, got %v", err)
+	}
+	if _, err := file.Write([]byte{0}); !errors.As(err, &roErr) {
+		t.Errorf("expected ErrReadOnly writing directly, got %v", err)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("file contents changed after operations in read-only mode")
+	}
+}
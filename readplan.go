@@ -0,0 +1,210 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// An explicit description of the I/O needed to read a region of a layer, built before any data is read.
+// Applications can inspect a plan to warn about pathological selections (such as a thin slice that cuts
+// across many large tiles), and tests can use it to assert that reads are efficient.
+type ReadPlan struct {
+	Region Region
+	// The stride of the read along each dimension given by WithStride, or nil if every sample of the region
+	// is read.
+	Stride []int
+	// The indices of the layer tiles covering the region, in increasing order. For separated layers, this
+	// includes the tile of every channel.
+	Tiles []int
+	// The subset of Tiles that were never written to the file, and so cannot be read.
+	Missing []int
+	// The byte ranges of the file that must be fetched, in file order, with adjacent tiles coalesced.
+	Ranges []ByteRange
+	// The number of samples within the region, or selected from it by the stride.
+	Samples int
+	// The number of samples that must be decoded to read the region, which is every sample of each tile
+	// overlapping it (counting the channel tiles of a separated layer together, as one tile).
+	DecodedSamples int
+}
+
+// The total number of bytes fetched from the file.
+func (p ReadPlan) Bytes() int64 {
+	total := int64(0)
+	for _, r := range p.Ranges {
+		total += r.Length
+	}
+	return total
+}
+
+// The number of ranged requests or seeks needed to fetch the data, one per coalesced range.
+func (p ReadPlan) Requests() int {
+	return len(p.Ranges)
+}
+
+// The fraction of decoded samples that are read, from nearly zero for a selection that only
+// touches a sliver of each tile up to one for a region aligned to tile boundaries.
+func (p ReadPlan) Efficiency() float64 {
+	if p.DecodedSamples == 0 {
+		return 0
+	}
+	return float64(p.Samples) / float64(p.DecodedSamples)
+}
+
+func (p ReadPlan) String() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "read %v", p.Region)
+	if p.Stride != nil {
+		fmt.Fprintf(&b, " by %v", p.Stride)
+	}
+	fmt.Fprintf(&b, ": %d samples from %d tiles", p.Samples, len(p.Tiles))
+	if len(p.Missing) > 0 {
+		fmt.Fprintf(&b, " (%d missing)", len(p.Missing))
+	}
+	fmt.Fprintf(&b, ", %d bytes in %d requests, %.1f%% efficiency", p.Bytes(), p.Requests(), p.Efficiency()*100)
+	return b.String()
+}
+
+// Builds the plan for reading the given region of the layer in a file with the given header with ReadRegion
+// and the same options, without reading anything. For separated layers, only the tiles of the channels selected by the options are read.
+func (l Layer) ExplainRead(h Header, region Region, opts ...ReadOption) (ReadPlan, error) {
+	if err := region.Validate(l.Dimensions); err != nil {
+		return ReadPlan{}, err
+	}
+	options := newReadOptions(opts)
+	if err := validateStride(options.stride, l.Dimensions); err != nil {
+		return ReadPlan{}, err
+	}
+	channels, err := channelIndices(l.Channels, options.channels)
+	if err != nil {
+		return ReadPlan{}, err
+	}
+	plan := ReadPlan{Region: region, Stride: slices.Clone(options.stride), Samples: 1}
+	for _, size := range region.StridedSize(options.stride) {
+		plan.Samples *= size
+	}
+
+	// the tiles holding samples of the region in each dimension, every tile it overlaps unless strided
+	tilesAlong := region.stridedTiles(l.Dimensions, options.stride)
+	tileRegion := Region{Start: make(SampleCoordinate, len(l.Dimensions)), End: make(SampleCoordinate, len(l.Dimensions))}
+	for d := range l.Dimensions {
+		tileRegion.End[d] = len(tilesAlong[d])
+	}
+	tileCoord := make([]int, len(l.Dimensions))
+	inTile := make([]int, len(l.Dimensions))
+	channelTiles := []int{0}
+	if l.Separated {
+		channelTiles = slices.Compact(slices.Sorted(slices.Values(channels)))
+	}
+	for along := range tileRegion.Coordinates() {
+		for d, i := range along {
+			tileCoord[d] = tilesAlong[d][i]
+		}
+		tile := TileCoordinate{Tile: tileCoord, InTile: inTile}.ToTileSelector(l.Dimensions).Tile
+		for _, channel := range channelTiles {
+			plan.Tiles = append(plan.Tiles, tile+l.Dimensions.Tiles()*channel)
+		}
+	}
+	slices.Sort(plan.Tiles)
+	plan.DecodedSamples = len(plan.Tiles) / len(channelTiles) * l.Dimensions.TileSamples()
+
+	for _, tile := range plan.Tiles {
+		if l.TileBytes[tile] == 0 {
+			plan.Missing = append(plan.Missing, tile)
+			continue
+		}
+		plan.Ranges = append(plan.Ranges, l.TileRange(h, tile))
+	}
+	slices.SortFunc(plan.Ranges, func(a, b ByteRange) int { return int(a.Offset - b.Offset) })
+	plan.Ranges = coalesceRanges(plan.Ranges)
+	return plan, nil
+}
+
+// Merges byte ranges (already sorted by offset) that touch or overlap into single ranges.
+func coalesceRanges(ranges []ByteRange) []ByteRange {
+	merged := []ByteRange{}
+	for _, r := range ranges {
+		if len(merged) > 0 && merged[len(merged)-1].End() >= r.Offset {
+			last := &merged[len(merged)-1]
+			last.Length = max(last.End(), r.End()) - last.Offset
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Reads every sample within the region of the layer, in the order given by Region.Coordinates, fetching the
+// tiles planned by ExplainRead in one batch. Tiles that were never written are read according to the absent
+// tile policy of the options. If the options select channels, the samples hold only those channels, and if
+// they set a stride only the selected samples are read, in the order given by Region.StridedCoordinates.
+func (l Layer) ReadRegion(r io.ReadSeeker, h Header, region Region, opts ...ReadOption) ([]Sample, error) {
+	access, plan, err := l.regionAccess(r, h, region, opts)
+	if err != nil {
+		return nil, err
+	}
+	samples := make([]Sample, 0, plan.Samples)
+	for coord := range region.StridedCoordinates(plan.Stride) {
+		sample, err := SampleAt(access, coord)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// Reads every tile the region overlaps, returning access to them with absent tiles filled and channels
+// selected according to the options, along with the plan of the read.
+func (l Layer) regionAccess(r io.ReadSeeker, h Header, region Region, opts []ReadOption) (TileAccessLayer, ReadPlan, error) {
+	plan, err := l.ExplainRead(h, region, opts...)
+	if err != nil {
+		return nil, plan, err
+	}
+	options := newReadOptions(opts).forLayer(l)
+	if len(plan.Missing) > 0 && options.absent == AbsentTileError {
+		return nil, plan, ErrTileNotFound{TileIndex: plan.Missing[0]}
+	}
+	present := slices.DeleteFunc(slices.Clone(plan.Tiles), func(tile int) bool { return slices.Contains(plan.Missing, tile) })
+	tiles, err := l.readTiles(r, h, present, options)
+	if err != nil {
+		return nil, plan, err
+	}
+
+	var access TileAccessLayer = tileMapLayer{layer: l, header: h, tiles: tiles}
+	if len(plan.Missing) > 0 {
+		if access, err = NewAbsentFillLayer(access, opts...); err != nil {
+			return nil, plan, err
+		}
+	}
+	if len(options.channels) > 0 {
+		if access, err = NewChannelSelectLayer(access, len(plan.Tiles), options.channels...); err != nil {
+			return nil, plan, err
+		}
+	}
+	return access, plan, nil
+}
+
+// Provides access to a fixed set of already decoded tiles.
+type tileMapLayer struct {
+	layer  Layer
+	header Header
+	tiles  map[int][]byte
+}
+
+func (t tileMapLayer) Layer() Layer {
+	return t.layer
+}
+
+func (t tileMapLayer) Header() Header {
+	return t.header
+}
+
+func (t tileMapLayer) Tile(tile int) ([]byte, error) {
+	data, ok := t.tiles[tile]
+	if !ok {
+		return nil, ErrTileNotFound{TileIndex: tile}
+	}
+	return data, nil
+}
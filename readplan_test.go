@@ -0,0 +1,95 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestExplainRead(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	dims := DimensionSet{{Name: "x", Size: 8, TileSize: 4}, {Name: "y", Size: 8, TileSize: 4}}
+	channels := ChannelSet{{Name: "a", Type: ChannelUint8}, {Name: "b", Type: ChannelUint16}}
+	layers := []Layer{
+		NewLayer("interleaved", dims, channels),
+		NewLayer("separated", dims, channels, WithPlanar()),
+	}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0]), uint16(coord[1] * 10)}
+	})
+
+	cases := []struct {
+		name       string
+		layer      int
+		region     Region
+		tiles      []int
+		requests   int
+		efficiency float64
+	}{
+		{"within one tile", 0, Region{Start: SampleCoordinate{1, 1}, End: SampleCoordinate{3, 3}}, []int{0}, 1, 4.0 / 16},
+		{"across tiles", 0, Region{Start: SampleCoordinate{3, 0}, End: SampleCoordinate{5, 8}}, []int{0, 1, 2, 3}, 1, 16.0 / 64},
+		{"tile aligned", 0, Region{Start: SampleCoordinate{4, 0}, End: SampleCoordinate{8, 8}}, []int{1, 3}, 2, 1},
+		{"separated", 1, Region{Start: SampleCoordinate{0, 4}, End: SampleCoordinate{8, 8}}, []int{2, 3, 6, 7}, 1, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			layer := written.Layers[c.layer]
+			plan, err := layer.ExplainRead(written.Header, c.region)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(plan.Tiles, c.tiles) {
+				t.Errorf("expected tiles %v, got %v", c.tiles, plan.Tiles)
+			}
+			if plan.Requests() != c.requests {
+				t.Errorf("expected %d requests, got %d (%v)", c.requests, plan.Requests(), plan.Ranges)
+			}
+			expectedBytes := int64(0)
+			for _, tile := range c.tiles {
+				expectedBytes += layer.TileRange(written.Header, tile).Length
+			}
+			if plan.Bytes() != expectedBytes {
+				t.Errorf("expected %d bytes, got %d", expectedBytes, plan.Bytes())
+			}
+			if plan.Efficiency() != c.efficiency {
+				t.Errorf("expected efficiency %v, got %v", c.efficiency, plan.Efficiency())
+			}
+
+			samples, err := layer.ReadRegion(buffer.NewBufferFrom(buf.Bytes()), written.Header, c.region)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(samples) != c.region.Samples() {
+				t.Fatalf("expected %d samples, got %d", c.region.Samples(), len(samples))
+			}
+			i := 0
+			for coord := range c.region.Coordinates() {
+				if samples[i][0] != uint8(coord[0]) || samples[i][1] != uint16(coord[1]*10) {
+					t.Errorf("sample at %v: got %v", coord, samples[i])
+				}
+				i++
+			}
+		})
+	}
+}
+
+func TestExplainReadMissingTiles(t *testing.T) {
+	layer := NewLayer("layer", DimensionSet{{Name: "x", Size: 8, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	layer.TileBytes[1] = 10
+	layer.TileOffsets[1] = 100
+	plan, err := layer.ExplainRead(NewHeader(binary.LittleEndian, OffsetSize4), Region{Start: SampleCoordinate{1}, End: SampleCoordinate{5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(plan.Missing, []int{0, 2}) {
+		t.Errorf("expected tiles 0 and 2 to be missing, got %v", plan.Missing)
+	}
+	if _, err := layer.ReadRegion(buffer.NewBuffer(0), NewHeader(binary.LittleEndian, OffsetSize4), plan.Region); err == nil {
+		t.Error("expected reading a region with missing tiles to fail")
+	}
+	if _, err := layer.ExplainRead(NewHeader(binary.LittleEndian, OffsetSize4), Region{Start: SampleCoordinate{4}, End: SampleCoordinate{9}}); err == nil {
+		t.Error("expected a region outside the layer to be rejected")
+	}
+}
@@ -0,0 +1,95 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+)
+
+// Reads the axis-aligned box of samples starting at the given coordinate and spanning count samples in
+// each dimension into one contiguous buffer, in the order of Region.Coordinates, with each sample laid out
+// as in the tiles of an interleaved layer (booleans as single bytes) even for separated layers. Options are
+// handled as for ReadRegion.
+func (l Layer) ReadRange(r io.ReadSeeker, h Header, start []int, count []int, opts ...ReadOption) ([]byte, error) {
+	if len(start) != len(count) {
+		return nil, ErrFormat(fmt.Sprintf("range has %d start and %d count coordinates", len(start), len(count)))
+	}
+	region := Region{Start: make(SampleCoordinate, len(start)), End: make(SampleCoordinate, len(start))}
+	for i := range start {
+		region.Start[i] = start[i]
+		region.End[i] = start[i] + count[i]
+	}
+	access, plan, err := l.regionAccess(r, h, region, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	layer := access.Layer()
+	dims := layer.Dimensions
+	sampleSize := layer.Channels.Size()
+	buffer := make([]byte, plan.Samples*sampleSize)
+
+	// copy the samples one run along the first dimension at a time, splitting runs where they cross tiles
+	rows := region
+	rows.End = append(SampleCoordinate{}, region.End...)
+	rows.End[0] = region.Start[0] + 1
+	step := strideStep(plan.Stride, 0)
+	at := 0
+	for row := range rows.StridedCoordinates(plan.Stride) {
+		coord := append(SampleCoordinate{}, row...)
+		for coord[0] < region.End[0] {
+			end := min(region.End[0], (coord[0]/dims[0].TileSize+1)*dims[0].TileSize)
+			run := (end - coord[0] + step - 1) / step
+			if err := layer.copyRun(access, coord, run, step, buffer[at:at+run*sampleSize]); err != nil {
+				return nil, err
+			}
+			at += run * sampleSize
+			coord[0] += run * step
+		}
+	}
+	return buffer, nil
+}
+
+// Copies the samples of a run along the first dimension that lies within a single tile, starting at the
+// coordinate and taking every step-th sample, into the buffer as interleaved samples.
+func (l Layer) copyRun(access TileAccessLayer, coord SampleCoordinate, run int, step int, buffer []byte) error {
+	selector := coord.ToTileSelector(l.Dimensions)
+	sampleSize := l.Channels.Size()
+	if !l.Separated {
+		data, err := access.Tile(selector.Tile)
+		if err != nil {
+			return err
+		}
+		if step == 1 {
+			copy(buffer, data[selector.InTile*sampleSize:(selector.InTile+run)*sampleSize])
+			return nil
+		}
+		for i := range run {
+			in := (selector.InTile + i*step) * sampleSize
+			copy(buffer[i*sampleSize:(i+1)*sampleSize], data[in:in+sampleSize])
+		}
+		return nil
+	}
+
+	for c, channel := range l.Channels {
+		tile, offset := l.channelLocation(coord, c)
+		data, err := access.Tile(tile)
+		if err != nil {
+			return err
+		}
+		size := channel.Size()
+		out := l.Channels.Offset(c)
+		for i := range run {
+			if channel.Type == ChannelBool {
+				if UnpackBool(data, offset+i*step) {
+					buffer[out] = 1
+				} else {
+					buffer[out] = 0
+				}
+			} else {
+				copy(buffer[out:out+size], data[offset+i*step*size:])
+			}
+			out += sampleSize
+		}
+	}
+	return nil
+}
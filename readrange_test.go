@@ -0,0 +1,95 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func writeRangeLayer(t *testing.T, order binary.ByteOrder, opts ...LayerOption) *MemoryFile {
+	t.Helper()
+	layer := NewLayer("cube", DimensionSet{{Name: "x", Size: 11, TileSize: 4}, {Name: "y", Size: 7, TileSize: 3}, {Name: "t", Size: 3, TileSize: 2}},
+		ChannelSet{{Name: "value", Type: ChannelFloat64}, {Name: "flag", Type: ChannelBool}, {Name: "id", Type: ChannelInt16}}, opts...)
+	buf := buffer.NewBuffer(10)
+	writeTestPixi(t, buf, NewHeader(order, OffsetSize4), nil, []Layer{layer}, func(_ int, c SampleCoordinate) Sample {
+		return Sample{float64(c[0]) + float64(c[1])/10 + float64(c[2])*100, (c[0]+c[1])%3 == 0, int16(-c[0] * c[2])}
+	})
+	file, err := FromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestReadRange(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, opts := range [][]LayerOption{nil, {WithPlanar(), WithCompression(CompressionFlate)}} {
+			file := writeRangeLayer(t, order, opts...)
+			layer := file.Layers[0]
+			start, count := []int{2, 1, 0}, []int{7, 5, 3}
+			region := Region{Start: SampleCoordinate{2, 1, 0}, End: SampleCoordinate{9, 6, 3}}
+			want, err := layer.ReadRegion(file.Stream(), file.Header, region)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, selected := range [][]ReadOption{nil, {WithChannels("id", "flag")}} {
+				buffer, err := layer.ReadRange(file.Stream(), file.Header, start, count, selected...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				channels := layer.Channels
+				indices := []int{0, 1, 2}
+				if selected != nil {
+					channels, indices = ChannelSet{channels[2], channels[1]}, []int{2, 1}
+				}
+				if len(buffer) != len(want)*channels.Size() {
+					t.Fatalf("expected %d bytes, got %d", len(want)*channels.Size(), len(buffer))
+				}
+				for i, sample := range want {
+					for c, channel := range channels {
+						at := i*channels.Size() + channels.Offset(c)
+						if got := channel.Value(buffer[at:], order); got != sample[indices[c]] {
+							t.Fatalf("%v separated %t: sample %d channel '%s' read as %v, expected %v",
+								order, layer.Separated, i, channel.Name, got, sample[indices[c]])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestReadRangeErrors(t *testing.T) {
+	file := writeRangeLayer(t, binary.LittleEndian)
+	layer := file.Layers[0]
+	for _, bad := range [][2][]int{
+		{{0, 0}, {1, 1}},
+		{{0, 0, 0}, {1, 1}},
+		{{8, 0, 0}, {4, 1, 1}},
+		{{0, 0, 0}, {0, 1, 1}},
+		{{-1, 0, 0}, {2, 1, 1}},
+	} {
+		if _, err := layer.ReadRange(file.Stream(), file.Header, bad[0], bad[1]); err == nil {
+			t.Errorf("expected error reading %d samples from %d", bad[1], bad[0])
+		}
+	}
+
+	sparse := layer
+	sparse.TileBytes = slices.Clone(layer.TileBytes)
+	sparse.TileBytes[0] = 0
+	var notFound ErrTileNotFound
+	if _, err := sparse.ReadRange(file.Stream(), file.Header, []int{0, 0, 0}, []int{2, 2, 1}); !errors.As(err, &notFound) {
+		t.Errorf("expected ErrTileNotFound for an absent tile, got %v", err)
+	}
+	zeros, err := sparse.ReadRange(file.Stream(), file.Header, []int{0, 0, 0}, []int{2, 2, 1}, WithAbsentTileZeros())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.ContainsFunc(zeros, func(b byte) bool { return b != 0 }) {
+		t.Errorf("expected an absent tile to read as zeros, got %v", zeros)
+	}
+}
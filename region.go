@@ -0,0 +1,96 @@
+package gopixi
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+)
+
+// An axis-aligned hyper-rectangle of samples within a layer, selecting every sample coordinate c for which
+// Start[d] <= c[d] < End[d] in each dimension d.
+type Region struct {
+	Start SampleCoordinate
+	End   SampleCoordinate
+}
+
+// Creates a region covering every sample of the given dimension set.
+func FullRegion(set DimensionSet) Region {
+	region := Region{Start: make(SampleCoordinate, len(set)), End: make(SampleCoordinate, len(set))}
+	for i, dim := range set {
+		region.End[i] = dim.Size
+	}
+	return region
+}
+
+// The number of samples the region spans in each dimension.
+func (r Region) Size() []int {
+	size := make([]int, len(r.Start))
+	for i := range r.Start {
+		size[i] = r.End[i] - r.Start[i]
+	}
+	return size
+}
+
+// The total number of samples within the region.
+func (r Region) Samples() int {
+	if len(r.Start) == 0 {
+		return 0
+	}
+	samples := 1
+	for _, s := range r.Size() {
+		samples *= s
+	}
+	return samples
+}
+
+// Returns true if the given sample coordinate lies within the region.
+func (r Region) Contains(coord SampleCoordinate) bool {
+	if len(coord) != len(r.Start) {
+		return false
+	}
+	for i, c := range coord {
+		if c < r.Start[i] || c >= r.End[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks that the region is non-empty and lies entirely within the given dimension set.
+func (r Region) Validate(set DimensionSet) error {
+	if len(r.Start) != len(set) || len(r.End) != len(set) {
+		return ErrFormat(fmt.Sprintf("region has %d/%d coordinates but layer has %d dimensions", len(r.Start), len(r.End), len(set)))
+	}
+	for i, dim := range set {
+		if r.Start[i] < 0 || r.End[i] > dim.Size || r.Start[i] >= r.End[i] {
+			return ErrFormat(fmt.Sprintf("region [%d, %d) is empty or outside of dimension %d with size %d", r.Start[i], r.End[i], i, dim.Size))
+		}
+	}
+	return nil
+}
+
+func (r Region) String() string {
+	return fmt.Sprintf("Region{%v - %v}", r.Start, r.End)
+}
+
+// Iterate over the sample coordinates within the region, with the first dimension changing the most
+// frequently and the last the least frequently, as in DimensionSet.SampleCoordinates.
+func (r Region) Coordinates() iter.Seq[SampleCoordinate] {
+	return func(yield func(coord SampleCoordinate) bool) {
+		samples := r.Samples()
+		coord := slices.Clone(r.Start)
+		for range samples {
+			if !yield(coord) {
+				return
+			}
+			for d := range coord {
+				coord[d] += 1
+				if coord[d] >= r.End[d] {
+					coord[d] = r.Start[d]
+				} else {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package gopixi
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// The palette of images rendered with RenderPlane: transparent for missing values, followed by 255 colors
+// of the ramp from dark blue for the lowest value of the rendered range, through green, to yellow for the
+// highest.
+var RenderPalette color.Palette = renderPalette()
+
+func renderPalette() color.Palette {
+	palette := color.Palette{color.NRGBA{}}
+	for i := range 255 {
+		palette = append(palette, rampColor(float64(i)/254))
+	}
+	return palette
+}
+
+// Describes how RenderPlane shades the values of a channel.
+type RenderOptions struct {
+	Channel string   // The channel to render, the first channel of the layer if empty.
+	Min     *float64 // The value shaded with the first color of the ramp, if given.
+	Max     *float64 // The value shaded with the last color of the ramp, if given.
+	Fill    *float64 // A value marking missing samples, which are rendered transparent like NaN.
+}
+
+// Renders a channel of the plane of the layer through the coordinate spanned by its first two dimensions (the
+// first plane for a nil coordinate) as a paletted image with RenderPalette, the first dimension running
+// across it. Values are clamped to the range of the options, whose missing ends are taken from the channel
+// statistics or else from the values in the plane.
+func RenderPlane(layer TileAccessLayer, at SampleCoordinate, options RenderOptions) (*image.Paletted, error) {
+	channel, err := renderChannel(layer.Layer(), options)
+	if err != nil {
+		return nil, err
+	}
+	plane, err := renderCoordinate(layer.Layer(), at)
+	if err != nil {
+		return nil, err
+	}
+	low, high, err := renderRange(layer, channel, []SampleCoordinate{plane}, options)
+	if err != nil {
+		return nil, err
+	}
+	return renderPlane(layer, channel, plane, low, high, options)
+}
+
+func renderChannel(layer Layer, options RenderOptions) (int, error) {
+	if len(layer.Dimensions) < 2 {
+		return 0, ErrFormat(fmt.Sprintf("layer '%s' must have at least two dimensions to render", layer.Name))
+	}
+	if options.Channel == "" {
+		return 0, nil
+	}
+	channel := layer.Channels.Index(options.Channel)
+	if channel < 0 {
+		return 0, ErrChannelNotFound{ChannelName: options.Channel}
+	}
+	return channel, nil
+}
+
+// A copy of the coordinate of a plane of the layer, checked against its dimensions, or the first plane if
+// the coordinate is nil.
+func renderCoordinate(layer Layer, at SampleCoordinate) (SampleCoordinate, error) {
+	plane := make(SampleCoordinate, len(layer.Dimensions))
+	if at == nil {
+		return plane, nil
+	}
+	if len(at) != len(layer.Dimensions) {
+		return nil, ErrFormat(fmt.Sprintf("coordinate %v has %d dimensions, expected %d", at, len(at), len(layer.Dimensions)))
+	}
+	copy(plane, at)
+	plane[0], plane[1] = 0, 0
+	if !layer.Dimensions.ContainsCoordinate(plane) {
+		return nil, ErrSampleCoordinateOutOfBounds{Coordinate: plane, Dimensions: layer.Dimensions}
+	}
+	return plane, nil
+}
+
+// Visits the value of the channel at every sample of the plane through the coordinate, as NaN where it is
+// missing.
+func forEachPlaneValue(layer TileAccessLayer, channel int, plane SampleCoordinate, fill *float64, f func(x int, y int, value float64)) error {
+	l := layer.Layer()
+	coord := make(SampleCoordinate, len(plane))
+	copy(coord, plane)
+	for y := range l.Dimensions[1].Size {
+		for x := range l.Dimensions[0].Size {
+			coord[0], coord[1] = x, y
+			sample, err := SampleAt(layer, coord)
+			if err != nil {
+				return err
+			}
+			value, ok := l.Channels[channel].Type.ToFloat64(sample[channel])
+			if !ok || (fill != nil && value == *fill) {
+				value = math.NaN()
+			}
+			f(x, y, value)
+		}
+	}
+	return nil
+}
+
+// The range of values shaded by the ramp, from the options, the statistics of the channel, or the values
+// in the planes, in that order of preference.
+func renderRange(layer TileAccessLayer, channel int, planes []SampleCoordinate, options RenderOptions) (float64, float64, error) {
+	low, high := math.NaN(), math.NaN()
+	if options.Min != nil {
+		low = *options.Min
+	}
+	if options.Max != nil {
+		high = *options.Max
+	}
+	stats := layer.Layer().Channels[channel]
+	if math.IsNaN(low) && stats.Min != nil {
+		low, _ = stats.Type.ToFloat64(stats.Min)
+	}
+	if math.IsNaN(high) && stats.Max != nil {
+		high, _ = stats.Type.ToFloat64(stats.Max)
+	}
+	if !math.IsNaN(low) && !math.IsNaN(high) {
+		return low, high, nil
+	}
+
+	least, greatest := math.Inf(1), math.Inf(-1)
+	for _, plane := range planes {
+		err := forEachPlaneValue(layer, channel, plane, options.Fill, func(x int, y int, value float64) {
+			if !math.IsNaN(value) && !math.IsInf(value, 0) {
+				least, greatest = math.Min(least, value), math.Max(greatest, value)
+			}
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if math.IsNaN(low) {
+		low = least
+	}
+	if math.IsNaN(high) {
+		high = greatest
+	}
+	return low, high, nil
+}
+
+func renderPlane(layer TileAccessLayer, channel int, plane SampleCoordinate, low float64, high float64, options RenderOptions) (*image.Paletted, error) {
+	dims := layer.Layer().Dimensions
+	img := image.NewPaletted(image.Rect(0, 0, dims[0].Size, dims[1].Size), RenderPalette)
+	err := forEachPlaneValue(layer, channel, plane, options.Fill, func(x int, y int, value float64) {
+		if math.IsNaN(value) {
+			return // left transparent
+		}
+		t := 0.5
+		if high > low {
+			t = math.Max(0, math.Min(1, (value-low)/(high-low)))
+		}
+		img.SetColorIndex(x, y, uint8(1+math.Round(t*254)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
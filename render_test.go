@@ -0,0 +1,86 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func renderTestLayer(t *testing.T, dims DimensionSet, channels ChannelSet, value func(coord SampleCoordinate) float64) TileAccessLayer {
+	t.Helper()
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("values", dims, channels, WithCompression(CompressionFlate))
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{channels[0].Type.FromFloat64(value(writer.Coordinate()))})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestRenderPlane(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 4, TileSize: 2}, {Name: "y", Size: 3, TileSize: 3}, {Name: "t", Size: 2, TileSize: 1}}
+	values := renderTestLayer(t, dims, ChannelSet{{Name: "v", Type: ChannelFloat32}}, func(coord SampleCoordinate) float64 {
+		return float64(coord[0] + 4*coord[1] + 100*coord[2])
+	})
+
+	// the range is that of the channel statistics, from 0 to 111, so 11 has index 1+round(254*11/111)
+	img, err := RenderPlane(values, nil, RenderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ColorIndexAt(0, 0) != 1 || img.ColorIndexAt(3, 2) != 26 {
+		t.Errorf("unexpected color indices %d and %d for the range of the channel statistics", img.ColorIndexAt(0, 0), img.ColorIndexAt(3, 2))
+	}
+
+	// without statistics, the range spans the values of the plane, with the fill value rendered transparent
+	unscaled, err := NewTransformedReadLayer(values, 4, ScaleOffset{Channel: "v", Scale: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill := 105.0
+	img, err = RenderPlane(unscaled, SampleCoordinate{3, 2, 1}, RenderOptions{Channel: "v", Fill: &fill})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 4 || img.Bounds().Dy() != 3 {
+		t.Fatalf("unexpected image bounds %v", img.Bounds())
+	}
+	if index := img.ColorIndexAt(0, 0); index != 1 {
+		t.Errorf("expected the least value to have the first color, got index %d", index)
+	}
+	if index := img.ColorIndexAt(3, 2); index != 255 {
+		t.Errorf("expected the greatest value to have the last color, got index %d", index)
+	}
+	if index := img.ColorIndexAt(1, 1); index != 0 {
+		t.Errorf("expected the fill value to be transparent, got index %d", index)
+	}
+
+	// values outside a given range are clamped to it
+	low, high := 101.0, 102.0
+	img, err = RenderPlane(values, SampleCoordinate{0, 0, 1}, RenderOptions{Min: &low, Max: &high})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ColorIndexAt(0, 0) != 1 || img.ColorIndexAt(2, 0) != 255 || img.ColorIndexAt(3, 2) != 255 {
+		t.Errorf("unexpected color indices %d, %d, %d for a clamped range", img.ColorIndexAt(0, 0), img.ColorIndexAt(2, 0), img.ColorIndexAt(3, 2))
+	}
+
+	if _, err := RenderPlane(values, SampleCoordinate{0, 0, 2}, RenderOptions{}); err == nil {
+		t.Error("expected error for a plane outside the layer")
+	}
+	if _, err := RenderPlane(values, nil, RenderOptions{Channel: "missing"}); err == nil {
+		t.Error("expected error for a missing channel")
+	}
+}
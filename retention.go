@@ -0,0 +1,123 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Limits how much tile history is kept by CollectGarbage. A generation stays reachable with OpenAt only if
+// every limit allows it; a zero limit does not restrict anything. The generation before the current one
+// always stays reachable.
+type RetentionPolicy struct {
+	// The number of generations before the current one that remain reachable.
+	KeepGenerations int
+	// The maximum age of reachable generations, measured from Now.
+	MaxAge time.Duration
+	// The time from which ages are measured. Defaults to the current time if zero.
+	Now time.Time
+}
+
+// What was removed by CollectGarbage.
+type GarbageReport struct {
+	Generations int   // The number of generation records removed.
+	Versions    int   // The number of retained tile versions removed.
+	Reclaimed   int64 // The number of bytes by which the file shrank, including any other dead space.
+}
+
+func (r GarbageReport) String() string {
+	return fmt.Sprintf("removed %d generations and %d tile versions, reclaimed %d bytes", r.Generations, r.Versions, r.Reclaimed)
+}
+
+// The earliest generation the policy keeps reachable, given the recorded generations (oldest first).
+func (r RetentionPolicy) earliestGeneration(generations []Generation) int {
+	if len(generations) == 0 {
+		return 0
+	}
+	current := generations[len(generations)-1].Number
+	earliest := 0
+	if r.KeepGenerations > 0 {
+		earliest = max(current-r.KeepGenerations, 0)
+	}
+	if r.MaxAge > 0 {
+		now := r.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		cutoff := now.Add(-r.MaxAge)
+		byAge := current
+		for i := len(generations) - 1; i >= 0 && !generations[i].Time.Before(cutoff); i-- {
+			byAge = generations[i].Number
+		}
+		earliest = max(earliest, byAge)
+	}
+	// the current generation's record is always kept, so that generation numbers keep counting up
+	return min(earliest, current-1)
+}
+
+// Copies the Pixi file with tile history in src into the empty stream dst, leaving out the tile versions and
+// generation records needed only to reach generations older than the policy allows, so that the kept
+// generations can still be opened with OpenAt. Tags are merged into a single section in the copy.
+func CollectGarbage(src io.ReadSeeker, dst io.WriteSeeker, policy RetentionPolicy) (*Pixi, GarbageReport, error) {
+	srcPixi, err := ReadPixi(src)
+	if err != nil {
+		return nil, GarbageReport{}, err
+	}
+	srcSize, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, GarbageReport{}, err
+	}
+	generations := srcPixi.Generations()
+	earliest := policy.earliestGeneration(generations)
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, GarbageReport{}, err
+	}
+	dstPixi, err := Clone(src, dst, CloneOptions{})
+	if err != nil {
+		return nil, GarbageReport{}, err
+	}
+	dstPixi.TileHistory = srcPixi.TileHistory
+
+	report := GarbageReport{}
+	relocated := map[int64]int64{}
+	tags := map[string]string{}
+	for _, generation := range generations {
+		if generation.Number <= earliest {
+			report.Generations++
+			for _, v := range generation.Superseded {
+				if v.Bytes != 0 {
+					report.Versions++
+				}
+			}
+			continue
+		}
+		for i, v := range generation.Superseded {
+			if v.Bytes == 0 {
+				continue
+			}
+			offset, ok := relocated[v.Offset]
+			if !ok {
+				offset, err = copyRawTile(src, dst, srcPixi.Header, v.Offset, v.Bytes)
+				if err != nil {
+					return nil, GarbageReport{}, err
+				}
+				relocated[v.Offset] = offset
+			}
+			generation.Superseded[i].Offset = offset
+		}
+		tags[generationTag(generation.Number)] = generation.encode()
+	}
+	if len(tags) > 0 {
+		if err := dstPixi.AppendTags(dst, tags); err != nil {
+			return nil, GarbageReport{}, err
+		}
+	}
+
+	dstSize, err := dst.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, GarbageReport{}, err
+	}
+	report.Reclaimed = srcSize - dstSize
+	return dstPixi, report, nil
+}
@@ -0,0 +1,98 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func newRetentionTestFile(t *testing.T, rewrites int) *buffer.Buffer {
+	t.Helper()
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}})}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), map[string]string{"name": "test"}, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+	written.TileHistory = true
+	for i := 1; i <= rewrites; i++ {
+		if err := written.RewriteTiles(buf, 0, map[int][]byte{0: bytes.Repeat([]byte{uint8(i)}, 4)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf
+}
+
+func TestCollectGarbage(t *testing.T) {
+	buf := newRetentionTestFile(t, 4)
+	dst := buffer.NewBuffer(10)
+	collected, report, err := CollectGarbage(buffer.NewBufferFrom(buf.Bytes()), dst, RetentionPolicy{KeepGenerations: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Generations != 2 || report.Versions != 2 || report.Reclaimed <= 0 {
+		t.Errorf("unexpected report: %v", report)
+	}
+	if int64(len(dst.Bytes())) != int64(len(buf.Bytes()))-report.Reclaimed {
+		t.Errorf("expected %d bytes to be reclaimed", report.Reclaimed)
+	}
+
+	dst.Seek(0, 0)
+	read, err := ReadPixi(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.TileHistory || read.CurrentGeneration() != 4 || collected.CurrentGeneration() != 4 {
+		t.Fatalf("expected generation numbers to be kept, got %d", read.CurrentGeneration())
+	}
+	if read.LiveBytes() != int64(len(dst.Bytes())) || read.AllTags()["name"] != "test" {
+		t.Error("expected a compacted file with ordinary tags kept")
+	}
+	for generation := 2; generation <= 4; generation++ {
+		view, err := read.OpenAt(generation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, 4)
+		if err := view.Layers[0].ReadTile(dst, view.Header, 0, data); err != nil || data[0] != uint8(generation) {
+			t.Errorf("generation %d: unexpected tile %v (%v)", generation, data, err)
+		}
+		if view.AllTags()["name"] != "test" {
+			t.Errorf("generation %d: expected ordinary tags in view", generation)
+		}
+	}
+	if _, err := read.OpenAt(1); err == nil {
+		t.Error("expected collected generation to be unreachable")
+	}
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	generations := []Generation{
+		{Number: 1, Time: now.Add(-72 * time.Hour)},
+		{Number: 2, Time: now.Add(-48 * time.Hour)},
+		{Number: 3, Time: now.Add(-24 * time.Hour)},
+		{Number: 4, Time: now.Add(-time.Hour)},
+	}
+	tests := []struct {
+		policy   RetentionPolicy
+		earliest int
+	}{
+		{RetentionPolicy{}, 0},
+		{RetentionPolicy{KeepGenerations: 10}, 0},
+		{RetentionPolicy{KeepGenerations: 3}, 1},
+		{RetentionPolicy{MaxAge: 30 * time.Hour, Now: now}, 3},
+		{RetentionPolicy{KeepGenerations: 3, MaxAge: 50 * time.Hour, Now: now}, 2},
+		{RetentionPolicy{MaxAge: time.Minute, Now: now}, 3},
+	}
+	for _, test := range tests {
+		if earliest := test.policy.earliestGeneration(generations); earliest != test.earliest {
+			t.Errorf("%+v: expected earliest generation %d, got %d", test.policy, test.earliest, earliest)
+		}
+	}
+	if (RetentionPolicy{KeepGenerations: 1}).earliestGeneration(nil) != 0 {
+		t.Error("expected generation zero without history")
+	}
+}
@@ -0,0 +1,424 @@
+package gopixi
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// The smallest part size accepted by S3 for every part of a multipart upload except the last.
+	MinS3PartSize int = 5 * 1024 * 1024
+	// The part size used by S3MultipartWriter unless another is given with WithPartSize.
+	DefaultS3PartSize int = 16 * 1024 * 1024
+)
+
+type partSizeOption struct {
+	partSize int
+}
+
+func (o partSizeOption) applyHttp(opts *httpOptions) {
+	opts.partSize = o.partSize
+}
+
+// Sets the size of each part uploaded by an S3MultipartWriter. Sizes below MinS3PartSize are raised to it.
+func WithPartSize(bytes int) HttpOption {
+	return partSizeOption{partSize: bytes}
+}
+
+// Streams a file to an object in S3 (or any service implementing the S3 multipart upload API) as it is
+// written, buffering a single part in memory at a time. The object appears once Close completes the upload;
+// Abort discards it. Only sequential writes are supported.
+type S3MultipartWriter struct {
+	url         *url.URL
+	client      *http.Client
+	ctx         context.Context
+	credentials Credentials
+	partSize    int
+	throttle    *Throttle
+
+	uploadId string
+	parts    []s3CompletedPart
+	buffer   bytes.Buffer
+	offset   int64
+	done     bool
+}
+
+// Starts a new multipart upload to the object at the given URL (such as
+// https://bucket.s3.region.amazonaws.com/key).
+func NewS3MultipartWriter(ctx context.Context, objectUrl *url.URL, client *http.Client, opts ...HttpOption) (*S3MultipartWriter, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	options := httpOptions{partSize: DefaultS3PartSize}
+	for _, o := range opts {
+		o.applyHttp(&options)
+	}
+	w := &S3MultipartWriter{
+		url:         objectUrl,
+		client:      client,
+		ctx:         ctx,
+		credentials: options.credentials,
+		partSize:    max(options.partSize, MinS3PartSize),
+		throttle:    options.throttle,
+	}
+
+	resp, err := w.do("POST", url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		UploadId string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid multipart upload response: %w", err)
+	}
+	if result.UploadId == "" {
+		return nil, fmt.Errorf("multipart upload response is missing an upload id")
+	}
+	w.uploadId = result.UploadId
+	return w, nil
+}
+
+func (w *S3MultipartWriter) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, ErrReadOnly{Operation: "write to completed upload"}
+	}
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), w.partSize-w.buffer.Len())
+		w.buffer.Write(p[:n])
+		p = p[n:]
+		written += n
+		w.offset += int64(n)
+		if w.buffer.Len() >= w.partSize {
+			if err := w.uploadPart(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *S3MultipartWriter) Seek(offset int64, whence int) (int64, error) {
+	newOffset := offset
+	switch whence {
+	case io.SeekStart:
+		// nothing to do here
+	case io.SeekCurrent, io.SeekEnd:
+		// the end of the stream is always the current position
+		newOffset += w.offset
+	default:
+		panic(fmt.Sprintf("invalid whence value: %d", whence))
+	}
+	if newOffset != w.offset {
+		return w.offset, ErrUnsupported("multipart uploads only support sequential writes")
+	}
+	return newOffset, nil
+}
+
+// Uploads any remaining buffered data as the final part and completes the upload, making the object
+// visible. Closing again has no effect.
+func (w *S3MultipartWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	if w.buffer.Len() > 0 || len(w.parts) == 0 {
+		if err := w.uploadPart(); err != nil {
+			return err
+		}
+	}
+
+	var complete struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}
+	complete.Parts = w.parts
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	resp, err := w.do("POST", url.Values{"uploadId": {w.uploadId}}, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// S3 may report a failure in the body of a successful response once the upload has started completing
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("multipart upload failed to complete: %s: %s", result.Code, result.Message)
+	}
+	w.done = true
+	return nil
+}
+
+// Aborts the upload, discarding all parts uploaded so far so that no object is created.
+func (w *S3MultipartWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.buffer.Reset()
+	resp, err := w.do("DELETE", url.Values{"uploadId": {w.uploadId}}, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (w *S3MultipartWriter) uploadPart() error {
+	partNumber := len(w.parts) + 1
+	resp, err := w.do("PUT", url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {w.uploadId},
+	}, w.buffer.Bytes())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	w.parts = append(w.parts, s3CompletedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+	w.buffer.Reset()
+	return nil
+}
+
+func (w *S3MultipartWriter) do(method string, query url.Values, body []byte) (*http.Response, error) {
+	requestUrl := *w.url
+	requestUrl.RawQuery = query.Encode()
+	ctx := w.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestUrl.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if w.credentials != nil {
+		if err := w.credentials.Authorize(req); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.throttle.Wait(ctx, int64(len(body))); err != nil {
+		return nil, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unsuccessful http request: response code %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+type s3EndpointOption struct {
+	endpoint string
+}
+
+func (o s3EndpointOption) applyHttp(opts *httpOptions) {
+	opts.s3Endpoint = o.endpoint
+}
+
+// Sets the endpoint of the S3-compatible service that s3:// URLs refer to, such as http://localhost:9000
+// for a local MinIO server, in which case objects are addressed in path style as <endpoint>/<bucket>/<key>.
+// Without this option the endpoint is read from the AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL environment
+// variables, and otherwise is that of S3 itself in the region of the environment.
+func WithS3Endpoint(endpoint string) HttpOption {
+	return s3EndpointOption{endpoint: endpoint}
+}
+
+// Resolves an s3://bucket/key URL to the HTTP URL of the object, using the endpoint given by WithS3Endpoint
+// among the options or else by the environment (see WithS3Endpoint). Objects in S3 itself are addressed
+// in virtual-hosted style, as https://<bucket>.s3.<region>.amazonaws.com/<key>.
+func S3ObjectUrl(s3Url *url.URL, opts ...HttpOption) (*url.URL, error) {
+	if s3Url.Scheme != "s3" || s3Url.Host == "" || strings.Trim(s3Url.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid s3 url '%s': expected s3://bucket/key", s3Url)
+	}
+	options := httpOptions{}
+	for _, o := range opts {
+		o.applyHttp(&options)
+	}
+	endpoint := cmp.Or(options.s3Endpoint, os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"))
+	key := strings.TrimPrefix(s3Url.Path, "/")
+	if endpoint == "" {
+		return &url.URL{Scheme: "https", Host: s3Url.Host + ".s3." + s3Region() + ".amazonaws.com", Path: "/" + key}, nil
+	}
+	objectUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return objectUrl.JoinPath(s3Url.Host, key), nil
+}
+
+// Opens the object at an s3://bucket/key URL (see S3ObjectUrl) for reading, as with OpenBufferedHttp, so
+// that tiles are fetched with concurrent ranged GET requests. Unless credentials are given in the options,
+// requests are signed with the credentials of the environment (see S3CredentialsFromEnv) if there are any,
+// and are otherwise sent anonymously, as for public buckets.
+func OpenS3(s3Url *url.URL, client *http.Client, opts ...HttpOption) (*BufferedHttpReadSeeker, error) {
+	objectUrl, err := S3ObjectUrl(s3Url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return OpenBufferedHttp(objectUrl, client, withS3EnvCredentials(opts)...)
+}
+
+// Starts a multipart upload to the object at an s3://bucket/key URL (see S3ObjectUrl), as with
+// NewS3MultipartWriter, authorizing requests as described for OpenS3.
+func CreateS3(ctx context.Context, s3Url *url.URL, client *http.Client, opts ...HttpOption) (*S3MultipartWriter, error) {
+	objectUrl, err := S3ObjectUrl(s3Url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewS3MultipartWriter(ctx, objectUrl, client, withS3EnvCredentials(opts)...)
+}
+
+// Prepends the credentials of the environment to the options, if there are any, so that any credentials
+// among the options take precedence.
+func withS3EnvCredentials(opts []HttpOption) []HttpOption {
+	if credentials, ok := S3CredentialsFromEnv(); ok {
+		return append([]HttpOption{WithCredentials(credentials)}, opts...)
+	}
+	return opts
+}
+
+// The region of the environment, from AWS_REGION or AWS_DEFAULT_REGION, or us-east-1 if neither is set.
+func s3Region() string {
+	return cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+}
+
+// Credentials that sign requests to S3 or an S3-compatible service with AWS Signature Version 4. When the
+// access key is empty, Authorize returns ErrNoCredentials so that a CredentialsChain falls through.
+type S3Credentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string // Optional, for temporary credentials.
+	Region          string // The region of the bucket; us-east-1 if empty.
+
+	now func() time.Time // the signing time, for tests
+}
+
+// The credentials given by the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, and
+// AWS_REGION (or AWS_DEFAULT_REGION) environment variables, and whether there are any.
+func S3CredentialsFromEnv() (S3Credentials, bool) {
+	credentials := S3Credentials{
+		AccessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Region:          s3Region(),
+	}
+	return credentials, credentials.AccessKeyId != ""
+}
+
+func (c S3Credentials) Authorize(req *http.Request) error {
+	if c.AccessKeyId == "" {
+		return ErrNoCredentials
+	}
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	signed := now().UTC()
+	amzDate := signed.Format("20060102T150405Z")
+	scope := signed.Format("20060102") + "/" + cmp.Or(c.Region, "us-east-1") + "/s3/aws4_request"
+
+	payload := sha256.New()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(payload, body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload.Sum(nil)))
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	// the host, any range, and every x-amz- header are signed
+	headers := map[string]string{"host": cmp.Or(req.Host, req.URL.Host)}
+	for key, values := range req.Header {
+		if key := strings.ToLower(key); key == "range" || strings.HasPrefix(key, "x-amz-") {
+			headers[key] = strings.Join(values, ",")
+		}
+	}
+	names := slices.Sorted(maps.Keys(headers))
+	canonical := strings.Builder{}
+	canonical.WriteString(req.Method + "\n" + s3Escape(req.URL.Path, false) + "\n")
+	query := req.URL.Query()
+	params := []string{}
+	for _, key := range slices.Sorted(maps.Keys(query)) {
+		for _, value := range query[key] {
+			params = append(params, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	canonical.WriteString(strings.Join(params, "&") + "\n")
+	for _, name := range names {
+		canonical.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical.WriteString("\n" + signedHeaders + "\n" + req.Header.Get("X-Amz-Content-Sha256"))
+
+	hashed := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	key := []byte("AWS4" + c.SecretAccessKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSha256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSha256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		c.AccessKeyId, scope, signedHeaders, signature))
+	return nil
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Percent-encodes every byte of the string other than the unreserved characters of RFC 3986, and slashes
+// unless encodeSlash is set, as required for the canonical requests of AWS signatures.
+func s3Escape(s string, encodeSlash bool) string {
+	b := strings.Builder{}
+	for i := range len(s) {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || strings.IndexByte("-_.~", c) >= 0 || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
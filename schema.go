@@ -0,0 +1,194 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// A reusable description of the structure of a family of datasets, such as a daily product whose files all
+// share the same layers, dimensions, axes, channels, and compression. A schema is defined once, either by
+// hand or from a reference file with SchemaOf, and used to create any number of datasets with Create and
+// Layer, and to check that an existing dataset conforms to it with Validate.
+type Schema struct {
+	ByteOrder  binary.ByteOrder
+	OffsetSize OffsetSize
+	// Tags that every dataset carries with exactly these values.
+	Tags map[string]string
+	// The names of tags that every dataset must carry, with values that vary between datasets (such as
+	// the date of a daily product).
+	RequiredTags []string
+	// The layers of every dataset, in order. Only their structure is used: tile data, and the Min and Max
+	// statistics of their channels, are not part of the schema.
+	Layers []Layer
+}
+
+// Describes the structure of an existing dataset as a schema, so that further datasets can be created
+// like it. Reserved tags (those beginning with "pixi.") and the embedded preview layer are not included.
+func SchemaOf(p *Pixi) Schema {
+	s := Schema{ByteOrder: p.Header.ByteOrder, OffsetSize: p.Header.OffsetSize, Tags: map[string]string{}}
+	for key, value := range p.AllTags() {
+		if !strings.HasPrefix(key, "pixi.") {
+			s.Tags[key] = value
+		}
+	}
+	for _, layer := range p.Layers {
+		if layer.Name != PreviewLayerName {
+			s.Layers = append(s.Layers, layer.template())
+		}
+	}
+	return s
+}
+
+// The layer with its structure but none of its data: no tiles are written and channel statistics are unset.
+func (l Layer) template() Layer {
+	template := NewLayer(l.Name, slices.Clone(l.Dimensions), slices.Clone(l.Channels), WithCompression(l.Compression))
+	template.Separated = l.Separated
+	template.HeaderDictionary = l.HeaderDictionary
+	for i := range template.Channels {
+		template.Channels[i].Min = nil
+		template.Channels[i].Max = nil
+	}
+	return template
+}
+
+// Starts a new dataset following the schema, writing a header with the schema's byte order and offset size
+// followed by a tag section holding the schema's tags together with the given per-dataset tags. Returns an
+// error if a tag required by the schema is not given, or if a given tag conflicts with a fixed tag of the
+// schema. Layers are then appended as usual, starting from the templates returned by Layer.
+func (s Schema) Create(w io.WriteSeeker, tags map[string]string, opts ...CreateOption) (*Pixi, error) {
+	for _, name := range s.RequiredTags {
+		if _, ok := tags[name]; !ok {
+			return nil, ErrFormat(fmt.Sprintf("schema requires tag '%s'", name))
+		}
+	}
+	allTags := maps.Clone(s.Tags)
+	if allTags == nil {
+		allTags = map[string]string{}
+	}
+	for key, value := range tags {
+		if fixed, ok := s.Tags[key]; ok && fixed != value {
+			return nil, ErrFormat(fmt.Sprintf("tag '%s' is fixed to '%s' by the schema", key, fixed))
+		}
+		allTags[key] = value
+	}
+
+	p, err := Create(w, NewHeader(s.ByteOrder, s.OffsetSize), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(allTags) > 0 {
+		if err := p.AppendTags(w, allTags); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Returns a fresh copy of the named layer of the schema, ready to be appended to a new dataset.
+func (s Schema) Layer(name string) (Layer, error) {
+	index := slices.IndexFunc(s.Layers, func(l Layer) bool { return l.Name == name })
+	if index < 0 {
+		return Layer{}, ErrFormat(fmt.Sprintf("schema has no layer '%s'", name))
+	}
+	return s.Layers[index].template(), nil
+}
+
+// Checks that the dataset conforms to the schema, reporting every difference found together in an
+// ErrNonConformant error. Tags and preview layers beyond those of the schema are allowed.
+func (s Schema) Validate(p *Pixi) error {
+	problems := []string{}
+	if p.Header.ByteOrder != s.ByteOrder {
+		problems = append(problems, fmt.Sprintf("byte order is %v, expected %v", p.Header.ByteOrder, s.ByteOrder))
+	}
+	if p.Header.OffsetSize != s.OffsetSize {
+		problems = append(problems, fmt.Sprintf("offset size is %d, expected %d", p.Header.OffsetSize, s.OffsetSize))
+	}
+
+	tags := p.AllTags()
+	for _, key := range slices.Sorted(maps.Keys(s.Tags)) {
+		value, ok := tags[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing tag '%s'", key))
+		} else if value != s.Tags[key] {
+			problems = append(problems, fmt.Sprintf("tag '%s' is '%s', expected '%s'", key, value, s.Tags[key]))
+		}
+	}
+	for _, key := range s.RequiredTags {
+		if _, ok := tags[key]; !ok {
+			problems = append(problems, fmt.Sprintf("missing tag '%s'", key))
+		}
+	}
+
+	layers := slices.DeleteFunc(slices.Clone(p.Layers), func(l Layer) bool { return l.Name == PreviewLayerName })
+	if len(layers) != len(s.Layers) {
+		problems = append(problems, fmt.Sprintf("has %d layers, expected %d", len(layers), len(s.Layers)))
+	}
+	for i := range min(len(layers), len(s.Layers)) {
+		problems = append(problems, layerConformance(layers[i], s.Layers[i])...)
+	}
+
+	if len(problems) > 0 {
+		return ErrNonConformant{Problems: problems}
+	}
+	return nil
+}
+
+func layerConformance(layer Layer, expected Layer) []string {
+	if layer.Name != expected.Name {
+		return []string{fmt.Sprintf("layer '%s' found where layer '%s' was expected", layer.Name, expected.Name)}
+	}
+	problems := []string{}
+	report := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("layer '%s': ", layer.Name)+fmt.Sprintf(format, args...))
+	}
+	if layer.Separated != expected.Separated {
+		report("separated is %t, expected %t", layer.Separated, expected.Separated)
+	}
+	if layer.Compression != expected.Compression {
+		report("compression is %v, expected %v", layer.Compression, expected.Compression)
+	}
+
+	if len(layer.Dimensions) != len(expected.Dimensions) {
+		report("has %d dimensions, expected %d", len(layer.Dimensions), len(expected.Dimensions))
+	} else {
+		for i, dim := range layer.Dimensions {
+			want := expected.Dimensions[i]
+			switch {
+			case dim.Name != want.Name:
+				report("dimension %d is '%s', expected '%s'", i, dim.Name, want.Name)
+			case dim.Size != want.Size || dim.TileSize != want.TileSize:
+				report("dimension '%s' has size %d and tile size %d, expected %d and %d", dim.Name, dim.Size, dim.TileSize, want.Size, want.TileSize)
+			case !axesEqual(dim.Axis, want.Axis):
+				report("dimension '%s' has axis %v, expected %v", dim.Name, dim.Axis, want.Axis)
+			}
+		}
+	}
+
+	if len(layer.Channels) != len(expected.Channels) {
+		report("has %d channels, expected %d", len(layer.Channels), len(expected.Channels))
+	} else {
+		for i, channel := range layer.Channels {
+			want := expected.Channels[i]
+			switch {
+			case channel.Name != want.Name:
+				report("channel %d is '%s', expected '%s'", i, channel.Name, want.Name)
+			case channel.Type.Base() != want.Type.Base():
+				report("channel '%s' has type %v, expected %v", channel.Name, channel.Type.Base(), want.Type.Base())
+			case channel.Unit != want.Unit:
+				report("channel '%s' has unit '%s', expected '%s'", channel.Name, channel.Unit, want.Unit)
+			}
+		}
+	}
+	return problems
+}
+
+func axesEqual(a *Axis, b *Axis) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Type == b.Type && a.Minimum == b.Minimum && a.Step == b.Step && a.Unit == b.Unit && a.Ref == b.Ref && a.Calendar == b.Calendar && slices.Equal(a.Coordinates, b.Coordinates)
+}
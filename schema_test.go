@@ -0,0 +1,138 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func newTestSchema() Schema {
+	return Schema{
+		ByteOrder:    binary.LittleEndian,
+		OffsetSize:   OffsetSize8,
+		Tags:         map[string]string{"product": "daily temperature"},
+		RequiredTags: []string{"date"},
+		Layers: []Layer{NewLayer("temperature",
+			DimensionSet{
+				{Name: "x", Size: 8, TileSize: 4, Axis: &Axis{Type: ChannelFloat64, Minimum: -10.0, Step: 2.5, Unit: "km"}},
+				{Name: "y", Size: 6, TileSize: 3},
+			},
+			ChannelSet{{Name: "t", Type: ChannelFloat32, Unit: "K"}, {Name: "valid", Type: ChannelBool}},
+			WithCompression(CompressionFlate),
+		)},
+	}
+}
+
+func writeFromSchema(t *testing.T, schema Schema, tags map[string]string) *buffer.Buffer {
+	t.Helper()
+	buf := buffer.NewBuffer(10)
+	pixi, err := schema.Create(buf, tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := schema.Layer("temperature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewTileOrderWriteIterator(buf, pixi.Header, layer)
+	err = pixi.AppendIterativeLayer(buf, layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			coord := writer.Coordinate()
+			if layer.Dimensions.ContainsCoordinate(coord) {
+				writer.SetSample(Sample{float32(coord[0] + coord[1]), coord[0] > 2})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestSchemaCreateAndValidate(t *testing.T) {
+	schema := newTestSchema()
+	for _, date := range []string{"2026-10-01", "2026-10-02"} {
+		buf := writeFromSchema(t, schema, map[string]string{"date": date})
+		read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := schema.Validate(read); err != nil {
+			t.Errorf("%s: expected dataset to conform, got %v", date, err)
+		}
+		if tags := read.AllTags(); tags["date"] != date || tags["product"] != "daily temperature" {
+			t.Errorf("%s: unexpected tags %v", date, tags)
+		}
+	}
+
+	if _, err := schema.Create(buffer.NewBuffer(10), nil); err == nil {
+		t.Error("expected an error when a required tag is missing")
+	}
+	if _, err := schema.Create(buffer.NewBuffer(10), map[string]string{"date": "x", "product": "other"}); err == nil {
+		t.Error("expected an error when a fixed tag is overridden")
+	}
+	if _, err := schema.Layer("missing"); err == nil {
+		t.Error("expected an error for a layer not in the schema")
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema := newTestSchema()
+	buf := writeFromSchema(t, schema, map[string]string{"date": "2026-10-01"})
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	derived := SchemaOf(read)
+	if err := derived.Validate(read); err != nil {
+		t.Fatalf("expected a dataset to conform to its own schema, got %v", err)
+	}
+	if derived.Tags["date"] != "2026-10-01" {
+		t.Errorf("expected the derived schema to fix every tag, got %v", derived.Tags)
+	}
+	if channel := derived.Layers[0].Channels[0]; channel.Min != nil || channel.Max != nil {
+		t.Errorf("expected channel statistics to be left out of the schema, got %v, %v", channel.Min, channel.Max)
+	}
+	if derived.Layers[0].TileBytes[0] != 0 {
+		t.Error("expected the schema layers to carry no tile data")
+	}
+}
+
+func TestSchemaValidateNonConformant(t *testing.T) {
+	buf := writeFromSchema(t, newTestSchema(), map[string]string{"date": "2026-10-01"})
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestSchema()
+	other.OffsetSize = OffsetSize4
+	other.Tags = map[string]string{"product": "daily humidity", "version": "2"}
+	other.RequiredTags = []string{"date", "site"}
+	layer := &other.Layers[0]
+	layer.Compression = CompressionNone
+	layer.Dimensions[0].Axis = &Axis{Type: ChannelFloat64, Minimum: -10.0, Step: 5.0, Unit: "km"}
+	layer.Dimensions[1].Size = 7
+	layer.Channels[0].Unit = "degC"
+	layer.Channels[1].Type = ChannelUint8
+
+	err = other.Validate(read)
+	var nonConformant ErrNonConformant
+	if !errors.As(err, &nonConformant) {
+		t.Fatalf("expected ErrNonConformant, got %v", err)
+	}
+	if len(nonConformant.Problems) != 9 {
+		t.Errorf("expected 9 problems, got %d: %v", len(nonConformant.Problems), nonConformant.Problems)
+	}
+
+	renamed := newTestSchema()
+	renamed.Layers[0].Name = "humidity"
+	renamed.Layers = append(renamed.Layers, renamed.Layers[0])
+	if err := renamed.Validate(read); !errors.As(err, &nonConformant) || len(nonConformant.Problems) != 2 {
+		t.Errorf("expected a layer count and a layer name problem, got %v", err)
+	}
+}
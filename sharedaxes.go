@@ -0,0 +1,172 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// Checks that the axis can be stored as a shared axis with the given identifier: it must be complete, and
+// cannot itself refer to another shared axis.
+func checkSharedAxis(id string, a *Axis) error {
+	switch {
+	case id == "":
+		return ErrFormat("shared axis identifier must not be empty")
+	case a == nil || a.Type.Base() == ChannelUnknown:
+		return ErrFormat(fmt.Sprintf("shared axis '%s' must have a type", id))
+	case a.Ref != "":
+		return ErrFormat(fmt.Sprintf("shared axis '%s' cannot refer to shared axis '%s'", id, a.Ref))
+	case a.Coordinates == nil && (a.Minimum == nil || a.Step == nil):
+		return ErrFormat(fmt.Sprintf("shared axis '%s' must have coordinates or both minimum and step values", id))
+	}
+	return nil
+}
+
+// The size in bytes of the shared axes as they are written to disk.
+func sharedAxesSize(h Header, axes map[string]*Axis) int {
+	size := 4
+	for id, axis := range axes {
+		size += h.FriendlySize(id) + 4 + axis.HeaderSize(h)
+	}
+	return size
+}
+
+// Writes the shared axes to the current position in the writer stream, in order of their identifiers: a
+// four-byte count of axes, then for each its identifier as a friendly string, a four-byte count of its
+// explicit coordinates (zero for regular axes), and the axis as it is written with a dimension.
+func writeSharedAxes(w io.Writer, h Header, axes map[string]*Axis) error {
+	if h.Version < VersionExtensions {
+		return ErrFormat(fmt.Sprintf("shared axes require version %d or later", VersionExtensions))
+	}
+	if err := h.Write(w, uint32(len(axes))); err != nil {
+		return err
+	}
+	for _, id := range slices.Sorted(maps.Keys(axes)) {
+		axis := axes[id]
+		if err := checkSharedAxis(id, axis); err != nil {
+			return err
+		}
+		if err := h.WriteFriendly(w, id); err != nil {
+			return err
+		}
+		if err := h.Write(w, uint32(len(axis.Coordinates))); err != nil {
+			return err
+		}
+		if err := axis.Write(w, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads shared axes written by writeSharedAxes from the current position in the reader stream.
+func readSharedAxes(r io.Reader, h Header) (map[string]*Axis, error) {
+	var count uint32
+	if err := h.Read(r, &count); err != nil {
+		return nil, ErrFormat(fmt.Sprintf("reading shared axis count: %s", err))
+	}
+	axes := make(map[string]*Axis, min(count, 1024))
+	for range count {
+		id, err := h.ReadFriendly(r)
+		if err != nil {
+			return nil, ErrFormat(fmt.Sprintf("reading shared axis identifier: %s", err))
+		}
+		var coordinates uint32
+		if err := h.Read(r, &coordinates); err != nil {
+			return nil, ErrFormat(fmt.Sprintf("reading shared axis '%s': %s", id, err))
+		}
+		var encodedType ChannelType
+		if err := h.Read(r, &encodedType); err != nil {
+			return nil, ErrFormat(fmt.Sprintf("reading shared axis '%s': %s", id, err))
+		}
+		if encodedType.Base() == ChannelUnknown || encodedType&axisTypeReferenceFlag != 0 {
+			return nil, ErrFormat(fmt.Sprintf("shared axis '%s' has an invalid type", id))
+		}
+		axis := &Axis{}
+		if encodedType&axisTypeCoordinatesFlag != 0 {
+			err = axis.readCoordinates(r, h, encodedType, int(coordinates))
+		} else {
+			err = axis.Read(r, h, encodedType)
+		}
+		if err != nil {
+			return nil, ErrFormat(fmt.Sprintf("reading shared axis '%s': %s", id, err))
+		}
+		axes[id] = axis
+	}
+	return axes, nil
+}
+
+// The shared axes of the file by identifier, merged from every tag section, with those of later sections
+// replacing those of the same identifier in earlier ones.
+func (p *Pixi) SharedAxes() map[string]*Axis {
+	axes := map[string]*Axis{}
+	for _, t := range p.Tags {
+		maps.Copy(axes, t.Axes)
+	}
+	return axes
+}
+
+// Returns an axis referring to the shared axis of the file with the given identifier, holding its values,
+// for use as the axis of the dimensions of new layers. Returns nil if the file has no such shared axis.
+func (p *Pixi) SharedAxis(id string) *Axis {
+	shared, ok := p.SharedAxes()[id]
+	if !ok {
+		return nil
+	}
+	axis := *shared
+	axis.Ref = id
+	return &axis
+}
+
+// Appends a new tag section holding only the given shared axes to the end of the file, as AppendTags does
+// for tags. Axes replace any shared axes of the same identifier appended earlier, and the axes of every
+// layer referring to them are updated to match. Requires VersionExtensions or later.
+func (p *Pixi) AppendAxes(w io.WriteSeeker, axes map[string]*Axis) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append axes"}
+	}
+	for id, axis := range axes {
+		if err := checkSharedAxis(id, axis); err != nil {
+			return err
+		}
+	}
+	err := p.appendTagSection(w, TagSection{Tags: map[string]string{}, Axes: maps.Clone(axes)})
+	if err != nil {
+		return err
+	}
+	p.resolveAxes()
+	return nil
+}
+
+// Fills in the axes of the dimensions of every layer that refer to a shared axis of the file.
+func (p *Pixi) resolveAxes() {
+	axes := p.SharedAxes()
+	for i, layer := range p.Layers {
+		p.Layers[i] = resolveLayerAxes(layer, axes)
+	}
+}
+
+// Returns the layer with the axes of its dimensions that refer to one of the shared axes replaced by a copy
+// of it, keeping the reference. The dimensions of the layer are copied if any axis is replaced. Axes
+// referring to unknown shared axes are left as they are.
+func resolveLayerAxes(layer Layer, axes map[string]*Axis) Layer {
+	cloned := false
+	for d, dim := range layer.Dimensions {
+		if dim.Axis == nil || dim.Axis.Ref == "" {
+			continue
+		}
+		shared, ok := axes[dim.Axis.Ref]
+		if !ok {
+			continue
+		}
+		if !cloned {
+			layer.Dimensions = slices.Clone(layer.Dimensions)
+			cloned = true
+		}
+		resolved := *shared
+		resolved.Ref = dim.Axis.Ref
+		layer.Dimensions[d].Axis = &resolved
+	}
+	return layer
+}
@@ -0,0 +1,209 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestSharedAxesWriteRead(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	pixi, err := Create(buf, NewHeader(binary.BigEndian, OffsetSize8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pixi.AppendAxes(buf, map[string]*Axis{
+		"lon":    {Type: ChannelFloat64, Minimum: -180.0, Step: 0.5, Unit: "degrees_east"},
+		"levels": {Type: ChannelInt16, Unit: "hPa", Coordinates: []any{int16(1000), int16(700), int16(300)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"u", "v"} {
+		layer := NewLayer(name, DimensionSet{
+			{Name: "lon", Size: 4, TileSize: 2, Axis: pixi.SharedAxis("lon")},
+			{Name: "level", Size: 3, TileSize: 3, Axis: pixi.SharedAxis("levels")},
+		}, ChannelSet{{Name: name, Type: ChannelFloat32}})
+		writer := NewTileOrderWriteIterator(buf, pixi.Header, layer)
+		err = pixi.AppendIterativeLayer(buf, layer, writer, func(writer IterativeLayerWriter) error {
+			for writer.Next() {
+				writer.SetSample(Sample{float32(writer.Coordinate()[0])})
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the axes are written once, and only referred to by each layer
+	inline := pixi.Layers[0]
+	inline.Dimensions = slices.Clone(inline.Dimensions)
+	for d := range inline.Dimensions {
+		axis := *inline.Dimensions[d].Axis
+		axis.Ref = ""
+		inline.Dimensions[d].Axis = &axis
+	}
+	if shared, full := pixi.Layers[0].HeaderSize(pixi.Header), inline.HeaderSize(pixi.Header); shared >= full {
+		t.Errorf("expected a header referring to shared axes (%d bytes) to be smaller than one storing them (%d bytes)", shared, full)
+	}
+
+	// redefining a shared axis changes the axis of every layer referring to it
+	err = pixi.AppendAxes(buf, map[string]*Axis{"lon": {Type: ChannelFloat64, Minimum: 0.0, Step: 0.5, Unit: "degrees_east"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range read.Layers {
+		lon, level := layer.Dimensions[0].Axis, layer.Dimensions[1].Axis
+		if lon.Ref != "lon" || lon.Minimum != 0.0 || lon.Step != 0.5 || lon.Unit != "degrees_east" {
+			t.Errorf("layer '%s': expected the redefined shared axis, got %+v", layer.Name, *lon)
+		}
+		if level.Ref != "levels" || level.StepValue(1) != int16(700) {
+			t.Errorf("layer '%s': expected the shared levels, got %+v", layer.Name, *level)
+		}
+	}
+	if !axesEqual(read.Layers[0].Dimensions[0].Axis, read.SharedAxis("lon")) {
+		t.Errorf("expected the layer axis to match the shared axis")
+	}
+}
+
+func TestSharedAxesInvalid(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	pixi, err := Create(buf, NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, axes := range map[string]map[string]*Axis{
+		"empty identifier": {"": {Type: ChannelInt32, Minimum: int32(0), Step: int32(1)}},
+		"missing step":     {"x": {Type: ChannelInt32, Minimum: int32(0)}},
+		"reference":        {"x": {Type: ChannelInt32, Minimum: int32(0), Step: int32(1), Ref: "y"}},
+		"no type":          {"x": {Minimum: int32(0), Step: int32(1)}},
+	} {
+		if err := pixi.AppendAxes(buf, axes); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if pixi.SharedAxis("x") != nil {
+		t.Errorf("expected no shared axis 'x'")
+	}
+
+	old := NewHeader(binary.LittleEndian, OffsetSize4)
+	old.Version = VersionExtensions - 1
+	dim := Dimension{Name: "x", Size: 4, TileSize: 4, Axis: &Axis{Type: ChannelInt32, Ref: "x"}}
+	if err := dim.Write(&bytes.Buffer{}, old); err == nil {
+		t.Errorf("expected axis references to require version %d", VersionExtensions)
+	}
+	section := TagSection{Axes: map[string]*Axis{"x": {Type: ChannelInt32, Minimum: int32(0), Step: int32(1)}}}
+	if err := section.Write(&bytes.Buffer{}, old); err == nil {
+		t.Errorf("expected shared axes to require version %d", VersionExtensions)
+	}
+}
+
+func TestSharedAxesStreamAndClone(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewStreamWriter(&out, NewHeader(binary.LittleEndian, OffsetSize8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time := &Axis{Type: ChannelInt64, Minimum: int64(1700000000), Step: int64(60), Unit: "s"}
+	if err := writer.AddAxes(map[string]*Axis{"time": time}); err != nil {
+		t.Fatal(err)
+	}
+	ref := *time
+	ref.Ref = "time"
+	layer := NewLayer("series", DimensionSet{{Name: "time", Size: 6, TileSize: 4, Axis: &ref}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	err = writer.AppendLayer(layer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{uint8(writer.Coordinate()[0])})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPixi(buffer.NewBufferFrom(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if axis := read.Layers[0].Dimensions[0].Axis; axis.Ref != "time" || axis.StepValue(2) != int64(1700000120) {
+		t.Errorf("expected the shared time axis, got %+v", *axis)
+	}
+
+	cloned := buffer.NewBuffer(10)
+	whole, err := Clone(buffer.NewBufferFrom(out.Bytes()), cloned, CloneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if axis := whole.Layers[0].Dimensions[0].Axis; axis.Ref != "time" || len(whole.SharedAxes()) != 1 {
+		t.Errorf("expected the clone to keep the shared axis, got %+v", *axis)
+	}
+
+	region := &Region{Start: SampleCoordinate{2}, End: SampleCoordinate{5}}
+	extracted, err := Clone(buffer.NewBufferFrom(out.Bytes()), buffer.NewBuffer(10), CloneOptions{Region: region})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if axis := extracted.Layers[0].Dimensions[0].Axis; axis.Ref != "" || axis.Minimum != int64(1700000120) {
+		t.Errorf("expected the extracted region to store its own axis, got %+v", *axis)
+	}
+}
+
+func TestManifestSharedAxes(t *testing.T) {
+	// the files only refer to the axes, which are defined by the manifest
+	grid := DimensionSet{
+		{Name: "x", Size: 4, TileSize: 2, Axis: &Axis{Type: ChannelFloat32, Ref: "x"}},
+		{Name: "depth", Size: 3, TileSize: 2, Axis: &Axis{Type: ChannelFloat64, Ref: "depth"}},
+	}
+	channels := ChannelSet{{Name: "v", Type: ChannelUint16}}
+	minimum, step := 100.0, 30.0
+	manifest := Manifest{
+		SharedDimensions: []string{"x", "depth"},
+		Axes: map[string]ManifestAxis{
+			"x":     {Type: "float32", Minimum: &minimum, Step: &step, Unit: "m"},
+			"depth": {Type: "float64", Unit: "m", Coordinates: []float64{0, 10, 50}},
+		},
+		Files: []ManifestFile{{Path: "a.pixi"}, {Path: "b.pixi"}},
+	}
+	var encoded bytes.Buffer
+	if err := manifest.Write(&encoded); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"a.pixi":        manifestTestFile(t, nil, NewLayer("a", grid, channels)),
+		"b.pixi":        manifestTestFile(t, nil, NewLayer("b", grid, channels)),
+		"manifest.json": {Data: encoded.Bytes()},
+	}
+	dataset, err := OpenManifest(fsys, "manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dataset.Close()
+	for _, layer := range dataset.Layers {
+		if x := layer.Dimensions[0].Axis; x.Minimum != float32(100) || x.Step != float32(30) || x.Ref != "x" {
+			t.Errorf("layer '%s': expected the manifest x axis, got %+v", layer.Name, *x)
+		}
+		if depth := layer.Dimensions[1].Axis; depth.StepValue(2) != 50.0 {
+			t.Errorf("layer '%s': expected the manifest depth axis, got %+v", layer.Name, *depth)
+		}
+	}
+
+	invalid := Manifest{Axes: map[string]ManifestAxis{"x": {Type: "complex64", Minimum: &minimum, Step: &step}}, Files: manifest.Files}
+	encoded.Reset()
+	if err := invalid.Write(&encoded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadManifest(&encoded); err == nil {
+		t.Errorf("expected a manifest axis of an unknown type to be rejected")
+	}
+}
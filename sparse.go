@@ -0,0 +1,25 @@
+package gopixi
+
+import "bytes"
+
+type sparseTilesOption bool
+
+func (o sparseTilesOption) applyLayer(opts *layerOptions) {
+	opts.sparseTiles = bool(o)
+}
+
+// Makes writers leave unwritten every tile of the layer whose samples all hold the fill values of their
+// channels (see Layer.SparseTiles).
+func WithSparseTiles() LayerOption {
+	return sparseTilesOption(true)
+}
+
+// Whether the decoded data of a tile of the layer is entirely the FillSample of its channels, compared bit
+// for bit, so that it need not be written. Layers without channel fill values have no fill tiles.
+func (l Layer) isFillTile(h Header, tile int, data []byte) bool {
+	if !l.Channels.HasFillValues() {
+		return false
+	}
+	fill, err := l.FillTile(h, tile, l.Channels.FillSample())
+	return err == nil && bytes.Equal(fill, data)
+}
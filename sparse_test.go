@@ -0,0 +1,119 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSparseTilesFillValues(t *testing.T) {
+	channels := ChannelSet{
+		{Name: "sst", Type: ChannelFloat32, FillValue: float32(math.NaN())},
+		{Name: "ice", Type: ChannelUint8},
+	}
+	dims := DimensionSet{{Name: "x", Size: 8, TileSize: 4}, {Name: "y", Size: 8, TileSize: 4}}
+	land := func(coord SampleCoordinate) bool { return coord[0] >= 4 && coord[1] < 4 }
+	ocean := func(coord SampleCoordinate) Sample {
+		if coord[1] >= 4 || land(coord) {
+			return Sample{float32(math.NaN()), uint8(0)}
+		}
+		return Sample{float32(coord[0]), uint8(coord[1])}
+	}
+
+	for _, opts := range [][]LayerOption{{WithSparseTiles()}, {WithSparseTiles(), WithPlanar(), WithCompression(CompressionFlate)}} {
+		file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize8))
+		if err != nil {
+			t.Fatal(err)
+		}
+		layer := NewLayer("ocean", dims, channels, opts...)
+		writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+		err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+			for writer.Next() {
+				writer.SetSample(ocean(writer.Coordinate()))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// only the first tile holds anything but fill; the ice channel of the separated layer is zero, its fill
+		written := file.Layers[0]
+		expected := map[bool]int{false: 1, true: 2}[written.Separated]
+		if stored := storedTiles(written); stored != expected {
+			t.Errorf("expected %d stored tiles, got %d of %d: %v", expected, stored, written.DiskTiles(), written.TileBytes)
+		}
+
+		read, err := FromBytes(file.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fill := read.Layers[0].Channels[0].FillValue; fill == nil || !math.IsNaN(float64(fill.(float32))) {
+			t.Errorf("expected the fill value to be read back as NaN, got %v", fill)
+		}
+		values, err := read.Layer(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples, err := read.Layers[0].ReadRegion(read.Stream(), read.Header, FullRegion(dims))
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := 0
+		for coord := range FullRegion(dims).Coordinates() {
+			want := ocean(coord)
+			sample, err := SampleAt(values, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, got := range []Sample{sample, samples[i]} {
+				if sst := got[0].(float32); (math.IsNaN(float64(sst)) != math.IsNaN(float64(want[0].(float32)))) ||
+					(!math.IsNaN(float64(sst)) && sst != want[0]) || got[1] != want[1] {
+					t.Fatalf("sample %v read as %v, expected %v", coord, got, want)
+				}
+			}
+			i++
+		}
+
+		strict, err := read.Layer(0, WithAbsentTileError())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var notFound ErrTileNotFound
+		if _, err := SampleAt(strict, SampleCoordinate{0, 7}); !errors.As(err, &notFound) {
+			t.Errorf("expected ErrTileNotFound with an explicit error policy, got %v", err)
+		}
+	}
+}
+
+func TestSparseTilesWithoutFillValues(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("zeros", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}}, WithSparseTiles())
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{uint8(0)})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored := storedTiles(file.Layers[0]); stored != file.Layers[0].DiskTiles() {
+		t.Errorf("expected every tile of a layer without fill values to be written, only %d were", stored)
+	}
+}
+
+func storedTiles(layer Layer) int {
+	stored := 0
+	for _, size := range layer.TileBytes {
+		if size != 0 {
+			stored++
+		}
+	}
+	return stored
+}
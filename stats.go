@@ -0,0 +1,65 @@
+package gopixi
+
+import (
+	"io"
+	"math"
+)
+
+// The moments of the valid values of a channel: those that are neither NaN nor the fill value of the
+// channel. Booleans count as 0 or 1, and string channels have no moments. A ValidCount of zero means the
+// moments are not known.
+type ChannelMoments struct {
+	ValidCount int64   // The number of valid values.
+	Mean       float64 // The mean of the valid values.
+	StdDev     float64 // The population standard deviation of the valid values.
+}
+
+// Adds the value to the moments, by Welford's method.
+func (m *ChannelMoments) add(value float64) {
+	n := float64(m.ValidCount)
+	m2 := m.StdDev * m.StdDev * n
+	m.ValidCount++
+	delta := value - m.Mean
+	m.Mean += delta / float64(m.ValidCount)
+	m2 += delta * (value - m.Mean)
+	m.StdDev = math.Sqrt(max(m2, 0) / float64(m.ValidCount))
+}
+
+// The statistics of the values of a channel, as returned by Channel.Stats.
+type ChannelStats struct {
+	Min        any     // The least value, as Channel.Min.
+	Max        any     // The greatest value, as Channel.Max.
+	Mean       float64 // The mean of the valid values.
+	StdDev     float64 // The population standard deviation of the valid values.
+	ValidCount int64   // The number of values that are neither NaN nor the fill value of the channel.
+}
+
+// The statistics of the channel, if its moments are known (see Channel.Moments).
+func (c Channel) Stats() (ChannelStats, bool) {
+	if c.Moments.ValidCount == 0 {
+		return ChannelStats{}, false
+	}
+	return ChannelStats{Min: c.Min, Max: c.Max, Mean: c.Moments.Mean, StdDev: c.Moments.StdDev, ValidCount: c.Moments.ValidCount}, true
+}
+
+// Recomputes the Min/Max statistics and moments of every channel of the layer with the given index from its
+// tiles and rewrites the layer header with them, clearing any stored histograms. Returns an ErrDataIntegrity
+// for the first tile failing its checksum, leaving the header as it was.
+func (p *Pixi) RecomputeStats(rw io.ReadWriteSeeker, layerIndex int) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "recompute statistics"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat("layer index out of range")
+	}
+	layer := p.Layers[layerIndex]
+	channels, mismatches, err := layer.tileStatistics(rw, p.Header)
+	if err != nil {
+		return err
+	}
+	if len(mismatches) > 0 {
+		return ErrDataIntegrity{TileIndex: mismatches[0], LayerName: layer.Name}
+	}
+	layer.Channels = channels
+	return p.UpdateLayerHeader(rw, layerIndex, layer)
+}
@@ -0,0 +1,124 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+// The count, mean, and population standard deviation of the values.
+func testMoments(values []float64) ChannelMoments {
+	sum, squares := 0.0, 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return ChannelMoments{ValidCount: int64(len(values)), Mean: mean, StdDev: math.Sqrt(squares / float64(len(values)))}
+}
+
+func sameMoments(a ChannelMoments, b ChannelMoments) bool {
+	return a.ValidCount == b.ValidCount && math.Abs(a.Mean-b.Mean) < 1e-9 && math.Abs(a.StdDev-b.StdDev) < 1e-9
+}
+
+func TestChannelStats(t *testing.T) {
+	buf, _ := newEditTestPixi(t)
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	products, flags := []float64{}, []float64{}
+	for coord := range read.Layers[0].Dimensions.SampleCoordinates() {
+		products = append(products, float64(coord[0]*coord[1]))
+		if coord[0]%2 == 0 {
+			flags = append(flags, 1)
+		} else {
+			flags = append(flags, 0)
+		}
+	}
+	for c, values := range [][]float64{products, flags} {
+		stats, ok := read.Layers[0].Channels[c].Stats()
+		if !ok || !sameMoments(ChannelMoments{stats.ValidCount, stats.Mean, stats.StdDev}, testMoments(values)) {
+			t.Errorf("channel %d: expected moments %+v, got %+v", c, testMoments(values), stats)
+		}
+	}
+	if stats, _ := read.Layers[0].Channels[0].Stats(); stats.Min != uint16(0) || stats.Max != uint16(18) {
+		t.Errorf("expected the statistics to range from 0 to 18, got %v to %v", stats.Min, stats.Max)
+	}
+
+	// NaN and fill values are not valid
+	values := []float32{-1, 2, float32(math.NaN()), 4, -1, 6}
+	layers := []Layer{NewLayer("series", DimensionSet{{Name: "t", Size: 6, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelFloat32, FillValue: float32(-1)}})}
+	pixi := writeTestPixi(t, buffer.NewBuffer(10), NewHeader(binary.BigEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{values[coord[0]]}
+	})
+	if moments := pixi.Layers[0].Channels[0].Moments; !sameMoments(moments, testMoments([]float64{2, 4, 6})) {
+		t.Errorf("expected the moments of the valid values, got %+v", moments)
+	}
+
+	// string channels have none
+	if _, ok := newStringTestFile(t).Layers[0].Channels[0].Stats(); ok {
+		t.Error("expected a string channel to have no moments")
+	}
+}
+
+func TestRecomputeStats(t *testing.T) {
+	buf, pixi := newEditTestPixi(t)
+	if err := pixi.UpdateTiles(buf, 1, map[int][]byte{0: {5, 6, 7}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pixi.Layers[1].Channels[0].Stats(); ok {
+		t.Error("expected updating tiles to clear the moments")
+	}
+	if err := pixi.RecomputeStats(buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPixi(buffer.NewBufferFrom(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := read.Layers[1].Channels[0]
+	if stats, ok := channel.Stats(); !ok || stats.Min != int8(5) || stats.Max != int8(7) || !sameMoments(channel.Moments, testMoments([]float64{5, 6, 7})) {
+		t.Errorf("expected recomputed moments of 5, 6, and 7, got %+v", stats)
+	}
+
+	if err := pixi.RecomputeStats(buf, 2); err == nil {
+		t.Error("expected an out of range layer index to fail")
+	}
+	pixi.ReadOnly = true
+	if err := pixi.RecomputeStats(buf, 0); !errors.As(err, new(ErrReadOnly)) {
+		t.Errorf("expected recomputing statistics of a read-only file to fail, got %v", err)
+	}
+}
+
+func TestChannelMomentsHeader(t *testing.T) {
+	channel := Channel{Name: "v", Type: ChannelInt16, Min: int16(-3), Max: int16(9), Moments: ChannelMoments{ValidCount: 12, Mean: 2.5, StdDev: 0.75}}
+	for _, version := range []int{Version, VersionExtensions - 1} {
+		header := NewHeader(binary.LittleEndian, OffsetSize4)
+		header.Version = version
+		buf := buffer.NewBuffer(10)
+		if err := channel.Write(buf, header); err != nil {
+			t.Fatal(err)
+		}
+		if len(buf.Bytes()) != channel.HeaderSize(header) {
+			t.Errorf("version %d: expected %d header bytes, wrote %d", version, channel.HeaderSize(header), len(buf.Bytes()))
+		}
+		var read Channel
+		if err := read.Read(buffer.NewBufferFrom(buf.Bytes()), header); err != nil {
+			t.Fatal(err)
+		}
+		expected := channel
+		if version < VersionExtensions {
+			expected.Moments = ChannelMoments{}
+		}
+		if !reflect.DeepEqual(read, expected) {
+			t.Errorf("version %d: expected %+v, got %+v", version, expected, read)
+		}
+	}
+}
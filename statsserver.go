@@ -0,0 +1,331 @@
+package gopixi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The default number of histogram bins of a StatsServer summary, when the request does not give one.
+const DefaultSummaryBins int = 16
+
+// The largest number of histogram bins a StatsServer summary can be requested with.
+const MaxSummaryBins int = 4096
+
+// The summary of a region of a layer returned by a StatsServer, as JSON.
+type RegionSummary struct {
+	Layer    string           `json:"layer"`
+	Start    SampleCoordinate `json:"start"`
+	End      SampleCoordinate `json:"end"` // exclusive
+	Channels []ChannelSummary `json:"channels"`
+}
+
+// The statistics of the values of one channel within a region. Missing values (NaN, the fill value of the
+// channel, or samples of tiles that were never written) are counted but otherwise left out, and Min, Max,
+// and Mean are absent if every value is missing.
+type ChannelSummary struct {
+	Name      string            `json:"name"`
+	Count     int64             `json:"count"`
+	Missing   int64             `json:"missing"`
+	Min       *float64          `json:"min,omitempty"`
+	Max       *float64          `json:"max,omitempty"`
+	Mean      *float64          `json:"mean,omitempty"`
+	Histogram *SummaryHistogram `json:"histogram,omitempty"`
+}
+
+// A histogram of the values of a channel, with len(Counts) bins of equal width dividing the range from Min
+// to Max. Values outside of the range are counted as Below or Above it; the maximum falls in the last bin.
+type SummaryHistogram struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Counts []int64 `json:"counts"`
+	Below  int64   `json:"below"`
+	Above  int64   `json:"above"`
+}
+
+// Serves summaries of regions of the layers of a Pixi file over HTTP as JSON (see RegionSummary), requested
+// with the query parameters "layer", "channel" (repeated for several), "start" and "end" (comma-separated
+// sample coordinates, the end exclusive), and "bins", "min", and "max" for the histogram. Invalid requests
+// are reported as 400 Bad Request, and unknown layers or channels as 404 Not Found.
+type StatsServer struct {
+	pixi      *Pixi
+	cacheSize int
+
+	lock   sync.Mutex
+	source io.ReadSeeker
+	layers map[int]TileAccessLayer
+}
+
+var _ http.Handler = (*StatsServer)(nil)
+
+// Creates a server summarizing the layers of the given file, read from source, keeping up to cacheSize
+// tiles of each layer in memory.
+func NewStatsServer(source io.ReadSeeker, pixi *Pixi, cacheSize int) *StatsServer {
+	return &StatsServer{pixi: pixi, source: source, cacheSize: max(cacheSize, 1), layers: map[int]TileAccessLayer{}}
+}
+
+func (s *StatsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	layerIndex, err := strconv.Atoi(query.Get("layer"))
+	if err != nil || layerIndex < 0 || layerIndex >= len(s.pixi.Layers) {
+		http.Error(w, "invalid or missing layer index", http.StatusNotFound)
+		return
+	}
+	layer := s.pixi.Layers[layerIndex]
+	channels := []int{}
+	for _, name := range query["channel"] {
+		c := layer.Channels.Index(name)
+		if c < 0 {
+			http.Error(w, fmt.Sprintf("unknown channel '%s'", name), http.StatusNotFound)
+			return
+		}
+		channels = append(channels, c)
+	}
+	if len(channels) == 0 {
+		for c := range layer.Channels {
+			channels = append(channels, c)
+		}
+	}
+	region, err := parseSummaryRegion(query.Get("start"), query.Get("end"), layer.Dimensions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bins := DefaultSummaryBins
+	if query.Has("bins") {
+		if bins, err = strconv.Atoi(query.Get("bins")); err != nil || bins < 0 || bins > MaxSummaryBins {
+			http.Error(w, fmt.Sprintf("bins must be an integer from 0 to %d", MaxSummaryBins), http.StatusBadRequest)
+			return
+		}
+	}
+	var histogramRange []float64
+	if query.Has("min") || query.Has("max") {
+		lo, loErr := strconv.ParseFloat(query.Get("min"), 64)
+		hi, hiErr := strconv.ParseFloat(query.Get("max"), 64)
+		if loErr != nil || hiErr != nil || !(lo < hi) {
+			http.Error(w, "min and max must both be given as numbers, with min less than max", http.StatusBadRequest)
+			return
+		}
+		histogramRange = []float64{lo, hi}
+	}
+
+	summary, err := s.summarize(layerIndex, channels, region, bins, histogramRange)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err := json.Marshal(summary)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == http.MethodGet {
+		w.Write(body)
+	}
+}
+
+// Parses a region of the dimensions from comma-separated start and end coordinates, either of which may be
+// empty to start at the first sample or end after the last.
+func parseSummaryRegion(start string, end string, set DimensionSet) (Region, error) {
+	region := FullRegion(set)
+	for _, bound := range []struct {
+		text  string
+		coord SampleCoordinate
+	}{{start, region.Start}, {end, region.End}} {
+		if bound.text == "" {
+			continue
+		}
+		fields := strings.Split(bound.text, ",")
+		if len(fields) != len(set) {
+			return Region{}, ErrFormat(fmt.Sprintf("region coordinate '%s' has %d values, layer has %d dimensions", bound.text, len(fields), len(set)))
+		}
+		for d, field := range fields {
+			v, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return Region{}, ErrFormat(fmt.Sprintf("invalid region coordinate '%s'", bound.text))
+			}
+			bound.coord[d] = v
+		}
+	}
+	if err := region.Validate(set); err != nil {
+		return Region{}, err
+	}
+	return region, nil
+}
+
+// Summarizes the channels of the layer within the region.
+func (s *StatsServer) summarize(layerIndex int, channels []int, region Region, bins int, histogramRange []float64) (RegionSummary, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	access, ok := s.layers[layerIndex]
+	if !ok {
+		var err error
+		if access, err = s.pixi.ReadLayer(s.source, layerIndex, s.cacheSize); err != nil {
+			return RegionSummary{}, err
+		}
+		s.layers[layerIndex] = access
+	}
+
+	layer := access.Layer()
+	s.pixi.AccessStats.recordRegionTiles(layerIndex, layer, channels, region)
+	summary := RegionSummary{Layer: layer.Name, Start: region.Start, End: region.End, Channels: make([]ChannelSummary, len(channels))}
+	stats := make([]ZoneStatistics, len(channels))
+	histograms := make([]*SummaryHistogram, len(channels))
+	for i, c := range channels {
+		summary.Channels[i].Name = layer.Channels[c].Name
+		if bins == 0 {
+			continue
+		}
+		if histogramRange != nil {
+			histograms[i] = newSummaryHistogram(histogramRange[0], histogramRange[1], bins)
+		} else if lo, hi, ok := layer.Channels[c].statisticsRange(); ok {
+			histograms[i] = newSummaryHistogram(lo, hi, bins)
+		}
+	}
+
+	// the histograms without a range are binned in a second pass, over the range found by the first
+	unranged := slices.ContainsFunc(histograms, func(h *SummaryHistogram) bool { return h == nil }) && bins > 0
+	err := forEachRegionValue(access, channels, region, func(i int, value float64, missing bool) {
+		if missing {
+			summary.Channels[i].Missing++
+			return
+		}
+		stats[i].add(value)
+		histograms[i].add(value)
+	})
+	if err != nil {
+		return RegionSummary{}, err
+	}
+	if unranged {
+		second := make([]*SummaryHistogram, len(channels))
+		for i := range channels {
+			if histograms[i] == nil && stats[i].Count > 0 {
+				second[i] = newSummaryHistogram(stats[i].Min, stats[i].Max, bins)
+				histograms[i] = second[i]
+			}
+		}
+		err := forEachRegionValue(access, channels, region, func(i int, value float64, missing bool) {
+			if !missing {
+				second[i].add(value)
+			}
+		})
+		if err != nil {
+			return RegionSummary{}, err
+		}
+	}
+
+	for i := range channels {
+		channel := &summary.Channels[i]
+		channel.Count = stats[i].Count
+		channel.Histogram = histograms[i]
+		if stats[i].Count > 0 {
+			lo, hi, mean := stats[i].Min, stats[i].Max, stats[i].Mean()
+			channel.Min, channel.Max, channel.Mean = &lo, &hi, &mean
+		}
+	}
+	return summary, nil
+}
+
+// Calls the function with the value of each of the channels (by their position in channels) for every
+// sample of the region, reading each tile intersecting the region once. Values are missing if they are
+// NaN, the fill value of their channel, or samples of tiles that were never written.
+func forEachRegionValue(access TileAccessLayer, channels []int, region Region, f func(i int, value float64, missing bool)) error {
+	layer := access.Layer()
+	fills := make([]*float64, len(channels))
+	for i, c := range channels {
+		if fill, ok := layer.Channels[c].Type.ToFloat64(layer.Channels[c].FillValue); ok && layer.Channels[c].FillValue != nil {
+			fills[i] = &fill
+		}
+	}
+	tiles := region.stridedTiles(layer.Dimensions, nil)
+	if slices.ContainsFunc(tiles, func(t []int) bool { return len(t) == 0 }) {
+		return nil
+	}
+	position := make([]int, len(tiles)) // of the tile within the tiles of each dimension
+	tile := TileCoordinate{Tile: make([]int, len(tiles)), InTile: make([]int, len(tiles))}
+	for {
+		for d := range tiles {
+			tile.Tile[d] = tiles[d][position[d]]
+		}
+		coords := []SampleCoordinate{}
+		index := tile.ToTileSelector(layer.Dimensions).Tile
+		layer.forEachTileSample(index, func(inTile int, coord SampleCoordinate) {
+			if region.Contains(coord) {
+				coords = append(coords, slices.Clone(coord))
+			}
+		})
+		for i, c := range channels {
+			values, err := ReadSamples[float64](access, layer.Channels[c].Name, coords...)
+			var notFound ErrTileNotFound
+			if errors.As(err, &notFound) {
+				for range coords {
+					f(i, math.NaN(), true)
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			for _, v := range values {
+				f(i, v, math.IsNaN(v) || (fills[i] != nil && v == *fills[i]))
+			}
+		}
+
+		// advance to the next tile intersecting the region, the first dimension the most frequently
+		d := 0
+		for ; d < len(position); d++ {
+			position[d]++
+			if position[d] < len(tiles[d]) {
+				break
+			}
+			position[d] = 0
+		}
+		if d == len(position) {
+			return nil
+		}
+	}
+}
+
+// The range of the Min/Max statistics of the channel, if it has both and they span a range.
+func (c Channel) statisticsRange() (float64, float64, bool) {
+	lo, loOk := c.Type.ToFloat64(c.Min)
+	hi, hiOk := c.Type.ToFloat64(c.Max)
+	return lo, hi, c.Min != nil && c.Max != nil && loOk && hiOk && lo < hi
+}
+
+func newSummaryHistogram(lo float64, hi float64, bins int) *SummaryHistogram {
+	return &SummaryHistogram{Min: lo, Max: hi, Counts: make([]int64, bins)}
+}
+
+// Counts the value in its bin. Does nothing for a nil histogram.
+func (h *SummaryHistogram) add(value float64) {
+	if h == nil {
+		return
+	}
+	bins := len(h.Counts)
+	switch {
+	case value < h.Min:
+		h.Below++
+	case value > h.Max:
+		h.Above++
+	case h.Max == h.Min:
+		h.Counts[0]++
+	default:
+		bin := int((value - h.Min) / (h.Max - h.Min) * float64(bins))
+		h.Counts[min(bin, bins-1)]++
+	}
+}
@@ -0,0 +1,125 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func fetchSummary(t *testing.T, url string) (RegionSummary, int) {
+	t.Helper()
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var summary RegionSummary
+	if response.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(response.Body).Decode(&summary); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return summary, response.StatusCode
+}
+
+func TestStatsServer(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.LittleEndian, OffsetSize4)
+	layers := []Layer{NewLayer("grid", DimensionSet{{Name: "x", Size: 10, TileSize: 4}, {Name: "y", Size: 6, TileSize: 4}},
+		ChannelSet{{Name: "v", Type: ChannelFloat32}, {Name: "n", Type: ChannelUint8, FillValue: uint8(0)}})}
+	value := func(coord SampleCoordinate) (float64, uint8) {
+		if coord[0] == 3 && coord[1] == 2 {
+			return math.NaN(), 0
+		}
+		return float64(coord[0] + 10*coord[1]), uint8(coord[0] * coord[1] % 5)
+	}
+	written := writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		v, n := value(coord)
+		return Sample{float32(v), n}
+	})
+	// leave the float channel without statistics, so that its histogram range is found from the values
+	written.Layers[0].Channels[0].Min, written.Layers[0].Channels[0].Max = nil, nil
+
+	server := httptest.NewServer(NewStatsServer(buffer.NewBufferFrom(buf.Bytes()), written, 4))
+	defer server.Close()
+
+	region := Region{Start: SampleCoordinate{1, 1}, End: SampleCoordinate{9, 5}}
+	summary, status := fetchSummary(t, server.URL+"?layer=0&start=1,1&end=9,5&bins=4")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", status)
+	}
+	if summary.Layer != "grid" || !slices.Equal(summary.Start, region.Start) || !slices.Equal(summary.End, region.End) || len(summary.Channels) != 2 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	expected := make([]ZoneStatistics, 2)
+	missing := make([]int64, 2)
+	for coord := range region.Coordinates() {
+		v, n := value(coord)
+		if math.IsNaN(v) {
+			missing[0]++
+		} else {
+			expected[0].add(v)
+		}
+		if n == 0 {
+			missing[1]++
+		} else {
+			expected[1].add(float64(n))
+		}
+	}
+	for c, channel := range summary.Channels {
+		if channel.Count != expected[c].Count || channel.Missing != missing[c] || *channel.Min != expected[c].Min ||
+			*channel.Max != expected[c].Max || math.Abs(*channel.Mean-expected[c].Mean()) > 1e-9 {
+			t.Errorf("channel %s: expected %+v with %d missing, got %+v", channel.Name, expected[c], missing[c], channel)
+		}
+		histogram := channel.Histogram
+		if histogram == nil || len(histogram.Counts) != 4 {
+			t.Fatalf("channel %s: expected a histogram of 4 bins, got %+v", channel.Name, histogram)
+		}
+		total := int64(0)
+		for _, count := range histogram.Counts {
+			total += count
+		}
+		if total+histogram.Below+histogram.Above != channel.Count {
+			t.Errorf("channel %s: expected the histogram to count every value, got %+v", channel.Name, histogram)
+		}
+	}
+	if h := summary.Channels[0].Histogram; h.Min != 11 || h.Max != 48 || h.Below != 0 || h.Above != 0 {
+		t.Errorf("expected the float histogram to range over the values, got %+v", h)
+	}
+	if h := summary.Channels[1].Histogram; h.Min != 0 || h.Max != 4 {
+		t.Errorf("expected the integer histogram to range over the channel statistics, got %+v", h)
+	}
+
+	// the maximum of the range falls in the last bin
+	summary, _ = fetchSummary(t, server.URL+"?layer=0&channel=v&bins=2&min=0&max=20")
+	if len(summary.Channels) != 1 || summary.Channels[0].Name != "v" || summary.Channels[0].Count != 59 {
+		t.Fatalf("expected the whole of channel v, got %+v", summary)
+	}
+	if h := summary.Channels[0].Histogram; h.Min != 0 || h.Max != 20 || !slices.Equal(h.Counts, []int64{10, 11}) || h.Above != 38 {
+		t.Errorf("expected the requested histogram range, got %+v", h)
+	}
+	summary, _ = fetchSummary(t, server.URL+"?layer=0&channel=n&bins=0")
+	if summary.Channels[0].Histogram != nil {
+		t.Errorf("expected no histogram for zero bins, got %+v", summary.Channels[0].Histogram)
+	}
+
+	for query, want := range map[string]int{
+		"?layer=1":                    http.StatusNotFound,
+		"?layer=0&channel=missing":    http.StatusNotFound,
+		"?layer=0&start=1":            http.StatusBadRequest,
+		"?layer=0&start=0,0&end=11,6": http.StatusBadRequest,
+		"?layer=0&bins=-1":            http.StatusBadRequest,
+		"?layer=0&min=5":              http.StatusBadRequest,
+	} {
+		if _, status := fetchSummary(t, server.URL+query); status != want {
+			t.Errorf("%s: expected status %d, got %d", query, want, status)
+		}
+	}
+}
@@ -7,21 +7,36 @@ import (
 	"strings"
 )
 
-// OpenFileOrHttp opens a file from a local path or an HTTP(S) URL. If the path is a URL,
-// it opens a buffered HTTP stream to reduce the number of individual reads of the file
-// from the network; otherwise, it opens a local file.
-func OpenFileOrHttp(path string) (io.ReadSeekCloser, error) {
+// OpenFileOrHttp opens a file from a local path, an HTTP(S) URL, or an s3://bucket/key URL (see OpenS3). If
+// the path is a URL, it opens a buffered HTTP stream to reduce the number of individual reads of the file
+// from the network; otherwise, it opens a local file. Files compressed as a whole with gzip or zstd are
+// transparently decompressed.
+func OpenFileOrHttp(path string, opts ...HttpOption) (io.ReadSeekCloser, error) {
 	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
 		pixiUrl, err := url.Parse(path)
 		if err != nil {
 			return nil, err
 		}
-		return OpenBufferedHttp(pixiUrl, nil)
+		stream, err := OpenBufferedHttp(pixiUrl, nil, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return unwrapStream(stream)
+	} else if strings.HasPrefix(path, "s3://") {
+		s3Url, err := url.Parse(path)
+		if err != nil {
+			return nil, err
+		}
+		stream, err := OpenS3(s3Url, nil, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return unwrapStream(stream)
 	} else {
 		file, err := os.Open(path)
 		if err != nil {
 			return nil, err
 		}
-		return file, nil
+		return unwrapStream(file)
 	}
 }
@@ -0,0 +1,99 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+)
+
+// Writes a Pixi file to a stream that cannot seek, such as standard output or a network socket, in a single
+// sequential pass, writing its index as a footer when the writer is closed. ReadPixi reads such files like
+// any other once complete.
+type StreamWriter struct {
+	deferred *DeferredWriter
+	stream   *sequentialStream
+}
+
+// Starts a new file by writing the given header, with no tags or layers referenced, to the stream.
+func NewStreamWriter(w io.Writer, header Header) (*StreamWriter, error) {
+	stream := &sequentialStream{w: w}
+	deferred, err := NewDeferredWriter(stream, header)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamWriter{deferred: deferred, stream: stream}, nil
+}
+
+// The metadata of everything written so far, as it will appear in the footer index.
+func (s *StreamWriter) Pixi() *Pixi {
+	return s.deferred.Pixi()
+}
+
+// The number of bytes written to the stream so far.
+func (s *StreamWriter) Offset() int64 {
+	return s.stream.offset
+}
+
+// Adds tags to be written with the footer index when the writer is closed.
+func (s *StreamWriter) AddTags(tags map[string]string) error {
+	return s.deferred.AddTags(tags)
+}
+
+// Adds attributes to be written with the footer index when the writer is closed, as for Pixi.AppendAttributes.
+func (s *StreamWriter) AddAttributes(attributes map[string]any) error {
+	return s.deferred.AddAttributes(attributes)
+}
+
+// Adds shared axes to be written with the footer index when the writer is closed, as for Pixi.AppendAxes. Layers appended
+// afterwards may refer to them with Axis.Ref.
+func (s *StreamWriter) AddAxes(axes map[string]*Axis) error {
+	return s.deferred.AddAxes(axes)
+}
+
+// Writes all of the tile data of a new layer using the generator, as for DeferredWriter.AppendLayer, with
+// the tiles written in order directly to the stream. The layer header is not written until the writer is
+// closed.
+func (s *StreamWriter) AppendLayer(layer Layer, generator func(writer IterativeLayerWriter) error) error {
+	return s.deferred.AppendLayer(layer, generator)
+}
+
+// Completes the file by finalizing it, writing the footer index of all tags and layers, including the
+// offsets of every tile, at the end of the stream. The underlying writer is not closed, as it may still be
+// in use by the caller (as with standard output). Closing again has no effect.
+func (s *StreamWriter) Close() error {
+	if s.deferred.closed {
+		return nil
+	}
+	err := s.deferred.Finalize()
+	if closeErr := s.deferred.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Adapts a writer that cannot seek for use where an io.WriteSeeker is needed for writing sequentially:
+// seeking is allowed solely to query the current position, which is always the end of the stream.
+type sequentialStream struct {
+	w      io.Writer
+	offset int64
+}
+
+func (s *sequentialStream) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.offset += int64(n)
+	return n, err
+}
+
+func (s *sequentialStream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		// nothing to do here
+	case io.SeekCurrent, io.SeekEnd:
+		offset += s.offset
+	default:
+		return s.offset, ErrUnsupported(fmt.Sprintf("seek with whence %d", whence))
+	}
+	if offset != s.offset {
+		return s.offset, ErrUnsupported("streamed files only support sequential writes")
+	}
+	return offset, nil
+}
@@ -0,0 +1,96 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestStreamWriter(t *testing.T) {
+	var out bytes.Buffer
+	// hide every method of the buffer other than Write, as with a pipe
+	writer, err := NewStreamWriter(struct{ io.Writer }{&out}, NewHeader(binary.BigEndian, OffsetSize8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := []Layer{
+		NewLayer("plain", DimensionSet{{Name: "x", Size: 7, TileSize: 3}}, ChannelSet{{Name: "v", Type: ChannelUint16}}),
+		NewLayer("packed", DimensionSet{{Name: "x", Size: 5, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}},
+			ChannelSet{{Name: "a", Type: ChannelFloat32}, {Name: "b", Type: ChannelBool}}, WithPlanar(), WithCompression(CompressionFlate)),
+	}
+	for _, layer := range layers {
+		err = writer.AppendLayer(layer, func(writer IterativeLayerWriter) error {
+			for writer.Next() {
+				coord := writer.Coordinate()
+				if len(coord) == 1 {
+					writer.SetSample(Sample{uint16(coord[0] * 3)})
+				} else {
+					writer.SetSample(Sample{float32(coord[0]) + 0.5*float32(coord[1]), coord[0] == coord[1]})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.AddTags(map[string]string{"source": "stream"}); err != nil {
+		t.Fatal(err)
+	}
+	if writer.Offset() != int64(out.Len()) {
+		t.Errorf("expected offset %d, got %d", out.Len(), writer.Offset())
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var roErr ErrReadOnly
+	if err := writer.AddTags(map[string]string{"late": "tag"}); !errors.As(err, &roErr) {
+		t.Errorf("expected read-only error after close, got %v", err)
+	}
+
+	r := buffer.NewBufferFrom(out.Bytes())
+	read, err := ReadPixi(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.AllTags()["source"] != "stream" || len(read.Layers) != 2 {
+		t.Fatalf("unexpected file with tags %v and %d layers", read.AllTags(), len(read.Layers))
+	}
+	if err := read.VerifyDigest(r); err != nil {
+		t.Errorf("expected the streamed file to match its digest, got %v", err)
+	}
+	plain := NewFifoCacheReadLayer(r, read.Header, read.Layers[0], 2)
+	for x := range 7 {
+		if sample, err := SampleAt(plain, SampleCoordinate{x}); err != nil || sample[0] != uint16(x*3) {
+			t.Errorf("plain sample %d: got %v (%v)", x, sample, err)
+		}
+	}
+	packed := NewFifoCacheReadLayer(r, read.Header, read.Layers[1], 4)
+	for coord := range read.Layers[1].Dimensions.SampleCoordinates() {
+		sample, err := SampleAt(packed, coord)
+		if err != nil || sample[0] != float32(coord[0])+0.5*float32(coord[1]) || sample[1] != (coord[0] == coord[1]) {
+			t.Errorf("packed sample %v: got %v (%v)", coord, sample, err)
+		}
+	}
+}
+
+func TestSequentialStreamSeek(t *testing.T) {
+	stream := &sequentialStream{w: io.Discard}
+	if _, err := stream.Write(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if offset, err := stream.Seek(0, io.SeekEnd); err != nil || offset != 10 {
+		t.Errorf("expected the end of the stream at 10, got %d (%v)", offset, err)
+	}
+	if offset, err := stream.Seek(10, io.SeekStart); err != nil || offset != 10 {
+		t.Errorf("expected seeking to the current position to succeed, got %d (%v)", offset, err)
+	}
+	var unsupported ErrUnsupported
+	if _, err := stream.Seek(2, io.SeekStart); !errors.As(err, &unsupported) {
+		t.Errorf("expected seeking backwards to be unsupported, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package gopixi
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+)
+
+type strideOption struct {
+	strides []int
+}
+
+func (o strideOption) applyRead(opts *readOptions) {
+	opts.stride = o.strides
+}
+
+// Makes region reads (ReadRegion, ReadRange, and the plans of ExplainRead) return only every stride-th
+// sample along each dimension from the start of the region, given one stride per dimension of the layer.
+// Tiles holding none of the selected samples are never fetched.
+func WithStride(strides ...int) ReadOption {
+	return strideOption{strides: strides}
+}
+
+// Checks that there is a stride of at least one for every dimension of the set, if any strides are given.
+func validateStride(stride []int, set DimensionSet) error {
+	if stride == nil {
+		return nil
+	}
+	if len(stride) != len(set) {
+		return ErrFormat(fmt.Sprintf("read has %d strides but layer has %d dimensions", len(stride), len(set)))
+	}
+	for d, s := range stride {
+		if s < 1 {
+			return ErrFormat(fmt.Sprintf("stride %d of dimension %d must be at least one", s, d))
+		}
+	}
+	return nil
+}
+
+// The step along dimension d of a stride, which may be nil to step by one along every dimension.
+func strideStep(stride []int, d int) int {
+	if stride == nil {
+		return 1
+	}
+	return stride[d]
+}
+
+// The number of samples of the region taken every stride samples along each dimension, as by
+// StridedCoordinates. A nil stride counts every sample of the region, as Size does.
+func (r Region) StridedSize(stride []int) []int {
+	size := r.Size()
+	for d := range size {
+		step := strideStep(stride, d)
+		size[d] = (size[d] + step - 1) / step
+	}
+	return size
+}
+
+// Iterate over the sample coordinates of the region taken every stride samples along each dimension,
+// starting from the start of the region, in the same order as Coordinates. A nil stride iterates over
+// every sample of the region, as Coordinates does.
+func (r Region) StridedCoordinates(stride []int) iter.Seq[SampleCoordinate] {
+	return func(yield func(coord SampleCoordinate) bool) {
+		if len(r.Start) == 0 {
+			return
+		}
+		samples := 1
+		for _, s := range r.StridedSize(stride) {
+			samples *= s
+		}
+		coord := slices.Clone(r.Start)
+		for range samples {
+			if !yield(coord) {
+				return
+			}
+			for d := range coord {
+				coord[d] += strideStep(stride, d)
+				if coord[d] >= r.End[d] {
+					coord[d] = r.Start[d]
+				} else {
+					break
+				}
+			}
+		}
+	}
+}
+
+// The indices of the tiles along each dimension of the set that hold at least one sample of the region
+// taken every stride samples, in increasing order.
+func (r Region) stridedTiles(set DimensionSet, stride []int) [][]int {
+	tiles := make([][]int, len(set))
+	for d, dim := range set {
+		step := strideStep(stride, d)
+		for c := r.Start[d]; c < r.End[d]; {
+			tile := c / dim.TileSize
+			tiles[d] = append(tiles[d], tile)
+			// skip to the first sample of the stride beyond the tile
+			next := (tile + 1) * dim.TileSize
+			c += (next - c + step - 1) / step * step
+		}
+	}
+	return tiles
+}
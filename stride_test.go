@@ -0,0 +1,106 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+func TestStridedCoordinates(t *testing.T) {
+	region := Region{Start: SampleCoordinate{1, 2}, End: SampleCoordinate{8, 5}}
+	got := []SampleCoordinate{}
+	for coord := range region.StridedCoordinates([]int{3, 2}) {
+		got = append(got, slices.Clone(coord))
+	}
+	expected := []SampleCoordinate{{1, 2}, {4, 2}, {7, 2}, {1, 4}, {4, 4}, {7, 4}}
+	if !slices.EqualFunc(got, expected, slices.Equal) {
+		t.Errorf("expected strided coordinates %v, got %v", expected, got)
+	}
+	if size := region.StridedSize([]int{3, 2}); !slices.Equal(size, []int{3, 2}) {
+		t.Errorf("expected strided size [3 2], got %v", size)
+	}
+	if size := region.StridedSize(nil); !slices.Equal(size, region.Size()) {
+		t.Errorf("expected a nil stride to keep the size %v, got %v", region.Size(), size)
+	}
+}
+
+func TestExplainReadStrideSkipsTiles(t *testing.T) {
+	layer := NewLayer("grid", DimensionSet{{Name: "x", Size: 16, TileSize: 2}, {Name: "y", Size: 16, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	for i := range layer.TileBytes {
+		layer.TileBytes[i], layer.TileOffsets[i] = 4, int64(100+8*i)
+	}
+	header := NewHeader(binary.LittleEndian, OffsetSize4)
+	full, err := layer.ExplainRead(header, FullRegion(layer.Dimensions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := layer.ExplainRead(header, FullRegion(layer.Dimensions), WithStride(4, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Tiles) != len(full.Tiles)/4 || plan.Samples != 16 {
+		t.Errorf("expected a quarter of %d tiles and 16 samples, got %v", len(full.Tiles), plan)
+	}
+	for _, tile := range plan.Tiles {
+		coord := TileSelector{Tile: tile}.ToTileCoordinate(layer.Dimensions).Tile
+		if coord[0]%2 != 0 || coord[1]%2 != 0 {
+			t.Errorf("tile %v holds no sample of the stride", coord)
+		}
+	}
+
+	// strides smaller than the tiles still touch every tile they cross
+	plan, err = layer.ExplainRead(header, Region{Start: SampleCoordinate{1, 0}, End: SampleCoordinate{16, 1}}, WithStride(3, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(plan.Tiles, []int{0, 2, 3, 5, 6}) {
+		t.Errorf("expected the tiles holding x of 1, 4, 7, 10, and 13, got %v", plan.Tiles)
+	}
+
+	for _, bad := range [][]int{{1}, {1, 0}, {2, -1}} {
+		if _, err := layer.ExplainRead(header, FullRegion(layer.Dimensions), WithStride(bad...)); err == nil {
+			t.Errorf("expected error for stride %v", bad)
+		}
+	}
+}
+
+func TestReadStrided(t *testing.T) {
+	for _, opts := range [][]LayerOption{nil, {WithPlanar()}} {
+		file := writeRangeLayer(t, binary.LittleEndian, opts...)
+		layer := file.Layers[0]
+		values, err := file.Layer(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		region := Region{Start: SampleCoordinate{1, 0, 1}, End: SampleCoordinate{11, 7, 3}}
+		stride := []int{3, 2, 1}
+
+		samples, err := layer.ReadRegion(file.Stream(), file.Header, region, WithStride(stride...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffer, err := layer.ReadRange(file.Stream(), file.Header, region.Start, region.Size(), WithStride(stride...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sampleSize := layer.Channels.Size()
+		if len(samples) != 4*4*2 || len(buffer) != len(samples)*sampleSize {
+			t.Fatalf("expected 32 samples, got %d and %d bytes", len(samples), len(buffer))
+		}
+		i := 0
+		for coord := range region.StridedCoordinates(stride) {
+			want, err := SampleAt(values, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for c, channel := range layer.Channels {
+				ranged := channel.Value(buffer[i*sampleSize+layer.Channels.Offset(c):], file.Header.ByteOrder)
+				if samples[i][c] != want[c] || ranged != want[c] {
+					t.Fatalf("separated %t: sample %v channel %d read as %v and %v, expected %v",
+						layer.Separated, coord, c, samples[i][c], ranged, want[c])
+				}
+			}
+			i++
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// The size in bytes of the slot of a string channel in each sample: the offset of the string from the start of
+// the slot, then the length of the string, as unsigned 32-bit integers.
+const stringSlotSize int = 8
+
+// Whether any channel of the set is a string channel, whose tiles end in a string data block.
+func (set ChannelSet) HasStrings() bool {
+	for _, channel := range set {
+		if channel.Type.Base() == ChannelString {
+			return true
+		}
+	}
+	return false
+}
+
+// Zeroed sizes of the string data blocks of every disk tile of the layer, or nil if the layer has no string
+// channels, for a layer whose tiles are yet to be written.
+func (d Layer) emptyStringBytes() []int64 {
+	if !d.Channels.HasStrings() {
+		return nil
+	}
+	return make([]int64, d.DiskTiles())
+}
+
+// Writes the string into the slot of a string channel at the given byte position of the decoded tile,
+// appending its bytes to the string data block at the end of the tile, and returns the grown tile. The empty
+// string takes no space in the block. Strings written over others leave the bytes of those in the block.
+func putString(tile []byte, slot int, value string, o binary.ByteOrder) []byte {
+	if value == "" {
+		clear(tile[slot : slot+stringSlotSize])
+		return tile
+	}
+	o.PutUint32(tile[slot:], uint32(len(tile)-slot))
+	o.PutUint32(tile[slot+4:], uint32(len(value)))
+	return append(tile, value...)
+}
+
+// Checks that the layer can store its string channels, if it has any. Run-length and progressive compression,
+// which encode tiles as runs of fixed-size samples, and halos, whose samples are copied from neighboring
+// tiles, are not supported with them.
+func (d Layer) checkStrings() error {
+	if !d.Channels.HasStrings() {
+		return nil
+	}
+	switch {
+	case d.Compression == CompressionRle8 || d.Compression == CompressionProgressive:
+		return ErrUnsupported(fmt.Sprintf("%v compression of layers with string channels", d.Compression))
+	case d.Dimensions.HasHalo():
+		return ErrUnsupported("halos of layers with string channels")
+	case len(d.StringBytes) != d.DiskTiles():
+		return ErrFormat("invalid StringBytes: must have an element for every tile of layers with string channels")
+	}
+	return nil
+}
+
+// Whether decoded data of the given size can be a disk tile of the layer: the size of the slots of its
+// samples, followed by a string data block of any size for layers with string channels.
+func (d Layer) validTileSize(tile int, size int) bool {
+	if d.Channels.HasStrings() {
+		return size >= d.slotsTileSize(tile)
+	}
+	return size == d.slotsTileSize(tile)
+}
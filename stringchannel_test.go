@@ -0,0 +1,165 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+// The label of the test sample at the coordinate, empty for every third sample.
+func stringTestLabel(coord SampleCoordinate) string {
+	if (coord[0]+coord[1])%3 == 0 {
+		return ""
+	}
+	return fmt.Sprintf("station %d-%d", coord[0], coord[1])
+}
+
+func newStringTestFile(t *testing.T, opts ...LayerOption) *MemoryFile {
+	t.Helper()
+	file, err := NewMemoryFile(NewHeader(binary.BigEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("stations", DimensionSet{{Name: "x", Size: 5, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}},
+		ChannelSet{{Name: "label", Type: ChannelString}, {Name: "height", Type: ChannelUint16}, {Name: "code", Type: ChannelString}}, opts...)
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			coord := writer.Coordinate()
+			if layer.Dimensions.ContainsCoordinate(coord) {
+				writer.SetSample(Sample{stringTestLabel(coord), uint16(coord[0] * 10), fmt.Sprint(coord[1])})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestStringChannelsRoundTrip(t *testing.T) {
+	for name, opts := range map[string][]LayerOption{
+		"contiguous": {WithCompression(CompressionFlate)},
+		"separated":  {WithPlanar(), WithCompression(CompressionLz4)},
+	} {
+		written := newStringTestFile(t, opts...)
+		file, err := FromBytes(written.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		stored := file.Layers[0]
+		if len(stored.StringBytes) != stored.DiskTiles() {
+			t.Fatalf("%s: expected string data block sizes for %d tiles, got %v", name, stored.DiskTiles(), stored.StringBytes)
+		}
+		if stored.StringBytes[0] == 0 {
+			t.Errorf("%s: expected the first tile to have a string data block", name)
+		}
+		if label := stored.Channels[0]; label.Min != "" || label.Max != "station 4-1" {
+			t.Errorf("%s: expected label range from empty to 'station 4-1', got %v to %v", name, label.Min, label.Max)
+		}
+
+		layer, err := file.Layer(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for coord := range stored.Dimensions.SampleCoordinates() {
+			expected := Sample{stringTestLabel(coord), uint16(coord[0] * 10), fmt.Sprint(coord[1])}
+			if sample, err := SampleAt(layer, coord); err != nil || !reflect.DeepEqual(sample, expected) {
+				t.Errorf("%s: sample %v: expected %v, got %v (%v)", name, coord, expected, sample, err)
+			}
+		}
+
+		// selecting channels repacks the strings of interleaved tiles
+		selected, err := file.Layer(0, WithChannels("code", "label"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		coord := SampleCoordinate{4, 1}
+		if sample, err := SampleAt(selected, coord); err != nil || !reflect.DeepEqual(sample, Sample{"1", "station 4-1"}) {
+			t.Errorf("%s: expected selected strings at %v, got %v (%v)", name, coord, sample, err)
+		}
+	}
+}
+
+func TestStringChannelsUnsupported(t *testing.T) {
+	channels := ChannelSet{{Name: "label", Type: ChannelString}}
+	dims := DimensionSet{{Name: "x", Size: 4, TileSize: 2}}
+
+	// strings cannot be set in place, as their tiles would need their string data blocks rebuilt
+	memory := NewMemoryLayer(buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize4), NewLayer("labels", dims, channels))
+	if err := SetSampleAt(memory, SampleCoordinate{1}, Sample{"a"}); !errors.As(err, new(ErrUnsupported)) {
+		t.Errorf("expected setting a string sample to be unsupported, got %v", err)
+	}
+	if err := SetChannelAt(memory, SampleCoordinate{1}, 0, "a"); !errors.As(err, new(ErrUnsupported)) {
+		t.Errorf("expected setting a string channel to be unsupported, got %v", err)
+	}
+
+	for name, layer := range map[string]Layer{
+		"run-length compression": NewLayer("labels", dims, channels, WithCompression(CompressionRle8)),
+		"halo":                   NewLayer("labels", dims, channels, WithHalo(1)),
+	} {
+		file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+		err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error { return nil })
+		if !errors.As(err, new(ErrUnsupported)) {
+			t.Errorf("expected a string layer with a %s to be unsupported, got %v", name, err)
+		}
+	}
+
+	old := NewHeader(binary.LittleEndian, OffsetSize4)
+	old.Version = VersionExtensions - 1
+	if err := channels[0].Write(buffer.NewBuffer(10), old); err == nil {
+		t.Errorf("expected string channels to require version %d", VersionExtensions)
+	}
+	if err := (Channel{Name: "label", Type: ChannelString, FillValue: "none"}).Write(buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize4)); err == nil {
+		t.Error("expected string channels with a fill value to be rejected")
+	}
+	if err := (&Axis{Type: ChannelString, Minimum: "a", Step: "b"}).Write(buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize4)); err == nil {
+		t.Error("expected string axes to be rejected")
+	}
+	file := newStringTestFile(t)
+	if err := file.WriteNetCDF(file.Stream(), buffer.NewBuffer(10)); err == nil {
+		t.Error("expected netCDF export of string channels to fail")
+	}
+}
+
+func TestStringChannelHeader(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		header := NewHeader(order, OffsetSize8)
+		channel := Channel{Name: "label", Type: ChannelString, Unit: "1", Min: "", Max: "zürich"}
+		buf := buffer.NewBuffer(10)
+		if err := channel.Write(buf, header); err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(buf.Bytes())) != int64(channel.HeaderSize(header)) {
+			t.Errorf("expected %d header bytes, wrote %d", channel.HeaderSize(header), len(buf.Bytes()))
+		}
+		var read Channel
+		if err := read.Read(buffer.NewBufferFrom(buf.Bytes()), header); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(read, channel) {
+			t.Errorf("expected %+v, got %+v", channel, read)
+		}
+	}
+
+	// strings are found from their slots, wherever in the tile they are
+	tile := make([]byte, 2*stringSlotSize)
+	tile = putString(tile, stringSlotSize, "second", binary.BigEndian)
+	tile = putString(tile, 0, "first", binary.BigEndian)
+	tile = putString(tile, stringSlotSize, "", binary.BigEndian)
+	if first := ChannelString.Value(tile, binary.BigEndian); first != "first" {
+		t.Errorf("expected 'first', got %v", first)
+	}
+	if second := ChannelString.Value(tile[stringSlotSize:], binary.BigEndian); second != "" {
+		t.Errorf("expected the overwritten slot to be empty, got %v", second)
+	}
+}
@@ -1,6 +1,11 @@
 package gopixi
 
-import "io"
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
 
 // Pixi files can contain zero or more tag sections, used for extraneous non-data related metadata
 // to help describe the file or indicate context of the file's ownership and lifespan. While the tags
@@ -8,15 +13,39 @@ import "io"
 // offsets pointing to further sections, allowing easier 'appending' of additional tags regardless of
 // where in the file previous tags are stored.
 type TagSection struct {
-	Tags          map[string]string // The tags for this section.
-	NextTagsStart int64             // A byte-index offset from the start of the file pointing to the next tag section. 0 if this is the last tag section.
+	Tags map[string]string // The tags for this section.
+	// Typed attributes of the file held in this section, as described for Layer.Attributes. Stored after the
+	// tags, and only in VersionExtensions or later.
+	Attributes map[string]any
+	// Shared axes of the file held in this section by identifier, to which the axes of dimensions may refer
+	// (see Axis.Ref). Stored after the attributes, and only in VersionExtensions or later.
+	Axes          map[string]*Axis
+	NextTagsStart int64 // A byte-index offset from the start of the file pointing to the next tag section. 0 if this is the last tag section.
 }
 
+const (
+	tagSectionAttributes uint32 = 1 << 31 // Set in the tag count of a section followed by attributes.
+	tagSectionAxes       uint32 = 1 << 30 // Set in the tag count of a section followed by shared axes.
+)
+
 // Writes the tag section header in binary to the given stream, according to the specification
 // in the Pixi header. This only writes the header (number of tags and offset to next section),
 // not the actual tags themselves.
 func (t TagSection) WriteHeader(w io.Writer, h Header) error {
-	err := h.Write(w, uint32(len(t.Tags)))
+	count := uint32(len(t.Tags))
+	if len(t.Attributes) > 0 {
+		if h.Version < VersionExtensions {
+			return ErrFormat(fmt.Sprintf("attributes require version %d or later", VersionExtensions))
+		}
+		count |= tagSectionAttributes
+	}
+	if len(t.Axes) > 0 {
+		if h.Version < VersionExtensions {
+			return ErrFormat(fmt.Sprintf("shared axes require version %d or later", VersionExtensions))
+		}
+		count |= tagSectionAxes
+	}
+	err := h.Write(w, count)
 	if err != nil {
 		return err
 	}
@@ -24,23 +53,33 @@ func (t TagSection) WriteHeader(w io.Writer, h Header) error {
 }
 
 // Writes the tag section in binary to the given stream, according to the specification
-// in the Pixi header.
+// in the Pixi header. Tags are written in order of their keys, so that a section is always written
+// identically.
 func (t TagSection) Write(w io.Writer, h Header) error {
 	// write number of tags, then each key-value pair for tags
 	err := t.WriteHeader(w, h)
 	if err != nil {
 		return err
 	}
-	for k, v := range t.Tags {
+	for _, k := range slices.Sorted(maps.Keys(t.Tags)) {
 		err = h.WriteFriendly(w, k)
 		if err != nil {
 			return err
 		}
-		err = h.WriteFriendly(w, v)
+		err = h.WriteFriendly(w, t.Tags[k])
 		if err != nil {
 			return err
 		}
 	}
+	if len(t.Attributes) > 0 {
+		err = writeAttributes(w, h, t.Attributes)
+		if err != nil {
+			return err
+		}
+	}
+	if len(t.Axes) > 0 {
+		return writeSharedAxes(w, h, t.Axes)
+	}
 	return nil
 }
 
@@ -56,6 +95,14 @@ func (t *TagSection) Read(r io.Reader, h Header) error {
 	if err != nil {
 		return err
 	}
+	hasAttributes := h.Version >= VersionExtensions && tagCount&tagSectionAttributes != 0
+	if hasAttributes {
+		tagCount &^= tagSectionAttributes
+	}
+	hasAxes := h.Version >= VersionExtensions && tagCount&tagSectionAxes != 0
+	if hasAxes {
+		tagCount &^= tagSectionAxes
+	}
 	t.Tags = make(map[string]string)
 	for range tagCount {
 		key, err := h.ReadFriendly(r)
@@ -68,5 +115,31 @@ func (t *TagSection) Read(r io.Reader, h Header) error {
 		}
 		t.Tags[key] = val
 	}
-	return nil
+	t.Attributes = nil
+	if hasAttributes {
+		t.Attributes, err = readAttributes(r, h)
+		if err != nil {
+			return err
+		}
+	}
+	t.Axes = nil
+	if hasAxes {
+		t.Axes, err = readSharedAxes(r, h)
+	}
+	return err
+}
+
+// Get the size in bytes of this tag section, including its header, as it is laid out and written to disk.
+func (t TagSection) DiskSize(h Header) int {
+	size := 4 + int(h.OffsetSize)
+	for k, v := range t.Tags {
+		size += h.FriendlySize(k) + h.FriendlySize(v)
+	}
+	if len(t.Attributes) > 0 {
+		size += attributesSize(h, t.Attributes)
+	}
+	if len(t.Axes) > 0 {
+		size += sharedAxesSize(h, t.Axes)
+	}
+	return size
 }
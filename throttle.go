@@ -0,0 +1,198 @@
+package gopixi
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limits the rate at which bytes are transferred and requests are made to a storage backend, admitting bursts
+// of up to one second of each rate. Share one between the streams of a backend (see WithThrottle and
+// NewThrottledStream) to limit them together. A Throttle is safe for concurrent use.
+type Throttle struct {
+	lock     sync.Mutex
+	bytes    tokenBucket
+	requests tokenBucket
+	delayed  time.Duration
+
+	now   func() time.Time                                 // the current time, for tests
+	sleep func(ctx context.Context, d time.Duration) error // waits for the duration, for tests
+}
+
+// A token bucket refilled at a constant rate, holding up to one second of the rate.
+type tokenBucket struct {
+	rate   float64 // tokens per second, or zero for no limit
+	tokens float64
+	last   time.Time
+}
+
+// Creates a throttle transferring no more than the given number of bytes per second, and making no more
+// than the given number of requests per second. Either limit may be zero (or negative) for no limit.
+func NewThrottle(bytesPerSecond float64, requestsPerSecond float64) *Throttle {
+	return &Throttle{
+		bytes:    tokenBucket{rate: max(bytesPerSecond, 0), tokens: max(bytesPerSecond, 0)},
+		requests: tokenBucket{rate: max(requestsPerSecond, 0), tokens: max(requestsPerSecond, 0)},
+		now:      time.Now,
+		sleep:    sleepContext,
+	}
+}
+
+// Takes the given number of tokens from the bucket at the given time, returning how long to wait until the
+// bucket has been refilled to cover them. Tokens are taken even if the bucket lacks them, so that later
+// takers wait for them too, and so that a transfer larger than the bucket can still proceed.
+func (b *tokenBucket) take(now time.Time, tokens float64) time.Duration {
+	if b.rate == 0 || tokens <= 0 {
+		return 0
+	}
+	if !b.last.IsZero() {
+		b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens -= tokens
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(-b.tokens / b.rate * float64(time.Second)))
+}
+
+// Waits until a single request transferring the given number of bytes is allowed by the throttle, or until
+// the context is done, returning its error. A nil throttle allows every request at once.
+func (t *Throttle) Wait(ctx context.Context, bytes int64) error {
+	return t.wait(ctx, 1, bytes)
+}
+
+// Waits until the given number of requests, transferring the given number of bytes in all, are allowed.
+func (t *Throttle) wait(ctx context.Context, requests int, bytes int64) error {
+	if t == nil {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	t.lock.Lock()
+	now := t.now()
+	delay := max(t.bytes.take(now, float64(bytes)), t.requests.take(now, float64(requests)))
+	t.delayed += delay
+	t.lock.Unlock()
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	return t.sleep(ctx, delay)
+}
+
+// The total time that requests have been made to wait by the throttle, as a measure of how much it has
+// slowed the work given it.
+func (t *Throttle) Delayed() time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.delayed
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type throttleOption struct {
+	throttle *Throttle
+}
+
+func (o throttleOption) applyHttp(opts *httpOptions) {
+	opts.throttle = o.throttle
+}
+
+// Limits the requests made to the remote resource, and the bytes transferred by them, with the given
+// throttle, which may be shared by the streams of every resource of the same backend.
+func WithThrottle(t *Throttle) HttpOption {
+	return throttleOption{throttle: t}
+}
+
+// A stream whose reads are limited by a Throttle, each Read counting as a request for as many bytes as it
+// asks for and each range of ReadRanges as a request of its own.
+type ThrottledStream struct {
+	stream   io.ReadSeeker
+	throttle *Throttle
+	ctx      context.Context
+}
+
+var _ RangeReader = (*ThrottledStream)(nil)
+var _ RemoteStream = (*ThrottledStream)(nil)
+
+// Wraps the stream so that its reads are limited by the given throttle. Reads wait for the throttle with
+// the background context, unless another is given with WithContext.
+func NewThrottledStream(stream io.ReadSeeker, t *Throttle) *ThrottledStream {
+	return &ThrottledStream{stream: stream, throttle: t, ctx: context.Background()}
+}
+
+// Returns a copy of the stream whose reads wait for the throttle with the given context, so that waiting
+// stops when the context is done.
+func (s *ThrottledStream) WithContext(ctx context.Context) *ThrottledStream {
+	return &ThrottledStream{stream: s.stream, throttle: s.throttle, ctx: ctx}
+}
+
+func (s *ThrottledStream) Read(p []byte) (int, error) {
+	if err := s.throttle.Wait(s.ctx, int64(len(p))); err != nil {
+		return 0, err
+	}
+	return s.stream.Read(p)
+}
+
+func (s *ThrottledStream) Seek(offset int64, whence int) (int64, error) {
+	return s.stream.Seek(offset, whence)
+}
+
+// Fetches several byte ranges of the stream, all at once if it is a RangeReader and otherwise by reading
+// each range in turn, once the throttle allows a request for each.
+func (s *ThrottledStream) ReadRanges(ranges []ByteRange) ([][]byte, error) {
+	if ranger, ok := s.stream.(RangeReader); ok {
+		total := int64(0)
+		for _, r := range ranges {
+			total += r.Length
+		}
+		if err := s.throttle.wait(s.ctx, len(ranges), total); err != nil {
+			return nil, err
+		}
+		return ranger.ReadRanges(ranges)
+	}
+	result := make([][]byte, len(ranges))
+	for i, r := range ranges {
+		if err := s.throttle.Wait(s.ctx, r.Length); err != nil {
+			return nil, err
+		}
+		result[i] = make([]byte, r.Length)
+		if _, err := s.stream.Seek(r.Offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(s.stream, result[i]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// The fetch latency of the wrapped stream, if it is a RemoteStream, and otherwise zero.
+func (s *ThrottledStream) FetchLatency() time.Duration {
+	if remote, ok := s.stream.(RemoteStream); ok {
+		return remote.FetchLatency()
+	}
+	return 0
+}
+
+// Closes the wrapped stream, if it can be closed.
+func (s *ThrottledStream) Close() error {
+	if closer, ok := s.stream.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package gopixi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+// Returns a throttle with the given limits whose clock only advances as it sleeps, recording every wait.
+func fakeClockThrottle(bytesPerSecond float64, requestsPerSecond float64) (*Throttle, *[]time.Duration) {
+	throttle := NewThrottle(bytesPerSecond, requestsPerSecond)
+	clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	waits := &[]time.Duration{}
+	lock := sync.Mutex{}
+	throttle.now = func() time.Time {
+		lock.Lock()
+		defer lock.Unlock()
+		return clock
+	}
+	throttle.sleep = func(ctx context.Context, d time.Duration) error {
+		lock.Lock()
+		defer lock.Unlock()
+		*waits = append(*waits, d)
+		clock = clock.Add(d)
+		return ctx.Err()
+	}
+	return throttle, waits
+}
+
+func TestThrottleRates(t *testing.T) {
+	throttle, waits := fakeClockThrottle(1000, 4)
+
+	// a second of bytes is allowed at once, after which transfers wait for the bytes to be refilled
+	for _, bytes := range []int64{600, 400, 500, 250} {
+		if err := throttle.Wait(context.Background(), bytes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*waits) != 2 || (*waits)[0] != 500*time.Millisecond || (*waits)[1] != 250*time.Millisecond {
+		t.Errorf("expected waits of 500ms and 250ms, got %v", *waits)
+	}
+	if throttle.Delayed() != 750*time.Millisecond {
+		t.Errorf("expected a total delay of 750ms, got %v", throttle.Delayed())
+	}
+
+	// requests without bytes are still limited by the request rate
+	requests, waits := fakeClockThrottle(0, 4)
+	for range 6 {
+		if err := requests.Wait(context.Background(), 1<<30); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*waits) != 2 || (*waits)[0] != 250*time.Millisecond || (*waits)[1] != 250*time.Millisecond {
+		t.Errorf("expected the fifth and sixth requests to wait 250ms each, got %v", *waits)
+	}
+
+	// no throttle, or no limits, never waits
+	var none *Throttle
+	if err := none.Wait(context.Background(), 1<<40); err != nil || none.Delayed() != 0 {
+		t.Errorf("expected a nil throttle to allow every request, got %v", err)
+	}
+	unlimited, waits := fakeClockThrottle(0, 0)
+	for range 100 {
+		unlimited.Wait(context.Background(), 1<<40)
+	}
+	if len(*waits) != 0 {
+		t.Errorf("expected an unlimited throttle never to wait, got %v", *waits)
+	}
+
+	// waits end when the context is done
+	slow := NewThrottle(1, 0)
+	slow.Wait(context.Background(), 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := slow.Wait(ctx, 3600); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to end with the context, got %v", err)
+	}
+}
+
+func TestThrottledStream(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 64, TileSize: 8}}, ChannelSet{{Name: "v", Type: ChannelUint16}})}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint16(coord[0] * 5)}
+	})
+	layer := written.Layers[0]
+	tileBytes := layer.TileRange(written.Header, 0).Length
+
+	throttle, waits := fakeClockThrottle(float64(2*tileBytes), 0)
+	stream := NewThrottledStream(bytes.NewReader(buf.Bytes()), throttle)
+	tiles, err := layer.ReadTiles(stream, written.Header, []int{0, 1, 2, 3, 4, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tiles[5][0] != byte(40*5) {
+		t.Errorf("expected the first sample of tile 5 to be %d, got %d", 40*5, tiles[5][0])
+	}
+	// two tiles a second: the four tiles after the first two wait half a second each
+	if len(*waits) != 4 || throttle.Delayed() != 2*time.Second {
+		t.Errorf("expected four waits for two seconds in all, got %v", *waits)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := stream.WithContext(cancelled).Read(make([]byte, 4)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a read with a cancelled context to fail, got %v", err)
+	}
+	if stream.FetchLatency() != 0 {
+		t.Errorf("expected a local stream to have no fetch latency")
+	}
+}
+
+func TestThrottleHttp(t *testing.T) {
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	server := &rangeServer{data: data}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	fileUrl, _ := url.Parse(httpServer.URL)
+
+	// both resources of the backend share the request budget
+	throttle, waits := fakeClockThrottle(0, 2)
+	first, err := OpenHttp(fileUrl, httpServer.Client(), WithThrottle(throttle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := OpenHttp(fileUrl, httpServer.Client(), WithThrottle(throttle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*waits) != 0 {
+		t.Errorf("expected the first two requests to proceed at once, got %v", *waits)
+	}
+	ranges, err := first.WithContext(context.Background()).ReadRanges([]ByteRange{{Offset: 10, Length: 5}, {Offset: 100, Length: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranges[1][0] != 100 {
+		t.Errorf("expected byte 100, got %d", ranges[1][0])
+	}
+	second.Seek(2000, io.SeekStart)
+	if _, err := io.ReadFull(second, make([]byte, 16)); err != nil {
+		t.Fatal(err)
+	}
+	if server.requests != 3 || len(*waits) != 3 || throttle.Delayed() != 1500*time.Millisecond {
+		t.Errorf("expected three throttled requests of 500ms each, got %d requests and waits %v", server.requests, *waits)
+	}
+}
@@ -0,0 +1,278 @@
+package gopixi
+
+import (
+	"container/heap"
+	"io"
+	"sync"
+	"time"
+)
+
+// A cache of decoded tiles limited to a budget of bytes, shared by every layer opened with ReadLayer from
+// the files it is attached to as their TileCache. The tiles estimated to be cheapest to read and decode again
+// are evicted first, so that tiles of remote or heavily compressed files are kept in preference to those of
+// local, uncompressed ones. A TileCache is safe for concurrent use.
+type TileCache struct {
+	budget int64
+
+	lock      sync.Mutex
+	tiles     map[tileCacheKey]*tileCacheEntry
+	queue     tileCacheQueue // lowest priority first
+	inflation float64
+	clock     uint64 // incremented on each use, to break ties between tiles of equal priority by recency
+	bytes     int64
+	hits      int64
+	misses    int64
+	evictions int64
+	saved     time.Duration
+}
+
+type tileCacheKey struct {
+	file  *Pixi
+	layer int
+	tile  int
+}
+
+type tileCacheEntry struct {
+	key      tileCacheKey
+	data     []byte
+	cost     time.Duration // the estimated time to read and decode the tile again
+	priority float64
+	used     uint64
+	index    int // in the queue
+}
+
+// A min-heap of cached tiles ordered by priority, then by how recently they were used.
+type tileCacheQueue []*tileCacheEntry
+
+var _ heap.Interface = (*tileCacheQueue)(nil)
+
+func (q tileCacheQueue) Len() int {
+	return len(q)
+}
+
+func (q tileCacheQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority < q[j].priority
+	}
+	return q[i].used < q[j].used
+}
+
+func (q tileCacheQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *tileCacheQueue) Push(x any) {
+	entry := x.(*tileCacheEntry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *tileCacheQueue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return entry
+}
+
+// Implemented by streams whose reads take long to start, such as those of HTTP servers and object stores,
+// for a TileCache to weigh the cost of reading their tiles again.
+type RemoteStream interface {
+	// The typical time from starting a read of the stream to receiving its first byte.
+	FetchLatency() time.Duration
+}
+
+// The latency of each read of an HttpReadSeeker (or BufferedHttpReadSeeker) assumed by a TileCache.
+const DefaultRemoteFetchLatency = 50 * time.Millisecond
+
+// The transfer rates assumed for local and remote streams, in nanoseconds per stored byte, and the time taken
+// to decode a tile with each compression, in nanoseconds per decoded byte. Only their ratios matter.
+const (
+	localFetchCost  = 0.5
+	remoteFetchCost = 10.0
+)
+
+var decodeCost = map[Compression]float64{
+	CompressionNone:        0,
+	CompressionFlate:       3,
+	CompressionLzwLsb:      4,
+	CompressionLzwMsb:      4,
+	CompressionRle8:        0.5,
+	CompressionZstd:        1,
+	CompressionLz4:         0.2,
+	CompressionProgressive: 4,
+}
+
+// Estimates the time it takes to read the tile of the layer from the stream and decode it.
+func tileReadCost(backing io.ReadSeeker, layer Layer, tile int) time.Duration {
+	fetch, latency := localFetchCost, time.Duration(0)
+	if remote, ok := backing.(RemoteStream); ok {
+		fetch, latency = remoteFetchCost, remote.FetchLatency()
+	}
+	nanoseconds := fetch*float64(layer.TileBytes[tile]) + decodeCost[layer.Compression]*float64(layer.DiskTileSize(tile))
+	return latency + time.Duration(nanoseconds)
+}
+
+// Counters describing the use of a TileCache since it was created or last reset, for tuning its budget.
+type TileCacheStats struct {
+	Hits      int64 // The number of tiles found in the cache.
+	Misses    int64 // The number of tiles that had to be read and decoded.
+	Evictions int64 // The number of tiles evicted to stay within the budget.
+	Tiles     int   // The number of tiles currently cached.
+	Bytes     int64 // The number of bytes of decoded tiles currently cached.
+	// The estimated time the tiles found in the cache would have taken to read and decode again.
+	Saved time.Duration
+}
+
+// The fraction of tiles requested that were found in the cache, or zero if none have been requested.
+func (s TileCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Creates a tile cache holding up to the given number of bytes of decoded tiles. Tiles larger than the
+// whole budget are never cached; a budget of zero caches nothing, but still counts misses.
+func NewTileCache(budget int64) *TileCache {
+	return &TileCache{budget: max(budget, 0), tiles: map[tileCacheKey]*tileCacheEntry{}}
+}
+
+// The number of bytes of decoded tiles the cache may hold.
+func (c *TileCache) Budget() int64 {
+	return c.budget
+}
+
+// The current counters of the cache.
+func (c *TileCache) Stats() TileCacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return TileCacheStats{Hits: c.hits, Misses: c.misses, Evictions: c.evictions, Tiles: len(c.tiles), Bytes: c.bytes, Saved: c.saved}
+}
+
+// Zeroes the hit, miss, and eviction counters and the saved time of the cache, keeping the tiles it holds.
+func (c *TileCache) ResetStats() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.hits, c.misses, c.evictions, c.saved = 0, 0, 0, 0
+}
+
+// Evicts every tile from the cache, as is needed after tiles it may hold are changed in the file other than
+// through the methods of its Pixi, since cached tiles are never checked against the file again.
+func (c *TileCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	clear(c.tiles)
+	c.queue = nil
+	c.inflation = 0
+	c.bytes = 0
+}
+
+// Evicts every cached tile of a layer of the file, as when its header is rewritten, since its tiles may have
+// been rewritten or laid out anew along with it. Does nothing for a nil cache.
+func (c *TileCache) evictLayer(file *Pixi, layer int) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	kept := c.queue[:0]
+	for _, entry := range c.queue {
+		if entry.key.file == file && entry.key.layer == layer {
+			delete(c.tiles, entry.key)
+			c.bytes -= int64(len(entry.data))
+			continue
+		}
+		entry.index = len(kept)
+		kept = append(kept, entry)
+	}
+	clear(c.queue[len(kept):])
+	c.queue = kept
+	heap.Init(&c.queue)
+}
+
+// Gives the tile the priority of a tile just used under GreedyDual-Size: its cost per byte plus the inflation,
+// which rises to the priority of each tile evicted.
+func (c *TileCache) touch(entry *tileCacheEntry) {
+	entry.priority = c.inflation + float64(entry.cost)/float64(max(len(entry.data), 1))
+	entry.used = c.clock
+	c.clock++
+}
+
+func (c *TileCache) get(key tileCacheKey) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if entry, ok := c.tiles[key]; ok {
+		c.hits++
+		c.saved += entry.cost
+		c.touch(entry)
+		heap.Fix(&c.queue, entry.index)
+		return entry.data, true
+	}
+	c.misses++
+	return nil, false
+}
+
+// Caches a tile that costs the given time to read and decode, evicting the tiles of lowest priority as
+// needed to stay within the budget.
+func (c *TileCache) put(key tileCacheKey, data []byte, cost time.Duration) {
+	size := int64(len(data))
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.tiles[key]; ok || size > c.budget {
+		return
+	}
+	for c.bytes+size > c.budget {
+		evicted := heap.Pop(&c.queue).(*tileCacheEntry)
+		delete(c.tiles, evicted.key)
+		c.bytes -= int64(len(evicted.data))
+		c.inflation = evicted.priority
+		c.evictions++
+	}
+	entry := &tileCacheEntry{key: key, data: data, cost: max(cost, 0)}
+	c.touch(entry)
+	heap.Push(&c.queue, entry)
+	c.tiles[key] = entry
+	c.bytes += size
+}
+
+// Reads the tiles of a layer through the tile cache of its file.
+type tileCacheReadLayer struct {
+	cache  *TileCache
+	file   *Pixi
+	index  int
+	layer  Layer
+	header Header
+	// Whether tiles are read without verifying their checksums, as set by WithVerifyChecksums.
+	skipChecksums bool
+
+	readLock sync.Mutex // serializes reads of the stream
+	backing  io.ReadSeeker
+}
+
+var _ TileAccessLayer = (*tileCacheReadLayer)(nil)
+
+func (t *tileCacheReadLayer) Layer() Layer {
+	return t.layer
+}
+
+func (t *tileCacheReadLayer) Header() Header {
+	return t.header
+}
+
+func (t *tileCacheReadLayer) Tile(tile int) ([]byte, error) {
+	key := tileCacheKey{file: t.file, layer: t.index, tile: tile}
+	if data, ok := t.cache.get(key); ok {
+		return data, nil
+	}
+	data := make([]byte, t.layer.DiskTileSize(tile))
+	t.readLock.Lock()
+	err := t.layer.readTile(t.backing, t.header, tile, data, !t.skipChecksums)
+	t.readLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	t.cache.put(key, data, tileReadCost(t.backing, t.layer, tile))
+	return data, nil
+}
@@ -0,0 +1,190 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTileCacheSharedAcrossReads(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 8, TileSize: 4}, {Name: "y", Size: 8, TileSize: 4}},
+		ChannelSet{{Name: "v", Type: ChannelInt32}}, WithCompression(CompressionFlate))
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			coord := writer.Coordinate()
+			writer.SetSample(Sample{int32(coord[0]*10 + coord[1])})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tileBytes := int64(layer.DiskTileSize(0))
+	cache := NewTileCache(3 * tileBytes)
+	file.TileCache = cache
+	readAll := func() {
+		t.Helper()
+		values, err := file.Layer(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for coord := range layer.Dimensions.SampleCoordinates() {
+			if sample, err := SampleAt(values, coord); err != nil || sample[0] != int32(coord[0]*10+coord[1]) {
+				t.Fatalf("sample %v: got %v (%v)", coord, sample, err)
+			}
+		}
+	}
+
+	// a second access layer finds the tiles cached by the first
+	for range 2 {
+		values, err := file.Layer(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := values.Tile(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Tiles != 1 || stats.Bytes != tileBytes || stats.HitRate() != 0.5 {
+		t.Errorf("unexpected stats %+v after reading a tile twice", stats)
+	}
+
+	// reading every tile of the layer exceeds the budget, evicting the least recently used tile
+	cache.ResetStats()
+	readAll()
+	stats := cache.Stats()
+	if stats.Misses != 3 || stats.Evictions != 1 || stats.Tiles != 3 || stats.Bytes != 3*tileBytes {
+		t.Errorf("unexpected stats %+v after reading every tile", stats)
+	}
+
+	cache.Clear()
+	if stats := cache.Stats(); stats.Tiles != 0 || stats.Bytes != 0 || stats.Misses != 3 {
+		t.Errorf("unexpected stats %+v after clearing", stats)
+	}
+
+	// tiles of different files are kept apart, and tiles larger than the budget are never cached
+	other, err := FromBytes(file.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.TileCache = cache
+	otherValues, err := other.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range []TileAccessLayer{values, otherValues} {
+		if _, err := layer.Tile(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := cache.Stats(); stats.Tiles != 2 {
+		t.Errorf("expected a cached tile for each file, got stats %+v", stats)
+	}
+	small := NewTileCache(tileBytes - 1)
+	file.TileCache = small
+	readAll()
+	if stats := small.Stats(); stats.Tiles != 0 || stats.Misses != int64(layer.DiskTiles()*layer.Dimensions.TileSamples()) {
+		t.Errorf("unexpected stats %+v for a cache smaller than a tile", stats)
+	}
+}
+
+func TestTileCacheCostAwareEviction(t *testing.T) {
+	cache := NewTileCache(300)
+	key := func(tile int) tileCacheKey { return tileCacheKey{layer: 0, tile: tile} }
+	cached := func(tile int) bool {
+		t.Helper()
+		_, ok := cache.tiles[key(tile)]
+		return ok
+	}
+
+	// an expensive tile (as from a remote zstd layer) outlasts cheaper ones read after it
+	cache.put(key(0), make([]byte, 100), 100*time.Microsecond)
+	cache.put(key(1), make([]byte, 100), 100*time.Nanosecond)
+	cache.put(key(2), make([]byte, 100), 100*time.Nanosecond)
+	cache.put(key(3), make([]byte, 100), 100*time.Nanosecond)
+	if !cached(0) || cached(1) || !cached(2) || !cached(3) {
+		t.Fatalf("expected the oldest cheap tile to be evicted, got %v", cache.tiles)
+	}
+
+	// among tiles of equal cost, the least recently used is evicted
+	if _, ok := cache.get(key(2)); !ok {
+		t.Fatal("expected tile 2 to be cached")
+	}
+	cache.put(key(4), make([]byte, 100), 100*time.Nanosecond)
+	if !cached(0) || !cached(2) || cached(3) || !cached(4) {
+		t.Fatalf("expected the least recently used cheap tile to be evicted, got %v", cache.tiles)
+	}
+
+	// small tiles cost more per byte than large tiles read in the same time
+	cache.Clear()
+	cache.put(key(0), make([]byte, 200), time.Microsecond)
+	cache.put(key(1), make([]byte, 50), time.Microsecond)
+	cache.put(key(2), make([]byte, 100), time.Microsecond)
+	if cached(0) || !cached(1) || !cached(2) {
+		t.Fatalf("expected the large tile to be evicted, got %v", cache.tiles)
+	}
+
+	// an expensive tile that is no longer used is eventually evicted as the inflation value rises
+	cache.Clear()
+	cache.ResetStats()
+	cache.put(key(0), make([]byte, 100), 10*time.Microsecond)
+	if _, ok := cache.get(key(0)); !ok {
+		t.Fatal("expected tile 0 to be cached")
+	}
+	tile := 1
+	for ; cached(0) && tile < 1000; tile++ {
+		cache.put(key(tile), make([]byte, 100), 100*time.Nanosecond)
+	}
+	if cached(0) || tile < 50 {
+		t.Errorf("expected the unused expensive tile to be evicted after many cheap ones, got %d tiles", tile)
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Saved != 10*time.Microsecond || stats.Tiles != 3 || stats.Bytes != 300 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	other := tileCacheKey{layer: 1, tile: 0}
+	cache.put(other, make([]byte, 100), time.Microsecond)
+	cache.evictLayer(nil, 0)
+	if _, ok := cache.tiles[other]; !ok || cache.Stats().Bytes != 100 || len(cache.queue) != 1 || cache.queue[0].index != 0 {
+		t.Errorf("expected only the tiles of layer 0 to be evicted, got %v", cache.tiles)
+	}
+}
+
+type testRemoteStream struct {
+	io.ReadSeeker
+}
+
+func (testRemoteStream) FetchLatency() time.Duration {
+	return time.Millisecond
+}
+
+func TestTileReadCost(t *testing.T) {
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelInt32}})
+	layer.TileBytes = []int64{16, 16}
+	local := tileReadCost(bytes.NewReader(nil), layer, 0)
+	remote := tileReadCost(testRemoteStream{bytes.NewReader(nil)}, layer, 0)
+	if local <= 0 || remote <= time.Millisecond {
+		t.Errorf("expected local and remote costs, got %v and %v", local, remote)
+	}
+	layer.Compression = CompressionZstd
+	layer.TileBytes = []int64{5, 16}
+	if compressed := tileReadCost(bytes.NewReader(nil), layer, 0); compressed <= local {
+		t.Errorf("expected decoding a compressed tile to cost more than reading a raw one, got %v and %v", compressed, local)
+	}
+	var stream io.ReadSeeker = &HttpReadSeeker{}
+	if _, ok := stream.(RemoteStream); !ok {
+		t.Error("expected HTTP streams to be remote")
+	}
+}
@@ -0,0 +1,85 @@
+package gopixi
+
+import (
+	"io"
+	"iter"
+)
+
+// Iterate over the decoded tiles of the layer in storage order, yielding the disk index of each tile along
+// with its data, reading each only when the iteration reaches it. Tiles that were never written are skipped
+// unless the layer has fill values or an absent tile policy is given. The returned function reports the
+// error that stopped the iteration, if any, once ranging is done.
+func (l Layer) Tiles(r io.ReadSeeker, h Header, opts ...ReadOption) (iter.Seq2[int, []byte], func() error) {
+	return l.tileRange(r, h, 0, l.DiskTiles(), newReadOptions(opts))
+}
+
+// Iterate over the values of the named channel in each tile of the layer, as Tiles does, yielding the index
+// of each tile in the dimensions of the layer along with the values of the channel in tile order, converted
+// to T as by ReadSamples.
+func TileValues[T Number](l Layer, r io.ReadSeeker, h Header, channel string, opts ...ReadOption) (iter.Seq2[int, []T], func() error) {
+	channelIndex := l.Channels.Index(channel)
+	if channelIndex < 0 {
+		return func(yield func(int, []T) bool) {}, func() error { return ErrChannelNotFound{ChannelName: channel} }
+	}
+	start, end := 0, l.DiskTiles()
+	stride, offset := l.Channels.Size(), l.Channels.Offset(channelIndex)
+	if l.Separated {
+		start = l.Dimensions.Tiles() * channelIndex
+		end = start + l.Dimensions.Tiles()
+		stride, offset = l.Channels[channelIndex].Size(), 0
+		if l.Channels[channelIndex].Type == ChannelBool {
+			stride = 1 // bit indices
+		}
+	}
+	tiles, tilesErr := l.tileRange(r, h, start, end, newReadOptions(opts))
+	decode := decodeNumber[T](l.Channels[channelIndex].Type, h.ByteOrder, l.Separated)
+	samples := l.Dimensions.TileSamples()
+	return func(yield func(int, []T) bool) {
+		for tile, data := range tiles {
+			values := make([]T, samples)
+			for i := range values {
+				values[i] = decode(data, offset+i*stride)
+			}
+			if !yield(tile%l.Dimensions.Tiles(), values) {
+				return
+			}
+		}
+	}, tilesErr
+}
+
+// Iterate over the decoded disk tiles of the layer from start up to end, as described for Tiles.
+func (l Layer) tileRange(r io.ReadSeeker, h Header, start int, end int, options readOptions) (iter.Seq2[int, []byte], func() error) {
+	var err error
+	fillAbsent := options.absentSet || l.Channels.HasFillValues()
+	options = options.forLayer(l)
+	tiles := func(yield func(int, []byte) bool) {
+		err = nil
+		for tile := start; tile < end; tile++ {
+			var data []byte
+			if l.TileBytes[tile] == 0 {
+				if !fillAbsent {
+					continue
+				}
+				switch options.absent {
+				case AbsentTileError:
+					err = ErrTileNotFound{TileIndex: tile}
+					return
+				case AbsentTileFill:
+					data, err = l.FillTile(h, tile, options.fill)
+				default:
+					data, err = l.FillTile(h, tile, nil)
+				}
+			} else {
+				data = make([]byte, l.DiskTileSize(tile))
+				err = l.readTile(r, h, tile, data, !options.skipChecksums)
+			}
+			if err != nil {
+				return
+			}
+			if !yield(tile, data) {
+				return
+			}
+		}
+	}
+	return tiles, func() error { return err }
+}
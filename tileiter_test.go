@@ -0,0 +1,150 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestLayerTiles(t *testing.T) {
+	for _, opts := range [][]LayerOption{nil, {WithPlanar(), WithCompression(CompressionFlate)}} {
+		file := writeRangeLayer(t, binary.LittleEndian, opts...)
+		layer := file.Layers[0]
+		tiles, tilesErr := layer.Tiles(file.Stream(), file.Header)
+		indices := []int{}
+		for tile, data := range tiles {
+			want := make([]byte, layer.DiskTileSize(tile))
+			if err := layer.ReadTile(file.Stream(), file.Header, tile, want); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, want) {
+				t.Errorf("separated %t: tile %d does not match ReadTile", layer.Separated, tile)
+			}
+			indices = append(indices, tile)
+		}
+		if err := tilesErr(); err != nil {
+			t.Fatal(err)
+		}
+		if len(indices) != layer.DiskTiles() || !slices.IsSorted(indices) {
+			t.Errorf("separated %t: expected all %d tiles in order, got %v", layer.Separated, layer.DiskTiles(), indices)
+		}
+
+		// breaking early stops the iteration without error
+		seen := 0
+		for range tiles {
+			seen++
+			if seen == 2 {
+				break
+			}
+		}
+		if seen != 2 || tilesErr() != nil {
+			t.Errorf("expected to stop after two tiles, saw %d (%v)", seen, tilesErr())
+		}
+	}
+}
+
+func TestLayerTilesAbsent(t *testing.T) {
+	file := writeRangeLayer(t, binary.LittleEndian)
+	layer := file.Layers[0]
+	layer.TileBytes = slices.Clone(layer.TileBytes)
+	layer.TileBytes[1] = 0
+
+	tiles, tilesErr := layer.Tiles(file.Stream(), file.Header)
+	for tile := range tiles {
+		if tile == 1 {
+			t.Error("expected the unwritten tile to be skipped")
+		}
+	}
+	if err := tilesErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	tiles, tilesErr = layer.Tiles(file.Stream(), file.Header, WithAbsentTileZeros())
+	for tile, data := range tiles {
+		if tile == 1 && slices.ContainsFunc(data, func(b byte) bool { return b != 0 }) {
+			t.Error("expected the unwritten tile to be read as zeros")
+		}
+	}
+	if err := tilesErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	tiles, tilesErr = layer.Tiles(file.Stream(), file.Header, WithAbsentTileError())
+	for range tiles {
+	}
+	var notFound ErrTileNotFound
+	if err := tilesErr(); !errors.As(err, &notFound) || notFound.TileIndex != 1 {
+		t.Errorf("expected ErrTileNotFound for tile 1, got %v", err)
+	}
+}
+
+func TestLayerTilesChecksum(t *testing.T) {
+	file := writeRangeLayer(t, binary.LittleEndian)
+	layer := file.Layers[0]
+	file.Bytes()[layer.TileOffsets[2]] ^= 0xff
+
+	tiles, tilesErr := layer.Tiles(file.Stream(), file.Header)
+	seen := 0
+	for range tiles {
+		seen++
+	}
+	var integrity ErrDataIntegrity
+	if err := tilesErr(); !errors.As(err, &integrity) || seen != 2 {
+		t.Errorf("expected a checksum error after two tiles, saw %d (%v)", seen, err)
+	}
+
+	tiles, tilesErr = layer.Tiles(file.Stream(), file.Header, WithVerifyChecksums(false))
+	for range tiles {
+	}
+	if err := tilesErr(); err != nil {
+		t.Errorf("expected no error without verifying checksums, got %v", err)
+	}
+}
+
+func TestTileValues(t *testing.T) {
+	for _, opts := range [][]LayerOption{nil, {WithPlanar()}} {
+		file := writeRangeLayer(t, binary.LittleEndian, opts...)
+		layer := file.Layers[0]
+		values, err := file.Layer(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, channel := range []string{"id", "flag"} {
+			tiles, tilesErr := TileValues[int32](layer, file.Stream(), file.Header, channel)
+			count := 0
+			for tile, tileValues := range tiles {
+				if len(tileValues) != layer.Dimensions.TileSamples() {
+					t.Fatalf("expected %d values, got %d", layer.Dimensions.TileSamples(), len(tileValues))
+				}
+				for inTile, v := range tileValues {
+					coord := TileSelector{Tile: tile, InTile: inTile}.ToTileCoordinate(layer.Dimensions).ToSampleCoordinate(layer.Dimensions)
+					if !layer.Dimensions.ContainsCoordinate(coord) {
+						continue
+					}
+					want, err := ReadSamples[int32](values, channel, coord)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if v != want[0] {
+						t.Fatalf("separated %t: channel %s at %v is %d, expected %d", layer.Separated, channel, coord, v, want[0])
+					}
+				}
+				count++
+			}
+			if err := tilesErr(); err != nil {
+				t.Fatal(err)
+			}
+			if count != layer.Dimensions.Tiles() {
+				t.Errorf("expected %d tiles, got %d", layer.Dimensions.Tiles(), count)
+			}
+		}
+	}
+
+	_, tilesErr := TileValues[int32](writeRangeLayer(t, binary.LittleEndian).Layers[0], nil, Header{}, "missing")
+	var notFound ErrChannelNotFound
+	if !errors.As(tilesErr(), &notFound) {
+		t.Errorf("expected ErrChannelNotFound, got %v", tilesErr())
+	}
+}
@@ -0,0 +1,145 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The prefix of the reserved tags holding per-tile metadata records, which are named
+// "pixi.tile.<layer index>.<tile index>".
+const TileMetadataTagPrefix string = "pixi.tile."
+
+// Well-known keys of per-tile metadata records.
+const (
+	TileAcquiredKey string = "acquired" // The acquisition time of the tile's data, in RFC 3339 format.
+	TileQualityKey  string = "quality"  // A quality score for the tile's data, as a decimal number.
+	TileSourceKey   string = "source"   // An identifier of the source granule or scene the tile was taken from.
+)
+
+// A small record of metadata attached to an individual tile position, such as the acquisition time and
+// source of each tile of a mosaic. For separated layers it is shared by every channel tile at the position.
+type TileMetadata map[string]string
+
+// The acquisition time recorded under TileAcquiredKey, if present and valid.
+func (m TileMetadata) Acquired() (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, m[TileAcquiredKey])
+	return t, err == nil
+}
+
+// Records the acquisition time under TileAcquiredKey.
+func (m TileMetadata) SetAcquired(t time.Time) {
+	m[TileAcquiredKey] = t.Format(time.RFC3339Nano)
+}
+
+// The quality score recorded under TileQualityKey, if present and valid.
+func (m TileMetadata) Quality() (float64, bool) {
+	q, err := strconv.ParseFloat(m[TileQualityKey], 64)
+	return q, err == nil
+}
+
+// Records the quality score under TileQualityKey.
+func (m TileMetadata) SetQuality(q float64) {
+	m[TileQualityKey] = strconv.FormatFloat(q, 'g', -1, 64)
+}
+
+// The source identifier recorded under TileSourceKey, or the empty string if there is none.
+func (m TileMetadata) Source() string {
+	return m[TileSourceKey]
+}
+
+// Records the source identifier under TileSourceKey.
+func (m TileMetadata) SetSource(source string) {
+	m[TileSourceKey] = source
+}
+
+// The name of the tag holding the metadata record of the given tile position of the given layer.
+func TileMetadataTag(layerIndex int, tile int) string {
+	return fmt.Sprintf("%s%d.%d", TileMetadataTagPrefix, layerIndex, tile)
+}
+
+// Parses the name of a per-tile metadata tag, returning false if it is not one.
+func parseTileMetadataTag(tag string) (layerIndex int, tile int, ok bool) {
+	rest, ok := strings.CutPrefix(tag, TileMetadataTagPrefix)
+	if !ok {
+		return 0, 0, false
+	}
+	layerPart, tilePart, ok := strings.Cut(rest, ".")
+	if !ok {
+		return 0, 0, false
+	}
+	layerIndex, err := strconv.Atoi(layerPart)
+	if err != nil {
+		return 0, 0, false
+	}
+	tile, err = strconv.Atoi(tilePart)
+	if err != nil {
+		return 0, 0, false
+	}
+	return layerIndex, tile, true
+}
+
+// The metadata record of the given tile position of the layer with the given index, if one has been
+// attached. Records appended later replace earlier records for the same tile.
+func (p *Pixi) TileMetadata(layerIndex int, tile int) (TileMetadata, bool) {
+	value, ok := p.AllTags()[TileMetadataTag(layerIndex, tile)]
+	if !ok {
+		return nil, false
+	}
+	return decodeTileMetadata(value)
+}
+
+// Every metadata record attached to the tiles of the layer with the given index, by tile position.
+func (p *Pixi) AllTileMetadata(layerIndex int) map[int]TileMetadata {
+	records := map[int]TileMetadata{}
+	for tag, value := range p.AllTags() {
+		if l, tile, ok := parseTileMetadataTag(tag); ok && l == layerIndex {
+			if record, ok := decodeTileMetadata(value); ok {
+				records[tile] = record
+			}
+		}
+	}
+	return records
+}
+
+// Decodes a metadata record, which is stored as URL-encoded key-value pairs so that keys and values may
+// contain any characters.
+func decodeTileMetadata(value string) (TileMetadata, bool) {
+	values, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, false
+	}
+	record := TileMetadata{}
+	for k, v := range values {
+		record[k] = v[0]
+	}
+	return record, true
+}
+
+// Attaches metadata records to tile positions (in [0, Dimensions.Tiles())) of the layer with the given
+// index, appending them to the file as a new tag section. A record replaces any record previously attached
+// to the same tile; records are not merged.
+func (p *Pixi) AppendTileMetadata(w io.WriteSeeker, layerIndex int, records map[int]TileMetadata) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append tile metadata"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	tiles := p.Layers[layerIndex].Dimensions.Tiles()
+	tags := make(map[string]string, len(records))
+	for tile, record := range records {
+		if tile < 0 || tile >= tiles {
+			return ErrTileNotFound{TileIndex: tile}
+		}
+		values := url.Values{}
+		for k, v := range record {
+			values.Set(k, v)
+		}
+		tags[TileMetadataTag(layerIndex, tile)] = values.Encode()
+	}
+	return p.AppendTags(w, tags)
+}
@@ -0,0 +1,72 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestTileMetadata(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("mosaic", DimensionSet{{Name: "x", Size: 8, TileSize: 4}, {Name: "y", Size: 4, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}})}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), map[string]string{"author": "test"}, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+
+	acquired := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	first := TileMetadata{"note": "a=b&c"}
+	first.SetAcquired(acquired)
+	first.SetQuality(0.75)
+	first.SetSource("S2A_MSIL2A_20240501")
+	err := written.AppendTileMetadata(buf, 0, map[int]TileMetadata{0: first, 3: {TileSourceKey: "old"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := written.AppendTileMetadata(buf, 0, map[int]TileMetadata{3: {TileSourceKey: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Seek(0, 0)
+	read, err := ReadPixi(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, ok := read.TileMetadata(0, 0)
+	if !ok {
+		t.Fatal("expected metadata for tile 0")
+	}
+	if at, ok := record.Acquired(); !ok || !at.Equal(acquired) {
+		t.Errorf("expected acquisition time %v, got %v", acquired, at)
+	}
+	if q, ok := record.Quality(); !ok || q != 0.75 {
+		t.Errorf("expected quality 0.75, got %v", q)
+	}
+	if record.Source() != "S2A_MSIL2A_20240501" || record["note"] != "a=b&c" {
+		t.Errorf("unexpected record %v", record)
+	}
+	if _, ok := read.TileMetadata(0, 1); ok {
+		t.Error("expected no metadata for tile 1")
+	}
+
+	all := read.AllTileMetadata(0)
+	if len(all) != 2 || all[3].Source() != "new" {
+		t.Errorf("unexpected records %v", all)
+	}
+	if len(read.AllTileMetadata(1)) != 0 {
+		t.Error("expected no records for a missing layer")
+	}
+	if read.AllTags()["author"] != "test" {
+		t.Error("expected ordinary tags to be preserved")
+	}
+
+	var notFound ErrTileNotFound
+	if err := written.AppendTileMetadata(buf, 0, map[int]TileMetadata{8: {}}); !errors.As(err, &notFound) {
+		t.Errorf("expected ErrTileNotFound for out of range tile, got %v", err)
+	}
+	if err := written.AppendTileMetadata(buf, 1, nil); err == nil {
+		t.Error("expected error for out of range layer")
+	}
+}
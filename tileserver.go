@@ -0,0 +1,258 @@
+package gopixi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// The request header in which clients of a TileServer list the tile compressions they can decode, by
+	// name (as given by Compression.String), most preferred first. A "*" accepts any compression.
+	AcceptCompressionHeader string = "Accept-Pixi-Compression"
+	// The response header naming the compression of the tile data in the body.
+	CompressionHeader string = "Pixi-Compression"
+	// The response header holding the CRC-32 (IEEE) checksum of the decoded tile data, in hexadecimal.
+	TileChecksumHeader string = "Pixi-Checksum"
+)
+
+// Parses the name of a compression, as given by Compression.String.
+func ParseCompression(name string) (Compression, error) {
+	for _, c := range []Compression{CompressionNone, CompressionFlate, CompressionLzwLsb, CompressionLzwMsb, CompressionRle8, CompressionZstd, CompressionLz4, CompressionProgressive} {
+		if strings.EqualFold(name, c.String()) {
+			return c, nil
+		}
+	}
+	return 0, ErrUnsupported(fmt.Sprintf("compression '%s'", name))
+}
+
+// Serves the tiles of a Pixi file over HTTP, requested with the query parameters "layer" and "tile" (the disk
+// tile index), sent as stored if the client accepts the layer's compression and transcoded otherwise. Tiles
+// that were never written are reported as 404 Not Found, and requests accepting no supported compression as
+// 406 Not Acceptable.
+type TileServer struct {
+	pixi *Pixi
+
+	readLock sync.Mutex
+	source   io.ReadSeeker
+
+	cacheLock sync.Mutex
+	cacheSize int
+	cache     map[transcodedTile]encodedTile
+	order     []transcodedTile // cached tiles, oldest first
+}
+
+type transcodedTile struct {
+	layer       int
+	tile        int
+	compression Compression
+}
+
+type encodedTile struct {
+	data     []byte
+	checksum uint32 // of the decoded tile
+}
+
+var _ http.Handler = (*TileServer)(nil)
+
+// Creates a server for the tiles of the given file, read from source, keeping up to cacheSize transcoded
+// tiles in memory.
+func NewTileServer(source io.ReadSeeker, pixi *Pixi, cacheSize int) *TileServer {
+	return &TileServer{pixi: pixi, source: source, cacheSize: max(cacheSize, 0), cache: map[transcodedTile]encodedTile{}}
+}
+
+func (s *TileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	layerIndex, err := strconv.Atoi(r.URL.Query().Get("layer"))
+	if err != nil || layerIndex < 0 || layerIndex >= len(s.pixi.Layers) {
+		http.Error(w, "invalid or missing layer index", http.StatusNotFound)
+		return
+	}
+	layer := s.pixi.Layers[layerIndex]
+	tile, err := strconv.Atoi(r.URL.Query().Get("tile"))
+	if err != nil || tile < 0 || tile >= layer.DiskTiles() || layer.TileBytes[tile] == 0 {
+		http.Error(w, "invalid, missing, or unwritten tile index", http.StatusNotFound)
+		return
+	}
+	compression, ok := negotiateCompression(r.Header.Values(AcceptCompressionHeader), layer.Compression)
+	if !ok {
+		http.Error(w, "no acceptable tile compression", http.StatusNotAcceptable)
+		return
+	}
+
+	data, checksum, err := s.tile(layerIndex, tile, compression)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.pixi.AccessStats.RecordTile(layerIndex, tile)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set(CompressionHeader, compression.String())
+	w.Header().Set(TileChecksumHeader, strconv.FormatUint(uint64(checksum), 16))
+	w.Header().Add("Vary", AcceptCompressionHeader)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+// Chooses the compression to send a tile stored with the given compression in, from the compressions
+// listed by the client. The stored compression is preferred whenever it is acceptable, avoiding transcoding.
+func negotiateCompression(accept []string, stored Compression) (Compression, bool) {
+	names := []string{}
+	for _, value := range accept {
+		for name := range strings.SplitSeq(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return stored, true
+	}
+	acceptable := []Compression{}
+	for _, name := range names {
+		if name == "*" {
+			return stored, true
+		}
+		if c, err := ParseCompression(name); err == nil {
+			if c == stored {
+				return stored, true
+			}
+			acceptable = append(acceptable, c)
+		}
+	}
+	if len(acceptable) == 0 {
+		return 0, false
+	}
+	return acceptable[0], true
+}
+
+// Returns the tile encoded with the given compression, along with the checksum of its decoded data.
+func (s *TileServer) tile(layerIndex int, tile int, compression Compression) ([]byte, uint32, error) {
+	layer := s.pixi.Layers[layerIndex]
+	if compression == layer.Compression {
+		return s.storedTile(layer, tile)
+	}
+
+	key := transcodedTile{layer: layerIndex, tile: tile, compression: compression}
+	s.cacheLock.Lock()
+	cached, ok := s.cache[key]
+	s.cacheLock.Unlock()
+	if ok {
+		return cached.data, cached.checksum, nil
+	}
+
+	decoded := make([]byte, layer.DiskTileSize(tile))
+	s.readLock.Lock()
+	err := layer.ReadTile(s.source, s.pixi.Header, tile, decoded)
+	s.readLock.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+	buf := &bytes.Buffer{}
+	if _, err := compression.writeChunk(buf, s.pixi.Header, layer, tile, decoded); err != nil {
+		return nil, 0, err
+	}
+	data, checksum := buf.Bytes(), crc32.ChecksumIEEE(decoded)
+
+	if s.cacheSize > 0 {
+		s.cacheLock.Lock()
+		if _, ok := s.cache[key]; !ok {
+			if len(s.order) >= s.cacheSize {
+				delete(s.cache, s.order[0])
+				s.order = s.order[1:]
+			}
+			s.order = append(s.order, key)
+		}
+		s.cache[key] = encodedTile{data: data, checksum: checksum}
+		s.cacheLock.Unlock()
+	}
+	return data, checksum, nil
+}
+
+// Reads the stored bytes of the tile along with the CRC-32 (IEEE) checksum of its decoded data, which is the
+// checksum stored after the tile in files using ChecksumCRC32, and is otherwise computed from the tile once
+// it is decoded and verified against its stored checksum.
+func (s *TileServer) storedTile(layer Layer, tile int) ([]byte, uint32, error) {
+	header := s.pixi.Header
+	tileRange := layer.TileRange(header, tile)
+	stored := make([]byte, tileRange.Length)
+	s.readLock.Lock()
+	_, err := s.source.Seek(tileRange.Offset, io.SeekStart)
+	if err == nil {
+		_, err = io.ReadFull(s.source, stored)
+	}
+	s.readLock.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+	data := stored[:layer.TileBytes[tile]]
+	if header.Checksum == ChecksumCRC32 {
+		return data, header.ByteOrder.Uint32(stored[len(data):]), nil
+	}
+	decoded := make([]byte, layer.DiskTileSize(tile))
+	if err := layer.ReadTile(newPrefetchedReader([]ByteRange{tileRange}, [][]byte{stored}), header, tile, decoded); err != nil {
+		return nil, 0, err
+	}
+	return data, crc32.ChecksumIEEE(decoded), nil
+}
+
+// Fetches a tile of the layer from a TileServer at the given URL (with no query parameters), accepting
+// the given compressions (or the tile's stored compression, if none are given), and returns the decoded
+// tile data after verifying its checksum.
+func FetchTile(client *http.Client, serverUrl string, layerIndex int, layer Layer, tile int, accept ...Compression) ([]byte, error) {
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s?layer=%d&tile=%d", serverUrl, layerIndex, tile), nil)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(accept))
+	for i, c := range accept {
+		names[i] = c.String()
+	}
+	if len(names) > 0 {
+		request.Header.Set(AcceptCompressionHeader, strings.Join(names, ", "))
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrTileNotFound{TileIndex: tile}
+	case http.StatusNotAcceptable:
+		return nil, ErrUnsupported(fmt.Sprintf("server cannot send tile %d in any of the compressions %v", tile, names))
+	default:
+		return nil, ErrFormat(fmt.Sprintf("fetching tile %d: unexpected status %s", tile, response.Status))
+	}
+
+	compression, err := ParseCompression(response.Header.Get(CompressionHeader))
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, layer.DiskTileSize(tile))
+	if _, err := compression.readChunk(bytes.NewReader(body), layer, tile, data); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	checksum, err := strconv.ParseUint(response.Header.Get(TileChecksumHeader), 16, 32)
+	if err != nil || uint32(checksum) != crc32.ChecksumIEEE(data) {
+		return nil, ErrDataIntegrity{TileIndex: tile, LayerName: layer.Name}
+	}
+	return data, nil
+}
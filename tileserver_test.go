@@ -0,0 +1,80 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestTileServer(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.BigEndian, OffsetSize8)
+	layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 64, TileSize: 32}}, ChannelSet{{Name: "v", Type: ChannelUint16}}, WithCompression(CompressionFlate))}
+	written := writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint16(coord[0] / 4)}
+	})
+	layer := written.Layers[0]
+	expected := make([]byte, layer.DiskTileSize(1))
+	if err := layer.ReadTile(buf, header, 1, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewTileServer(buffer.NewBufferFrom(buf.Bytes()), written, 4)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	client := httpServer.Client()
+
+	for _, accept := range [][]Compression{nil, {CompressionFlate}, {CompressionNone}, {CompressionRle8, CompressionFlate}, {CompressionLzwMsb, CompressionNone}, {CompressionNone}} {
+		data, err := FetchTile(client, httpServer.URL, 0, layer, 1, accept...)
+		if err != nil {
+			t.Fatalf("accept %v: %v", accept, err)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("accept %v: tile data does not match", accept)
+		}
+	}
+	if len(server.cache) != 2 {
+		t.Errorf("expected 2 transcoded tiles to be cached, got %d", len(server.cache))
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, httpServer.URL+"?layer=0&tile=0", nil)
+	request.Header.Set(AcceptCompressionHeader, "lzw_lsb, none")
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.Header.Get(CompressionHeader) != "lzw_lsb" {
+		t.Errorf("expected the first acceptable compression to be chosen, got %s", response.Header.Get(CompressionHeader))
+	}
+
+	if _, err := FetchTile(client, httpServer.URL, 0, layer, 5); !errors.As(err, new(ErrTileNotFound)) {
+		t.Errorf("expected ErrTileNotFound for a missing tile, got %v", err)
+	}
+	request, _ = http.NewRequest(http.MethodGet, httpServer.URL+"?layer=0&tile=0", nil)
+	request.Header.Set(AcceptCompressionHeader, "brotli")
+	response, err = client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("expected 406 for unsupported compressions, got %d", response.StatusCode)
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	for _, c := range []Compression{CompressionNone, CompressionFlate, CompressionLzwLsb, CompressionLzwMsb, CompressionRle8, CompressionZstd, CompressionLz4, CompressionProgressive} {
+		if parsed, err := ParseCompression(c.String()); err != nil || parsed != c {
+			t.Errorf("expected %s to round trip, got %v (%v)", c, parsed, err)
+		}
+	}
+	if _, err := ParseCompression("brotli"); err == nil {
+		t.Error("expected unknown compression to fail")
+	}
+}
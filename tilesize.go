@@ -0,0 +1,93 @@
+package gopixi
+
+import (
+	"math"
+	"slices"
+)
+
+// The default target for the compressed size in bytes of each tile when tile sizes are chosen
+// automatically.
+const DefaultTargetTileBytes int = 256 * 1024
+
+type targetTileBytesOption struct {
+	bytes int
+}
+
+func (o targetTileBytesOption) applyLayer(opts *layerOptions) {
+	opts.targetTileBytes = o.bytes
+}
+
+// Sets the approximate compressed size in bytes that NewLayer aims for when choosing the tile size
+// of dimensions whose TileSize is zero. Defaults to DefaultTargetTileBytes.
+func WithTargetTileBytes(bytes int) LayerOption {
+	return targetTileBytesOption{bytes: max(bytes, 1)}
+}
+
+// Chooses a tile size for every dimension with a TileSize of zero (or less) so that each tile is about
+// targetBytes after compression, returning a copy of the dimensions if any tile sizes were chosen. Tile
+// sizes that were specified are kept.
+func ChooseTileSizes(dims DimensionSet, channels ChannelSet, separated bool, compression Compression, targetBytes int) DimensionSet {
+	auto := []int{}
+	for i, dim := range dims {
+		if dim.TileSize <= 0 {
+			auto = append(auto, i)
+		}
+	}
+	if len(auto) == 0 {
+		return dims
+	}
+	dims = slices.Clone(dims)
+
+	// separated tiles hold a single channel, so they are sized for the largest channel
+	sampleBytes := channels.Size()
+	if separated {
+		sampleBytes = 0
+		for _, c := range channels {
+			sampleBytes = max(sampleBytes, c.Size())
+		}
+	}
+	budget := float64(targetBytes) * estimatedCompressionRatio(compression) / float64(max(sampleBytes, 1))
+	for _, dim := range dims {
+		if dim.TileSize > 0 {
+			budget /= float64(dim.TileSize)
+		}
+	}
+
+	// dimensions smaller than an even share of the budget are left untiled, and the remaining budget
+	// is shared out among the larger dimensions
+	for capped := true; capped && len(auto) > 0; {
+		capped = false
+		side := math.Pow(max(budget, 1), 1/float64(len(auto)))
+		for i, dimIndex := range auto {
+			if float64(dims[dimIndex].Size) <= side {
+				dims[dimIndex].TileSize = max(dims[dimIndex].Size, 1)
+				budget /= float64(dims[dimIndex].TileSize)
+				auto = slices.Delete(auto, i, i+1)
+				capped = true
+				break
+			}
+		}
+	}
+	if len(auto) == 0 {
+		return dims
+	}
+
+	side := math.Pow(max(budget, 1), 1/float64(len(auto)))
+	tileSize := int(math.Exp2(math.Round(math.Log2(side))))
+	for _, dimIndex := range auto {
+		dims[dimIndex].TileSize = min(tileSize, dims[dimIndex].Size)
+	}
+	return dims
+}
+
+// A rough estimate of how much smaller data gets when compressed, used only for choosing tile sizes.
+func estimatedCompressionRatio(c Compression) float64 {
+	switch c {
+	case CompressionFlate, CompressionZstd, CompressionProgressive:
+		return 2
+	case CompressionLzwLsb, CompressionLzwMsb, CompressionRle8, CompressionLz4:
+		return 1.5
+	default:
+		return 1
+	}
+}
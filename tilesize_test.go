@@ -0,0 +1,54 @@
+package gopixi
+
+import "testing"
+
+func TestChooseTileSizesBalanced(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 10000}, {Name: "y", Size: 8000}}
+	channels := ChannelSet{{Name: "v", Type: ChannelFloat32}}
+	chosen := ChooseTileSizes(dims, channels, false, CompressionNone, 1024*1024)
+
+	if dims[0].TileSize != 0 || dims[1].TileSize != 0 {
+		t.Error("expected input dimensions to be left unchanged")
+	}
+	// 1 MiB of float32 samples is 512x512
+	if chosen[0].TileSize != 512 || chosen[1].TileSize != 512 {
+		t.Errorf("expected 512x512 tiles, got %dx%d", chosen[0].TileSize, chosen[1].TileSize)
+	}
+
+	compressed := ChooseTileSizes(dims, channels, false, CompressionFlate, 1024*1024)
+	if compressed[0].TileSize*compressed[1].TileSize <= chosen[0].TileSize*chosen[1].TileSize {
+		t.Errorf("expected larger tiles for compressed layers, got %dx%d", compressed[0].TileSize, compressed[1].TileSize)
+	}
+}
+
+func TestChooseTileSizesSmallAndFixed(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 100000}, {Name: "band", Size: 3}, {Name: "t", Size: 50, TileSize: 5}}
+	channels := ChannelSet{{Name: "a", Type: ChannelUint8}, {Name: "b", Type: ChannelUint16}}
+	chosen := ChooseTileSizes(dims, channels, true, CompressionNone, 64*1024)
+
+	if chosen[1].TileSize != 3 {
+		t.Errorf("expected small dimension to be untiled, got tile size %d", chosen[1].TileSize)
+	}
+	if chosen[2].TileSize != 5 {
+		t.Errorf("expected specified tile size to be kept, got %d", chosen[2].TileSize)
+	}
+	// separated tiles are sized for the 2-byte channel: 64 KiB / 2 / 3 / 5 samples, rounded to a power of two
+	if chosen[0].TileSize != 2048 {
+		t.Errorf("expected tile size 2048 for remaining dimension, got %d", chosen[0].TileSize)
+	}
+}
+
+func TestNewLayerAutoTileSize(t *testing.T) {
+	layer := NewLayer("auto",
+		DimensionSet{{Name: "x", Size: 300}, {Name: "y", Size: 200}},
+		ChannelSet{{Name: "v", Type: ChannelUint8}},
+		WithTargetTileBytes(1024))
+	for _, dim := range layer.Dimensions {
+		if dim.TileSize != 32 {
+			t.Errorf("expected tile size 32 for dimension %s, got %d", dim.Name, dim.TileSize)
+		}
+	}
+	if len(layer.TileBytes) != layer.DiskTiles() || layer.DiskTiles() != 10*7 {
+		t.Errorf("expected 70 tiles, got %d", layer.DiskTiles())
+	}
+}
@@ -0,0 +1,279 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Transforms the samples of a layer as its tiles are decoded, producing "analysis-ready" values such as
+// physical quantities from packed integers, or appending virtual channels computed from the others. Transforms are descriptions that can be shared between layers;
+// Bind resolves one against the channels of a particular layer.
+type ReadTransform interface {
+	// Returns the channels seen after the transform, given the channels before it, along with the function
+	// that transforms each sample. The function may modify and return the sample it is given.
+	Bind(channels ChannelSet) (ChannelSet, func(coord SampleCoordinate, sample Sample) Sample, error)
+}
+
+// Converts the named channel to float64 values v*Scale + Offset, as for packed data following the CF
+// scale_factor and add_offset conventions.
+type ScaleOffset struct {
+	Channel string
+	Scale   float64
+	Offset  float64
+}
+
+func (s ScaleOffset) Bind(channels ChannelSet) (ChannelSet, func(SampleCoordinate, Sample) Sample, error) {
+	return mapChannels(channels, []string{s.Channel}, ChannelFloat64, "", func(v float64) float64 { return v*s.Scale + s.Offset })
+}
+
+// Casts the named channels (or every channel, if none are named) to float32.
+type CastFloat32 struct {
+	Channels []string
+}
+
+func (c CastFloat32) Bind(channels ChannelSet) (ChannelSet, func(SampleCoordinate, Sample) Sample, error) {
+	names := c.Channels
+	if len(names) == 0 {
+		for _, channel := range channels {
+			names = append(names, channel.Name)
+		}
+	}
+	return mapChannels(channels, names, ChannelFloat32, "", func(v float64) float64 { return v })
+}
+
+// Replaces the values of the named channels (or every channel, if none are named) with Fill wherever the
+// Masked function returns true for a sample, such as for samples flagged as cloudy by a quality channel.
+// The fill value is converted to the type of each channel.
+type Mask struct {
+	Channels []string
+	Masked   func(coord SampleCoordinate, sample Sample) bool
+	Fill     float64
+}
+
+func (m Mask) Bind(channels ChannelSet) (ChannelSet, func(SampleCoordinate, Sample) Sample, error) {
+	indices, err := channelIndices(channels, m.Channels)
+	if err != nil {
+		return nil, nil, err
+	}
+	fills := make([]any, len(indices))
+	for i, index := range indices {
+		fills[i] = channels[index].Type.FromFloat64(m.Fill)
+	}
+	return channels, func(coord SampleCoordinate, sample Sample) Sample {
+		if m.Masked(coord, sample) {
+			for i, index := range indices {
+				sample[index] = fills[i]
+			}
+		}
+		return sample
+	}, nil
+}
+
+// Converts the named channel from its unit to the given unit, producing float64 values. Both units are
+// parsed with ParseUnit, and must be convertible.
+type ConvertUnit struct {
+	Channel string
+	To      string
+}
+
+func (c ConvertUnit) Bind(channels ChannelSet) (ChannelSet, func(SampleCoordinate, Sample) Sample, error) {
+	index := channels.Index(c.Channel)
+	if index < 0 {
+		return nil, nil, ErrChannelNotFound{ChannelName: c.Channel}
+	}
+	from, err := ParseUnit(channels[index].Unit)
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := ParseUnit(c.To)
+	if err != nil {
+		return nil, nil, err
+	}
+	convert, err := from.ConverterTo(to)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mapChannels(channels, []string{c.Channel}, ChannelFloat64, c.To, convert)
+}
+
+func channelIndices(channels ChannelSet, names []string) ([]int, error) {
+	if len(names) == 0 {
+		indices := make([]int, len(channels))
+		for i := range channels {
+			indices[i] = i
+		}
+		return indices, nil
+	}
+	indices := make([]int, len(names))
+	for i, name := range names {
+		indices[i] = channels.Index(name)
+		if indices[i] < 0 {
+			return nil, ErrChannelNotFound{ChannelName: name}
+		}
+	}
+	return indices, nil
+}
+
+// Binds a transform that converts the named channels to the given type, applying f to each value. The
+// Min/Max statistics of converted channels are dropped, and their unit replaced if a new one is given.
+func mapChannels(channels ChannelSet, names []string, to ChannelType, unit string, f func(float64) float64) (ChannelSet, func(SampleCoordinate, Sample) Sample, error) {
+	indices, err := channelIndices(channels, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := slices.Clone(channels)
+	for _, index := range indices {
+		result[index] = Channel{Name: channels[index].Name, Type: to, Unit: channels[index].Unit}
+		if unit != "" {
+			result[index].Unit = unit
+		}
+	}
+	return result, func(coord SampleCoordinate, sample Sample) Sample {
+		for _, index := range indices {
+			v, _ := channels[index].Type.ToFloat64(sample[index])
+			sample[index] = to.FromFloat64(f(v))
+		}
+		return sample
+	}, nil
+}
+
+// Provides access to the tiles of a layer with read transforms applied as each tile is decoded. The
+// layer it reports has the transformed channels, so sample accessors such as SampleAt see the transformed
+// values. Transformed tiles are cached separately from the underlying layer.
+type TransformedReadLayer struct {
+	base       TileAccessLayer
+	layer      Layer
+	transforms []func(SampleCoordinate, Sample) Sample
+	maxSize    int
+
+	cacheLock sync.Mutex
+	cache     map[int][]byte
+	order     []int // cached tile positions, oldest first
+}
+
+var _ TileAccessLayer = (*TransformedReadLayer)(nil)
+
+// Wraps the tiles of the base layer so that every sample passes through the transforms, in order. Up
+// to maxSize transformed tile positions (counting every channel tile of a separated layer together) are
+// kept in memory.
+func NewTransformedReadLayer(base TileAccessLayer, maxSize int, transforms ...ReadTransform) (*TransformedReadLayer, error) {
+	layer := base.Layer()
+	channels := layer.Channels
+	t := &TransformedReadLayer{base: base, maxSize: max(maxSize, 1), cache: map[int][]byte{}}
+	for _, transform := range transforms {
+		next, f, err := transform.Bind(channels)
+		if err != nil {
+			return nil, err
+		}
+		channels = next
+		t.transforms = append(t.transforms, f)
+	}
+	layer.Channels = channels
+	t.layer = layer
+	return t, nil
+}
+
+func (t *TransformedReadLayer) Layer() Layer {
+	return t.layer
+}
+
+func (t *TransformedReadLayer) Header() Header {
+	return t.base.Header()
+}
+
+func (t *TransformedReadLayer) Tile(tile int) ([]byte, error) {
+	t.cacheLock.Lock()
+	defer t.cacheLock.Unlock()
+	if data, ok := t.cache[tile]; ok {
+		return data, nil
+	}
+
+	// every channel tile at the same position is transformed together, since transforms see whole samples
+	dims := t.layer.Dimensions
+	position := tile % dims.Tiles()
+	scratch := &scratchTiles{layer: t.layer, header: t.Header(), tiles: map[int][]byte{}}
+	for inTile := range dims.TileSamples() {
+		coord := TileSelector{Tile: position, InTile: inTile}.ToTileCoordinate(dims).ToSampleCoordinate(dims)
+		if !dims.ContainsCoordinate(coord) {
+			continue
+		}
+		sample, err := SampleAt(t.base, coord)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range t.transforms {
+			sample = f(coord, sample)
+		}
+		if err := SetSampleAt(scratch, coord, sample); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(t.order) >= t.maxSize {
+		oldest := t.order[0]
+		t.order = t.order[1:]
+		for channel := range t.layer.Channels {
+			delete(t.cache, oldest+channel*dims.Tiles())
+		}
+	}
+	t.order = append(t.order, position)
+	for index := range scratch.tiles {
+		t.cache[index] = scratch.tiles[index]
+	}
+	return scratch.Tile(tile)
+}
+
+// Registers read transforms for the named layer, applied in order (after any already registered) by
+// ReadLayer.
+func (p *Pixi) RegisterReadTransform(layerName string, transforms ...ReadTransform) {
+	if p.readTransforms == nil {
+		p.readTransforms = map[string][]ReadTransform{}
+	}
+	p.readTransforms[layerName] = append(p.readTransforms[layerName], transforms...)
+}
+
+// Opens the layer with the given index for reading, caching up to cacheSize tiles (or using the TileCache of
+// the file), and applying any read transforms registered for it. Tiles that were never written are read
+// according to the absent tile policy of the options. If the options select channels, only those channels
+// (named as they are after any transforms) are read.
+func (p *Pixi) ReadLayer(r io.ReadSeeker, layerIndex int, cacheSize int, opts ...ReadOption) (TileAccessLayer, error) {
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return nil, ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	layer := p.Layers[layerIndex]
+	options := newReadOptions(opts).forLayer(layer)
+	var base TileAccessLayer
+	if p.TileCache != nil {
+		base = &tileCacheReadLayer{cache: p.TileCache, file: p, index: layerIndex, layer: layer, header: p.Header,
+			skipChecksums: options.skipChecksums, backing: r}
+	} else {
+		cached := NewFifoCacheReadLayer(r, p.Header, layer, cacheSize)
+		cached.skipChecksums = options.skipChecksums
+		base = cached
+	}
+	return p.wrapReadLayer(base, cacheSize, options, opts)
+}
+
+// Wraps the accessor reading the stored tiles of a layer of the file to apply the absent tile policy, read
+// transforms, and channel selection of ReadLayer.
+func (p *Pixi) wrapReadLayer(base TileAccessLayer, cacheSize int, options readOptions, opts []ReadOption) (TileAccessLayer, error) {
+	layer := base.Layer()
+	if options.absent != AbsentTileError {
+		var err error
+		if base, err = NewAbsentFillLayer(base, opts...); err != nil {
+			return nil, err
+		}
+	}
+	if transforms := p.readTransforms[layer.Name]; len(transforms) > 0 {
+		var err error
+		if base, err = NewTransformedReadLayer(base, cacheSize, transforms...); err != nil {
+			return nil, err
+		}
+	}
+	if len(options.channels) == 0 {
+		return base, nil
+	}
+	return NewChannelSelectLayer(base, cacheSize, options.channels...)
+}
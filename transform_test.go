@@ -0,0 +1,72 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestReadTransforms(t *testing.T) {
+	for _, planar := range []bool{false, true} {
+		buf := buffer.NewBuffer(10)
+		opts := []LayerOption{}
+		if planar {
+			opts = append(opts, WithPlanar())
+		}
+		channels := ChannelSet{
+			{Name: "temp", Type: ChannelUint16, Unit: "K"},
+			{Name: "quality", Type: ChannelUint8},
+			{Name: "count", Type: ChannelInt16},
+		}
+		layers := []Layer{NewLayer("obs", DimensionSet{{Name: "x", Size: 10, TileSize: 4}}, channels, opts...)}
+		written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+			return Sample{uint16(27000 + coord[0]*100), uint8(coord[0] % 3), int16(coord[0])}
+		})
+
+		written.RegisterReadTransform("obs",
+			ScaleOffset{Channel: "temp", Scale: 0.01},
+			ConvertUnit{Channel: "temp", To: "degC"},
+			Mask{Channels: []string{"temp"}, Masked: func(coord SampleCoordinate, sample Sample) bool { return sample[1] == uint8(0) }, Fill: math.NaN()},
+			CastFloat32{Channels: []string{"count"}},
+		)
+		access, err := written.ReadLayer(buffer.NewBufferFrom(buf.Bytes()), 0, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		layer := access.Layer()
+		if layer.Channels[0].Type != ChannelFloat64 || layer.Channels[0].Unit != "degC" || layer.Channels[2].Type != ChannelFloat32 {
+			t.Errorf("unexpected transformed channels %v", layer.Channels)
+		}
+		// read in an order that evicts and reloads cached tiles
+		for _, x := range []int{9, 0, 5, 1, 8, 4} {
+			sample, err := SampleAt(access, SampleCoordinate{x})
+			if err != nil {
+				t.Fatal(err)
+			}
+			temp := sample[0].(float64)
+			if x%3 == 0 {
+				if !math.IsNaN(temp) {
+					t.Errorf("planar %v: expected sample %d to be masked, got %v", planar, x, temp)
+				}
+			} else if expected := 270 + float64(x) - 273.15; math.Abs(temp-expected) > 1e-9 {
+				t.Errorf("planar %v: expected sample %d to be %v degC, got %v", planar, x, expected, temp)
+			}
+			if sample[1] != uint8(x%3) || sample[2] != float32(x) {
+				t.Errorf("planar %v: unexpected untransformed values %v", planar, sample)
+			}
+		}
+	}
+}
+
+func TestReadTransformErrors(t *testing.T) {
+	base := NewFifoCacheReadLayer(buffer.NewBuffer(0), NewHeader(binary.LittleEndian, OffsetSize4),
+		NewLayer("layer", DimensionSet{{Name: "x", Size: 4, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8, Unit: "m"}}), 1)
+	if _, err := NewTransformedReadLayer(base, 1, ScaleOffset{Channel: "missing", Scale: 1}); err == nil {
+		t.Error("expected transform of a missing channel to fail")
+	}
+	if _, err := NewTransformedReadLayer(base, 1, ConvertUnit{Channel: "v", To: "s"}); err == nil {
+		t.Error("expected conversion between incompatible units to fail")
+	}
+}
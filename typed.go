@@ -0,0 +1,194 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// The Go numeric types that ReadSamples and WriteSamples convert channel values to and from, including
+// types defined on them (such as a Kelvin float32).
+type Number interface {
+	~int8 | ~uint8 | ~int16 | ~uint16 | ~int32 | ~uint32 | ~int64 | ~uint64 | ~float32 | ~float64
+}
+
+// Reads the values of the named channel at each coordinate into a slice of T, converted from the channel
+// type as by a Go conversion (booleans being 0 or 1), without boxing every value as SampleAt does.
+func ReadSamples[T Number](accessor TileAccessLayer, channel string, coords ...SampleCoordinate) ([]T, error) {
+	layer := accessor.Layer()
+	channelIndex := layer.Channels.Index(channel)
+	if channelIndex < 0 {
+		return nil, ErrChannelNotFound{ChannelName: channel}
+	}
+	decode := decodeNumber[T](layer.Channels[channelIndex].Type, accessor.Header().ByteOrder, layer.Separated)
+
+	values := make([]T, len(coords))
+	for i, coord := range coords {
+		if !layer.Dimensions.ContainsCoordinate(coord) {
+			return nil, ErrSampleCoordinateOutOfBounds{Coordinate: coord, Dimensions: layer.Dimensions}
+		}
+		tile, offset := layer.channelLocation(coord, channelIndex)
+		data, err := accessor.Tile(tile)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = decode(data, offset)
+	}
+	return values, nil
+}
+
+// Writes each value to the named channel of the sample at the corresponding coordinate, encoding it straight
+// into the tiles of the modifier, as the typed counterpart of SetChannelAt. Values are converted from T to
+// the channel type as by a Go conversion, booleans being true for any non-zero value, and the Min/Max
+// statistics and non-finite rules of the channel are applied as for SetChannelAt.
+func WriteSamples[T Number](modifier TileModifierLayer, channel string, coords []SampleCoordinate, values []T) error {
+	if len(coords) != len(values) {
+		return ErrFormat(fmt.Sprintf("%d values given for %d coordinates", len(values), len(coords)))
+	}
+	layer := modifier.Layer()
+	channelIndex := layer.Channels.Index(channel)
+	if channelIndex < 0 {
+		return ErrChannelNotFound{ChannelName: channel}
+	}
+	order := modifier.Header().ByteOrder
+	c := layer.Channels[channelIndex]
+	// decodes a value as it is stored unpacked, for handing single values to Channel methods
+	boxed := func(v T) any {
+		raw := make([]byte, c.Size())
+		encodeNumber[T](c.Type, order, false)(raw, 0, v)
+		return c.Value(raw, order)
+	}
+	if rule, ok := layer.NonFinite[channel]; ok && rule.Policy != NonFiniteAllow {
+		for i, coord := range coords {
+			if err := SetChannelAt(modifier, coord, channelIndex, boxed(values[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	encode := encodeNumber[T](c.Type, order, layer.Separated)
+	lowest, highest, seen := T(0), T(0), false
+	dirty := -1
+	for i, coord := range coords {
+		if !layer.Dimensions.ContainsCoordinate(coord) {
+			return ErrSampleCoordinateOutOfBounds{Coordinate: coord, Dimensions: layer.Dimensions}
+		}
+		tile, offset := layer.channelLocation(coord, channelIndex)
+		data, err := modifier.Tile(tile)
+		if err != nil {
+			return err
+		}
+		v := values[i]
+		encode(data, offset, v)
+		if tile != dirty {
+			modifier.SetDirty(tile)
+			dirty = tile
+		}
+		if v != v { // NaN
+			continue
+		}
+		if !seen || v < lowest {
+			lowest = v
+		}
+		if !seen || v > highest {
+			highest = v
+		}
+		seen = true
+	}
+	if seen {
+		layer.Channels[channelIndex] = layer.Channels[channelIndex].WithMinMax(boxed(lowest)).WithMinMax(boxed(highest))
+	}
+	layer.Channels[channelIndex].clearDistribution()
+	return nil
+}
+
+// The disk tile holding the value of the channel of the sample at the coordinate, and the byte offset of the
+// value within the tile, or its bit index for the bit packed boolean channels of separated layers.
+func (l Layer) channelLocation(coord SampleCoordinate, channelIndex int) (int, int) {
+	selector := coord.ToTileSelector(l.Dimensions)
+	if l.Separated {
+		tile := selector.Tile + l.Dimensions.Tiles()*channelIndex
+		if l.Channels[channelIndex].Type == ChannelBool {
+			return tile, selector.InTile
+		}
+		return tile, selector.InTile * l.Channels[channelIndex].Size()
+	}
+	return selector.Tile, selector.InTile*l.Channels.Size() + l.Channels.Offset(channelIndex)
+}
+
+// Returns a function decoding a value of the channel type at an offset of tile data as a T, with booleans bit
+// packed if the layer is separated.
+func decodeNumber[T Number](t ChannelType, order binary.ByteOrder, separated bool) func([]byte, int) T {
+	switch t.Base() {
+	case ChannelInt8:
+		return func(data []byte, offset int) T { return T(int8(data[offset])) }
+	case ChannelUint8:
+		return func(data []byte, offset int) T { return T(data[offset]) }
+	case ChannelInt16:
+		return func(data []byte, offset int) T { return T(int16(order.Uint16(data[offset:]))) }
+	case ChannelUint16:
+		return func(data []byte, offset int) T { return T(order.Uint16(data[offset:])) }
+	case ChannelInt32:
+		return func(data []byte, offset int) T { return T(int32(order.Uint32(data[offset:]))) }
+	case ChannelUint32:
+		return func(data []byte, offset int) T { return T(order.Uint32(data[offset:])) }
+	case ChannelInt64:
+		return func(data []byte, offset int) T { return T(int64(order.Uint64(data[offset:]))) }
+	case ChannelUint64:
+		return func(data []byte, offset int) T { return T(order.Uint64(data[offset:])) }
+	case ChannelFloat32:
+		return func(data []byte, offset int) T { return T(math.Float32frombits(order.Uint32(data[offset:]))) }
+	case ChannelFloat64:
+		return func(data []byte, offset int) T { return T(math.Float64frombits(order.Uint64(data[offset:]))) }
+	case ChannelBool:
+		return func(data []byte, offset int) T {
+			if (separated && UnpackBool(data, offset)) || (!separated && data[offset] != 0) {
+				return 1
+			}
+			return 0
+		}
+	default:
+		return func(data []byte, offset int) T {
+			f, _ := t.ToFloat64(t.Value(data[offset:], order))
+			return T(f)
+		}
+	}
+}
+
+// Returns a function encoding a T as a value of the channel type at an offset of tile data, with booleans
+// bit packed if the layer is separated.
+func encodeNumber[T Number](t ChannelType, order binary.ByteOrder, separated bool) func([]byte, int, T) {
+	switch t.Base() {
+	case ChannelInt8:
+		return func(data []byte, offset int, v T) { data[offset] = byte(int8(v)) }
+	case ChannelUint8:
+		return func(data []byte, offset int, v T) { data[offset] = uint8(v) }
+	case ChannelInt16:
+		return func(data []byte, offset int, v T) { order.PutUint16(data[offset:], uint16(int16(v))) }
+	case ChannelUint16:
+		return func(data []byte, offset int, v T) { order.PutUint16(data[offset:], uint16(v)) }
+	case ChannelInt32:
+		return func(data []byte, offset int, v T) { order.PutUint32(data[offset:], uint32(int32(v))) }
+	case ChannelUint32:
+		return func(data []byte, offset int, v T) { order.PutUint32(data[offset:], uint32(v)) }
+	case ChannelInt64:
+		return func(data []byte, offset int, v T) { order.PutUint64(data[offset:], uint64(int64(v))) }
+	case ChannelUint64:
+		return func(data []byte, offset int, v T) { order.PutUint64(data[offset:], uint64(v)) }
+	case ChannelFloat32:
+		return func(data []byte, offset int, v T) { order.PutUint32(data[offset:], math.Float32bits(float32(v))) }
+	case ChannelFloat64:
+		return func(data []byte, offset int, v T) { order.PutUint64(data[offset:], math.Float64bits(float64(v))) }
+	case ChannelBool:
+		return func(data []byte, offset int, v T) {
+			if separated {
+				PackBool(v != 0, data, offset)
+			} else {
+				t.PutValue(v != 0, order, data[offset:])
+			}
+		}
+	default:
+		return func(data []byte, offset int, v T) { t.PutValue(t.FromFloat64(float64(v)), order, data[offset:]) }
+	}
+}
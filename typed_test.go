@@ -0,0 +1,156 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+	"github.com/x448/float16"
+)
+
+func TestWriteReadSamples(t *testing.T) {
+	type kelvin float32
+	channels := ChannelSet{
+		{Name: "temperature", Type: ChannelFloat32},
+		{Name: "count", Type: ChannelInt16},
+		{Name: "valid", Type: ChannelBool},
+		{Name: "half", Type: ChannelFloat16},
+	}
+	dims := DimensionSet{{Name: "x", Size: 5, TileSize: 2}, {Name: "y", Size: 3, TileSize: 2}}
+	coords := []SampleCoordinate{}
+	for coord := range dims.SampleCoordinates() {
+		coords = append(coords, slices.Clone(coord))
+	}
+	temperatures, counts, valid, halves := []kelvin{}, []int32{}, []uint8{}, []float64{}
+	for i := range coords {
+		temperatures = append(temperatures, kelvin(250+float32(i)/4))
+		counts = append(counts, int32(-3*i))
+		valid = append(valid, uint8(i%2))
+		halves = append(halves, float64(i)/2)
+	}
+
+	for _, opts := range [][]LayerOption{nil, {WithPlanar()}} {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			header := NewHeader(order, OffsetSize4)
+			layer := NewLayer("typed", dims, append(ChannelSet{}, channels...), opts...)
+			modifier := NewMemoryLayer(buffer.NewBuffer(10), header, layer)
+			if err := WriteSamples(modifier, "temperature", coords, temperatures); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteSamples(modifier, "count", coords, counts); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteSamples(modifier, "valid", coords, valid); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteSamples(modifier, "half", coords, halves); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, coord := range coords {
+				sample, err := SampleAt(modifier, coord)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := Sample{float32(temperatures[i]), int16(counts[i]), valid[i] == 1, float16.Fromfloat32(float32(halves[i]))}
+				for c := range want {
+					if sample[c] != want[c] {
+						t.Fatalf("separated %t, %v: sample %v channel %d is %v, expected %v", layer.Separated, order, coord, c, sample[c], want[c])
+					}
+				}
+			}
+			if min, max := modifier.Layer().Channels[1].Min, modifier.Layer().Channels[1].Max; min != int16(counts[len(counts)-1]) || max != int16(0) {
+				t.Errorf("expected count statistics %d to 0, got %v to %v", counts[len(counts)-1], min, max)
+			}
+
+			// values are converted to the requested type
+			read, err := ReadSamples[float64](modifier, "temperature", coords...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counted, err := ReadSamples[int64](modifier, "count", coords...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			flags, err := ReadSamples[float32](modifier, "valid", coords...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			halved, err := ReadSamples[float64](modifier, "half", coords...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range coords {
+				if read[i] != float64(temperatures[i]) || counted[i] != int64(counts[i]) || flags[i] != float32(valid[i]) || halved[i] != halves[i] {
+					t.Fatalf("sample %d read as %v, %v, %v, %v", i, read[i], counted[i], flags[i], halved[i])
+				}
+			}
+		}
+	}
+}
+
+func TestReadSamplesAllocations(t *testing.T) {
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 64, TileSize: 16}}, ChannelSet{{Name: "v", Type: ChannelFloat64}})
+	modifier := NewMemoryLayer(buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize4), layer)
+	coords := []SampleCoordinate{}
+	for coord := range layer.Dimensions.SampleCoordinates() {
+		coords = append(coords, slices.Clone(coord))
+	}
+	values := make([]float64, len(coords))
+	for i := range values {
+		values[i] = math.Sqrt(float64(i))
+	}
+	if err := WriteSamples(modifier, "v", coords, values); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := ReadSamples[float64](modifier, "v", coords...); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 2 { // the result and its decoder
+		t.Errorf("expected allocations independent of the number of samples, got %v for %d samples", allocs, len(coords))
+	}
+}
+
+func TestWriteReadSamplesErrors(t *testing.T) {
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelUint8}})
+	modifier := NewMemoryLayer(buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize4), layer)
+	var notFound ErrChannelNotFound
+	if _, err := ReadSamples[uint8](modifier, "w", SampleCoordinate{0}); !errors.As(err, &notFound) {
+		t.Errorf("expected ErrChannelNotFound, got %v", err)
+	}
+	if err := WriteSamples(modifier, "w", []SampleCoordinate{{0}}, []uint8{1}); !errors.As(err, &notFound) {
+		t.Errorf("expected ErrChannelNotFound, got %v", err)
+	}
+	var outOfBounds ErrSampleCoordinateOutOfBounds
+	if _, err := ReadSamples[uint8](modifier, "v", SampleCoordinate{4}); !errors.As(err, &outOfBounds) {
+		t.Errorf("expected ErrSampleCoordinateOutOfBounds, got %v", err)
+	}
+	if err := WriteSamples(modifier, "v", []SampleCoordinate{{-1}}, []uint8{1}); !errors.As(err, &outOfBounds) {
+		t.Errorf("expected ErrSampleCoordinateOutOfBounds, got %v", err)
+	}
+	if err := WriteSamples(modifier, "v", []SampleCoordinate{{0}, {1}}, []uint8{1}); err == nil {
+		t.Error("expected error for mismatched coordinates and values")
+	}
+}
+
+func TestWriteSamplesNonFinite(t *testing.T) {
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 4, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelFloat32}},
+		WithNonFinite("v", NonFiniteFill, -1))
+	modifier := NewMemoryLayer(buffer.NewBuffer(10), NewHeader(binary.LittleEndian, OffsetSize4), layer)
+	coords := []SampleCoordinate{{0}, {1}, {2}, {3}}
+	if err := WriteSamples(modifier, "v", coords, []float64{1, math.NaN(), math.Inf(1), 4}); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadSamples[float32](modifier, "v", coords...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read[0] != 1 || read[1] != -1 || read[2] != -1 || read[3] != 4 {
+		t.Errorf("expected non-finite values to be filled, got %v", read)
+	}
+}
@@ -0,0 +1,232 @@
+package gopixi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A unit resolved into SI base units, as parsed by ParseUnit from UDUNITS-style unit strings. A value v
+// in this unit corresponds to v*Scale + Offset in the base units described by Terms. Time units written
+// with a reference epoch ("hours since 2000-01-01") also carry that epoch in Since.
+type Unit struct {
+	Scale  float64    // The factor converting values in this unit to the base units.
+	Offset float64    // The offset added after scaling, for units with a shifted origin such as degC.
+	Terms  UnitTerms  // The base units, using SI symbols for recognized units and the written symbol otherwise.
+	Since  *time.Time // The reference epoch for time units written with "since", or nil.
+}
+
+type unitDef struct {
+	scale  float64
+	offset float64
+	terms  string
+}
+
+// The recognized unit names and symbols, defined in terms of the SI base units m, kg, s, A, K, mol, cd,
+// and rad, along with the CF convention's directional degrees, which are kept as base units of their own.
+var unitDefs = map[string]unitDef{
+	"m": {1, 0, "m"}, "meter": {1, 0, "m"}, "metre": {1, 0, "m"},
+	"g": {1e-3, 0, "kg"}, "gram": {1e-3, 0, "kg"},
+	"s": {1, 0, "s"}, "sec": {1, 0, "s"}, "second": {1, 0, "s"},
+	"A": {1, 0, "A"}, "ampere": {1, 0, "A"},
+	"K": {1, 0, "K"}, "kelvin": {1, 0, "K"},
+	"mol": {1, 0, "mol"}, "mole": {1, 0, "mol"},
+	"cd": {1, 0, "cd"}, "candela": {1, 0, "cd"},
+	"rad": {1, 0, "rad"}, "radian": {1, 0, "rad"},
+	"sr": {1, 0, "rad2"}, "steradian": {1, 0, "rad2"},
+
+	"min": {60, 0, "s"}, "minute": {60, 0, "s"},
+	"h": {3600, 0, "s"}, "hr": {3600, 0, "s"}, "hour": {3600, 0, "s"},
+	"d": {86400, 0, "s"}, "day": {86400, 0, "s"},
+	"week": {604800, 0, "s"}, "yr": {3.15569259747e7, 0, "s"}, "year": {3.15569259747e7, 0, "s"},
+	"Hz": {1, 0, "s-1"}, "hertz": {1, 0, "s-1"},
+
+	"N": {1, 0, "kg m s-2"}, "newton": {1, 0, "kg m s-2"},
+	"Pa": {1, 0, "kg m-1 s-2"}, "pascal": {1, 0, "kg m-1 s-2"},
+	"bar": {1e5, 0, "kg m-1 s-2"}, "atm": {101325, 0, "kg m-1 s-2"},
+	"J": {1, 0, "kg m2 s-2"}, "joule": {1, 0, "kg m2 s-2"},
+	"W": {1, 0, "kg m2 s-3"}, "watt": {1, 0, "kg m2 s-3"},
+	"C": {1, 0, "A s"}, "coulomb": {1, 0, "A s"},
+	"V": {1, 0, "kg m2 s-3 A-1"}, "volt": {1, 0, "kg m2 s-3 A-1"}, "ohm": {1, 0, "kg m2 s-3 A-2"},
+	"L": {1e-3, 0, "m3"}, "l": {1e-3, 0, "m3"}, "liter": {1e-3, 0, "m3"}, "litre": {1e-3, 0, "m3"},
+	"t": {1e3, 0, "kg"}, "tonne": {1e3, 0, "kg"},
+	"inch": {0.0254, 0, "m"}, "in": {0.0254, 0, "m"},
+	"foot": {0.3048, 0, "m"}, "ft": {0.3048, 0, "m"}, "mile": {1609.344, 0, "m"},
+
+	"degC": {1, 273.15, "K"}, "celsius": {1, 273.15, "K"}, "degree_Celsius": {1, 273.15, "K"}, "°C": {1, 273.15, "K"},
+	"degF": {5.0 / 9.0, 459.67 * 5.0 / 9.0, "K"}, "fahrenheit": {5.0 / 9.0, 459.67 * 5.0 / 9.0, "K"}, "°F": {5.0 / 9.0, 459.67 * 5.0 / 9.0, "K"},
+	"degree": {math.Pi / 180, 0, "rad"}, "deg": {math.Pi / 180, 0, "rad"}, "arc_degree": {math.Pi / 180, 0, "rad"}, "°": {math.Pi / 180, 0, "rad"},
+
+	"degrees_north": {1, 0, "degrees_north"}, "degree_north": {1, 0, "degrees_north"}, "degree_N": {1, 0, "degrees_north"}, "degreeN": {1, 0, "degrees_north"}, "degrees_N": {1, 0, "degrees_north"},
+	"degrees_east": {1, 0, "degrees_east"}, "degree_east": {1, 0, "degrees_east"}, "degree_E": {1, 0, "degrees_east"}, "degreeE": {1, 0, "degrees_east"}, "degrees_E": {1, 0, "degrees_east"},
+
+	"percent": {1e-2, 0, "1"}, "%": {1e-2, 0, "1"},
+	"ppm": {1e-6, 0, "1"}, "ppb": {1e-9, 0, "1"},
+}
+
+// SI prefixes by symbol and by name, tried when a symbol is not itself a recognized unit.
+var unitPrefixes = []struct {
+	prefix string
+	scale  float64
+}{
+	{"yotta", 1e24}, {"zetta", 1e21}, {"exa", 1e18}, {"peta", 1e15}, {"tera", 1e12}, {"giga", 1e9},
+	{"mega", 1e6}, {"kilo", 1e3}, {"hecto", 1e2}, {"deka", 1e1}, {"deci", 1e-1}, {"centi", 1e-2},
+	{"milli", 1e-3}, {"micro", 1e-6}, {"nano", 1e-9}, {"pico", 1e-12}, {"femto", 1e-15}, {"atto", 1e-18},
+	{"zepto", 1e-21}, {"yocto", 1e-24},
+	{"da", 1e1}, {"Y", 1e24}, {"Z", 1e21}, {"E", 1e18}, {"P", 1e15}, {"T", 1e12}, {"G", 1e9}, {"M", 1e6},
+	{"k", 1e3}, {"h", 1e2}, {"d", 1e-1}, {"c", 1e-2}, {"m", 1e-3}, {"u", 1e-6}, {"µ", 1e-6}, {"μ", 1e-6},
+	{"n", 1e-9}, {"p", 1e-12}, {"f", 1e-15}, {"a", 1e-18}, {"z", 1e-21}, {"y", 1e-24},
+}
+
+// The layouts accepted for the reference epoch of "since" time units, after any trailing "UTC" is removed.
+var unitEpochLayouts = []string{
+	"2006-1-2T15:4:5Z07:00",
+	"2006-1-2 15:4:5Z07:00",
+	"2006-1-2 15:4:5 Z07:00",
+	"2006-1-2T15:4:5",
+	"2006-1-2 15:4:5",
+	"2006-1-2 15:4",
+	"2006-1-2",
+}
+
+// Parses a UDUNITS-style unit string (as used by the CF conventions and NetCDF) into its scale and offset
+// relative to SI base units, including SI prefixes, plural names, and time units with a reference epoch.
+// Symbols that are not recognized are kept as base units of their own. The empty string parses to a
+// dimensionless unit.
+func ParseUnit(unit string) (Unit, error) {
+	product, epoch, hasEpoch := strings.Cut(unit, " since ")
+	scale, terms, err := parseUnitProduct(product)
+	if err != nil {
+		return Unit{}, err
+	}
+
+	parsed := Unit{Scale: scale, Terms: UnitTerms{}}
+	hasOffset := false
+	for _, term := range terms {
+		def := resolveUnitSymbol(term.Symbol)
+		defScale, defTerms, err := parseUnitProduct(def.terms)
+		if err != nil {
+			return Unit{}, err
+		}
+		parsed.Scale *= math.Pow(def.scale*defScale, float64(term.Power))
+		parsed.Terms = parsed.Terms.Multiply(defTerms.Pow(term.Power))
+		if def.offset != 0 {
+			hasOffset = true
+			parsed.Offset = def.offset
+		}
+	}
+	// offsets only apply to a lone unit with a shifted origin; in products (such as "degC s-1") they
+	// describe intervals, which are only scaled
+	if hasOffset && !(len(terms) == 1 && terms[0].Power == 1) {
+		parsed.Offset = 0
+	}
+
+	if hasEpoch {
+		if len(parsed.Terms) != 1 || parsed.Terms[0] != (UnitTerm{Symbol: "s", Power: 1}) {
+			return Unit{}, ErrFormat(fmt.Sprintf("unit '%s' has a reference epoch but is not a time unit", unit))
+		}
+		since, err := parseUnitEpoch(epoch)
+		if err != nil {
+			return Unit{}, err
+		}
+		parsed.Since = &since
+	}
+	return parsed, nil
+}
+
+// Resolves a single symbol into its definition, trying exact matches, then plural names, then SI
+// prefixes of recognized units. Unrecognized symbols are defined as themselves.
+func resolveUnitSymbol(symbol string) unitDef {
+	if def, ok := lookupUnitName(symbol); ok {
+		return def
+	}
+	for _, p := range unitPrefixes {
+		if rest, ok := strings.CutPrefix(symbol, p.prefix); ok {
+			if def, ok := lookupUnitName(rest); ok {
+				def.scale *= p.scale
+				return def
+			}
+		}
+	}
+	return unitDef{scale: 1, terms: symbol}
+}
+
+// Looks up a unit by its exact symbol or name, or by the plural of its name.
+func lookupUnitName(name string) (unitDef, bool) {
+	if def, ok := unitDefs[name]; ok {
+		return def, true
+	}
+	for _, suffix := range []string{"s", "es"} {
+		if singular, ok := strings.CutSuffix(name, suffix); ok && len(singular) > 1 {
+			if def, ok := unitDefs[singular]; ok {
+				return def, true
+			}
+		}
+	}
+	return unitDef{}, false
+}
+
+func parseUnitEpoch(epoch string) (time.Time, error) {
+	epoch = strings.TrimSpace(epoch)
+	epoch = strings.TrimSpace(strings.TrimSuffix(epoch, "UTC"))
+	for _, layout := range unitEpochLayouts {
+		if t, err := time.Parse(layout, epoch); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, ErrFormat(fmt.Sprintf("invalid reference epoch '%s' in time unit", epoch))
+}
+
+// Returns true if values in this unit can be converted to the other unit, which requires both to have
+// the same base units.
+func (u Unit) ConvertibleTo(other Unit) bool {
+	return len(u.Terms.Multiply(other.Terms.Pow(-1))) == 0 && (u.Since == nil) == (other.Since == nil)
+}
+
+// Returns a function converting values in this unit to values in the other unit, or an error if the
+// units are not convertible. For time units with reference epochs, the difference between the epochs
+// is accounted for, so that the converted value refers to the same instant.
+func (u Unit) ConverterTo(other Unit) (func(float64) float64, error) {
+	if !u.ConvertibleTo(other) {
+		return nil, ErrFormat(fmt.Sprintf("cannot convert from '%s' to '%s'", u, other))
+	}
+	offset := u.Offset - other.Offset
+	if u.Since != nil {
+		offset += u.Since.Sub(*other.Since).Seconds()
+	}
+	scale := u.Scale / other.Scale
+	offset /= other.Scale
+	return func(v float64) float64 {
+		return v*scale + offset
+	}, nil
+}
+
+// Returns the instant that a value in a time unit with a reference epoch refers to. Returns an error if
+// the unit has no reference epoch.
+func (u Unit) Time(v float64) (time.Time, error) {
+	if u.Since == nil {
+		return time.Time{}, ErrFormat("unit has no reference epoch")
+	}
+	seconds := v*u.Scale + u.Offset
+	return u.Since.Add(time.Duration(math.Round(seconds * float64(time.Second)))), nil
+}
+
+// Formats the unit in terms of its base units, including any scale, offset, and reference epoch.
+func (u Unit) String() string {
+	var b strings.Builder
+	if u.Scale != 1 {
+		b.WriteString(strconv.FormatFloat(u.Scale, 'g', -1, 64))
+		b.WriteString(" ")
+	}
+	b.WriteString(u.Terms.String())
+	if u.Offset != 0 {
+		fmt.Fprintf(&b, " @ %s", strconv.FormatFloat(u.Offset, 'g', -1, 64))
+	}
+	if u.Since != nil {
+		b.WriteString(" since ")
+		b.WriteString(u.Since.Format(time.RFC3339Nano))
+	}
+	return b.String()
+}
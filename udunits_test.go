@@ -0,0 +1,120 @@
+package gopixi
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseUnit(t *testing.T) {
+	cases := []struct {
+		unit   string
+		scale  float64
+		offset float64
+		terms  string
+	}{
+		{"m s-1", 1, 0, "m s-1"},
+		{"km/h", 1000.0 / 3600.0, 0, "m s-1"},
+		{"kilometers/hours", 1000.0 / 3600.0, 0, "m s-1"},
+		{"mm", 1e-3, 0, "m"},
+		{"hPa", 100, 0, "kg m-1 s-2"},
+		{"W m-2", 1, 0, "kg s-3"},
+		{"g/kg", 1e-3, 0, "1"},
+		{"0.01 m", 0.01, 0, "m"},
+		{"10^3 g", 1, 0, "kg"},
+		{"degC", 1, 273.15, "K"},
+		{"degC/s", 1, 0, "K s-1"},
+		{"degrees", math.Pi / 180, 0, "rad"},
+		{"degrees_north", 1, 0, "degrees_north"},
+		{"degree_N", 1, 0, "degrees_north"},
+		{"%", 0.01, 0, "1"},
+		{"counts", 1, 0, "counts"},
+		{"", 1, 0, "1"},
+	}
+	for _, c := range cases {
+		unit, err := ParseUnit(c.unit)
+		if err != nil {
+			t.Fatalf("parsing '%s': %v", c.unit, err)
+		}
+		if math.Abs(unit.Scale-c.scale) > 1e-12*c.scale || math.Abs(unit.Offset-c.offset) > 1e-9 || unit.Terms.String() != c.terms {
+			t.Errorf("expected '%s' to parse to %v %s @ %v, got %s", c.unit, c.scale, c.terms, c.offset, unit)
+		}
+	}
+}
+
+func TestParseTimeUnitSince(t *testing.T) {
+	epoch := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, unit := range []string{
+		"hours since 2000-01-01T00:00:00Z",
+		"hours since 2000-01-01 00:00:00",
+		"hours since 2000-1-1 0:0:0 UTC",
+		"hours since 2000-01-01",
+	} {
+		parsed, err := ParseUnit(unit)
+		if err != nil {
+			t.Fatalf("parsing '%s': %v", unit, err)
+		}
+		if parsed.Since == nil || !parsed.Since.Equal(epoch) || parsed.Scale != 3600 {
+			t.Errorf("expected '%s' to be hours since %v, got %s", unit, epoch, parsed)
+		}
+		instant, err := parsed.Time(36)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !instant.Equal(epoch.Add(36 * time.Hour)) {
+			t.Errorf("expected 36 hours after epoch, got %v", instant)
+		}
+	}
+
+	if _, err := ParseUnit("m since 2000-01-01"); err == nil {
+		t.Error("expected error for non-time unit with reference epoch")
+	}
+	if _, err := ParseUnit("days since yesterday"); err == nil {
+		t.Error("expected error for invalid reference epoch")
+	}
+}
+
+func TestUnitConversion(t *testing.T) {
+	convert := func(from, to string, v float64) float64 {
+		t.Helper()
+		fromUnit, err := ParseUnit(from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		toUnit, err := ParseUnit(to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converter, err := fromUnit.ConverterTo(toUnit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return converter(v)
+	}
+
+	cases := []struct {
+		from, to    string
+		value, want float64
+	}{
+		{"km", "m", 2.5, 2500},
+		{"degC", "K", 0, 273.15},
+		{"degF", "degC", 212, 100},
+		{"m/s", "km/h", 10, 36},
+		{"days since 2000-01-02", "hours since 2000-01-01", 1, 48},
+		{"degrees", "rad", 180, math.Pi},
+	}
+	for _, c := range cases {
+		if got := convert(c.from, c.to, c.value); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("expected %v %s to be %v %s, got %v", c.value, c.from, c.want, c.to, got)
+		}
+	}
+
+	m, _ := ParseUnit("m")
+	s, _ := ParseUnit("s")
+	if m.ConvertibleTo(s) {
+		t.Error("expected meters to not be convertible to seconds")
+	}
+	if _, err := m.ConverterTo(s); err == nil {
+		t.Error("expected error converting meters to seconds")
+	}
+}
@@ -0,0 +1,257 @@
+package gopixi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The unit of dimensionless quantities, such as ratios of values with the same unit.
+const UnitDimensionless string = "1"
+
+// A single symbol raised to an integer power in a product of units, such as the "m-2" in "W m-2".
+type UnitTerm struct {
+	Symbol string
+	Power  int
+}
+
+// A unit written as a product of symbols raised to integer powers, kept in the order in which the
+// symbols first appeared so that derived units read naturally (e.g., "W m-2 s-1").
+type UnitTerms []UnitTerm
+
+var unitSuperscripts = strings.NewReplacer(
+	"⁻", "-", "⁺", "+", "⁰", "0", "¹", "1", "²", "2", "³", "3", "⁴", "4",
+	"⁵", "5", "⁶", "6", "⁷", "7", "⁸", "8", "⁹", "9",
+)
+
+// Parses a unit written as a product of terms separated by spaces, '.', '*', or '·', with '/' dividing by
+// the term that follows it and powers written as "m2", "m^2", "m**2", or "m²". Symbols are kept as written;
+// use ParseUnit to resolve them into base units.
+func ParseUnitTerms(unit string) (UnitTerms, error) {
+	scale, terms, err := parseUnitProduct(unit)
+	if err != nil {
+		return nil, err
+	}
+	if scale != 1 {
+		return nil, ErrFormat(fmt.Sprintf("unit '%s' has a numeric scale factor", unit))
+	}
+	return terms, nil
+}
+
+// Parses a product of unit terms as in ParseUnitTerms, additionally allowing numeric scale factors
+// (such as the "0.01" in "0.01 m" or the "10^3" in "10^3 g"), which are multiplied into the returned scale.
+func parseUnitProduct(unit string) (float64, UnitTerms, error) {
+	unit = unitSuperscripts.Replace(unit)
+	unit = strings.ReplaceAll(unit, "**", "^")
+	unit = strings.NewReplacer("/", " / ", "*", " ", "·", " ").Replace(unit)
+
+	scale := 1.0
+	terms := UnitTerms{}
+	invert := false
+	for _, field := range unitFields(unit) {
+		if field == "/" {
+			if invert {
+				return 0, nil, ErrFormat(fmt.Sprintf("unit '%s' has consecutive divisions", unit))
+			}
+			invert = true
+			continue
+		}
+		if factor, ok := parseUnitFactor(field); ok {
+			if invert {
+				factor = 1 / factor
+				invert = false
+			}
+			scale *= factor
+			continue
+		}
+		term, err := parseUnitTerm(field)
+		if err != nil {
+			return 0, nil, err
+		}
+		if invert {
+			term.Power = -term.Power
+			invert = false
+		}
+		terms = terms.with(term)
+	}
+	if invert {
+		return 0, nil, ErrFormat(fmt.Sprintf("unit '%s' ends with a division", unit))
+	}
+	return scale, terms, nil
+}
+
+// Splits a unit string into fields on whitespace and on periods used as multiplication, leaving the
+// decimal points of numeric scale factors intact.
+func unitFields(unit string) []string {
+	runes := []rune(unit)
+	for i, r := range runes {
+		if r == '.' && !(i > 0 && unicode.IsDigit(runes[i-1]) && i+1 < len(runes) && unicode.IsDigit(runes[i+1])) {
+			runes[i] = ' '
+		}
+	}
+	return strings.Fields(string(runes))
+}
+
+// Parses a numeric scale factor, optionally raised to an integer power ("10^3").
+func parseUnitFactor(field string) (float64, bool) {
+	base, power, hasPower := strings.Cut(field, "^")
+	factor, err := strconv.ParseFloat(base, 64)
+	if err != nil {
+		return 0, false
+	}
+	if hasPower {
+		exp, err := strconv.Atoi(power)
+		if err != nil {
+			return 0, false
+		}
+		factor = math.Pow(factor, float64(exp))
+	}
+	return factor, true
+}
+
+func parseUnitTerm(field string) (UnitTerm, error) {
+	end := strings.IndexFunc(field, func(r rune) bool {
+		return r == '^' || r == '-' || r == '+' || unicode.IsDigit(r)
+	})
+	if end == 0 {
+		return UnitTerm{}, ErrFormat(fmt.Sprintf("unit term '%s' does not start with a symbol", field))
+	}
+	if end < 0 {
+		return UnitTerm{Symbol: field, Power: 1}, nil
+	}
+	power, err := strconv.Atoi(strings.TrimPrefix(field[end:], "^"))
+	if err != nil {
+		return UnitTerm{}, ErrFormat(fmt.Sprintf("unit term '%s' has an invalid power", field))
+	}
+	return UnitTerm{Symbol: field[:end], Power: power}, nil
+}
+
+// Returns a copy of the terms with the power of the given term added, dropping symbols whose power
+// becomes zero.
+func (u UnitTerms) with(term UnitTerm) UnitTerms {
+	result := make(UnitTerms, 0, len(u)+1)
+	found := false
+	for _, existing := range u {
+		if existing.Symbol == term.Symbol {
+			existing.Power += term.Power
+			found = true
+		}
+		if existing.Power != 0 {
+			result = append(result, existing)
+		}
+	}
+	if !found && term.Power != 0 {
+		result = append(result, term)
+	}
+	return result
+}
+
+// Returns the product of the two units.
+func (u UnitTerms) Multiply(other UnitTerms) UnitTerms {
+	result := u
+	for _, term := range other {
+		result = result.with(term)
+	}
+	return result
+}
+
+// Returns the unit raised to the given integer power.
+func (u UnitTerms) Pow(power int) UnitTerms {
+	result := make(UnitTerms, 0, len(u))
+	for _, term := range u {
+		if term.Power*power != 0 {
+			result = append(result, UnitTerm{Symbol: term.Symbol, Power: term.Power * power})
+		}
+	}
+	return result
+}
+
+// Formats the unit in UDUNITS style, with terms separated by spaces and powers other than one written
+// directly after the symbol (e.g., "W m-2"). An empty product is written as UnitDimensionless.
+func (u UnitTerms) String() string {
+	if len(u) == 0 {
+		return UnitDimensionless
+	}
+	parts := make([]string, len(u))
+	for i, term := range u {
+		if term.Power == 1 {
+			parts[i] = term.Symbol
+		} else {
+			parts[i] = term.Symbol + strconv.Itoa(term.Power)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Derives the unit of the product of values with units a and b. If either unit is unspecified (the
+// empty string), the derived unit is also unspecified.
+func MultiplyUnits(a string, b string) (string, error) {
+	if a == "" || b == "" {
+		return "", nil
+	}
+	aTerms, err := ParseUnitTerms(a)
+	if err != nil {
+		return "", err
+	}
+	bTerms, err := ParseUnitTerms(b)
+	if err != nil {
+		return "", err
+	}
+	return aTerms.Multiply(bTerms).String(), nil
+}
+
+// Derives the unit of the quotient of values with unit a by values with unit b (e.g., "W m-2" divided
+// by "s" gives "W m-2 s-1"). If either unit is unspecified (the empty string), the derived unit is also
+// unspecified.
+func DivideUnits(a string, b string) (string, error) {
+	if a == "" || b == "" {
+		return "", nil
+	}
+	aTerms, err := ParseUnitTerms(a)
+	if err != nil {
+		return "", err
+	}
+	bTerms, err := ParseUnitTerms(b)
+	if err != nil {
+		return "", err
+	}
+	return aTerms.Multiply(bTerms.Pow(-1)).String(), nil
+}
+
+// Derives the unit of values with the given unit raised to an integer power. An unspecified unit
+// remains unspecified.
+func PowUnit(unit string, power int) (string, error) {
+	if unit == "" {
+		return "", nil
+	}
+	terms, err := ParseUnitTerms(unit)
+	if err != nil {
+		return "", err
+	}
+	return terms.Pow(power).String(), nil
+}
+
+// Derives the unit of the sum or difference of values with units a and b, which is only defined when
+// both have the same unit. If either unit is unspecified, the other unit is used.
+func AddUnits(a string, b string) (string, error) {
+	if a == "" {
+		return b, nil
+	}
+	if b == "" {
+		return a, nil
+	}
+	aTerms, err := ParseUnitTerms(a)
+	if err != nil {
+		return "", err
+	}
+	bTerms, err := ParseUnitTerms(b)
+	if err != nil {
+		return "", err
+	}
+	if len(aTerms.Multiply(bTerms.Pow(-1))) != 0 {
+		return "", ErrFormat(fmt.Sprintf("cannot add values with incompatible units '%s' and '%s'", a, b))
+	}
+	return a, nil
+}
@@ -0,0 +1,85 @@
+package gopixi
+
+import "testing"
+
+func TestParseUnitTerms(t *testing.T) {
+	cases := []struct {
+		unit     string
+		expected string
+	}{
+		{"m s-1", "m s-1"},
+		{"m/s", "m s-1"},
+		{"m·s⁻¹", "m s-1"},
+		{"W/m^2", "W m-2"},
+		{"W m**-2", "W m-2"},
+		{"W m²", "W m2"},
+		{"kg.m2.s-2", "kg m2 s-2"},
+		{"kg/m/s", "kg m-1 s-1"},
+		{"m m-1", UnitDimensionless},
+		{"1", UnitDimensionless},
+		{"", UnitDimensionless},
+		{"degrees_north", "degrees_north"},
+	}
+	for _, c := range cases {
+		terms, err := ParseUnitTerms(c.unit)
+		if err != nil {
+			t.Fatalf("parsing '%s': %v", c.unit, err)
+		}
+		if terms.String() != c.expected {
+			t.Errorf("expected '%s' to parse to '%s', got '%s'", c.unit, c.expected, terms.String())
+		}
+	}
+
+	for _, invalid := range []string{"m/", "m//s", "2m", "m^x"} {
+		if _, err := ParseUnitTerms(invalid); err == nil {
+			t.Errorf("expected error parsing '%s'", invalid)
+		}
+	}
+}
+
+func TestDeriveUnits(t *testing.T) {
+	unit, err := DivideUnits("W m-2", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit != "W m-2 s-1" {
+		t.Errorf("expected 'W m-2 s-1', got '%s'", unit)
+	}
+
+	unit, err = MultiplyUnits("W m-2 s-1", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit != "W m-2" {
+		t.Errorf("expected 'W m-2', got '%s'", unit)
+	}
+
+	unit, err = DivideUnits("K", "K")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit != UnitDimensionless {
+		t.Errorf("expected dimensionless ratio, got '%s'", unit)
+	}
+
+	unit, err = PowUnit("m s-1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit != "m2 s-2" {
+		t.Errorf("expected 'm2 s-2', got '%s'", unit)
+	}
+
+	unit, err = MultiplyUnits("", "s")
+	if err != nil || unit != "" {
+		t.Errorf("expected unspecified unit to stay unspecified, got '%s', %v", unit, err)
+	}
+
+	unit, err = AddUnits("m s-1", "s-1 m")
+	if err != nil || unit != "m s-1" {
+		t.Errorf("expected compatible units to add, got '%s', %v", unit, err)
+	}
+	if _, err = AddUnits("m", "s"); err == nil {
+		t.Error("expected error adding incompatible units")
+	}
+}
@@ -0,0 +1,84 @@
+package gopixi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// Replaces tiles of the layer with the given index with new (uncompressed) data, keyed by disk tile index,
+// overwriting each in place if it fits and appending it otherwise, then updates the layer header. Unlike
+// RewriteTiles, an interrupted update may leave tiles that fail checksum verification; with tile history
+// enabled, the update is done by RewriteTiles instead. Channel moments and histograms are cleared, and
+// layers with halos are unsupported.
+func (p *Pixi) UpdateTiles(w io.WriteSeeker, layerIndex int, tiles map[int][]byte) error {
+	if p.TileHistory {
+		return p.RewriteTiles(w, layerIndex, tiles)
+	}
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "update tiles"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	old := p.Layers[layerIndex]
+	if old.Dimensions.HasHalo() {
+		return ErrUnsupported("updating tiles of layers with halos")
+	}
+	order := slices.Sorted(maps.Keys(tiles))
+	for _, tile := range order {
+		if tile < 0 || tile >= old.DiskTiles() {
+			return ErrTileNotFound{TileIndex: tile}
+		}
+		if !old.validTileSize(tile, len(tiles[tile])) {
+			return ErrFormat(fmt.Sprintf("tile %d has %d bytes, expected %d", tile, len(tiles[tile]), old.slotsTileSize(tile)))
+		}
+	}
+
+	layer := old
+	layer.Channels = slices.Clone(old.Channels)
+	layer.TileBytes = slices.Clone(old.TileBytes)
+	layer.TileOffsets = slices.Clone(old.TileOffsets)
+	layer.StringBytes = slices.Clone(old.StringBytes)
+	encoder := p.newTileEncoder(layerIndex)
+	var encoded bytes.Buffer
+	for _, tile := range order {
+		data := tiles[tile]
+		if layer.StringBytes != nil {
+			layer.StringBytes[tile] = int64(len(data) - layer.slotsTileSize(tile))
+		}
+		encoded.Reset()
+		size, err := layer.Compression.writeChunkWith(encoder, &encoded, p.Header, layer, tile, data)
+		if err != nil {
+			return err
+		}
+		if err := p.Header.writeChecksum(&encoded, data); err != nil {
+			return err
+		}
+
+		offset := layer.TileOffsets[tile]
+		if layer.TileBytes[tile] == 0 || int64(size) > layer.TileBytes[tile] {
+			if offset, err = w.Seek(0, io.SeekEnd); err != nil {
+				return err
+			}
+		} else if _, err := w.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := w.Write(encoded.Bytes()); err != nil {
+			return err
+		}
+		layer.TileOffsets[tile] = offset
+		layer.TileBytes[tile] = int64(size)
+		layer.updateTileStatistics(p.Header, tile, data)
+		if err := encoder.tileWritten(layer, tile, data); err != nil {
+			return err
+		}
+	}
+	layer.Channels = layer.Channels.withoutDistributions()
+	if err := p.UpdateLayerHeader(w, layerIndex, layer); err != nil {
+		return err
+	}
+	return p.layerFinalized(layerIndex)
+}
@@ -0,0 +1,128 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestUpdateTiles(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := NewLayer("values", DimensionSet{{Name: "x", Size: 16, TileSize: 8}, {Name: "y", Size: 8, TileSize: 8}},
+		ChannelSet{{Name: "v", Type: ChannelUint8}}, WithCompression(CompressionFlate))
+	writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+	err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+		for writer.Next() {
+			writer.SetSample(Sample{uint8(writer.Coordinate()[0] % 4)})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.TileCache = NewTileCache(1 << 20)
+	before, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := before.Tile(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// a constant tile compresses better than the original, so it fits in its place; noise does not
+	old := file.Layers[0]
+	size := len(file.Bytes())
+	constant := make([]byte, old.DiskTileSize(0))
+	for i := range constant {
+		constant[i] = 2
+	}
+	noise := make([]byte, old.DiskTileSize(1))
+	random := rand.New(rand.NewPCG(1, 2))
+	for i := range noise {
+		noise[i] = uint8(random.IntN(200))
+	}
+	if err := file.UpdateTiles(file.Stream(), 0, map[int][]byte{0: constant, 1: noise}); err != nil {
+		t.Fatal(err)
+	}
+	updated := file.Layers[0]
+	if updated.TileOffsets[0] != old.TileOffsets[0] || updated.TileBytes[0] >= old.TileBytes[0] {
+		t.Errorf("expected the first tile to be overwritten in place, was at %d with %d bytes, now at %d with %d",
+			old.TileOffsets[0], old.TileBytes[0], updated.TileOffsets[0], updated.TileBytes[0])
+	}
+	if updated.TileOffsets[1] < int64(size) {
+		t.Errorf("expected the second tile to be relocated to the end of the file, is at %d", updated.TileOffsets[1])
+	}
+	if greatest := slices.Max(noise); updated.Channels[0].Max != greatest {
+		t.Errorf("expected the channel maximum to be widened to %d, got %v", greatest, updated.Channels[0].Max)
+	}
+
+	read, err := FromBytes(file.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := file.Layer(0) // the updated tiles must not be read from the cache
+	if err != nil {
+		t.Fatal(err)
+	}
+	reread, err := read.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range []TileAccessLayer{cached, reread} {
+		for coord := range layer.Dimensions.SampleCoordinates() {
+			expected := constant[0]
+			if coord[0] >= 8 {
+				expected = noise[coord[1]*8+coord[0]-8]
+			}
+			if sample, err := SampleAt(values, coord); err != nil || sample[0] != expected {
+				t.Fatalf("sample %v: got %v (%v), expected %d", coord, sample, err, expected)
+			}
+		}
+	}
+
+	if err := file.UpdateTiles(file.Stream(), 0, map[int][]byte{2: constant}); err == nil {
+		t.Error("expected error for a tile outside the layer")
+	}
+	if err := file.UpdateTiles(file.Stream(), 0, map[int][]byte{0: constant[1:]}); err == nil {
+		t.Error("expected error for a tile of the wrong size")
+	}
+}
+
+func TestUpdateStringTiles(t *testing.T) {
+	file := newStringTestFile(t, WithCompression(CompressionFlate))
+	access, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tile, err := access.Tile(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the label of the first sample is replaced with a longer one, growing the string data block
+	label := "a considerably longer label than any station had before"
+	data := putString(slices.Clone(tile), 0, label, file.Header.ByteOrder)
+	if err := file.UpdateTiles(file.Stream(), 0, map[int][]byte{0: data}); err != nil {
+		t.Fatal(err)
+	}
+	read, err := FromBytes(file.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reread, err := read.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for coord := range read.Layers[0].Dimensions.SampleCoordinates() {
+		expected := stringTestLabel(coord)
+		if coord[0] == 0 && coord[1] == 0 {
+			expected = label
+		}
+		if sample, err := SampleAt(reread, coord); err != nil || sample[0] != expected || sample[1] != uint16(coord[0]*10) {
+			t.Fatalf("sample %v: got %v (%v), expected label '%s'", coord, sample, err, expected)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package gopixi
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The prefix of the reserved tags recording the tile versions superseded by each generation of a file
+// with tile history, which are named "pixi.generation.<number>".
+const GenerationTagPrefix string = "pixi.generation."
+
+type tileHistoryOption struct{}
+
+func (o tileHistoryOption) applyCreate(opts *createOptions) {
+	opts.tileHistory = true
+}
+
+// Retains the prior versions of tiles replaced with RewriteTiles, rather than leaving them as dead space.
+// Files read with ReadPixi retain tile history if they already have recorded generations.
+func WithTileHistory() CreateOption {
+	return tileHistoryOption{}
+}
+
+// The location in the file of one stored version of a tile.
+type TileVersion struct {
+	Layer  int   // The index of the layer the tile belongs to.
+	Tile   int   // The disk tile index within the layer.
+	Offset int64 // The offset of the tile data in the file.
+	Bytes  int64 // The size of the stored tile data in bytes, excluding its checksum.
+}
+
+// A generation of a file with tile history, created by each call to RewriteTiles. Generation zero is the
+// file as originally written, and has no record.
+type Generation struct {
+	Number int       // The generation number, counting up from 1.
+	Time   time.Time // When the generation was committed, or zero if written with deterministic writes.
+	// The tile versions that were current until this generation replaced them, so that the file as it was
+	// in the previous generation can be reconstructed.
+	Superseded []TileVersion
+}
+
+// The generations recorded in the file, oldest first.
+func (p *Pixi) Generations() []Generation {
+	generations := []Generation{}
+	for tag, value := range p.AllTags() {
+		rest, ok := strings.CutPrefix(tag, GenerationTagPrefix)
+		if !ok {
+			continue
+		}
+		number, err := strconv.Atoi(rest)
+		if err != nil {
+			continue
+		}
+		generation, err := decodeGeneration(number, value)
+		if err != nil {
+			continue
+		}
+		generations = append(generations, generation)
+	}
+	slices.SortFunc(generations, func(a, b Generation) int { return a.Number - b.Number })
+	return generations
+}
+
+// The number of the current generation of the file: zero if no generations have been recorded.
+func (p *Pixi) CurrentGeneration() int {
+	generations := p.Generations()
+	if len(generations) == 0 {
+		return 0
+	}
+	return generations[len(generations)-1].Number
+}
+
+// Replaces tiles of the layer with the given index with new (uncompressed) data, keyed by disk tile index,
+// by appending them and updating the layer header. With tile history enabled (see WithTileHistory), the
+// superseded tiles are retained as a new generation; otherwise they are left for Compact to reclaim. Channel
+// moments and histograms are cleared, and layers with halos are unsupported.
+func (p *Pixi) RewriteTiles(w io.WriteSeeker, layerIndex int, tiles map[int][]byte) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "rewrite tiles"}
+	}
+	if layerIndex < 0 || layerIndex >= len(p.Layers) {
+		return ErrFormat(fmt.Sprintf("layer index %d out of range", layerIndex))
+	}
+	old := p.Layers[layerIndex]
+	if old.Channels.HasStrings() {
+		return ErrUnsupported("tile history of layers with string channels")
+	}
+	if old.Dimensions.HasHalo() {
+		return ErrUnsupported("rewriting tiles of layers with halos")
+	}
+	order := slices.Sorted(maps.Keys(tiles))
+	for _, tile := range order {
+		if tile < 0 || tile >= old.DiskTiles() {
+			return ErrTileNotFound{TileIndex: tile}
+		}
+		if len(tiles[tile]) != old.DiskTileSize(tile) {
+			return ErrFormat(fmt.Sprintf("tile %d has %d bytes, expected %d", tile, len(tiles[tile]), old.DiskTileSize(tile)))
+		}
+	}
+
+	layer := old
+	layer.Channels = slices.Clone(old.Channels)
+	layer.TileBytes = slices.Clone(old.TileBytes)
+	layer.TileOffsets = slices.Clone(old.TileOffsets)
+	if _, err := w.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	encoder := p.newTileEncoder(layerIndex)
+	for _, tile := range order {
+		if err := layer.writeTileWith(encoder, w, p.Header, tile, tiles[tile]); err != nil {
+			return err
+		}
+		layer.updateTileStatistics(p.Header, tile, tiles[tile])
+	}
+	layer.Channels = layer.Channels.withoutDistributions()
+
+	if p.TileHistory {
+		generation := Generation{Number: p.CurrentGeneration() + 1}
+		if !p.Deterministic {
+			generation.Time = time.Now().UTC()
+		}
+		for _, tile := range order {
+			generation.Superseded = append(generation.Superseded, TileVersion{
+				Layer: layerIndex, Tile: tile, Offset: old.TileOffsets[tile], Bytes: old.TileBytes[tile],
+			})
+		}
+		if err := p.AppendTags(w, map[string]string{generationTag(generation.Number): generation.encode()}); err != nil {
+			return err
+		}
+	}
+	if err := p.UpdateLayerHeader(w, layerIndex, layer); err != nil {
+		return err
+	}
+	return p.layerFinalized(layerIndex)
+}
+
+func generationTag(number int) string {
+	return GenerationTagPrefix + strconv.Itoa(number)
+}
+
+// Generations are stored as URL-encoded key-value pairs: the commit time (if any) under "time", and the offset and
+// size of each superseded tile under "<layer>.<tile>" as "<offset>:<bytes>". A tile never written before
+// the generation is recorded with zero offset and size.
+func (g Generation) encode() string {
+	values := url.Values{}
+	if !g.Time.IsZero() {
+		values.Set("time", g.Time.Format(time.RFC3339Nano))
+	}
+	for _, v := range g.Superseded {
+		values.Set(fmt.Sprintf("%d.%d", v.Layer, v.Tile), fmt.Sprintf("%d:%d", v.Offset, v.Bytes))
+	}
+	return values.Encode()
+}
+
+func decodeGeneration(number int, value string) (Generation, error) {
+	values, err := url.ParseQuery(value)
+	if err != nil {
+		return Generation{}, err
+	}
+	generation := Generation{Number: number}
+	if values.Has("time") {
+		generation.Time, err = time.Parse(time.RFC3339Nano, values.Get("time"))
+		if err != nil {
+			return Generation{}, err
+		}
+	}
+	for key, v := range values {
+		if key == "time" {
+			continue
+		}
+		var version TileVersion
+		if _, err := fmt.Sscanf(key, "%d.%d", &version.Layer, &version.Tile); err != nil {
+			return Generation{}, ErrFormat(fmt.Sprintf("invalid tile in generation %d: %s", number, key))
+		}
+		if _, err := fmt.Sscanf(v[0], "%d:%d", &version.Offset, &version.Bytes); err != nil {
+			return Generation{}, ErrFormat(fmt.Sprintf("invalid tile location in generation %d: %s", number, v[0]))
+		}
+		generation.Superseded = append(generation.Superseded, version)
+	}
+	slices.SortFunc(generation.Superseded, func(a, b TileVersion) int {
+		if a.Layer != b.Layer {
+			return a.Layer - b.Layer
+		}
+		return a.Tile - b.Tile
+	})
+	return generation, nil
+}
+
+// Every retained prior tile version referenced by the recorded generations.
+func (p *Pixi) retainedTileVersions() []TileVersion {
+	versions := []TileVersion{}
+	for _, generation := range p.Generations() {
+		for _, v := range generation.Superseded {
+			if v.Bytes != 0 {
+				versions = append(versions, v)
+			}
+		}
+	}
+	return versions
+}
+
+// Returns a read-only view of the file as it was at the given generation, sharing the stream of the file.
+// Every later generation must still be retained.
+func (p *Pixi) OpenAt(generation int) (*Pixi, error) {
+	generations := p.Generations()
+	current := 0
+	if len(generations) > 0 {
+		current = generations[len(generations)-1].Number
+	}
+	if generation < 0 || generation > current {
+		return nil, ErrFormat(fmt.Sprintf("generation %d does not exist, current generation is %d", generation, current))
+	}
+
+	view := &Pixi{Header: p.Header, Layers: make([]Layer, len(p.Layers)), ReadOnly: true, TileHistory: p.TileHistory}
+	for i, layer := range p.Layers {
+		layer.TileBytes = slices.Clone(layer.TileBytes)
+		layer.TileOffsets = slices.Clone(layer.TileOffsets)
+		view.Layers[i] = layer
+	}
+	next := current
+	for i := len(generations) - 1; i >= 0 && generations[i].Number > generation; i-- {
+		if generations[i].Number != next {
+			return nil, ErrFormat(fmt.Sprintf("generation %d is no longer reachable: the record of generation %d was removed", generation, next))
+		}
+		for _, v := range generations[i].Superseded {
+			if v.Layer < 0 || v.Layer >= len(view.Layers) || v.Tile < 0 || v.Tile >= len(view.Layers[v.Layer].TileBytes) {
+				return nil, ErrFormat(fmt.Sprintf("generation %d references missing tile %d of layer %d", next, v.Tile, v.Layer))
+			}
+			view.Layers[v.Layer].TileOffsets[v.Tile] = v.Offset
+			view.Layers[v.Layer].TileBytes[v.Tile] = v.Bytes
+		}
+		next--
+	}
+	if next != generation {
+		return nil, ErrFormat(fmt.Sprintf("generation %d is no longer reachable: the record of generation %d was removed", generation, next))
+	}
+
+	// tag sections are appended in order, so the view ends just before the section of the next generation
+	for _, section := range p.Tags {
+		if _, ok := section.Tags[generationTag(generation+1)]; ok {
+			break
+		}
+		view.Tags = append(view.Tags, section)
+	}
+	return view, nil
+}
+
+// The number of the latest generation committed at or before the given time, for opening the file as it
+// was at that time with OpenAt. Returns zero if no generation was committed by then.
+func (p *Pixi) GenerationAt(t time.Time) int {
+	number := 0
+	for _, generation := range p.Generations() {
+		if generation.Time.After(t) {
+			break
+		}
+		number = generation.Number
+	}
+	return number
+}
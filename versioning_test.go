@@ -0,0 +1,171 @@
+package gopixi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestRewriteTilesWithHistory(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	header := NewHeader(binary.LittleEndian, OffsetSize4)
+	layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}}, WithCompression(CompressionFlate))}
+	written := writeTestPixi(t, buf, header, nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+	written.TileHistory = true
+	original := written.Layers[0]
+
+	if err := written.RewriteTiles(buf, 0, map[int][]byte{1: {10, 11, 12, 13}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := written.RewriteTiles(buf, 0, map[int][]byte{0: {20, 21, 22, 23}, 1: {30, 31, 32, 33}}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Seek(0, 0)
+	read, err := ReadPixi(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !read.TileHistory || read.CurrentGeneration() != 2 {
+		t.Fatalf("expected tile history with 2 generations, got %v and %d", read.TileHistory, read.CurrentGeneration())
+	}
+	generations := read.Generations()
+	if len(generations[0].Superseded) != 1 || len(generations[1].Superseded) != 2 || generations[1].Time.Before(generations[0].Time) {
+		t.Fatalf("unexpected generations %v", generations)
+	}
+	if v := generations[0].Superseded[0]; v.Tile != 1 || v.Offset != original.TileOffsets[1] || v.Bytes != original.TileBytes[1] {
+		t.Errorf("expected generation 1 to supersede the original tile 1, got %v", v)
+	}
+	if max := read.Layers[0].Channels[0].Max; max != uint8(33) {
+		t.Errorf("expected widened maximum 33, got %v", max)
+	}
+
+	current := make([]byte, 4)
+	if err := read.Layers[0].ReadTile(buf, read.Header, 1, current); err != nil || !bytes.Equal(current, []byte{30, 31, 32, 33}) {
+		t.Errorf("unexpected current tile %v (%v)", current, err)
+	}
+	// the retained versions are still intact
+	for i, expected := range [][]byte{{4, 5, 6, 7}, {10, 11, 12, 13}} {
+		v := generations[i].Superseded[len(generations[i].Superseded)-1]
+		layer := read.Layers[0]
+		layer.TileOffsets = []int64{0, v.Offset}
+		layer.TileBytes = []int64{0, v.Bytes}
+		data := make([]byte, 4)
+		if err := layer.ReadTile(buf, read.Header, 1, data); err != nil || !bytes.Equal(data, expected) {
+			t.Errorf("unexpected retained tile %v (%v)", data, err)
+		}
+	}
+	// only the space the layer header shrank by, once the rewrite cleared its moments, is dead
+	shrunk := int64(original.HeaderSize(read.Header) - read.Layers[0].HeaderSize(read.Header))
+	if read.LiveBytes() != int64(len(buf.Bytes()))-shrunk {
+		t.Errorf("expected retained versions to be live, got %d live bytes of %d", read.LiveBytes(), len(buf.Bytes()))
+	}
+
+	compacted := buffer.NewBuffer(10)
+	compactPixi, err := Compact(buffer.NewBufferFrom(buf.Bytes()), compacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compactPixi.Generations()) != 0 {
+		t.Error("expected compaction to drop generations")
+	}
+}
+
+func TestRewriteTilesWithoutHistory(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}})}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+	if err := written.RewriteTiles(buf, 0, map[int][]byte{0: {1, 2, 3, 4}}); err != nil {
+		t.Fatal(err)
+	}
+	if written.CurrentGeneration() != 0 || written.LiveBytes() >= int64(len(buf.Bytes())) {
+		t.Error("expected the replaced tile to be dead space without history")
+	}
+	if err := written.RewriteTiles(buf, 0, map[int][]byte{2: {1, 2, 3, 4}}); err == nil {
+		t.Error("expected error rewriting a tile out of range")
+	}
+	if err := written.RewriteTiles(buf, 0, map[int][]byte{0: {1, 2}}); err == nil {
+		t.Error("expected error rewriting a tile with the wrong size")
+	}
+}
+
+func TestOpenAt(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("data", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint8}}, WithPlanar())}
+	written := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), map[string]string{"stage": "raw"}, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint8(coord[0])}
+	})
+	written.TileHistory = true
+	if err := written.RewriteTiles(buf, 0, map[int][]byte{0: {10, 11, 12, 13}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := written.AppendTags(buf, map[string]string{"stage": "corrected"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := written.RewriteTiles(buf, 0, map[int][]byte{0: {20, 21, 22, 23}, 1: {24, 25, 26, 27}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := written.AppendTags(buf, map[string]string{"stage": "final"}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Seek(0, 0)
+	read, err := ReadPixi(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		stage string
+		tiles [][]byte
+	}{
+		{"raw", [][]byte{{0, 1, 2, 3}, {4, 5, 6, 7}}},
+		{"corrected", [][]byte{{10, 11, 12, 13}, {4, 5, 6, 7}}},
+		{"final", [][]byte{{20, 21, 22, 23}, {24, 25, 26, 27}}},
+	}
+	for generation, exp := range expected {
+		view, err := read.OpenAt(generation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !view.ReadOnly || view.AllTags()["stage"] != exp.stage {
+			t.Errorf("generation %d: unexpected view tags %v", generation, view.AllTags())
+		}
+		for tile, data := range exp.tiles {
+			got := make([]byte, 4)
+			if err := view.Layers[0].ReadTile(buf, view.Header, tile, got); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("generation %d: expected tile %d to be %v, got %v (%v)", generation, tile, data, got, err)
+			}
+		}
+	}
+	current := make([]byte, 4)
+	read.Layers[0].ReadTile(buf, read.Header, 0, current)
+	if !bytes.Equal(current, []byte{20, 21, 22, 23}) {
+		t.Error("expected opening an earlier generation to leave the current index untouched")
+	}
+
+	if _, err := read.OpenAt(3); err == nil {
+		t.Error("expected error opening a future generation")
+	}
+	generations := read.Generations()
+	if read.GenerationAt(generations[0].Time.Add(-1)) != 0 || read.GenerationAt(generations[0].Time) != 1 || read.GenerationAt(generations[1].Time.Add(1)) != 2 {
+		t.Error("unexpected generations resolved from times")
+	}
+
+	// dropping a later generation's record makes earlier generations unreachable
+	for _, section := range read.Tags {
+		if record, ok := section.Tags[generationTag(2)]; ok {
+			delete(section.Tags, generationTag(2))
+			section.Tags[generationTag(3)] = record
+		}
+	}
+	if _, err := read.OpenAt(1); err == nil {
+		t.Error("expected error opening a generation whose successor is not retained")
+	}
+}
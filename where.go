@@ -0,0 +1,133 @@
+package gopixi
+
+import (
+	"io"
+	"math"
+)
+
+// Selects samples for AppendWhere: those at which the first channel of the layer is nonzero (or true), or,
+// if an expression is given, those at which the expression evaluated over the samples of the layer is
+// nonzero. The layer is broadcast onto the grid of the selection like an Operand.
+type Condition struct {
+	Layer      TileAccessLayer
+	Expression *Expression       // Evaluated over the channels of the layer, if not nil.
+	Options    ExpressionOptions // Controls which inputs of the expression are missing.
+	// The value of the first channel marking samples at which the condition is missing, if no expression is
+	// given. The condition is also missing where the expression has a missing input or (by default) a result
+	// that is not finite. The results of samples with a missing condition are missing.
+	Fill *float64
+}
+
+// A condition selecting samples where the first channel of the layer is nonzero.
+func LayerCondition(layer TileAccessLayer) Condition {
+	return Condition{Layer: layer}
+}
+
+// A condition selecting samples where the expression over the channels of the layer is nonzero.
+func ExpressionCondition(layer TileAccessLayer, expression *Expression, opts ExpressionOptions) Condition {
+	return Condition{Layer: layer, Expression: expression, Options: opts}
+}
+
+// Returns a function evaluating the condition at every coordinate of the target layer's grid, giving 1 where
+// it holds, 0 where it does not, and NaN where it is missing.
+func (c Condition) bind(target Layer) (func(coord SampleCoordinate) (float64, error), error) {
+	layer := c.Layer.Layer()
+	mapped, err := broadcastCoordinates(target, layer)
+	if err != nil {
+		return nil, err
+	}
+	evaluate := func(sample Sample) float64 {
+		value, ok := layer.Channels[0].Type.ToFloat64(sample[0])
+		if !ok || (c.Fill != nil && value == *c.Fill) {
+			return math.NaN()
+		}
+		return value
+	}
+	if c.Expression != nil {
+		if evaluate, err = c.Expression.Bind(layer.Channels, c.Options); err != nil {
+			return nil, err
+		}
+	}
+	return func(coord SampleCoordinate) (float64, error) {
+		sample, err := SampleAt(c.Layer, mapped(coord))
+		if err != nil {
+			return 0, err
+		}
+		switch value := evaluate(sample); {
+		case math.IsNaN(value):
+			return value, nil
+		case value != 0:
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	}, nil
+}
+
+// Appends a layer to the end of the file taking its values from the first operand where the condition holds
+// and from the second elsewhere. Operands are broadcast and typed as in AppendOperation, and samples whose
+// condition or selected operand is missing are missing in the result.
+func (p *Pixi) AppendWhere(w io.WriteSeeker, name string, cond Condition, a Operand, b Operand, outputFill *float64) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append layer"}
+	}
+	if cond.Layer == nil {
+		return ErrFormat("condition must have a layer")
+	}
+
+	var target Layer
+	switch {
+	case a.Layer != nil:
+		target = a.Layer.Layer()
+	case b.Layer != nil:
+		target = b.Layer.Layer()
+	default:
+		target = cond.Layer.Layer()
+		target.Channels = ChannelSet{{Name: name, Type: PromoteTypes(a.channelType(0), b.channelType(0))}}
+	}
+	condition, err := cond.bind(target)
+	if err != nil {
+		return err
+	}
+	aValues, err := a.bind(target)
+	if err != nil {
+		return err
+	}
+	bValues, err := b.bind(target)
+	if err != nil {
+		return err
+	}
+
+	channels := make(ChannelSet, len(target.Channels))
+	for i, channel := range target.Channels {
+		resultType := PromoteTypes(a.channelType(i), b.channelType(i))
+		if oneConstant := (a.Layer == nil) != (b.Layer == nil); oneConstant && floatRank(channel.Type) > 0 {
+			resultType = channel.Type.Base() // constants do not widen floating point layers
+		}
+		channels[i] = Channel{Name: channel.Name, Type: resultType, Unit: channel.Unit}
+	}
+
+	x, y := make([]float64, len(channels)), make([]float64, len(channels))
+	return p.appendTilewise(w, derivedLayer(name, target, channels), resultFills(channels, outputFill, a, b),
+		func(coord SampleCoordinate, result []float64) error {
+			selected, err := condition(coord)
+			if err != nil {
+				return err
+			}
+			if math.IsNaN(selected) {
+				for c := range result {
+					result[c] = math.NaN()
+				}
+				return nil
+			}
+			values, operand := x, aValues
+			if selected == 0 {
+				values, operand = y, bValues
+			}
+			if err := operand(coord, values); err != nil {
+				return err
+			}
+			copy(result, values)
+			return nil
+		})
+}
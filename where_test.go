@@ -0,0 +1,119 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestAppendWhere(t *testing.T) {
+	grid := DimensionSet{{Name: "x", Size: 5, TileSize: 2}, {Name: "y", Size: 4, TileSize: 3}, {Name: "t", Size: 2, TileSize: 1}}
+	plane := DimensionSet{{Name: "x", Size: 5, TileSize: 5}, {Name: "y", Size: 4, TileSize: 4}}
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{
+		NewLayer("data", grid, ChannelSet{{Name: "v", Type: ChannelFloat32}}, WithPlanar()),
+		NewLayer("mask", plane, ChannelSet{{Name: "ok", Type: ChannelBool}}),
+		NewLayer("quality", plane, ChannelSet{{Name: "q", Type: ChannelUint8}, {Name: "cloud", Type: ChannelUint8}}),
+	}, func(layer int, coord SampleCoordinate) Sample {
+		switch layer {
+		case 0:
+			return Sample{float32(coord[0] + 10*coord[1] + 100*coord[2])}
+		case 1:
+			return Sample{coord[0] != coord[1]}
+		default:
+			return Sample{uint8(coord[0] * coord[1]), uint8(coord[0] % 2)}
+		}
+	})
+	data := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 4), nil)
+	mask := LayerCondition(NewFifoCacheReadLayer(buf, p.Header, p.Layers[1], 4))
+	quality := NewFifoCacheReadLayer(buf, p.Header, p.Layers[2], 4)
+	expression, err := ParseExpression("q - cloud * 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noData := -1.0
+
+	if err := p.AppendWhere(buf, "masked", mask, data, ConstantOperand(noData), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendWhere(buf, "cloudy", ExpressionCondition(quality, expression, ExpressionOptions{InputFill: map[string]float64{"q": 0}}), ConstantOperand(1), ConstantOperand(0), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r := buffer.NewBufferFrom(buf.Bytes())
+	read, err := ReadPixi(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	masked, cloudy := read.Layers[3], read.Layers[4]
+	if masked.Channels[0].Type != ChannelFloat32 || !masked.Separated || len(masked.Dimensions) != 3 {
+		t.Errorf("unexpected masked layer %+v", masked)
+	}
+	if cloudy.Channels[0].Name != "cloudy" || cloudy.Channels[0].Type != ChannelUint8 || len(cloudy.Dimensions) != 2 {
+		t.Errorf("unexpected cloudy layer %+v", cloudy)
+	}
+	maskedAccess := NewFifoCacheReadLayer(r, read.Header, masked, 4)
+	for coord := range grid.SampleCoordinates() {
+		want := float32(coord[0] + 10*coord[1] + 100*coord[2])
+		if coord[0] == coord[1] {
+			want = float32(noData)
+		}
+		if sample, err := SampleAt(maskedAccess, coord); err != nil || sample[0] != want {
+			t.Fatalf("masked at %v: expected %v, got %v (%v)", coord, want, sample, err)
+		}
+	}
+	cloudyAccess := NewFifoCacheReadLayer(r, read.Header, cloudy, 4)
+	for coord := range plane.SampleCoordinates() {
+		// the condition is missing where q is zero, and the fill of an integer result defaults to zero
+		want := uint8(0)
+		if q := coord[0] * coord[1]; q != 0 && float64(q-coord[0]%2*100) != 0 {
+			want = 1
+		}
+		if sample, err := SampleAt(cloudyAccess, coord); err != nil || sample[0] != want {
+			t.Fatalf("cloudy at %v: expected %v, got %v (%v)", coord, want, sample, err)
+		}
+	}
+}
+
+func TestAppendWhereMissing(t *testing.T) {
+	dims := DimensionSet{{Name: "x", Size: 6, TileSize: 4}}
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{
+		NewLayer("a", dims, ChannelSet{{Name: "v", Type: ChannelInt16}}),
+		NewLayer("b", dims, ChannelSet{{Name: "v", Type: ChannelUint8}}),
+		NewLayer("c", dims, ChannelSet{{Name: "v", Type: ChannelInt8}}),
+	}, func(layer int, coord SampleCoordinate) Sample {
+		switch layer {
+		case 0:
+			return Sample{int16(-coord[0])}
+		case 1:
+			return Sample{uint8(10 + coord[0])}
+		default:
+			return Sample{int8(coord[0]%3 - 1)} // -1 is missing, 0 selects b, 1 selects a
+		}
+	})
+	fill := -1.0
+	cond := LayerCondition(NewFifoCacheReadLayer(buf, p.Header, p.Layers[2], 4))
+	cond.Fill = &fill
+	a := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 4), nil)
+	b := LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[1], 4), nil)
+	output := 99.0
+	if err := p.AppendWhere(buf, "where", cond, a, b, &output); err != nil {
+		t.Fatal(err)
+	}
+	layer := p.Layers[3]
+	if layer.Channels[0].Type != ChannelInt16 {
+		t.Errorf("expected promoted type int16, got %v", layer.Channels[0].Type)
+	}
+	access := NewFifoCacheReadLayer(buf, p.Header, layer, 4)
+	for x, want := range []int16{99, 11, -2, 99, 14, -5} {
+		if sample, err := SampleAt(access, SampleCoordinate{x}); err != nil || sample[0] != want {
+			t.Errorf("at %d: expected %d, got %v (%v)", x, want, sample, err)
+		}
+	}
+	if !math.IsNaN(resultFills(ChannelSet{{Type: ChannelFloat32}}, nil, a)[0]) {
+		t.Error("expected floating point results to be missing as NaN by default")
+	}
+}
@@ -0,0 +1,92 @@
+package gopixi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Returns true if the format is a whole-file compression wrapper that Unwrap can remove, such as
+// a Pixi file compressed externally with gzip (.pixi.gz) or zstd (.pixi.zst).
+func (f Format) IsWrapper() bool {
+	return f == FormatGzip || f == FormatZstd
+}
+
+// Wraps the reader in a streaming decompressor for the given wrapper format. Returns an
+// ErrUnsupported error if the format is not a supported compression wrapper.
+func NewUnwrapReader(r io.Reader, format Format) (io.ReadCloser, error) {
+	switch format {
+	case FormatGzip:
+		return gzip.NewReader(r)
+	case FormatZstd:
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	default:
+		return nil, ErrUnsupported("unwrapping " + format.String() + " files")
+	}
+}
+
+// A decompressed copy of a wrapped file, stored in a temporary file that is removed on Close.
+type UnwrappedFile struct {
+	*os.File
+}
+
+// Closes and removes the temporary file.
+func (f *UnwrappedFile) Close() error {
+	return errors.Join(f.File.Close(), os.Remove(f.File.Name()))
+}
+
+// Decompresses the wrapped stream into a new temporary file in dir (or the default temporary
+// directory if dir is empty), so that the random access needed to read tiles is possible. The
+// returned file is positioned at its start, and is removed from disk when closed.
+func Unwrap(r io.Reader, format Format, dir string) (*UnwrappedFile, error) {
+	dec, err := NewUnwrapReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+
+	temp, err := os.CreateTemp(dir, "pixi-unwrapped-*.pixi")
+	if err != nil {
+		return nil, err
+	}
+	unwrapped := &UnwrappedFile{File: temp}
+	_, err = io.Copy(temp, dec)
+	if err == nil {
+		_, err = temp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return nil, errors.Join(err, unwrapped.Close())
+	}
+	return unwrapped, nil
+}
+
+// Checks the start of the stream for a compression wrapper, and if one is found, decompresses the
+// stream into a temporary file and closes the original stream. Otherwise, the stream is returned
+// unchanged, positioned back at its start.
+func unwrapStream(stream io.ReadSeekCloser) (io.ReadSeekCloser, error) {
+	prefix := make([]byte, detectLength)
+	n, err := io.ReadFull(stream, prefix)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, errors.Join(err, stream.Close())
+	}
+	_, err = stream.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, errors.Join(err, stream.Close())
+	}
+
+	info, err := Detect(bytes.NewReader(prefix[:n]))
+	if err != nil || !info.Format.IsWrapper() {
+		return stream, err
+	}
+
+	unwrapped, err := Unwrap(stream, info.Format, "")
+	return unwrapped, errors.Join(err, stream.Close())
+}
@@ -0,0 +1,81 @@
+package gopixi
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestOpenWrappedFiles(t *testing.T) {
+	buf := buffer.NewBuffer(10)
+	layers := []Layer{NewLayer("wrapped", DimensionSet{{Name: "x", Size: 8, TileSize: 4}}, ChannelSet{{Name: "v", Type: ChannelUint32}})}
+	writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, layers, func(layer int, coord SampleCoordinate) Sample {
+		return Sample{uint32(coord[0] * 3)}
+	})
+	content := buf.Bytes()
+
+	wrappers := map[string]func(w io.Writer) (io.WriteCloser, error){
+		"test.pixi":     func(w io.Writer) (io.WriteCloser, error) { return nopWriteCloser{w}, nil },
+		"test.pixi.gz":  func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		"test.pixi.zst": func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+	}
+	for name, wrap := range wrappers {
+		path := filepath.Join(t.TempDir(), name)
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrapper, err := wrap(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = wrapper.Write(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrapper.Close()
+		file.Close()
+
+		stream, err := OpenFileOrHttp(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pixi, err := ReadPixi(stream)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		layer := NewFifoCacheReadLayer(stream, pixi.Header, pixi.Layers[0], 2)
+		sample, err := SampleAt(layer, SampleCoordinate{5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sample[0] != uint32(15) {
+			t.Errorf("%s: expected sample 15, got %v", name, sample[0])
+		}
+
+		unwrapped, isUnwrapped := stream.(*UnwrappedFile)
+		err = stream.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isUnwrapped {
+			if _, err := os.Stat(unwrapped.Name()); !os.IsNotExist(err) {
+				t.Errorf("%s: expected temporary file to be removed on close", name)
+			}
+		} else if name != "test.pixi" {
+			t.Errorf("%s: expected wrapped file to be unwrapped", name)
+		}
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,866 @@
+package gopixi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/x448/float16"
+)
+
+// The key of the metadata document of each node of a Zarr v3 store, within the node.
+const zarrMetadataKey = "zarr.json"
+
+// The metadata document of a Zarr v3 group or array.
+type zarrMetadata struct {
+	ZarrFormat          int             `json:"zarr_format"`
+	NodeType            string          `json:"node_type"`
+	Shape               []int           `json:"shape,omitempty"`
+	DataType            any             `json:"data_type,omitempty"`
+	ChunkGrid           *zarrExtension  `json:"chunk_grid,omitempty"`
+	ChunkKeyEncoding    *zarrExtension  `json:"chunk_key_encoding,omitempty"`
+	FillValue           any             `json:"fill_value,omitempty"`
+	Codecs              []zarrExtension `json:"codecs,omitempty"`
+	Attributes          map[string]any  `json:"attributes,omitempty"`
+	DimensionNames      []*string       `json:"dimension_names,omitempty"`
+	StorageTransformers []zarrExtension `json:"storage_transformers,omitempty"`
+}
+
+// A named extension point of Zarr metadata, such as a codec or the chunk grid, with its configuration.
+type zarrExtension struct {
+	Name          string         `json:"name"`
+	Configuration map[string]any `json:"configuration,omitempty"`
+}
+
+// The Zarr data types of the channel types that have one.
+var zarrDataTypes = map[ChannelType]string{
+	ChannelBool:    "bool",
+	ChannelInt8:    "int8",
+	ChannelInt16:   "int16",
+	ChannelInt32:   "int32",
+	ChannelInt64:   "int64",
+	ChannelUint8:   "uint8",
+	ChannelUint16:  "uint16",
+	ChannelUint32:  "uint32",
+	ChannelUint64:  "uint64",
+	ChannelFloat16: "float16",
+	ChannelFloat32: "float32",
+	ChannelFloat64: "float64",
+}
+
+// An array of a Zarr v3 store, with the parts of its metadata needed to read and write its chunks.
+type zarrArray struct {
+	path       string // the key of the array within the store, "." for the root
+	meta       zarrMetadata
+	dtype      ChannelType
+	chunks     []int
+	dimensions []string
+	separator  string
+	v2Keys     bool // whether chunk keys are encoded as in Zarr v2, without the "c" prefix
+	codecs     zarrCodecs
+	fill       any
+}
+
+// Appends a layer to the end of the file for every array of the Zarr v3 store, read as a file system whose
+// paths are its keys (such as os.DirFS), with the values of the array in a single channel named for it and
+// its dimensions in reverse. Each chunk becomes a tile, so the layer options only set compression and
+// separation. Evenly spaced coordinate arrays become axes, "units" attributes become units, and other
+// attributes become tags named "array:attribute". Sharded arrays and Zarr v2 stores are unsupported.
+func (p *Pixi) AppendZarr(w io.WriteSeeker, store fs.FS, opts ...LayerOption) error {
+	if p.ReadOnly {
+		return ErrReadOnly{Operation: "append Zarr"}
+	}
+	if _, err := fs.Stat(store, zarrMetadataKey); errors.Is(err, fs.ErrNotExist) {
+		if _, v2 := fs.Stat(store, ".zgroup"); v2 == nil {
+			return ErrUnsupported("Zarr v2 stores are not supported")
+		}
+		return ErrFormat("not a Zarr v3 store: no zarr.json at its root")
+	}
+
+	tags := map[string]string{}
+	arrays := []zarrArray{}
+	err := fs.WalkDir(store, ".", func(node string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+		meta, err := readZarrMetadata(store, node)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch meta.NodeType {
+		case "group":
+			for name, value := range meta.Attributes {
+				tags[zarrTagName(node, name)] = zarrText(value)
+			}
+			return nil
+		case "array":
+			array, err := newZarrArray(node, meta)
+			if err != nil {
+				return err
+			}
+			arrays = append(arrays, array)
+			return fs.SkipDir
+		}
+		return ErrFormat(fmt.Sprintf("node '%s' has unknown Zarr node type '%s'", node, meta.NodeType))
+	})
+	if err != nil {
+		return err
+	}
+
+	axes := map[string]*Axis{} // by the key of their coordinate array
+	for _, array := range arrays {
+		if !array.isCoordinate() {
+			continue
+		}
+		axis, err := array.coordinateAxis(store)
+		if err != nil {
+			return err
+		}
+		if axis == nil {
+			continue
+		}
+		axes[array.path] = axis
+		for name, value := range array.meta.Attributes {
+			if text, ok := value.(string); !ok || (name != "units" && (name != "calendar" || !isCalendar(text))) {
+				tags[zarrTagName(array.path, name)] = zarrText(value)
+			}
+		}
+	}
+
+	for _, array := range arrays {
+		if axes[array.path] != nil {
+			continue
+		}
+		name := array.path
+		if name == "." {
+			name = "data"
+		}
+		channel := Channel{Name: path.Base(name), Type: array.dtype}
+		for attr, value := range array.meta.Attributes {
+			switch attr {
+			case "units":
+				if unit, ok := value.(string); ok {
+					channel.Unit = unit
+					continue
+				}
+			case "_FillValue":
+				if fill, err := zarrScalar(array.dtype, value); err == nil {
+					channel.FillValue = fill
+					continue
+				}
+			}
+			tags[zarrTagName(name, attr)] = zarrText(value)
+		}
+		if channel.FillValue == nil && array.fill != array.dtype.FromFloat64(0) {
+			channel.FillValue = array.fill
+		}
+		if len(array.meta.Shape) == 0 {
+			chunk, err := array.readChunk(store, nil, 0, name)
+			if err != nil {
+				return err
+			}
+			value := array.fill
+			if chunk != nil {
+				value = array.dtype.Value(chunk, array.codecs.order)
+			}
+			tags[name] = fmt.Sprint(value)
+			continue
+		}
+
+		n := len(array.meta.Shape)
+		dims := make(DimensionSet, n)
+		for j, size := range array.meta.Shape {
+			coordinate := path.Join(path.Dir(array.path), array.dimensions[j])
+			dims[n-1-j] = Dimension{Name: array.dimensions[j], Size: size, TileSize: min(array.chunks[j], size), Axis: axes[coordinate]}
+		}
+		if dims.Samples() == 0 {
+			continue
+		}
+		layer := NewLayer(name, dims, ChannelSet{channel}, opts...)
+		if err := p.appendZarrArray(w, store, array, layer); err != nil {
+			return err
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return p.AppendTags(w, tags)
+}
+
+// Writes the chunks of the array as the tiles of the single channel of the layer, whose dimensions are
+// those of the array in reverse. Chunks that were never written are left as absent tiles if the channel has
+// a fill value, and written as tiles of the fill value of the array otherwise.
+func (p *Pixi) appendZarrArray(w io.WriteSeeker, store fs.FS, array zarrArray, layer Layer) error {
+	if layer.Dimensions.HasHalo() {
+		return ErrUnsupported("layers with halos must be appended with AppendHaloLayer")
+	}
+	p.checkpointed = false
+
+	// the stride of each dimension of the layer within a chunk, whose last dimension changes the most frequently
+	n := len(layer.Dimensions)
+	strides := make([]int, n)
+	stride := 1
+	for d := range n {
+		strides[d] = stride
+		stride *= array.chunks[n-1-d]
+	}
+	size := array.dtype.Size()
+	encoder := p.newTileEncoder(len(p.Layers))
+	for tile := range layer.DiskTiles() {
+		chunk, err := array.readChunk(store, layer.zarrChunkCoordinates(tile), tile, layer.Name)
+		if err != nil {
+			return err
+		}
+		var data []byte
+		switch {
+		case chunk == nil && layer.Channels.HasFillValues():
+			continue
+		case chunk == nil:
+			// absent tiles of channels without fill values cannot be read
+			if data, err = layer.FillTile(p.Header, tile, Sample{array.fill}); err != nil {
+				return err
+			}
+		default:
+			data = make([]byte, layer.DiskTileSize(tile))
+			for inTile := range layer.Dimensions.TileSamples() {
+				index, rest := 0, inTile
+				for d, dim := range layer.Dimensions {
+					index += rest % dim.TileSize * strides[d]
+					rest /= dim.TileSize
+				}
+				layer.putStoredValue(p.Header, data, 0, inTile, array.dtype.Value(chunk[index*size:], array.codecs.order))
+			}
+		}
+		if _, err := w.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+		if err := layer.writeTileWith(encoder, w, p.Header, tile, data); err != nil {
+			return err
+		}
+		layer.updateTileStatistics(p.Header, tile, data)
+	}
+	return p.appendLayerHeader(w, layer)
+}
+
+// Writes the layers of the file to a Zarr v3 store as the inverse of AppendZarr, with an array for every
+// channel chunked by the tiles of its layer. Each key of the store is written through a writer returned by
+// create, such as that of ZarrDirectory, and closed once written. Dimensions are shared between layers by
+// name and must have the same size in each. Absent tiles are left unwritten. Float8, bfloat16, and 128-bit
+// channels cannot be written.
+func (p *Pixi) WriteZarr(r io.ReadSeeker, create func(key string) (io.WriteCloser, error)) error {
+	dims, err := p.sharedDimensions()
+	if err != nil {
+		return err
+	}
+	tags := p.AllTags()
+	attributes := func(name string) map[string]any {
+		attrs := map[string]any{}
+		for attr, value := range variableTags(tags, name) {
+			attrs[attr] = value
+		}
+		return attrs
+	}
+
+	axes := []*Axis{}
+	arrays := []zarrArray{}
+	names := map[string]bool{}
+	for _, dim := range dims {
+		axis := dim.writtenAxis()
+		if axis == nil {
+			continue
+		}
+		attrs := attributes(dim.name)
+		if axis.Unit != "" {
+			attrs["units"] = axis.Unit
+		}
+		if axis.Calendar != CalendarStandard {
+			attrs["calendar"] = axis.Calendar.String()
+		}
+		array, err := newZarrOutput(dim.name, axis.Type, []int{dim.size}, []int{dim.size}, []string{dim.name}, nil, CompressionNone, 0, attrs)
+		if err != nil {
+			return ErrUnsupported(fmt.Sprintf("axis of dimension '%s': %v", dim.name, err))
+		}
+		axes = append(axes, axis)
+		arrays = append(arrays, array)
+		names[dim.name] = true
+	}
+	layerArrays := make([][]zarrArray, len(p.Layers))
+	for l, layer := range p.Layers {
+		n := len(layer.Dimensions)
+		shape, chunks, dimensionNames := make([]int, n), make([]int, n), make([]string, n)
+		for d, dim := range layer.Dimensions {
+			shape[n-1-d], chunks[n-1-d], dimensionNames[n-1-d] = dim.Size, dim.TileSize, dim.Name
+		}
+		for _, channel := range layer.Channels {
+			name := channel.Name
+			if names[name] {
+				name = layer.Name + "_" + channel.Name
+			}
+			attrs := attributes(name)
+			if channel.Unit != "" {
+				attrs["units"] = channel.Unit
+			}
+			array, err := newZarrOutput(name, channel.Type, shape, chunks, dimensionNames, channel.FillValue, layer.Compression, layer.CompressionLevel, attrs)
+			if err != nil {
+				return ErrUnsupported(fmt.Sprintf("channel '%s' of layer '%s': %v", channel.Name, layer.Name, err))
+			}
+			layerArrays[l] = append(layerArrays[l], array)
+			names[name] = true
+		}
+	}
+
+	root := zarrMetadata{ZarrFormat: 3, NodeType: "group", Attributes: map[string]any{}}
+	for key, value := range tags {
+		root.Attributes[key] = value
+	}
+	if err := writeZarrMetadata(create, ".", root); err != nil {
+		return err
+	}
+	for i, array := range arrays {
+		if err := writeZarrMetadata(create, array.path, array.meta); err != nil {
+			return err
+		}
+		values := make([]byte, array.meta.Shape[0]*array.dtype.Size())
+		for v := range array.meta.Shape[0] {
+			array.dtype.PutValue(axes[i].StepValue(v), binary.LittleEndian, values[v*array.dtype.Size():])
+		}
+		if err := array.writeChunk(create, []int{0}, values); err != nil {
+			return err
+		}
+	}
+	for l, layer := range p.Layers {
+		for _, array := range layerArrays[l] {
+			if err := writeZarrMetadata(create, array.path, array.meta); err != nil {
+				return err
+			}
+		}
+		if err := p.writeZarrLayer(r, create, layer, layerArrays[l]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes each stored tile of the layer as a chunk of the arrays of the channels it holds.
+func (p *Pixi) writeZarrLayer(r io.ReadSeeker, create func(key string) (io.WriteCloser, error), layer Layer, arrays []zarrArray) error {
+	samples := layer.Dimensions.TileSamples()
+	tiles, tilesErr := layer.Tiles(r, p.Header)
+	for index, data := range tiles {
+		if layer.TileBytes[index] == 0 {
+			continue
+		}
+		tile, first, end := index, 0, len(layer.Channels)
+		if layer.Separated {
+			tile, first = index%layer.Dimensions.Tiles(), index/layer.Dimensions.Tiles()
+			end = first + 1
+		}
+		coords := layer.zarrChunkCoordinates(tile)
+		for c := first; c < end; c++ {
+			array := &arrays[c]
+			size := array.dtype.Size()
+			values := make([]byte, samples*size)
+			for i := range samples {
+				array.dtype.PutValue(layer.storedValue(p.Header, data, c, i), binary.LittleEndian, values[i*size:])
+			}
+			if err := array.writeChunk(create, coords, values); err != nil {
+				return err
+			}
+		}
+	}
+	return tilesErr()
+}
+
+// Creates the keys of a Zarr store in the directory of the local file system, as the create function of
+// WriteZarr, making the directories of each key as needed. The store is read back with os.DirFS.
+func ZarrDirectory(dir string) func(key string) (io.WriteCloser, error) {
+	return func(key string) (io.WriteCloser, error) {
+		name := filepath.Join(dir, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return nil, err
+		}
+		return os.Create(name)
+	}
+}
+
+// The coordinates in the chunk grid of a Zarr array of the tile with the given index in the dimensions of
+// the layer, which are those of the array in reverse.
+func (l Layer) zarrChunkCoordinates(tile int) []int {
+	n := len(l.Dimensions)
+	coords := make([]int, n)
+	for d, dim := range l.Dimensions {
+		coords[n-1-d] = tile % dim.Tiles()
+		tile /= dim.Tiles()
+	}
+	return coords
+}
+
+// Reads the metadata document of the node of the store with the given key.
+func readZarrMetadata(store fs.FS, node string) (zarrMetadata, error) {
+	data, err := fs.ReadFile(store, path.Join(node, zarrMetadataKey))
+	if err != nil {
+		return zarrMetadata{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var meta zarrMetadata
+	if err := decoder.Decode(&meta); err != nil {
+		return zarrMetadata{}, ErrFormat(fmt.Sprintf("Zarr metadata of '%s': %v", node, err))
+	}
+	if meta.ZarrFormat != 3 {
+		return zarrMetadata{}, ErrUnsupported(fmt.Sprintf("Zarr format %d of '%s'", meta.ZarrFormat, node))
+	}
+	return meta, nil
+}
+
+// Writes the metadata document of the node of the store with the given key.
+func writeZarrMetadata(create func(key string) (io.WriteCloser, error), node string, meta zarrMetadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeZarrKey(create, path.Join(node, zarrMetadataKey), data)
+}
+
+func writeZarrKey(create func(key string) (io.WriteCloser, error), key string, data []byte) error {
+	w, err := create(key)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return errors.Join(err, w.Close())
+}
+
+// Validates the metadata of the array node of a store with the given key, returning the array it describes.
+func newZarrArray(node string, meta zarrMetadata) (zarrArray, error) {
+	array := zarrArray{path: node, meta: meta, separator: "/"}
+	dataType, _ := meta.DataType.(string)
+	for t, name := range zarrDataTypes {
+		if name == dataType {
+			array.dtype = t
+		}
+	}
+	if array.dtype == ChannelUnknown {
+		return zarrArray{}, ErrUnsupported(fmt.Sprintf("Zarr data type %v of array '%s'", meta.DataType, node))
+	}
+	if len(meta.StorageTransformers) > 0 {
+		return zarrArray{}, ErrUnsupported(fmt.Sprintf("storage transformers of Zarr array '%s'", node))
+	}
+
+	if meta.ChunkGrid == nil || meta.ChunkGrid.Name != "regular" {
+		return zarrArray{}, ErrUnsupported(fmt.Sprintf("chunk grid of Zarr array '%s' is not regular", node))
+	}
+	shape, _ := meta.ChunkGrid.Configuration["chunk_shape"].([]any)
+	if len(shape) != len(meta.Shape) {
+		return zarrArray{}, ErrFormat(fmt.Sprintf("chunk shape of Zarr array '%s' does not match its %d dimensions", node, len(meta.Shape)))
+	}
+	for j, size := range shape {
+		chunk, err := strconv.Atoi(zarrText(size))
+		if err != nil || chunk <= 0 || meta.Shape[j] < 0 {
+			return zarrArray{}, ErrFormat(fmt.Sprintf("invalid shape or chunk shape of Zarr array '%s'", node))
+		}
+		array.chunks = append(array.chunks, chunk)
+	}
+
+	if meta.ChunkKeyEncoding != nil {
+		switch meta.ChunkKeyEncoding.Name {
+		case "default":
+		case "v2":
+			array.separator, array.v2Keys = ".", true
+		default:
+			return zarrArray{}, ErrUnsupported(fmt.Sprintf("chunk key encoding '%s' of Zarr array '%s'", meta.ChunkKeyEncoding.Name, node))
+		}
+		if separator, ok := meta.ChunkKeyEncoding.Configuration["separator"].(string); ok {
+			array.separator = separator
+		}
+	}
+
+	for j := range meta.Shape {
+		name := fmt.Sprintf("dim_%d", j)
+		if j < len(meta.DimensionNames) && meta.DimensionNames[j] != nil {
+			name = *meta.DimensionNames[j]
+		}
+		array.dimensions = append(array.dimensions, name)
+	}
+
+	codecs, err := newZarrCodecs(meta.Codecs)
+	if err != nil {
+		return zarrArray{}, ErrUnsupported(fmt.Sprintf("codecs of Zarr array '%s': %v", node, err))
+	}
+	array.codecs = codecs
+	if array.fill, err = zarrScalar(array.dtype, meta.FillValue); err != nil {
+		return zarrArray{}, ErrFormat(fmt.Sprintf("fill value of Zarr array '%s': %v", node, err))
+	}
+	return array, nil
+}
+
+// Builds the array written by WriteZarr with the given key, encoding its chunks with the codec equivalent
+// to the compression.
+func newZarrOutput(node string, t ChannelType, shape []int, chunks []int, dimensions []string, fill any, compression Compression, level int, attrs map[string]any) (zarrArray, error) {
+	dataType, ok := zarrDataTypes[t.Base()]
+	if !ok {
+		return zarrArray{}, fmt.Errorf("type %v has no Zarr equivalent", t.Base())
+	}
+	meta := zarrMetadata{
+		ZarrFormat:       3,
+		NodeType:         "array",
+		Shape:            shape,
+		DataType:         dataType,
+		ChunkGrid:        &zarrExtension{Name: "regular", Configuration: map[string]any{"chunk_shape": chunks}},
+		ChunkKeyEncoding: &zarrExtension{Name: "default", Configuration: map[string]any{"separator": "/"}},
+		FillValue:        zarrJSONScalar(t, t.FromFloat64(0)),
+		Attributes:       attrs,
+	}
+	if fill != nil {
+		meta.FillValue = zarrJSONScalar(t, fill)
+		attrs["_FillValue"] = meta.FillValue
+	}
+	codec := zarrExtension{Name: "bytes"}
+	if t.Size() > 1 {
+		codec.Configuration = map[string]any{"endian": "little"}
+	}
+	meta.Codecs = []zarrExtension{codec}
+	switch compression {
+	case CompressionNone:
+	case CompressionFlate:
+		if level < 1 || level > 9 {
+			level = 6
+		}
+		meta.Codecs = append(meta.Codecs, zarrExtension{Name: "gzip", Configuration: map[string]any{"level": level}})
+	default:
+		if compression != CompressionZstd {
+			level = 0
+		}
+		meta.Codecs = append(meta.Codecs, zarrExtension{Name: "zstd", Configuration: map[string]any{"level": level, "checksum": false}})
+	}
+	for _, name := range dimensions {
+		meta.DimensionNames = append(meta.DimensionNames, &name)
+	}
+
+	// round trip the metadata, so that it is read back as it is written
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return zarrArray{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&meta); err != nil {
+		return zarrArray{}, err
+	}
+	return newZarrArray(node, meta)
+}
+
+// Whether the array is a coordinate array: one-dimensional, and named for its dimension.
+func (a zarrArray) isCoordinate() bool {
+	return len(a.dimensions) == 1 && a.dimensions[0] == path.Base(a.path)
+}
+
+// Reads the values of the coordinate array and returns the axis they fall on, or nil if they are not evenly
+// spaced.
+func (a zarrArray) coordinateAxis(store fs.FS) (*Axis, error) {
+	if a.dtype == ChannelBool {
+		return nil, nil
+	}
+	size, chunk := a.meta.Shape[0], a.chunks[0]
+	values := make([]float64, size)
+	for c := range (size + chunk - 1) / chunk {
+		data, err := a.readChunk(store, []int{c}, c, a.path)
+		if err != nil {
+			return nil, err
+		}
+		for i := c * chunk; i < min(size, (c+1)*chunk); i++ {
+			value := a.fill
+			if data != nil {
+				value = a.dtype.Value(data[(i-c*chunk)*a.dtype.Size():], a.codecs.order)
+			}
+			values[i], _ = a.dtype.ToFloat64(value)
+		}
+	}
+	axis := regularAxis(a.dtype, values)
+	if axis != nil {
+		axis.Unit, _ = a.meta.Attributes["units"].(string)
+		if calendar, ok := a.meta.Attributes["calendar"].(string); ok {
+			axis.Calendar, _ = ParseCalendar(calendar)
+		}
+	}
+	return axis, nil
+}
+
+// The key of the chunk at the given coordinates of the chunk grid.
+func (a zarrArray) chunkKey(coords []int) string {
+	parts := make([]string, len(coords))
+	for i, c := range coords {
+		parts[i] = strconv.Itoa(c)
+	}
+	if a.v2Keys {
+		if len(parts) == 0 {
+			return path.Join(a.path, "0")
+		}
+		return path.Join(a.path, strings.Join(parts, a.separator))
+	}
+	return path.Join(a.path, strings.Join(append([]string{"c"}, parts...), a.separator))
+}
+
+// Reads and decodes the chunk at the given coordinates of the chunk grid, returning nil if it was never
+// written. The tile and layer name are those reported by a checksum error.
+func (a zarrArray) readChunk(store fs.FS, coords []int, tile int, layer string) ([]byte, error) {
+	data, err := fs.ReadFile(store, a.chunkKey(coords))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err = a.codecs.decode(data, tile, layer)
+	if err != nil {
+		return nil, err
+	}
+	samples := 1
+	for _, chunk := range a.chunks {
+		samples *= chunk
+	}
+	if len(data) != samples*a.dtype.Size() {
+		return nil, ErrFormat(fmt.Sprintf("chunk '%s' decodes to %d bytes, expected %d", a.chunkKey(coords), len(data), samples*a.dtype.Size()))
+	}
+	return data, nil
+}
+
+// Encodes the little-endian values of the chunk at the given coordinates of the chunk grid, and writes it.
+func (a *zarrArray) writeChunk(create func(key string) (io.WriteCloser, error), coords []int, values []byte) error {
+	data, err := a.codecs.encode(values)
+	if err != nil {
+		return err
+	}
+	return writeZarrKey(create, a.chunkKey(coords), data)
+}
+
+// The codecs encoding the chunks of a Zarr array: the byte order of its values written by the bytes codec,
+// and the codecs applied to those bytes in order.
+type zarrCodecs struct {
+	order   binary.ByteOrder
+	bytes   []zarrExtension
+	encoder *zstd.Encoder // for encoding with the zstd codec, created when first needed
+}
+
+func newZarrCodecs(codecs []zarrExtension) (zarrCodecs, error) {
+	c := zarrCodecs{}
+	for _, codec := range codecs {
+		switch codec.Name {
+		case "bytes":
+			if c.order != nil {
+				return zarrCodecs{}, errors.New("more than one bytes codec")
+			}
+			c.order = binary.ByteOrder(binary.LittleEndian)
+			if endian, _ := codec.Configuration["endian"].(string); endian == "big" {
+				c.order = binary.BigEndian
+			}
+		case "gzip", "zstd", "crc32c":
+			if c.order == nil {
+				return zarrCodecs{}, fmt.Errorf("codec '%s' before the bytes codec", codec.Name)
+			}
+			c.bytes = append(c.bytes, codec)
+		default:
+			return zarrCodecs{}, fmt.Errorf("codec '%s' is not supported", codec.Name)
+		}
+	}
+	if c.order == nil {
+		return zarrCodecs{}, errors.New("no bytes codec")
+	}
+	return c, nil
+}
+
+// Decodes the bytes of a chunk as stored into the values of its array. The tile and layer name are those
+// reported by a checksum error.
+func (c zarrCodecs) decode(data []byte, tile int, layer string) ([]byte, error) {
+	for _, codec := range slices.Backward(c.bytes) {
+		switch codec.Name {
+		case "gzip":
+			reader, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			if data, err = io.ReadAll(reader); err != nil {
+				return nil, err
+			}
+		case "zstd":
+			decoder, err := zstdDecoder()
+			if err != nil {
+				return nil, err
+			}
+			if data, err = decoder.DecodeAll(data, nil); err != nil {
+				return nil, err
+			}
+		case "crc32c":
+			if len(data) < 4 {
+				return nil, ErrFormat("chunk too short for its crc32c checksum")
+			}
+			checksum := binary.LittleEndian.Uint32(data[len(data)-4:])
+			if data = data[:len(data)-4]; crc32.Checksum(data, castagnoli) != checksum {
+				return nil, ErrChecksum{TileIndex: tile, LayerName: layer}
+			}
+		}
+	}
+	return data, nil
+}
+
+// Encodes the values of a chunk into the bytes stored for it.
+func (c *zarrCodecs) encode(data []byte) ([]byte, error) {
+	for _, codec := range c.bytes {
+		switch codec.Name {
+		case "gzip":
+			var buf bytes.Buffer
+			writer, err := gzip.NewWriterLevel(&buf, zarrConfigInt(codec, "level", gzip.DefaultCompression))
+			if err != nil {
+				return nil, err
+			}
+			if _, err := writer.Write(data); err != nil {
+				return nil, err
+			}
+			if err := writer.Close(); err != nil {
+				return nil, err
+			}
+			data = buf.Bytes()
+		case "zstd":
+			if c.encoder == nil {
+				level := zstd.SpeedDefault
+				if l := zarrConfigInt(codec, "level", 0); l != 0 {
+					level = zstd.EncoderLevelFromZstd(l)
+				}
+				encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+				if err != nil {
+					return nil, err
+				}
+				c.encoder = encoder
+			}
+			data = c.encoder.EncodeAll(data, nil)
+		case "crc32c":
+			data = binary.LittleEndian.AppendUint32(data, crc32.Checksum(data, castagnoli))
+		}
+	}
+	return data, nil
+}
+
+func zarrConfigInt(codec zarrExtension, key string, fallback int) int {
+	if value, err := strconv.Atoi(zarrText(codec.Configuration[key])); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// Decodes a scalar of the channel type from its form in Zarr metadata: a number; a boolean; one of the
+// strings "NaN", "Infinity", and "-Infinity"; or the bits of a float as a hexadecimal string.
+func zarrScalar(t ChannelType, value any) (any, error) {
+	switch v := value.(type) {
+	case bool:
+		if t == ChannelBool {
+			return v, nil
+		}
+	case json.Number:
+		switch t {
+		case ChannelInt64:
+			return strconv.ParseInt(v.String(), 10, 64)
+		case ChannelUint64:
+			return strconv.ParseUint(v.String(), 10, 64)
+		case ChannelBool:
+		default:
+			f, err := v.Float64()
+			if err != nil {
+				return nil, err
+			}
+			if !isFloatChannel(t) && f != math.Trunc(f) {
+				break
+			}
+			return t.FromFloat64(f), nil
+		}
+	case string:
+		if !isFloatChannel(t) {
+			break
+		}
+		switch v {
+		case "NaN":
+			return t.FromFloat64(math.NaN()), nil
+		case "Infinity":
+			return t.FromFloat64(math.Inf(1)), nil
+		case "-Infinity":
+			return t.FromFloat64(math.Inf(-1)), nil
+		}
+		if hex, ok := strings.CutPrefix(v, "0x"); ok {
+			bits, err := strconv.ParseUint(hex, 16, t.Size()*8)
+			if err != nil {
+				return nil, err
+			}
+			switch t {
+			case ChannelFloat16:
+				return float16.Frombits(uint16(bits)), nil
+			case ChannelFloat32:
+				return math.Float32frombits(uint32(bits)), nil
+			default:
+				return math.Float64frombits(bits), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%v is not a value of type %v", value, t)
+}
+
+// Encodes a value of the channel type in its form in Zarr metadata, as decoded by zarrScalar.
+func zarrJSONScalar(t ChannelType, value any) any {
+	switch v := value.(type) {
+	case bool, int64, uint64:
+		return v
+	}
+	f, _ := t.ToFloat64(value)
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case isFloatChannel(t):
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// The name of the tag holding an attribute of the node of a store with the given key.
+func zarrTagName(node string, attr string) string {
+	if node == "." {
+		return attr
+	}
+	return node + ":" + attr
+}
+
+// A value of Zarr metadata as text: strings as they are, lists as their values separated by commas, and
+// other values as JSON.
+func zarrText(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case []any:
+		values := make([]string, len(v))
+		for i, value := range v {
+			values[i] = zarrText(value)
+		}
+		return strings.Join(values, ",")
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
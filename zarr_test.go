@@ -0,0 +1,363 @@
+package gopixi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// A key of an in-memory Zarr store being written, stored once closed.
+type zarrMemoryKey struct {
+	bytes.Buffer
+	store fstest.MapFS
+	key   string
+}
+
+func (k *zarrMemoryKey) Close() error {
+	k.store[k.key] = &fstest.MapFile{Data: k.Bytes()}
+	return nil
+}
+
+func memoryZarrStore() (fstest.MapFS, func(key string) (io.WriteCloser, error)) {
+	store := fstest.MapFS{}
+	return store, func(key string) (io.WriteCloser, error) {
+		return &zarrMemoryKey{store: store, key: key}, nil
+	}
+}
+
+func zarrTestMetadata(t *testing.T, store fstest.MapFS, key string) map[string]any {
+	t.Helper()
+	file, ok := store[key]
+	if !ok {
+		t.Fatalf("expected the store to hold %s", key)
+	}
+	meta := map[string]any{}
+	if err := json.Unmarshal(file.Data, &meta); err != nil {
+		t.Fatal(err)
+	}
+	return meta
+}
+
+func TestZarrRoundTrip(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.BigEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := []Layer{
+		NewLayer("weather", DimensionSet{
+			{Name: "lon", Size: 5, TileSize: 2, Axis: &Axis{Type: ChannelFloat64, Minimum: -10.0, Step: 2.5, Unit: "degrees_east"}},
+			{Name: "lat", Size: 4, TileSize: 4, Axis: &Axis{Type: ChannelFloat32, Minimum: float32(50), Step: float32(-0.5), Unit: "degrees_north"}},
+			{Name: "time", Size: 3, TileSize: 2},
+		}, ChannelSet{
+			{Name: "temp", Type: ChannelFloat32, Unit: "K", FillValue: float32(-999)},
+			{Name: "mask", Type: ChannelBool},
+			{Name: "count", Type: ChannelUint16},
+		}, WithCompression(CompressionZstd)),
+		NewLayer("terrain", DimensionSet{{Name: "lon", Size: 5, TileSize: 3}, {Name: "lat", Size: 4, TileSize: 2}}, ChannelSet{
+			{Name: "height", Type: ChannelInt64, FillValue: int64(-1)},
+			{Name: "temp", Type: ChannelFloat64},
+		}, WithPlanar(), WithCompression(CompressionFlate), WithSparseTiles()),
+	}
+	sampleAt := func(layer int, c SampleCoordinate) Sample {
+		switch {
+		case layer == 0:
+			return Sample{float32(c[0]) + float32(c[1])/4 + float32(c[2])*10, (c[0]+c[2])%2 == 0, uint16(60000 + c[0]*c[1])}
+		case c[0] >= 3 || c[1] >= 2:
+			return Sample{int64(c[0]*1_000_000_000_000 + c[1]), float64(c[0]) / 3}
+		}
+		// the first tile of each channel is left unwritten
+		return Sample{int64(-1), 0.0}
+	}
+	for i, layer := range layers {
+		writer := NewTileOrderWriteIterator(file.Stream(), file.Header, layer)
+		err = file.AppendIterativeLayer(file.Stream(), layer, writer, func(writer IterativeLayerWriter) error {
+			for writer.Next() {
+				writer.SetSample(sampleAt(i, writer.Coordinate()))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := file.AppendTags(file.Stream(), map[string]string{"title": "test weather", "temp:long_name": "air temperature"}); err != nil {
+		t.Fatal(err)
+	}
+
+	store, create := memoryZarrStore()
+	if err := file.WriteZarr(file.Stream(), create); err != nil {
+		t.Fatal(err)
+	}
+	if root := zarrTestMetadata(t, store, "zarr.json"); root["node_type"] != "group" || root["attributes"].(map[string]any)["title"] != "test weather" {
+		t.Errorf("expected a root group with the tags as attributes, got %v", root)
+	}
+	temp := zarrTestMetadata(t, store, "temp/zarr.json")
+	for key, want := range map[string]string{
+		"shape":           "[3,4,5]",
+		"chunk_grid":      `{"configuration":{"chunk_shape":[2,4,2]},"name":"regular"}`,
+		"dimension_names": `["time","lat","lon"]`,
+		"fill_value":      "-999",
+		"codecs":          `[{"configuration":{"endian":"little"},"name":"bytes"},{"configuration":{"checksum":false,"level":0},"name":"zstd"}]`,
+		"attributes":      `{"_FillValue":-999,"long_name":"air temperature","units":"K"}`,
+	} {
+		if got, _ := json.Marshal(temp[key]); string(got) != want {
+			t.Errorf("expected %s of temp to be %s, got %s", key, want, got)
+		}
+	}
+	if lon := zarrTestMetadata(t, store, "lon/zarr.json"); lon["data_type"] != "float64" || lon["attributes"].(map[string]any)["units"] != "degrees_east" {
+		t.Errorf("expected a coordinate array for lon, got %v", lon)
+	}
+	if _, ok := store["height/c/0/0"]; ok {
+		t.Error("expected the unwritten tile of height to be left out of the store")
+	}
+	if _, ok := store["terrain_temp/c/1/1"]; !ok {
+		t.Error("expected the temp channel of terrain to be renamed by its layer")
+	}
+
+	imported, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imported.AppendZarr(imported.Stream(), store, WithCompression(CompressionLz4)); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, l := range imported.Layers {
+		names = append(names, l.Name)
+	}
+	if !slices.Equal(names, []string{"count", "height", "mask", "temp", "terrain_temp"}) {
+		t.Fatalf("expected an array per channel, with the coordinates as axes, got %v", names)
+	}
+	if tags := imported.AllTags(); tags["title"] != "test weather" || tags["temp:long_name"] != "air temperature" || len(tags) != 2 {
+		t.Errorf("expected the attributes to be kept as tags, got %v", tags)
+	}
+	reimported := imported.Layers[3]
+	if c := reimported.Channels[0]; c.Unit != "K" || c.FillValue != float32(-999) || c.Type != ChannelFloat32 {
+		t.Errorf("expected the units and fill value to round trip, got %v", c)
+	}
+	for d, dim := range reimported.Dimensions {
+		want := layers[0].Dimensions[d]
+		if dim.Name != want.Name || dim.Size != want.Size || dim.TileSize != want.TileSize {
+			t.Errorf("expected dimension %v, got %v", want, dim)
+		}
+		if !axesEqual(dim.Axis, want.Axis) {
+			t.Errorf("expected axis %v of dimension %s, got %v", want.Axis, dim.Name, dim.Axis)
+		}
+	}
+	if height := imported.Layers[1]; height.TileBytes[0] != 0 || height.Channels[0].FillValue != int64(-1) {
+		t.Errorf("expected the unwritten chunk of height to be an absent tile filled by -1, got %v", height)
+	}
+
+	sources := map[string][2]int{"temp": {0, 0}, "mask": {0, 1}, "count": {0, 2}, "height": {1, 0}, "terrain_temp": {1, 1}}
+	for i, l := range imported.Layers {
+		source := sources[l.Name]
+		if l.Channels[0].Type != layers[source[0]].Channels[source[1]].Type {
+			t.Errorf("expected %s to keep its channel type, got %v", l.Name, l.Channels[0].Type)
+		}
+		accessor, err := imported.Layer(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for coord := range l.Dimensions.SampleCoordinates() {
+			got, err := SampleAt(accessor, coord)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := sampleAt(source[0], coord)[source[1]]; got[0] != want {
+				t.Fatalf("%s at %v imported as %v, expected %v", l.Name, coord, got[0], want)
+			}
+		}
+	}
+}
+
+// Writes a Zarr v3 array holding the given chunks to the store.
+func putZarrArray(t *testing.T, store fstest.MapFS, key string, meta string, chunks map[string][]byte) {
+	t.Helper()
+	store[key+"/zarr.json"] = &fstest.MapFile{Data: []byte(meta)}
+	for chunk, data := range chunks {
+		store[key+"/"+chunk] = &fstest.MapFile{Data: data}
+	}
+}
+
+func TestAppendZarrCodecs(t *testing.T) {
+	values := make([]byte, 2*8*2)
+	for i := range 2 {
+		for j := range 5 {
+			binary.BigEndian.PutUint16(values[(i*8+j)*2:], uint16(i*10+j))
+		}
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(values)
+	writer.Close()
+	chunk := binary.LittleEndian.AppendUint32(compressed.Bytes(), crc32.Checksum(compressed.Bytes(), castagnoli))
+
+	store := fstest.MapFS{"zarr.json": &fstest.MapFile{Data: []byte(`{"zarr_format":3,"node_type":"group","attributes":{"history":"by hand","levels":[1,2.5]}}`)}}
+	putZarrArray(t, store, "a", `{"zarr_format":3,"node_type":"array","shape":[3,5],"data_type":"int16",
+		"chunk_grid":{"name":"regular","configuration":{"chunk_shape":[2,8]}},
+		"chunk_key_encoding":{"name":"v2","configuration":{"separator":"."}},"fill_value":7,
+		"codecs":[{"name":"bytes","configuration":{"endian":"big"}},{"name":"gzip","configuration":{"level":1}},{"name":"crc32c"}],
+		"attributes":{"valid_range":[0,20]}}`, map[string][]byte{"0.0": chunk})
+	putZarrArray(t, store, "scale", `{"zarr_format":3,"node_type":"array","shape":[],"data_type":"float64",
+		"chunk_grid":{"name":"regular","configuration":{"chunk_shape":[]}},"fill_value":"NaN","codecs":[{"name":"bytes"}]}`,
+		map[string][]byte{"c": binary.LittleEndian.AppendUint64(nil, 0x3fe0000000000000)})
+	store["sub/zarr.json"] = &fstest.MapFile{Data: []byte(`{"zarr_format":3,"node_type":"group","attributes":{"note":"nested"}}`)}
+	coordinates := []byte{}
+	for _, v := range []int32{0, 1, 5} {
+		coordinates = binary.LittleEndian.AppendUint32(coordinates, uint32(v))
+	}
+	putZarrArray(t, store, "sub/x", `{"zarr_format":3,"node_type":"array","shape":[3],"data_type":"int32",
+		"chunk_grid":{"name":"regular","configuration":{"chunk_shape":[2]}},"fill_value":0,
+		"codecs":[{"name":"bytes","configuration":{"endian":"little"}}],"dimension_names":["x"]}`,
+		map[string][]byte{"c/0": coordinates[:8], "c/1": append(slices.Clone(coordinates[8:]), 0, 0, 0, 0)})
+
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.AppendZarr(file.Stream(), store); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, l := range file.Layers {
+		names = append(names, l.Name)
+	}
+	if !slices.Equal(names, []string{"a", "sub/x"}) {
+		t.Fatalf("expected the uneven coordinate array to be kept as a layer, got %v", names)
+	}
+	tags := file.AllTags()
+	for key, want := range map[string]string{"history": "by hand", "levels": "1,2.5", "scale": "0.5", "sub:note": "nested", "a:valid_range": "0,20"} {
+		if tags[key] != want {
+			t.Errorf("expected tag %s to be %q, got %q", key, want, tags[key])
+		}
+	}
+
+	a := file.Layers[0]
+	if want := (DimensionSet{{Name: "dim_1", Size: 5, TileSize: 5}, {Name: "dim_0", Size: 3, TileSize: 2}}); !slices.Equal(a.Dimensions, want) {
+		t.Errorf("expected dimensions %v, got %v", want, a.Dimensions)
+	}
+	if a.Channels[0].FillValue != int16(7) || a.TileBytes[1] != 0 {
+		t.Errorf("expected the missing chunk to be an absent tile filled by 7, got %v", a)
+	}
+	accessor, err := file.Layer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSamples[int16](accessor, "a", SampleCoordinate{0, 0}, SampleCoordinate{4, 0}, SampleCoordinate{2, 1}, SampleCoordinate{3, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []int16{0, 4, 12, 7}) {
+		t.Errorf("expected the values of a to read as [0 4 12 7], got %v", got)
+	}
+	accessor, err = file.Layer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadSamples[int32](accessor, "x", SampleCoordinate{0}, SampleCoordinate{1}, SampleCoordinate{2}); err != nil || !slices.Equal(got, []int32{0, 1, 5}) {
+		t.Errorf("expected the coordinates of x to read as [0 1 5], got %v (%v)", got, err)
+	}
+}
+
+func TestZarrCoordinateAxis(t *testing.T) {
+	store := fstest.MapFS{"zarr.json": &fstest.MapFile{Data: []byte(`{"zarr_format":3,"node_type":"group"}`)}}
+	putZarrArray(t, store, "x", `{"zarr_format":3,"node_type":"array","shape":[3],"data_type":"float32",
+		"chunk_grid":{"name":"regular","configuration":{"chunk_shape":[3]}},"fill_value":0,
+		"codecs":[{"name":"bytes","configuration":{"endian":"little"}}],"dimension_names":["x"],"attributes":{"units":"m"}}`,
+		map[string][]byte{"c/0": binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, 0x3f800000), 0x40000000), 0x40400000)})
+	putZarrArray(t, store, "v", `{"zarr_format":3,"node_type":"array","shape":[3],"data_type":"uint8",
+		"chunk_grid":{"name":"regular","configuration":{"chunk_shape":[3]}},"fill_value":0,
+		"codecs":[{"name":"bytes"}],"dimension_names":["x"]}`, map[string][]byte{"c/0": {1, 2, 3}})
+
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.AppendZarr(file.Stream(), store); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Layers) != 1 {
+		t.Fatalf("expected the coordinate array to become an axis, got %d layers", len(file.Layers))
+	}
+	axis := file.Layers[0].Dimensions[0].Axis
+	if axis == nil || axis.Minimum != float32(1) || axis.Step != float32(1) || axis.Unit != "m" {
+		t.Errorf("expected an axis from 1 by 1 in meters, got %v", axis)
+	}
+}
+
+func TestZarrErrors(t *testing.T) {
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unsupported ErrUnsupported
+	var format ErrFormat
+	if err := file.AppendZarr(file.Stream(), fstest.MapFS{".zgroup": &fstest.MapFile{Data: []byte(`{"zarr_format":2}`)}}); !errors.As(err, &unsupported) {
+		t.Errorf("expected ErrUnsupported for a Zarr v2 store, got %v", err)
+	}
+	if err := file.AppendZarr(file.Stream(), fstest.MapFS{}); !errors.As(err, &format) {
+		t.Errorf("expected ErrFormat for a store with no metadata, got %v", err)
+	}
+
+	root := &fstest.MapFile{Data: []byte(`{"zarr_format":3,"node_type":"group"}`)}
+	sharded := fstest.MapFS{"zarr.json": root}
+	putZarrArray(t, sharded, "s", `{"zarr_format":3,"node_type":"array","shape":[4],"data_type":"uint8",
+		"chunk_grid":{"name":"regular","configuration":{"chunk_shape":[4]}},"fill_value":0,
+		"codecs":[{"name":"sharding_indexed","configuration":{}}]}`, nil)
+	if err := file.AppendZarr(file.Stream(), sharded); !errors.As(err, &unsupported) {
+		t.Errorf("expected ErrUnsupported for a sharded array, got %v", err)
+	}
+
+	corrupt := fstest.MapFS{"zarr.json": root}
+	putZarrArray(t, corrupt, "c", `{"zarr_format":3,"node_type":"array","shape":[4],"data_type":"uint8",
+		"chunk_grid":{"name":"regular","configuration":{"chunk_shape":[4]}},"fill_value":0,
+		"codecs":[{"name":"bytes"},{"name":"crc32c"}]}`, map[string][]byte{"c/0": {1, 2, 3, 4, 0, 0, 0, 0}})
+	var integrity ErrDataIntegrity
+	if err := file.AppendZarr(file.Stream(), corrupt); !errors.As(err, &integrity) || integrity.LayerName != "c" {
+		t.Errorf("expected a checksum error for a corrupt chunk, got %v", err)
+	}
+
+	file.Layers = []Layer{NewLayer("narrow", DimensionSet{{Name: "x", Size: 2, TileSize: 2}}, ChannelSet{{Name: "v", Type: ChannelFloat8}})}
+	_, create := memoryZarrStore()
+	if err := file.WriteZarr(file.Stream(), create); !errors.As(err, &unsupported) {
+		t.Errorf("expected ErrUnsupported for a float8 channel, got %v", err)
+	}
+}
+
+func TestZarrDirectory(t *testing.T) {
+	data, err := fs.ReadFile(goldenCorpus(), "compression-none-separated.pixi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := source.WriteZarr(source.Stream(), ZarrDirectory(dir)); err != nil {
+		t.Fatal(err)
+	}
+	file, err := NewMemoryFile(NewHeader(binary.LittleEndian, OffsetSize4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.AppendZarr(file.Stream(), os.DirFS(dir)); err != nil {
+		t.Fatal(err)
+	}
+	channels := 0
+	for _, layer := range source.Layers {
+		channels += len(layer.Channels)
+	}
+	if len(file.Layers) != channels {
+		t.Errorf("expected a layer per channel of the golden file, got %d", len(file.Layers))
+	}
+}
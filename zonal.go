@@ -0,0 +1,138 @@
+package gopixi
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// The statistics of the values of one channel within a zone. Missing values are not counted.
+type ZoneStatistics struct {
+	Count int64
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+// The mean of the values within the zone, or NaN if it has none.
+func (s ZoneStatistics) Mean() float64 {
+	if s.Count == 0 {
+		return math.NaN()
+	}
+	return s.Sum / float64(s.Count)
+}
+
+func (s *ZoneStatistics) add(value float64) {
+	if math.IsNaN(value) {
+		return
+	}
+	if s.Count == 0 {
+		s.Min, s.Max = value, value
+	} else {
+		s.Min, s.Max = math.Min(s.Min, value), math.Max(s.Max, value)
+	}
+	s.Count++
+	s.Sum += value
+}
+
+// The statistics of every channel of a value layer within one zone of a label layer, computed by ZonalStats.
+type Zone struct {
+	Label    int64
+	Samples  int64            // The number of samples labelled with the zone, including those with missing values.
+	Channels []ZoneStatistics // The statistics of each channel of the value layer, in order.
+}
+
+// Computes the statistics of every channel of the value layer within each zone of the label layer, whose
+// first channel (of an integer or boolean type) holds the zone of each sample. The labels are broadcast as in
+// AppendOperation, and samples labelled with their fill value belong to no zone. Zones are returned in order
+// of their labels.
+func ZonalStats(values Operand, zones Operand) ([]Zone, error) {
+	if values.Layer == nil || zones.Layer == nil {
+		return nil, ErrFormat("zonal statistics require a value layer and a label layer")
+	}
+	layer := values.Layer.Layer()
+	labels := zones.Layer.Layer()
+	labelType := labels.Channels[0].Type.Base()
+	if bits, _ := integerBits(labelType); bits == 0 || bits > 64 {
+		return nil, ErrFormat(fmt.Sprintf("label layer '%s' has type %v, expected an integer type of at most 64 bits", labels.Name, labelType))
+	}
+	mapped, err := broadcastCoordinates(layer, labels)
+	if err != nil {
+		return nil, err
+	}
+	bound, err := values.bind(layer)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[int64]*Zone{}
+	sample := make([]float64, len(layer.Channels))
+	for tile := range layer.Dimensions.Tiles() {
+		var sampleErr error
+		layer.forEachTileSample(tile, func(inTile int, coord SampleCoordinate) {
+			if sampleErr != nil {
+				return
+			}
+			var label any
+			if label, sampleErr = ChannelAt(zones.Layer, mapped(coord), 0); sampleErr != nil {
+				return
+			}
+			zoneLabel := labelValue(label)
+			if zones.Fill != nil && float64(zoneLabel) == *zones.Fill {
+				return
+			}
+			zone, ok := found[zoneLabel]
+			if !ok {
+				zone = &Zone{Label: zoneLabel, Channels: make([]ZoneStatistics, len(layer.Channels))}
+				found[zoneLabel] = zone
+			}
+			if sampleErr = bound(coord, sample); sampleErr != nil {
+				return
+			}
+			zone.Samples++
+			for c, value := range sample {
+				zone.Channels[c].add(value)
+			}
+		})
+		if sampleErr != nil {
+			return nil, sampleErr
+		}
+	}
+
+	result := make([]Zone, 0, len(found))
+	for _, label := range slices.Sorted(maps.Keys(found)) {
+		result = append(result, *found[label])
+	}
+	return result, nil
+}
+
+// The zone label of a value of an integer or boolean channel; unsigned 64-bit labels above the range of
+// int64 wrap around.
+func labelValue(value any) int64 {
+	switch v := value.(type) {
+	case int8:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case int64:
+		return v
+	case uint64:
+		return int64(v)
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	default:
+		return 0
+	}
+}
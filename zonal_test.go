@@ -0,0 +1,85 @@
+package gopixi
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/gracefulearth/gopixi/internal/buffer"
+)
+
+func TestZonalStats(t *testing.T) {
+	values := DimensionSet{{Name: "x", Size: 7, TileSize: 3}, {Name: "y", Size: 5, TileSize: 2}, {Name: "t", Size: 3, TileSize: 2}}
+	plane := DimensionSet{{Name: "x", Size: 7, TileSize: 4}, {Name: "y", Size: 5, TileSize: 5}}
+	value := func(coord SampleCoordinate) float64 { return float64(coord[0] + 10*coord[1] + 100*coord[2]) }
+	label := func(coord SampleCoordinate) int16 { return int16((coord[0]/2+coord[1])%4 - 1) }
+	buf := buffer.NewBuffer(10)
+	p := writeTestPixi(t, buf, NewHeader(binary.LittleEndian, OffsetSize4), nil, []Layer{
+		NewLayer("values", values, ChannelSet{{Name: "v", Type: ChannelFloat32}, {Name: "w", Type: ChannelUint8}}, WithPlanar()),
+		NewLayer("zones", plane, ChannelSet{{Name: "zone", Type: ChannelInt16}}),
+	}, func(layer int, coord SampleCoordinate) Sample {
+		if layer == 1 {
+			return Sample{label(coord)}
+		}
+		v := value(coord)
+		if coord[0] == 3 {
+			v = -1 // missing
+		}
+		return Sample{float32(v), uint8(coord[2])}
+	})
+	fill, zoneFill := -1.0, -1.0
+	zones, err := ZonalStats(
+		LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 4), &fill),
+		LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[1], 2), &zoneFill))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int64]*Zone{}
+	for coord := range values.SampleCoordinates() {
+		zoneLabel := int64(label(coord[:2]))
+		if zoneLabel == -1 {
+			continue
+		}
+		zone, ok := expected[zoneLabel]
+		if !ok {
+			zone = &Zone{Label: zoneLabel, Channels: make([]ZoneStatistics, 2)}
+			expected[zoneLabel] = zone
+		}
+		zone.Samples++
+		v := value(coord)
+		if coord[0] == 3 {
+			v = math.NaN()
+		}
+		zone.Channels[0].add(v)
+		zone.Channels[1].add(float64(coord[2]))
+	}
+	if len(zones) != len(expected) {
+		t.Fatalf("expected %d zones, got %d", len(expected), len(zones))
+	}
+	for i, zone := range zones {
+		if i > 0 && zones[i-1].Label >= zone.Label {
+			t.Errorf("zones are not in label order: %v", zones)
+		}
+		want := expected[zone.Label]
+		if want == nil || zone.Samples != want.Samples {
+			t.Fatalf("unexpected zone %+v", zone)
+		}
+		for c := range want.Channels {
+			if zone.Channels[c] != want.Channels[c] {
+				t.Errorf("zone %d channel %d: expected %+v, got %+v", zone.Label, c, want.Channels[c], zone.Channels[c])
+			}
+		}
+	}
+	if mean := zones[0].Channels[1].Mean(); mean != zones[0].Channels[1].Sum/float64(zones[0].Channels[1].Count) {
+		t.Errorf("unexpected mean %v", mean)
+	}
+	if mean := (ZoneStatistics{}).Mean(); !math.IsNaN(mean) {
+		t.Errorf("expected the mean of an empty zone to be NaN, got %v", mean)
+	}
+
+	if _, err := ZonalStats(LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[1], 2), nil),
+		LayerOperand(NewFifoCacheReadLayer(buf, p.Header, p.Layers[0], 2), nil)); err == nil {
+		t.Error("expected an error for floating point labels")
+	}
+}